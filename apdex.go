@@ -0,0 +1,31 @@
+package beeline
+
+import "time"
+
+// apdexHook returns a field hook that classifies each HTTP request span by
+// how satisfied its user is likely to be, using the Apdex definition: a
+// request taking up to threshold satisfies, up to four times threshold is
+// tolerable and anything slower, or failing with a 5xx, frustrates.
+func apdexHook(threshold time.Duration) func(map[string]interface{}) {
+	thresholdMS := float64(threshold) / float64(time.Millisecond)
+	return func(fields map[string]interface{}) {
+		if fields["meta.type"] != "http_request" {
+			return
+		}
+		dur, ok := fields["duration_ms"].(float64)
+		if !ok {
+			return
+		}
+		class, score := "frustrated", 0.0
+		status, _ := fields["response.status_code"].(int)
+		switch {
+		case status >= 500:
+		case dur <= thresholdMS:
+			class, score = "satisfied", 1
+		case dur <= 4*thresholdMS:
+			class, score = "tolerating", 0.5
+		}
+		fields["apdex.class"] = class
+		fields["apdex.score"] = score
+	}
+}
@@ -0,0 +1,65 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApdexHook(t *testing.T) {
+	hook := apdexHook(100 * time.Millisecond)
+	tests := []struct {
+		dur    float64
+		status int
+		class  string
+		score  float64
+	}{
+		{50, 200, "satisfied", 1},
+		{100, 200, "satisfied", 1},
+		{250, 200, "tolerating", 0.5},
+		{401, 200, "frustrated", 0},
+		{10, 503, "frustrated", 0},
+		{10, 404, "satisfied", 1},
+	}
+	for _, tt := range tests {
+		fields := map[string]interface{}{
+			"meta.type":            "http_request",
+			"duration_ms":          tt.dur,
+			"response.status_code": tt.status,
+		}
+		hook(fields)
+		assert.Equal(t, tt.class, fields["apdex.class"], "%vms with status %d", tt.dur, tt.status)
+		assert.Equal(t, tt.score, fields["apdex.score"])
+	}
+
+	fields := map[string]interface{}{"duration_ms": float64(10)}
+	hook(fields)
+	assert.NotContains(t, fields, "apdex.class", "only HTTP requests are classified")
+}
+
+func TestApdexConfig(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client, ApdexThreshold: time.Hour})
+	defer setupLibhoney(t)
+
+	_, span := StartSpan(context.Background(), "request")
+	span.AddField("meta.type", "http_request")
+	span.AddField("response.status_code", 200)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "satisfied", evs[0].Data["apdex.class"])
+	}
+}
@@ -0,0 +1,62 @@
+package beeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// CopyContext returns a context with the values in ctx, including its trace
+// and span, that is never canceled and has no deadline. Use it for work that
+// carries on after the request that started it is done, whose context is
+// canceled when its handler returns.
+func CopyContext(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// detachedContext keeps the values of its parent but none of its
+// cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// StartAsyncSpan starts a span for work done in a new goroutine that may
+// outlive the span in ctx, and returns a context from CopyContext holding
+// it. The goroutine must send the span when its work is done:
+//
+//	ctx, span := beeline.StartAsyncSpan(r.Context(), "send_receipt")
+//	go func() {
+//		defer span.Send()
+//		sendReceipt(ctx, order)
+//	}()
+//
+// The span is a child of the span in ctx, in a separate copy of its trace
+// made with trace.Span.CreateDetachedChild, so it can be used after the
+// request's spans are sent without racing with them. It has the trace level
+// fields the trace has when it's started. If ctx has no span, it is the root
+// of a new trace.
+func StartAsyncSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	return std.StartAsyncSpan(ctx, name)
+}
+
+// StartAsyncSpan starts a span for work done in a new goroutine, as
+// StartAsyncSpan does. If ctx has no span, it starts a new trace sent by b.
+func (b *Beeline) StartAsyncSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	ctx = CopyContext(ctx)
+	var span *trace.Span
+	if parent := trace.GetSpanFromContext(ctx); parent != nil {
+		ctx, span = parent.CreateDetachedChild(ctx)
+	} else {
+		var tr *trace.Trace
+		ctx, tr = trace.NewTrace(b.Context(ctx), "")
+		span = tr.GetRootSpan()
+	}
+	span.AddField("name", name)
+	return ctx, span
+}
@@ -0,0 +1,63 @@
+package beeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyContext(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "v"))
+	ctx := CopyContext(parent)
+	cancel()
+	assert.Equal(t, "v", ctx.Value(key{}))
+	assert.NoError(t, ctx.Err())
+	assert.Nil(t, ctx.Done())
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func TestStartAsyncSpan(t *testing.T) {
+	mo := setupLibhoney(t)
+	reqCtx, cancel := context.WithCancel(context.Background())
+	reqCtx, req := StartSpan(reqCtx, "request")
+	AddFieldToTrace(reqCtx, "user", "ada")
+
+	ctx, span := StartAsyncSpan(reqCtx, "send_receipt")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	started := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		defer span.Send()
+		<-started
+		assert.NoError(t, ctx.Err(), "the request's cancellation shouldn't reach async work")
+		AddFieldToTrace(ctx, "receipt", 1)
+		_, child := StartSpan(ctx, "render")
+		child.Send()
+	}()
+	req.Send()
+	cancel()
+	close(started)
+	wg.Wait()
+
+	_, orphan := StartAsyncSpan(context.Background(), "cleanup")
+	orphan.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 4, len(evs)) {
+		return
+	}
+	r, render, async := evs[0].Data, evs[1].Data, evs[2].Data
+	assert.Equal(t, "send_receipt", async["name"])
+	assert.Equal(t, r["trace.trace_id"], async["trace.trace_id"])
+	assert.Equal(t, r["trace.span_id"], async["trace.parent_id"])
+	assert.Equal(t, "ada", async["app.user"])
+	assert.Equal(t, 1, async["app.receipt"])
+	assert.NotContains(t, r, "app.receipt")
+	assert.Equal(t, async["trace.span_id"], render["trace.parent_id"])
+	assert.NotContains(t, evs[3].Data, "trace.parent_id")
+}
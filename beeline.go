@@ -2,22 +2,37 @@ package beeline
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
 	"github.com/honeycombio/libhoney-go/transmission"
 
-	"github.com/honeycombio/beeline-go/client"
+	"github.com/honeycombio/beeline-go/propagation"
 	"github.com/honeycombio/beeline-go/sample"
 	"github.com/honeycombio/beeline-go/trace"
+	dynsampler "github.com/honeycombio/dynsampler-go"
 	libhoney "github.com/honeycombio/libhoney-go"
 )
 
+// std is the default beeline, set up by Init, that the package level
+// functions use. Its client is the one set with client.Set and its config is
+// trace.GlobalConfig, so code using those directly sees the same.
+var std = &Beeline{config: &trace.GlobalConfig}
+
 const (
 	defaultWriteKey   = "apikey-placeholder"
 	defaultDataset    = "beeline-go"
 	defaultSampleRate = 1
+	// defaultTraceIDResponseHeader is the header SendTraceIDResponseHeader
+	// sets when TraceIDResponseHeader isn't.
+	defaultTraceIDResponseHeader = "X-Honeycomb-Trace-Id"
+	// defaultHTTPBodyCaptureBytes and maxHTTPBodyCaptureBytes bound how much
+	// of a request body is recorded.
+	defaultHTTPBodyCaptureBytes = 1024
+	maxHTTPBodyCaptureBytes     = 64 * 1024
 )
 
 // Config is the place where you configure your Honeycomb write key and dataset
@@ -34,9 +49,34 @@ type Config struct {
 	// field is extremely valuable when you instrument multiple services. If set
 	// it will be added to all events as `service_name`
 	ServiceName string
-	// SamplRate is a positive integer indicating the rate at which to sample
+	// ServiceVersion identifies the version of your application being run,
+	// eg a release number or commit SHA. If set it will be added to all
+	// events as `service_version`, and used in the startup marker.
+	ServiceVersion string
+	// Environment names the deployment your application is running in, eg
+	// "production" or "staging". If set it will be added to all events as
+	// `environment`.
+	Environment string
+	// DisableResourceDetection, when true, stops Init from looking for the
+	// container and Kubernetes pod the application runs in. Otherwise every
+	// event gets the container's ID as `container_id`, if one is found, and
+	// in Kubernetes the pod's name, namespace, node and UID as
+	// `k8s.pod_name`, `k8s.namespace`, `k8s.node_name` and `k8s.pod_uid`,
+	// taken from the environment variables the downward API is usually set
+	// up to fill in, such as POD_NAME, POD_NAMESPACE and NODE_NAME. The
+	// hostname is always added as `meta.local_hostname`. default: false
+	DisableResourceDetection bool
+	// SendStartupMarker, when true, creates a Honeycomb marker of type
+	// "deploy" on the dataset when Init is called, labelled with the service
+	// name, version and hostname, so deploys show up on graphs. It is sent in
+	// the background and needs WriteKey to be set. Use SendMarker for markers
+	// of your own. default: false
+	SendStartupMarker bool
+	// SampleRate is a positive integer indicating the rate at which to sample
 	// events. Default sampling is at the trace level - entire traces will be
-	// kept or dropped. default: 1 (meaning no sampling)
+	// kept or dropped, decided deterministically by the trace ID so that
+	// every service in a trace makes the same decision when they share a
+	// sample rate. default: 1 (meaning no sampling)
 	SampleRate uint
 	// SamplerHook is a function that will get run with the contents of each
 	// event just before sending the event to Honeycomb. Register a function
@@ -44,23 +84,426 @@ type Config struct {
 	// beeline. The function should return true if the event should be kept and
 	// false if it should be dropped.  If it should be kept, the returned
 	// integer is the sample rate that has been applied. The SamplerHook
-	// overrides the default sampler. Runs before the PresendHook.
+	// overrides the default sampler. Runs before the PresendHook. Spans are
+	// sampled when they are sent, so the fields the wrappers add once a call
+	// finishes, such as `response.status_code`, are there to decide on. Events
+	// the wrappers send outside of a trace, such as DB calls made without a
+	// context, are passed to it too.
 	SamplerHook func(map[string]interface{}) (bool, int)
+	// MaxEventsPerSecondPerRoute, if set, caps the events sent for each
+	// route, so that one busy endpoint can't use up the Honeycomb quota. Each
+	// route may send this many events a second on average, in bursts of up to
+	// a second's worth. The limit applies after sampling, to the events that
+	// would have been sent. The first event sent for a route after some were
+	// dropped carries the number dropped in `meta.rate_limited_count`. Events
+	// with no route, such as most child spans, aren't limited, so traces of a
+	// limited route can be missing their root span. default: 0 (no limit)
+	MaxEventsPerSecondPerRoute float64
+	// RateLimitFields are the fields the route of an event is taken from for
+	// MaxEventsPerSecondPerRoute; the first the event has is used.
+	// default: handler.route, request.path
+	RateLimitFields []string
+	// DynamicSampler, if set, chooses the sample rate of each event from its
+	// key, so that rare events such as errors can be kept at full fidelity
+	// while busy, successful traffic is sampled heavily. Use one of the
+	// samplers from github.com/honeycombio/dynsampler-go, such as
+	// AvgSampleRate; Init starts it. Events are kept or dropped
+	// deterministically by trace ID at their key's rate. Spans with a
+	// different key from their root span, such as child spans without a
+	// status code, may be sampled at a different rate, so traces can be
+	// incomplete. Not used if SamplerHook is set. default: none
+	DynamicSampler dynsampler.Sampler
+	// DynamicSampleKeyFields are the fields whose values, joined by commas,
+	// make the key DynamicSampler picks each event's sample rate by.
+	// default: the route (handler.route or else request.path) and
+	// response.status_code
+	DynamicSampleKeyFields []string
 	// PresendHook is a function call that will get run with the contents of
 	// each event just before sending them to Honeycomb. The function registered
 	// here may mutate the map passed in to add, change, or drop fields from the
 	// event before it gets sent to Honeycomb. Does not get invoked if the event
 	// is going to be dropped because of sampling. Runs after the SamplerHook.
+	// Like the SamplerHook it sees every span and every event the wrappers
+	// send, so it is the place to redact values such as `db.query_args` or
+	// request headers.
 	PresendHook func(map[string]interface{})
+	// RefineryCompatible, when true, makes the beeline work well behind
+	// Refinery, Honeycomb's tail sampling proxy, which needs every span of a
+	// trace to keep or drop it as a whole. The sample rate each trace is
+	// head sampled at, if any, is recorded on all of its spans as
+	// `meta.refinery.sample_rate` and passed to downstream services in the
+	// trace context, and traces continued from upstream are sampled at the
+	// rate passed along, so every service keeps the same traces and Refinery
+	// can weight them correctly. Traces sampled by a SamplerHook pass no
+	// rate on. MaxEventsPerSecondPerRoute drops single spans, so it can't be
+	// used with this. default: false
+	RefineryCompatible bool
+	// DatasetResolver, if set, chooses the dataset each span and event is
+	// sent to, so one beeline can send eg HTTP request spans to one dataset
+	// and DB spans to another. It is called with the event's fields after
+	// the PresendHook, and returns the dataset to send it to, or "" to send
+	// it where it was going. Only the event is moved: trace context passed
+	// to other services still names the trace's dataset. DatasetsByMetaType
+	// builds a resolver that routes by `meta.type`. default: nil
+	DatasetResolver func(fields map[string]interface{}) string
+	// RecordDurationNanos, when true, adds a `duration_ns` field to every span
+	// and DB event alongside `duration_ms`. It holds the exact duration as an
+	// integer number of nanoseconds, which is useful when comparing very fast
+	// operations such as cache or DB calls. default: false
+	RecordDurationNanos bool
+	// DBCallerPackages lists the import paths of packages that DB calls are
+	// made through, such as a service's own data access layer, in addition to
+	// the beeline's DB wrappers. Each DB span names the function the call was
+	// made with in `db.call`, and the function that called it in `db.caller`;
+	// functions in these packages are taken to be part of the call, so that
+	// `db.call` becomes the data access function and `db.caller` the code
+	// using it. default: nil
+	DBCallerPackages []string
+	// IgnorePropagatedIDs, when true, makes every incoming request start a
+	// brand new trace instead of continuing the trace described by upstream
+	// trace headers. Trace header values are controlled by whoever sends the
+	// request, so services at the edge of your infrastructure may not want to
+	// trust them. The upstream IDs are recorded on the root span as
+	// `trace.upstream_trace_id` and `trace.upstream_parent_id` so the traces
+	// can still be correlated. Any dataset named by upstream is ignored too.
+	// Trace level fields from upstream are still added. default: false
+	IgnorePropagatedIDs bool
+	// MaxRollupFields caps the number of distinct rollup keys (see
+	// Span.AddRollupField) tracked by each trace. Once a trace has this many,
+	// values for new keys are summed into `rollup.other` so that code making
+	// calls with many distinct names can't grow the root span past the API's
+	// size limits. default: 100
+	MaxRollupFields int
+	// RollupDimensions lists span fields to total span durations by. For each
+	// span with one of these fields, the root span gets the total duration and
+	// number of spans with each value of it, eg with "peer.service" listed a
+	// span with `peer.service` of "billing" adds to
+	// `rollup.peer.service.billing.duration_ms` and
+	// `rollup.peer.service.billing.count`. Values count towards
+	// MaxRollupFields, so pick fields with few distinct values.
+	// default: none
+	RollupDimensions []string
+	// MaxSpanDepth limits how deeply spans can nest below the root span, and
+	// MaxChildrenPerSpan how many children a span can have, so that runaway
+	// recursive instrumentation can't create millions of spans for one
+	// request. Spans over either limit are not sent. Instead each parent with
+	// spans over the limit gets one child span named "aggregated", with the
+	// number of spans it stands in for in `meta.aggregated_count`, their total
+	// duration in `meta.aggregated_duration_ms` and which limit they were over
+	// in `meta.aggregated_reason` ("depth" or "fan_out"). Spans created below
+	// an aggregated span are aggregated with it. default: 0 (unlimited)
+	MaxSpanDepth       int
+	MaxChildrenPerSpan int
+	// InProgressAfter, when set, sends an event for each span that has been
+	// open this long without being sent, so requests stuck for minutes are
+	// visible before they finish. The event has the span's fields so far,
+	// its duration so far and `meta.in_progress` set to true, and is a child
+	// of the span with a `meta.span_type` of "in_progress". Each span is
+	// reported once. Spans are checked from a background goroutine, and
+	// tracking them has a small cost on every span. default: 0 (disabled)
+	InProgressAfter time.Duration
+	// TrackOpenSpans keeps a list of the spans that haven't been sent yet, so
+	// that Shutdown can send those still open when the process exits. It is
+	// implied by InProgressAfter. default: false
+	TrackOpenSpans bool
+	// RecordErrorStacks, when true, adds the stack trace of the code calling
+	// AddError (or Span.AddError) to the span as `error.stack`. Capturing a
+	// stack is relatively expensive, so leave it off for code that records
+	// errors on hot paths. default: false
+	RecordErrorStacks bool
+	// IDGenerator, if set, makes the IDs of new traces and spans, for
+	// services that need IDs in a particular format, eg to match IDs made by
+	// another tracing system. The trace and span IDs it returns must be
+	// unique and printable ASCII; an empty ID is replaced by a random one.
+	// IDs propagated from upstream services are used as they are.
+	// default: random 16 byte trace IDs and 8 byte span IDs, hex encoded
+	IDGenerator trace.IDGenerator
+	// IDFormat chooses the format of the IDs made for new traces when
+	// IDGenerator isn't set: trace.IDFormatHex for 32 hex character trace
+	// IDs, trace.IDFormatHex64 for 16 hex character trace IDs, or
+	// trace.IDFormatUUID for UUIDs with dashes, as older beelines made. Span
+	// IDs are always 16 hex characters. Trace IDs in either of the other
+	// formats are converted as they are sent in and parsed from W3C and B3
+	// headers, so IDs in this format come back unchanged from services that
+	// only speak those. default: trace.IDFormatHex
+	IDFormat trace.IDFormat
+	// SendTraceIDResponseHeader, when true, makes the HTTP wrappers return
+	// the trace ID of each request in a response header, so that a customer
+	// reporting a problem can quote it and support can paste it straight into
+	// Honeycomb. The header is set before the handler runs. Use
+	// TraceIDFromContext to put the ID in error pages or logs too.
+	// default: false
+	SendTraceIDResponseHeader bool
+	// TraceIDResponseHeader names the header SendTraceIDResponseHeader sets.
+	// default: X-Honeycomb-Trace-Id
+	TraceIDResponseHeader string
+	// EchoAmazonTraceHeader, when true, makes the HTTP wrappers copy the
+	// X-Amzn-Trace-Id header an AWS load balancer added to a request onto
+	// the response, so that a client can quote it to find the request in the
+	// load balancer's logs and in Honeycomb. Request spans always record the
+	// header's Root, Self and Parent as `request.aws.trace_root`,
+	// `request.aws.self` and `request.aws.parent`. default: false
+	EchoAmazonTraceHeader bool
+	// SpanEventsAsField, when true, keeps the events added with AddEvent (or
+	// Span.AddEvent) with their span and sends them in its `span_events`
+	// field, a list of each event's fields with its `name`, `timestamp` and
+	// `offset_ms` since the span started, rather than sending each as a span
+	// event of its own. It costs fewer events, but Honeycomb won't draw them
+	// on the trace timeline. default: false
+	SpanEventsAsField bool
+	// B3Format sends B3 (Zipkin) trace headers, in the single b3 header or
+	// the X-B3-* headers, with outbound HTTP calls made by the wrappers, so
+	// services traced with Zipkin continue the trace. Incoming B3 headers are
+	// always used to continue a trace when there is no Honeycomb or W3C
+	// header. default: propagation.B3None
+	B3Format propagation.B3Format
+	// DBQueryMode chooses how the DB wrappers record queries in `db.query`:
+	// trace.DBQueryRaw sends them as they are, trace.DBQueryNormalized
+	// replaces their literal values with ?, eg `WHERE id = 42` becomes
+	// `WHERE id = ?`, and trace.DBQueryOmitted leaves them out. Normalizing
+	// keeps values such as email addresses that are written into queries out
+	// of Honeycomb, and makes `db.query` useful for grouping. default:
+	// trace.DBQueryRaw
+	DBQueryMode trace.DBQueryMode
+	// OmitDBQueryArgs, when true, stops the DB wrappers from sending the
+	// arguments of queries in `db.query_args`. default: false
+	OmitDBQueryArgs bool
+	// HTTPHeadersToCapture lists request headers, eg "Accept" or
+	// "X-Request-Source", that the HTTP wrappers record on request spans as
+	// `request.header.<name>`, lower cased with dashes replaced by
+	// underscores, eg `request.header.x_request_source`. Names are matched
+	// case-insensitively. The values of Authorization, Proxy-Authorization
+	// and Cookie are always replaced with "[REDACTED]". User-Agent,
+	// X-Forwarded-For and X-Forwarded-Proto are recorded whether or not they
+	// are listed. default: none
+	HTTPHeadersToCapture []string
+	// HTTPQueryParamsToCapture lists URL query parameters the HTTP wrappers
+	// record on request spans as `request.query_param.<name>`, lower cased
+	// with dashes replaced by underscores, so traffic can be broken down by
+	// them. "*" records every parameter. Parameters given more than once are
+	// joined with commas. The values of parameters whose names look like
+	// they hold secrets, containing "token", "key", "password", "secret",
+	// "auth", "signature" or "session", are replaced with "[REDACTED]". The
+	// raw query is still recorded as `request.query`. default: none
+	HTTPQueryParamsToCapture []string
+	// HTTPQueryParamsToOmit lists query parameters that are never recorded
+	// individually, even when HTTPQueryParamsToCapture is "*". default: none
+	HTTPQueryParamsToOmit []string
+	// HTTPBodyContentTypes lists the media types, eg "application/json",
+	// of the request bodies the HTTP wrappers record the start of as
+	// `request.body`, to help debug requests rejected as malformed. A type
+	// ending in "/*" matches any subtype. Bodies sent gzip or deflate
+	// encoded are decoded first. Up to HTTPBodyCaptureBytes are read from
+	// the body when the request's span starts, and put back for the handler
+	// to read as usual; `request.body_truncated` is set if there was more.
+	// The Content-Type and Content-Encoding headers are always recorded as
+	// `request.header.content_type` and `request.header.content_encoding`.
+	// Handlers that take secrets can be wrapped with hnynethttp's
+	// WithoutRequestBodyFields to keep their bodies out. default: none
+	HTTPBodyContentTypes []string
+	// HTTPBodyCaptureBytes caps how much of a request body is recorded. It
+	// can't be more than 64KiB. default: 1024
+	HTTPBodyCaptureBytes int
+	// HTTPBodyRedactor, if set, is given each captured body, decoded, with
+	// the request's media type, and returns what is recorded instead, eg with
+	// passwords or card numbers masked. Returning nil records nothing.
+	// default: nil
+	HTTPBodyRedactor func(contentType string, body []byte) []byte
+	// TrustedProxies lists the proxies and load balancers, as IP addresses or
+	// CIDR ranges such as "10.0.0.0/8", whose forwarding headers the HTTP
+	// wrappers believe when recording the client's address as
+	// `request.client_ip`. The address a request came from is the client's
+	// unless it's a trusted proxy, in which case the addresses in the RFC
+	// 7239 Forwarded header, or else X-Forwarded-For, are checked from the
+	// most recent back, and the first that isn't a trusted proxy is the
+	// client's. X-Real-IP is used if a trusted proxy sent neither. Invalid
+	// entries are skipped. default: the loopback and private address ranges
+	TrustedProxies []string
+	// IgnoreClientIPHeaders, when true, records the address each request came
+	// from as `request.client_ip` without looking at any forwarding headers,
+	// for services that take requests straight from the internet, where the
+	// headers could say anything. default: false
+	IgnoreClientIPHeaders bool
+	// ParseUserAgents, when true, has the HTTP wrappers parse each request's
+	// User-Agent header into `request.ua.browser`, `request.ua.os`,
+	// `request.ua.device` (desktop, mobile, tablet or bot) and
+	// `request.ua.is_bot`, so traffic from people can be told apart from
+	// crawlers, uptime checks and scripts without parsing the header in
+	// queries. Only well known browsers and operating systems are named.
+	// default: false
+	ParseUserAgents bool
+	// IgnoreHTTPPaths lists request paths the HTTP wrappers don't trace at
+	// all, such as health checks and metrics scrapes that would otherwise
+	// make up most of the events sent. Paths are matched exactly, or as
+	// patterns with path.Match, so "/healthz" and "/debug/*" both work.
+	// Requests to them are passed to the handler with no span in their
+	// context, so nothing the handler does is traced either. default: none
+	IgnoreHTTPPaths []string
+	// IgnoreHTTPRequest, when set, is called with each request the HTTP
+	// wrappers see, and requests it returns true for are not traced, as for
+	// IgnoreHTTPPaths. default: nil
+	IgnoreHTTPRequest func(*http.Request) bool
+	// HTTPErrorStatus is the lowest response status that marks an HTTP
+	// request as failed. Every request span gets the class of its status,
+	// eg "2xx" or "5xx", as `response.status_class`, and those with a status
+	// of HTTPErrorStatus or more get `error` set to true, unless an error was
+	// already recorded on the span. Set it to 400 to count client errors
+	// too, or above 599 to leave `error` alone. default: 500
+	HTTPErrorStatus int
+	// LatencySLOs sets a latency target for HTTP requests to each route,
+	// keyed by the route as the wrapper records it (eg `handler.route` for
+	// gorilla or `handler.pattern` for a ServeMux, falling back to
+	// `request.path`). Requests with a target get `slo.target_ms`,
+	// `slo.violated` and `slo.margin_ms`, which is negative when the target
+	// was missed. default: none
+	LatencySLOs map[string]time.Duration
+	// DefaultLatencySLO is the latency target for routes not in LatencySLOs.
+	// default: none
+	DefaultLatencySLO time.Duration
+	// TrackIngestVolume, when true, counts the events sent and their size,
+	// per dataset and per HTTP route, so GetIngestVolume can project monthly
+	// volume. Encoding each event to measure it has a cost, so this is best
+	// turned on for a while to size sampling rather than left on.
+	// default: false
+	// Not used if client is set
+	TrackIngestVolume bool
+	// IngestSummaryInterval, if set along with TrackIngestVolume, sends an
+	// `ingest_summary` event every interval with the volume of the interval
+	// just finished and its monthly projection.
+	// Not used if client is set
+	IngestSummaryInterval time.Duration
+	// RuntimeMetricsInterval, when set, sends a `runtime_metrics` event every
+	// interval with Go runtime stats, so resource regressions show up
+	// alongside request data: the number of goroutines, heap and system
+	// memory in use, the number of GCs and their total pause time, both
+	// since the process started and during the interval, and, on systems
+	// with /proc, the number of open file descriptors. Like every event it
+	// has `service_name` and `meta.local_hostname`. Reading the stats briefly
+	// stops the world, so keep the interval to seconds or more.
+	// default: 0 (disabled)
+	RuntimeMetricsInterval time.Duration
+	// ApdexThreshold, if set, classifies every HTTP request by Apdex: those
+	// taking up to the threshold are "satisfied", up to four times it
+	// "tolerating" and slower requests or 5xx responses "frustrated". The
+	// class is recorded as `apdex.class`, and `apdex.score` holds 1, 0.5 or 0
+	// respectively so that its average is the Apdex score. default: none
+	ApdexThreshold time.Duration
+	// FieldSchema declares the type of fields, keyed by their name as sent
+	// (so those added with AddField need their `app.` prefix). Each declared
+	// field is converted to its type before the span is sent, eg the string
+	// "42" to an int64 for a FieldInt, so that a column in Honeycomb never
+	// mixes types. A field that can't be converted is dropped and its name
+	// added to `meta.schema_violations`. Conversion happens before the SLO,
+	// Apdex and DerivedFields hooks run. default: none
+	FieldSchema map[string]FieldType
+	// TenantFunc, if set, is called by the HTTP wrappers with each request
+	// that starts a new trace, and returns the tenant the request belongs to,
+	// eg with TenantFromHeader or TenantFromSubdomain. A non-empty tenant is
+	// added to every span of the trace as `tenant` and passed on to
+	// downstream services. default: none
+	TenantFunc func(r *http.Request) string
+	// TenantDatasets maps tenants found by TenantFunc to the dataset their
+	// traces are sent to, in place of Dataset. Downstream services are asked
+	// to send their spans to the same dataset. Tenants not listed use
+	// Dataset. default: none
+	TenantDatasets map[string]string
+	// TraceFieldsFunc, if set, is called with the request by
+	// hnynethttp.TraceFieldsMiddleware, which goes after any authentication
+	// middleware so that it sees what that put in the request's context, eg
+	// the user, account and plan from the claims of a JWT. The fields it
+	// returns are added to the request's trace, prefixed with `app.` as with
+	// AddFieldToTrace, so every span of the trace can be queried by
+	// customer. default: none
+	TraceFieldsFunc func(r *http.Request) map[string]interface{}
+	// DerivedFields are run, in order, on the fields of every span just before
+	// it is sent, and may add fields computed from the others, eg bucketing
+	// `duration_ms` into a latency class or pulling the top level domain out
+	// of `request.host`. They see fields as sent, so those added with AddField
+	// have their `app.` prefix, and they run after the SLO and Apdex fields
+	// are added and before the SamplerHook, so both can use what they add.
+	// They are called with the span's lock held and must not call back into
+	// the beeline. default: none
+	DerivedFields []func(fields map[string]interface{})
+	// OTelFieldNames, when true, renames the fields recorded by the wrappers
+	// to their OpenTelemetry semantic convention names just before spans are
+	// sent, eg `request.method` to `http.request.method`, `request.path` to
+	// `url.path` and `db.query` to `db.statement`, so queries and boards can
+	// be shared with services instrumented with OpenTelemetry. Renaming
+	// happens after FieldSchema, the SLO and Apdex fields and DerivedFields,
+	// which all use the beeline's names, and before the SamplerHook and
+	// PresendHook, which see the new ones. default: false
+	OTelFieldNames bool
+	// FieldNames renames fields just before spans and events are sent, to
+	// fit them to an in-house schema, eg {"request.method": "http.method"}.
+	// A key ending in "." renames every field starting with it, so
+	// {"request.header.": "http.header."} moves all the captured headers.
+	// Exact names win over prefixes, and the longest prefix wins over shorter
+	// ones. Renaming happens after OTelFieldNames, so it can adjust the
+	// OpenTelemetry names too, and like it, before the SamplerHook and
+	// PresendHook. A field renamed onto one that is already set replaces it.
+	// default: none
+	FieldNames map[string]string
+
+	// RemoteConfigURL, if set, is fetched every RemoteConfigInterval for a
+	// RemoteConfig of sampling rules, fields to scrub and the fields allowed
+	// to be sent, so they can be changed across many services without a
+	// redeploy. Responses must be signed with the private key matching
+	// RemoteConfigPublicKey, the signature sent base64 encoded in the
+	// X-Beeline-Signature header; any that aren't, or fail to fetch, are
+	// ignored and the config in use is kept. Each fetch replaces all of the
+	// previous remote config at once. The first fetch happens in the
+	// background; call RefreshRemoteConfig to wait for it. default: none
+	RemoteConfigURL string
+	// RemoteConfigPublicKey is the ed25519 public key remote configs are
+	// verified with.
+	RemoteConfigPublicKey []byte
+	// RemoteConfigInterval is how often the remote config is fetched.
+	// default: 1 minute
+	RemoteConfigInterval time.Duration
 
 	// APIHost is the hostname for the Honeycomb API server to which to send
 	// this event. default: https://api.honeycomb.io/
 	// Not used if client is set
 	APIHost string
+	// ProxyURL is the URL of the HTTP proxy, eg "http://proxy.corp:3128",
+	// events are sent through, along with markers and remote config fetches.
+	// default: from the HTTPS_PROXY and NO_PROXY environment variables
+	// Not used if client or Transport is set
+	ProxyURL string
+	// TLSConfig, if set, is the TLS configuration used to connect to APIHost,
+	// eg with client certificates for a collector that requires them.
+	// default: nil
+	// Not used if client or Transport is set
+	TLSConfig *tls.Config
+	// CACertFile names a PEM file of CA certificates to trust when
+	// connecting to APIHost, in addition to the system's, for collectors
+	// such as Refinery with certificates signed by a private CA.
+	// default: none
+	// Not used if client or Transport is set
+	CACertFile string
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout bound how
+	// long connecting to APIHost, the TLS handshake and waiting for a
+	// response may take. default: those of http.DefaultTransport
+	// Not used if client or Transport is set
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	// Transport, if set, is used to send events, markers and remote config
+	// fetches in place of one built from the settings above. default: nil
+	// Not used if client is set
+	Transport http.RoundTripper
 	// STDOUT when set to true will print events to STDOUT *instead* of sending
 	// them to honeycomb; useful for development. default: false
 	// Not used if client is set
 	STDOUT bool
+	// STDOUTFormat chooses how events are printed when STDOUT is set:
+	// STDOUTJSON prints each event as a line of JSON, the way it would be
+	// sent, and STDOUTTree holds on to the spans of each trace until its root
+	// is sent and prints the whole trace as an indented tree, with each
+	// span's name, duration and fields. No API key is needed for either.
+	// default: STDOUTJSON
+	// Not used if client is set
+	STDOUTFormat STDOUTFormat
 	// Mute when set to true will disable Honeycomb entirely; useful for tests
 	// and CI. default: false
 	// Not used if client is set
@@ -81,20 +524,361 @@ type Config struct {
 	// goroutines (libhoney.DefaultMaxConcurrentBatches) that are used to send batches of events in parallel.
 	// Not used if client is set
 	MaxConcurrentBatches uint
+	// EnableMsgpackEncoding, when true, encodes batches of events as msgpack
+	// instead of JSON, which is cheaper to produce and smaller on the wire at
+	// high event volumes. default: false
+	// Not used if client is set
+	EnableMsgpackEncoding bool
+	// DisableCompression, when true, sends batches of events uncompressed.
+	// Batches are otherwise compressed with zstd, which costs a little CPU
+	// but greatly reduces the bytes sent. default: false
+	// Not used if client is set
+	DisableCompression bool
 	// PendingWorkCapacity overrides the default event queue size (libhoney.DefaultPendingWorkCapacity).
-	// If the queue is full, events will be dropped.
+	// If the queue is full, events are dropped or the caller waits, as the
+	// OverflowPolicy says.
 	// Not used if client is set
 	PendingWorkCapacity uint
+	// TransmissionErrorHandler, if set, is called with the response for every
+	// event that could not be sent to Honeycomb and will not be retried, for
+	// example because the write key was rejected (401) or retries ran out. It
+	// is called from a background goroutine and should not block. Use
+	// LogTransmissionErrors to log them.
+	// Not used if client is set
+	TransmissionErrorHandler func(transmission.Response)
+	// MaxRetries is the number of times an event will be sent again after a
+	// failure that is likely to be temporary: throttling (429), server errors
+	// (5xx) and timeouts or other network errors. default: 0 (no retries)
+	// Not used if client is set
+	MaxRetries uint
+	// RetryBackoff is how long to wait before retrying an event. It doubles
+	// with each retry of the same event, up to one minute. Events still
+	// waiting to be retried are sent immediately on Flush or Close.
+	// default: 100ms
+	// Not used if client is set
+	RetryBackoff time.Duration
+
+	// OverflowPolicy chooses what happens to events sent while
+	// PendingWorkCapacity events are already waiting to be sent: the new
+	// event is dropped, the oldest waiting is dropped to make room, or the
+	// caller waits up to OverflowBlockTimeout for room. Drops under each
+	// policy are counted in TransmissionStats. default: OverflowDropNewest
+	// Not used if client is set
+	OverflowPolicy OverflowPolicy
+	// OverflowBlockTimeout is the longest OverflowBlock makes a caller wait.
+	// default: 100ms
+	OverflowBlockTimeout time.Duration
+	// RecordDroppedEvents, when true, adds `meta.dropped_events_total` to
+	// every root span: the number of events that have failed to be sent since
+	// Init, as counted in TransmissionStats.Failed. It is a running total
+	// rather than a count since the last root span so that sampling can't lose
+	// any drops; graph its MAX to watch for a transmission that can't keep up.
+	// default: false
+	// Not used if client is set
+	RecordDroppedEvents bool
+
+	// SpoolDir, if set, is a directory events are written to while Honeycomb
+	// can't be reached, to be sent once it can. After BreakerThreshold
+	// consecutive server errors, throttling responses or timeouts the breaker
+	// trips and events go straight to the spool, with one let through every
+	// BreakerCooldown to see if the API is back. Events that run out of
+	// retries are spooled too. Spooled events are sent in the background
+	// once an event succeeds, including any left by an earlier process. No
+	// response is sent for an event when it is spooled, and replayed events
+	// have no metadata. Write keys aren't written to the spool, and replayed
+	// events are sent with WriteKey. default: none
+	// Not used if client is set
+	SpoolDir string
+	// SpoolMaxBytes bounds the size of the spool. Events that don't fit are
+	// dropped and reported to the TransmissionErrorHandler.
+	// default: 64MB
+	SpoolMaxBytes int64
+	// EnableBreaker, when true, trips the breaker described under SpoolDir
+	// even without a spool. While it is open events are dropped instead of
+	// being sent, so an unreachable API can't back up the queue and slow the
+	// application down, and counted in TransmissionStats.Shed.
+	// default: false
+	// Not used if client is set
+	EnableBreaker bool
+	// BreakerThreshold is the number of consecutive failures that trips the
+	// breaker when SpoolDir or EnableBreaker is set. default: 5
+	BreakerThreshold uint
+	// BreakerCooldown is how long the breaker waits before letting an event
+	// through to see if the API is back. default: 30s
+	BreakerCooldown time.Duration
+
+	// TransmissionStatsHandler, if set, is called with the TransmissionStats
+	// every TransmissionStatsInterval, to export them as metrics or log them.
+	// It is called from a background goroutine. default: none
+	// Not used if client is set
+	TransmissionStatsHandler func(TransmissionStats)
+	// TransmissionStatsInterval is how often TransmissionStatsHandler is
+	// called. default: 10s
+	TransmissionStatsInterval time.Duration
+
+	// DisableEnvConfig, when true, stops Init from filling in the fields left
+	// unset from environment variables such as HONEYCOMB_API_KEY and
+	// BEELINE_SAMPLE_RATE. See ConfigFromEnv for the full list.
+	// default: false
+	DisableEnvConfig bool
 
 	// Client, if specified, allows overriding the default client used to send events to Honeycomb
 	// If set, overrides many fields in this config - see descriptions
 	Client *libhoney.Client
 }
 
-// Init intializes the honeycomb instrumentation library.
+// Init intializes the honeycomb instrumentation library. Calling Init again
+// closes the client created by the previous call, if there was one, and stops
+// its background goroutines.
+//
+// Init doesn't check the config, and carries on discarding events if the
+// client can't be created. Use InitWithError to find out about mistakes.
+//
+// Fields left unset are taken from the environment, as described by
+// ConfigFromEnv, so Init(Config{}) configures the beeline entirely from
+// environment variables. Variables that can't be parsed are skipped, and
+// reported on stderr if Debug is set.
 func Init(config Config) {
-	userAgentAddition := fmt.Sprintf("beeline/%s", version)
+	config, err := applyEnvConfig(config)
+	if err != nil && config.Debug {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+	initialize(config)
+}
+
+// initialize does the work of Init, returning the first problem that stops
+// the beeline from working as configured. It carries on regardless.
+func initialize(config Config) error {
+	if std.ownsClient {
+		// close the client made by an earlier Init so its transmission
+		// doesn't keep running in the background
+		std.closeClient()
+		std.ownsClient = false
+	}
+	if std.background != nil {
+		// stop anything left running by an earlier Init
+		std.background.stop()
+	}
+	err := std.setup(config)
+	if sampler := trace.GlobalConfig.Sampler; sampler != nil {
+		// for code that samples its own events the way the beeline does
+		sample.GlobalSampler = sampler
+	}
+	trace.ResumeNewSpans()
+	return err
+}
+
+// setup sets b up to send traces as config describes, for Init or New,
+// returning the first problem that stops it from working as configured. It
+// carries on regardless.
+func (b *Beeline) setup(config Config) error {
+	var initErr error
+	b.background = newLifecycle()
+
+	config = applyDefaults(config)
+	transport, err := newTransport(config)
+	if err != nil {
+		initErr = err
+		if config.Debug {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+	}
+	b.setMarkerConfig(config, transport)
+	b.sender = nil
+	b.volume = nil
+	if config.Client == nil {
+		sender := newRetrySender(newBaseTransmission(config, transport), config)
+		if config.SpoolDir != "" {
+			if sp, err := openSpool(config.SpoolDir, config.SpoolMaxBytes); err != nil {
+				initErr = fmt.Errorf("beeline: failed to open spool: %s", err)
+				if config.Debug {
+					fmt.Fprintf(os.Stderr, "beeline: failed to open spool: %s\n", err)
+				}
+			} else {
+				sender.spool = sp
+			}
+		}
+		var tx transmission.Sender = sender
+		var volume *volumeSender
+		if config.TrackIngestVolume {
+			volume = newVolumeSender(sender)
+			tx = volume
+		}
+		c, err := libhoney.NewClient(clientConfig(config, tx))
+		if err != nil {
+			// carry on with the default client so instrumented code keeps
+			// working; events will be discarded
+			initErr = fmt.Errorf("beeline: failed to create libhoney client: %s", err)
+			if config.Debug {
+				fmt.Fprintf(os.Stderr, "beeline: failed to create libhoney client: %s\n", err)
+			}
+			b.setClient(nil)
+		} else {
+			b.setClient(c)
+			b.ownsClient = true
+			b.sender = sender
+			b.volume = volume
+		}
+	} else {
+		b.setClient(config.Client)
+	}
+
+	c := b.Client()
+	addClientFields(c, config)
+
+	if config.SendStartupMarker {
+		m, debug := startupMarker(config), config.Debug
+		b.background.goFunc(func(done <-chan struct{}) {
+			b.sendStartupMarker(m, debug, done)
+		})
+	}
+
+	if v := b.volume; v != nil && config.IngestSummaryInterval > 0 {
+		interval := config.IngestSummaryInterval
+		b.background.goFunc(func(done <-chan struct{}) {
+			reportIngestVolume(c, v, interval, done)
+		})
+	}
+
+	if s := b.sender; s != nil && config.TransmissionStatsHandler != nil {
+		handler, interval := config.TransmissionStatsHandler, config.TransmissionStatsInterval
+		if interval <= 0 {
+			interval = defaultTransmissionStatsInterval
+		}
+		b.background.goFunc(func(done <-chan struct{}) {
+			reportTransmissionStats(s, handler, interval, done)
+		})
+	}
+
+	if config.RuntimeMetricsInterval > 0 {
+		interval := config.RuntimeMetricsInterval
+		b.background.goFunc(func(done <-chan struct{}) {
+			reportRuntimeMetrics(c, interval, done)
+		})
+	}
+
+	if config.Debug {
+		// TODO add more debugging than just the responses queue
+		responses := b.txResponses()
+		b.background.goFunc(func(done <-chan struct{}) {
+			readResponses(responses, done)
+		})
+	}
+
+	var tc trace.Config
+	if config.SamplerHook == nil && config.DynamicSampler != nil {
+		if err := config.DynamicSampler.Start(); err != nil && initErr == nil {
+			initErr = fmt.Errorf("beeline: failed to start dynamic sampler: %s", err)
+		}
+		config.SamplerHook = dynamicSamplerHook(config.DynamicSampler, config.DynamicSampleKeyFields)
+	}
+	// Use the sampler hook if it's defined, otherwise a deterministic sampler
+	tc.SamplerHook = config.SamplerHook
+	if config.SamplerHook == nil {
+		sampler, err := sample.NewDeterministicSampler(config.SampleRate)
+		if err == nil {
+			tc.Sampler = sampler
+		}
+	}
+
+	tc.PresendHook = config.PresendHook
+	tc.DatasetHook = config.DatasetResolver
+	tc.PropagateSampleRate = config.RefineryCompatible
+	tc.SendDecisionHook = countSendDecision
+	if config.MaxEventsPerSecondPerRoute > 0 && !config.RefineryCompatible {
+		limiter := newRateLimiter(config.MaxEventsPerSecondPerRoute, config.RateLimitFields)
+		tc.RateLimitHook = limiter.allow
+	}
+	b.remote = nil
+	if config.RemoteConfigURL != "" {
+		// the remote config can change sampling and filtering at any time, so
+		// its hooks stand in front of the user's
+		b.remote = newRemoteConfig(config, transport, tc.Sampler)
+		tc.SamplerHook = b.remote.sample
+		tc.PresendHook = b.remote.filter
+		b.background.goFunc(b.remote.run)
+	}
+	tc.IgnorePropagatedIDs = config.IgnorePropagatedIDs
+	tc.RecordDurationNanos = config.RecordDurationNanos
+	tc.DBCallerPackages = config.DBCallerPackages
+	tc.MaxRollupFields = config.MaxRollupFields
+	tc.RollupDimensions = config.RollupDimensions
+	tc.MaxSpanDepth = config.MaxSpanDepth
+	tc.MaxChildrenPerSpan = config.MaxChildrenPerSpan
+	tc.TrackOpenSpans = config.TrackOpenSpans || config.InProgressAfter > 0
+	tc.RecordErrorStacks = config.RecordErrorStacks
+	tc.SpanEventsAsField = config.SpanEventsAsField
+	tc.IDGenerator = config.IDGenerator
+	tc.IDFormat = config.IDFormat
+	if config.InProgressAfter > 0 {
+		age := config.InProgressAfter
+		b.background.goFunc(func(done <-chan struct{}) {
+			watchInProgress(age, done)
+		})
+	}
+	tc.B3Format = config.B3Format
+	tc.DBQueryMode = config.DBQueryMode
+	tc.OmitDBQueryArgs = config.OmitDBQueryArgs
+	tc.HTTPHeadersToCapture = config.HTTPHeadersToCapture
+	tc.HTTPQueryParamsToCapture = config.HTTPQueryParamsToCapture
+	tc.HTTPQueryParamsToOmit = config.HTTPQueryParamsToOmit
+	tc.HTTPBodyContentTypes = config.HTTPBodyContentTypes
+	tc.HTTPBodyCaptureBytes = config.HTTPBodyCaptureBytes
+	if tc.HTTPBodyCaptureBytes == 0 {
+		tc.HTTPBodyCaptureBytes = defaultHTTPBodyCaptureBytes
+	}
+	if tc.HTTPBodyCaptureBytes > maxHTTPBodyCaptureBytes {
+		tc.HTTPBodyCaptureBytes = maxHTTPBodyCaptureBytes
+	}
+	tc.HTTPBodyRedactor = config.HTTPBodyRedactor
+	tc.TrustedProxies, _ = parseTrustedProxies(config.TrustedProxies)
+	tc.IgnoreClientIPHeaders = config.IgnoreClientIPHeaders
+	tc.ParseUserAgents = config.ParseUserAgents
+	tc.EchoAmazonTraceHeader = config.EchoAmazonTraceHeader
+	if config.SendTraceIDResponseHeader {
+		tc.TraceIDResponseHeader = config.TraceIDResponseHeader
+		if tc.TraceIDResponseHeader == "" {
+			tc.TraceIDResponseHeader = defaultTraceIDResponseHeader
+		}
+	}
+	if len(config.IgnoreHTTPPaths) > 0 || config.IgnoreHTTPRequest != nil {
+		tc.IgnoreRequestHook = ignoreRequestHook(config.IgnoreHTTPPaths, config.IgnoreHTTPRequest)
+	}
+	if config.TenantFunc != nil {
+		tc.TenantHook = tenantHook(config.TenantFunc, config.TenantDatasets)
+	}
+	tc.TraceFieldsHook = config.TraceFieldsFunc
+	if len(config.FieldSchema) > 0 {
+		tc.FieldHooks = append(tc.FieldHooks, schemaHook(config.FieldSchema))
+	}
+	errorStatus := config.HTTPErrorStatus
+	if errorStatus == 0 {
+		errorStatus = defaultHTTPErrorStatus
+	}
+	tc.FieldHooks = append(tc.FieldHooks, statusHook(errorStatus))
+	if len(config.LatencySLOs) > 0 || config.DefaultLatencySLO > 0 {
+		tc.FieldHooks = append(tc.FieldHooks, sloHook(config.LatencySLOs, config.DefaultLatencySLO))
+	}
+	if config.ApdexThreshold > 0 {
+		tc.FieldHooks = append(tc.FieldHooks, apdexHook(config.ApdexThreshold))
+	}
+	tc.FieldHooks = append(tc.FieldHooks, config.DerivedFields...)
+	if config.RecordDroppedEvents && config.Client == nil {
+		tc.FieldHooks = append(tc.FieldHooks, b.droppedEventsHook)
+	}
+	if config.OTelFieldNames {
+		tc.FieldHooks = append(tc.FieldHooks, otelHook)
+	}
+	if len(config.FieldNames) > 0 {
+		tc.FieldHooks = append(tc.FieldHooks, fieldNamesHook(config.FieldNames))
+	}
+	*b.config = tc
+	return initErr
+}
 
+// applyDefaults fills in the settings left unset in config that have
+// defaults.
+func applyDefaults(config Config) Config {
 	if config.WriteKey == "" {
 		config.WriteKey = defaultWriteKey
 	}
@@ -116,90 +900,118 @@ func Init(config Config) {
 	if config.PendingWorkCapacity == 0 {
 		config.PendingWorkCapacity = libhoney.DefaultPendingWorkCapacity
 	}
-	if config.Client == nil {
-		var tx transmission.Sender
-		if config.STDOUT == true {
+	return config
+}
+
+// newBaseTransmission returns the transmission that sends events as config
+// describes, to Honeycomb with transport or to STDOUT, before the retries,
+// spooling and volume tracking that Init adds.
+func newBaseTransmission(config Config, transport http.RoundTripper) transmission.Sender {
+	var tx transmission.Sender
+	if config.STDOUT == true {
+		if config.STDOUTFormat == STDOUTTree {
+			tx = &treeSender{}
+		} else {
 			tx = &transmission.WriterSender{}
 		}
-		if config.Mute == true {
-			tx = &transmission.DiscardSender{}
-		}
-		if tx == nil {
-			tx = &transmission.Honeycomb{
-				MaxBatchSize:         config.MaxBatchSize,
-				BatchTimeout:         config.BatchTimeout,
-				MaxConcurrentBatches: config.MaxConcurrentBatches,
-				PendingWorkCapacity:  config.PendingWorkCapacity,
-				UserAgentAddition:    userAgentAddition,
-			}
-		}
-		clientConfig := libhoney.ClientConfig{
-			APIKey:       config.WriteKey,
-			Dataset:      config.Dataset,
-			Transmission: tx,
+	}
+	if config.Mute == true {
+		tx = &transmission.DiscardSender{}
+	}
+	if tx == nil {
+		tx = &transmission.Honeycomb{
+			MaxBatchSize:          config.MaxBatchSize,
+			BatchTimeout:          config.BatchTimeout,
+			MaxConcurrentBatches:  config.MaxConcurrentBatches,
+			PendingWorkCapacity:   config.PendingWorkCapacity,
+			UserAgentAddition:     fmt.Sprintf("beeline/%s", version),
+			Transport:             transport,
+			EnableMsgpackEncoding: config.EnableMsgpackEncoding,
+			DisableCompression:    config.DisableCompression,
+			// the overflow policy decides what is dropped instead
+			BlockOnSend: config.OverflowPolicy != OverflowDropNewest,
 		}
-		if config.APIHost != "" {
-			clientConfig.APIHost = config.APIHost
+		if config.OverflowPolicy != OverflowDropNewest {
+			tx = newOverflowSender(tx, config)
 		}
-		if config.Debug {
-			clientConfig.Logger = &libhoney.DefaultLogger{}
-		}
-		c, _ := libhoney.NewClient(clientConfig)
-		client.Set(c)
-	} else {
-		client.Set(config.Client)
 	}
+	return tx
+}
 
-	client.AddField("meta.beeline_version", version)
-	// add a bunch of fields
-	if config.ServiceName != "" {
-		client.AddField("service_name", config.ServiceName)
+// clientConfig returns the config for a libhoney client sending the events
+// described by config with tx.
+func clientConfig(config Config, tx transmission.Sender) libhoney.ClientConfig {
+	clientConfig := libhoney.ClientConfig{
+		APIKey:       config.WriteKey,
+		Dataset:      config.Dataset,
+		Transmission: tx,
 	}
-	if hostname, err := os.Hostname(); err == nil {
-		client.AddField("meta.local_hostname", hostname)
+	if config.APIHost != "" {
+		clientConfig.APIHost = config.APIHost
 	}
-
 	if config.Debug {
-		// TODO add more debugging than just the responses queue
-		go readResponses(client.TxResponses())
+		clientConfig.Logger = &libhoney.DefaultLogger{}
 	}
+	return clientConfig
+}
 
-	// Use the sampler hook if it's defined, otherwise a deterministic sampler
-	if config.SamplerHook != nil {
-		trace.GlobalConfig.SamplerHook = config.SamplerHook
-	} else {
-		// configure and set a global sampler so sending traces can use it
-		// without threading it through
-		sampler, err := sample.NewDeterministicSampler(config.SampleRate)
-		if err == nil {
-			sample.GlobalSampler = sampler
-		}
+// addClientFields adds the fields config puts on every event to c.
+func addClientFields(c *libhoney.Client, config Config) {
+	c.AddField("meta.beeline_version", version)
+	// add a bunch of fields
+	if config.ServiceName != "" {
+		c.AddField("service_name", config.ServiceName)
 	}
-
-	if config.PresendHook != nil {
-		trace.GlobalConfig.PresendHook = config.PresendHook
+	if config.ServiceVersion != "" {
+		c.AddField("service_version", config.ServiceVersion)
+	}
+	if config.Environment != "" {
+		c.AddField("environment", config.Environment)
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		c.AddField("meta.local_hostname", hostname)
+	}
+	if !config.DisableResourceDetection {
+		for k, v := range osResource() {
+			c.AddField(k, v)
+		}
 	}
-	return
 }
 
 // Flush sends any pending events to Honeycomb. This is optional; events will be
 // flushed on a timer otherwise. It is useful to flush before AWS Lambda
 // functions finish to ensure events get sent before AWS freezes the function.
-// Flush implicitly ends all currently active spans.
-func Flush(ctx context.Context) {
-	tr := trace.GetTraceFromContext(ctx)
-	if tr != nil {
-		tr.Send()
-	}
-	client.Flush()
+// Flush implicitly ends all currently active spans in the trace in ctx.
+//
+// Flush blocks until the events have been sent or ctx is done, so a deadline
+// on ctx bounds how long a graceful shutdown waits. It returns a *FlushError
+// if ctx was done first, or if any events were dropped while flushing, eg
+// because Honeycomb rejected them; events dropped are only counted if the
+// beeline created its own client.
+func Flush(ctx context.Context) error {
+	return std.Flush(ctx)
 }
 
 // Close shuts down the beeline. Closing does not send any pending traces but
 // does flush any pending libhoney events and blocks until they have been sent.
-// It is optional to close the beeline, and prohibited to try and send an event
-// after the beeline has been closed.
+// It also stops any background goroutines started by Init. It is optional to
+// close the beeline, and prohibited to try and send an event after the beeline
+// has been closed.
 func Close() {
-	client.Close()
+	// unlike other beelines, the default one closes its client even if Init
+	// was given it
+	std.closeClient()
+	std.ownsClient = false
+	if std.background != nil {
+		std.background.stop()
+	}
+}
+
+// GetTransmissionStats returns counts of the events that have been sent,
+// failed and retried since Init was called. All counts are zero if the beeline
+// has not been initialized or Config.Client was set.
+func GetTransmissionStats() TransmissionStats {
+	return std.GetTransmissionStats()
 }
 
 // AddField allows you to add a single field to an event anywhere downstream of
@@ -208,20 +1020,29 @@ func Close() {
 // context from the request (`r.Context()`) and the key and value you wish to
 // add.This function is good for span-level data, eg timers or the arguments to
 // a specific function call, etc. Fields added here are prefixed with `app.`
-func AddField(ctx context.Context, key string, val interface{}) {
-	span := trace.GetSpanFromContext(ctx)
-	if span != nil {
-		if val != nil {
-			namespacedKey := fmt.Sprintf("app.%s", key)
-			if valErr, ok := val.(error); ok {
-				// treat errors specially because it's a pain to have to
-				// remember to stringify them
-				span.AddField(namespacedKey, valErr.Error())
-			} else {
-				span.AddField(namespacedKey, val)
-			}
-		}
-	}
+//
+// AddField is always safe to call, whatever the context: without a span in
+// it, as in tests and code that isn't instrumented, or with a nil context, it
+// does nothing. It returns true if the field was added to a span that is
+// still to be sent, and false if it went nowhere, including when val is nil
+// or the span has already been sent.
+func AddField(ctx context.Context, key string, val interface{}) bool {
+	return std.AddField(ctx, key, val)
+}
+
+// AddFieldf adds a field to the current span like AddField, with a value
+// formatted by fmt.Sprintf. The value is only formatted if the span will be
+// sent.
+func AddFieldf(ctx context.Context, key string, format string, args ...interface{}) bool {
+	return std.AddFieldf(ctx, key, format, args...)
+}
+
+// AddFields adds each of the fields to the current span, like calling
+// AddField for each one. Keys are prefixed with `app.` and errors are
+// stringified in the same way. It is as safe to call as AddField, and
+// returns true if the fields were added to a span that is still to be sent.
+func AddFields(ctx context.Context, fields map[string]interface{}) bool {
+	return std.AddFields(ctx, fields)
 }
 
 // AddFieldToTrace adds the field to both the currently active span and all
@@ -232,11 +1053,37 @@ func AddField(ctx context.Context, key string, val interface{}) {
 // eg user IDs, globally relevant feature flags, errors, etc. Fields added here
 // are prefixed with `app.`
 func AddFieldToTrace(ctx context.Context, key string, val interface{}) {
-	namespacedKey := fmt.Sprintf("app.%s", key)
-	tr := trace.GetTraceFromContext(ctx)
-	if tr != nil {
-		tr.AddField(namespacedKey, val)
-	}
+	std.AddFieldToTrace(ctx, key, val)
+}
+
+// AddError records err on the current span with Span.AddError, as `error`,
+// `error.type` and, for wrapped errors, `error.chain` and `error.cause`.
+// Unlike AddField these fields are not prefixed with `app.`, so errors look
+// the same whether the application or a wrapper recorded them.
+func AddError(ctx context.Context, err error) {
+	std.AddError(ctx, err)
+}
+
+// AddEvent records that something happened at a point in time during the
+// current span, such as a cache miss or a retry, with Span.AddEvent. Honeycomb
+// shows these on their span in the trace view, or they can be kept in the
+// span's `span_events` field with Config.SpanEventsAsField.
+func AddEvent(ctx context.Context, name string, fields map[string]interface{}) {
+	std.AddEvent(ctx, name, fields)
+}
+
+// AddLink links the current span to the span spanID in the trace traceID,
+// such as the producer of a message being consumed, with Span.AddLink.
+func AddLink(ctx context.Context, traceID, spanID string, fields map[string]interface{}) {
+	std.AddLink(ctx, traceID, spanID, fields)
+}
+
+// Increment adds delta to a counter kept for the whole trace, eg the number
+// of emails sent or rows processed while handling a request. It is safe to
+// call from concurrent goroutines. The total is added to the root span as
+// `app.<name>` when the trace is sent.
+func Increment(ctx context.Context, name string, delta int64) {
+	std.Increment(ctx, name, delta)
 }
 
 // StartSpan lets you start a new span as a child of an already instrumented
@@ -249,25 +1096,41 @@ func AddFieldToTrace(ctx context.Context, key string, val interface{}) {
 // `span.Send()` when the span should be sent (often in a defer immediately
 // after creation). You should pass the returned context downstream.
 func StartSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
-	span := trace.GetSpanFromContext(ctx)
-	var newSpan *trace.Span
-	if span != nil {
-		ctx, newSpan = span.CreateChild(ctx)
-	} else {
-		// there is no trace active; we should make one, but use the root span
-		// as the "new" span instead of creating a child of this mostly empty
-		// span
-		ctx, _ = trace.NewTrace(ctx, "")
-		newSpan = trace.GetSpanFromContext(ctx)
-	}
-	newSpan.AddField("name", name)
-	return ctx, newSpan
+	return std.StartSpan(ctx, name)
+}
+
+// TraceIDFromContext returns the ID of the trace in ctx, or "" if there is
+// none, to show to users or put in logs so the trace can be found later.
+func TraceIDFromContext(ctx context.Context) string {
+	return std.TraceIDFromContext(ctx)
+}
+
+// BuilderFromContext returns a libhoney.Builder for events that belong to the
+// trace in ctx as children of its current span, for wrappers of in-house
+// protocols and other code that builds its own events. Events made with it
+// have the fields added in Init, the trace level fields, the trace ID, the
+// current span as their parent and a span ID of their own, and go to the
+// trace's dataset. They skip the sampler and presend hooks, so set their
+// sample rate yourself if you need one. If ctx has no span, the builder has
+// only the fields added in Init.
+func BuilderFromContext(ctx context.Context) *libhoney.Builder {
+	return std.BuilderFromContext(ctx)
 }
 
 // readResponses pulls from the response queue and spits them to STDOUT for
-// debugging
-func readResponses(responses chan transmission.Response) {
-	for r := range responses {
+// debugging, until the queue is closed or done is.
+func readResponses(responses chan transmission.Response, done <-chan struct{}) {
+	for {
+		var r transmission.Response
+		var ok bool
+		select {
+		case r, ok = <-responses:
+			if !ok {
+				return
+			}
+		case <-done:
+			return
+		}
 		var metadata string
 		if r.Metadata != nil {
 			metadata = fmt.Sprintf("%s", r.Metadata)
@@ -2,11 +2,15 @@ package beeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/honeycombio/libhoney-go/transmission"
 
+	"github.com/honeycombio/beeline-go/propagation"
 	libhoney "github.com/honeycombio/libhoney-go"
 	"github.com/stretchr/testify/assert"
 )
@@ -92,6 +96,214 @@ func TestBasicSpanAttributes(t *testing.T) {
 	assert.True(t, foundRoot, "root span missing")
 }
 
+func TestAddFields(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := StartSpan(context.Background(), "start")
+	AddFields(ctx, map[string]interface{}{
+		"user_id": 42,
+		"err":     errors.New("oops"),
+		"nothing": nil,
+	})
+	span.Send()
+	AddFields(context.Background(), map[string]interface{}{"ignored": 1})
+
+	events := mo.Events()
+	if assert.Equal(t, 1, len(events)) {
+		fields := events[0].Data
+		assert.Equal(t, 42, fields["app.user_id"])
+		assert.Equal(t, "oops", fields["app.err"], "errors should be stringified")
+		assert.NotContains(t, fields, "app.nothing", "nil values should be skipped like AddField")
+	}
+}
+
+func TestAddFieldReportsWhetherAttached(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := StartSpan(context.Background(), "start")
+	assert.True(t, AddField(ctx, "user_id", 42))
+	assert.False(t, AddField(ctx, "nothing", nil), "nil values aren't added")
+	assert.True(t, AddFieldf(ctx, "greeting", "hello %s", "world"))
+	assert.True(t, AddFields(ctx, map[string]interface{}{"plan": "pro"}))
+	span.Send()
+	assert.False(t, AddField(ctx, "late", 1), "fields can't be added once the span is sent")
+	assert.False(t, AddFieldf(ctx, "late", "%d", 1))
+	assert.False(t, AddFields(ctx, map[string]interface{}{"late": 1}))
+
+	// no span, or no context at all, is safe
+	assert.False(t, AddField(context.Background(), "ignored", 1))
+	assert.False(t, AddFieldf(context.Background(), "ignored", "%d", 1))
+	assert.False(t, AddFields(context.Background(), map[string]interface{}{"ignored": 1}))
+	var nilCtx context.Context
+	assert.False(t, AddField(nilCtx, "ignored", 1))
+
+	events := mo.Events()
+	if assert.Equal(t, 1, len(events)) {
+		fields := events[0].Data
+		assert.Equal(t, 42, fields["app.user_id"])
+		assert.Equal(t, "hello world", fields["app.greeting"])
+		assert.Equal(t, "pro", fields["app.plan"])
+		assert.NotContains(t, fields, "app.late")
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, root := StartSpan(context.Background(), "request")
+	ctx, child := StartSpan(ctx, "work")
+	Increment(ctx, "emails_sent", 2)
+	child.Send()
+	Increment(ctx, "emails_sent", 1)
+	root.Send()
+	Increment(context.Background(), "ignored", 1)
+
+	events := mo.Events()
+	if assert.Equal(t, 2, len(events)) {
+		assert.NotContains(t, events[0].Data, "app.emails_sent")
+		assert.Equal(t, int64(3), events[1].Data["app.emails_sent"])
+	}
+}
+
+func TestDerivedFields(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{
+		Client:         client,
+		ApdexThreshold: time.Hour,
+		DerivedFields: []func(map[string]interface{}){
+			func(fields map[string]interface{}) {
+				if host, ok := fields["app.host"].(string); ok {
+					fields["app.tld"] = host[strings.LastIndex(host, ".")+1:]
+				}
+			},
+			func(fields map[string]interface{}) {
+				// runs after the apdex hook and the derived field before it
+				if fields["apdex.class"] == "satisfied" && fields["app.tld"] == "nz" {
+					fields["app.happy_kiwi"] = true
+				}
+			},
+		},
+	})
+	defer setupLibhoney(t)
+
+	ctx, span := StartSpan(context.Background(), "request")
+	span.AddField("meta.type", "http_request")
+	AddField(ctx, "host", "example.co.nz")
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "nz", evs[0].Data["app.tld"])
+		assert.Equal(t, true, evs[0].Data["app.happy_kiwi"])
+	}
+}
+
+func TestAddFieldToTrace(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, root := StartSpan(context.Background(), "request")
+	_, before := StartSpan(ctx, "query")
+	AddFieldToTrace(ctx, "tenant", "acme")
+	_, after := StartSpan(ctx, "render")
+	header := root.SerializeHeaders()
+	after.Send()
+	before.Send()
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		for _, ev := range evs {
+			assert.Equal(t, "acme", ev.Data["app.tenant"], "%s should have the trace field", ev.Data["name"])
+		}
+	}
+	prop, err := propagation.UnmarshalHoneycombTraceContext(header)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "acme", prop.TraceContext["app.tenant"])
+	}
+}
+
+func TestAddError(t *testing.T) {
+	mo := setupLibhoney(t)
+	AddError(context.Background(), errors.New("no span"))
+	ctx, span := StartSpan(context.Background(), "request")
+	AddError(ctx, errors.New("failed"))
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "failed", evs[0].Data["error"])
+		assert.Equal(t, "*errors.errorString", evs[0].Data["error.type"])
+	}
+}
+
+func TestBuilderFromContext(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := StartSpan(context.Background(), "request")
+	AddFieldToTrace(ctx, "user", "ada")
+
+	b := BuilderFromContext(ctx)
+	for i := 0; i < 2; i++ {
+		ev := b.NewEvent()
+		ev.AddField("name", "in-house call")
+		assert.NoError(t, ev.Send())
+	}
+	span.Send()
+	ev := BuilderFromContext(context.Background()).NewEvent()
+	ev.AddField("name", "untraced")
+	ev.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 4, len(evs)) {
+		root := evs[2].Data
+		for _, ev := range evs[:2] {
+			assert.Equal(t, root["trace.trace_id"], ev.Data["trace.trace_id"])
+			assert.Equal(t, root["trace.span_id"], ev.Data["trace.parent_id"])
+			assert.Equal(t, "ada", ev.Data["app.user"])
+			assert.Equal(t, version, ev.Data["meta.beeline_version"])
+		}
+		assert.NotEqual(t, evs[0].Data["trace.span_id"], evs[1].Data["trace.span_id"],
+			"every event should get its own span ID")
+		assert.NotContains(t, evs[3].Data, "trace.trace_id")
+		assert.Equal(t, version, evs[3].Data["meta.beeline_version"])
+	}
+}
+
+func TestSampleRateKeepsTracesTogether(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client, SampleRate: 4})
+	defer setupLibhoney(t)
+
+	const traces = 200
+	for i := 0; i < traces; i++ {
+		ctx, root := StartSpan(context.Background(), "request")
+		ctx, child := StartSpan(ctx, "query")
+		_, grandchild := StartSpan(ctx, "row")
+		grandchild.Send()
+		child.Send()
+		root.Send()
+	}
+
+	spans := map[interface{}]int{}
+	for _, ev := range mo.Events() {
+		assert.Equal(t, uint(4), ev.SampleRate)
+		spans[ev.Data["trace.trace_id"]]++
+	}
+	for id, n := range spans {
+		assert.Equal(t, 3, n, "every span of trace %v should be kept", id)
+	}
+	assert.True(t, len(spans) > 0 && len(spans) < traces, "some traces should be sampled out, got %d of %d", len(spans), traces)
+}
+
 func BenchmarkCreateSpan(b *testing.B) {
 	setupLibhoney(b)
 
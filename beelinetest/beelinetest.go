@@ -0,0 +1,197 @@
+// Package beelinetest helps unit test instrumentation. It initializes the
+// beeline to keep every event it sends in memory instead of sending it to
+// Honeycomb, and has helpers for finding spans and checking traces in what
+// was sent.
+//
+//	func TestHandler(t *testing.T) {
+//		rec := beelinetest.Init(beeline.Config{})
+//		defer beeline.Close()
+//
+//		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+//
+//		if spans := rec.SpansWithField("db.query"); len(spans) != 1 {
+//			t.Errorf("expected one query, got %d", len(spans))
+//		}
+//		rec.AssertTraceComplete(t)
+//	}
+package beelinetest
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+)
+
+// Recorder keeps the events the beeline sends. It's a transmission.Sender, so
+// it can also be given to a libhoney client of your own.
+type Recorder struct {
+	lock      sync.Mutex
+	events    []*transmission.Event
+	responses chan transmission.Response
+}
+
+// Init initializes the beeline with config, sending events to a new Recorder
+// rather than to Honeycomb, and returns the Recorder. Any client in config is
+// replaced.
+func Init(config beeline.Config) *Recorder {
+	r := &Recorder{}
+	c, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: r,
+	})
+	if err != nil {
+		// only possible with an invalid config, and this one is valid
+		panic(err)
+	}
+	config.Client = c
+	beeline.Init(config)
+	return r
+}
+
+// Events returns the fields of every event sent so far, in the order they
+// were sent.
+func (r *Recorder) Events() []map[string]interface{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	evs := make([]map[string]interface{}, len(r.events))
+	for i, ev := range r.events {
+		evs[i] = ev.Data
+	}
+	return evs
+}
+
+// Spans returns the events sent so far that are part of a trace.
+func (r *Recorder) Spans() []map[string]interface{} {
+	var spans []map[string]interface{}
+	for _, ev := range r.Events() {
+		if _, ok := ev["trace.trace_id"]; ok {
+			spans = append(spans, ev)
+		}
+	}
+	return spans
+}
+
+// SpansNamed returns the spans sent so far with the given name.
+func (r *Recorder) SpansNamed(name string) []map[string]interface{} {
+	var spans []map[string]interface{}
+	for _, s := range r.Spans() {
+		if s["name"] == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// SpansWithField returns the spans sent so far that have the field key,
+// whatever its value.
+func (r *Recorder) SpansWithField(key string) []map[string]interface{} {
+	var spans []map[string]interface{}
+	for _, s := range r.Spans() {
+		if _, ok := s[key]; ok {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Reset forgets the events sent so far.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	r.events = nil
+	r.lock.Unlock()
+}
+
+// AssertTraceComplete checks that every trace sent so far is whole: that its
+// root span was sent and that the parent of every other span in it was sent
+// too. Each problem found is reported with t.Errorf. It returns whether the
+// traces were complete.
+//
+// The root of a trace continued from an upstream service, a subroot, counts
+// as its root, though its parent was sent elsewhere.
+func (r *Recorder) AssertTraceComplete(t testing.TB) bool {
+	t.Helper()
+	traces := make(map[string][]map[string]interface{})
+	for _, s := range r.Spans() {
+		id, _ := s["trace.trace_id"].(string)
+		traces[id] = append(traces[id], s)
+	}
+	ids := make([]string, 0, len(traces))
+	for id := range traces {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	complete := true
+	for _, id := range ids {
+		spans := make(map[string]bool)
+		roots := 0
+		for _, s := range traces[id] {
+			if spanID, ok := s["trace.span_id"].(string); ok {
+				spans[spanID] = true
+			}
+			if isRoot(s) {
+				roots++
+			}
+		}
+		if roots == 0 {
+			t.Errorf("trace %s: root span was never sent", id)
+			complete = false
+		}
+		for _, s := range traces[id] {
+			if isRoot(s) {
+				continue
+			}
+			parent, _ := s["trace.parent_id"].(string)
+			if !spans[parent] {
+				t.Errorf("trace %s: parent %q of span %v was never sent", id, parent, s["name"])
+				complete = false
+			}
+		}
+	}
+	return complete
+}
+
+func isRoot(span map[string]interface{}) bool {
+	t := span["meta.span_type"]
+	return t == "root" || t == "subroot"
+}
+
+// Add records ev. It's part of transmission.Sender.
+func (r *Recorder) Add(ev *transmission.Event) {
+	r.lock.Lock()
+	r.events = append(r.events, ev)
+	r.lock.Unlock()
+	r.SendResponse(transmission.Response{Metadata: ev.Metadata, StatusCode: 202})
+}
+
+// Start is part of transmission.Sender.
+func (r *Recorder) Start() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.responses = make(chan transmission.Response, 100)
+	return nil
+}
+
+// Stop is part of transmission.Sender.
+func (r *Recorder) Stop() error { return nil }
+
+// TxResponses is part of transmission.Sender.
+func (r *Recorder) TxResponses() chan transmission.Response {
+	return r.responses
+}
+
+// SendResponse is part of transmission.Sender.
+func (r *Recorder) SendResponse(resp transmission.Response) bool {
+	select {
+	case r.responses <- resp:
+	default:
+		return true
+	}
+	return false
+}
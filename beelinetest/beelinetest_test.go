@@ -0,0 +1,68 @@
+package beelinetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/beeline-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeT records the errors reported to it instead of failing the test
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	rec := Init(beeline.Config{ServiceName: "test"})
+	defer beeline.Close()
+
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	_, query := beeline.StartSpan(ctx, "query")
+	query.AddField("db.query", "select 1")
+	query.Send()
+	root.Send()
+	beeline.Flush(context.Background())
+
+	assert.Equal(t, 2, len(rec.Events()))
+	assert.Equal(t, 2, len(rec.Spans()))
+	if spans := rec.SpansWithField("db.query"); assert.Equal(t, 1, len(spans)) {
+		assert.Equal(t, "query", spans[0]["name"])
+		assert.Equal(t, "test", spans[0]["service_name"])
+	}
+	assert.Equal(t, 1, len(rec.SpansNamed("request")))
+	assert.True(t, rec.AssertTraceComplete(t))
+
+	rec.Reset()
+	assert.Empty(t, rec.Events())
+}
+
+func TestAssertTraceComplete(t *testing.T) {
+	rec := Init(beeline.Config{})
+	defer beeline.Close()
+
+	// a child sent without its parent
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	_, child := beeline.StartSpan(ctx, "query")
+	child.Send()
+
+	ft := &fakeT{TB: t}
+	assert.False(t, rec.AssertTraceComplete(ft))
+	if assert.Equal(t, 2, len(ft.errors)) {
+		assert.Contains(t, ft.errors[0], "root span was never sent")
+		assert.Contains(t, ft.errors[1], "of span query was never sent")
+	}
+
+	root.Send()
+	ft = &fakeT{TB: t}
+	assert.True(t, rec.AssertTraceComplete(ft))
+	assert.Empty(t, ft.errors)
+}
@@ -8,10 +8,19 @@ import (
 	"github.com/honeycombio/libhoney-go/transmission"
 )
 
-var client = &libhoney.Client{}
+// defaultClient is used until Set is called. It discards everything sent to
+// it.
+var defaultClient = &libhoney.Client{}
 
-// Set the active libhoney client used by the beeline
+var client = defaultClient
+
+// Set the active libhoney client used by the beeline. Setting a nil client
+// goes back to the default client, which discards everything sent to it, so
+// the beeline keeps working as a no-op.
 func Set(c *libhoney.Client) {
+	if c == nil {
+		c = defaultClient
+	}
 	client = c
 }
 
@@ -48,9 +57,11 @@ func NewBuilder() *libhoney.Builder {
 	return &libhoney.Builder{}
 }
 
+// TxResponses returns the queue of responses from the libhoney client. If the
+// client has not been set, the returned channel is closed.
 func TxResponses() chan transmission.Response {
-	if client != nil {
-		client.TxResponses()
+	if client != nil && client != defaultClient {
+		return client.TxResponses()
 	}
 
 	c := make(chan transmission.Response)
@@ -20,3 +20,15 @@ func TestClientWrappersWorkWithoutInit(t *testing.T) {
 		fmt.Println(r.Body)
 	}
 }
+
+func TestSetNilClient(t *testing.T) {
+	Set(nil)
+	if Get() == nil {
+		t.Fatal("setting a nil client should fall back to the default client")
+	}
+	// the default client discards events rather than panicking
+	ev := NewBuilder().NewEvent()
+	ev.AddField("beep", "boop")
+	ev.Send()
+	Flush()
+}
@@ -0,0 +1,265 @@
+// Command hnytrace decodes and generates the trace headers the beeline
+// understands, for debugging trace propagation between services.
+//
+// To see what a header carries, pass it to decode, with or without its name.
+// The format is worked out from the name, or from the value if there is none:
+//
+//	hnytrace decode 'traceparent: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'
+//	hnytrace decode '1;trace_id=abc,parent_id=def,context=e30='
+//
+// To make a header for a request to an instrumented service, use generate.
+// IDs are random unless given:
+//
+//	curl -H "$(hnytrace generate -format w3c)" localhost:8080/
+//	hnytrace generate -trace-id abc -parent-id def -dataset my-service -field user=42
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/honeycombio/beeline-go/propagation"
+)
+
+const usage = `usage:
+  hnytrace decode [-format honeycomb|w3c|aws|b3] <header>
+  hnytrace generate [-format honeycomb|w3c|aws|b3|b3multi] [-trace-id id] [-parent-id id]
+                    [-dataset name] [-tracestate state] [-field key=value]...`
+
+// The supported header formats.
+const (
+	formatHoneycomb = "honeycomb"
+	formatW3C       = "w3c"
+	formatAWS       = "aws"
+	formatB3        = "b3"
+	formatB3Multi   = "b3multi"
+)
+
+var (
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+	b3Pattern          = regexp.MustCompile(`^([0-9a-f]{16}|[0-9a-f]{32})-[0-9a-f]{16}(-[01d](-[0-9a-f]{16})?)?$`)
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "decode":
+		return decode(args[1:], out)
+	case "generate":
+		return generate(args[1:], out)
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+}
+
+func decode(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("decode", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	format := flags.String("format", "", "header format, detected if not set")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%s\n%s", err, usage)
+	}
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+	name, value := splitHeader(flags.Arg(0))
+	if *format == "" {
+		*format = detectFormat(name, value)
+		if *format == "" {
+			return fmt.Errorf("can't tell the format of %q; set -format", flags.Arg(0))
+		}
+	}
+
+	var prop *propagation.PropagationContext
+	var err error
+	switch *format {
+	case formatHoneycomb:
+		prop, err = propagation.UnmarshalHoneycombTraceContext(value)
+	case formatAWS:
+		prop, err = propagation.UnmarshalAmazonTraceContext(value)
+	case formatW3C:
+		_, prop, err = propagation.UnmarshalW3CTraceContext(context.Background(), map[string]string{
+			propagation.W3CTraceParentHTTPHeader: value,
+		})
+	case formatB3:
+		prop, err = propagation.UnmarshalB3SingleTraceContext(value)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "format: %s\n", *format)
+	fmt.Fprintf(out, "trace_id: %s\n", prop.TraceID)
+	fmt.Fprintf(out, "parent_id: %s\n", prop.ParentID)
+	if *format == formatW3C || *format == formatB3 {
+		fmt.Fprintf(out, "sampled: %t\n", prop.TraceFlags&0x01 != 0)
+	}
+	if prop.Dataset != "" {
+		fmt.Fprintf(out, "dataset: %s\n", prop.Dataset)
+	}
+	keys := make([]string, 0, len(prop.TraceContext))
+	for k := range prop.TraceContext {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(out, "field %s: %v\n", k, prop.TraceContext[k])
+	}
+	return nil
+}
+
+// fieldsFlag collects repeated -field key=value flags.
+type fieldsFlag map[string]interface{}
+
+func (f fieldsFlag) String() string {
+	return fmt.Sprint(map[string]interface{}(f))
+}
+
+func (f fieldsFlag) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("fields should look like key=value, not %q", s)
+	}
+	f[kv[0]] = kv[1]
+	return nil
+}
+
+func generate(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	format := flags.String("format", formatHoneycomb, "header format")
+	traceID := flags.String("trace-id", "", "trace ID, random if not set")
+	parentID := flags.String("parent-id", "", "parent span ID, random if not set")
+	dataset := flags.String("dataset", "", "dataset for the trace (honeycomb only)")
+	traceState := flags.String("tracestate", "", "tracestate header to send too (w3c only)")
+	fields := fieldsFlag{}
+	flags.Var(fields, "field", "trace field as key=value (honeycomb and aws only), may be repeated")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%s\n%s", err, usage)
+	}
+
+	prop := &propagation.PropagationContext{
+		TraceID:      *traceID,
+		ParentID:     *parentID,
+		Dataset:      *dataset,
+		TraceContext: map[string]interface{}(fields),
+		TraceFlags:   0x01,
+		TraceState:   *traceState,
+	}
+	if prop.ParentID == "" {
+		prop.ParentID = newID(8)
+	}
+	switch *format {
+	case formatHoneycomb:
+		if prop.TraceID == "" {
+			prop.TraceID = newID(16)
+		}
+		fmt.Fprintf(out, "%s: %s\n", propagation.TracePropagationHTTPHeader, propagation.MarshalHoneycombTraceContext(prop))
+	case formatAWS:
+		if prop.TraceID == "" {
+			prop.TraceID = newID(12)
+			prop.TraceContext[propagation.AmazonRootTimestampField] = time.Now().Unix()
+		}
+		fmt.Fprintf(out, "%s: %s\n", propagation.AmazonTracePropagationHTTPHeader, propagation.MarshalAmazonTraceContext(prop))
+	case formatW3C:
+		if prop.TraceID == "" {
+			prop.TraceID = newID(16)
+		}
+		_, headers := propagation.MarshalW3CTraceContext(context.Background(), prop)
+		if headers[propagation.W3CTraceParentHTTPHeader] == "" {
+			return errors.New("W3C headers need a 32 hex digit trace ID and a 16 hex digit parent ID")
+		}
+		fmt.Fprintf(out, "%s: %s\n", propagation.W3CTraceParentHTTPHeader, headers[propagation.W3CTraceParentHTTPHeader])
+		if state := headers[propagation.W3CTraceStateHTTPHeader]; state != "" {
+			fmt.Fprintf(out, "%s: %s\n", propagation.W3CTraceStateHTTPHeader, state)
+		}
+	case formatB3:
+		if prop.TraceID == "" {
+			prop.TraceID = newID(16)
+		}
+		header := propagation.MarshalB3SingleTraceContext(prop)
+		if header == "" {
+			return errB3IDs
+		}
+		fmt.Fprintf(out, "%s: %s\n", propagation.B3SingleHTTPHeader, header)
+	case formatB3Multi:
+		if prop.TraceID == "" {
+			prop.TraceID = newID(16)
+		}
+		headers := propagation.MarshalB3TraceContext(prop)
+		if len(headers) == 0 {
+			return errB3IDs
+		}
+		for _, name := range []string{propagation.B3TraceIDHTTPHeader, propagation.B3SpanIDHTTPHeader, propagation.B3SampledHTTPHeader} {
+			fmt.Fprintf(out, "%s: %s\n", name, headers[name])
+		}
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	return nil
+}
+
+// splitHeader splits "Name: value" into its name and value. Values without a
+// name are returned as they are.
+func splitHeader(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, ":"); i > 0 && !strings.ContainsAny(s[:i], " ;=,") {
+		return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	return "", s
+}
+
+// detectFormat works out the format of a header from its name, or from its
+// value if it has no name. It returns "" if it can't tell.
+func detectFormat(name, value string) string {
+	switch strings.ToLower(name) {
+	case strings.ToLower(propagation.TracePropagationHTTPHeader):
+		return formatHoneycomb
+	case strings.ToLower(propagation.AmazonTracePropagationHTTPHeader):
+		return formatAWS
+	case propagation.W3CTraceParentHTTPHeader:
+		return formatW3C
+	case propagation.B3SingleHTTPHeader:
+		return formatB3
+	}
+	switch {
+	case traceparentPattern.MatchString(value):
+		return formatW3C
+	case b3Pattern.MatchString(value):
+		return formatB3
+	case strings.HasPrefix(value, "1;"):
+		return formatHoneycomb
+	case strings.Contains(strings.ToLower(value), "root="):
+		return formatAWS
+	}
+	return ""
+}
+
+var errB3IDs = errors.New("B3 headers need a 16 or 32 hex digit trace ID and a 16 hex digit parent ID")
+
+func newID(length int) string {
+	id := make([]byte, length)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
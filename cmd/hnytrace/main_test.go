@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		header string
+		output string
+	}{
+		{
+			"traceparent: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			"format: w3c\ntrace_id: 0af7651916cd43dd8448eb211c80319c\nparent_id: b7ad6b7169203331\nsampled: true\n",
+		},
+		{
+			"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00",
+			"format: w3c\ntrace_id: 0af7651916cd43dd8448eb211c80319c\nparent_id: b7ad6b7169203331\nsampled: false\n",
+		},
+		{
+			"X-Honeycomb-Trace: 1;trace_id=abc,parent_id=def,dataset=ds,context=eyJ1c2VyIjo0Mn0=",
+			"format: honeycomb\ntrace_id: abc\nparent_id: def\ndataset: ds\nfield user: 42\n",
+		},
+		{
+			"b3: 64fe8b2a57d3eff7-e457b5a2e4d86bd1-1",
+			"format: b3\ntrace_id: 64fe8b2a57d3eff7\nparent_id: e457b5a2e4d86bd1\nsampled: true\n",
+		},
+		{
+			"Root=1-67891233-abcdef012345678912345678;Self=1-67891233-0102030405",
+			"format: aws\ntrace_id: abcdef012345678912345678\nparent_id: 1-67891233-0102030405\nfield aws.root_timestamp: 1737036339\n",
+		},
+	}
+	for _, tc := range testCases {
+		var out bytes.Buffer
+		if assert.NoError(t, run([]string{"decode", tc.header}, &out), tc.header) {
+			assert.Equal(t, tc.output, out.String(), tc.header)
+		}
+	}
+
+	assert.Error(t, run([]string{"decode", "what is this"}, &bytes.Buffer{}), "unknown formats should be an error")
+	assert.Error(t, run([]string{"decode", "-format", "w3c", "1;trace_id=abc"}, &bytes.Buffer{}), "bad headers should be an error")
+}
+
+func TestGenerate(t *testing.T) {
+	for _, format := range []string{formatHoneycomb, formatW3C, formatAWS, formatB3} {
+		var generated bytes.Buffer
+		if !assert.NoError(t, run([]string{"generate", "-format", format}, &generated), format) {
+			continue
+		}
+		// generated headers should decode as themselves
+		var decoded bytes.Buffer
+		header := strings.TrimSpace(generated.String())
+		if assert.NoError(t, run([]string{"decode", header}, &decoded), header) {
+			assert.Contains(t, decoded.String(), "format: "+format)
+		}
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, run([]string{"generate", "-format", "w3c", "-trace-id", "0af7651916cd43dd8448eb211c80319c",
+		"-parent-id", "b7ad6b7169203331", "-tracestate", "vendor=1"}, &out))
+	assert.Equal(t, "traceparent: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01\ntracestate: vendor=1\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, run([]string{"generate", "-format", "b3multi", "-trace-id", "64fe8b2a57d3eff7", "-parent-id", "e457b5a2e4d86bd1"}, &out))
+	assert.Equal(t, "X-B3-TraceId: 64fe8b2a57d3eff7\nX-B3-SpanId: e457b5a2e4d86bd1\nX-B3-Sampled: 1\n", out.String())
+
+	assert.Error(t, run([]string{"generate", "-format", "w3c", "-trace-id", "abc"}, &bytes.Buffer{}))
+	assert.Error(t, run([]string{"generate", "-format", "b3", "-trace-id", "abc"}, &bytes.Buffer{}))
+	assert.Error(t, run([]string{"generate", "-field", "novalue"}, &bytes.Buffer{}))
+	assert.Error(t, run([]string{"nope"}, &bytes.Buffer{}))
+}
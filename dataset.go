@@ -0,0 +1,17 @@
+package beeline
+
+// DatasetsByMetaType returns a Config.DatasetResolver that sends spans and
+// events to the dataset given for their `meta.type`, eg
+//
+//	DatasetsByMetaType(map[string]string{
+//		"http_request": "requests",
+//		"sql":          "queries",
+//	})
+//
+// Those with other types, or none, go to the configured dataset.
+func DatasetsByMetaType(datasets map[string]string) func(map[string]interface{}) string {
+	return func(fields map[string]interface{}) string {
+		metaType, _ := fields["meta.type"].(string)
+		return datasets[metaType]
+	}
+}
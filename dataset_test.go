@@ -0,0 +1,45 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatasetResolver(t *testing.T) {
+	mo := &transmission.MockSender{}
+	c, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: c, DatasetResolver: DatasetsByMetaType(map[string]string{
+		"http_request": "requests",
+		"sql":          "queries",
+	})})
+	defer Init(Config{Client: c})
+
+	ctx, root := StartSpan(context.Background(), "request")
+	root.AddField("meta.type", "http_request")
+	_, query := StartSpan(ctx, "query")
+	query.AddField("meta.type", "sql")
+	query.Send()
+	_, work := StartSpan(ctx, "work")
+	work.Send()
+	root.Send()
+
+	datasets := make(map[string]string)
+	for _, ev := range mo.Events() {
+		datasets[ev.Data["name"].(string)] = ev.Dataset
+	}
+	assert.Equal(t, map[string]string{
+		"query":   "queries",
+		"work":    "placeholder",
+		"request": "requests",
+	}, datasets)
+}
@@ -41,6 +41,18 @@
 // Once configured, use one of the subpackages to wrap HTTP handlers and SQL db
 // objects.
 //
+// Spans
+//
+// The wrappers start a trace for each request and put its span in the
+// request's context. To time a piece of work within it, start a child span
+// from that context and send it when the work is done. Spans started from the
+// returned context are children of the new span, and the trace, parent and
+// span IDs of each are filled in when it is sent:
+//
+//   ctx, span := beeline.StartSpan(r.Context(), "render")
+//   defer span.Send()
+//   beeline.AddField(ctx, "template", name)
+//
 // Examples
 //
 // There are runnable examples at
@@ -0,0 +1,51 @@
+package beeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/honeycombio/beeline-go/sample"
+	dynsampler "github.com/honeycombio/dynsampler-go"
+)
+
+// defaultDynamicSampleRouteFields are the fields the route part of the default
+// dynamic sampling key is taken from; the first an event has is used.
+var defaultDynamicSampleRouteFields = []string{"handler.route", "request.path"}
+
+// dynamicSampleKey returns the key an event is sampled by: the values of the
+// fields, joined by commas, or by default its route and response status code.
+func dynamicSampleKey(fields map[string]interface{}, keyFields []string) string {
+	if len(keyFields) == 0 {
+		var route interface{}
+		for _, f := range defaultDynamicSampleRouteFields {
+			if v, ok := fields[f]; ok {
+				route = v
+				break
+			}
+		}
+		return fmt.Sprintf("%v,%v", route, fields["response.status_code"])
+	}
+	values := make([]string, len(keyFields))
+	for i, f := range keyFields {
+		values[i] = fmt.Sprint(fields[f])
+	}
+	return strings.Join(values, ",")
+}
+
+// dynamicSamplerHook returns a SamplerHook that asks sampler for the sample
+// rate of each event's key, then keeps or drops it deterministically by its
+// trace ID, so the spans of a trace that share a rate are kept together.
+func dynamicSamplerHook(sampler dynsampler.Sampler, keyFields []string) func(map[string]interface{}) (bool, int) {
+	return func(fields map[string]interface{}) (bool, int) {
+		rate := sampler.GetSampleRate(dynamicSampleKey(fields, keyFields))
+		if rate <= 1 {
+			return true, 1
+		}
+		ds, err := sample.NewDeterministicSampler(uint(rate))
+		if err != nil {
+			return true, 1
+		}
+		traceID, _ := fields["trace.trace_id"].(string)
+		return ds.Sample(traceID), rate
+	}
+}
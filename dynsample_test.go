@@ -0,0 +1,68 @@
+package beeline
+
+import (
+	"testing"
+
+	dynsampler "github.com/honeycombio/dynsampler-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedRates is a dynsampler.Sampler with a set rate per key.
+type fixedRates struct {
+	dynsampler.Static
+	keys []string
+}
+
+func (f *fixedRates) GetSampleRate(key string) int {
+	f.keys = append(f.keys, key)
+	return f.Static.GetSampleRate(key)
+}
+
+func TestDynamicSampleKey(t *testing.T) {
+	fields := map[string]interface{}{
+		"handler.route":        "/users/{id}",
+		"request.path":         "/users/1",
+		"request.method":       "GET",
+		"response.status_code": 500,
+	}
+	assert.Equal(t, "/users/{id},500", dynamicSampleKey(fields, nil))
+	delete(fields, "handler.route")
+	assert.Equal(t, "/users/1,500", dynamicSampleKey(fields, nil))
+	assert.Equal(t, "GET,<nil>", dynamicSampleKey(fields, []string{"request.method", "missing"}))
+}
+
+func TestDynamicSamplerHook(t *testing.T) {
+	sampler := &fixedRates{Static: dynsampler.Static{
+		Rates:   map[string]int{"/hot,200": 1000},
+		Default: 1,
+	}}
+	assert.NoError(t, sampler.Start())
+	hook := dynamicSamplerHook(sampler, nil)
+
+	keep, rate := hook(map[string]interface{}{
+		"request.path":         "/hot",
+		"response.status_code": 500,
+		"trace.trace_id":       "abc",
+	})
+	assert.True(t, keep, "rare keys are kept")
+	assert.Equal(t, 1, rate)
+
+	kept := 0
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		keep, rate = hook(map[string]interface{}{
+			"request.path":         "/hot",
+			"response.status_code": 200,
+			"trace.trace_id":       id,
+		})
+		assert.Equal(t, 1000, rate)
+		if keep {
+			kept++
+		}
+	}
+	assert.True(t, kept < 8, "busy keys are sampled")
+	hot := map[string]interface{}{"request.path": "/hot", "response.status_code": 200, "trace.trace_id": "a"}
+	first, _ := hook(hot)
+	again, _ := hook(hot)
+	assert.Equal(t, first, again, "decisions are deterministic by trace ID")
+	assert.Equal(t, "/hot,500", sampler.keys[0])
+}
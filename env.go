@@ -0,0 +1,153 @@
+package beeline
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The environment variables read by ConfigFromEnv.
+const (
+	envWriteKey       = "HONEYCOMB_API_KEY"
+	envWriteKeyLegacy = "HONEYCOMB_WRITEKEY"
+	envDataset        = "HONEYCOMB_DATASET"
+	envAPIHost        = "HONEYCOMB_API_ENDPOINT"
+	envServiceName    = "HONEYCOMB_SERVICE_NAME"
+	envServiceVersion = "BEELINE_SERVICE_VERSION"
+	envEnvironment    = "BEELINE_ENVIRONMENT"
+	envSampleRate     = "BEELINE_SAMPLE_RATE"
+	envDebug          = "BEELINE_DEBUG"
+	envSTDOUT         = "BEELINE_STDOUT"
+	envMute           = "BEELINE_MUTE"
+	envIgnorePaths    = "BEELINE_IGNORE_HTTP_PATHS"
+	envCaptureHeaders = "BEELINE_HTTP_HEADERS_TO_CAPTURE"
+)
+
+// ConfigFromEnv returns a Config set from environment variables, so the same
+// binary can be configured differently wherever it runs:
+//
+//	HONEYCOMB_API_KEY                WriteKey (or HONEYCOMB_WRITEKEY)
+//	HONEYCOMB_DATASET                Dataset
+//	HONEYCOMB_API_ENDPOINT           APIHost
+//	HONEYCOMB_SERVICE_NAME           ServiceName
+//	BEELINE_SERVICE_VERSION          ServiceVersion
+//	BEELINE_ENVIRONMENT              Environment
+//	BEELINE_SAMPLE_RATE              SampleRate
+//	BEELINE_DEBUG                    Debug
+//	BEELINE_STDOUT                   STDOUT
+//	BEELINE_MUTE                     Mute
+//	BEELINE_IGNORE_HTTP_PATHS        IgnoreHTTPPaths, comma separated
+//	BEELINE_HTTP_HEADERS_TO_CAPTURE  HTTPHeadersToCapture, comma separated
+//
+// Booleans are parsed by strconv.ParseBool. Variables that are unset or empty
+// leave their field unset. An error is returned for the first variable that
+// can't be parsed, along with the config from the rest.
+//
+// Init and InitWithError use these variables for any of the fields left
+// unset in the config they are given, unless Config.DisableEnvConfig is set,
+// so Init(Config{}) configures the beeline entirely from the environment.
+func ConfigFromEnv() (Config, error) {
+	return configFromEnv(os.Getenv)
+}
+
+func configFromEnv(getenv func(string) string) (Config, error) {
+	var c Config
+	var err error
+	parseErr := func(name string, e error) {
+		if err == nil {
+			err = fmt.Errorf("beeline: can't parse %s %q: %s", name, getenv(name), e)
+		}
+	}
+	c.WriteKey = getenv(envWriteKey)
+	if c.WriteKey == "" {
+		c.WriteKey = getenv(envWriteKeyLegacy)
+	}
+	c.Dataset = getenv(envDataset)
+	c.APIHost = getenv(envAPIHost)
+	c.ServiceName = getenv(envServiceName)
+	c.ServiceVersion = getenv(envServiceVersion)
+	c.Environment = getenv(envEnvironment)
+	if v := getenv(envSampleRate); v != "" {
+		rate, e := strconv.ParseUint(v, 10, 32)
+		if e != nil {
+			parseErr(envSampleRate, e)
+		}
+		c.SampleRate = uint(rate)
+	}
+	for _, b := range []struct {
+		name  string
+		field *bool
+	}{
+		{envDebug, &c.Debug},
+		{envSTDOUT, &c.STDOUT},
+		{envMute, &c.Mute},
+	} {
+		if v := getenv(b.name); v != "" {
+			val, e := strconv.ParseBool(v)
+			if e != nil {
+				parseErr(b.name, e)
+			}
+			*b.field = val
+		}
+	}
+	c.IgnoreHTTPPaths = splitEnvList(getenv(envIgnorePaths))
+	c.HTTPHeadersToCapture = splitEnvList(getenv(envCaptureHeaders))
+	return c, err
+}
+
+// splitEnvList splits a comma separated list, dropping empty entries.
+func splitEnvList(v string) []string {
+	var list []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// withEnvConfig fills in the fields of config that ConfigFromEnv reads and
+// that are unset, from env. Booleans already true stay true.
+func withEnvConfig(config Config, env Config) Config {
+	if config.WriteKey == "" {
+		config.WriteKey = env.WriteKey
+	}
+	if config.Dataset == "" {
+		config.Dataset = env.Dataset
+	}
+	if config.APIHost == "" {
+		config.APIHost = env.APIHost
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = env.ServiceName
+	}
+	if config.ServiceVersion == "" {
+		config.ServiceVersion = env.ServiceVersion
+	}
+	if config.Environment == "" {
+		config.Environment = env.Environment
+	}
+	if config.SampleRate == 0 {
+		config.SampleRate = env.SampleRate
+	}
+	config.Debug = config.Debug || env.Debug
+	config.STDOUT = config.STDOUT || env.STDOUT
+	config.Mute = config.Mute || env.Mute
+	if len(config.IgnoreHTTPPaths) == 0 {
+		config.IgnoreHTTPPaths = env.IgnoreHTTPPaths
+	}
+	if len(config.HTTPHeadersToCapture) == 0 {
+		config.HTTPHeadersToCapture = env.HTTPHeadersToCapture
+	}
+	return config
+}
+
+// applyEnvConfig fills in config from the environment as Init does.
+func applyEnvConfig(config Config) (Config, error) {
+	if config.DisableEnvConfig {
+		return config, nil
+	}
+	env, err := ConfigFromEnv()
+	return withEnvConfig(config, env), err
+}
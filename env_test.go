@@ -0,0 +1,70 @@
+package beeline
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	c, err := configFromEnv(fakeEnv(map[string]string{
+		"HONEYCOMB_WRITEKEY":              "legacy",
+		"HONEYCOMB_DATASET":               "shop",
+		"HONEYCOMB_API_ENDPOINT":          "https://api.example.com",
+		"HONEYCOMB_SERVICE_NAME":          "checkout",
+		"BEELINE_SERVICE_VERSION":         "1.2.3",
+		"BEELINE_ENVIRONMENT":             "staging",
+		"BEELINE_SAMPLE_RATE":             "10",
+		"BEELINE_DEBUG":                   "true",
+		"BEELINE_STDOUT":                  "1",
+		"BEELINE_IGNORE_HTTP_PATHS":       "/healthz, /metrics,",
+		"BEELINE_HTTP_HEADERS_TO_CAPTURE": "Accept",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, Config{
+		WriteKey:             "legacy",
+		Dataset:              "shop",
+		APIHost:              "https://api.example.com",
+		ServiceName:          "checkout",
+		ServiceVersion:       "1.2.3",
+		Environment:          "staging",
+		SampleRate:           10,
+		Debug:                true,
+		STDOUT:               true,
+		IgnoreHTTPPaths:      []string{"/healthz", "/metrics"},
+		HTTPHeadersToCapture: []string{"Accept"},
+	}, c)
+
+	c, err = configFromEnv(fakeEnv(map[string]string{
+		"HONEYCOMB_API_KEY":   "key",
+		"HONEYCOMB_WRITEKEY":  "legacy",
+		"BEELINE_SAMPLE_RATE": "ten",
+		"BEELINE_MUTE":        "yes please",
+	}))
+	assert.Equal(t, "key", c.WriteKey, "HONEYCOMB_API_KEY should win")
+	if assert.Error(t, err) {
+		assert.Equal(t, `beeline: can't parse BEELINE_SAMPLE_RATE "ten": strconv.ParseUint: parsing "ten": invalid syntax`, err.Error())
+	}
+}
+
+func TestWithEnvConfig(t *testing.T) {
+	env := Config{WriteKey: "env", Dataset: "env", SampleRate: 10, Debug: true}
+	c := withEnvConfig(Config{Dataset: "code"}, env)
+	assert.Equal(t, "env", c.WriteKey)
+	assert.Equal(t, "code", c.Dataset, "the config given should win")
+	assert.Equal(t, uint(10), c.SampleRate)
+	assert.True(t, c.Debug)
+}
+
+func TestInitWithErrorFromEnv(t *testing.T) {
+	os.Setenv("HONEYCOMB_API_KEY", "from-env")
+	defer os.Unsetenv("HONEYCOMB_API_KEY")
+	defer Close()
+	assert.NoError(t, InitWithError(Config{Dataset: "test", Mute: true}))
+	assert.Equal(t, ErrMissingWriteKey, InitWithError(Config{DisableEnvConfig: true}))
+
+	os.Setenv("BEELINE_SAMPLE_RATE", "-1")
+	defer os.Unsetenv("BEELINE_SAMPLE_RATE")
+	assert.Error(t, InitWithError(Config{}))
+}
@@ -3,18 +3,25 @@ module github.com/honeycombio/beeline-go
 go 1.13
 
 require (
+	cloud.google.com/go/storage v1.10.0
+	github.com/99designs/gqlgen v0.13.0
 	github.com/DATA-DOG/go-sqlmock v1.4.1
+	github.com/Shopify/sarama v1.27.2
+	github.com/aws/aws-lambda-go v1.19.1
+	github.com/aws/aws-sdk-go v1.34.0
 	github.com/felixge/httpsnoop v1.0.1
 	github.com/gin-gonic/gin v1.6.3
+	github.com/go-chi/chi v4.1.2+incompatible
 	github.com/go-playground/validator/v10 v10.3.0 // indirect
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/gobuffalo/envy v1.9.0 // indirect
 	github.com/gobuffalo/packd v1.0.0 // indirect
 	github.com/gobuffalo/pop/v5 v5.2.3
-	github.com/gobuffalo/tags v2.1.7+incompatible // indirect
-	github.com/golang/protobuf v1.4.2 // indirect
-	github.com/google/uuid v1.1.1
+	github.com/golang/protobuf v1.4.2
+	github.com/gomodule/redigo v1.8.3
 	github.com/gorilla/mux v1.7.4
+	github.com/gorilla/websocket v1.4.2
+	github.com/honeycombio/dynsampler-go v0.2.1
 	github.com/honeycombio/libhoney-go v1.12.4
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/json-iterator/go v1.1.10 // indirect
@@ -22,10 +29,17 @@ require (
 	github.com/labstack/echo/v4 v4.1.16
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.1 // indirect
-	github.com/stretchr/testify v1.6.1
+	github.com/opentracing/opentracing-go v1.2.0
+	github.com/segmentio/kafka-go v0.4.12
+	github.com/sirupsen/logrus v1.7.0
+	github.com/stretchr/testify v1.8.1
+	github.com/urfave/negroni v1.0.0
+	github.com/vektah/gqlparser/v2 v2.1.0
+	go.mongodb.org/mongo-driver v1.3.7
 	go.opentelemetry.io/otel v0.6.0
+	go.uber.org/zap v1.16.0
 	goji.io/v3 v3.0.0
-	golang.org/x/sys v0.0.0-20200622214017-ed371f2e16b4 // indirect
+	google.golang.org/api v0.28.0
 	google.golang.org/grpc v1.30.0
 	google.golang.org/protobuf v1.25.0 // indirect
 	gopkg.in/yaml.v2 v2.3.0 // indirect
@@ -0,0 +1,149 @@
+package beeline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// Heartbeat summarizes the traffic on a long-lived connection, such as a
+// WebSocket, a gRPC stream or a server-sent event stream, in a child span of
+// the connection's span every interval. Without one, a connection that lives
+// for hours shows up as a single span once it closes. Create one with
+// StartHeartbeat, record traffic on it as it happens and Stop it when the
+// connection closes. A nil Heartbeat does nothing.
+type Heartbeat struct {
+	parent   *trace.Span
+	interval time.Duration
+
+	lock     sync.Mutex
+	counts   heartbeatCounts
+	sequence int
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// heartbeatCounts is the traffic in one interval.
+type heartbeatCounts struct {
+	messagesIn, messagesOut int64
+	bytesIn, bytesOut       int64
+	errors                  int64
+}
+
+// StartHeartbeat starts sending a span named "heartbeat" every interval as a
+// child of the span in ctx. Each has the duration of the interval and the
+// traffic recorded during it in `heartbeat.messages_in`,
+// `heartbeat.messages_out`, `heartbeat.bytes_in`, `heartbeat.bytes_out` and
+// `heartbeat.errors`, and its number in `heartbeat.sequence`, starting at 1.
+// When it is stopped the span for the last, partial interval is sent and the
+// number of intervals is added to the connection's span as
+// `heartbeat.intervals`. It returns nil if there is no span in ctx or the
+// beeline hasn't been initialized.
+func StartHeartbeat(ctx context.Context, interval time.Duration) *Heartbeat {
+	return std.StartHeartbeat(ctx, interval)
+}
+
+// StartHeartbeat starts a heartbeat for the span in ctx, as StartHeartbeat
+// does. It is stopped when b is closed.
+func (b *Beeline) StartHeartbeat(ctx context.Context, interval time.Duration) *Heartbeat {
+	parent := trace.GetSpanFromContext(ctx)
+	if parent == nil || b.background == nil || interval <= 0 {
+		return nil
+	}
+	h := &Heartbeat{
+		parent:   parent,
+		interval: interval,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	b.background.goFunc(h.run)
+	return h
+}
+
+// MessageReceived records a message of size bytes read from the connection.
+func (h *Heartbeat) MessageReceived(size int) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	h.counts.messagesIn++
+	h.counts.bytesIn += int64(size)
+	h.lock.Unlock()
+}
+
+// MessageSent records a message of size bytes written to the connection.
+func (h *Heartbeat) MessageSent(size int) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	h.counts.messagesOut++
+	h.counts.bytesOut += int64(size)
+	h.lock.Unlock()
+}
+
+// Error records an error on the connection.
+func (h *Heartbeat) Error() {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	h.counts.errors++
+	h.lock.Unlock()
+}
+
+// Stop sends the span for the current interval and stops the heartbeat. It
+// is safe to call more than once.
+func (h *Heartbeat) Stop() {
+	if h == nil {
+		return
+	}
+	h.stopOnce.Do(func() {
+		close(h.stop)
+	})
+	<-h.stopped
+}
+
+func (h *Heartbeat) run(done <-chan struct{}) {
+	defer close(h.stopped)
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	_, span := h.parent.CreateChild(context.Background())
+	for {
+		select {
+		case <-ticker.C:
+			h.send(span)
+			_, span = h.parent.CreateChild(context.Background())
+		case <-h.stop:
+			h.send(span)
+			h.parent.AddField("heartbeat.intervals", h.sequence)
+			return
+		case <-done:
+			h.send(span)
+			return
+		}
+	}
+}
+
+// send sends span with the traffic since the last one.
+func (h *Heartbeat) send(span *trace.Span) {
+	h.lock.Lock()
+	counts := h.counts
+	h.counts = heartbeatCounts{}
+	h.sequence++
+	sequence := h.sequence
+	h.lock.Unlock()
+
+	span.AddField("name", "heartbeat")
+	span.AddField("heartbeat.sequence", sequence)
+	span.AddField("heartbeat.messages_in", counts.messagesIn)
+	span.AddField("heartbeat.messages_out", counts.messagesOut)
+	span.AddField("heartbeat.bytes_in", counts.bytesIn)
+	span.AddField("heartbeat.bytes_out", counts.bytesOut)
+	span.AddField("heartbeat.errors", counts.errors)
+	span.Send()
+}
@@ -0,0 +1,55 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeat(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, conn := StartSpan(context.Background(), "websocket")
+	h := StartHeartbeat(ctx, 50*time.Millisecond)
+	h.MessageReceived(10)
+	h.MessageReceived(5)
+	h.MessageSent(7)
+	h.Error()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(mo.Events()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	h.MessageSent(1)
+	h.Stop()
+	h.Stop()
+	conn.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		first, last, c := evs[0].Data, evs[1].Data, evs[2].Data
+		assert.Equal(t, "heartbeat", first["name"])
+		assert.Equal(t, 1, first["heartbeat.sequence"])
+		assert.Equal(t, int64(2), first["heartbeat.messages_in"])
+		assert.Equal(t, int64(15), first["heartbeat.bytes_in"])
+		assert.Equal(t, int64(1), first["heartbeat.messages_out"])
+		assert.Equal(t, int64(1), first["heartbeat.errors"])
+		assert.True(t, first["duration_ms"].(float64) >= 50, "a heartbeat should span its interval")
+		assert.Equal(t, c["trace.span_id"], first["trace.parent_id"])
+
+		assert.Equal(t, 2, last["heartbeat.sequence"])
+		assert.Equal(t, int64(0), last["heartbeat.messages_in"], "counts should reset every interval")
+		assert.Equal(t, int64(1), last["heartbeat.messages_out"])
+		assert.Equal(t, 2, c["heartbeat.intervals"])
+	}
+}
+
+func TestHeartbeatWithoutSpan(t *testing.T) {
+	setupLibhoney(t)
+	h := StartHeartbeat(context.Background(), time.Second)
+	assert.Nil(t, h)
+	h.MessageReceived(1)
+	h.MessageSent(1)
+	h.Error()
+	h.Stop()
+}
@@ -0,0 +1,22 @@
+package beeline
+
+import (
+	"net/http"
+	"path"
+)
+
+// ignoreRequestHook returns a trace.IgnoreRequestHook that ignores requests
+// whose path matches one of paths, or that ignore says to.
+func ignoreRequestHook(paths []string, ignore func(*http.Request) bool) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, p := range paths {
+			if p == r.URL.Path {
+				return true
+			}
+			if ok, _ := path.Match(p, r.URL.Path); ok {
+				return true
+			}
+		}
+		return ignore != nil && ignore(r)
+	}
+}
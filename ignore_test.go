@@ -0,0 +1,46 @@
+package beeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/wrappers/common"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnoreHTTP(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client})
+	assert.False(t, common.IgnoreRequest(httptest.NewRequest("GET", "/healthz", nil)), "nothing is ignored by default")
+
+	Init(Config{
+		Client:          client,
+		IgnoreHTTPPaths: []string{"/healthz", "/debug/*"},
+		IgnoreHTTPRequest: func(r *http.Request) bool {
+			return r.Header.Get("User-Agent") == "kube-probe/1.18"
+		},
+	})
+	defer Init(Config{Client: client})
+	for target, want := range map[string]bool{
+		"/healthz":           true,
+		"/healthz?verbose=1": true,
+		"/debug/vars":        true,
+		"/debug/pprof/heap":  false,
+		"/users":             false,
+	} {
+		assert.Equal(t, want, common.IgnoreRequest(httptest.NewRequest("GET", target, nil)), target)
+	}
+	probe := httptest.NewRequest("GET", "/ready", nil)
+	probe.Header.Set("User-Agent", "kube-probe/1.18")
+	assert.True(t, common.IgnoreRequest(probe))
+}
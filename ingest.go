@@ -0,0 +1,172 @@
+package beeline
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+)
+
+const (
+	// projectionPeriod is the length of the "month" volumes are projected to.
+	projectionPeriod = 30 * 24 * time.Hour
+	// maxIngestRoutes bounds the number of routes volume is tracked for;
+	// events for any further routes are counted under "other".
+	maxIngestRoutes = 500
+)
+
+// IngestCounts is the number of events, and their approximate size in bytes,
+// sent over a window.
+type IngestCounts struct {
+	Events uint64
+	Bytes  uint64
+	// ProjectedMonthlyEvents and ProjectedMonthlyBytes extrapolate the counts
+	// to a 30 day month at the rate seen during the window.
+	ProjectedMonthlyEvents float64
+	ProjectedMonthlyBytes  float64
+}
+
+// IngestVolume describes the events sent since the current window started.
+// Bytes are the size of each event's fields encoded as JSON, which is close to
+// but not exactly what Honeycomb counts.
+type IngestVolume struct {
+	// Window is how long events have been counted for.
+	Window time.Duration
+	Total  IngestCounts
+	// Datasets holds the counts for each dataset events were sent to.
+	Datasets map[string]IngestCounts
+	// Routes holds the counts for HTTP request spans by route. Other events
+	// aren't counted here.
+	Routes map[string]IngestCounts
+}
+
+// volumeSender counts the events added to a transmission. It sits outside the
+// retrySender so that retries aren't counted twice.
+type volumeSender struct {
+	transmission.Sender
+
+	lock     sync.Mutex
+	start    time.Time
+	total    IngestCounts
+	datasets map[string]IngestCounts
+	routes   map[string]IngestCounts
+}
+
+func newVolumeSender(tx transmission.Sender) *volumeSender {
+	v := &volumeSender{Sender: tx}
+	v.reset()
+	return v
+}
+
+func (v *volumeSender) reset() {
+	v.start = time.Now()
+	v.total = IngestCounts{}
+	v.datasets = make(map[string]IngestCounts)
+	v.routes = make(map[string]IngestCounts)
+}
+
+func (v *volumeSender) Add(ev *transmission.Event) {
+	size := 0
+	if b, err := json.Marshal(ev.Data); err == nil {
+		size = len(b)
+	}
+	route := spanRoute(ev.Data)
+
+	v.lock.Lock()
+	v.total = addCounts(v.total, size)
+	v.datasets[ev.Dataset] = addCounts(v.datasets[ev.Dataset], size)
+	if route != "" {
+		if _, ok := v.routes[route]; !ok && len(v.routes) >= maxIngestRoutes {
+			route = "other"
+		}
+		v.routes[route] = addCounts(v.routes[route], size)
+	}
+	v.lock.Unlock()
+
+	v.Sender.Add(ev)
+}
+
+func addCounts(c IngestCounts, size int) IngestCounts {
+	c.Events++
+	c.Bytes += uint64(size)
+	return c
+}
+
+// snapshot returns the volume since the window started. If reset is true a
+// new window is started.
+func (v *volumeSender) snapshot(reset bool) IngestVolume {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	window := time.Since(v.start)
+	vol := IngestVolume{
+		Window:   window,
+		Total:    project(v.total, window),
+		Datasets: make(map[string]IngestCounts, len(v.datasets)),
+		Routes:   make(map[string]IngestCounts, len(v.routes)),
+	}
+	for k, c := range v.datasets {
+		vol.Datasets[k] = project(c, window)
+	}
+	for k, c := range v.routes {
+		vol.Routes[k] = project(c, window)
+	}
+	if reset {
+		v.reset()
+	}
+	return vol
+}
+
+func project(c IngestCounts, window time.Duration) IngestCounts {
+	if window > 0 {
+		scale := float64(projectionPeriod) / float64(window)
+		c.ProjectedMonthlyEvents = float64(c.Events) * scale
+		c.ProjectedMonthlyBytes = float64(c.Bytes) * scale
+	}
+	return c
+}
+
+// reportIngestVolume sends a summary event every interval with the volume of
+// the window just finished, then starts a new one.
+func reportIngestVolume(c *libhoney.Client, v *volumeSender, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			vol := v.snapshot(true)
+			ev := c.NewBuilder().NewEvent()
+			ev.AddField("meta.type", "ingest_summary")
+			ev.AddField("name", "ingest_summary")
+			ev.AddField("ingest.window_ms", float64(vol.Window)/float64(time.Millisecond))
+			ev.AddField("ingest.events", vol.Total.Events)
+			ev.AddField("ingest.bytes", vol.Total.Bytes)
+			ev.AddField("ingest.projected_monthly_events", vol.Total.ProjectedMonthlyEvents)
+			ev.AddField("ingest.projected_monthly_bytes", vol.Total.ProjectedMonthlyBytes)
+			for dataset, c := range vol.Datasets {
+				ev.AddField("ingest.dataset."+dataset+".events", c.Events)
+			}
+			ev.Send()
+		}
+	}
+}
+
+// GetIngestVolume returns the number and size of the events sent since
+// tracking started, or since the last summary event if
+// Config.IngestSummaryInterval is set, with projections of the monthly volume.
+// It is empty unless Config.TrackIngestVolume was set.
+func GetIngestVolume() IngestVolume {
+	return std.GetIngestVolume()
+}
+
+// GetIngestVolume returns the volume of the events b has sent, as
+// GetIngestVolume does.
+func (b *Beeline) GetIngestVolume() IngestVolume {
+	if b.volume == nil {
+		return IngestVolume{}
+	}
+	return b.volume.snapshot(false)
+}
@@ -0,0 +1,81 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/beeline-go/client"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeSender(t *testing.T) {
+	mo := &transmission.MockSender{}
+	v := newVolumeSender(mo)
+	v.Add(&transmission.Event{Dataset: "a", Data: map[string]interface{}{
+		"meta.type":     "http_request",
+		"handler.route": "/orders/{id}",
+	}})
+	v.Add(&transmission.Event{Dataset: "a", Data: map[string]interface{}{"x": 1}})
+	v.Add(&transmission.Event{Dataset: "b", Data: map[string]interface{}{}})
+	assert.Equal(t, 3, len(mo.Events()), "events should be passed on")
+
+	vol := v.snapshot(true)
+	assert.Equal(t, uint64(3), vol.Total.Events)
+	assert.Equal(t, uint64(2), vol.Datasets["a"].Events)
+	assert.Equal(t, uint64(1), vol.Datasets["b"].Events)
+	assert.Equal(t, uint64(2), vol.Datasets["b"].Bytes, "an empty event encodes as {}")
+	assert.Equal(t, 1, len(vol.Routes))
+	assert.Equal(t, uint64(1), vol.Routes["/orders/{id}"].Events)
+	assert.True(t, vol.Total.ProjectedMonthlyEvents > 3)
+	assert.Equal(t, uint64(0), v.snapshot(false).Total.Events, "the window should have been reset")
+}
+
+func TestProject(t *testing.T) {
+	c := project(IngestCounts{Events: 10, Bytes: 100}, 24*time.Hour)
+	assert.Equal(t, float64(300), c.ProjectedMonthlyEvents)
+	assert.Equal(t, float64(3000), c.ProjectedMonthlyBytes)
+}
+
+func TestTrackIngestVolume(t *testing.T) {
+	Init(Config{Mute: true, TrackIngestVolume: true})
+	defer setupLibhoney(t)
+
+	_, span := StartSpan(context.Background(), "request")
+	span.Send()
+	assert.Equal(t, uint64(1), GetIngestVolume().Total.Events)
+	assert.Equal(t, uint64(1), GetIngestVolume().Datasets[defaultDataset].Events)
+}
+
+func TestIngestVolumeWithClient(t *testing.T) {
+	setupLibhoney(t)
+	assert.Equal(t, IngestVolume{}, GetIngestVolume())
+}
+
+func TestReportIngestVolume(t *testing.T) {
+	mo := setupLibhoney(t)
+	v := newVolumeSender(&transmission.MockSender{})
+	v.Add(&transmission.Event{Dataset: "a", Data: map[string]interface{}{}})
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		reportIngestVolume(client.Get(), v, time.Millisecond, done)
+		close(stopped)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(mo.Events()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(done)
+	<-stopped
+
+	evs := mo.Events()
+	if assert.True(t, len(evs) > 0, "a summary should have been sent") {
+		fields := evs[0].Data
+		assert.Equal(t, "ingest_summary", fields["meta.type"])
+		assert.Equal(t, uint64(1), fields["ingest.events"])
+		assert.Equal(t, uint64(1), fields["ingest.dataset.a.events"])
+	}
+}
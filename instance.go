@@ -0,0 +1,306 @@
+package beeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+
+	"github.com/honeycombio/beeline-go/client"
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// Beeline sends traces as a Config of its own describes, for libraries and
+// multi-tenant binaries that need to report to a different write key or
+// dataset than the rest of the process, or to sample, filter or record
+// requests differently. The package level functions work on the default
+// beeline set up by Init, and each has a method of the same name that works on
+// b.
+//
+// Everything in the Config is per instance: the client, with its write key,
+// dataset, batching, retries, spooling and transmission stats, the sampling
+// and hooks, and what the wrappers record. Only the counters served by
+// MetricsHandler, the open spans sent by Shutdown and the in progress spans
+// reported by InProgressAfter are kept for the whole process.
+//
+// A trace keeps the client and config of the beeline that started it, so spans
+// started with the package functions, or by wrappers, in a context holding one
+// of its spans are sent with it too:
+//
+//	billing, err := beeline.New(beeline.Config{WriteKey: billingKey, Dataset: "billing"})
+//	...
+//	ctx, span := billing.StartSpan(ctx, "charge")
+//	defer span.Send()
+//	beeline.AddField(ctx, "amount", amount)
+//
+// Wrappers start traces with the beeline in the context they're given. The
+// hnynethttp and hnyfasthttp handlers take a beeline with their WithBeeline
+// option. The other HTTP wrappers, such as hnygorilla, hnychi, hnyecho and
+// hnygin, are given one by serving the router or framework with Handler. gRPC
+// servers can set the context with b.Context in an interceptor chained before
+// hnygrpc's, and database calls made through a driver wrapped by hnysql, which
+// hnysqlx and ORMs use too, are sent with the beeline of the context they're
+// made with when they aren't part of a trace.
+type Beeline struct {
+	client *libhoney.Client
+	// ownsClient is set if the client was created for the beeline, so Close
+	// closes it.
+	ownsClient bool
+	// config is what the beeline's traces are sent with.
+	config *trace.Config
+	// sender is the transmission created for the beeline, used for
+	// TransmissionStats. It is nil if the beeline was given a client.
+	sender *retrySender
+	// volume counts the events sent when Config.TrackIngestVolume is set.
+	volume *volumeSender
+	// remote is set when Config.RemoteConfigURL is.
+	remote *remoteConfig
+	// background tracks the goroutines started for the beeline; Close stops
+	// them.
+	background *lifecycle
+
+	markerLock sync.Mutex
+	markers    markerSettings
+	// flushLock is held while the client is flushed. See flushClient.
+	flushLock sync.Mutex
+}
+
+// New returns a beeline that sends traces as config describes, without
+// changing the default beeline. Unlike Init, fields left unset aren't taken
+// from the environment. It returns an error if the beeline can't work as
+// configured, eg because the spool directory can't be opened.
+func New(config Config) (*Beeline, error) {
+	b := &Beeline{config: &trace.Config{}}
+	if err := b.setup(config); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Client returns the client the beeline sends its traces with.
+func (b *Beeline) Client() *libhoney.Client {
+	if b == std {
+		return client.Get()
+	}
+	return b.client
+}
+
+// TraceConfig returns the config the beeline's traces are sent with, for
+// wrappers that start traces with it.
+func (b *Beeline) TraceConfig() *trace.Config {
+	return b.config
+}
+
+// setClient makes c the client b sends with. The default beeline's client is
+// the one set with client.Set, so that the client package uses it too. As
+// with client.Set, a nil client is replaced by one that discards everything
+// sent to it.
+func (b *Beeline) setClient(c *libhoney.Client) {
+	if b == std {
+		client.Set(c)
+		return
+	}
+	if c == nil {
+		c = &libhoney.Client{}
+	}
+	b.client = c
+}
+
+// txResponses returns the queue of responses from b's client.
+func (b *Beeline) txResponses() chan transmission.Response {
+	if b == std {
+		return client.TxResponses()
+	}
+	return b.client.TxResponses()
+}
+
+// Context returns ctx set up so that any trace started with it, eg by
+// StartSpan or a wrapper, is sent by b.
+func (b *Beeline) Context(ctx context.Context) context.Context {
+	if b == std {
+		// traces are sent by the default beeline unless their context says
+		// otherwise
+		return ctx
+	}
+	ctx = trace.PutClientInContext(ctx, b.client)
+	return trace.PutConfigInContext(ctx, b.config)
+}
+
+// Handler returns a handler that serves requests with h, with contexts set up
+// so that the traces wrappers inside h start for them are sent by b.
+func (b *Beeline) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(b.Context(r.Context())))
+	})
+}
+
+// StartSpan starts a span as StartSpan does, as a child of the span in ctx
+// if there is one. Otherwise it starts a new trace, sent by b.
+func (b *Beeline) StartSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	span := trace.GetSpanFromContext(ctx)
+	var newSpan *trace.Span
+	if span != nil {
+		ctx, newSpan = span.CreateChild(ctx)
+	} else {
+		// there is no trace active; we should make one, but use the root span
+		// as the "new" span instead of creating a child of this mostly empty
+		// span
+		ctx, _ = trace.NewTrace(b.Context(ctx), "")
+		newSpan = trace.GetSpanFromContext(ctx)
+	}
+	newSpan.AddField("name", name)
+	return ctx, newSpan
+}
+
+// BuilderFromContext returns a builder for events that belong to the trace
+// in ctx, as BuilderFromContext does. If ctx has no span, the builder makes
+// events sent by b.
+func (b *Beeline) BuilderFromContext(ctx context.Context) *libhoney.Builder {
+	if span := trace.GetSpanFromContext(ctx); span != nil {
+		return span.NewBuilder()
+	}
+	return b.Client().NewBuilder()
+}
+
+// Flush sends the trace in ctx, if any, and b's pending events, as Flush
+// does.
+func (b *Beeline) Flush(ctx context.Context) error {
+	tr := trace.GetTraceFromContext(ctx)
+	if tr != nil {
+		tr.Send()
+	}
+	before := b.GetTransmissionStats().Failed
+	err := b.flushClient(ctx)
+	dropped := b.GetTransmissionStats().Failed - before
+	if err != nil || dropped > 0 {
+		return &FlushError{Dropped: dropped, Err: err}
+	}
+	return nil
+}
+
+// Close flushes b's pending events, closes its client if it was created by
+// New, and stops its background goroutines. Traces can't be sent with b once
+// it has been closed.
+func (b *Beeline) Close() {
+	if b.ownsClient {
+		b.closeClient()
+		b.ownsClient = false
+	} else if c := b.Client(); c != nil {
+		b.flushLock.Lock()
+		c.Flush()
+		b.flushLock.Unlock()
+	}
+	if b.background != nil {
+		b.background.stop()
+	}
+}
+
+// GetTransmissionStats returns counts of the events b has sent, failed and
+// retried, as GetTransmissionStats does.
+func (b *Beeline) GetTransmissionStats() TransmissionStats {
+	if b.sender == nil {
+		return TransmissionStats{}
+	}
+	return b.sender.stats()
+}
+
+// AddField adds a field to the current span, as AddField does.
+func (b *Beeline) AddField(ctx context.Context, key string, val interface{}) bool {
+	span := trace.GetSpanFromContext(ctx)
+	if span == nil || val == nil || !span.IsRecording() {
+		return false
+	}
+	addAppField(span, key, val)
+	return true
+}
+
+// AddFieldf adds a field with a formatted value to the current span, as
+// AddFieldf does.
+func (b *Beeline) AddFieldf(ctx context.Context, key string, format string, args ...interface{}) bool {
+	span := trace.GetSpanFromContext(ctx)
+	if span == nil || !span.IsRecording() {
+		return false
+	}
+	addAppField(span, key, fmt.Sprintf(format, args...))
+	return true
+}
+
+// AddFields adds each of the fields to the current span, as AddFields does.
+func (b *Beeline) AddFields(ctx context.Context, fields map[string]interface{}) bool {
+	span := trace.GetSpanFromContext(ctx)
+	if span == nil || !span.IsRecording() {
+		return false
+	}
+	for key, val := range fields {
+		if val != nil {
+			addAppField(span, key, val)
+		}
+	}
+	return true
+}
+
+// addAppField adds val to span under key prefixed with `app.`, as a string
+// if it's an error.
+func addAppField(span *trace.Span, key string, val interface{}) {
+	namespacedKey := "app." + key
+	if valErr, ok := val.(error); ok {
+		// treat errors specially because it's a pain to have to
+		// remember to stringify them
+		span.AddField(namespacedKey, valErr.Error())
+	} else {
+		span.AddField(namespacedKey, val)
+	}
+}
+
+// AddFieldToTrace adds the field to every span of the trace in ctx, as
+// AddFieldToTrace does.
+func (b *Beeline) AddFieldToTrace(ctx context.Context, key string, val interface{}) {
+	namespacedKey := fmt.Sprintf("app.%s", key)
+	tr := trace.GetTraceFromContext(ctx)
+	if tr != nil {
+		tr.AddField(namespacedKey, val)
+	}
+}
+
+// AddError records err on the current span, as AddError does.
+func (b *Beeline) AddError(ctx context.Context, err error) {
+	if span := trace.GetSpanFromContext(ctx); span != nil {
+		span.AddError(err)
+	}
+}
+
+// AddEvent records that something happened during the current span, as
+// AddEvent does.
+func (b *Beeline) AddEvent(ctx context.Context, name string, fields map[string]interface{}) {
+	if span := trace.GetSpanFromContext(ctx); span != nil {
+		span.AddEvent(name, fields)
+	}
+}
+
+// AddLink links the current span to another span, as AddLink does.
+func (b *Beeline) AddLink(ctx context.Context, traceID, spanID string, fields map[string]interface{}) {
+	if span := trace.GetSpanFromContext(ctx); span != nil {
+		span.AddLink(traceID, spanID, fields)
+	}
+}
+
+// Increment adds delta to a counter of the trace in ctx, as Increment does.
+func (b *Beeline) Increment(ctx context.Context, name string, delta int64) {
+	tr := trace.GetTraceFromContext(ctx)
+	if tr != nil {
+		tr.IncrementCounter("app."+name, delta)
+	}
+}
+
+// TraceIDFromContext returns the ID of the trace in ctx, as
+// TraceIDFromContext does.
+func (b *Beeline) TraceIDFromContext(ctx context.Context) string {
+	if tr := trace.GetTraceFromContext(ctx); tr != nil {
+		return tr.GetTraceID()
+	}
+	return ""
+}
@@ -0,0 +1,193 @@
+package beeline
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/beeline-go/wrappers/common"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBeelineInstances(t *testing.T) {
+	global := setupLibhoney(t)
+	mo := &transmission.MockSender{}
+	c, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "billing",
+		Dataset:      "billing",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	billing, err := New(Config{Client: c})
+	assert.NoError(t, err)
+
+	ctx, span := billing.StartSpan(context.Background(), "charge")
+	// spans in the instance's trace go with it, however they're started
+	_, child := StartSpan(ctx, "card")
+	child.Send()
+	billing.BuilderFromContext(ctx).NewEvent().Send()
+	span.Send()
+	_, other := StartSpan(context.Background(), "request")
+	other.Send()
+	ev := billing.BuilderFromContext(context.Background()).NewEvent()
+	ev.AddField("name", "audit")
+	ev.SendPresampled()
+
+	evs := mo.Events()
+	if assert.Equal(t, 4, len(evs)) {
+		for _, ev := range evs {
+			assert.Equal(t, "billing", ev.Dataset)
+		}
+		assert.Equal(t, "card", evs[0].Data["name"])
+		assert.Equal(t, "charge", evs[2].Data["name"])
+		assert.Equal(t, evs[2].Data["trace.span_id"], evs[0].Data["trace.parent_id"])
+		assert.Equal(t, "audit", evs[3].Data["name"])
+	}
+	if evs := global.Events(); assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "request", evs[0].Data["name"])
+	}
+	assert.NoError(t, billing.Flush(context.Background()))
+	billing.Close()
+}
+
+func TestNewBeeline(t *testing.T) {
+	b, err := New(Config{WriteKey: "abc", Dataset: "tenant", ServiceName: "api", Mute: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ev := b.BuilderFromContext(context.Background()).NewEvent()
+	assert.Equal(t, "tenant", ev.Dataset)
+	assert.Equal(t, "abc", ev.WriteKey)
+	assert.Equal(t, "api", ev.Fields()["service_name"])
+	b.Close()
+}
+
+func TestBeelineHandler(t *testing.T) {
+	global := setupLibhoney(t)
+	mo := &transmission.MockSender{}
+	c, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "billing",
+		Dataset:      "billing",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	billing, err := New(Config{Client: c})
+	assert.NoError(t, err)
+
+	// a wrapper inside the handler starts the request's trace
+	h := billing.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := common.StartSpanOrTraceFromHTTP(r)
+		span.Send()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/charge", nil))
+
+	if evs := mo.Events(); assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "/charge", evs[0].Data["request.path"])
+	}
+	assert.Equal(t, 0, len(global.Events()))
+}
+
+// newInstanceClient returns a client for a Beeline that sends to a mock.
+func newInstanceClient(t *testing.T, dataset string) (*libhoney.Client, *transmission.MockSender) {
+	mo := &transmission.MockSender{}
+	c, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       dataset,
+		Dataset:      dataset,
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	return c, mo
+}
+
+func TestBeelineInstanceConfig(t *testing.T) {
+	global := setupLibhoney(t)
+	billingClient, billingEvents := newInstanceClient(t, "billing")
+	billing, err := New(Config{
+		Client:      billingClient,
+		PresendHook: func(fields map[string]interface{}) { fields["tenant"] = "billing" },
+		SamplerHook: func(map[string]interface{}) (bool, int) { return true, 5 },
+	})
+	assert.NoError(t, err)
+	searchClient, searchEvents := newInstanceClient(t, "search")
+	search, err := New(Config{Client: searchClient, SampleRate: 1})
+	assert.NoError(t, err)
+
+	for _, b := range []*Beeline{billing, search, std} {
+		ctx, span := b.StartSpan(context.Background(), "work")
+		// a child started by a wrapper is sent with its trace's config
+		_, child := common.StartSpanOrTraceFromHTTP(httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+		child.Send()
+		span.Send()
+	}
+
+	if evs := billingEvents.Events(); assert.Equal(t, 2, len(evs)) {
+		for _, ev := range evs {
+			assert.Equal(t, "billing", ev.Data["tenant"])
+			assert.Equal(t, uint(5), ev.SampleRate)
+		}
+	}
+	for _, mo := range []*transmission.MockSender{searchEvents, global} {
+		if evs := mo.Events(); assert.Equal(t, 2, len(evs)) {
+			for _, ev := range evs {
+				assert.NotContains(t, ev.Data, "tenant")
+				assert.Equal(t, uint(1), ev.SampleRate)
+			}
+		}
+	}
+	billing.Close()
+	search.Close()
+}
+
+func TestNewUsesTransmissionSettings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"status":202}]`))
+	}))
+	defer srv.Close()
+	setupLibhoney(t)
+
+	statsReported := make(chan TransmissionStats, 10)
+	b, err := New(Config{
+		WriteKey:                  "abc",
+		APIHost:                   srv.URL,
+		TransmissionStatsHandler:  func(s TransmissionStats) { statsReported <- s },
+		TransmissionStatsInterval: time.Millisecond,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, span := b.StartSpan(context.Background(), "work")
+	span.Send()
+	assert.NoError(t, b.Flush(context.Background()))
+	assert.Equal(t, uint64(1), b.GetTransmissionStats().Sent)
+	assert.Equal(t, TransmissionStats{}, GetTransmissionStats(),
+		"the instance's events shouldn't count towards the default beeline's")
+	select {
+	case <-statsReported:
+	case <-time.After(5 * time.Second):
+		t.Error("the instance's stats should be reported")
+	}
+	b.Close()
+
+	// the spool can't be a file
+	dir, err := ioutil.TempDir("", "beeline-instance")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, ioutil.WriteFile(file, nil, 0600))
+	_, err = New(Config{WriteKey: "abc", Mute: true, SpoolDir: file})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "beeline: failed to open spool")
+	}
+}
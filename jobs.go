@@ -0,0 +1,104 @@
+package beeline
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// maxJobPropagationErrorLength bounds meta.propagation_error, which can quote
+// the trace context stored in a job's payload.
+const maxJobPropagationErrorLength = 256
+
+// addJobPropagationError records err on span as meta.propagation_error, cut
+// to maxJobPropagationErrorLength bytes between characters.
+func addJobPropagationError(span *trace.Span, err error) {
+	msg := err.Error()
+	if len(msg) > maxJobPropagationErrorLength {
+		cut := maxJobPropagationErrorLength
+		for cut > 0 && !utf8.RuneStart(msg[cut]) {
+			cut--
+		}
+		msg = msg[:cut]
+	}
+	span.AddField("meta.propagation_error", msg)
+}
+
+// Job describes a unit of background work, such as a cron job or a message
+// taken from a queue, to StartJob.
+type Job struct {
+	// Name is the kind of work, eg "send_welcome_email". It names the job's
+	// span.
+	Name string
+	// Queue is the queue the job was taken from, if any.
+	Queue string
+	// Attempt is the number of times the job has been run, counting this
+	// one, if the queue keeps count.
+	Attempt int
+	// TraceContext is the trace context from JobTraceContext stored in the
+	// job's payload when it was enqueued, if any.
+	TraceContext string
+}
+
+// StartJob starts a trace for running job and returns a context holding its
+// root span, which the caller must send when the job is done:
+//
+//	ctx, span := beeline.StartJob(context.Background(), beeline.Job{
+//		Name:         "send_welcome_email",
+//		Queue:        "mailers",
+//		Attempt:      msg.Attempt,
+//		TraceContext: msg.TraceContext,
+//	})
+//	defer span.Send()
+//
+// The job's span records job.name, job.queue and job.attempt. If the job
+// carries the trace context of the work that enqueued it, the job continues
+// that trace as a child of the span that enqueued it. Otherwise it is the
+// root of a new trace. Any span already in ctx is ignored.
+func StartJob(ctx context.Context, job Job) (context.Context, *trace.Span) {
+	return std.StartJob(ctx, job)
+}
+
+// StartJob starts a trace for running job, as StartJob does. A job that
+// doesn't continue the trace that enqueued it starts a new one sent by b.
+func (b *Beeline) StartJob(ctx context.Context, job Job) (context.Context, *trace.Span) {
+	var prop *propagation.PropagationContext
+	var propErr error
+	if job.TraceContext != "" {
+		prop, propErr = propagation.UnmarshalHoneycombTraceContext(job.TraceContext)
+	}
+	ctx, tr := trace.NewTraceFromPropagationContext(b.Context(ctx), prop)
+	span := tr.GetRootSpan()
+	if propErr != nil {
+		addJobPropagationError(span, propErr)
+	}
+	span.AddField("meta.type", "job")
+	span.AddField("name", job.Name)
+	span.AddField("job.name", job.Name)
+	if job.Queue != "" {
+		span.AddField("job.queue", job.Queue)
+	}
+	if job.Attempt > 0 {
+		span.AddField("job.attempt", job.Attempt)
+	}
+	return ctx, span
+}
+
+// JobTraceContext returns the trace context of the span in ctx, to store in
+// the payload of a job enqueued from it and pass to StartJob when the job
+// runs. It returns an empty string if ctx has no span.
+func JobTraceContext(ctx context.Context) string {
+	return std.JobTraceContext(ctx)
+}
+
+// JobTraceContext returns the trace context of the span in ctx, as
+// JobTraceContext does.
+func (b *Beeline) JobTraceContext(ctx context.Context) string {
+	span := trace.GetSpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	return span.SerializeHeaders()
+}
@@ -0,0 +1,76 @@
+package beeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartJob(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, enqueue := StartSpan(context.Background(), "enqueue")
+	traceContext := JobTraceContext(ctx)
+	enqueue.Send()
+	assert.Equal(t, "", JobTraceContext(context.Background()))
+
+	// the job is run by a worker whose own span is ignored
+	pollCtx, poll := StartSpan(context.Background(), "poll")
+	ctx, job := StartJob(pollCtx, Job{Name: "send_email", Queue: "mailers", Attempt: 2, TraceContext: traceContext})
+	_, child := StartSpan(ctx, "render")
+	child.Send()
+	job.Send()
+	poll.Send()
+
+	_, cron := StartJob(context.Background(), Job{Name: "nightly_report"})
+	cron.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 5, len(evs)) {
+		return
+	}
+	enq, render, run, reportJob := evs[0].Data, evs[1].Data, evs[2].Data, evs[4].Data
+	assert.Equal(t, "send_email", run["name"])
+	assert.Equal(t, "job", run["meta.type"])
+	assert.Equal(t, "send_email", run["job.name"])
+	assert.Equal(t, "mailers", run["job.queue"])
+	assert.Equal(t, 2, run["job.attempt"])
+	assert.Equal(t, enq["trace.trace_id"], run["trace.trace_id"])
+	assert.Equal(t, enq["trace.span_id"], run["trace.parent_id"])
+	assert.Equal(t, run["trace.span_id"], render["trace.parent_id"])
+
+	assert.Equal(t, "nightly_report", reportJob["name"])
+	assert.NotContains(t, reportJob, "trace.parent_id")
+	assert.NotContains(t, reportJob, "job.queue")
+	assert.NotContains(t, reportJob, "job.attempt")
+}
+
+func TestStartJobBadTraceContext(t *testing.T) {
+	mo := setupLibhoney(t)
+	_, span := StartJob(context.Background(), Job{Name: "send_email", TraceContext: "2;nope"})
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Contains(t, evs[0].Data, "meta.propagation_error")
+		assert.NotContains(t, evs[0].Data, "trace.parent_id")
+	}
+}
+
+func TestStartJobLongBadTraceContext(t *testing.T) {
+	mo := setupLibhoney(t)
+	for _, header := range []string{"1;" + strings.Repeat("é", 500), "1;x" + strings.Repeat("é", 500)} {
+		_, span := StartJob(context.Background(), Job{Name: "send_email", TraceContext: header})
+		span.Send()
+	}
+
+	evs := mo.Events()
+	assert.Equal(t, 2, len(evs))
+	for _, ev := range evs {
+		propErr, _ := ev.Data["meta.propagation_error"].(string)
+		assert.True(t, utf8.ValidString(propErr), "errors should be cut between characters")
+		assert.True(t, len(propErr) == maxJobPropagationErrorLength || len(propErr) == maxJobPropagationErrorLength-1)
+	}
+}
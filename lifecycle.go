@@ -0,0 +1,38 @@
+package beeline
+
+import "sync"
+
+// lifecycle owns the background goroutines started by the beeline. One is
+// created by Init and stopped by Close, so anything that runs in the
+// background (response readers, periodic reporters and the like) must be
+// started with goFunc rather than a bare go statement.
+type lifecycle struct {
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		done: make(chan struct{}),
+	}
+}
+
+// goFunc runs f in a new goroutine. The channel passed to f is closed when the
+// lifecycle is stopped, and f must return promptly once it is.
+func (l *lifecycle) goFunc(f func(done <-chan struct{})) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		f(l.done)
+	}()
+}
+
+// stop signals every goroutine started by goFunc to finish and waits for them
+// to do so. It is safe to call more than once.
+func (l *lifecycle) stop() {
+	l.stopOnce.Do(func() {
+		close(l.done)
+	})
+	l.wg.Wait()
+}
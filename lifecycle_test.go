@@ -0,0 +1,59 @@
+package beeline
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertNoGoroutineLeak waits for the number of goroutines to drop back to
+// before. assert.Eventually isn't used because it runs its condition in a
+// goroutine of its own.
+func assertNoGoroutineLeak(t *testing.T, before int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Errorf("goroutines were leaked: %d running, expected %d", runtime.NumGoroutine(), before)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLifecycleStop(t *testing.T) {
+	l := newLifecycle()
+	var finished bool
+	l.goFunc(func(done <-chan struct{}) {
+		<-done
+		finished = true
+	})
+	l.stop()
+	assert.True(t, finished, "stop should wait for goroutines to return")
+	// stopping again must not panic or block
+	l.stop()
+}
+
+// TestCloseStopsBackgroundGoroutines checks that nothing started by Init is
+// left running after Close.
+func TestCloseStopsBackgroundGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	Init(Config{Mute: true, Debug: true, MaxRetries: 2})
+	Close()
+	assertNoGoroutineLeak(t, before)
+}
+
+func TestInitStopsPreviousBackgroundGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	Init(Config{Mute: true, Debug: true})
+	first := std.background
+	Init(Config{Mute: true})
+	select {
+	case <-first.done:
+	default:
+		t.Error("a second Init should stop the first one's background work")
+	}
+	Close()
+	assertNoGoroutineLeak(t, before)
+}
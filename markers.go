@@ -0,0 +1,136 @@
+package beeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAPIHost = "https://api.honeycomb.io/"
+	// startupMarkerTimeout bounds how long Init's startup marker may take, so
+	// a slow API can't hold a background goroutine for long.
+	startupMarkerTimeout = 10 * time.Second
+)
+
+// Marker is a Honeycomb marker, which annotates the graphs of a dataset at a
+// point in time, eg to show when a deploy happened.
+type Marker struct {
+	// Message is the marker's label.
+	Message string `json:"message,omitempty"`
+	// Type groups similar markers, eg "deploy" or "migration".
+	Type string `json:"type,omitempty"`
+	// URL links the marker to more detail, eg a build.
+	URL string `json:"url,omitempty"`
+	// StartTime and EndTime are Unix timestamps in seconds. If StartTime is
+	// zero, the marker is placed at the time it is received.
+	StartTime int64 `json:"start_time,omitempty"`
+	EndTime   int64 `json:"end_time,omitempty"`
+}
+
+// markerSettings are the API settings a beeline sends markers with.
+type markerSettings struct {
+	writeKey  string
+	dataset   string
+	apiHost   string
+	transport http.RoundTripper
+}
+
+// markerHTTPClient is used to send markers. It is replaced in tests.
+var markerHTTPClient = http.DefaultClient
+
+func (b *Beeline) setMarkerConfig(config Config, transport http.RoundTripper) {
+	apiHost := config.APIHost
+	if apiHost == "" {
+		apiHost = defaultAPIHost
+	}
+	b.markerLock.Lock()
+	defer b.markerLock.Unlock()
+	b.markers = markerSettings{
+		writeKey:  config.WriteKey,
+		dataset:   config.Dataset,
+		apiHost:   apiHost,
+		transport: transport,
+	}
+}
+
+// SendMarker creates a marker on the dataset given to Init using the Markers
+// API, authenticated with the same write key. It returns an error if no write
+// key was given to Init or the API rejects the marker.
+func SendMarker(ctx context.Context, m Marker) error {
+	return std.SendMarker(ctx, m)
+}
+
+// SendMarker creates a marker on b's dataset, as SendMarker does.
+func (b *Beeline) SendMarker(ctx context.Context, m Marker) error {
+	b.markerLock.Lock()
+	settings := b.markers
+	b.markerLock.Unlock()
+	if settings.writeKey == "" || settings.writeKey == defaultWriteKey {
+		return errors.New("beeline: a write key is needed to send markers")
+	}
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	u := strings.TrimSuffix(settings.apiHost, "/") + "/1/markers/" + url.PathEscape(settings.dataset)
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Honeycomb-Team", settings.writeKey)
+	req.Header.Set("User-Agent", "beeline-go/"+version)
+
+	resp, err := httpClientFor(settings.transport, markerHTTPClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("beeline: creating marker failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// startupMarker describes this process starting.
+func startupMarker(config Config) Marker {
+	name := config.ServiceName
+	if name == "" {
+		name = "service"
+	}
+	msg := name + " started"
+	if config.ServiceVersion != "" {
+		msg = name + " " + config.ServiceVersion + " started"
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		msg += " on " + hostname
+	}
+	return Marker{Message: msg, Type: "deploy"}
+}
+
+// sendStartupMarker sends the startup marker, giving up if the beeline is
+// closed first.
+func (b *Beeline) sendStartupMarker(m Marker, debug bool, done <-chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), startupMarkerTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := b.SendMarker(ctx, m); err != nil && debug {
+		fmt.Fprintf(os.Stderr, "beeline: failed to send startup marker: %s\n", err)
+	}
+}
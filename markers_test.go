@@ -0,0 +1,96 @@
+package beeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type markerServer struct {
+	*httptest.Server
+	lock    sync.Mutex
+	paths   []string
+	keys    []string
+	markers []Marker
+}
+
+func newMarkerServer(status int) *markerServer {
+	s := &markerServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m Marker
+		json.NewDecoder(r.Body).Decode(&m)
+		s.lock.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.keys = append(s.keys, r.Header.Get("X-Honeycomb-Team"))
+		s.markers = append(s.markers, m)
+		s.lock.Unlock()
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func (s *markerServer) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.markers)
+}
+
+func TestSendMarker(t *testing.T) {
+	srv := newMarkerServer(http.StatusCreated)
+	defer srv.Close()
+	Init(Config{WriteKey: "key", Dataset: "my data", APIHost: srv.URL, Mute: true})
+	defer setupLibhoney(t)
+
+	err := SendMarker(context.Background(), Marker{Message: "migrated", Type: "migration"})
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, srv.count()) {
+		assert.Equal(t, "/1/markers/my data", srv.paths[0])
+		assert.Equal(t, "key", srv.keys[0])
+		assert.Equal(t, Marker{Message: "migrated", Type: "migration"}, srv.markers[0])
+	}
+}
+
+func TestSendMarkerErrors(t *testing.T) {
+	srv := newMarkerServer(http.StatusUnauthorized)
+	defer srv.Close()
+	Init(Config{WriteKey: "bad", APIHost: srv.URL, Mute: true})
+	defer setupLibhoney(t)
+	assert.Error(t, SendMarker(context.Background(), Marker{Message: "x"}))
+
+	Init(Config{APIHost: srv.URL, Mute: true})
+	assert.Error(t, SendMarker(context.Background(), Marker{Message: "x"}),
+		"markers need a real write key")
+	assert.Equal(t, 1, srv.count())
+}
+
+func TestStartupMarker(t *testing.T) {
+	srv := newMarkerServer(http.StatusCreated)
+	defer srv.Close()
+	Init(Config{
+		WriteKey:          "key",
+		APIHost:           srv.URL,
+		ServiceName:       "api",
+		ServiceVersion:    "abc123",
+		SendStartupMarker: true,
+		Mute:              true,
+	})
+	defer setupLibhoney(t)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if assert.Equal(t, 1, srv.count()) {
+		m := srv.markers[0]
+		assert.Equal(t, "deploy", m.Type)
+		assert.True(t, strings.HasPrefix(m.Message, "api abc123 started"), m.Message)
+		assert.Equal(t, "/1/markers/"+defaultDataset, srv.paths[0])
+	}
+}
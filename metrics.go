@@ -0,0 +1,85 @@
+package beeline
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// eventsCreated and eventsSampledOut count every span and event finished
+// since the process started, and those sampling or rate limiting dropped.
+var (
+	eventsCreated    uint64
+	eventsSampledOut uint64
+)
+
+// countSendDecision is the trace.GlobalConfig.SendDecisionHook.
+func countSendDecision(kept bool) {
+	atomic.AddUint64(&eventsCreated, 1)
+	if !kept {
+		atomic.AddUint64(&eventsSampledOut, 1)
+	}
+}
+
+// MetricsHandler returns a handler that serves the beeline's own counters in
+// the Prometheus text format, so the health of the instrumentation can be
+// scraped and alerted on like that of the rest of the service:
+//
+//	http.Handle("/metrics/beeline", beeline.MetricsHandler())
+//
+// It serves
+//
+//   - beeline_events_created_total, the spans and events finished
+//   - beeline_events_sampled_out_total, those dropped by sampling or rate limiting
+//   - beeline_events_sent_total, those Honeycomb accepted
+//   - beeline_events_dropped_total, those that could not be sent
+//   - beeline_events_retried_total, the number of times events were sent again
+//   - beeline_queue_depth, the events waiting for a response from Honeycomb
+//   - beeline_send_latency_seconds, a summary of the time from an event
+//     being handed to the transmission to its final response
+//
+// The created and sampled out counts are kept for the life of the process and
+// cover every Beeline; the rest are those of the transmission created by the
+// last call to Init, and are zero if Config.Client was set.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w)
+	})
+}
+
+func writeMetrics(w io.Writer) {
+	var stats TransmissionStats
+	var queued int64
+	var latency time.Duration
+	var latencyCount uint64
+	if s := std.sender; s != nil {
+		stats = s.stats()
+		queued = atomic.LoadInt64(&s.inFlight)
+		latency = time.Duration(atomic.LoadUint64(&s.latencyNanos))
+		latencyCount = atomic.LoadUint64(&s.latencyCount)
+	}
+	writeMetric(w, "beeline_events_created_total", "counter",
+		"Spans and events finished, before sampling.", atomic.LoadUint64(&eventsCreated))
+	writeMetric(w, "beeline_events_sampled_out_total", "counter",
+		"Spans and events dropped by sampling or rate limiting.", atomic.LoadUint64(&eventsSampledOut))
+	writeMetric(w, "beeline_events_sent_total", "counter",
+		"Events accepted by Honeycomb.", stats.Sent)
+	writeMetric(w, "beeline_events_dropped_total", "counter",
+		"Events that could not be sent.", stats.Failed)
+	writeMetric(w, "beeline_events_retried_total", "counter",
+		"Times an event was sent again after a failure.", stats.Retried)
+	writeMetric(w, "beeline_queue_depth", "gauge",
+		"Events waiting for a response from Honeycomb.", queued)
+
+	fmt.Fprintf(w, "# HELP beeline_send_latency_seconds Time from an event being queued to its final response.\n")
+	fmt.Fprintf(w, "# TYPE beeline_send_latency_seconds summary\n")
+	fmt.Fprintf(w, "beeline_send_latency_seconds_sum %g\n", latency.Seconds())
+	fmt.Fprintf(w, "beeline_send_latency_seconds_count %d\n", latencyCount)
+}
+
+func writeMetric(w io.Writer, name, kind, help string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
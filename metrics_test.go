@@ -0,0 +1,53 @@
+package beeline
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	Init(Config{
+		WriteKey:         "abc",
+		STDOUT:           true,
+		SampleRate:       1,
+		DisableEnvConfig: true,
+	})
+	defer Close()
+	_, span := StartSpan(context.Background(), "work")
+	span.Send()
+	Flush(context.Background())
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+
+	metrics := map[string]string{}
+	for _, line := range strings.Split(body, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if assert.Equal(t, 2, len(parts), line) {
+			metrics[parts[0]] = parts[1]
+		}
+	}
+	assert.NotEqual(t, "0", metrics["beeline_events_created_total"])
+	assert.Equal(t, "1", metrics["beeline_events_sent_total"])
+	assert.Equal(t, "0", metrics["beeline_events_dropped_total"])
+	assert.Equal(t, "0", metrics["beeline_queue_depth"])
+	assert.Equal(t, "1", metrics["beeline_send_latency_seconds_count"])
+	assert.Contains(t, body, "# TYPE beeline_send_latency_seconds summary\n")
+}
+
+func TestCountSendDecision(t *testing.T) {
+	created, sampledOut := eventsCreated, eventsSampledOut
+	countSendDecision(true)
+	countSendDecision(false)
+	assert.Equal(t, created+2, eventsCreated)
+	assert.Equal(t, sampledOut+1, eventsSampledOut)
+}
@@ -0,0 +1,25 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/client"
+)
+
+// TestUninitializedIsNoop makes sure the public API is safe to use when Init
+// was never called or failed to create a client.
+func TestUninitializedIsNoop(t *testing.T) {
+	client.Set(nil)
+	ctx, span := StartSpan(context.Background(), "start")
+	AddField(ctx, "start_col", 1)
+	AddFieldToTrace(ctx, "trace_col", 1)
+	_, child := StartSpan(ctx, "child")
+	child.Send()
+	span.Send()
+	Flush(ctx)
+	Flush(context.Background())
+	AddField(context.Background(), "no_span", 1)
+	AddFieldToTrace(context.Background(), "no_trace", 1)
+	Close()
+}
@@ -0,0 +1,143 @@
+package beeline
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/honeycombio/libhoney-go/transmission"
+)
+
+// OverflowPolicy chooses what happens to an event sent while the queue of
+// events waiting to be sent is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest drops the event being sent, keeping those already
+	// queued. This is libhoney's own behavior and never delays the caller.
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest drops the event that has been queued longest to
+	// make room, favoring recent telemetry.
+	OverflowDropOldest
+	// OverflowBlock makes the caller wait up to Config.OverflowBlockTimeout
+	// for room in the queue, and drops the event if none is made, trading
+	// request latency for completeness.
+	OverflowBlock
+)
+
+const defaultOverflowBlockTimeout = 100 * time.Millisecond
+
+// The errors in responses for events dropped by an overflowSender. Both start
+// with queueOverflowMessage, so neither is retried.
+var (
+	errDroppedOldest = errors.New(queueOverflowMessage + ": dropped oldest")
+	errBlockTimeout  = errors.New(queueOverflowMessage + ": timed out waiting for room")
+)
+
+// overflowSender queues events in front of a transmission that blocks when
+// its own queue is full, so that the overflow policy decides what is dropped
+// rather than libhoney.
+type overflowSender struct {
+	transmission.Sender
+
+	policy   OverflowPolicy
+	capacity uint
+	timeout  time.Duration
+
+	// lock is held for reading while an event is queued, and for writing
+	// while the queue is replaced on Start and closed on Stop.
+	lock    sync.RWMutex
+	queue   chan *transmission.Event
+	drained chan struct{}
+}
+
+// newOverflowSender wraps tx, which must block rather than drop events when
+// it is full, eg a transmission.Honeycomb with BlockOnSend set.
+func newOverflowSender(tx transmission.Sender, config Config) *overflowSender {
+	timeout := config.OverflowBlockTimeout
+	if timeout <= 0 {
+		timeout = defaultOverflowBlockTimeout
+	}
+	return &overflowSender{
+		Sender:   tx,
+		policy:   config.OverflowPolicy,
+		capacity: config.PendingWorkCapacity,
+		timeout:  timeout,
+	}
+}
+
+// Start starts the wrapped transmission and a goroutine feeding it from the
+// queue.
+func (s *overflowSender) Start() error {
+	err := s.Sender.Start()
+	s.lock.Lock()
+	s.queue = make(chan *transmission.Event, s.capacity)
+	s.drained = make(chan struct{})
+	go s.drain(s.queue, s.drained)
+	s.lock.Unlock()
+	return err
+}
+
+// Stop hands every queued event to the wrapped transmission and stops it.
+func (s *overflowSender) Stop() error {
+	s.lock.Lock()
+	if s.queue != nil {
+		close(s.queue)
+		<-s.drained
+		s.queue = nil
+	}
+	s.lock.Unlock()
+	return s.Sender.Stop()
+}
+
+func (s *overflowSender) drain(queue chan *transmission.Event, drained chan struct{}) {
+	defer close(drained)
+	for ev := range queue {
+		s.Sender.Add(ev)
+	}
+}
+
+// Add queues the event, applying the overflow policy if the queue is full.
+func (s *overflowSender) Add(ev *transmission.Event) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.queue == nil {
+		s.drop(ev, errors.New(queueOverflowMessage))
+		return
+	}
+	select {
+	case s.queue <- ev:
+		return
+	default:
+	}
+	switch s.policy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.queue <- ev:
+				return
+			default:
+			}
+			// the drain may have made room already, so only take one
+			select {
+			case old := <-s.queue:
+				s.drop(old, errDroppedOldest)
+			default:
+			}
+		}
+	case OverflowBlock:
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		select {
+		case s.queue <- ev:
+		case <-timer.C:
+			s.drop(ev, errBlockTimeout)
+		}
+	default:
+		s.drop(ev, errors.New(queueOverflowMessage))
+	}
+}
+
+func (s *overflowSender) drop(ev *transmission.Event, err error) {
+	s.Sender.SendResponse(transmission.Response{Err: err, Metadata: ev.Metadata})
+}
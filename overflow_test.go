@@ -0,0 +1,145 @@
+package beeline
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingSender takes events one at a time, blocking each Add until the
+// test releases it, like a full libhoney queue with BlockOnSend set.
+type blockingSender struct {
+	lock      sync.Mutex
+	events    []*transmission.Event
+	adding    chan struct{}
+	release   chan struct{}
+	responses chan transmission.Response
+}
+
+func newBlockingSender() *blockingSender {
+	return &blockingSender{
+		adding:  make(chan struct{}, 100),
+		release: make(chan struct{}, 100),
+	}
+}
+
+func (b *blockingSender) Start() error {
+	b.responses = make(chan transmission.Response, 10)
+	return nil
+}
+
+func (b *blockingSender) Stop() error {
+	close(b.responses)
+	return nil
+}
+
+func (b *blockingSender) Add(ev *transmission.Event) {
+	b.adding <- struct{}{}
+	<-b.release
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.events = append(b.events, ev)
+	b.responses <- transmission.Response{StatusCode: 202, Metadata: ev.Metadata}
+}
+
+func (b *blockingSender) TxResponses() chan transmission.Response {
+	return b.responses
+}
+
+func (b *blockingSender) SendResponse(r transmission.Response) bool {
+	b.responses <- r
+	return false
+}
+
+func (b *blockingSender) metadata() []interface{} {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var md []interface{}
+	for _, ev := range b.events {
+		md = append(md, ev.Metadata)
+	}
+	return md
+}
+
+// fillQueue adds the first event, waits for it to be taken from the queue,
+// then fills the queue behind it.
+func fillQueue(t *testing.T, s *overflowSender, tx *blockingSender, metadata ...interface{}) {
+	s.Add(&transmission.Event{Metadata: metadata[0]})
+	select {
+	case <-tx.adding:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not taken from the queue")
+	}
+	for _, md := range metadata[1:] {
+		s.Add(&transmission.Event{Metadata: md})
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	tx := newBlockingSender()
+	s := newOverflowSender(tx, Config{OverflowPolicy: OverflowDropOldest, PendingWorkCapacity: 2})
+	assert.NoError(t, s.Start())
+
+	fillQueue(t, s, tx, 1, 2, 3)
+	s.Add(&transmission.Event{Metadata: 4})
+	r := <-tx.TxResponses()
+	assert.Equal(t, errDroppedOldest, r.Err)
+	assert.Equal(t, 2, r.Metadata, "the oldest queued event should be dropped")
+
+	for i := 0; i < 3; i++ {
+		tx.release <- struct{}{}
+	}
+	assert.NoError(t, s.Stop())
+	assert.Equal(t, []interface{}{1, 3, 4}, tx.metadata())
+}
+
+func TestOverflowBlock(t *testing.T) {
+	tx := newBlockingSender()
+	s := newOverflowSender(tx, Config{
+		OverflowPolicy:       OverflowBlock,
+		OverflowBlockTimeout: 10 * time.Millisecond,
+		PendingWorkCapacity:  1,
+	})
+	assert.NoError(t, s.Start())
+
+	fillQueue(t, s, tx, 1, 2)
+	start := time.Now()
+	s.Add(&transmission.Event{Metadata: 3})
+	assert.True(t, time.Since(start) >= 10*time.Millisecond, "Add should wait for room")
+	r := <-tx.TxResponses()
+	assert.Equal(t, errBlockTimeout, r.Err)
+	assert.Equal(t, 3, r.Metadata)
+
+	// once the queue has room again events are accepted
+	s.timeout = 5 * time.Second
+	go func() {
+		<-tx.adding
+		tx.release <- struct{}{}
+	}()
+	tx.release <- struct{}{}
+	s.Add(&transmission.Event{Metadata: 4})
+	tx.release <- struct{}{}
+	assert.NoError(t, s.Stop())
+	assert.Equal(t, []interface{}{1, 2, 4}, tx.metadata())
+}
+
+func TestOverflowStats(t *testing.T) {
+	tx := newBlockingSender()
+	o := newOverflowSender(tx, Config{OverflowPolicy: OverflowDropOldest, PendingWorkCapacity: 1})
+	s := startRetrySender(t, o, Config{})
+
+	s.Add(&transmission.Event{Metadata: 1})
+	<-tx.adding
+	s.Add(&transmission.Event{Metadata: 2})
+	s.Add(&transmission.Event{Metadata: 3})
+	r := nextResponse(t, s)
+	assert.Equal(t, 2, r.Metadata)
+
+	tx.release <- struct{}{}
+	tx.release <- struct{}{}
+	assert.NoError(t, s.Stop())
+	assert.Equal(t, TransmissionStats{Sent: 2, Failed: 1, DroppedOldest: 1}, s.stats())
+}
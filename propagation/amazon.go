@@ -2,11 +2,23 @@ package propagation
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
 const (
-	amazonTracePropagationHTTPHeader = "X-Amzn-Trace-Id"
+	// AmazonTracePropagationHTTPHeader is the header AWS load balancers use to
+	// pass trace context.
+	AmazonTracePropagationHTTPHeader = "X-Amzn-Trace-Id"
+
+	// AmazonRootTimestampField is the trace context field holding the epoch
+	// (in seconds) embedded in an AWS Root trace ID. It is used to rebuild the
+	// Root when marshaling the trace context back into an AWS header.
+	AmazonRootTimestampField = "aws.root_timestamp"
+
+	// amazonRootIDLength is the length of the hex encoded unique part of an AWS
+	// Root trace ID.
+	amazonRootIDLength = 24
 )
 
 // MarshalAmazonTraceContext uses the information in prop to create a trace context header
@@ -22,17 +34,26 @@ func MarshalAmazonTraceContext(prop *PropagationContext) string {
 	// From https://docs.aws.amazon.com/elasticloadbalancing/latest/application/load-balancer-request-tracing.html:
 	// "If the X-Amzn-Trace-Id header is present and has a Self field, the load balancer updates
 	// the value of the Self field."
-	h := fmt.Sprintf("Root=%s;Self=%s", prop.TraceID, prop.ParentID)
+	root := prop.TraceID
+	epoch, hasEpoch := amazonRootTimestamp(prop.TraceContext)
+	rebuiltRoot := hasEpoch && isHex(prop.TraceID, amazonRootIDLength)
+	if rebuiltRoot {
+		root = fmt.Sprintf("1-%08x-%s", epoch, prop.TraceID)
+	}
+	h := fmt.Sprintf("Root=%s;Self=%s", root, prop.ParentID)
 
 	if len(prop.TraceContext) != 0 {
-		elems := make([]string, len(prop.TraceContext))
-		i := 0
+		elems := make([]string, 0, len(prop.TraceContext))
 		for k, v := range prop.TraceContext {
-			elems[i] = fmt.Sprintf("%s=%v", k, v)
-			i++
+			if k == AmazonRootTimestampField && rebuiltRoot {
+				// already encoded in the Root
+				continue
+			}
+			elems = append(elems, fmt.Sprintf("%s=%v", k, v))
+		}
+		if len(elems) != 0 {
+			h = h + ";" + strings.Join(elems, ";")
 		}
-		traceContext := ";" + strings.Join(elems, ";")
-		h = h + traceContext
 	}
 
 	return h
@@ -50,6 +71,14 @@ func MarshalAmazonTraceContext(prop *PropagationContext) string {
 // will be put into the map as strings. Note that this differs from the Honeycomb header, where trace context
 // fields are stored as a base64 encoded JSON object and unmarshaled into ints, bools, etc.
 //
+// A Root is made up of a version, the epoch time at which the request started
+// (as 8 hex digits) and a 96 bit unique ID (as 24 hex digits), eg
+// Root=1-67891233-abcdef012345678912345678. When the Root has that form, the
+// unique ID is used as the trace ID and the epoch is stored in the TraceContext as
+// AmazonRootTimestampField, in seconds. Other Root values are used as the trace ID
+// verbatim. Self identifies the load balancer hop that forwarded the request and
+// is used as the parent ID.
+//
 // If the header cannot be used to construct a valid PropagationContext, an error will be returned.
 func UnmarshalAmazonTraceContext(header string) (*PropagationContext, error) {
 	segments := strings.Split(header, ";")
@@ -75,6 +104,10 @@ func UnmarshalAmazonTraceContext(header string) (*PropagationContext, error) {
 			prop.ParentID = keyval[1]
 		case "root":
 			prop.TraceID = keyval[1]
+			if id, epoch, ok := parseAmazonRoot(keyval[1]); ok {
+				prop.TraceID = id
+				prop.TraceContext[AmazonRootTimestampField] = epoch
+			}
 		default:
 			prop.TraceContext[keyval[0]] = keyval[1]
 		}
@@ -93,3 +126,46 @@ func UnmarshalAmazonTraceContext(header string) (*PropagationContext, error) {
 
 	return prop, nil
 }
+
+// parseAmazonRoot splits a Root of the form 1-{8 hex epoch}-{24 hex id} in to
+// its unique ID and epoch. It returns false if the Root is not in that form.
+func parseAmazonRoot(root string) (string, int64, bool) {
+	parts := strings.Split(root, "-")
+	if len(parts) != 3 || parts[0] != "1" || !isHex(parts[1], 8) || !isHex(parts[2], amazonRootIDLength) {
+		return "", 0, false
+	}
+	epoch, err := strconv.ParseInt(parts[1], 16, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return parts[2], epoch, true
+}
+
+// amazonRootTimestamp pulls the Root epoch back out of a trace context. The
+// value may have been through a JSON roundtrip in a Honeycomb header, so it
+// accepts any numeric type.
+func amazonRootTimestamp(traceContext map[string]interface{}) (int64, bool) {
+	switch v := traceContext[AmazonRootTimestampField].(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// isHex reports whether s is exactly length lowercase or uppercase hex digits.
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package propagation
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// B3SingleHTTPHeader is the header holding the whole B3 (Zipkin) trace
+	// context, eg b3: {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}.
+	B3SingleHTTPHeader = "b3"
+
+	// B3TraceIDHTTPHeader, B3SpanIDHTTPHeader, B3ParentSpanIDHTTPHeader,
+	// B3SampledHTTPHeader and B3FlagsHTTPHeader are the headers of the B3
+	// multiple header format.
+	B3TraceIDHTTPHeader      = "X-B3-TraceId"
+	B3SpanIDHTTPHeader       = "X-B3-SpanId"
+	B3ParentSpanIDHTTPHeader = "X-B3-ParentSpanId"
+	B3SampledHTTPHeader      = "X-B3-Sampled"
+	B3FlagsHTTPHeader        = "X-B3-Flags"
+
+	// b3SampledFlag is the bit of PropagationContext.TraceFlags that records
+	// a B3 sampling decision of accept or debug, as in W3C trace context.
+	b3SampledFlag = 0x01
+)
+
+// B3Format chooses which B3 headers, if any, are sent to downstream services.
+type B3Format int
+
+const (
+	// B3None sends no B3 headers.
+	B3None B3Format = iota
+	// B3Single sends the single b3 header.
+	B3Single
+	// B3Multi sends the X-B3-* headers.
+	B3Multi
+)
+
+// MarshalB3SingleTraceContext uses the information in prop to create a trace context header
+// in the B3 single header format, with prop's ParentID as the span ID. The sampling state is
+// taken from prop.TraceFlags.
+//
+// If prop is nil, or its IDs can't be expressed in B3, the returned value will be an empty
+// string. Trace IDs that are UUIDs are sent without their dashes.
+func MarshalB3SingleTraceContext(prop *PropagationContext) string {
+	if prop == nil {
+		return ""
+	}
+	traceID := HexTraceID(prop.TraceID)
+	if !isB3TraceID(traceID) || !isHex(prop.ParentID, 16) {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s-%s", strings.ToLower(traceID), strings.ToLower(prop.ParentID), b3Sampled(prop))
+}
+
+// UnmarshalB3SingleTraceContext parses a b3 header and creates a PropagationContext instance.
+// The span ID of the header is the parent ID of the context, and a sampling state of accept or
+// debug sets the sampled bit of TraceFlags. 64 bit trace IDs are kept as they are, so that spans
+// join the same trace as the upstream Zipkin spans.
+//
+// If the header doesn't hold a trace ID and span ID, including when the header only holds
+// a sampling state, an error will be returned.
+func UnmarshalB3SingleTraceContext(header string) (*PropagationContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return nil, &PropagationError{fmt.Sprintf("unable to parse b3 header: %s", header), nil}
+	}
+	prop := &PropagationContext{TraceID: parts[0], ParentID: parts[1]}
+	if len(parts) > 2 {
+		flags, ok := parseB3Sampled(parts[2])
+		if !ok {
+			return nil, &PropagationError{fmt.Sprintf("unrecognized b3 sampling state %s", parts[2]), nil}
+		}
+		prop.TraceFlags = flags
+	}
+	if len(parts) == 4 && !isHex(parts[3], 16) {
+		return nil, &PropagationError{fmt.Sprintf("invalid b3 parent span id %s", parts[3]), nil}
+	}
+	if err := checkB3IDs(prop); err != nil {
+		return nil, err
+	}
+	return prop, nil
+}
+
+// MarshalB3TraceContext uses the information in prop to create trace context headers in the
+// B3 multiple header format, returned as a map of header names to values. prop's ParentID is
+// sent as the span ID, and trace IDs that are UUIDs are sent without their dashes.
+//
+// If prop is nil, or its IDs can't be expressed in B3, the return value will be an empty map.
+func MarshalB3TraceContext(prop *PropagationContext) map[string]string {
+	headers := make(map[string]string)
+	if prop == nil {
+		return headers
+	}
+	traceID := HexTraceID(prop.TraceID)
+	if !isB3TraceID(traceID) || !isHex(prop.ParentID, 16) {
+		return headers
+	}
+	headers[B3TraceIDHTTPHeader] = strings.ToLower(traceID)
+	headers[B3SpanIDHTTPHeader] = strings.ToLower(prop.ParentID)
+	headers[B3SampledHTTPHeader] = b3Sampled(prop)
+	return headers
+}
+
+// UnmarshalB3TraceContext parses the B3 multiple header format and creates a PropagationContext
+// instance. headers is keyed by the header names defined in this package. Like
+// UnmarshalB3SingleTraceContext, the span ID becomes the parent ID, and an accepted or debug
+// sampling decision sets the sampled bit of TraceFlags.
+//
+// If the headers don't hold a valid trace ID and span ID, an error will be returned.
+func UnmarshalB3TraceContext(headers map[string]string) (*PropagationContext, error) {
+	prop := &PropagationContext{
+		TraceID:  strings.TrimSpace(headers[B3TraceIDHTTPHeader]),
+		ParentID: strings.TrimSpace(headers[B3SpanIDHTTPHeader]),
+	}
+	sampled := headers[B3SampledHTTPHeader]
+	if sampled == "true" {
+		// older clients send booleans
+		sampled = "1"
+	}
+	flags, ok := parseB3Sampled(sampled)
+	if !ok {
+		return nil, &PropagationError{fmt.Sprintf("unrecognized b3 sampling state %s", sampled), nil}
+	}
+	prop.TraceFlags = flags
+	if headers[B3FlagsHTTPHeader] == "1" {
+		prop.TraceFlags = b3SampledFlag
+	}
+	if err := checkB3IDs(prop); err != nil {
+		return nil, err
+	}
+	return prop, nil
+}
+
+func checkB3IDs(prop *PropagationContext) error {
+	if !isB3TraceID(prop.TraceID) {
+		return &PropagationError{fmt.Sprintf("invalid b3 trace id %s", prop.TraceID), nil}
+	}
+	if !isHex(prop.ParentID, 16) {
+		return &PropagationError{fmt.Sprintf("invalid b3 span id %s", prop.ParentID), nil}
+	}
+	if strings.Trim(prop.TraceID, "0") == "" || strings.Trim(prop.ParentID, "0") == "" {
+		return &PropagationError{"b3 trace and span ids must not be zero", nil}
+	}
+	return nil
+}
+
+// isB3TraceID reports whether id is a 64 or 128 bit hex encoded trace ID.
+func isB3TraceID(id string) bool {
+	return isHex(id, 16) || isHex(id, 32)
+}
+
+// parseB3Sampled turns a B3 sampling state into trace flags. Unknown and
+// empty states defer the decision, which is recorded as not sampled.
+func parseB3Sampled(state string) (byte, bool) {
+	switch state {
+	case "", "0":
+		return 0, true
+	case "1", "d":
+		return b3SampledFlag, true
+	}
+	return 0, false
+}
+
+func b3Sampled(prop *PropagationContext) string {
+	if prop.TraceFlags&b3SampledFlag != 0 {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,85 @@
+//go:build go1.18
+// +build go1.18
+
+package propagation
+
+import (
+	"context"
+	"testing"
+)
+
+// The fuzz targets below check that the header parsers never panic on
+// arbitrary input, and that any context they accept is one we would be willing
+// to start a trace from. Seed inputs live in testdata/fuzz alongside the ones
+// added here.
+
+func FuzzUnmarshalHoneycombTraceContext(f *testing.F) {
+	f.Add("1;trace_id=abcdef,parent_id=12345")
+	f.Add("1;trace_id=abcdef,parent_id=12345,dataset=imadataset,context=eyJ1c2VySUQiOjF9")
+	f.Add("1")
+	f.Add("1;trace_id")
+	f.Add("1;,,,=,")
+	f.Fuzz(func(t *testing.T, header string) {
+		prop, err := UnmarshalHoneycombTraceContext(header)
+		if err != nil {
+			if prop != nil {
+				t.Errorf("got a propagation context along with error %s", err)
+			}
+			return
+		}
+		if !prop.IsValid() {
+			t.Errorf("accepted invalid propagation context from %q", header)
+		}
+		// anything we can read, we should be able to write back out and read again
+		again, err := UnmarshalHoneycombTraceContext(MarshalHoneycombTraceContext(prop))
+		if err != nil {
+			t.Fatalf("failed to roundtrip %q: %s", header, err)
+		}
+		if again.TraceID != prop.TraceID || again.ParentID != prop.ParentID {
+			t.Errorf("roundtrip of %q changed IDs: %+v != %+v", header, again, prop)
+		}
+	})
+}
+
+func FuzzUnmarshalAmazonTraceContext(f *testing.F) {
+	f.Add("Root=1-67891233-abcdef012345678912345678;Self=1-67891233-abcdef0876543219876543210")
+	f.Add("Root=foo;Parent=bar;Self=baz")
+	f.Add(";;;=;")
+	f.Add("Self=")
+	f.Fuzz(func(t *testing.T, header string) {
+		prop, err := UnmarshalAmazonTraceContext(header)
+		if err != nil {
+			if prop != nil {
+				t.Errorf("got a propagation context along with error %s", err)
+			}
+			return
+		}
+		if !prop.IsValid() {
+			t.Errorf("accepted invalid propagation context from %q", header)
+		}
+		MarshalAmazonTraceContext(prop)
+	})
+}
+
+func FuzzUnmarshalW3CTraceContext(f *testing.F) {
+	f.Add("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00", "foo=bar,bar=baz")
+	f.Add("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331", "")
+	f.Add("ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "=,=")
+	f.Fuzz(func(t *testing.T, traceparent, tracestate string) {
+		headers := map[string]string{
+			"traceparent": traceparent,
+			"tracestate":  tracestate,
+		}
+		ctx, prop, err := UnmarshalW3CTraceContext(context.Background(), headers)
+		if err != nil {
+			if prop != nil {
+				t.Errorf("got a propagation context along with error %s", err)
+			}
+			return
+		}
+		if !prop.IsValid() {
+			t.Errorf("accepted invalid propagation context from %q", traceparent)
+		}
+		MarshalW3CTraceContext(ctx, prop)
+	})
+}
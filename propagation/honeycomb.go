@@ -69,6 +69,9 @@ func MarshalHoneycombTraceContext(prop *PropagationContext) string {
 func UnmarshalHoneycombTraceContext(header string) (*PropagationContext, error) {
 	// pull the version out of the header
 	getVer := strings.SplitN(header, ";", 2)
+	if len(getVer) < 2 {
+		return nil, &PropagationError{fmt.Sprintf("unable to find version separator in trace header %s", header), nil}
+	}
 	if getVer[0] == "1" {
 		return unmarshalHoneycombTraceContextV1(getVer[1])
 	}
@@ -85,6 +88,10 @@ func unmarshalHoneycombTraceContextV1(header string) (*PropagationContext, error
 	var tcB64 string
 	for _, clause := range clauses {
 		keyval := strings.SplitN(clause, "=", 2)
+		if len(keyval) < 2 {
+			// clauses without a value can't carry anything we understand
+			continue
+		}
 		switch keyval[0] {
 		case "trace_id":
 			prop.TraceID = keyval[1]
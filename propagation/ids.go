@@ -0,0 +1,48 @@
+package propagation
+
+import "strings"
+
+// HexTraceID returns id, a trace ID, with its dashes taken out if it is a
+// UUID, so that it can be sent in W3C and B3 trace headers. Other IDs are
+// returned as they are.
+func HexTraceID(id string) string {
+	if !isUUID(id) {
+		return id
+	}
+	return strings.ToLower(strings.Replace(id, "-", "", -1))
+}
+
+// UUIDTraceID returns id, a trace ID, as a lower case UUID with dashes if it
+// is a 128 bit hex ID, undoing HexTraceID. Other IDs are returned as they
+// are.
+func UUIDTraceID(id string) string {
+	if !isHex(id, 32) {
+		return id
+	}
+	id = strings.ToLower(id)
+	return id[:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:]
+}
+
+// w3cTraceID returns id as the 32 character hex trace ID W3C trace headers
+// need, padding 64 bit IDs with zeros as OpenTelemetry does for B3 IDs.
+func w3cTraceID(id string) string {
+	id = HexTraceID(id)
+	if isHex(id, 16) {
+		return "0000000000000000" + id
+	}
+	return id
+}
+
+// isUUID reports whether id is a UUID in its usual form, 32 hex characters
+// split into groups of 8, 4, 4, 4 and 12 by dashes.
+func isUUID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, group := range strings.Split(id, "-") {
+		if i > 4 || !isHex(group, []int{8, 4, 4, 4, 12}[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -16,6 +16,9 @@ type PropagationContext struct {
 	Dataset      string
 	TraceContext map[string]interface{}
 	TraceFlags   byte
+	// TraceState is the W3C tracestate header, which vendors use to pass
+	// their own data along with a trace. It is kept as it was received.
+	TraceState string
 }
 
 // hasTraceID checks that the trace ID is valid.
@@ -51,7 +54,12 @@ func (p *PropagationError) Error() string {
 	if p.wrappedError == nil {
 		return p.message
 	}
-	return fmt.Sprintf(p.message, p.wrappedError)
+	return fmt.Sprintf("%s: %s", p.message, p.wrappedError)
+}
+
+// Unwrap returns the underlying error, if any, that caused parsing to fail.
+func (p *PropagationError) Unwrap() error {
+	return p.wrappedError
 }
 
 // MarshalTraceContext wraps MarshalHoneycombTraceContext for backwards compatibility.
@@ -3,6 +3,7 @@ package propagation
 import (
 	"context"
 	"encoding/hex"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -124,6 +125,30 @@ func TestMarshalAmazonTraceContext(t *testing.T) {
 	if assert.NoError(t, err) {
 		assert.Equal(t, prop, returned, "roundtrip object")
 	}
+
+	// a structured Root should be rebuilt from its ID and epoch, even after
+	// the epoch has been through a JSON roundtrip in a Honeycomb header
+	prop = &PropagationContext{
+		TraceID:  "abcdef012345678912345678",
+		ParentID: "0102030405",
+		TraceContext: map[string]interface{}{
+			AmazonRootTimestampField: float64(0x67891233),
+		},
+	}
+	header = MarshalAmazonTraceContext(prop)
+	assert.Equal(t, "Root=1-67891233-abcdef012345678912345678;Self=0102030405", header)
+
+	// if the trace ID can't be part of a structured Root, the epoch has to be
+	// passed along as an ordinary field instead of being dropped
+	prop = &PropagationContext{
+		TraceID:  "not-an-aws-trace-id",
+		ParentID: "0102030405",
+		TraceContext: map[string]interface{}{
+			AmazonRootTimestampField: int64(0x67891233),
+		},
+	}
+	header = MarshalAmazonTraceContext(prop)
+	assert.Equal(t, "Root=not-an-aws-trace-id;Self=0102030405;aws.root_timestamp=1737036339", header)
 }
 
 func TestW3CTraceContext(t *testing.T) {
@@ -151,6 +176,10 @@ func TestW3CTraceContext(t *testing.T) {
 	assert.NoError(t, err, "unmarshal w3c headers")
 	ctx, marshaled := MarshalW3CTraceContext(ctx, prop)
 	assert.Equal(t, "foo=bar,bar=baz", marshaled["tracestate"])
+	assert.Equal(t, "foo=bar,bar=baz", prop.TraceState)
+	// the propagation context carries tracestate without the context too
+	_, marshaled = MarshalW3CTraceContext(context.Background(), prop)
+	assert.Equal(t, "foo=bar,bar=baz", marshaled["tracestate"])
 
 	// ensure that empty headers are handled the way we expect (silently)
 	headers = map[string]string{}
@@ -158,6 +187,27 @@ func TestW3CTraceContext(t *testing.T) {
 	assert.Error(t, err, "Cannot unmarshal empty header")
 }
 
+func TestTraceIDConversion(t *testing.T) {
+	uuid := "0af76519-16cd-43dd-8448-eb211c80319c"
+	hexID := "0af7651916cd43dd8448eb211c80319c"
+	assert.Equal(t, hexID, HexTraceID(uuid))
+	assert.Equal(t, hexID, HexTraceID(strings.ToUpper(uuid)))
+	assert.Equal(t, uuid, UUIDTraceID(hexID))
+	assert.Equal(t, "abc-def", HexTraceID("abc-def"), "IDs that aren't UUIDs are kept")
+	assert.Equal(t, "64fe8b2a57d3eff7", UUIDTraceID("64fe8b2a57d3eff7"), "64 bit IDs are kept")
+
+	prop := &PropagationContext{TraceID: uuid, ParentID: "b7ad6b7169203331", TraceFlags: 1}
+	_, headers := MarshalW3CTraceContext(context.Background(), prop)
+	assert.Equal(t, "00-"+hexID+"-b7ad6b7169203331-01", headers["traceparent"], "UUIDs are sent without dashes")
+	assert.Equal(t, hexID+"-b7ad6b7169203331-1", MarshalB3SingleTraceContext(prop))
+	assert.Equal(t, hexID, MarshalB3TraceContext(prop)[B3TraceIDHTTPHeader])
+
+	prop.TraceID = "64fe8b2a57d3eff7"
+	_, headers = MarshalW3CTraceContext(context.Background(), prop)
+	assert.Equal(t, "00-000000000000000064fe8b2a57d3eff7-b7ad6b7169203331-01", headers["traceparent"], "64 bit IDs are padded")
+	assert.Equal(t, "64fe8b2a57d3eff7-b7ad6b7169203331-1", MarshalB3SingleTraceContext(prop))
+}
+
 func TestUnmarshalTraceContext(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -271,9 +321,11 @@ func TestUnmarshalAmazonTraceContext(t *testing.T) {
 			"all fields legit",
 			"Root=1-67891233-abcdef012345678912345678;Self=1-67891233-abcdef0876543219876543210",
 			&PropagationContext{
-				TraceID:      "1-67891233-abcdef012345678912345678",
-				ParentID:     "1-67891233-abcdef0876543219876543210",
-				TraceContext: make(map[string]interface{}),
+				TraceID:  "abcdef012345678912345678",
+				ParentID: "1-67891233-abcdef0876543219876543210",
+				TraceContext: map[string]interface{}{
+					AmazonRootTimestampField: int64(0x67891233),
+				},
 			},
 			false,
 		},
@@ -281,12 +333,13 @@ func TestUnmarshalAmazonTraceContext(t *testing.T) {
 			"all fields legit with some context",
 			"Root=1-67891233-abcdef012345678912345678;Self=1-67891233-abcdef0876543219876543210;Foo=bar;UserId=123;toRetry=true",
 			&PropagationContext{
-				TraceID:  "1-67891233-abcdef012345678912345678",
+				TraceID:  "abcdef012345678912345678",
 				ParentID: "1-67891233-abcdef0876543219876543210",
 				TraceContext: map[string]interface{}{
-					"Foo":     "bar",
-					"UserId":  "123",
-					"toRetry": "true",
+					"Foo":                    "bar",
+					"UserId":                 "123",
+					"toRetry":                "true",
+					AmazonRootTimestampField: int64(0x67891233),
 				},
 			},
 			false,
@@ -327,6 +380,28 @@ func TestUnmarshalAmazonTraceContext(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"structured root without self is used as both trace id and parent id",
+			"Root=1-67891233-abcdef012345678912345678",
+			&PropagationContext{
+				TraceID:  "abcdef012345678912345678",
+				ParentID: "abcdef012345678912345678",
+				TraceContext: map[string]interface{}{
+					AmazonRootTimestampField: int64(0x67891233),
+				},
+			},
+			false,
+		},
+		{
+			"root with a malformed epoch is used verbatim",
+			"Root=1-6789123g-abcdef012345678912345678;Self=baz",
+			&PropagationContext{
+				TraceID:      "1-6789123g-abcdef012345678912345678",
+				ParentID:     "baz",
+				TraceContext: map[string]interface{}{},
+			},
+			false,
+		},
 		{
 			"Missing trace id and parent id is populated, error",
 			"Foo=bar;Self=foobar;Bar=baz",
@@ -345,3 +420,67 @@ func TestUnmarshalAmazonTraceContext(t *testing.T) {
 		}
 	}
 }
+
+func TestB3TraceContext(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		prop   *PropagationContext
+	}{
+		{
+			"128 bit trace id, sampled, with a parent",
+			"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90",
+			&PropagationContext{TraceID: "80f198ee56343ba864fe8b2a57d3eff7", ParentID: "e457b5a2e4d86bd1", TraceFlags: 1},
+		},
+		{
+			"64 bit trace ids are kept as they are",
+			"64fe8b2a57d3eff7-e457b5a2e4d86bd1-0",
+			&PropagationContext{TraceID: "64fe8b2a57d3eff7", ParentID: "e457b5a2e4d86bd1"},
+		},
+		{
+			"debug counts as sampled, and the sampling state is optional",
+			"64fe8b2a57d3eff7-e457b5a2e4d86bd1-d",
+			&PropagationContext{TraceID: "64fe8b2a57d3eff7", ParentID: "e457b5a2e4d86bd1", TraceFlags: 1},
+		},
+		{"a sampling decision alone can't continue a trace", "0", nil},
+		{"bad sampling state", "64fe8b2a57d3eff7-e457b5a2e4d86bd1-x", nil},
+		{"bad span id", "64fe8b2a57d3eff7-e457b5a2", nil},
+		{"zero trace id", "0000000000000000-e457b5a2e4d86bd1", nil},
+	}
+	for _, tc := range testCases {
+		prop, err := UnmarshalB3SingleTraceContext(tc.header)
+		if tc.prop == nil {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.prop, prop, tc.name)
+	}
+
+	prop := &PropagationContext{TraceID: "80F198EE56343BA864FE8B2A57D3EFF7", ParentID: "e457b5a2e4d86bd1", TraceFlags: 1}
+	assert.Equal(t, "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1", MarshalB3SingleTraceContext(prop))
+	headers := MarshalB3TraceContext(prop)
+	assert.Equal(t, map[string]string{
+		B3TraceIDHTTPHeader: "80f198ee56343ba864fe8b2a57d3eff7",
+		B3SpanIDHTTPHeader:  "e457b5a2e4d86bd1",
+		B3SampledHTTPHeader: "1",
+	}, headers)
+	roundtrip, err := UnmarshalB3TraceContext(headers)
+	assert.NoError(t, err)
+	assert.Equal(t, "80f198ee56343ba864fe8b2a57d3eff7", roundtrip.TraceID)
+	assert.Equal(t, byte(1), roundtrip.TraceFlags)
+
+	// booleans and the debug flag are understood too
+	roundtrip, err = UnmarshalB3TraceContext(map[string]string{
+		B3TraceIDHTTPHeader: "64fe8b2a57d3eff7",
+		B3SpanIDHTTPHeader:  "e457b5a2e4d86bd1",
+		B3FlagsHTTPHeader:   "1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, byte(1), roundtrip.TraceFlags)
+	_, err = UnmarshalB3TraceContext(map[string]string{B3TraceIDHTTPHeader: "64fe8b2a57d3eff7", B3SampledHTTPHeader: "true"})
+	assert.Error(t, err, "a span id is needed")
+
+	assert.Equal(t, "", MarshalB3SingleTraceContext(&PropagationContext{TraceID: "abc", ParentID: "def"}))
+	assert.Equal(t, 0, len(MarshalB3TraceContext(nil)))
+}
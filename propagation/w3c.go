@@ -10,6 +10,13 @@ import (
 	"google.golang.org/grpc/codes"
 )
 
+const (
+	// W3CTraceParentHTTPHeader and W3CTraceStateHTTPHeader are the headers
+	// defined by the W3C Trace Context specification.
+	W3CTraceParentHTTPHeader = "traceparent"
+	W3CTraceStateHTTPHeader  = "tracestate"
+)
+
 // MarshalHoneycombTraceContext uses the information in prop to create trace context headers
 // that conform to the W3C Trace Context specification. The header values are set in headers,
 // which is an HTTPSupplier, an interface to which http.Header is an implementation. The headers
@@ -19,6 +26,9 @@ import (
 // tracestate header. This is required in order to use the Propagator interface exported by the
 // OpenTelemetry Go SDK and avoid writing our own W3C Trace Context parser and serializer.
 //
+// Trace IDs that are UUIDs are sent without their dashes, and 64 bit trace IDs are padded
+// with zeros to the 128 bits the specification requires.
+//
 // If prop is empty or nil, the return value will be an empty map.
 func MarshalW3CTraceContext(ctx context.Context, prop *PropagationContext) (context.Context, map[string]string) {
 	headerMap := make(map[string]string)
@@ -35,6 +45,9 @@ func MarshalW3CTraceContext(ctx context.Context, prop *PropagationContext) (cont
 	for _, key := range propagator.GetAllKeys() {
 		headerMap[key] = supp.Get(key)
 	}
+	if prop != nil && prop.TraceState != "" && headerMap[W3CTraceStateHTTPHeader] == "" {
+		headerMap[W3CTraceStateHTTPHeader] = prop.TraceState
+	}
 	return ctx, headerMap
 }
 
@@ -58,6 +71,7 @@ func UnmarshalW3CTraceContext(ctx context.Context, headers map[string]string) (c
 		TraceID:    spanContext.TraceID.String(),
 		ParentID:   spanContext.SpanID.String(),
 		TraceFlags: spanContext.TraceFlags,
+		TraceState: headers[W3CTraceStateHTTPHeader],
 	}
 	if !prop.IsValid() {
 		return ctx, nil, &PropagationError{
@@ -75,7 +89,7 @@ func createOpenTelemetrySpan(prop *PropagationContext) (trace.Span, error) {
 		return otelSpan{}, nil
 	}
 
-	traceID, err := trace.IDFromHex(prop.TraceID)
+	traceID, err := trace.IDFromHex(w3cTraceID(prop.TraceID))
 	if err != nil {
 		return nil, err
 	}
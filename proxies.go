@@ -0,0 +1,38 @@
+package beeline
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// parseTrustedProxies parses Config.TrustedProxies, skipping entries that
+// aren't IP addresses or CIDR ranges and returning an error naming the first
+// of them. Single addresses become ranges holding only themselves.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	if len(proxies) == 0 {
+		return nil, nil
+	}
+	var err error
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if _, n, cidrErr := net.ParseCIDR(p); cidrErr == nil {
+			nets = append(nets, n)
+			continue
+		}
+		ip := net.ParseIP(p)
+		if ip == nil {
+			if err == nil {
+				err = fmt.Errorf("beeline: trusted proxy %q is neither an IP address nor a CIDR range", p)
+			}
+			continue
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, err
+}
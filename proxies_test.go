@@ -0,0 +1,23 @@
+package beeline
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := parseTrustedProxies([]string{"10.0.0.0/8", " 192.0.2.1", "2001:db8::1", "nope"})
+	assert.Error(t, err)
+	if assert.Equal(t, 3, len(nets)) {
+		assert.True(t, nets[0].Contains(net.ParseIP("10.20.30.40")))
+		assert.True(t, nets[1].Contains(net.ParseIP("192.0.2.1")))
+		assert.False(t, nets[1].Contains(net.ParseIP("192.0.2.2")), "an address should only trust itself")
+		assert.True(t, nets[2].Contains(net.ParseIP("2001:db8::1")))
+	}
+
+	nets, err = parseTrustedProxies(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, nets)
+}
@@ -0,0 +1,111 @@
+package beeline
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitedField is added to the first event sent for a route after
+	// some were dropped by the rate limit, with the number dropped.
+	rateLimitedField = "meta.rate_limited_count"
+	// rateLimitOtherKey is the route events are limited under once
+	// maxRateLimitKeys routes are being tracked.
+	rateLimitOtherKey = "other"
+	// maxRateLimitKeys bounds the number of routes tracked, so that routes
+	// taken from raw request paths can't grow the limiter without bound.
+	maxRateLimitKeys = 1000
+)
+
+// defaultRateLimitFields are the fields the rate limit takes each event's
+// route from when Config.RateLimitFields is not set.
+var defaultRateLimitFields = []string{"handler.route", "request.path"}
+
+// tokenBucket allows rate events a second on average, and bursts of up to
+// burst events.
+type tokenBucket struct {
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+// rateLimiter limits the events sent for each route with a token bucket per
+// route.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	fields []string
+	now    func() time.Time
+
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(rate float64, fields []string) *rateLimiter {
+	if len(fields) == 0 {
+		fields = defaultRateLimitFields
+	}
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   burst,
+		fields:  fields,
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow is the trace.RateLimitHook. It returns false if the event's route has
+// used up its allowance, and otherwise records on the event how many of the
+// route's events were dropped since the last one was sent. Events with none
+// of the route fields aren't limited.
+func (l *rateLimiter) allow(fields map[string]interface{}) bool {
+	key, ok := l.key(fields)
+	if !ok {
+		return true
+	}
+	now := l.now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitKeys {
+			key = rateLimitOtherKey
+			b = l.buckets[key]
+		}
+		if b == nil {
+			b = &tokenBucket{tokens: l.burst, last: now}
+			l.buckets[key] = b
+		}
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		b.dropped++
+		return false
+	}
+	b.tokens--
+	if b.dropped > 0 {
+		fields[rateLimitedField] = b.dropped
+		b.dropped = 0
+	}
+	return true
+}
+
+// key returns the route of the event, from the first of the route fields it
+// has.
+func (l *rateLimiter) key(fields map[string]interface{}) (string, bool) {
+	for _, f := range l.fields {
+		if v, ok := fields[f]; ok && v != nil {
+			return fmt.Sprint(v), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,66 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	l := newRateLimiter(2, nil)
+	l.now = func() time.Time { return now }
+	send := func(path string) (bool, map[string]interface{}) {
+		fields := map[string]interface{}{"request.path": path}
+		return l.allow(fields), fields
+	}
+
+	for i := 0; i < 2; i++ {
+		ok, _ := send("/hot")
+		assert.True(t, ok, "burst of a second's worth allowed")
+	}
+	for i := 0; i < 3; i++ {
+		ok, _ := send("/hot")
+		assert.False(t, ok, "over the limit")
+	}
+	ok, _ := send("/cold")
+	assert.True(t, ok, "routes are limited separately")
+	assert.True(t, l.allow(map[string]interface{}{"name": "child"}), "events without a route aren't limited")
+
+	now = now.Add(500 * time.Millisecond)
+	ok, fields := send("/hot")
+	assert.True(t, ok, "tokens are refilled over time")
+	assert.Equal(t, 3, fields[rateLimitedField])
+	ok, fields = send("/hot")
+	assert.False(t, ok)
+	assert.NotContains(t, fields, rateLimitedField)
+
+	l = newRateLimiter(1, nil)
+	assert.True(t, l.allow(map[string]interface{}{"handler.route": "/users/{id}", "request.path": "/users/1"}))
+	assert.False(t, l.allow(map[string]interface{}{"handler.route": "/users/{id}", "request.path": "/users/2"}),
+		"the route is preferred to the path")
+}
+
+func TestMaxEventsPerSecondPerRoute(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client, MaxEventsPerSecondPerRoute: 1})
+	defer Init(Config{Client: client})
+
+	for i := 0; i < 5; i++ {
+		_, span := StartSpan(context.Background(), "request")
+		span.AddField("request.path", "/hot")
+		span.Send()
+	}
+	assert.Equal(t, 1, len(mo.Events()))
+}
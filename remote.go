@@ -0,0 +1,311 @@
+package beeline
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/honeycombio/beeline-go/sample"
+)
+
+const (
+	// RemoteConfigSignatureHeader carries the base64 encoded ed25519
+	// signature of the remote config response body.
+	RemoteConfigSignatureHeader = "X-Beeline-Signature"
+
+	defaultRemoteConfigInterval = time.Minute
+	// remoteConfigTimeout bounds each fetch of the remote config.
+	remoteConfigTimeout = 10 * time.Second
+	// maxRemoteConfigBytes bounds how much of a response is read.
+	maxRemoteConfigBytes = 1 << 20
+)
+
+// RemoteConfig is the document served at Config.RemoteConfigURL. Each fetch
+// replaces the whole of the previous one; fields left out go back to the
+// local configuration.
+type RemoteConfig struct {
+	// SampleRate, if set, samples traces at this rate in place of
+	// Config.SampleRate and Config.SamplerHook.
+	SampleRate uint `json:"sample_rate,omitempty"`
+	// SampleRules sample spans with a field of a given value at their own
+	// rate. The first matching rule wins; spans matching none use SampleRate.
+	SampleRules []RemoteSampleRule `json:"sample_rules,omitempty"`
+	// ScrubFields lists fields whose values are replaced by a hash of them
+	// before they are sent.
+	ScrubFields []string `json:"scrub_fields,omitempty"`
+	// AllowedFields, if set, lists the only fields that are sent. A name
+	// ending in `*` allows every field starting with the rest of it, eg
+	// "app.*". Fields the trace structure depends on (`name`,
+	// `service_name`, `duration_ms` and those starting `meta.` or `trace.`)
+	// are always sent.
+	AllowedFields []string `json:"allowed_fields,omitempty"`
+}
+
+// RemoteSampleRule samples spans whose Field, formatted as a string, equals
+// Value at SampleRate.
+type RemoteSampleRule struct {
+	Field      string `json:"field"`
+	Value      string `json:"value"`
+	SampleRate uint   `json:"sample_rate"`
+}
+
+// alwaysAllowedFields are sent even when missing from AllowedFields.
+var alwaysAllowedFields = map[string]bool{
+	"name":         true,
+	"service_name": true,
+	"duration_ms":  true,
+}
+
+// remoteRules is a RemoteConfig prepared for use on every span.
+type remoteRules struct {
+	sampler         *sample.DeterministicSampler
+	samplerRules    []remoteSamplerRule
+	scrub           []string
+	allowed         map[string]bool
+	allowedPrefixes []string
+}
+
+type remoteSamplerRule struct {
+	field   string
+	value   string
+	sampler *sample.DeterministicSampler
+}
+
+func compileRemoteConfig(rc RemoteConfig) (*remoteRules, error) {
+	rules := &remoteRules{scrub: rc.ScrubFields}
+	if rc.SampleRate > 0 {
+		rules.sampler, _ = sample.NewDeterministicSampler(rc.SampleRate)
+	}
+	for _, r := range rc.SampleRules {
+		if r.Field == "" {
+			return nil, errors.New("beeline: remote sample rule has no field")
+		}
+		sampler, err := sample.NewDeterministicSampler(r.SampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("beeline: remote sample rule for %s: %s", r.Field, err)
+		}
+		rules.samplerRules = append(rules.samplerRules, remoteSamplerRule{r.Field, r.Value, sampler})
+	}
+	if len(rc.AllowedFields) > 0 {
+		rules.allowed = make(map[string]bool)
+		for _, f := range rc.AllowedFields {
+			if strings.HasSuffix(f, "*") {
+				rules.allowedPrefixes = append(rules.allowedPrefixes, strings.TrimSuffix(f, "*"))
+			} else {
+				rules.allowed[f] = true
+			}
+		}
+	}
+	return rules, nil
+}
+
+// remoteConfig fetches the remote config and applies it to every span through
+// the sampler and presend hooks it provides.
+type remoteConfig struct {
+	url       string
+	publicKey ed25519.PublicKey
+	interval  time.Duration
+	debug     bool
+	// fallback samples spans when the remote config doesn't.
+	fallback func(map[string]interface{}) (bool, int)
+	// sampler samples spans when neither the remote config nor fallback
+	// does.
+	sampler *sample.DeterministicSampler
+	// presend is the user's PresendHook, run before remote filtering.
+	presend func(map[string]interface{})
+	// transport, if set, is what the config is fetched with.
+	transport http.RoundTripper
+
+	// fetchLock serializes fetches so an older response can't replace a
+	// newer one.
+	fetchLock sync.Mutex
+	// rules holds the current *remoteRules, swapped whole on every fetch.
+	rules atomic.Value
+}
+
+// remoteHTTPClient is used to fetch the remote config. It is replaced in
+// tests.
+var remoteHTTPClient = http.DefaultClient
+
+func newRemoteConfig(config Config, transport http.RoundTripper, sampler *sample.DeterministicSampler) *remoteConfig {
+	interval := config.RemoteConfigInterval
+	if interval <= 0 {
+		interval = defaultRemoteConfigInterval
+	}
+	r := &remoteConfig{
+		url:       config.RemoteConfigURL,
+		publicKey: ed25519.PublicKey(config.RemoteConfigPublicKey),
+		interval:  interval,
+		debug:     config.Debug,
+		fallback:  config.SamplerHook,
+		sampler:   sampler,
+		presend:   config.PresendHook,
+		transport: transport,
+	}
+	r.rules.Store(&remoteRules{})
+	return r
+}
+
+func (r *remoteConfig) current() *remoteRules {
+	return r.rules.Load().(*remoteRules)
+}
+
+// fetch gets, verifies and applies the remote config. The current config is
+// kept if anything goes wrong.
+func (r *remoteConfig) fetch(ctx context.Context) error {
+	if len(r.publicKey) != ed25519.PublicKeySize {
+		return errors.New("beeline: a valid ed25519 public key is needed to verify the remote config")
+	}
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "beeline-go/"+version)
+
+	r.fetchLock.Lock()
+	defer r.fetchLock.Unlock()
+	resp, err := httpClientFor(r.transport, remoteHTTPClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("beeline: fetching remote config failed with status %d", resp.StatusCode)
+	}
+	// a truncated body fails verification below
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigBytes))
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(resp.Header.Get(RemoteConfigSignatureHeader))
+	if err != nil || !ed25519.Verify(r.publicKey, body, sig) {
+		return errors.New("beeline: remote config signature is missing or invalid")
+	}
+	var rc RemoteConfig
+	if err := json.Unmarshal(body, &rc); err != nil {
+		return fmt.Errorf("beeline: decoding remote config: %s", err)
+	}
+	rules, err := compileRemoteConfig(rc)
+	if err != nil {
+		return err
+	}
+	r.rules.Store(rules)
+	return nil
+}
+
+// run fetches the remote config straight away and then every interval until
+// done is closed.
+func (r *remoteConfig) run(done <-chan struct{}) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), remoteConfigTimeout)
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		err := r.fetch(ctx)
+		cancel()
+		if err != nil && r.debug {
+			fmt.Fprintf(os.Stderr, "beeline: failed to refresh remote config: %s\n", err)
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample is the SamplerHook used while the remote config is enabled.
+func (r *remoteConfig) sample(fields map[string]interface{}) (bool, int) {
+	rules := r.current()
+	traceID, _ := fields["trace.trace_id"].(string)
+	for _, rule := range rules.samplerRules {
+		if v, ok := fields[rule.field]; ok && fmt.Sprint(v) == rule.value {
+			return rule.sampler.Sample(traceID), rule.sampler.GetSampleRate()
+		}
+	}
+	switch {
+	case rules.sampler != nil:
+		return rules.sampler.Sample(traceID), rules.sampler.GetSampleRate()
+	case r.fallback != nil:
+		return r.fallback(fields)
+	case r.sampler != nil:
+		return r.sampler.Sample(traceID), r.sampler.GetSampleRate()
+	}
+	return true, 1
+}
+
+// filter is the PresendHook used while the remote config is enabled. It runs
+// the user's PresendHook, then scrubs and drops fields as the remote config
+// asks, so the user's hook can't put back what central config removes.
+func (r *remoteConfig) filter(fields map[string]interface{}) {
+	if r.presend != nil {
+		r.presend(fields)
+	}
+	rules := r.current()
+	for _, key := range rules.scrub {
+		if v, ok := fields[key]; ok && v != nil {
+			sum := sha256.Sum256([]byte(fmt.Sprint(v)))
+			fields[key] = hex.EncodeToString(sum[:8])
+		}
+	}
+	if rules.allowed == nil {
+		return
+	}
+	for key := range fields {
+		if !rules.allows(key) {
+			delete(fields, key)
+		}
+	}
+}
+
+func (rules *remoteRules) allows(key string) bool {
+	if rules.allowed[key] || alwaysAllowedFields[key] ||
+		strings.HasPrefix(key, "meta.") || strings.HasPrefix(key, "trace.") {
+		return true
+	}
+	for _, prefix := range rules.allowedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshRemoteConfig fetches the remote config now rather than waiting for
+// the next refresh, eg to have it in place before serving traffic. It returns
+// an error if no RemoteConfigURL was given to Init or the config could not be
+// fetched and verified, in which case the current config stays in use.
+func RefreshRemoteConfig(ctx context.Context) error {
+	return std.RefreshRemoteConfig(ctx)
+}
+
+// RefreshRemoteConfig fetches b's remote config now, as RefreshRemoteConfig
+// does.
+func (b *Beeline) RefreshRemoteConfig(ctx context.Context) error {
+	r := b.remote
+	if r == nil {
+		return errors.New("beeline: no remote config URL was given")
+	}
+	return r.fetch(ctx)
+}
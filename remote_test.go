@@ -0,0 +1,144 @@
+package beeline
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+// remoteConfigServer serves whatever config it was last given, signed with
+// its key.
+type remoteConfigServer struct {
+	*httptest.Server
+	lock sync.Mutex
+	body []byte
+	sig  string
+	pub  ed25519.PublicKey
+	priv ed25519.PrivateKey
+}
+
+func newRemoteConfigServer(t *testing.T) *remoteConfigServer {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	s := &remoteConfigServer{pub: pub, priv: priv}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		w.Header().Set(RemoteConfigSignatureHeader, s.sig)
+		w.Write(s.body)
+	}))
+	return s
+}
+
+func (s *remoteConfigServer) serve(t *testing.T, rc RemoteConfig) {
+	body, err := json.Marshal(rc)
+	assert.NoError(t, err)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.body = body
+	s.sig = base64.StdEncoding.EncodeToString(ed25519.Sign(s.priv, body))
+}
+
+func TestRemoteConfig(t *testing.T) {
+	srv := newRemoteConfigServer(t)
+	defer srv.Close()
+	srv.serve(t, RemoteConfig{
+		SampleRules:   []RemoteSampleRule{{Field: "name", Value: "health", SampleRate: 1000}},
+		ScrubFields:   []string{"app.email"},
+		AllowedFields: []string{"app.*"},
+	})
+
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	var presendCalled bool
+	Init(Config{
+		Client:                client,
+		RemoteConfigURL:       srv.URL,
+		RemoteConfigPublicKey: srv.pub,
+		RemoteConfigInterval:  time.Hour,
+		PresendHook: func(fields map[string]interface{}) {
+			presendCalled = true
+			fields["secret"] = "added by the user"
+		},
+	})
+	defer setupLibhoney(t)
+	assert.NoError(t, RefreshRemoteConfig(context.Background()))
+
+	ctx, span := StartSpan(context.Background(), "request")
+	AddField(ctx, "email", "someone@example.com")
+	AddField(ctx, "plan", "free")
+	span.AddField("request.path", "/")
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.True(t, presendCalled, "the user's presend hook should still run")
+		assert.Equal(t, uint(1), evs[0].SampleRate, "unmatched spans use the local sample rate")
+		assert.NotEqual(t, "someone@example.com", evs[0].Data["app.email"])
+		assert.Len(t, evs[0].Data["app.email"], 16, "scrubbed fields should be hashed")
+		assert.Equal(t, "free", evs[0].Data["app.plan"])
+		assert.NotContains(t, evs[0].Data, "request.path", "fields not allowed should be dropped")
+		assert.NotContains(t, evs[0].Data, "secret")
+		assert.Contains(t, evs[0].Data, "trace.trace_id")
+		assert.Equal(t, "request", evs[0].Data["name"])
+	}
+
+	fields := map[string]interface{}{"name": "health", "trace.trace_id": "abc"}
+	_, rate := std.remote.sample(fields)
+	assert.Equal(t, 1000, rate, "matching spans should use the rule's rate")
+
+	// an unsigned config is ignored and the current one kept
+	srv.serve(t, RemoteConfig{SampleRate: 5})
+	srv.lock.Lock()
+	srv.sig = base64.StdEncoding.EncodeToString([]byte("nope"))
+	srv.lock.Unlock()
+	assert.Error(t, RefreshRemoteConfig(context.Background()))
+	assert.Equal(t, []string{"app.email"}, std.remote.current().scrub)
+
+	// a new config replaces all of the old one
+	srv.serve(t, RemoteConfig{SampleRate: 5})
+	assert.NoError(t, RefreshRemoteConfig(context.Background()))
+	_, rate = std.remote.sample(fields)
+	assert.Equal(t, 5, rate)
+	assert.Nil(t, std.remote.current().scrub)
+	filtered := map[string]interface{}{"request.path": "/"}
+	std.remote.filter(filtered)
+	assert.Contains(t, filtered, "request.path")
+}
+
+func TestRemoteConfigInvalid(t *testing.T) {
+	srv := newRemoteConfigServer(t)
+	defer srv.Close()
+	srv.serve(t, RemoteConfig{SampleRules: []RemoteSampleRule{{Field: "name", SampleRate: 0}}})
+	Init(Config{
+		Mute:                  true,
+		RemoteConfigURL:       srv.URL,
+		RemoteConfigPublicKey: srv.pub,
+		RemoteConfigInterval:  time.Hour,
+	})
+	defer setupLibhoney(t)
+	assert.Error(t, RefreshRemoteConfig(context.Background()), "rules need a valid sample rate")
+
+	Init(Config{Mute: true, RemoteConfigURL: srv.URL, RemoteConfigInterval: time.Hour})
+	assert.Error(t, RefreshRemoteConfig(context.Background()), "a public key is required")
+
+	setupLibhoney(t)
+	assert.Error(t, RefreshRemoteConfig(context.Background()), "no remote config was configured")
+}
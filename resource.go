@@ -0,0 +1,93 @@
+package beeline
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// podEnvVars lists, for each Kubernetes field, the environment variables a
+// pod's downward API is commonly set up to fill in, in the order they're
+// looked for.
+var podEnvVars = []struct {
+	field string
+	names []string
+}{
+	{"k8s.pod_name", []string{"POD_NAME", "K8S_POD_NAME", "MY_POD_NAME"}},
+	{"k8s.namespace", []string{"POD_NAMESPACE", "K8S_NAMESPACE", "MY_POD_NAMESPACE"}},
+	{"k8s.node_name", []string{"NODE_NAME", "K8S_NODE_NAME", "MY_NODE_NAME"}},
+	{"k8s.pod_uid", []string{"POD_UID", "K8S_POD_UID", "MY_POD_UID"}},
+}
+
+// serviceAccountNamespace holds the namespace of the pod in every container
+// with a service account mounted.
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// containerIDPattern matches the 64 hex digit IDs that docker, containerd and
+// cri-o give containers, as they appear in cgroup paths and mounts.
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// detectResource returns the fields describing where the process is running:
+// the ID of the container it's in, as container_id, and its pod, namespace
+// and node in Kubernetes, as k8s.pod_name, k8s.namespace, k8s.node_name and
+// k8s.pod_uid. Only what can be found is returned. getenv and readFile stand
+// in for os.Getenv and ioutil.ReadFile.
+func detectResource(getenv func(string) string, readFile func(string) ([]byte, error)) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, v := range podEnvVars {
+		for _, name := range v.names {
+			if val := getenv(name); val != "" {
+				fields[v.field] = val
+				break
+			}
+		}
+	}
+	if getenv("KUBERNETES_SERVICE_HOST") != "" {
+		// in a pod, but without the downward API; the hostname defaults to
+		// the pod's name, and the namespace is mounted with its token
+		if _, ok := fields["k8s.pod_name"]; !ok {
+			if hostname, err := os.Hostname(); err == nil {
+				fields["k8s.pod_name"] = hostname
+			}
+		}
+		if _, ok := fields["k8s.namespace"]; !ok {
+			if ns, err := readFile(serviceAccountNamespace); err == nil && len(ns) > 0 {
+				fields["k8s.namespace"] = strings.TrimSpace(string(ns))
+			}
+		}
+	}
+	if id := containerID(readFile); id != "" {
+		fields["container_id"] = id
+	}
+	return fields
+}
+
+// containerID returns the ID of the container the process is in, or "" if
+// it isn't in one or the ID can't be found. cgroup v1 puts the ID in the
+// process's cgroup paths; with cgroup v2 those are empty, but the container
+// runtime's files for the container are mounted into it.
+func containerID(readFile func(string) ([]byte, error)) string {
+	if cgroup, err := readFile("/proc/self/cgroup"); err == nil {
+		for _, line := range strings.Split(string(cgroup), "\n") {
+			if id := containerIDPattern.FindString(line[strings.LastIndex(line, "/")+1:]); id != "" {
+				return id
+			}
+		}
+	}
+	if mounts, err := readFile("/proc/self/mountinfo"); err == nil {
+		for _, line := range strings.Split(string(mounts), "\n") {
+			if i := strings.Index(line, "/containers/"); i >= 0 {
+				if id := containerIDPattern.FindString(line[i:]); id != "" {
+					return id
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// osResource detects the resource fields of the running process.
+func osResource() map[string]interface{} {
+	return detectResource(os.Getenv, ioutil.ReadFile)
+}
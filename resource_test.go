@@ -0,0 +1,91 @@
+package beeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+const testContainerID = "3f4b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b"
+
+func fakeEnv(env map[string]string) func(string) string {
+	return func(name string) string { return env[name] }
+}
+
+func fakeFiles(files map[string]string) func(string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		if f, ok := files[name]; ok {
+			return []byte(f), nil
+		}
+		return nil, os.ErrNotExist
+	}
+}
+
+func TestDetectResource(t *testing.T) {
+	fields := detectResource(fakeEnv(map[string]string{
+		"POD_NAME":                "web-5d8f7c-x2k9q",
+		"MY_POD_NAMESPACE":        "shop",
+		"NODE_NAME":               "node-3",
+		"KUBERNETES_SERVICE_HOST": "10.0.0.1",
+	}), fakeFiles(map[string]string{
+		"/proc/self/cgroup":     "12:memory:/kubepods/burstable/pod1234/" + testContainerID + "\n",
+		serviceAccountNamespace: "ignored\n",
+	}))
+	assert.Equal(t, map[string]interface{}{
+		"k8s.pod_name":  "web-5d8f7c-x2k9q",
+		"k8s.namespace": "shop",
+		"k8s.node_name": "node-3",
+		"container_id":  testContainerID,
+	}, fields)
+}
+
+func TestDetectResourceWithoutDownwardAPI(t *testing.T) {
+	hostname, _ := os.Hostname()
+	fields := detectResource(fakeEnv(map[string]string{
+		"KUBERNETES_SERVICE_HOST": "10.0.0.1",
+	}), fakeFiles(map[string]string{
+		"/proc/self/cgroup":     "0::/\n",
+		"/proc/self/mountinfo":  "1 2 0:1 /var/lib/docker/containers/" + testContainerID + "/hostname /etc/hostname rw - ext4 /dev/sda1 rw\n",
+		serviceAccountNamespace: "shop\n",
+	}))
+	assert.Equal(t, hostname, fields["k8s.pod_name"], "a pod's hostname is its name")
+	assert.Equal(t, "shop", fields["k8s.namespace"])
+	assert.Equal(t, testContainerID, fields["container_id"], "cgroup v2 containers are found from their mounts")
+}
+
+func TestDetectResourceOutsideContainers(t *testing.T) {
+	fields := detectResource(fakeEnv(nil), func(string) ([]byte, error) {
+		return nil, errors.New("no such file")
+	})
+	assert.Empty(t, fields)
+	fields = detectResource(fakeEnv(nil), fakeFiles(map[string]string{
+		"/proc/self/cgroup": "0::/user.slice/user-1000.slice/session-2.scope\n",
+	}))
+	assert.Empty(t, fields)
+}
+
+func TestServiceResourceFields(t *testing.T) {
+	mo := &transmission.MockSender{}
+	c, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: c, ServiceVersion: "1.2.3", Environment: "staging"})
+	_, span := StartSpan(context.Background(), "work")
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "1.2.3", evs[0].Data["service_version"])
+		assert.Equal(t, "staging", evs[0].Data["environment"])
+		assert.Contains(t, evs[0].Data, "meta.local_hostname")
+	}
+}
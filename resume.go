@@ -0,0 +1,84 @@
+package beeline
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// marshaledAtClause is added to the trace header by MarshalTraceContext with
+// the time it was marshaled, in milliseconds since the epoch. Parsers of the
+// trace header ignore clauses they don't know.
+const marshaledAtClause = "marshaled_at="
+
+// MarshalTraceContext returns the trace context of the span in ctx as a
+// string, to be stored with work that will carry on the trace later, perhaps
+// hours later or in another process, such as a retry or the next step of a
+// saga. Pass it to UnmarshalTraceContext when the work is done to add it to
+// the original trace. It returns an empty string if ctx has no span.
+//
+// The string is a Honeycomb trace header, so it can also be passed to
+// StartJob or sent in a request's headers.
+func MarshalTraceContext(ctx context.Context) string {
+	return std.MarshalTraceContext(ctx)
+}
+
+// MarshalTraceContext returns the trace context of the span in ctx as a
+// string, as MarshalTraceContext does.
+func (b *Beeline) MarshalTraceContext(ctx context.Context) string {
+	span := trace.GetSpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s,%s%d", span.SerializeHeaders(), marshaledAtClause, time.Now().UnixNano()/int64(time.Millisecond))
+}
+
+// UnmarshalTraceContext resumes the trace marshaled by MarshalTraceContext,
+// starting a span called name that is a child of the span that marshaled it,
+// and returns a context holding the span, which the caller must send. Any
+// span already in ctx is ignored.
+//
+// The span records meta.resumed, and meta.resumed_after_ms, the time since
+// the trace context was marshaled. If traceContext can't be parsed the span
+// is the root of a new trace, with the error in meta.propagation_error, and
+// the error is returned too.
+func UnmarshalTraceContext(ctx context.Context, name, traceContext string) (context.Context, *trace.Span, error) {
+	return std.UnmarshalTraceContext(ctx, name, traceContext)
+}
+
+// UnmarshalTraceContext resumes the trace marshaled by MarshalTraceContext,
+// as UnmarshalTraceContext does, sending the span it starts with b.
+func (b *Beeline) UnmarshalTraceContext(ctx context.Context, name, traceContext string) (context.Context, *trace.Span, error) {
+	prop, err := propagation.UnmarshalHoneycombTraceContext(traceContext)
+	ctx, tr := trace.NewTraceFromPropagationContext(b.Context(ctx), prop)
+	span := tr.GetRootSpan()
+	span.AddField("name", name)
+	if err != nil {
+		addJobPropagationError(span, err)
+		return ctx, span, err
+	}
+	span.AddField("meta.resumed", true)
+	if marshaledAt, ok := traceContextMarshaledAt(traceContext); ok {
+		span.AddField("meta.resumed_after_ms", float64(time.Since(marshaledAt))/float64(time.Millisecond))
+	}
+	return ctx, span, nil
+}
+
+// traceContextMarshaledAt returns the time recorded in a trace context by
+// MarshalTraceContext, if there is one.
+func traceContextMarshaledAt(traceContext string) (time.Time, bool) {
+	i := strings.LastIndex(traceContext, ","+marshaledAtClause)
+	if i < 0 {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(traceContext[i+1+len(marshaledAtClause):], 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), true
+}
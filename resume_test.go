@@ -0,0 +1,76 @@
+package beeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalTraceContext(t *testing.T) {
+	mo := setupLibhoney(t)
+	assert.Equal(t, "", MarshalTraceContext(context.Background()))
+
+	ctx, checkout := StartSpan(context.Background(), "checkout")
+	AddFieldToTrace(ctx, "order_id", 42)
+	traceContext := MarshalTraceContext(ctx)
+	checkout.Send()
+
+	// hours later, in another process
+	ctx, retry, err := UnmarshalTraceContext(context.Background(), "charge_retry", traceContext)
+	assert.NoError(t, err)
+	_, child := StartSpan(ctx, "charge")
+	child.Send()
+	retry.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 3, len(evs)) {
+		return
+	}
+	original, charge, resumed := evs[0].Data, evs[1].Data, evs[2].Data
+	assert.Equal(t, "charge_retry", resumed["name"])
+	assert.Equal(t, true, resumed["meta.resumed"])
+	assert.Contains(t, resumed, "meta.resumed_after_ms")
+	assert.Equal(t, original["trace.trace_id"], resumed["trace.trace_id"])
+	assert.Equal(t, original["trace.span_id"], resumed["trace.parent_id"])
+	assert.Equal(t, resumed["trace.span_id"], charge["trace.parent_id"])
+	assert.EqualValues(t, 42, charge["app.order_id"], "trace fields should be carried over")
+
+	// it's an ordinary trace header, so jobs can be started from it too
+	_, job := StartJob(context.Background(), Job{Name: "charge", TraceContext: traceContext})
+	job.Send()
+	assert.Equal(t, original["trace.span_id"], mo.Events()[3].Data["trace.parent_id"])
+}
+
+func TestUnmarshalTraceContextError(t *testing.T) {
+	mo := setupLibhoney(t)
+	_, span, err := UnmarshalTraceContext(context.Background(), "retry", "1;nope")
+	assert.Error(t, err)
+	if assert.NotNil(t, span) {
+		span.Send()
+	}
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "retry", evs[0].Data["name"])
+		assert.Contains(t, evs[0].Data, "meta.propagation_error")
+		assert.NotContains(t, evs[0].Data, "trace.parent_id")
+		assert.NotContains(t, evs[0].Data, "meta.resumed")
+	}
+}
+
+func TestTraceContextMarshaledAt(t *testing.T) {
+	when := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	header := fmt.Sprintf("1;trace_id=abc,parent_id=def,context=e30=,marshaled_at=%d", when.UnixNano()/int64(time.Millisecond))
+	got, ok := traceContextMarshaledAt(header)
+	assert.True(t, ok)
+	assert.True(t, when.Equal(got))
+
+	_, ok = traceContextMarshaledAt(strings.Split(header, ",marshaled_at")[0])
+	assert.False(t, ok)
+	_, ok = traceContextMarshaledAt("1;trace_id=abc,parent_id=def,marshaled_at=soon")
+	assert.False(t, ok)
+}
@@ -0,0 +1,58 @@
+package beeline
+
+import (
+	"os"
+	"runtime"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+)
+
+// reportRuntimeMetrics sends a runtime_metrics event every interval.
+func reportRuntimeMetrics(c *libhoney.Client, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last runtime.MemStats
+	runtime.ReadMemStats(&last)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			ev := c.NewBuilder().NewEvent()
+			ev.AddField("meta.type", "runtime_metrics")
+			ev.AddField("name", "runtime_metrics")
+			ev.AddField("runtime.goroutines", runtime.NumGoroutine())
+			ev.AddField("runtime.heap_alloc_bytes", stats.HeapAlloc)
+			ev.AddField("runtime.heap_objects", stats.HeapObjects)
+			ev.AddField("runtime.sys_bytes", stats.Sys)
+			ev.AddField("runtime.num_gc", stats.NumGC)
+			ev.AddField("runtime.gc_count", stats.NumGC-last.NumGC)
+			ev.AddField("runtime.gc_pause_total_ms", float64(stats.PauseTotalNs)/float64(time.Millisecond))
+			ev.AddField("runtime.gc_pause_ms", float64(stats.PauseTotalNs-last.PauseTotalNs)/float64(time.Millisecond))
+			if fds, ok := openFileDescriptors(); ok {
+				ev.AddField("runtime.open_fds", fds)
+			}
+			ev.Send()
+			last = stats
+		}
+	}
+}
+
+// openFileDescriptors counts the process's open file descriptors, on systems
+// with /proc.
+func openFileDescriptors() (int, bool) {
+	dir, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0, false
+	}
+	// reading the directory takes a descriptor of its own
+	return len(names) - 1, true
+}
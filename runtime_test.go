@@ -0,0 +1,43 @@
+package beeline
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeMetrics(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client, ServiceName: "api", RuntimeMetricsInterval: 10 * time.Millisecond})
+	defer setupLibhoney(t)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(mo.Events()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	evs := mo.Events()
+	if assert.NotEmpty(t, evs) {
+		fields := evs[0].Data
+		assert.Equal(t, "runtime_metrics", fields["meta.type"])
+		assert.Equal(t, "api", fields["service_name"])
+		assert.Contains(t, fields, "meta.local_hostname")
+		assert.True(t, fields["runtime.goroutines"].(int) > 0)
+		assert.True(t, fields["runtime.heap_alloc_bytes"].(uint64) > 0)
+		assert.Contains(t, fields, "runtime.gc_pause_ms")
+		if runtime.GOOS == "linux" {
+			assert.True(t, fields["runtime.open_fds"].(int) > 0)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package beeline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldType is the type a field is declared to have in Config.FieldSchema.
+type FieldType int
+
+const (
+	// FieldInt fields are sent as an int64. Whole floats and strings holding
+	// an integer are converted.
+	FieldInt FieldType = iota + 1
+	// FieldFloat fields are sent as a float64. Other numbers and strings
+	// holding a number are converted.
+	FieldFloat
+	// FieldString fields are sent as a string. Anything else is formatted with
+	// fmt.Sprint, or Error for an error.
+	FieldString
+	// FieldBool fields are sent as a bool. Strings accepted by
+	// strconv.ParseBool are converted.
+	FieldBool
+)
+
+// schemaViolationsField lists the fields dropped from a span because they
+// couldn't be converted to their declared type.
+const schemaViolationsField = "meta.schema_violations"
+
+// schemaHook returns a field hook that converts each field in schema to its
+// declared type, dropping any that can't be and naming them in
+// meta.schema_violations.
+func schemaHook(schema map[string]FieldType) func(map[string]interface{}) {
+	return func(fields map[string]interface{}) {
+		var violations []string
+		for key, typ := range schema {
+			val, ok := fields[key]
+			if !ok || val == nil {
+				continue
+			}
+			if coerced, ok := coerceField(val, typ); ok {
+				fields[key] = coerced
+			} else {
+				delete(fields, key)
+				violations = append(violations, key)
+			}
+		}
+		if len(violations) > 0 {
+			sort.Strings(violations)
+			fields[schemaViolationsField] = strings.Join(violations, ",")
+		}
+	}
+}
+
+// coerceField converts val to typ, returning false if it can't be done without
+// losing information.
+func coerceField(val interface{}, typ FieldType) (interface{}, bool) {
+	switch typ {
+	case FieldInt:
+		switch v := val.(type) {
+		case int:
+			return int64(v), true
+		case int8:
+			return int64(v), true
+		case int16:
+			return int64(v), true
+		case int32:
+			return int64(v), true
+		case int64:
+			return v, true
+		case uint:
+			return int64(v), uint64(v) <= math.MaxInt64
+		case uint8:
+			return int64(v), true
+		case uint16:
+			return int64(v), true
+		case uint32:
+			return int64(v), true
+		case uint64:
+			return int64(v), uint64(v) <= math.MaxInt64
+		case float32:
+			return coerceFloatToInt(float64(v))
+		case float64:
+			return coerceFloatToInt(v)
+		case string:
+			i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return i, err == nil
+		}
+	case FieldFloat:
+		switch v := val.(type) {
+		case int:
+			return float64(v), true
+		case int8:
+			return float64(v), true
+		case int16:
+			return float64(v), true
+		case int32:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		case uint:
+			return float64(v), true
+		case uint8:
+			return float64(v), true
+		case uint16:
+			return float64(v), true
+		case uint32:
+			return float64(v), true
+		case uint64:
+			return float64(v), true
+		case float32:
+			return float64(v), true
+		case float64:
+			return v, true
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return f, err == nil
+		}
+	case FieldString:
+		switch v := val.(type) {
+		case string:
+			return v, true
+		case error:
+			return v.Error(), true
+		default:
+			return fmt.Sprint(v), true
+		}
+	case FieldBool:
+		switch v := val.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			return b, err == nil
+		}
+	}
+	return nil, false
+}
+
+func coerceFloatToInt(f float64) (interface{}, bool) {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return nil, false
+	}
+	return int64(f), true
+}
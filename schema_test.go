@@ -0,0 +1,98 @@
+package beeline
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoerceField(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		typ  FieldType
+		want interface{}
+		ok   bool
+	}{
+		{42, FieldInt, int64(42), true},
+		{uint8(7), FieldInt, int64(7), true},
+		{uint64(math.MaxUint64), FieldInt, nil, false},
+		{float64(3), FieldInt, int64(3), true},
+		{3.5, FieldInt, nil, false},
+		{" 12 ", FieldInt, int64(12), true},
+		{"twelve", FieldInt, nil, false},
+		{true, FieldInt, nil, false},
+		{42, FieldFloat, float64(42), true},
+		{float32(1.5), FieldFloat, float64(1.5), true},
+		{"2.5", FieldFloat, 2.5, true},
+		{"x", FieldFloat, nil, false},
+		{"s", FieldString, "s", true},
+		{42, FieldString, "42", true},
+		{errors.New("boom"), FieldString, "boom", true},
+		{true, FieldBool, true, true},
+		{"false", FieldBool, false, true},
+		{1, FieldBool, nil, false},
+		{1, FieldType(0), nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := coerceField(tt.val, tt.typ)
+		assert.Equal(t, tt.ok, ok, "%#v as %d", tt.val, tt.typ)
+		if tt.ok {
+			assert.Equal(t, tt.want, got, "%#v as %d", tt.val, tt.typ)
+		}
+	}
+}
+
+func TestSchemaHook(t *testing.T) {
+	hook := schemaHook(map[string]FieldType{
+		"app.user_id": FieldInt,
+		"app.plan":    FieldString,
+		"app.ratio":   FieldFloat,
+		"app.admin":   FieldBool,
+		"app.missing": FieldInt,
+	})
+	fields := map[string]interface{}{
+		"app.user_id": "1234",
+		"app.plan":    7,
+		"app.ratio":   "lots",
+		"app.admin":   "maybe",
+		"app.other":   "untouched",
+	}
+	hook(fields)
+	assert.Equal(t, map[string]interface{}{
+		"app.user_id":         int64(1234),
+		"app.plan":            "7",
+		"app.other":           "untouched",
+		schemaViolationsField: "app.admin,app.ratio",
+	}, fields)
+
+	fields = map[string]interface{}{"app.user_id": 1}
+	hook(fields)
+	assert.NotContains(t, fields, schemaViolationsField)
+}
+
+func TestFieldSchemaConfig(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client, FieldSchema: map[string]FieldType{"app.user_id": FieldInt}})
+	defer setupLibhoney(t)
+
+	ctx, span := StartSpan(context.Background(), "request")
+	AddField(ctx, "user_id", "99")
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, int64(99), evs[0].Data["app.user_id"])
+	}
+}
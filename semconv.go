@@ -0,0 +1,140 @@
+package beeline
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// otelFieldNames maps the field names the wrappers record to their
+// OpenTelemetry semantic convention equivalents. Fields not listed keep their
+// names.
+var otelFieldNames = map[string]string{
+	"service_name":    "service.name",
+	"service_version": "service.version",
+	"environment":     "deployment.environment.name",
+	"container_id":    "container.id",
+	"k8s.pod_name":    "k8s.pod.name",
+	"k8s.namespace":   "k8s.namespace.name",
+	"k8s.node_name":   "k8s.node.name",
+	"k8s.pod_uid":     "k8s.pod.uid",
+
+	"request.method":                   "http.request.method",
+	"request.path":                     "url.path",
+	"request.query":                    "url.query",
+	"request.url":                      "url.full",
+	"request.host":                     "server.address",
+	"request.content_length":           "http.request.body.size",
+	"request.header.user_agent":        "user_agent.original",
+	"request.header.x_forwarded_for":   "http.request.header.x-forwarded-for",
+	"request.header.x_forwarded_proto": "http.request.header.x-forwarded-proto",
+	"response.status_code":             "http.response.status_code",
+	"response.size":                    "http.response.body.size",
+	"response.bytes_written":           "http.response.body.size",
+	"response.content_length":          "http.response.header.content-length",
+	"response.content_type":            "http.response.header.content-type",
+	"response.content_encoding":        "http.response.header.content-encoding",
+	"handler.route":                    "http.route",
+
+	"db.query":      "db.statement",
+	"db.query_args": "db.statement.args",
+	"db.call":       "db.operation",
+
+	"grpc.target":      "server.address",
+	"grpc.status_code": "rpc.grpc.status_code",
+	"grpc.error":       "rpc.grpc.status_message",
+}
+
+// otelHook renames fields to the OpenTelemetry semantic conventions. A few
+// values are reshaped to match too: captured request headers become
+// http.request.header.<name>, the HTTP version loses its "HTTP/" prefix, the
+// remote address is split into client.address and client.port, and the gRPC
+// method is split into rpc.service and rpc.method.
+func otelHook(fields map[string]interface{}) {
+	for from, to := range otelFieldNames {
+		if v, ok := fields[from]; ok {
+			delete(fields, from)
+			fields[to] = v
+		}
+	}
+	// headers captured with HTTPHeadersToCapture use the OpenTelemetry
+	// header attribute names too
+	for k, v := range fields {
+		if strings.HasPrefix(k, "request.header.") {
+			delete(fields, k)
+			fields["http.request.header."+strings.Replace(strings.TrimPrefix(k, "request.header."), "_", "-", -1)] = v
+		}
+	}
+	if route, ok := fields["handler.pattern"]; ok {
+		delete(fields, "handler.pattern")
+		if _, ok := fields["http.route"]; !ok {
+			fields["http.route"] = route
+		}
+	}
+	if v, ok := fields["request.http_version"].(string); ok {
+		delete(fields, "request.http_version")
+		fields["network.protocol.version"] = strings.TrimPrefix(v, "HTTP/")
+	}
+	if addr, ok := fields["request.remote_addr"].(string); ok {
+		delete(fields, "request.remote_addr")
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			fields["client.address"] = host
+			if p, err := strconv.Atoi(port); err == nil {
+				fields["client.port"] = p
+			}
+		} else {
+			fields["client.address"] = addr
+		}
+	}
+	if method, ok := fields["grpc.method"].(string); ok {
+		delete(fields, "grpc.method")
+		fields["rpc.system"] = "grpc"
+		// full methods look like /package.Service/Method
+		if i := strings.LastIndex(method, "/"); i > 0 {
+			fields["rpc.service"] = strings.TrimPrefix(method[:i], "/")
+			fields["rpc.method"] = method[i+1:]
+		} else {
+			fields["rpc.method"] = method
+		}
+	}
+}
+
+// fieldNamesHook returns a hook renaming fields as Config.FieldNames
+// describes.
+func fieldNamesHook(names map[string]string) func(map[string]interface{}) {
+	exact := make(map[string]string, len(names))
+	var prefixes []string
+	for from, to := range names {
+		if strings.HasSuffix(from, ".") {
+			prefixes = append(prefixes, from)
+		} else {
+			exact[from] = to
+		}
+	}
+	// longest first, so the most specific prefix wins
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+	return func(fields map[string]interface{}) {
+		renamed := make(map[string]interface{})
+		for k, v := range fields {
+			to, ok := exact[k]
+			if !ok {
+				for _, prefix := range prefixes {
+					if strings.HasPrefix(k, prefix) {
+						to, ok = names[prefix]+strings.TrimPrefix(k, prefix), true
+						break
+					}
+				}
+			}
+			if ok && to != k {
+				delete(fields, k)
+				renamed[to] = v
+			}
+		}
+		for k, v := range renamed {
+			fields[k] = v
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package beeline
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/beeline-go/wrappers/common"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOTelHook(t *testing.T) {
+	fields := map[string]interface{}{
+		"request.method":        "GET",
+		"request.path":          "/users/1",
+		"request.http_version":  "HTTP/1.1",
+		"request.remote_addr":   "192.0.2.1:1234",
+		"handler.pattern":       "/users/",
+		"response.status_code":  200,
+		"grpc.method":           "/pkg.Users/Get",
+		"db.query":              "SELECT 1",
+		"request.header.accept": "text/html",
+		"app.custom":            "kept",
+	}
+	otelHook(fields)
+	assert.Equal(t, map[string]interface{}{
+		"http.request.method":        "GET",
+		"url.path":                   "/users/1",
+		"network.protocol.version":   "1.1",
+		"client.address":             "192.0.2.1",
+		"client.port":                1234,
+		"http.route":                 "/users/",
+		"http.response.status_code":  200,
+		"rpc.system":                 "grpc",
+		"rpc.service":                "pkg.Users",
+		"rpc.method":                 "Get",
+		"db.statement":               "SELECT 1",
+		"http.request.header.accept": "text/html",
+		"app.custom":                 "kept",
+	}, fields)
+
+	fields = map[string]interface{}{"handler.route": "/a/{id}", "handler.pattern": "/a/", "request.remote_addr": "@"}
+	otelHook(fields)
+	assert.Equal(t, "/a/{id}", fields["http.route"], "the route should win over the pattern")
+	assert.Equal(t, "@", fields["client.address"])
+}
+
+func TestOTelFieldNamesConfig(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	var sampled map[string]interface{}
+	Init(Config{
+		Client:            client,
+		ServiceName:       "api",
+		DefaultLatencySLO: time.Second,
+		OTelFieldNames:    true,
+		SamplerHook: func(fields map[string]interface{}) (bool, int) {
+			sampled = fields
+			return true, 1
+		},
+	})
+	defer setupLibhoney(t)
+
+	_, span := common.StartSpanOrTraceFromHTTP(httptest.NewRequest("POST", "/things", nil))
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "POST", evs[0].Data["http.request.method"])
+		assert.Equal(t, "/things", evs[0].Data["url.path"])
+		assert.Equal(t, "api", evs[0].Data["service.name"])
+		assert.NotContains(t, evs[0].Data, "request.method")
+		assert.Contains(t, evs[0].Data, "slo.target_ms", "SLOs are found before renaming")
+		assert.Equal(t, "POST", sampled["http.request.method"], "the sampler should see the new names")
+	}
+}
+
+func TestFieldNamesHook(t *testing.T) {
+	hook := fieldNamesHook(map[string]string{
+		"request.method":            "http.method",
+		"request.header.":           "http.header.",
+		"request.header.user_agent": "http.user_agent",
+		"db.":                       "sql.",
+		"db.query.":                 "sql.statement.",
+		"response.status_code":      "request.method",
+	})
+	fields := map[string]interface{}{
+		"request.method":            "GET",
+		"request.header.accept":     "text/html",
+		"request.header.user_agent": "curl",
+		"db.query":                  "SELECT 1",
+		"db.query.normalized":       "SELECT ?",
+		"response.status_code":      200,
+		"app.custom":                "kept",
+	}
+	hook(fields)
+	assert.Equal(t, map[string]interface{}{
+		"http.method":              "GET",
+		"http.header.accept":       "text/html",
+		"http.user_agent":          "curl",
+		"sql.query":                "SELECT 1",
+		"sql.statement.normalized": "SELECT ?",
+		"request.method":           200,
+		"app.custom":               "kept",
+	}, fields)
+}
+
+func TestFieldNamesConfig(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{
+		Client:         client,
+		OTelFieldNames: true,
+		FieldNames: map[string]string{
+			"http.request.method": "http.method",
+			"url.path":            "http.target",
+		},
+	})
+	defer setupLibhoney(t)
+
+	_, span := common.StartSpanOrTraceFromHTTP(httptest.NewRequest("POST", "/things", nil))
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "POST", evs[0].Data["http.method"])
+		assert.Equal(t, "/things", evs[0].Data["http.target"])
+		assert.NotContains(t, evs[0].Data, "http.request.method")
+		assert.NotContains(t, evs[0].Data, "request.method")
+	}
+}
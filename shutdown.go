@@ -0,0 +1,115 @@
+package beeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// Shutdown prepares the beeline for the process to exit. It stops new spans
+// from being recorded, sends the spans still open with `meta.shutdown` set so
+// the work interrupted is visible, and flushes the events waiting to be sent,
+// giving up once ctx is done. Open spans are only sent if Config.TrackOpenSpans
+// or Config.InProgressAfter was set. Call it from an existing shutdown
+// handler, or use ShutdownOnSignal. It returns ctx's error if the flush
+// didn't finish in time.
+func Shutdown(ctx context.Context) error {
+	trace.StopNewSpans()
+	trace.SendOpenSpans(map[string]interface{}{"meta.shutdown": true})
+	return std.flushClient(ctx)
+}
+
+// FlushError is returned by Flush when it didn't send every event.
+type FlushError struct {
+	// Dropped is the number of events that failed to send while flushing
+	// and won't be retried.
+	Dropped uint64
+	// Err is ctx's error if ctx was done before the flush finished, in which
+	// case the remaining events are still sent in the background.
+	Err error
+}
+
+func (e *FlushError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("beeline: %d events dropped while flushing", e.Dropped)
+	}
+	return fmt.Sprintf("beeline: flush incomplete, %d events dropped: %s", e.Dropped, e.Err)
+}
+
+// Unwrap returns ctx's error, if Flush gave up waiting.
+func (e *FlushError) Unwrap() error {
+	return e.Err
+}
+
+// flushClient flushes b's client, giving up waiting once ctx is done.
+// libhoney can't flush or close a client that is already flushing, and a
+// flush given up on carries on in the background, so b.flushLock is held
+// while it runs and closeClient waits for it.
+func (b *Beeline) flushClient(ctx context.Context) error {
+	c := b.Client()
+	flushed := make(chan struct{})
+	go func() {
+		b.flushLock.Lock()
+		defer b.flushLock.Unlock()
+		c.Flush()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ShutdownOnSignal calls Shutdown, with timeout to flush in, when the process
+// receives one of signals, or SIGINT or SIGTERM if none are given, and then
+// exits with the usual status for the signal. It returns a function that
+// removes the handler.
+func ShutdownOnSignal(timeout time.Duration, signals ...os.Signal) func() {
+	sigs := shutdownSignals(signals)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-ch:
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			Shutdown(ctx)
+			cancel()
+			os.Exit(exitStatus(sig))
+		case <-stop:
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(stop)
+	}
+}
+
+func shutdownSignals(signals []os.Signal) []os.Signal {
+	if len(signals) == 0 {
+		return []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	return signals
+}
+
+// exitStatus is the status a shell reports for a process killed by sig.
+func exitStatus(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
+
+// closeClient closes b's client once any flush in progress has finished.
+func (b *Beeline) closeClient() {
+	b.flushLock.Lock()
+	defer b.flushLock.Unlock()
+	b.Client().Close()
+}
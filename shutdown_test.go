@@ -0,0 +1,94 @@
+package beeline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client, TrackOpenSpans: true})
+	defer setupLibhoney(t)
+
+	ctx, root := StartSpan(context.Background(), "request")
+	ctx, _ = StartSpan(ctx, "query")
+	_, async := root.CreateAsyncChild(ctx)
+	async.AddField("name", "background")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, Shutdown(ctx))
+
+	_, late := StartSpan(context.Background(), "late")
+	late.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs), "open spans should be sent, and new ones not recorded") {
+		names := map[interface{}]interface{}{}
+		for _, ev := range evs {
+			names[ev.Data["name"]] = ev.Data["meta.shutdown"]
+		}
+		assert.Equal(t, map[interface{}]interface{}{"request": true, "query": nil, "background": true}, names)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	release := make(chan struct{})
+	var reject int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&reject) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		<-release
+		w.Write([]byte(`[{"status":202}]`))
+	}))
+	defer srv.Close()
+	Init(Config{WriteKey: "key", Dataset: "flush", APIHost: srv.URL})
+	defer setupLibhoney(t)
+	// unblock the server before setupLibhoney closes the client
+	defer close(release)
+
+	ctx, _ := StartSpan(context.Background(), "rejected")
+	err := Flush(ctx)
+	if assert.IsType(t, &FlushError{}, err) {
+		assert.Equal(t, uint64(1), err.(*FlushError).Dropped)
+		assert.Nil(t, err.(*FlushError).Err)
+	}
+
+	atomic.StoreInt32(&reject, 0)
+	_, span := StartSpan(context.Background(), "slow")
+	span.Send()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = Flush(ctx)
+	if assert.IsType(t, &FlushError{}, err) {
+		assert.Equal(t, context.DeadlineExceeded, err.(*FlushError).Err)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	assert.Equal(t, 143, exitStatus(syscall.SIGTERM))
+	assert.Equal(t, []os.Signal{os.Interrupt, syscall.SIGTERM}, shutdownSignals(nil))
+	assert.Equal(t, []os.Signal{syscall.SIGHUP}, shutdownSignals([]os.Signal{syscall.SIGHUP}))
+
+	stop := ShutdownOnSignal(time.Second)
+	stop()
+}
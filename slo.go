@@ -0,0 +1,46 @@
+package beeline
+
+import (
+	"time"
+)
+
+// routeFields are the span fields the HTTP wrappers record a request's route
+// in, most specific first.
+var routeFields = []string{"route", "handler.route", "handler.pattern", "request.path"}
+
+// spanRoute returns the route of an HTTP request span, or "" if the span
+// isn't one.
+func spanRoute(fields map[string]interface{}) string {
+	if fields["meta.type"] != "http_request" {
+		return ""
+	}
+	for _, key := range routeFields {
+		if route, ok := fields[key].(string); ok && route != "" {
+			return route
+		}
+	}
+	return ""
+}
+
+// sloHook returns a field hook that compares the duration of each HTTP request
+// span with the latency target for its route.
+func sloHook(targets map[string]time.Duration, fallback time.Duration) func(map[string]interface{}) {
+	return func(fields map[string]interface{}) {
+		route := spanRoute(fields)
+		if route == "" {
+			return
+		}
+		target, ok := targets[route]
+		if !ok {
+			target = fallback
+		}
+		dur, ok := fields["duration_ms"].(float64)
+		if target <= 0 || !ok {
+			return
+		}
+		targetMS := float64(target) / float64(time.Millisecond)
+		fields["slo.target_ms"] = targetMS
+		fields["slo.violated"] = dur > targetMS
+		fields["slo.margin_ms"] = targetMS - dur
+	}
+}
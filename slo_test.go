@@ -0,0 +1,64 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSLOHook(t *testing.T) {
+	hook := sloHook(map[string]time.Duration{"/slow": time.Second}, 100*time.Millisecond)
+
+	fields := map[string]interface{}{
+		"meta.type":     "http_request",
+		"handler.route": "/slow",
+		"request.path":  "/slow",
+		"duration_ms":   float64(250),
+	}
+	hook(fields)
+	assert.Equal(t, float64(1000), fields["slo.target_ms"])
+	assert.Equal(t, false, fields["slo.violated"])
+	assert.Equal(t, float64(750), fields["slo.margin_ms"])
+
+	fields = map[string]interface{}{
+		"meta.type":    "http_request",
+		"request.path": "/other",
+		"duration_ms":  float64(250),
+	}
+	hook(fields)
+	assert.Equal(t, float64(100), fields["slo.target_ms"], "the default target should apply")
+	assert.Equal(t, true, fields["slo.violated"])
+	assert.Equal(t, float64(-150), fields["slo.margin_ms"])
+
+	fields = map[string]interface{}{"name": "work", "duration_ms": float64(250)}
+	hook(fields)
+	assert.NotContains(t, fields, "slo.target_ms", "only HTTP requests have SLOs")
+}
+
+func TestLatencySLOConfig(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client, LatencySLOs: map[string]time.Duration{"/orders": time.Hour}})
+	defer setupLibhoney(t)
+
+	_, span := StartSpan(context.Background(), "request")
+	span.AddField("meta.type", "http_request")
+	span.AddField("request.path", "/orders")
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, false, evs[0].Data["slo.violated"])
+		assert.Equal(t, float64(time.Hour/time.Millisecond), evs[0].Data["slo.target_ms"])
+	}
+}
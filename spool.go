@@ -0,0 +1,289 @@
+package beeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/honeycombio/libhoney-go/transmission"
+)
+
+const (
+	defaultSpoolMaxBytes    = 64 << 20
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+
+	spoolFileName = "events.spool"
+	// replayBatchSize events are replayed at a time, with replayPause between
+	// batches, so a large spool doesn't overflow libhoney's queue.
+	replayBatchSize = 500
+	replayPause     = 100 * time.Millisecond
+)
+
+var (
+	errSpoolFull   = errors.New("spool full")
+	errBreakerOpen = errors.New("circuit breaker open")
+)
+
+// circuitBreaker trips after a run of consecutive failures that suggest the
+// API is unreachable, and then lets a single probe event through every
+// cooldown until one succeeds.
+type circuitBreaker struct {
+	threshold uint
+	cooldown  time.Duration
+
+	lock     sync.Mutex
+	failures uint
+	open     bool
+	openedAt time.Time
+	probing  bool
+	trips    uint64
+}
+
+// allow returns true if an event may be sent now.
+func (b *circuitBreaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.open {
+		return true
+	}
+	if !b.probing && time.Since(b.openedAt) >= b.cooldown {
+		b.probing = true
+		return true
+	}
+	return false
+}
+
+func (b *circuitBreaker) isOpen() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.open
+}
+
+// state returns whether the breaker is open and how many times it has tripped.
+func (b *circuitBreaker) state() (bool, uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.open, b.trips
+}
+
+func (b *circuitBreaker) failure() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.failures++
+	switch {
+	case b.open && b.probing:
+		// the probe failed; wait out another cooldown
+		b.probing = false
+		b.openedAt = time.Now()
+	case !b.open && b.failures >= b.threshold:
+		b.open = true
+		b.openedAt = time.Now()
+		b.trips++
+	}
+}
+
+func (b *circuitBreaker) success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.failures = 0
+	b.open = false
+	b.probing = false
+}
+
+// spooledEvent is an event as it is written to the spool. Metadata can't be
+// written, so replayed events have none. The write key isn't written either,
+// so the spool holds no credentials; replayed events are sent with the
+// sender's.
+type spooledEvent struct {
+	Dataset    string                 `json:"dataset,omitempty"`
+	APIHost    string                 `json:"api_host,omitempty"`
+	SampleRate uint                   `json:"sample_rate,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// diskSpool is a bounded file of events, one JSON object per line, waiting to
+// be replayed.
+type diskSpool struct {
+	path     string
+	maxBytes int64
+
+	lock sync.Mutex
+	size int64
+}
+
+func openSpool(dir string, maxBytes int64) (*diskSpool, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultSpoolMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	d := &diskSpool{path: filepath.Join(dir, spoolFileName), maxBytes: maxBytes}
+	// events left by an earlier process are replayed too
+	if fi, err := os.Stat(d.path); err == nil {
+		d.size = fi.Size()
+	}
+	return d, nil
+}
+
+// write appends ev to the spool, returning errSpoolFull if it doesn't fit.
+func (d *diskSpool) write(ev *transmission.Event) error {
+	line, err := json.Marshal(spooledEvent{
+		Dataset:    ev.Dataset,
+		APIHost:    ev.APIHost,
+		SampleRate: ev.SampleRate,
+		Timestamp:  ev.Timestamp,
+		Data:       ev.Data,
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.size+int64(len(line)) > d.maxBytes {
+		return errSpoolFull
+	}
+	f, err := os.OpenFile(d.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := f.Write(line)
+	d.size += int64(n)
+	return err
+}
+
+// take moves the spooled events aside for replay, and returns the path of the
+// file they are now in, or "" if there are none. A file left by a replay that
+// didn't finish is returned before anything spooled since.
+func (d *diskSpool) take() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	replayPath := d.path + ".replay"
+	if _, err := os.Stat(replayPath); err == nil {
+		return replayPath
+	}
+	if err := os.Rename(d.path, replayPath); err != nil {
+		return ""
+	}
+	d.size = 0
+	return replayPath
+}
+
+// spoolEvent writes the event to the spool, returning false if it couldn't be.
+func (s *retrySender) spoolEvent(meta *retryMetadata) bool {
+	if err := s.spool.write(meta.event); err != nil {
+		return false
+	}
+	atomic.AddUint64(&s.spooled, 1)
+	return true
+}
+
+// recordOutcome updates the breaker with the response to an event, and starts
+// replaying the spool, if there is one, once the API is reachable again.
+func (s *retrySender) recordOutcome(r transmission.Response) {
+	if isRetryable(r) {
+		s.breaker.failure()
+		return
+	}
+	if r.Err != nil {
+		// an overflowing queue says nothing about the API
+		return
+	}
+	s.breaker.success()
+	if s.spool != nil {
+		s.startReplay()
+	}
+}
+
+// startReplay replays the spool in the background unless a replay is already
+// running.
+func (s *retrySender) startReplay() {
+	if !atomic.CompareAndSwapInt32(&s.replaying, 0, 1) {
+		return
+	}
+	path := s.spool.take()
+	if path == "" {
+		atomic.StoreInt32(&s.replaying, 0)
+		return
+	}
+	s.readers.Add(1)
+	go func() {
+		defer s.readers.Done()
+		defer atomic.StoreInt32(&s.replaying, 0)
+		s.replay(path)
+	}()
+}
+
+// replay sends every event in the file at path. Events go through Add, so
+// they are spooled again if the breaker trips part way through, and written
+// straight back to the spool if the transmission is stopped.
+func (s *retrySender) replay(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var se spooledEvent
+			if json.Unmarshal(line, &se) == nil {
+				s.replayEvent(&transmission.Event{
+					APIKey:     s.writeKey,
+					Dataset:    se.Dataset,
+					APIHost:    se.APIHost,
+					SampleRate: se.SampleRate,
+					Timestamp:  se.Timestamp,
+					Data:       se.Data,
+				})
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			// leave the file to be picked up by the next replay
+			f.Close()
+			return
+		}
+		if n%replayBatchSize == 0 && s.isRunning() {
+			time.Sleep(replayPause)
+		}
+	}
+	f.Close()
+	os.Remove(path)
+}
+
+func (s *retrySender) isRunning() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.running
+}
+
+// replayEvent sends ev, or writes it back to the spool if the transmission
+// is stopped. As in retry, the lock isn't held while ev is added.
+func (s *retrySender) replayEvent(ev *transmission.Event) {
+	s.lock.Lock()
+	running := s.running
+	if running {
+		s.adding.Add(1)
+	}
+	s.lock.Unlock()
+	if !running {
+		s.spool.write(ev)
+		return
+	}
+	defer s.adding.Done()
+	atomic.AddUint64(&s.replayed, 1)
+	s.Add(ev)
+}
@@ -0,0 +1,193 @@
+package beeline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func startSpoolingSender(t *testing.T, tx transmission.Sender, config Config) *retrySender {
+	s := newRetrySender(tx, config)
+	sp, err := openSpool(config.SpoolDir, config.SpoolMaxBytes)
+	assert.NoError(t, err)
+	s.spool = sp
+	assert.NoError(t, s.Start())
+	return s
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := circuitBreaker{threshold: 2, cooldown: 10 * time.Millisecond}
+	b.failure()
+	assert.True(t, b.allow())
+	b.failure()
+	assert.False(t, b.allow(), "the breaker should trip")
+
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, b.allow(), "a probe should be let through after the cooldown")
+	assert.False(t, b.allow(), "only one probe at a time")
+	b.failure()
+	assert.False(t, b.allow(), "a failed probe should restart the cooldown")
+
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, b.allow())
+	b.success()
+	assert.True(t, b.allow())
+	assert.False(t, b.isOpen())
+}
+
+func TestRetrySenderSpoolsDuringOutage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tx := &scriptedSender{codes: []int{503, 503}}
+	s := startSpoolingSender(t, tx, Config{
+		SpoolDir:         dir,
+		BreakerThreshold: 2,
+		BreakerCooldown:  50 * time.Millisecond,
+	})
+	defer s.Stop()
+
+	s.Add(&transmission.Event{Data: map[string]interface{}{"n": 1}})
+	s.Add(&transmission.Event{Data: map[string]interface{}{"n": 2}})
+	assert.Eventually(t, func() bool { return s.stats().Spooled == 2 }, 5*time.Second, time.Millisecond,
+		"events out of retries should be spooled")
+	assert.True(t, s.breaker.isOpen())
+
+	s.Add(&transmission.Event{Data: map[string]interface{}{"n": 3}})
+	assert.Equal(t, uint64(3), s.stats().Spooled)
+	assert.Equal(t, 2, tx.addCount(), "the open breaker should keep events from the API")
+
+	// once the cooldown is up the next event probes the API, and its success
+	// replays the spool
+	time.Sleep(60 * time.Millisecond)
+	s.Add(&transmission.Event{Data: map[string]interface{}{"n": 4}})
+	assert.Eventually(t, func() bool { return s.stats().Sent == 4 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, TransmissionStats{Sent: 4, Spooled: 3, Replayed: 3, BreakerTrips: 1}, s.stats())
+	assert.Equal(t, 6, tx.addCount())
+	assert.Eventually(t, func() bool {
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		return len(files) == 0
+	}, 5*time.Second, time.Millisecond, "the spool should be empty")
+}
+
+func TestRetrySenderSpoolFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var failures []transmission.Response
+	var lock sync.Mutex
+	tx := &scriptedSender{codes: []int{503}}
+	s := startSpoolingSender(t, tx, Config{
+		SpoolDir:         dir,
+		SpoolMaxBytes:    1,
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Hour,
+		TransmissionErrorHandler: func(r transmission.Response) {
+			lock.Lock()
+			defer lock.Unlock()
+			failures = append(failures, r)
+		},
+	})
+	defer s.Stop()
+
+	s.Add(&transmission.Event{})
+	assert.Eventually(t, s.breaker.isOpen, 5*time.Second, time.Millisecond)
+	s.Add(&transmission.Event{Metadata: "dropped"})
+
+	lock.Lock()
+	defer lock.Unlock()
+	if assert.Equal(t, 2, len(failures)) {
+		assert.Equal(t, 503, failures[0].StatusCode)
+		assert.Equal(t, errSpoolFull, failures[1].Err)
+		assert.Equal(t, "dropped", failures[1].Metadata)
+	}
+}
+
+func TestRetrySenderReplaysEarlierSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sp, err := openSpool(dir, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, sp.write(&transmission.Event{Dataset: "left over", Data: map[string]interface{}{"a": "b"}}))
+
+	tx := &scriptedSender{}
+	s := startSpoolingSender(t, tx, Config{SpoolDir: dir})
+	s.Add(&transmission.Event{})
+	assert.Eventually(t, func() bool { return s.stats().Sent == 2 }, 5*time.Second, time.Millisecond)
+	assert.NoError(t, s.Stop())
+	assert.Equal(t, uint64(1), s.stats().Replayed)
+}
+
+func TestRetrySenderReplayDoesNotHoldLockWhileAdding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sp, err := openSpool(dir, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, sp.write(&transmission.Event{Data: map[string]interface{}{"a": "b"}}))
+
+	tx := &fullSender{blocked: make(chan struct{}), room: make(chan struct{})}
+	s := startSpoolingSender(t, tx, Config{SpoolDir: dir})
+	s.Add(&transmission.Event{})
+	select {
+	case <-tx.blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the spooled event was not replayed")
+	}
+
+	unlocked := make(chan struct{})
+	go func() {
+		s.lock.Lock()
+		s.lock.Unlock()
+		close(unlocked)
+	}()
+	select {
+	case <-unlocked:
+	case <-time.After(5 * time.Second):
+		t.Error("the lock was held while the replayed event waited for room")
+	}
+	close(tx.room)
+	assert.Eventually(t, func() bool { return s.stats().Sent == 2 }, 5*time.Second, time.Millisecond)
+	assert.NoError(t, s.Stop())
+}
+
+// keySender is a scriptedSender that records the write key of every event.
+type keySender struct {
+	scriptedSender
+	keys []string
+}
+
+func (s *keySender) Add(ev *transmission.Event) {
+	s.Lock()
+	s.keys = append(s.keys, ev.APIKey)
+	s.Unlock()
+	s.scriptedSender.Add(ev)
+}
+
+func TestSpoolDoesNotWriteKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sp, err := openSpool(dir, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, sp.write(&transmission.Event{APIKey: "secret", Data: map[string]interface{}{"a": "b"}}))
+	spooled, err := ioutil.ReadFile(filepath.Join(dir, spoolFileName))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(spooled), "secret")
+
+	tx := &keySender{}
+	s := startSpoolingSender(t, tx, Config{SpoolDir: dir, WriteKey: "key"})
+	s.Add(&transmission.Event{APIKey: "key"})
+	assert.Eventually(t, func() bool { return s.stats().Sent == 2 }, 5*time.Second, time.Millisecond)
+	assert.NoError(t, s.Stop())
+	assert.Equal(t, []string{"key", "key"}, tx.keys, "replayed events should be sent with the configured key")
+}
@@ -0,0 +1,27 @@
+package beeline
+
+import "strconv"
+
+// defaultHTTPErrorStatus is the lowest status that marks a request as an
+// error when Config.HTTPErrorStatus isn't set.
+const defaultHTTPErrorStatus = 500
+
+// statusHook returns a field hook that adds the class of each HTTP request
+// span's status, eg "4xx", as response.status_class, and sets error to true
+// on those whose status is errorStatus or more, unless the span already has
+// an error.
+func statusHook(errorStatus int) func(map[string]interface{}) {
+	return func(fields map[string]interface{}) {
+		if fields["meta.type"] != "http_request" {
+			return
+		}
+		status, ok := fields["response.status_code"].(int)
+		if !ok || status < 100 || status > 999 {
+			return
+		}
+		fields["response.status_class"] = strconv.Itoa(status/100) + "xx"
+		if _, ok := fields["error"]; !ok && status >= errorStatus {
+			fields["error"] = true
+		}
+	}
+}
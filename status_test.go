@@ -0,0 +1,47 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusHook(t *testing.T) {
+	hook := statusHook(500)
+	tests := []struct {
+		fields map[string]interface{}
+		class  interface{}
+		err    interface{}
+	}{
+		{map[string]interface{}{"meta.type": "http_request", "response.status_code": 204}, "2xx", nil},
+		{map[string]interface{}{"meta.type": "http_request", "response.status_code": 404}, "4xx", nil},
+		{map[string]interface{}{"meta.type": "http_request", "response.status_code": 503}, "5xx", true},
+		{map[string]interface{}{"meta.type": "http_request", "response.status_code": 500, "error": "boom"}, "5xx", "boom"},
+		{map[string]interface{}{"meta.type": "http_request"}, nil, nil},
+		{map[string]interface{}{"meta.type": "http_client", "response.status_code": 500}, nil, nil},
+	}
+	for _, tt := range tests {
+		hook(tt.fields)
+		assert.Equal(t, tt.class, tt.fields["response.status_class"], "%v", tt.fields)
+		assert.Equal(t, tt.err, tt.fields["error"], "%v", tt.fields)
+	}
+
+	fields := map[string]interface{}{"meta.type": "http_request", "response.status_code": 429}
+	statusHook(400)(fields)
+	assert.Equal(t, true, fields["error"], "the threshold should be configurable")
+}
+
+func TestHTTPErrorStatusConfig(t *testing.T) {
+	mo := setupLibhoney(t)
+	_, span := StartSpan(context.Background(), "request")
+	span.AddField("meta.type", "http_request")
+	span.AddField("response.status_code", 502)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "5xx", evs[0].Data["response.status_class"])
+		assert.Equal(t, true, evs[0].Data["error"])
+	}
+}
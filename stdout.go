@@ -0,0 +1,175 @@
+package beeline
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/honeycombio/libhoney-go/transmission"
+)
+
+// STDOUTFormat chooses how events are printed when Config.STDOUT is set.
+type STDOUTFormat int
+
+const (
+	// STDOUTJSON prints each event as a line of JSON as it is sent, the way
+	// it would be sent to Honeycomb.
+	STDOUTJSON STDOUTFormat = iota
+	// STDOUTTree prints each trace as an indented tree of its spans once its
+	// root span is sent, which is easier to read while developing.
+	STDOUTTree
+)
+
+// fields printed as part of the span's line rather than with its other fields
+var treeSkippedFields = map[string]bool{
+	"name":            true,
+	"duration_ms":     true,
+	"trace.trace_id":  true,
+	"trace.span_id":   true,
+	"trace.parent_id": true,
+}
+
+// treeSender is a transmission.Sender that holds on to the spans of each
+// trace until its root span is sent, then prints the whole trace as a tree to
+// STDOUT, or to the writer W if one is set. Events that aren't part of a
+// trace are printed on their own straight away, and any traces still waiting
+// for their root are printed when the sender is stopped.
+type treeSender struct {
+	W io.Writer
+
+	lock      sync.Mutex
+	traces    map[string][]*transmission.Event
+	responses chan transmission.Response
+}
+
+func (t *treeSender) Start() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.traces == nil {
+		t.traces = make(map[string][]*transmission.Event)
+	}
+	t.responses = make(chan transmission.Response, 100)
+	return nil
+}
+
+// Stop prints the traces that never had their root span sent, so that
+// nothing is lost when the beeline is flushed or closed.
+func (t *treeSender) Stop() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ids := make([]string, 0, len(t.traces))
+	for id := range t.traces {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		t.printTrace(id, t.traces[id])
+		delete(t.traces, id)
+	}
+	return nil
+}
+
+func (t *treeSender) Add(ev *transmission.Event) {
+	t.lock.Lock()
+	traceID, _ := ev.Data["trace.trace_id"].(string)
+	if traceID == "" {
+		t.printEvent(ev, 0)
+	} else {
+		t.traces[traceID] = append(t.traces[traceID], ev)
+		switch ev.Data["meta.span_type"] {
+		case "root", "subroot":
+			t.printTrace(traceID, t.traces[traceID])
+			delete(t.traces, traceID)
+		}
+	}
+	t.lock.Unlock()
+	t.SendResponse(transmission.Response{Metadata: ev.Metadata})
+}
+
+func (t *treeSender) TxResponses() chan transmission.Response {
+	return t.responses
+}
+
+func (t *treeSender) SendResponse(r transmission.Response) bool {
+	select {
+	case t.responses <- r:
+	default:
+		return true
+	}
+	return false
+}
+
+// printTrace prints the spans of a trace indented beneath their parents.
+// Spans whose parent isn't among them, such as the root or spans whose parent
+// was already printed, are printed at the top level. Siblings are printed in
+// the order they started.
+func (t *treeSender) printTrace(traceID string, evs []*transmission.Event) {
+	spans := make(map[string]bool, len(evs))
+	for _, ev := range evs {
+		if id, ok := ev.Data["trace.span_id"].(string); ok {
+			spans[id] = true
+		}
+	}
+	children := make(map[string][]*transmission.Event)
+	var tops []*transmission.Event
+	for _, ev := range evs {
+		parent, _ := ev.Data["trace.parent_id"].(string)
+		if spans[parent] {
+			children[parent] = append(children[parent], ev)
+		} else {
+			tops = append(tops, ev)
+		}
+	}
+
+	t.writeString(fmt.Sprintf("trace %s\n", traceID))
+	var print func(evs []*transmission.Event, depth int)
+	print = func(evs []*transmission.Event, depth int) {
+		sort.SliceStable(evs, func(i, j int) bool {
+			return evs[i].Timestamp.Before(evs[j].Timestamp)
+		})
+		for _, ev := range evs {
+			t.printEvent(ev, depth+1)
+			if id, ok := ev.Data["trace.span_id"].(string); ok {
+				print(children[id], depth+1)
+			}
+		}
+	}
+	print(tops, 0)
+}
+
+// printEvent prints one span or event on a line of its own: its name and
+// duration, then the rest of its fields sorted by name.
+func (t *treeSender) printEvent(ev *transmission.Event, depth int) {
+	var b strings.Builder
+	b.WriteString(strings.Repeat("  ", depth))
+	if name, ok := ev.Data["name"]; ok {
+		fmt.Fprintf(&b, "%v", name)
+	} else {
+		b.WriteString("(unnamed)")
+	}
+	if d, ok := ev.Data["duration_ms"]; ok {
+		fmt.Fprintf(&b, " (%vms)", d)
+	}
+	keys := make([]string, 0, len(ev.Data))
+	for k := range ev.Data {
+		if !treeSkippedFields[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, ev.Data[k])
+	}
+	b.WriteString("\n")
+	t.writeString(b.String())
+}
+
+func (t *treeSender) writeString(s string) {
+	if t.W == nil {
+		t.W = os.Stdout
+	}
+	io.WriteString(t.W, s)
+}
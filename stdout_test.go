@@ -0,0 +1,62 @@
+package beeline
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/trace"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSTDOUTTree(t *testing.T) {
+	var buf bytes.Buffer
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: &treeSender{W: &buf},
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client})
+	defer setupLibhoney(t)
+
+	ctx, root := StartSpan(context.Background(), "request")
+	traceID := trace.GetTraceFromContext(ctx).GetTraceID()
+	root.AddField("user", "ada")
+	ctx, query := StartSpan(ctx, "query")
+	_, fetch := StartSpan(ctx, "fetch")
+	fetch.Send()
+	query.Send()
+	_, async := root.CreateAsyncChild(ctx)
+	async.AddField("name", "background")
+	assert.Equal(t, "", buf.String(), "spans should be held until the root is sent")
+	root.Send()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if assert.Equal(t, 4, len(lines), buf.String()) {
+		assert.True(t, strings.HasPrefix(lines[0], "trace "+traceID), lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "  request ("), lines[1])
+		assert.Contains(t, lines[1], " user=ada")
+		assert.True(t, strings.HasPrefix(lines[2], "    query ("), lines[2])
+		assert.True(t, strings.HasPrefix(lines[3], "      fetch ("), lines[3])
+	}
+
+	// the async child is printed once it's sent, on its own
+	buf.Reset()
+	async.Send()
+	client.Flush()
+	lines = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if assert.Equal(t, 2, len(lines), buf.String()) {
+		assert.True(t, strings.HasPrefix(lines[1], "  background ("), lines[1])
+	}
+
+	buf.Reset()
+	ev := client.NewEvent()
+	ev.AddField("name", "marker")
+	ev.Send()
+	client.Flush()
+	assert.True(t, strings.HasPrefix(buf.String(), "marker "), buf.String())
+}
@@ -0,0 +1,46 @@
+package beeline
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TenantFromHeader returns a TenantFunc that takes the tenant from the named
+// request header, eg "X-Tenant-ID".
+func TenantFromHeader(name string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// TenantFromSubdomain is a TenantFunc that takes the tenant from the first
+// label of the request's host, so a request to acme.example.com belongs to
+// "acme". Hosts with fewer than three labels, and IP addresses, have no
+// tenant.
+func TenantFromSubdomain(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 3 {
+		return ""
+	}
+	return strings.ToLower(labels[0])
+}
+
+// tenantHook returns a trace.TenantHook that finds the tenant of a request
+// with tenantFunc and looks its dataset up in datasets.
+func tenantHook(tenantFunc func(*http.Request) string, datasets map[string]string) func(*http.Request) (string, string) {
+	return func(r *http.Request) (string, string) {
+		tenant := tenantFunc(r)
+		if tenant == "" {
+			return "", ""
+		}
+		return tenant, datasets[tenant]
+	}
+}
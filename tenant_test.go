@@ -0,0 +1,59 @@
+package beeline
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/wrappers/common"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantFromSubdomain(t *testing.T) {
+	for host, want := range map[string]string{
+		"acme.example.com":      "acme",
+		"ACME.example.com:8080": "acme",
+		"example.com":           "",
+		"localhost:8080":        "",
+		"10.0.0.1:80":           "",
+		"[::1]:80":              "",
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		assert.Equal(t, want, TenantFromSubdomain(req), host)
+	}
+}
+
+func TestTenantRouting(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{
+		Client:         client,
+		TenantFunc:     TenantFromHeader("X-Tenant-ID"),
+		TenantDatasets: map[string]string{"acme": "acme"},
+	})
+	defer setupLibhoney(t)
+
+	for _, tenant := range []string{"acme", "initech", ""} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Tenant-ID", tenant)
+		_, span := common.StartSpanOrTraceFromHTTP(req)
+		span.Send()
+	}
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		assert.Equal(t, "acme", evs[0].Dataset)
+		assert.Equal(t, "acme", evs[0].Data["tenant"])
+		assert.Equal(t, "placeholder", evs[1].Dataset)
+		assert.Equal(t, "initech", evs[1].Data["tenant"])
+		assert.NotContains(t, evs[2].Data, "tenant")
+	}
+}
@@ -13,19 +13,32 @@ type Timer interface {
 	Finish() float64
 }
 
+// DurationTimer is a Timer that can also report the elapsed time as a
+// time.Duration, keeping full nanosecond precision. Timers returned by New and
+// Start implement it.
+type DurationTimer interface {
+	Timer
+	// FinishDuration calculates the time since the timer was started
+	FinishDuration() time.Duration
+}
+
 // timer gives you an object to pass around for timing your code
 type timer struct {
 	start time.Time
 }
 
-// New creates a new timer with an arbitrary starting time
+// New creates a new timer with an arbitrary starting time. Durations are only
+// immune to wall clock changes (eg NTP adjustments) when t carries a monotonic
+// clock reading, which is the case for times returned by time.Now. Otherwise
+// the wall clock is used.
 func New(t time.Time) Timer {
 	return &timer{
 		start: t,
 	}
 }
 
-// Start creates a new timer using `time.Now()` as the starting time
+// Start creates a new timer using `time.Now()` as the starting time. Durations
+// are measured using the monotonic clock.
 func Start() Timer {
 	return &timer{
 		start: time.Now(),
@@ -35,8 +48,16 @@ func Start() Timer {
 // Finish closes off a started timer. It returns the duration timed in
 // milliseconds. Will return zero for timers that were never started.
 func (t timer) Finish() float64 {
+	return float64(t.FinishDuration()) / float64(time.Millisecond)
+}
+
+// FinishDuration closes off a started timer. It returns the duration timed
+// with nanosecond precision. time.Since saturates rather than overflowing, so
+// very long durations are capped instead of wrapping negative. Will return
+// zero for timers that were never started.
+func (t timer) FinishDuration() time.Duration {
 	if t.start.IsZero() {
 		return 0
 	}
-	return float64(time.Since(t.start)) / float64(time.Millisecond)
+	return time.Since(t.start)
 }
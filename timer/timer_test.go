@@ -2,7 +2,10 @@ package timer
 
 import (
 	"fmt"
+	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 // Example of combining a timer with a defer to make it easy to put all your
@@ -31,3 +34,27 @@ func Example_otherTime() {
 	dur := t.Finish()
 	fmt.Printf("log my duration as %g\n", dur)
 }
+
+func TestFinishDuration(t *testing.T) {
+	tm, ok := Start().(DurationTimer)
+	if assert.True(t, ok, "timers from Start should report durations") {
+		time.Sleep(time.Millisecond)
+		dur := tm.FinishDuration()
+		assert.True(t, dur >= time.Millisecond, "duration should cover the sleep")
+		assert.True(t, tm.Finish() >= 1, "milliseconds should cover the sleep")
+	}
+
+	var zero timer
+	assert.Equal(t, time.Duration(0), zero.FinishDuration(), "unstarted timers should finish at zero")
+	assert.Equal(t, float64(0), zero.Finish(), "unstarted timers should finish at zero")
+}
+
+func TestSubMillisecondPrecision(t *testing.T) {
+	tm := New(time.Now().Add(-1500 * time.Nanosecond))
+	dur := tm.Finish()
+	assert.True(t, dur > 0, "sub-millisecond durations should not round to zero")
+	assert.True(t, dur < 1000, "duration should be in milliseconds")
+	ns := tm.(DurationTimer).FinishDuration()
+	assert.True(t, ns >= 1500*time.Nanosecond, "durations should keep nanosecond precision")
+	assert.True(t, ns < time.Second)
+}
@@ -0,0 +1,82 @@
+package beeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// Time runs f and adds the time it took to the current span as
+// `app.<name>.duration_ms`. If the same name is timed more than once on a span,
+// the durations are summed, and the total for the whole trace is added to the
+// root span as `rollup.app.<name>.duration_ms`. f is always run, even when
+// there is no span in ctx.
+func Time(ctx context.Context, name string, f func()) {
+	std.Time(ctx, name, f)
+}
+
+// Time runs f and adds the time it took to the current span, as Time does.
+func (b *Beeline) Time(ctx context.Context, name string, f func()) {
+	stop := b.StartTimer(ctx, name)
+	defer stop()
+	f()
+}
+
+// TimeSpan runs f in a new child span named name, which is sent when f
+// returns. f is passed a context holding the new span, so any spans it starts
+// are children of it.
+func TimeSpan(ctx context.Context, name string, f func(ctx context.Context)) {
+	std.TimeSpan(ctx, name, f)
+}
+
+// TimeSpan runs f in a new span named name, as TimeSpan does. If ctx has no
+// span, the span starts a new trace sent by b.
+func (b *Beeline) TimeSpan(ctx context.Context, name string, f func(ctx context.Context)) {
+	ctx, span := b.StartSpan(ctx, name)
+	defer span.Send()
+	f(ctx)
+}
+
+// WrapFunc runs f in a new child span named name, like TimeSpan, and records
+// the error f returns, if any, on the span as AddError does. It returns f's
+// error, so it can wrap a call without changing how failures are handled:
+//
+//	err := beeline.WrapFunc(ctx, "render_template", func(ctx context.Context) error {
+//		return tmpl.Execute(w, data)
+//	})
+func WrapFunc(ctx context.Context, name string, f func(ctx context.Context) error) error {
+	return std.WrapFunc(ctx, name, f)
+}
+
+// WrapFunc runs f in a new span named name and records its error, as
+// WrapFunc does. If ctx has no span, the span starts a new trace sent by b.
+func (b *Beeline) WrapFunc(ctx context.Context, name string, f func(ctx context.Context) error) error {
+	ctx, span := b.StartSpan(ctx, name)
+	defer span.Send()
+	err := f(ctx)
+	if err != nil {
+		span.AddError(err)
+	}
+	return err
+}
+
+// StartTimer starts timing name and returns a function that stops the timer
+// and records the duration like Time does. It is meant to be deferred:
+//
+//	defer beeline.StartTimer(ctx, "image.resize")()
+func StartTimer(ctx context.Context, name string) func() {
+	return std.StartTimer(ctx, name)
+}
+
+// StartTimer starts timing name, as StartTimer does.
+func (b *Beeline) StartTimer(ctx context.Context, name string) func() {
+	span := trace.GetSpanFromContext(ctx)
+	start := time.Now()
+	return func() {
+		if span == nil {
+			return
+		}
+		span.AddRollupField("app."+name+".duration_ms", float64(time.Since(start))/float64(time.Millisecond))
+	}
+}
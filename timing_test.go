@@ -0,0 +1,81 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTime(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := StartSpan(context.Background(), "request")
+	ran := 0
+	for i := 0; i < 2; i++ {
+		Time(ctx, "image.resize", func() {
+			ran++
+			time.Sleep(5 * time.Millisecond)
+		})
+	}
+	func() {
+		defer StartTimer(ctx, "render")()
+	}()
+	span.Send()
+	assert.Equal(t, 2, ran)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0].Data
+		dur, ok := fields["app.image.resize.duration_ms"].(float64)
+		assert.True(t, ok)
+		assert.True(t, dur >= 10, "durations should be summed, got %v", dur)
+		assert.Equal(t, dur, fields["rollup.app.image.resize.duration_ms"])
+		assert.Contains(t, fields, "app.render.duration_ms")
+	}
+}
+
+func TestTimeWithoutSpan(t *testing.T) {
+	setupLibhoney(t)
+	ran := false
+	Time(context.Background(), "work", func() { ran = true })
+	assert.True(t, ran)
+}
+
+func TestTimeSpan(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := StartSpan(context.Background(), "request")
+	TimeSpan(ctx, "resize", func(ctx context.Context) {
+		AddField(ctx, "width", 100)
+	})
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "resize", evs[0].Data["name"])
+		assert.Equal(t, 100, evs[0].Data["app.width"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], evs[0].Data["trace.parent_id"])
+	}
+}
+
+func TestWrapFunc(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := StartSpan(context.Background(), "request")
+	assert.NoError(t, WrapFunc(ctx, "render_template", func(ctx context.Context) error {
+		return nil
+	}))
+	err := WrapFunc(ctx, "fetch", func(ctx context.Context) error {
+		return assert.AnError
+	})
+	assert.Equal(t, assert.AnError, err)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		assert.Equal(t, "render_template", evs[0].Data["name"])
+		assert.NotContains(t, evs[0].Data, "error")
+		assert.Equal(t, "fetch", evs[1].Data["name"])
+		assert.Equal(t, assert.AnError.Error(), evs[1].Data["error"])
+		assert.Equal(t, evs[2].Data["trace.span_id"], evs[1].Data["trace.parent_id"])
+	}
+}
@@ -3,11 +3,15 @@ package trace
 import (
 	"context"
 	"errors"
+
+	libhoney "github.com/honeycombio/libhoney-go"
 )
 
 const (
-	honeySpanContextKey  = "honeycombSpanContextKey"
-	honeyTraceContextKey = "honeycombTraceContextKey"
+	honeySpanContextKey   = "honeycombSpanContextKey"
+	honeyTraceContextKey  = "honeycombTraceContextKey"
+	honeyClientContextKey = "honeycombClientContextKey"
+	honeyConfigContextKey = "honeycombConfigContextKey"
 )
 
 var (
@@ -56,6 +60,55 @@ func PutSpanInContext(ctx context.Context, span *Span) context.Context {
 	return context.WithValue(ctx, honeySpanContextKey, span)
 }
 
+// GetClientFromContext returns the client put in the context by
+// PutClientInContext, or nil if there is none.
+func GetClientFromContext(ctx context.Context) *libhoney.Client {
+	if ctx != nil {
+		if c, ok := ctx.Value(honeyClientContextKey).(*libhoney.Client); ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// PutClientInContext returns a context that makes traces started with it
+// send their spans with c rather than the client set with client.Set, so
+// that they go to c's write key and dataset. Traces already in the context,
+// and their spans, carry on with the client they were started with.
+func PutClientInContext(ctx context.Context, c *libhoney.Client) context.Context {
+	return context.WithValue(ctx, honeyClientContextKey, c)
+}
+
+// GetConfigFromContext returns the config of the trace in the context, or
+// if there is none the config put in it by PutConfigInContext, or
+// GlobalConfig. The HTTP wrappers use it to find the settings to record
+// requests with.
+func GetConfigFromContext(ctx context.Context) *Config {
+	if tr := GetTraceFromContext(ctx); tr != nil {
+		return tr.cfg()
+	}
+	return configPutInContext(ctx)
+}
+
+// configPutInContext returns the config put in ctx by PutConfigInContext, or
+// GlobalConfig.
+func configPutInContext(ctx context.Context) *Config {
+	if ctx != nil {
+		if c, ok := ctx.Value(honeyConfigContextKey).(*Config); ok {
+			return c
+		}
+	}
+	return &GlobalConfig
+}
+
+// PutConfigInContext returns a context that makes traces started with it
+// use c rather than GlobalConfig for their sampling, hooks and other
+// settings. Traces already in the context, and their spans, carry on with the
+// config they were started with.
+func PutConfigInContext(ctx context.Context, c *Config) context.Context {
+	return context.WithValue(ctx, honeyConfigContextKey, c)
+}
+
 // CopyContext takes a context that has a beeline trace and one that doesn't. It
 // copies all the bits necessary to continue the trace from one to the other.
 // This is useful if you need to break context to launch a goroutine that
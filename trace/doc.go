@@ -132,4 +132,11 @@
 // (`meta.sent_by_parent`) added to indicate that they were unsent. Sending
 // unsent spans is likely indicative of either an opportunity to use an async
 // span or a bug in the program where a span accidentally does not get sent.
+//
+// Async spans are not sent by their parent, and may finish after it. Those
+// that do, and any span started from a parent that had already been sent,
+// get `meta.sent_after_parent` so that stragglers, such as work left running
+// after the request it was for was cancelled, can be found. Their rollup
+// fields are recorded on their own span, but once the root span has been
+// sent they are no longer added to the trace's totals.
 package trace
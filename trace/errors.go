@@ -0,0 +1,58 @@
+package trace
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// wrapper is implemented by errors that wrap another error, as understood by
+// errors.Unwrap.
+type wrapper interface {
+	Unwrap() error
+}
+
+// AddError records err on the span in a consistent shape: its message in
+// `error`, its Go type in `error.type` and, for errors that wrap others, the
+// types of the whole chain (outermost first) in `error.chain` and the message
+// of the innermost error in `error.cause`. When Config.RecordErrorStacks is
+// set the stack of the goroutine calling AddError is added as `error.stack`.
+// A nil error adds nothing.
+func (s *Span) AddError(err error) {
+	if err == nil {
+		return
+	}
+	s.AddField("error", err.Error())
+	s.AddField("error.type", fmt.Sprintf("%T", err))
+
+	chain := []string{fmt.Sprintf("%T", err)}
+	cause := err
+	for {
+		w, ok := cause.(wrapper)
+		if !ok {
+			break
+		}
+		next := w.Unwrap()
+		if next == nil {
+			break
+		}
+		cause = next
+		chain = append(chain, fmt.Sprintf("%T", cause))
+	}
+	if len(chain) > 1 {
+		s.AddField("error.chain", chain)
+		s.AddField("error.cause", cause.Error())
+	}
+	if s.cfg().RecordErrorStacks {
+		s.AddField("error.stack", currentStack())
+	}
+}
+
+// currentStack returns the stack of the calling goroutine, cut off at
+// maxStackLength.
+func currentStack() string {
+	stack := debug.Stack()
+	if len(stack) > maxStackLength {
+		stack = stack[:maxStackLength]
+	}
+	return string(stack)
+}
@@ -0,0 +1,55 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string { return e.msg + ": " + e.err.Error() }
+func (e *wrappedError) Unwrap() error { return e.err }
+
+func TestAddError(t *testing.T) {
+	mo := setupLibhoney()
+	_, tr := NewTrace(context.Background(), "")
+	root := tr.GetRootSpan()
+	root.AddError(nil)
+	root.AddError(&wrappedError{msg: "loading user", err: errors.New("connection refused")})
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "loading user: connection refused", fields["error"])
+		assert.Equal(t, "*trace.wrappedError", fields["error.type"])
+		assert.Equal(t, []string{"*trace.wrappedError", "*errors.errorString"}, fields["error.chain"])
+		assert.Equal(t, "connection refused", fields["error.cause"])
+		assert.NotContains(t, fields, "error.stack", "stacks should only be recorded when configured")
+	}
+}
+
+func TestAddErrorStack(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.RecordErrorStacks = true
+	defer func() { GlobalConfig.RecordErrorStacks = false }()
+
+	_, tr := NewTrace(context.Background(), "")
+	root := tr.GetRootSpan()
+	root.AddError(errors.New("boom"))
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "*errors.errorString", fields["error.type"])
+		assert.NotContains(t, fields, "error.chain", "unwrapped errors have no chain")
+		assert.Contains(t, fields["error.stack"], "TestAddErrorStack")
+	}
+}
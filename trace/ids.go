@@ -0,0 +1,170 @@
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	mrand "math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/honeycombio/beeline-go/propagation"
+)
+
+// IDGenerator makes the IDs of new traces and spans. Set one with
+// `beeline.Config.IDGenerator` to use an ID scheme of your own; IDs must be
+// unique, and are sent to other services in trace headers, so should be
+// printable ASCII. The default makes random 16 byte trace IDs and 8 byte span
+// IDs, hex encoded, as the other beelines do.
+type IDGenerator interface {
+	NewTraceID() string
+	NewSpanID() string
+}
+
+// IDFormat chooses the format of the IDs the beeline makes for new traces.
+type IDFormat string
+
+const (
+	// IDFormatHex makes random 128 bit trace IDs, hex encoded as 32
+	// characters. It is the default.
+	IDFormatHex IDFormat = "hex"
+	// IDFormatHex64 makes random 64 bit trace IDs, hex encoded as 16
+	// characters, for services tracing with systems such as Zipkin or Jaeger
+	// configured for 64 bit IDs. They are padded with zeros to 32 characters
+	// in W3C trace headers, and the zeros are taken off again when they are
+	// parsed.
+	IDFormatHex64 IDFormat = "hex64"
+	// IDFormatUUID makes random version 4 UUID trace IDs, with dashes, as
+	// older beelines did, for services joining traces with those that still
+	// make them. The dashes are left out of W3C and B3 trace headers, and put
+	// back in the 128 bit trace IDs parsed from them.
+	IDFormatUUID IDFormat = "uuid"
+)
+
+// randomIDs is the default IDGenerator.
+type randomIDs struct{}
+
+func (randomIDs) NewTraceID() string { return getNewID(traceIDLengthBytes) }
+func (randomIDs) NewSpanID() string  { return getNewID(spanIDLengthBytes) }
+
+// NewTraceID returns an ID for a new trace from GlobalConfig.IDGenerator, or
+// a random one if it isn't set.
+func NewTraceID() string {
+	return GlobalConfig.NewTraceID()
+}
+
+// NewSpanID returns an ID for a new span from GlobalConfig.IDGenerator, or a
+// random one if it isn't set. The wrappers also use it for other IDs they
+// record, such as those of DB transactions.
+func NewSpanID() string {
+	return GlobalConfig.NewSpanID()
+}
+
+// NewTraceID returns an ID for a new trace from c.IDGenerator, or a random
+// one in c.IDFormat if it isn't set.
+func (c *Config) NewTraceID() string {
+	if gen := c.IDGenerator; gen != nil {
+		if id := gen.NewTraceID(); id != "" {
+			return id
+		}
+	}
+	switch c.IDFormat {
+	case IDFormatHex64:
+		return getNewID(spanIDLengthBytes)
+	case IDFormatUUID:
+		return uuidTraceID(getNewID(traceIDLengthBytes))
+	}
+	return getNewID(traceIDLengthBytes)
+}
+
+// uuidTraceID makes a version 4 UUID of a random 32 character hex ID.
+func uuidTraceID(id string) string {
+	b := []byte(id)
+	// the version nibble, and the top two bits of the variant
+	b[12] = '4'
+	b[16] = "89ab"[strings.IndexByte("0123456789abcdef", b[16])&3]
+	return propagation.UUIDTraceID(string(b))
+}
+
+// PropagatedTraceID returns a trace ID parsed from W3C or B3 trace headers
+// in the format set by GlobalConfig.IDFormat, so that IDs made in that
+// format come back unchanged after passing through services that only speak
+// those headers. Under IDFormatUUID dashes are put in 128 bit IDs, and under
+// IDFormatHex64 the zeros padding 64 bit IDs are taken off. IDs in Honeycomb
+// trace headers are used as they are, so don't need it.
+func PropagatedTraceID(id string) string {
+	return GlobalConfig.PropagatedTraceID(id)
+}
+
+// PropagatedTraceID returns a trace ID parsed from W3C or B3 trace headers
+// in the format set by c.IDFormat, as the package level PropagatedTraceID
+// does.
+func (c *Config) PropagatedTraceID(id string) string {
+	switch c.IDFormat {
+	case IDFormatHex64:
+		if len(id) == 32 && strings.Trim(id[:16], "0") == "" {
+			return id[16:]
+		}
+	case IDFormatUUID:
+		return propagation.UUIDTraceID(id)
+	}
+	return id
+}
+
+// NewSpanID returns an ID for a new span from c.IDGenerator, or a random one
+// if it isn't set.
+func (c *Config) NewSpanID() string {
+	if gen := c.IDGenerator; gen != nil {
+		if id := gen.NewSpanID(); id != "" {
+			return id
+		}
+	}
+	return getNewID(spanIDLengthBytes)
+}
+
+// idSources are math/rand sources for IDs, each seeded from crypto/rand.
+// Reading crypto/rand for every span costs a system call, and a single
+// locked source would be contended by concurrent requests, so each goroutine
+// takes a source of its own from the pool while it makes an ID.
+var idSources = sync.Pool{
+	New: func() interface{} {
+		return mrand.New(mrand.NewSource(idSeed()))
+	},
+}
+
+// fallbackSeeds counts the sources seeded without crypto/rand, so that each
+// is seeded differently.
+var fallbackSeeds uint64
+
+// idSeed returns a seed for a new ID source. If crypto/rand can't be read,
+// which should never happen, the seed is made from the time, the process ID
+// and a counter instead, so that sources in this and other processes still
+// make different IDs rather than all starting from the same seed.
+func idSeed() int64 {
+	var seed [8]byte
+	if _, err := rand.Read(seed[:]); err == nil {
+		return int64(binary.LittleEndian.Uint64(seed[:]))
+	}
+	n := atomic.AddUint64(&fallbackSeeds, 1)
+	return time.Now().UnixNano() ^ int64(os.Getpid())<<32 ^ int64(n*0x9e3779b97f4a7c15)
+}
+
+// getNewID generates a lowercase hex encoded string with the specified number
+// of bytes. It is used for ID generation for traces and spans.
+func getNewID(length uint16) string {
+	var buf [traceIDLengthBytes]byte
+	var encodedBuf [2 * traceIDLengthBytes]byte
+	id, encoded := buf[:], encodedBuf[:]
+	if length > traceIDLengthBytes {
+		id, encoded = make([]byte, length), make([]byte, 2*length)
+	}
+	src := idSources.Get().(*mrand.Rand)
+	// reading a math/rand source never fails
+	_, _ = src.Read(id[:length])
+	idSources.Put(src)
+	hex.Encode(encoded, id[:length])
+	return string(encoded[:2*length])
+}
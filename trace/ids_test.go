@@ -0,0 +1,99 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingIDs is an IDGenerator making sequential IDs.
+type countingIDs struct {
+	n uint64
+}
+
+func (c *countingIDs) NewTraceID() string {
+	return fmt.Sprintf("trace-%d", atomic.AddUint64(&c.n, 1))
+}
+
+func (c *countingIDs) NewSpanID() string {
+	return fmt.Sprintf("span-%d", atomic.AddUint64(&c.n, 1))
+}
+
+func TestIDGenerator(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.IDGenerator = &countingIDs{}
+	defer func() { GlobalConfig.IDGenerator = nil }()
+
+	ctx, tr := NewTrace(context.Background(), "")
+	_, span := tr.GetRootSpan().CreateChild(ctx)
+	span.Send()
+	tr.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "trace-1", evs[0].Data["trace.trace_id"])
+		assert.Equal(t, "span-2", evs[1].Data["trace.span_id"])
+		assert.Equal(t, "span-3", evs[0].Data["trace.span_id"])
+		assert.Equal(t, "span-2", evs[0].Data["trace.parent_id"])
+	}
+	assert.Equal(t, "span-4", NewSpanID())
+
+	_, tr = NewTrace(context.Background(), "1;trace_id=upstream,parent_id=parent")
+	assert.Equal(t, "upstream", tr.GetTraceID(), "propagated IDs are kept")
+}
+
+func TestRandomIDsAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := NewSpanID()
+		assert.Equal(t, 2*spanIDLengthBytes, len(id))
+		assert.False(t, seen[id], "duplicate ID %s", id)
+		seen[id] = true
+	}
+	assert.Equal(t, 2*traceIDLengthBytes, len(NewTraceID()))
+	assert.Equal(t, 64, len(getNewID(32)), "longer IDs can be made too")
+	assert.NotEqual(t, idSeed(), idSeed())
+}
+
+func TestIDFormat(t *testing.T) {
+	defer func() { GlobalConfig.IDFormat = "" }()
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	GlobalConfig.IDFormat = IDFormatHex
+	assert.Len(t, NewTraceID(), 32)
+	assert.Len(t, NewSpanID(), 16)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", PropagatedTraceID("0af7651916cd43dd8448eb211c80319c"))
+
+	GlobalConfig.IDFormat = IDFormatHex64
+	assert.Len(t, NewTraceID(), 16)
+	assert.Len(t, NewSpanID(), 16)
+	assert.Equal(t, "64fe8b2a57d3eff7", PropagatedTraceID("000000000000000064fe8b2a57d3eff7"), "padding is taken off")
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", PropagatedTraceID("0af7651916cd43dd8448eb211c80319c"))
+
+	GlobalConfig.IDFormat = IDFormatUUID
+	for i := 0; i < 100; i++ {
+		id := NewTraceID()
+		assert.Regexp(t, uuid, id)
+		assert.Equal(t, id, PropagatedTraceID(propagation.HexTraceID(id)), "UUIDs come back from W3C and B3 headers")
+	}
+	assert.Len(t, NewSpanID(), 16)
+	assert.Equal(t, "64fe8b2a57d3eff7", PropagatedTraceID("64fe8b2a57d3eff7"))
+
+	GlobalConfig.IDGenerator = &countingIDs{}
+	defer func() { GlobalConfig.IDGenerator = nil }()
+	assert.Equal(t, "trace-1", NewTraceID(), "a generator wins over the format")
+}
+
+func BenchmarkGetNewID(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			getNewID(spanIDLengthBytes)
+		}
+	})
+}
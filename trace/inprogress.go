@@ -0,0 +1,121 @@
+package trace
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stopped is set to 1 by StopNewSpans.
+var stopped int32
+
+// StopNewSpans stops spans from being recorded, for a process that is
+// shutting down. Traces and spans started afterwards can be used as usual,
+// but are never sent. Spans already started are unaffected.
+func StopNewSpans() {
+	atomic.StoreInt32(&stopped, 1)
+}
+
+// ResumeNewSpans undoes StopNewSpans.
+func ResumeNewSpans() {
+	atomic.StoreInt32(&stopped, 0)
+}
+
+func newSpansStopped() bool {
+	return atomic.LoadInt32(&stopped) == 1
+}
+
+// openSpans holds the spans created while their trace's Config.TrackOpenSpans
+// was set that haven't been sent yet.
+var openSpans = struct {
+	lock  sync.Mutex
+	spans map[*Span]struct{}
+}{spans: make(map[*Span]struct{})}
+
+func trackSpan(s *Span) {
+	if !s.cfg().TrackOpenSpans {
+		return
+	}
+	s.tracked = true
+	openSpans.lock.Lock()
+	openSpans.spans[s] = struct{}{}
+	openSpans.lock.Unlock()
+}
+
+func untrackSpan(s *Span) {
+	if !s.tracked {
+		return
+	}
+	openSpans.lock.Lock()
+	delete(openSpans.spans, s)
+	openSpans.lock.Unlock()
+}
+
+// SendInProgress sends an event for each span that has been open for longer
+// than age, so that requests that are stuck show up before they finish. Each
+// span is reported once, by an event with the fields the span has so far, its
+// duration so far and `meta.in_progress` set. The event is a child of the
+// span, with a `meta.span_type` of "in_progress", and is sampled and passed
+// to the hooks in its trace's config like a span. Only spans created while
+// Config.TrackOpenSpans is set are reported. It returns the number of
+// spans reported.
+func SendInProgress(age time.Duration) int {
+	var stuck []*Span
+	openSpans.lock.Lock()
+	for s := range openSpans.spans {
+		if !s.reportedInProgress && time.Since(s.started) > age {
+			s.reportedInProgress = true
+			stuck = append(stuck, s)
+		}
+	}
+	openSpans.lock.Unlock()
+
+	for _, s := range stuck {
+		s.sendInProgress()
+	}
+	return len(stuck)
+}
+
+func (s *Span) sendInProgress() {
+	ev := s.trace.builder.NewEvent()
+	s.eventLock.Lock()
+	for k, v := range s.ev.Fields() {
+		ev.AddField(k, v)
+	}
+	s.eventLock.Unlock()
+	for k, v := range s.trace.copyTraceLevelFields() {
+		ev.AddField(k, v)
+	}
+	ev.AddField("duration_ms", float64(time.Since(s.started))/float64(time.Millisecond))
+	ev.AddField("meta.in_progress", true)
+	ev.AddField("meta.span_type", "in_progress")
+	ev.AddField("trace.trace_id", s.trace.traceID)
+	ev.AddField("trace.parent_id", s.spanID)
+	ev.AddField("trace.span_id", s.cfg().NewSpanID())
+	s.trace.sendEvent(ev)
+}
+
+// SendOpenSpans adds fields to every open root and asynchronous span and
+// sends it, which sends their open synchronous children too, so that work
+// interrupted by a shutdown is still recorded. It is meant to be called once
+// the process is shutting down, after StopNewSpans, as the spans may still be
+// in use. Only spans created while Config.TrackOpenSpans is set are
+// sent. It returns the number of spans it sent directly.
+func SendOpenSpans(fields map[string]interface{}) int {
+	var open []*Span
+	openSpans.lock.Lock()
+	for s := range openSpans.spans {
+		if s.isRoot || s.isAsync {
+			open = append(open, s)
+		}
+	}
+	openSpans.lock.Unlock()
+
+	for _, s := range open {
+		for k, v := range fields {
+			s.AddField(k, v)
+		}
+		s.Send()
+	}
+	return len(open)
+}
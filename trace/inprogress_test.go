@@ -0,0 +1,43 @@
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendInProgress(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.TrackOpenSpans = true
+	defer func() { GlobalConfig.TrackOpenSpans = false }()
+
+	ctx, tr := NewTrace(context.Background(), "")
+	tr.AddField("tenant", "acme")
+	root := tr.GetRootSpan()
+	root.AddField("name", "handler")
+	_, child := root.CreateChild(ctx)
+	child.Send()
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Equal(t, 0, SendInProgress(time.Hour), "young spans shouldn't be reported")
+	assert.Equal(t, 1, SendInProgress(time.Millisecond), "only the open span should be reported")
+	assert.Equal(t, 0, SendInProgress(time.Millisecond), "spans should only be reported once")
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		ip := evs[1].Data
+		assert.Equal(t, "handler", ip["name"])
+		assert.Equal(t, "acme", ip["tenant"])
+		assert.Equal(t, true, ip["meta.in_progress"])
+		assert.Equal(t, "in_progress", ip["meta.span_type"])
+		assert.Equal(t, root.spanID, ip["trace.parent_id"])
+		assert.True(t, ip["duration_ms"].(float64) >= 5)
+	}
+
+	root.Send()
+	openSpans.lock.Lock()
+	assert.Equal(t, 0, len(openSpans.spans), "sent spans should no longer be tracked")
+	openSpans.lock.Unlock()
+}
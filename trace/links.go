@@ -0,0 +1,33 @@
+package trace
+
+// AddLink records that the span is related to another span, possibly in
+// another trace, beyond being its parent or child: a batch job span can link
+// to the spans that queued each of the messages it processes, or a request
+// can link to the one that scheduled it. Honeycomb's trace view shows links on
+// their span and lets you follow them to the linked trace. The link is sent as
+// an event with the given fields straight away and is sampled with the rest
+// of the trace. Links without a trace ID, or from spans that aren't recorded,
+// are dropped.
+func (s *Span) AddLink(traceID, spanID string, fields map[string]interface{}) {
+	if traceID == "" {
+		return
+	}
+	ev := s.newAnnotation("link", fields)
+	if ev == nil {
+		return
+	}
+	ev.AddField("trace.link.trace_id", traceID)
+	if spanID != "" {
+		ev.AddField("trace.link.span_id", spanID)
+	}
+	s.trace.sendEvent(ev)
+}
+
+// AddLinkToSpan links the span to other, which may belong to another trace.
+// See AddLink.
+func (s *Span) AddLinkToSpan(other *Span, fields map[string]interface{}) {
+	if other == nil || other.trace == nil {
+		return
+	}
+	s.AddLink(other.trace.traceID, other.spanID, fields)
+}
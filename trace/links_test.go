@@ -0,0 +1,30 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddLink(t *testing.T) {
+	mo := setupLibhoney()
+	_, producer := NewTrace(context.Background(), "")
+	ctx, tr := NewTrace(context.Background(), "")
+	_, span := tr.GetRootSpan().CreateChild(ctx)
+	span.AddLinkToSpan(producer.GetRootSpan(), map[string]interface{}{"message.id": "m1"})
+	span.AddLink("", "ignored", nil)
+	span.Send()
+	tr.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		ev := evs[0].Data
+		assert.Equal(t, "link", ev["meta.annotation_type"])
+		assert.Equal(t, producer.GetTraceID(), ev["trace.link.trace_id"])
+		assert.Equal(t, producer.GetRootSpan().GetSpanID(), ev["trace.link.span_id"])
+		assert.Equal(t, "m1", ev["message.id"])
+		assert.Equal(t, tr.GetTraceID(), ev["trace.trace_id"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], ev["trace.parent_id"])
+	}
+}
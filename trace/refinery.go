@@ -0,0 +1,53 @@
+package trace
+
+import "strconv"
+
+// SampleRateField is the trace level field the sample rate of a trace is
+// passed to downstream services in when the trace's
+// Config.PropagateSampleRate is set. Being a trace level field, it is on every span of the trace too.
+const SampleRateField = "meta.refinery.sample_rate"
+
+// propagateSampleRate makes a new trace keep to the sample rate an upstream
+// service chose for it, so that every service keeps or drops the same traces,
+// or otherwise records the rate the beeline samples at for services
+// downstream. Traces sampled by a SamplerHook have no rate until their spans
+// are sent, so nothing is recorded for them.
+func (t *Trace) propagateSampleRate() {
+	t.tlfLock.Lock()
+	upstream, ok := propagatedSampleRate(t.traceLevelFields[SampleRateField])
+	t.tlfLock.Unlock()
+	if ok {
+		t.SetSampleRate(upstream)
+		return
+	}
+	config := t.cfg()
+	if sampler := config.sampler(); config.SamplerHook == nil && sampler != nil {
+		t.AddField(SampleRateField, sampler.GetSampleRate())
+	}
+}
+
+// propagatedSampleRate reads a sample rate received from upstream, which may
+// have been through JSON, returning false if there isn't a valid one.
+func propagatedSampleRate(val interface{}) (uint, bool) {
+	var rate float64
+	switch v := val.(type) {
+	case float64:
+		rate = v
+	case int:
+		rate = float64(v)
+	case uint:
+		rate = float64(v)
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		rate = f
+	default:
+		return 0, false
+	}
+	if rate < 1 || rate > float64(^uint32(0)) {
+		return 0, false
+	}
+	return uint(rate), true
+}
@@ -0,0 +1,64 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropagateSampleRate(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.PropagateSampleRate = true
+	defer func() {
+		GlobalConfig.PropagateSampleRate = false
+		sample.GlobalSampler = nil
+	}()
+
+	// upstream samples at 1 in 4
+	sample.GlobalSampler, _ = sample.NewDeterministicSampler(4)
+	_, upstream := NewTrace(context.Background(), "")
+	header := upstream.GetRootSpan().SerializeHeaders()
+	upstream.GetRootSpan().Send()
+
+	// downstream is configured to keep everything, but keeps to upstream's rate
+	sample.GlobalSampler, _ = sample.NewDeterministicSampler(1)
+	_, downstream := NewTraceFromSerializedHeaders(context.Background(), header)
+	if assert.NotNil(t, downstream.getSampler()) {
+		assert.Equal(t, 4, downstream.getSampler().GetSampleRate())
+	}
+	downstream.GetRootSpan().Send()
+
+	evs := mo.Events()
+	for _, ev := range evs {
+		assert.Equal(t, uint(4), ev.SampleRate)
+		assert.EqualValues(t, 4, ev.Data[SampleRateField])
+	}
+	assert.True(t, len(evs) == 0 || len(evs) == 2, "both services should make the same decision")
+
+	// a rate set on the trace is passed on too
+	_, tr := NewTrace(context.Background(), "")
+	tr.SetSampleRate(20)
+	assert.Equal(t, uint(20), tr.getTraceLevelFields()[SampleRateField])
+}
+
+func TestPropagatedSampleRate(t *testing.T) {
+	for _, tt := range []struct {
+		val  interface{}
+		rate uint
+		ok   bool
+	}{
+		{float64(10), 10, true},
+		{3, 3, true},
+		{"7", 7, true},
+		{float64(0), 0, false},
+		{"lots", 0, false},
+		{nil, 0, false},
+		{float64(1e20), 0, false},
+	} {
+		rate, ok := propagatedSampleRate(tt.val)
+		assert.Equal(t, tt.rate, rate, "%v", tt.val)
+		assert.Equal(t, tt.ok, ok, "%v", tt.val)
+	}
+}
@@ -0,0 +1,74 @@
+package trace
+
+import (
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+)
+
+// AddSpanEvent sends an event marking something that happened at a point in
+// time during the span, such as a log line or a retry. Honeycomb shows span
+// events on their span in the trace view. The event is named name, has the
+// given fields and the trace level fields, and is sent straight away; it is
+// sampled with the rest of the trace. Nothing is sent for spans that aren't
+// recorded.
+func (s *Span) AddSpanEvent(name string, fields map[string]interface{}) {
+	ev := s.newAnnotation("span_event", fields)
+	if ev == nil {
+		return
+	}
+	ev.AddField("name", name)
+	ev.AddField("duration_ms", 0)
+	s.trace.sendEvent(ev)
+}
+
+// newAnnotation returns an event attached to the span for Honeycomb to show
+// with it rather than as a span of its own, with the given fields, the trace
+// level fields and the annotation type. It returns nil for spans that aren't
+// recorded.
+func (s *Span) newAnnotation(annotationType string, fields map[string]interface{}) *libhoney.Event {
+	parentID := s.spanID
+	if s.ev == nil {
+		if s.aggregate == nil {
+			return nil
+		}
+		// spans over the span tree limits are reported by their placeholder
+		parentID = s.aggregate.spanID
+	}
+	ev := s.trace.builder.NewEvent()
+	ev.Timestamp = time.Now()
+	for k, v := range fields {
+		ev.AddField(k, v)
+	}
+	for k, v := range s.trace.copyTraceLevelFields() {
+		ev.AddField(k, v)
+	}
+	ev.AddField("meta.annotation_type", annotationType)
+	ev.AddField("trace.trace_id", s.trace.traceID)
+	ev.AddField("trace.parent_id", parentID)
+	return ev
+}
+
+// AddEvent records that something happened at a point in time during the
+// span, such as a cache miss, a retry or a lock being acquired. It's sent as a
+// span event, as AddSpanEvent sends it, unless the trace's
+// Config.SpanEventsAsField is set, in which case it's kept with the span and sent with it in the
+// span_events field: a list of the events' fields, each with its name, its
+// timestamp and offset_ms, the milliseconds since the span started.
+func (s *Span) AddEvent(name string, fields map[string]interface{}) {
+	if !s.cfg().SpanEventsAsField || s.ev == nil {
+		s.AddSpanEvent(name, fields)
+		return
+	}
+	now := time.Now()
+	event := make(map[string]interface{}, len(fields)+3)
+	for k, v := range fields {
+		event[k] = v
+	}
+	event["name"] = name
+	event["timestamp"] = now.UTC().Format(time.RFC3339Nano)
+	event["offset_ms"] = float64(now.Sub(s.started)) / float64(time.Millisecond)
+	s.spanEventsLock.Lock()
+	s.spanEvents = append(s.spanEvents, event)
+	s.spanEventsLock.Unlock()
+}
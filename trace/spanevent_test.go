@@ -0,0 +1,72 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddSpanEvent(t *testing.T) {
+	mo := setupLibhoney()
+	ctx, tr := NewTrace(context.Background(), "")
+	tr.AddField("tenant", "acme")
+	_, span := tr.GetRootSpan().CreateChild(ctx)
+	span.AddSpanEvent("retry", map[string]interface{}{"attempt": 2})
+	span.Send()
+	tr.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		ev := evs[0].Data
+		assert.Equal(t, "retry", ev["name"])
+		assert.Equal(t, 2, ev["attempt"])
+		assert.Equal(t, "acme", ev["tenant"])
+		assert.Equal(t, "span_event", ev["meta.annotation_type"])
+		assert.Equal(t, evs[1].Data["trace.trace_id"], ev["trace.trace_id"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], ev["trace.parent_id"])
+		assert.NotContains(t, ev, "trace.span_id")
+	}
+
+	StopNewSpans()
+	defer ResumeNewSpans()
+	_, tr = NewTrace(context.Background(), "")
+	tr.GetRootSpan().AddSpanEvent("ignored", nil)
+	assert.Equal(t, 3, len(mo.Events()), "unrecorded spans shouldn't send span events")
+}
+
+func TestAddEvent(t *testing.T) {
+	mo := setupLibhoney()
+	ctx, tr := NewTrace(context.Background(), "")
+	_, span := tr.GetRootSpan().CreateChild(ctx)
+	span.AddEvent("cache_miss", map[string]interface{}{"key": "user:1"})
+	span.Send()
+	tr.Send()
+	if assert.Equal(t, 3, len(mo.Events())) {
+		assert.Equal(t, "span_event", mo.Events()[0].Data["meta.annotation_type"])
+		assert.Equal(t, "cache_miss", mo.Events()[0].Data["name"])
+	}
+
+	GlobalConfig.SpanEventsAsField = true
+	defer func() { GlobalConfig.SpanEventsAsField = false }()
+	mo = setupLibhoney()
+	ctx, tr = NewTrace(context.Background(), "")
+	_, span = tr.GetRootSpan().CreateChild(ctx)
+	span.AddEvent("cache_miss", map[string]interface{}{"key": "user:1"})
+	span.AddEvent("retry", nil)
+	span.Send()
+	tr.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		events, ok := evs[0].Data["span_events"].([]map[string]interface{})
+		if assert.True(t, ok) && assert.Equal(t, 2, len(events)) {
+			assert.Equal(t, "cache_miss", events[0]["name"])
+			assert.Equal(t, "user:1", events[0]["key"])
+			assert.Contains(t, events[0], "timestamp")
+			assert.Contains(t, events[0], "offset_ms")
+			assert.Equal(t, "retry", events[1]["name"])
+		}
+		assert.NotContains(t, evs[1].Data, "span_events")
+	}
+}
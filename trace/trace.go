@@ -2,9 +2,12 @@ package trace
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/honeycombio/beeline-go/client"
@@ -16,19 +19,191 @@ import (
 const (
 	traceIDLengthBytes = 16
 	spanIDLengthBytes  = 8
+
+	// w3cSampledFlag is the W3C trace flag saying the caller may have
+	// recorded the trace. Sampling happens as spans are sent, so it is always
+	// passed on.
+	w3cSampledFlag = 0x01
+
+	// maxPropagatedIDLength bounds the trace and parent IDs accepted from
+	// upstream services. IDs come from request headers and so are attacker
+	// controlled; longer IDs are truncated.
+	maxPropagatedIDLength = 64
+	// maxRecordedIDLength bounds how much of a rejected or truncated ID is
+	// recorded on the root span for debugging.
+	maxRecordedIDLength = 256
+	// maxStackLength bounds the stack traces recorded on spans for panics
+	// and errors. Deep stacks are cut off at the bottom.
+	maxStackLength = 8192
+
+	// defaultMaxRollupFields is the number of distinct rollup keys a trace
+	// will track when Config.MaxRollupFields is not set.
+	defaultMaxRollupFields = 100
+	// RollupOverflowField is the rollup key that values are summed into once a
+	// trace has reached its limit of distinct rollup keys.
+	RollupOverflowField = "other"
 )
 
+// spanTypeValues holds each meta.span_type already converted to an
+// interface{}, so that adding it to every span sent doesn't allocate.
+var spanTypeValues = map[string]interface{}{
+	"root":    "root",
+	"subroot": "subroot",
+	"async":   "async",
+	"leaf":    "leaf",
+	"mid":     "mid",
+}
+
+// GlobalConfig is the config of traces started without one in their
+// context. beeline.Init sets it up.
 var GlobalConfig Config
 
+// Config holds the settings traces are sent with. Each trace keeps the config
+// it was started with, as put in its context by PutConfigInContext, or
+// GlobalConfig.
 type Config struct {
 	// SamplerHook is a function to manage sampling on this trace. See the docs
 	// for `beeline.Config` for a full description.
 	SamplerHook func(map[string]interface{}) (bool, int)
+	// Sampler samples spans by their trace ID when there is no SamplerHook.
+	// If it isn't set, sample.GlobalSampler is used.
+	Sampler *sample.DeterministicSampler
 	// PresendHook is a function to mutate spans just before they are sent to
 	// Honeycomb. See the docs for `beeline.Config` for a full description.
 	PresendHook func(map[string]interface{})
+	// RateLimitHook is called with the fields of every event that sampling
+	// kept, and returns false for those to drop to keep within the route's
+	// rate limit. See the docs for `beeline.Config.MaxEventsPerSecondPerRoute`
+	// for a full description.
+	RateLimitHook func(map[string]interface{}) bool
+	// SendDecisionHook, if set, is called for every event once sampling and
+	// rate limiting have decided whether it is sent, with that decision. The
+	// beeline uses it to count the events sampled out.
+	SendDecisionHook func(kept bool)
+	// RecordDurationNanos adds an integer duration_ns field to every span. See
+	// the docs for `beeline.Config` for a full description.
+	RecordDurationNanos bool
+	// DBCallerPackages lists packages, besides the beeline's own DB wrappers,
+	// that are passed over when naming the function that made a DB call. See
+	// the docs for `beeline.Config` for a full description.
+	DBCallerPackages []string
+	// IgnorePropagatedIDs stops new traces from adopting the trace and parent
+	// IDs of an upstream service. See the docs for `beeline.Config` for a full
+	// description.
+	IgnorePropagatedIDs bool
+	// MaxRollupFields limits the number of distinct rollup keys tracked per
+	// trace. See the docs for `beeline.Config` for a full description.
+	MaxRollupFields int
+	// RollupDimensions lists span fields whose values spans are totaled by on
+	// the root span. See the docs for `beeline.Config` for a full description.
+	RollupDimensions []string
+	// MaxSpanDepth and MaxChildrenPerSpan limit the shape of the span tree,
+	// aggregating spans over the limits into a placeholder span. See the docs
+	// for `beeline.Config` for a full description.
+	MaxSpanDepth       int
+	MaxChildrenPerSpan int
+	// TrackOpenSpans keeps a list of the spans that haven't been sent yet, so
+	// that SendInProgress and SendOpenSpans can find them.
+	TrackOpenSpans bool
+	// FieldHooks are run, in order, on the fields of every span just before it
+	// is sent, ahead of the SamplerHook. The beeline uses them to derive fields
+	// such as SLO results from the finished span, so that sampling can see
+	// them, and to run the user's DerivedFields. They may add fields to the map.
+	FieldHooks []func(map[string]interface{})
+	// TenantHook is called by the HTTP wrappers with each request that starts
+	// a trace, and returns the tenant the request belongs to and the dataset
+	// to send its trace to. See the docs for `beeline.Config.TenantFunc` for a
+	// full description.
+	TenantHook func(r *http.Request) (tenant, dataset string)
+	// TraceFieldsHook is called by the HTTP wrappers' trace fields middleware
+	// with each request, and returns fields to add to its trace. See the docs
+	// for `beeline.Config.TraceFieldsFunc` for a full description.
+	TraceFieldsHook func(r *http.Request) map[string]interface{}
+	// PropagateSampleRate passes the sample rate of each trace to downstream
+	// services, and samples traces from upstream at the rate passed along.
+	// See the docs for `beeline.Config.RefineryCompatible` for a full
+	// description.
+	PropagateSampleRate bool
+	// DatasetHook, if set, is called with the fields of each span and event
+	// that is kept, just before it is sent, and returns the dataset to send
+	// it to, or "" to leave it where it was going. See the docs for
+	// `beeline.Config.DatasetResolver` for a full description.
+	DatasetHook func(map[string]interface{}) string
+	// IgnoreRequestHook is called by the HTTP wrappers with each request, and
+	// returns true for requests that shouldn't be traced at all. See the docs
+	// for `beeline.Config.IgnoreHTTPPaths` for a full description.
+	IgnoreRequestHook func(r *http.Request) bool
+	// B3Format chooses the B3 headers the HTTP wrappers send to downstream
+	// services. See the docs for `beeline.Config` for a full description.
+	B3Format propagation.B3Format
+	// DBQueryMode and OmitDBQueryArgs choose how the DB wrappers record
+	// queries and their arguments. See the docs for `beeline.Config` for a
+	// full description.
+	DBQueryMode     DBQueryMode
+	OmitDBQueryArgs bool
+	// HTTPHeadersToCapture lists the request headers the HTTP wrappers record.
+	// See the docs for `beeline.Config` for a full description.
+	HTTPHeadersToCapture []string
+	// HTTPQueryParamsToCapture and HTTPQueryParamsToOmit choose the query
+	// parameters the HTTP wrappers record. See the docs for `beeline.Config`
+	// for a full description.
+	HTTPQueryParamsToCapture []string
+	HTTPQueryParamsToOmit    []string
+	// HTTPBodyContentTypes, HTTPBodyCaptureBytes and HTTPBodyRedactor choose
+	// the request bodies the HTTP wrappers record the start of. See the docs
+	// for `beeline.Config` for a full description.
+	HTTPBodyContentTypes []string
+	HTTPBodyCaptureBytes int
+	HTTPBodyRedactor     func(contentType string, body []byte) []byte
+	// TrustedProxies and IgnoreClientIPHeaders decide how the HTTP wrappers
+	// find `request.client_ip`. See the docs for `beeline.Config` for a full
+	// description.
+	TrustedProxies        []*net.IPNet
+	IgnoreClientIPHeaders bool
+	// TraceIDResponseHeader, if set, names a response header the HTTP
+	// wrappers set to the trace ID. See the docs for
+	// `beeline.Config.TraceIDResponseHeader` for a full description.
+	TraceIDResponseHeader string
+	// EchoAmazonTraceHeader makes the HTTP wrappers copy the X-Amzn-Trace-Id
+	// request header to the response. See the docs for `beeline.Config` for
+	// a full description.
+	EchoAmazonTraceHeader bool
+	// ParseUserAgents adds request.ua fields parsed from the user agent to
+	// request spans. See the docs for `beeline.Config` for a full
+	// description.
+	ParseUserAgents bool
+	// RecordErrorStacks adds a stack trace to errors recorded with
+	// Span.AddError. See the docs for `beeline.Config` for a full
+	// description.
+	RecordErrorStacks bool
+	// IDGenerator, if set, makes the IDs of new traces and spans in place of
+	// the default random IDs. See the docs for `beeline.Config` for a full
+	// description.
+	IDGenerator IDGenerator
+	// IDFormat is the format of the IDs made for new traces when there is
+	// no IDGenerator. See the docs for `beeline.Config` for a full
+	// description.
+	IDFormat IDFormat
+	// SpanEventsAsField records events added with Span.AddEvent in a field of
+	// their span rather than sending each one. See the docs for
+	// `beeline.Config` for a full description.
+	SpanEventsAsField bool
 }
 
+// DBQueryMode chooses how the DB wrappers record the queries they run.
+type DBQueryMode int
+
+const (
+	// DBQueryRaw records each query as it was sent to the database.
+	DBQueryRaw DBQueryMode = iota
+	// DBQueryNormalized records each query with its literal values replaced
+	// by ?, so queries that differ only in their values are recorded the
+	// same way.
+	DBQueryNormalized
+	// DBQueryOmitted doesn't record queries at all.
+	DBQueryOmitted
+)
+
 // Trace holds some trace level state and the root of the span tree that will be
 // the entire in-process trace. Traces are sent to Honeycomb when the root span
 // is sent. You can send a trace manually, and that will cause all
@@ -40,52 +215,124 @@ type Trace struct {
 	parentID         string
 	rollupFields     map[string]float64
 	rollupLock       sync.Mutex
+	counters         map[string]int64
+	countersLock     sync.Mutex
 	rootSpan         *Span
 	tlfLock          sync.RWMutex
 	traceLevelFields map[string]interface{}
+	// traceState is the W3C tracestate that came with the trace from
+	// upstream, passed on to downstream services.
+	traceState string
+	// sampler, if set, samples the trace in place of the configured sampling
+	sampler     *sample.DeterministicSampler
+	samplerLock sync.RWMutex
+	// config is the config the trace was started with. If it is nil the
+	// trace uses GlobalConfig.
+	config *Config
+}
+
+// GetConfig returns the config the trace is sent with.
+func (t *Trace) GetConfig() *Config {
+	return t.cfg()
+}
+
+func (t *Trace) cfg() *Config {
+	if t.config != nil {
+		return t.config
+	}
+	return &GlobalConfig
 }
 
-// getNewID generates a lowercase hex encoded string with the specified number
-// of bytes. It is used for ID generation for traces and spans.
-func getNewID(length uint16) string {
-	id := make([]byte, length)
-	// rand.Seed is called in libhoney's init, so this is sure to have well-seeded random content.
-	_, _ = rand.Read(id)
-	return hex.EncodeToString(id)
+// sampler returns the sampler used when there is no SamplerHook.
+func (c *Config) sampler() *sample.DeterministicSampler {
+	if c.Sampler != nil {
+		return c.Sampler
+	}
+	return sample.GlobalSampler
 }
 
 // NewTraceFromPropagationContext creates a brand new trace. prop is optional, and if included,
 // should be populated with data from a trace context header.
 func NewTraceFromPropagationContext(ctx context.Context, prop *propagation.PropagationContext) (context.Context, *Trace) {
-	trace := &Trace{
-		builder:          client.NewBuilder(),
-		rollupFields:     make(map[string]float64),
-		traceLevelFields: make(map[string]interface{}),
+	// like the client, the config is the one put in the context rather than
+	// that of any trace already in it
+	trace := &Trace{config: configPutInContext(ctx)}
+	config := trace.cfg()
+	if c := GetClientFromContext(ctx); c != nil {
+		trace.builder = c.NewBuilder()
+	} else {
+		trace.builder = client.NewBuilder()
 	}
 
+	// rootFields is only needed for propagated IDs that had to be changed, so
+	// it is made when first needed, like the trace's own maps
+	var rootFields map[string]interface{}
+	addRootField := func(key string, val interface{}) {
+		if rootFields == nil {
+			rootFields = make(map[string]interface{})
+		}
+		rootFields[key] = val
+	}
 	if prop != nil {
-		trace.traceID = prop.TraceID
-		trace.parentID = prop.ParentID
-		for k, v := range prop.TraceContext {
-			trace.traceLevelFields[k] = v
+		traceID, traceIDOK := sanitizePropagatedID(prop.TraceID)
+		parentID, parentIDOK := sanitizePropagatedID(prop.ParentID)
+		// IDs we changed are recorded quoted so control characters from
+		// upstream never end up in an event verbatim
+		if traceID != prop.TraceID {
+			addRootField("meta.original_trace_id", truncateID(strconv.Quote(prop.TraceID), maxRecordedIDLength))
+		}
+		if parentID != prop.ParentID {
+			addRootField("meta.original_parent_id", truncateID(strconv.Quote(prop.ParentID), maxRecordedIDLength))
+		}
+		switch {
+		case !traceIDOK || !parentIDOK:
+			// don't half-continue a trace we can't make sense of. The header
+			// can't be trusted, so neither can the dataset it asks for.
+		case config.IgnorePropagatedIDs:
+			// upstream isn't trusted to pick the dataset either
+			if traceID != "" {
+				addRootField("trace.upstream_trace_id", traceID)
+			}
+			if parentID != "" {
+				addRootField("trace.upstream_parent_id", parentID)
+			}
+		default:
+			trace.traceID = traceID
+			trace.parentID = parentID
+			if prop.Dataset != "" {
+				trace.builder.Dataset = prop.Dataset
+			}
+			trace.traceState = prop.TraceState
 		}
-		if prop.Dataset != "" {
-			trace.builder.Dataset = prop.Dataset
+		if len(prop.TraceContext) > 0 {
+			trace.traceLevelFields = make(map[string]interface{}, len(prop.TraceContext))
+			for k, v := range prop.TraceContext {
+				trace.traceLevelFields[k] = v
+			}
 		}
 	}
 
 	if trace.traceID == "" {
-		trace.traceID = getNewID(traceIDLengthBytes)
+		trace.traceID = config.NewTraceID()
+	}
+	if config.PropagateSampleRate {
+		trace.propagateSampleRate()
 	}
 
-	rootSpan := newSpan()
+	rootSpan := newSpan(config)
 	rootSpan.isRoot = true
 	if trace.parentID != "" {
 		rootSpan.parentID = trace.parentID
 	}
-	rootSpan.ev = trace.builder.NewEvent()
 	rootSpan.trace = trace
 	trace.rootSpan = rootSpan
+	if !newSpansStopped() {
+		rootSpan.ev = trace.builder.NewEvent()
+		trackSpan(rootSpan)
+	}
+	for k, v := range rootFields {
+		rootSpan.AddField(k, v)
+	}
 
 	// put trace and root span in context
 	ctx = PutTraceInContext(ctx, trace)
@@ -93,6 +340,25 @@ func NewTraceFromPropagationContext(ctx context.Context, prop *propagation.Propa
 	return ctx, trace
 }
 
+// sanitizePropagatedID checks an ID received from an upstream service before it
+// is trusted. IDs containing anything other than printable ASCII are rejected,
+// returning an empty ID and false. Overly long IDs are truncated.
+func sanitizePropagatedID(id string) (string, bool) {
+	for i := 0; i < len(id); i++ {
+		if id[i] < ' ' || id[i] > '~' {
+			return "", false
+		}
+	}
+	return truncateID(id, maxPropagatedIDLength), true
+}
+
+func truncateID(id string, length int) string {
+	if len(id) > length {
+		return id[:length]
+	}
+	return id
+}
+
 // NewTraceFromSerializedHeaders creates a brand new trace. serializedHeaders is optional, and if
 // included, should be the header as written by trace.SerializeHeaders(). When
 // not starting from an upstream trace, pass the empty string here.
@@ -120,8 +386,20 @@ func NewTrace(ctx context.Context, serializedHeaders string) (context.Context, *
 func (t *Trace) AddField(key string, val interface{}) {
 	t.tlfLock.Lock()
 	defer t.tlfLock.Unlock()
-	if t.traceLevelFields != nil {
-		t.traceLevelFields[key] = val
+	if t.traceLevelFields == nil {
+		t.traceLevelFields = make(map[string]interface{})
+	}
+	t.traceLevelFields[key] = val
+}
+
+// SetDataset changes the dataset the trace is sent to, and that downstream
+// services are asked to send their part of it to. It applies to the root span
+// and any spans created after it, so it should be called as soon as the trace
+// is created.
+func (t *Trace) SetDataset(dataset string) {
+	t.builder.Dataset = dataset
+	if t.rootSpan != nil && t.rootSpan.ev != nil {
+		t.rootSpan.ev.Dataset = dataset
 	}
 }
 
@@ -132,51 +410,159 @@ func (t *Trace) AddField(key string, val interface{}) {
 // The serialized form may be passed to NewTrace() in order to create a new
 // trace that will be connected to this trace.
 func (t *Trace) serializeHeaders(spanID string) string {
+	t.tlfLock.RLock()
+	defer t.tlfLock.RUnlock()
+	traceContext := t.traceLevelFields
+	if traceContext == nil {
+		// the header always carries a context object, even an empty one
+		traceContext = map[string]interface{}{}
+	}
 	var prop = &propagation.PropagationContext{
 		TraceID:      t.traceID,
 		ParentID:     spanID,
 		Dataset:      t.builder.Dataset,
-		TraceContext: t.traceLevelFields,
+		TraceContext: traceContext,
 	}
-	t.tlfLock.RLock()
-	defer t.tlfLock.RUnlock()
 	return propagation.MarshalTraceContext(prop)
 }
 
+// propagationContext describes the trace with spanID as the parent, for
+// propagation formats other than the Honeycomb header.
+func (t *Trace) propagationContext(spanID string) *propagation.PropagationContext {
+	return &propagation.PropagationContext{
+		TraceID:      t.traceID,
+		ParentID:     spanID,
+		Dataset:      t.builder.Dataset,
+		TraceContext: t.getTraceLevelFields(),
+		TraceFlags:   w3cSampledFlag,
+		TraceState:   t.traceState,
+	}
+}
+
 // addRollupField is here to let a span contribute a field to the trace while
 // keeping the trace's locks private.
+// Rollups added once the root span has been sent are dropped, as there is
+// nothing left to report them on.
 func (t *Trace) addRollupField(key string, val float64) {
+	if t.rootSpan != nil && atomic.LoadInt32(&t.rootSpan.finished) == 1 {
+		return
+	}
 	t.rollupLock.Lock()
 	defer t.rollupLock.Unlock()
-	if t.rollupFields != nil {
-		t.rollupFields[key] += val
+	if t.rollupFields == nil {
+		t.rollupFields = make(map[string]float64)
+	}
+	addBoundedRollup(t.rollupFields, key, val, t.cfg().MaxRollupFields)
+}
+
+// addBoundedRollup adds val to fields[key]. Once fields holds max distinct
+// keys, or defaultMaxRollupFields if max isn't set, values for any new key
+// are summed into RollupOverflowField instead, so a pathological number of
+// distinct names can't grow the root span without bound.
+func addBoundedRollup(fields map[string]float64, key string, val float64, max int) {
+	if _, ok := fields[key]; !ok {
+		if max <= 0 {
+			max = defaultMaxRollupFields
+		}
+		if len(fields) >= max {
+			key = RollupOverflowField
+		}
 	}
+	fields[key] += val
 }
 
 // getTraceLevelFields is here to let a span retrieve trace level fields to add
 // them to itself just before sending while keeping the trace's locks around
 // that field private.
 func (t *Trace) getTraceLevelFields() map[string]interface{} {
-	t.tlfLock.Lock()
-	defer t.tlfLock.Unlock()
-	// return a copy of trace level fields
-	retVals := make(map[string]interface{})
+	if fields := t.copyTraceLevelFields(); fields != nil {
+		return fields
+	}
+	return make(map[string]interface{})
+}
+
+// copyTraceLevelFields returns a copy of the trace level fields, or nil if
+// there are none, for callers that only range over them.
+func (t *Trace) copyTraceLevelFields() map[string]interface{} {
+	t.tlfLock.RLock()
+	defer t.tlfLock.RUnlock()
+	if len(t.traceLevelFields) == 0 {
+		return nil
+	}
+	retVals := make(map[string]interface{}, len(t.traceLevelFields))
 	for k, v := range t.traceLevelFields {
 		retVals[k] = v
 	}
 	return retVals
 }
 
-func (t *Trace) getRollupFields() map[string]interface{} {
+// getRollupFields returns a copy of the trace's rollup fields, or nil if there
+// are none.
+func (t *Trace) getRollupFields() map[string]float64 {
 	t.rollupLock.Lock()
 	defer t.rollupLock.Unlock()
-	rollupFields := make(map[string]interface{})
+	if len(t.rollupFields) == 0 {
+		return nil
+	}
+	rollupFields := make(map[string]float64, len(t.rollupFields))
 	for k, v := range t.rollupFields {
 		rollupFields[k] = v
 	}
 	return rollupFields
 }
 
+// IncrementCounter adds delta to the named counter. Counters are kept per
+// trace and added to the root span, under their own name, when it is sent.
+// Unlike rollup fields they are integers and aren't added to the spans that
+// incremented them. Counters are bounded like rollup fields: once a trace has
+// Config.MaxRollupFields distinct counters, new ones are summed into
+// RollupOverflowField.
+func (t *Trace) IncrementCounter(key string, delta int64) {
+	t.countersLock.Lock()
+	defer t.countersLock.Unlock()
+	if t.counters == nil {
+		t.counters = make(map[string]int64)
+	}
+	if _, ok := t.counters[key]; !ok {
+		max := t.cfg().MaxRollupFields
+		if max <= 0 {
+			max = defaultMaxRollupFields
+		}
+		if len(t.counters) >= max {
+			key = RollupOverflowField
+		}
+	}
+	t.counters[key] += delta
+}
+
+// GetCounter returns the current value of the named counter.
+func (t *Trace) GetCounter(key string) int64 {
+	t.countersLock.Lock()
+	defer t.countersLock.Unlock()
+	return t.counters[key]
+}
+
+func (t *Trace) getCounters() map[string]int64 {
+	t.countersLock.Lock()
+	defer t.countersLock.Unlock()
+	if len(t.counters) == 0 {
+		return nil
+	}
+	counters := make(map[string]int64, len(t.counters))
+	for k, v := range t.counters {
+		counters[k] = v
+	}
+	return counters
+}
+
+// GetRollupField returns the total so far of a rollup field across every span
+// in the trace, or 0 if it hasn't been added to.
+func (t *Trace) GetRollupField(key string) float64 {
+	t.rollupLock.Lock()
+	defer t.rollupLock.Unlock()
+	return t.rollupFields[key]
+}
+
 // GetRootSpan returns the root of the in-process trace. Sending the root span
 // will send the entire trace to Honeycomb. From the root span you can walk the
 // entire span tree using GetChildren (and recursively calling GetChildren on
@@ -185,6 +571,29 @@ func (t *Trace) GetRootSpan() *Span {
 	return t.rootSpan
 }
 
+// SetSampleRate samples the trace at rate, by its trace ID, in place of the
+// SamplerHook or sample rate the beeline was configured with. Spans already
+// sent aren't affected, so it's best set when the trace starts. A rate of 0
+// goes back to the configured sampling.
+func (t *Trace) SetSampleRate(rate uint) {
+	var sampler *sample.DeterministicSampler
+	if rate > 0 {
+		sampler, _ = sample.NewDeterministicSampler(rate)
+	}
+	t.samplerLock.Lock()
+	t.sampler = sampler
+	t.samplerLock.Unlock()
+	if t.cfg().PropagateSampleRate && rate > 0 {
+		t.AddField(SampleRateField, rate)
+	}
+}
+
+func (t *Trace) getSampler() *sample.DeterministicSampler {
+	t.samplerLock.RLock()
+	defer t.samplerLock.RUnlock()
+	return t.sampler
+}
+
 // GetTraceID returns the ID of the trace
 func (t *Trace) GetTraceID() string {
 	return t.traceID
@@ -196,12 +605,19 @@ func (t *Trace) GetParentID() string {
 }
 
 // Send will finish and send all the synchronous spans in the trace to Honeycomb
+//
+// When deferred directly (`defer tr.Send()`) during a panic, the root span is
+// marked with the panic and sent before the panic continues.
 func (t *Trace) Send() {
-	rs := t.rootSpan
-	if !rs.isSent {
-		rs.Send()
-		// sending the span will also send all its children
+	if r := recover(); r != nil {
+		t.rootSpan.addPanicFields(r)
+		t.rootSpan.Send()
+		panic(r)
 	}
+	// sending the span will also send all its children. Span.Send checks
+	// whether the root was already sent under its own lock, so don't peek at
+	// isSent here.
+	t.rootSpan.Send()
 }
 
 // Span represents a specific task or portion of an application. It has a time
@@ -222,20 +638,80 @@ type Span struct {
 	trace        *Trace
 	eventLock    sync.Mutex
 	sendLock     sync.RWMutex
+	depth        int
+	// childCount counts every child created, sent or not, for
+	// MaxChildrenPerSpan. It is protected by childrenLock.
+	childCount int
+	// overflow is where children over the span tree limits are counted, set
+	// once the first is created. It is protected by childrenLock.
+	overflow *aggregate
+	// aggregate is set on spans over the span tree limits, which have no
+	// event and are counted in it instead, and on the placeholder span that
+	// reports it.
+	aggregate *aggregate
+	// tracked is set on spans added to openSpans when they were created.
+	tracked bool
+	// finished is set, atomically, once the span has been sent, so that its
+	// children and its trace can tell without taking its locks.
+	finished int32
+	// reportedInProgress is set once SendInProgress has reported the span.
+	// It is protected by openSpans' lock.
+	reportedInProgress bool
+	// spanEvents are the events added with AddEvent while the trace's
+	// Config.SpanEventsAsField is set, sent as the span_events field.
+	spanEvents     []map[string]interface{}
+	spanEventsLock sync.Mutex
+}
+
+// aggregate totals the spans below one parent that were over the span tree
+// limits, for its placeholder span to report.
+type aggregate struct {
+	spanID string
+	reason string
+
+	lock       sync.Mutex
+	count      int64
+	durationMS float64
+}
+
+func (a *aggregate) add() {
+	a.lock.Lock()
+	a.count++
+	a.lock.Unlock()
+}
+
+func (a *aggregate) addDuration(dur time.Duration) {
+	a.lock.Lock()
+	a.durationMS += float64(dur) / float64(time.Millisecond)
+	a.lock.Unlock()
+}
+
+func (a *aggregate) totals() (int64, float64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.count, a.durationMS
 }
 
 // newSpan takes care of *some* of the initialization necessary to create a new
 // span. IMPORTANT it is not all of the initialization! It does *not* set parent
 // ID or assign the pointer to the trace that contains this span. See existing
 // uses of this function to get an example of the other things necessary to
-// create a well formed span.
-func newSpan() *Span {
+// create a well formed span. config is the config of the span's trace.
+func newSpan(config *Config) *Span {
 	return &Span{
-		spanID:  getNewID(spanIDLengthBytes),
+		spanID:  config.NewSpanID(),
 		started: time.Now(),
 	}
 }
 
+// cfg returns the config of the span's trace.
+func (s *Span) cfg() *Config {
+	if s.trace != nil {
+		return s.trace.cfg()
+	}
+	return &GlobalConfig
+}
+
 // AddField adds a key/value pair to this span
 func (s *Span) AddField(key string, val interface{}) {
 	// The call to event's AddField is protected by a lock, but this is not always sufficient
@@ -264,7 +740,7 @@ func (s *Span) AddRollupField(key string, val float64) {
 		s.rollupFields = make(map[string]float64)
 	}
 	if s.rollupFields != nil {
-		s.rollupFields[key] += val
+		addBoundedRollup(s.rollupFields, key, val, s.cfg().MaxRollupFields)
 	}
 }
 
@@ -283,7 +759,23 @@ func (s *Span) AddTraceField(key string, val interface{}) {
 // span to Honeycomb. Sending a span also triggers sending all synchronous
 // child spans - in other words, if any synchronous child span has not yet been
 // sent, sending the parent will finish and send the children as well.
+//
+// When deferred directly (`defer span.Send()`) during a panic, the span gets
+// an `error` field of "panic", the recovered value in `panic.value` and the
+// stack trace in `panic.stack`. It is sent and then the panic continues with
+// the same value, so any recovery middleware further up the stack still sees
+// it.
 func (s *Span) Send() {
+	if r := recover(); r != nil {
+		s.addPanicFields(r)
+		s.sendOnce()
+		panic(r)
+	}
+	s.sendOnce()
+}
+
+// sendOnce sends the span unless it has already been sent.
+func (s *Span) sendOnce() {
 	s.sendLock.Lock()
 	defer s.sendLock.Unlock()
 	// don't send already sent spans
@@ -294,6 +786,30 @@ func (s *Span) Send() {
 	s.sendLocked()
 }
 
+// Discard drops the span without sending it, for a span started for work
+// that turned out not to happen. A discarded span is never sent, even by its
+// parent. Its children are unaffected.
+func (s *Span) Discard() {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+	if s.isSent {
+		return
+	}
+	s.isSent = true
+	untrackSpan(s)
+	if s.parent != nil {
+		s.parent.removeChildSpan(s)
+	}
+}
+
+// addPanicFields records a value recovered from a panic on the span, along
+// with the stack of the goroutine that panicked.
+func (s *Span) addPanicFields(r interface{}) {
+	s.AddField("error", "panic")
+	s.AddField("panic.value", fmt.Sprint(r))
+	s.AddField("panic.stack", currentStack())
+}
+
 func (s *Span) sendByParent() {
 	s.sendLock.Lock()
 	defer s.sendLock.Unlock()
@@ -308,25 +824,46 @@ func (s *Span) sendByParent() {
 
 func (s *Span) sendLocked() {
 	if s.ev == nil {
+		if s.aggregate != nil {
+			s.aggregate.addDuration(time.Since(s.started))
+			s.isSent = true
+		}
 		return
 	}
+	if s.aggregate != nil {
+		count, dur := s.aggregate.totals()
+		s.AddField("meta.aggregated_count", count)
+		s.AddField("meta.aggregated_duration_ms", dur)
+		s.AddField("meta.aggregated_reason", s.aggregate.reason)
+	}
 	// finish the timer for this span
 	if !s.started.IsZero() {
-		dur := float64(time.Since(s.started)) / float64(time.Millisecond)
-		s.AddField("duration_ms", dur)
+		// started always comes from time.Now, so this is measured on the
+		// monotonic clock
+		dur := time.Since(s.started)
+		s.AddField("duration_ms", float64(dur)/float64(time.Millisecond))
+		if s.cfg().RecordDurationNanos {
+			s.AddField("duration_ns", int64(dur))
+		}
 	}
 	// set trace IDs for this span
-	s.ev.AddField("trace.trace_id", s.trace.traceID)
+	s.AddField("trace.trace_id", s.trace.traceID)
 	if s.parentID != "" {
 		s.AddField("trace.parent_id", s.parentID)
 	}
-	s.ev.AddField("trace.span_id", s.spanID)
+	s.AddField("trace.span_id", s.spanID)
+	if s.parent != nil && atomic.LoadInt32(&s.parent.finished) == 1 {
+		// an async span that outlived its parent, or a span started from a
+		// parent that had already been sent
+		s.AddField("meta.sent_after_parent", true)
+	}
 	// add this span's rollup fields to the event
 	s.rollupLock.Lock()
 	for k, v := range s.rollupFields {
 		s.AddField(k, v)
 	}
 	s.rollupLock.Unlock()
+	s.addDimensionRollups()
 
 	s.childrenLock.Lock()
 	var childrenToSend []*Span
@@ -348,6 +885,8 @@ func (s *Span) sendLocked() {
 
 	s.send()
 	s.isSent = true
+	atomic.StoreInt32(&s.finished, 1)
+	untrackSpan(s)
 
 	// Remove this span from its parent's children list so that it can be GC'd
 	if s.parent != nil {
@@ -356,15 +895,61 @@ func (s *Span) sendLocked() {
 
 }
 
+// addDimensionRollups totals this span's duration on the trace by the value of
+// each field in the trace's Config.RollupDimensions it has, as
+// <field>.<value>.duration_ms and <field>.<value>.count.
+func (s *Span) addDimensionRollups() {
+	if s.trace == nil {
+		return
+	}
+	dims := s.trace.cfg().RollupDimensions
+	if len(dims) == 0 {
+		return
+	}
+	s.eventLock.Lock()
+	fields := s.ev.Fields()
+	values := make([]string, len(dims))
+	for i, dim := range dims {
+		if v, ok := fields[dim]; ok && v != nil {
+			values[i] = fmt.Sprint(v)
+		}
+	}
+	dur, _ := fields["duration_ms"].(float64)
+	s.eventLock.Unlock()
+
+	for i, dim := range dims {
+		if values[i] == "" {
+			continue
+		}
+		prefix := dim + "." + values[i] + "."
+		s.trace.addRollupField(prefix+"duration_ms", dur)
+		s.trace.addRollupField(prefix+"count", 1)
+	}
+}
+
 // IsAsync reveals whether the span is asynchronous (true) or synchronous (false).
 func (s *Span) IsAsync() bool {
 	return s.isAsync
 }
 
+// IsRecording returns true if fields added to the span will be sent: the
+// span is part of a trace that is being recorded and hasn't been sent or
+// discarded yet.
+func (s *Span) IsRecording() bool {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+	return s.ev != nil && !s.isSent
+}
+
 // GetChildren returns a list of all child spans (both synchronous and
-// asynchronous).
+// asynchronous). The returned slice is a copy; children created or sent after
+// the call will not be reflected in it.
 func (s *Span) GetChildren() []*Span {
-	return s.children
+	s.childrenLock.Lock()
+	defer s.childrenLock.Unlock()
+	children := make([]*Span, len(s.children))
+	copy(children, s.children)
+	return children
 }
 
 // Get Parent returns this span's parent.
@@ -400,6 +985,46 @@ func (s *Span) CreateChild(ctx context.Context) (context.Context, *Span) {
 	return s.createChildSpan(ctx, false)
 }
 
+// CreateDetachedChild creates an async child of the current span for work
+// handed to another goroutine that may outlive the span and its trace. The
+// child is the root of a trace of its own in this process, with the same
+// trace ID and dataset, this span as its parent, and a copy of the trace
+// level fields as they are now. Fields added to either trace afterwards
+// don't reach the other, so the two can be used from different goroutines
+// without racing, and nothing the child does touches parts of the trace
+// that may have been sent. The counters incremented in the child's trace go
+// on the child, but its rollups are not reported, as they are for the
+// trace's local root.
+func (s *Span) CreateDetachedChild(ctx context.Context) (context.Context, *Span) {
+	parentID := s.spanID
+	if s.ev == nil && s.aggregate != nil {
+		parentID = s.aggregate.spanID
+	}
+	tr := &Trace{
+		builder:          s.trace.builder.Clone(),
+		traceID:          s.trace.traceID,
+		parentID:         parentID,
+		traceLevelFields: s.trace.copyTraceLevelFields(),
+		traceState:       s.trace.traceState,
+		config:           s.trace.config,
+	}
+	child := newSpan(tr.cfg())
+	child.isRoot = true
+	child.isAsync = true
+	child.parentID = parentID
+	child.depth = s.depth + 1
+	child.trace = tr
+	tr.rootSpan = child
+	// children of spans that aren't recorded aren't recorded either
+	if (s.ev != nil || s.aggregate != nil) && !newSpansStopped() {
+		child.ev = tr.builder.NewEvent()
+		trackSpan(child)
+	}
+	ctx = PutTraceInContext(ctx, tr)
+	ctx = PutSpanInContext(ctx, child)
+	return ctx, child
+}
+
 // SerializeHeaders returns the trace ID, current span ID as parent ID, and an
 // encoded form of all trace level fields. This serialized header is intended to
 // be put in an HTTP (or other protocol) header to transmit to downstream
@@ -407,24 +1032,56 @@ func (s *Span) CreateChild(ctx context.Context) (context.Context, *Span) {
 // The serialized form may be passed to NewTrace() in order to create a new
 // trace that will be connected to this trace.
 func (s *Span) SerializeHeaders() string {
+	if s.ev == nil && s.aggregate != nil {
+		// this span is never sent, so downstream spans belong to the
+		// placeholder that stands in for it
+		return s.trace.serializeHeaders(s.aggregate.spanID)
+	}
 	return s.trace.serializeHeaders(s.spanID)
 }
 
+// PropagationContext returns the trace context to pass to downstream services
+// so that their spans are children of this one, for use with the marshal
+// functions in the propagation package. SerializeHeaders is a shortcut for
+// the Honeycomb header.
+func (s *Span) PropagationContext() *propagation.PropagationContext {
+	if s.ev == nil && s.aggregate != nil {
+		return s.trace.propagationContext(s.aggregate.spanID)
+	}
+	return s.trace.propagationContext(s.spanID)
+}
+
+// NewBuilder returns a builder for events that belong to the trace as
+// children of this span, for code that sends its own events rather than
+// creating spans. Events made with it are sent to the trace's dataset with
+// the trace level fields as they are now, the trace ID, this span as their
+// parent and a span ID of their own. They are not sampled or passed to the
+// hooks in the trace's config, and do not contribute to rollups.
+func (s *Span) NewBuilder() *libhoney.Builder {
+	config := s.trace.cfg()
+	b := s.trace.builder.Clone()
+	for k, v := range s.trace.getTraceLevelFields() {
+		b.AddField(k, v)
+	}
+	b.AddField("trace.trace_id", s.trace.traceID)
+	b.AddField("trace.parent_id", s.spanID)
+	b.AddDynamicField("trace.span_id", func() interface{} {
+		return config.NewSpanID()
+	})
+	return b
+}
+
 // removeChildSpan remove a child which has been sent. It is intended to be
 // called after a child of this span has been sent.
 func (s *Span) removeChildSpan(sentSpan *Span) {
 	s.childrenLock.Lock()
 	defer s.childrenLock.Unlock()
-	var index *int
 	for i, child := range s.children {
-		i := i
 		if child == sentSpan {
-			index = &i
+			s.children = append(s.children[:i], s.children[i+1:]...)
+			return
 		}
 	}
-	if index != nil {
-		s.children = append(s.children[:*index], s.children[*index+1:]...)
-	}
 }
 
 // send gets all the trace level fields and does pre-send hooks, then sends the
@@ -432,7 +1089,7 @@ func (s *Span) removeChildSpan(sentSpan *Span) {
 func (s *Span) send() {
 	// add all the trace level fields to the event as late as possible - when
 	// the trace is all getting sent
-	for k, v := range s.trace.getTraceLevelFields() {
+	for k, v := range s.trace.copyTraceLevelFields() {
 		s.AddField(k, v)
 	}
 
@@ -440,6 +1097,9 @@ func (s *Span) send() {
 	// classify span type
 	var spanType string
 	switch {
+	case s.isRoot && s.isAsync:
+		// the root of a detached child's trace
+		spanType = "async"
 	case s.isRoot:
 		if s.parentID == "" {
 			spanType = "root"
@@ -454,7 +1114,13 @@ func (s *Span) send() {
 		spanType = "mid"
 	}
 	s.childrenLock.Unlock()
-	s.AddField("meta.span_type", spanType)
+	s.AddField("meta.span_type", spanTypeValues[spanType])
+
+	s.spanEventsLock.Lock()
+	if len(s.spanEvents) > 0 {
+		s.AddField("span_events", s.spanEvents)
+	}
+	s.spanEventsLock.Unlock()
 
 	if spanType == "root" {
 		// add the trace's rollup fields to the root span
@@ -462,6 +1128,13 @@ func (s *Span) send() {
 			s.AddField("rollup."+k, v)
 		}
 	}
+	if s.isRoot {
+		// counters go on the root of the in-process trace, even when it
+		// continues a trace from upstream
+		for k, v := range s.trace.getCounters() {
+			s.AddField(k, v)
+		}
+	}
 
 	// Because we hand a raw map over to the Sampler and Presend hooks, it's
 	// possible for the user to modify/iterate over the map in these hooks and
@@ -469,38 +1142,131 @@ func (s *Span) send() {
 	// prevent this from causing an unnecessary panic.
 	s.eventLock.Lock()
 	defer s.eventLock.Unlock()
+	s.trace.sendEvent(s.ev)
+}
+
+// sendEvent sends ev, an event of the trace, with the trace's config and
+// sampler.
+func (t *Trace) sendEvent(ev *libhoney.Event) {
+	t.cfg().sendEvent(ev, t.traceID, t.getSampler())
+}
+
+// sendEvent runs the hooks in c on ev, an event of the trace with traceID,
+// and sends it if it is sampled. If sampler is set it decides instead of the
+// configured sampling.
+func (c *Config) sendEvent(ev *libhoney.Event, traceID string, sampler *sample.DeterministicSampler) {
 	// run hooks
+	for _, hook := range c.FieldHooks {
+		hook(ev.Fields())
+	}
 	var shouldKeep = true
-	if GlobalConfig.SamplerHook != nil {
+	if sampler != nil {
+		shouldKeep = sampler.Sample(traceID)
+		ev.SampleRate = uint(sampler.GetSampleRate())
+	} else if c.SamplerHook != nil {
 		var sampleRate int
-		shouldKeep, sampleRate = GlobalConfig.SamplerHook(s.ev.Fields())
-		s.ev.SampleRate = uint(sampleRate)
+		shouldKeep, sampleRate = c.SamplerHook(ev.Fields())
+		ev.SampleRate = uint(sampleRate)
 	} else {
 		// use the default sampler
-		if sample.GlobalSampler != nil {
-			shouldKeep = sample.GlobalSampler.Sample(s.trace.traceID)
-			s.ev.SampleRate = uint(sample.GlobalSampler.GetSampleRate())
+		if sampler := c.sampler(); sampler != nil {
+			shouldKeep = sampler.Sample(traceID)
+			ev.SampleRate = uint(sampler.GetSampleRate())
 		}
 	}
+	if shouldKeep && c.RateLimitHook != nil {
+		shouldKeep = c.RateLimitHook(ev.Fields())
+	}
+	if c.SendDecisionHook != nil {
+		c.SendDecisionHook(shouldKeep)
+	}
 	if shouldKeep {
-		if GlobalConfig.PresendHook != nil {
+		if c.PresendHook != nil {
 			// munge all the fields
-			GlobalConfig.PresendHook(s.ev.Fields())
+			c.PresendHook(ev.Fields())
+		}
+		if c.DatasetHook != nil {
+			if dataset := c.DatasetHook(ev.Fields()); dataset != "" {
+				ev.Dataset = dataset
+			}
 		}
-		s.ev.SendPresampled()
+		ev.SendPresampled()
 	}
 }
 
+// SendEvent sends an event that isn't part of a trace, such as one for a DB
+// call made without a context, after passing it through the same hooks in
+// GlobalConfig as spans. Without a SamplerHook it is sampled by the global
+// sampler at random, as there is no trace to keep it with.
+func SendEvent(ev *libhoney.Event) {
+	GlobalConfig.SendEvent(ev)
+}
+
+// SendEvent sends an event that isn't part of a trace as the package level
+// SendEvent does, with the hooks and sampling in c.
+func (c *Config) SendEvent(ev *libhoney.Event) {
+	c.sendEvent(ev, c.NewTraceID(), nil)
+}
+
 func (s *Span) createChildSpan(ctx context.Context, async bool) (context.Context, *Span) {
-	newSpan := newSpan()
+	newSpan := newSpan(s.cfg())
 	newSpan.parent = s
 	newSpan.parentID = s.spanID
 	newSpan.trace = s.trace
-	newSpan.ev = s.trace.builder.NewEvent()
 	newSpan.isAsync = async
-	s.childrenLock.Lock()
-	s.children = append(s.children, newSpan)
-	s.childrenLock.Unlock()
+	newSpan.depth = s.depth + 1
+	if s.ev == nil && s.aggregate == nil || newSpansStopped() {
+		// children of spans that aren't recorded aren't recorded either
+		ctx = PutSpanInContext(ctx, newSpan)
+		return ctx, newSpan
+	}
+	newSpan.ev = s.trace.builder.NewEvent()
+	if agg := s.addChild(newSpan); agg != nil {
+		// the span records nothing itself, and is counted by the placeholder
+		newSpan.ev = nil
+		newSpan.aggregate = agg
+		agg.add()
+	} else {
+		trackSpan(newSpan)
+	}
 	ctx = PutSpanInContext(ctx, newSpan)
 	return ctx, newSpan
 }
+
+// addChild adds child to this span's children, unless it is over the trace's
+// Config.MaxSpanDepth or Config.MaxChildrenPerSpan. Then it
+// returns the aggregate the child should be counted in instead, creating the
+// placeholder span for it the first time. Children of aggregated spans are
+// aggregated with them.
+func (s *Span) addChild(child *Span) *aggregate {
+	if s.ev == nil && s.aggregate != nil {
+		return s.aggregate
+	}
+	s.childrenLock.Lock()
+	defer s.childrenLock.Unlock()
+	config := s.cfg()
+	var reason string
+	switch {
+	case config.MaxSpanDepth > 0 && child.depth > config.MaxSpanDepth:
+		reason = "depth"
+	case config.MaxChildrenPerSpan > 0 && s.childCount >= config.MaxChildrenPerSpan:
+		reason = "fan_out"
+	default:
+		s.children = append(s.children, child)
+		s.childCount++
+		return nil
+	}
+	if s.overflow == nil {
+		placeholder := newSpan(config)
+		placeholder.parent = s
+		placeholder.parentID = s.spanID
+		placeholder.trace = s.trace
+		placeholder.depth = child.depth
+		placeholder.ev = s.trace.builder.NewEvent()
+		placeholder.ev.AddField("name", "aggregated")
+		placeholder.aggregate = &aggregate{spanID: placeholder.spanID, reason: reason}
+		s.overflow = placeholder.aggregate
+		s.children = append(s.children, placeholder)
+	}
+	return s.overflow
+}
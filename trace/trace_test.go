@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"strings"
 	"sync"
 	"testing"
@@ -24,13 +25,17 @@ func TestNewTrace(t *testing.T) {
 	assert.NotNil(t, tr.builder, "traces should have a builder")
 	assert.NotEmpty(t, tr.traceID, "trace should have a trace ID")
 	assert.Empty(t, tr.parentID, "trace created with no headers should have an empty parent ID")
-	assert.NotNil(t, tr.rollupFields, "trace should initialize rollup fields map")
+	assert.Nil(t, tr.rollupFields, "rollup fields map should be made on first use")
 	assert.NotNil(t, tr.rootSpan, "trace should have a root span")
-	assert.NotNil(t, tr.traceLevelFields, "trace should initialize trace level fields map")
+	assert.Nil(t, tr.traceLevelFields, "trace level fields map should be made on first use")
 	trFromContext := GetTraceFromContext(ctx)
 	assert.Equal(t, tr, trFromContext, "new trace should put the trace in the context")
 	spFromContext := GetSpanFromContext(ctx)
 	assert.Equal(t, tr.rootSpan, spFromContext, "new trace should put the root span in the context")
+	tr.AddField("tenant", "acme")
+	tr.GetRootSpan().AddRollupField("db.duration_ms", 1)
+	assert.Equal(t, "acme", tr.traceLevelFields["tenant"])
+	assert.Equal(t, float64(1), tr.GetRollupField("db.duration_ms"))
 
 	// trace created with headers should take the trace and parent IDs and context
 	// serialized header with IDs and three fields in the context:
@@ -67,9 +72,9 @@ func TestNewTraceFromPropagationContext(t *testing.T) {
 	assert.NotNil(t, tr.builder, "traces should have a builder")
 	assert.NotEmpty(t, tr.traceID, "trace should have a trace ID")
 	assert.Empty(t, tr.parentID, "trace created with no propagation context should have an empty parent ID")
-	assert.NotNil(t, tr.rollupFields, "trace should initialize rollup fields map")
+	assert.Nil(t, tr.rollupFields, "rollup fields map should be made on first use")
 	assert.NotNil(t, tr.rootSpan, "trace should have a root span")
-	assert.NotNil(t, tr.traceLevelFields, "trace should initialize trace level fields map")
+	assert.Nil(t, tr.traceLevelFields, "trace level fields map should be made on first use")
 	trFromContext := GetTraceFromContext(ctx)
 	assert.Equal(t, tr, trFromContext, "new trace should put the trace in the context")
 	spFromContext := GetSpanFromContext(ctx)
@@ -98,6 +103,67 @@ func TestNewTraceFromPropagationContext(t *testing.T) {
 	assert.Equal(t, true, tr.traceLevelFields["toRetry"], "trace with a propagation context should populate trace level fields")
 }
 
+// TestNewTraceFromHostilePropagationContext makes sure IDs that come from
+// upstream are bounded before we use them.
+func TestNewTraceFromHostilePropagationContext(t *testing.T) {
+	mo := setupLibhoney()
+
+	long := strings.Repeat("a", 1000)
+	_, tr := NewTraceFromPropagationContext(context.Background(), &propagation.PropagationContext{
+		TraceID:  long,
+		ParentID: "00f067aa0ba902b7",
+	})
+	assert.Equal(t, maxPropagatedIDLength, len(tr.traceID), "long trace IDs should be truncated")
+	assert.Equal(t, "00f067aa0ba902b7", tr.parentID)
+	tr.Send()
+
+	_, tr = NewTraceFromPropagationContext(context.Background(), &propagation.PropagationContext{
+		TraceID:  "0af7651916cd43dd8448eb211c80319c\r\nX-Evil: 1",
+		ParentID: "00f067aa0ba902b7",
+		Dataset:  "someone-elses-dataset",
+		TraceContext: map[string]interface{}{
+			"userID": 1,
+		},
+	})
+	assert.NotContains(t, tr.traceID, "Evil", "trace IDs with control characters should be rejected")
+	assert.Empty(t, tr.parentID, "a rejected trace ID should not leave its parent ID behind")
+	tr.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 2, len(evs))
+	assert.Equal(t, maxRecordedIDLength, len(evs[0].Data["meta.original_trace_id"].(string)))
+	assert.Equal(t, `"0af7651916cd43dd8448eb211c80319c\r\nX-Evil: 1"`, evs[1].Data["meta.original_trace_id"],
+		"rejected IDs should be recorded escaped")
+	assert.Equal(t, "root", evs[1].Data["meta.span_type"])
+	assert.NotEqual(t, "someone-elses-dataset", evs[1].Dataset, "a rejected header should not pick the dataset")
+	assert.Equal(t, 1, evs[1].Data["userID"], "trace level fields should still propagate")
+}
+
+func TestIgnorePropagatedIDs(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.IgnorePropagatedIDs = true
+	defer func() { GlobalConfig.IgnorePropagatedIDs = false }()
+
+	_, tr := NewTraceFromPropagationContext(context.Background(), &propagation.PropagationContext{
+		TraceID:  "0af7651916cd43dd8448eb211c80319c",
+		ParentID: "00f067aa0ba902b7",
+		Dataset:  "someone-elses-dataset",
+		TraceContext: map[string]interface{}{
+			"userID": 1,
+		},
+	})
+	assert.NotEqual(t, "0af7651916cd43dd8448eb211c80319c", tr.traceID, "upstream trace ID should not be used")
+	assert.Empty(t, tr.parentID, "upstream parent ID should not be used")
+	tr.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 1, len(evs))
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", evs[0].Data["trace.upstream_trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", evs[0].Data["trace.upstream_parent_id"])
+	assert.Equal(t, 1, evs[0].Data["userID"], "trace level fields should still propagate")
+	assert.NotEqual(t, "someone-elses-dataset", evs[0].Dataset, "upstream dataset should not be used")
+}
+
 // TestAddField tests adding a field to a trace
 func TestAddField(t *testing.T) {
 	_, tr := NewTrace(context.Background(), "")
@@ -115,6 +181,121 @@ func TestRollupField(t *testing.T) {
 	assert.Equal(t, 0.1, tr.rollupFields["smallnum"], "addRollupField on a trace should sum the fields added")
 }
 
+// TestRollupFieldLimit makes sure distinct rollup keys beyond the limit are
+// summed into the overflow bucket.
+func TestRollupFieldLimit(t *testing.T) {
+	GlobalConfig.MaxRollupFields = 3
+	defer func() { GlobalConfig.MaxRollupFields = 0 }()
+
+	_, tr := NewTrace(context.Background(), "")
+	sp := tr.GetRootSpan()
+	for i := 0; i < 10; i++ {
+		sp.AddRollupField(fmt.Sprintf("db.call.%d", i), 1)
+	}
+	sp.AddRollupField("db.call.0", 1)
+	// the overflow bucket takes the place of the fourth key
+	assert.Equal(t, 4, len(tr.rollupFields))
+	assert.Equal(t, float64(2), tr.rollupFields["db.call.0"], "keys under the limit should still sum")
+	assert.Equal(t, float64(7), tr.rollupFields[RollupOverflowField], "keys over the limit should go to the overflow bucket")
+	assert.Equal(t, 4, len(sp.rollupFields), "span rollups should be bounded too")
+}
+
+func TestGetRollupField(t *testing.T) {
+	ctx, tr := NewTrace(context.Background(), "")
+	_, child := tr.GetRootSpan().CreateChild(ctx)
+	tr.GetRootSpan().AddRollupField("r1", 2)
+	child.AddRollupField("r1", 3)
+	assert.Equal(t, float64(5), tr.GetRollupField("r1"))
+	assert.Equal(t, float64(0), tr.GetRollupField("missing"))
+}
+
+func TestRollupDimensions(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.RollupDimensions = []string{"meta.type", "peer.service"}
+	defer func() { GlobalConfig.RollupDimensions = nil }()
+
+	ctx, tr := NewTrace(context.Background(), "")
+	root := tr.GetRootSpan()
+	for _, svc := range []string{"billing", "billing", "users"} {
+		_, child := root.CreateChild(ctx)
+		child.AddField("meta.type", "http_client")
+		child.AddField("peer.service", svc)
+		child.Send()
+	}
+	// spans without the fields aren't totaled
+	_, other := root.CreateChild(ctx)
+	other.Send()
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 5, len(evs)) {
+		fields := evs[4].Data
+		assert.Equal(t, float64(2), fields["rollup.peer.service.billing.count"])
+		assert.Equal(t, float64(1), fields["rollup.peer.service.users.count"])
+		assert.Equal(t, float64(3), fields["rollup.meta.type.http_client.count"])
+		assert.Contains(t, fields, "rollup.peer.service.billing.duration_ms")
+		assert.NotContains(t, evs[0].Data, "peer.service.billing.count",
+			"dimension totals belong to the trace, not the span")
+	}
+}
+
+// TestConcurrentRollups makes sure rollups from concurrent children, like
+// DB calls made in parallel under one request, are all counted.
+func TestConcurrentRollups(t *testing.T) {
+	mo := setupLibhoney()
+	ctx, tr := NewTrace(context.Background(), "")
+	root := tr.GetRootSpan()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, child := root.CreateChild(ctx)
+			child.AddRollupField("db.call_count", 1)
+			child.AddRollupField("db.duration_ms", 2)
+			root.AddRollupField("handler.calls", 1)
+			child.Send()
+		}()
+	}
+	wg.Wait()
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 51, len(evs)) {
+		fields := evs[50].Data
+		assert.Equal(t, float64(50), fields["rollup.db.call_count"])
+		assert.Equal(t, float64(100), fields["rollup.db.duration_ms"])
+		assert.Equal(t, float64(50), fields["handler.calls"], "a span's own rollups should be summed too")
+		assert.Equal(t, float64(50), fields["rollup.handler.calls"])
+	}
+}
+
+func TestCounters(t *testing.T) {
+	mo := setupLibhoney()
+	ctx, tr := NewTraceFromSerializedHeaders(context.Background(), "1;trace_id=abc,parent_id=def")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, child := tr.GetRootSpan().CreateAsyncChild(ctx)
+			tr.IncrementCounter("rows", 2)
+			child.Send()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(20), tr.GetCounter("rows"))
+	tr.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 11, len(evs)) {
+		root := evs[10].Data
+		assert.Equal(t, "subroot", root["meta.span_type"])
+		assert.Equal(t, int64(20), root["rows"], "counters should be on the in-process root")
+		assert.NotContains(t, evs[0].Data, "rows")
+	}
+}
+
 // TestGetRootSpan verifies the real root span is returned
 func TestGetRootSpan(t *testing.T) {
 	_, tr := NewTrace(context.Background(), "")
@@ -329,6 +510,39 @@ func TestCreateSubSpanDoesNotCauseRaceInSend(t *testing.T) {
 	wg.Wait()
 }
 
+func TestSentAfterParent(t *testing.T) {
+	mo := setupLibhoney()
+	ctx, tr := NewTrace(context.Background(), "")
+	root := tr.GetRootSpan()
+	_, child := root.CreateChild(ctx)
+	_, async := root.CreateAsyncChild(ctx)
+	async.AddField("name", "async")
+	async.AddRollupField("db.call_count", 1)
+	root.Send()
+	_, late := root.CreateChild(ctx)
+	late.AddField("name", "late")
+	async.AddRollupField("db.call_count", 1)
+	async.Send()
+	late.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 4, len(evs)) {
+		return
+	}
+	byID := make(map[string]map[string]interface{})
+	for _, ev := range evs {
+		byID[ev.Data["trace.span_id"].(string)] = ev.Data
+	}
+	assert.NotContains(t, byID[root.GetSpanID()], "meta.sent_after_parent")
+	assert.NotContains(t, byID[child.GetSpanID()], "meta.sent_after_parent", "spans sent by their parent aren't late")
+	assert.Equal(t, true, byID[async.GetSpanID()]["meta.sent_after_parent"])
+	assert.Equal(t, true, byID[late.GetSpanID()]["meta.sent_after_parent"])
+	// the straggler keeps its own rollups, but the trace's were reported
+	assert.Equal(t, float64(2), byID[async.GetSpanID()]["db.call_count"])
+	assert.Equal(t, float64(1), byID[root.GetSpanID()]["rollup.db.call_count"])
+	assert.Equal(t, float64(1), tr.GetRollupField("db.call_count"))
+}
+
 func TestChildAndParentSendsDoNotRace(t *testing.T) {
 	setupLibhoney()
 
@@ -401,6 +615,52 @@ func TestAddFieldDoesNotCauseRaceInSendHooks(t *testing.T) {
 	wg.Wait()
 }
 
+func TestGetChildrenDoesNotRaceWithChildLifecycle(t *testing.T) {
+	setupLibhoney()
+	ctx, tr := NewTrace(context.Background(), "")
+	rs := tr.GetRootSpan()
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			for j := 0; j < 50; j++ {
+				_, s := rs.CreateChild(ctx)
+				s.AddField("a", j)
+				s.Send()
+			}
+			wg.Done()
+		}()
+		go func() {
+			for j := 0; j < 50; j++ {
+				for _, child := range rs.GetChildren() {
+					child.AddField("b", j)
+				}
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	tr.Send()
+	assert.Empty(t, rs.GetChildren(), "sent children should be removed from their parent")
+}
+
+func TestConcurrentTraceSend(t *testing.T) {
+	mo := setupLibhoney()
+	_, tr := NewTrace(context.Background(), "")
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			tr.Send()
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, len(mo.Events()), "the root span should only be sent once")
+}
+
 func TestPropagatedFields(t *testing.T) {
 	prop := &propagation.PropagationContext{
 		TraceID:  "abcdef123456",
@@ -451,10 +711,106 @@ func TestPropagatedFields(t *testing.T) {
 	assert.NotEqual(t, "", tr.traceID, "trace id should have propagated")
 	assert.Equal(t, "", tr.parentID, "parent id should have propagated")
 	assert.Equal(t, "placeholder", tr.builder.Dataset, "dataset should have propagated")
-	assert.Equal(t, map[string]interface{}{}, tr.traceLevelFields, "trace fields should have propagated")
+	assert.Empty(t, tr.traceLevelFields, "trace fields should have propagated")
 
 }
 
+func TestRecordDurationNanos(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.RecordDurationNanos = true
+	defer func() { GlobalConfig.RecordDurationNanos = false }()
+
+	_, tr := NewTrace(context.Background(), "")
+	tr.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 1, len(evs))
+	ns, ok := evs[0].Data["duration_ns"].(int64)
+	assert.True(t, ok, "duration_ns should be an integer")
+	assert.True(t, ns > 0, "duration_ns should be populated")
+	assert.InDelta(t, float64(ns)/1e6, evs[0].Data["duration_ms"], 0.000001, "duration_ns and duration_ms should agree")
+}
+
+func TestMaxChildrenPerSpan(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.MaxChildrenPerSpan = 2
+	defer func() { GlobalConfig.MaxChildrenPerSpan = 0 }()
+
+	ctx, tr := NewTrace(context.Background(), "")
+	root := tr.GetRootSpan()
+	for i := 0; i < 5; i++ {
+		_, child := root.CreateChild(ctx)
+		child.AddField("name", "call")
+		// children of aggregated spans are aggregated too
+		_, grandchild := child.CreateChild(ctx)
+		grandchild.Send()
+		child.Send()
+	}
+	root.Send()
+
+	evs := mo.Events()
+	// two children with their grandchildren, the placeholder and the root
+	if assert.Equal(t, 6, len(evs)) {
+		placeholder := evs[4].Data
+		assert.Equal(t, "aggregated", placeholder["name"])
+		assert.Equal(t, int64(6), placeholder["meta.aggregated_count"])
+		assert.Equal(t, "fan_out", placeholder["meta.aggregated_reason"])
+		assert.True(t, placeholder["meta.aggregated_duration_ms"].(float64) > 0)
+		assert.Equal(t, evs[5].Data["trace.span_id"], placeholder["trace.parent_id"])
+	}
+}
+
+func TestMaxSpanDepth(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.MaxSpanDepth = 2
+	defer func() { GlobalConfig.MaxSpanDepth = 0 }()
+
+	ctx, tr := NewTrace(context.Background(), "")
+	spans := []*Span{tr.GetRootSpan()}
+	for i := 0; i < 5; i++ {
+		var s *Span
+		ctx, s = spans[len(spans)-1].CreateChild(ctx)
+		spans = append(spans, s)
+	}
+	headers := spans[len(spans)-1].SerializeHeaders()
+	for i := len(spans) - 1; i >= 0; i-- {
+		spans[i].Send()
+	}
+
+	evs := mo.Events()
+	if assert.Equal(t, 4, len(evs)) {
+		placeholder := evs[0].Data
+		assert.Equal(t, "aggregated", placeholder["name"])
+		assert.Equal(t, int64(3), placeholder["meta.aggregated_count"])
+		assert.Equal(t, "depth", placeholder["meta.aggregated_reason"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], placeholder["trace.parent_id"])
+		assert.Contains(t, headers, placeholder["trace.span_id"], "aggregated spans should propagate the placeholder as the parent")
+	}
+}
+
+func TestSendDuringPanic(t *testing.T) {
+	mo := setupLibhoney()
+	ctx, tr := NewTrace(context.Background(), "")
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		defer tr.Send()
+		_, span := tr.GetRootSpan().CreateChild(ctx)
+		defer span.Send()
+		panic("oh no")
+	}()
+	assert.Equal(t, "oh no", recovered, "the panic should continue after sending")
+
+	evs := mo.Events()
+	assert.Equal(t, 2, len(evs), "both spans should be sent despite the panic")
+	for _, ev := range evs {
+		assert.Equal(t, "panic", ev.Data["error"])
+		assert.Equal(t, "oh no", ev.Data["panic.value"])
+		assert.Contains(t, ev.Data["panic.stack"], "TestSendDuringPanic", "the stack should include where the panic happened")
+	}
+}
+
 // TestGetNewID ensures that ID is always a lowercase hex string of the requested length
 func TestGetNewID(t *testing.T) {
 	id := getNewID(8)
@@ -491,6 +847,19 @@ func BenchmarkSendSpan(b *testing.B) {
 	}
 }
 
+// BenchmarkNewTrace benchmarks starting and sending a trace with one child
+// span, the work done for every traced request.
+func BenchmarkNewTrace(b *testing.B) {
+	setupLibhoney()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		ctx, tr := NewTrace(context.Background(), "")
+		_, s := tr.GetRootSpan().CreateChild(ctx)
+		s.Send()
+		tr.Send()
+	}
+}
+
 func setupLibhoney() *transmission.MockSender {
 	mo := &transmission.MockSender{}
 	c, _ := libhoney.NewClient(
@@ -504,3 +873,111 @@ func setupLibhoney() *transmission.MockSender {
 
 	return mo
 }
+
+func TestSendEvent(t *testing.T) {
+	mo := setupLibhoney()
+	GlobalConfig.FieldHooks = []func(map[string]interface{}){func(fields map[string]interface{}) {
+		fields["derived"] = true
+	}}
+	GlobalConfig.SamplerHook = func(fields map[string]interface{}) (bool, int) {
+		return fields["keep"] == true, 3
+	}
+	GlobalConfig.PresendHook = func(fields map[string]interface{}) {
+		delete(fields, "secret")
+	}
+	defer func() {
+		GlobalConfig.FieldHooks = nil
+		GlobalConfig.SamplerHook = nil
+		GlobalConfig.PresendHook = nil
+	}()
+
+	for _, keep := range []bool{true, false} {
+		ev := client.NewBuilder().NewEvent()
+		ev.AddField("keep", keep)
+		ev.AddField("secret", "hunter2")
+		SendEvent(ev)
+	}
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs), "the sampler hook should drop events") {
+		assert.Equal(t, true, evs[0].Data["derived"])
+		assert.NotContains(t, evs[0].Data, "secret")
+		assert.Equal(t, uint(3), evs[0].SampleRate)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	mo := setupLibhoney()
+	ctx, tr := NewTrace(context.Background(), "")
+	root := tr.GetRootSpan()
+	_, discarded := root.CreateChild(ctx)
+	_, kept := root.CreateChild(ctx)
+	kept.Send()
+	discarded.Discard()
+	discarded.Send()
+	assert.Empty(t, root.GetChildren())
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs), "a discarded span should not be sent, even by its parent") {
+		assert.Equal(t, kept.GetSpanID(), evs[0].Data["trace.span_id"])
+		assert.Equal(t, root.GetSpanID(), evs[1].Data["trace.span_id"])
+	}
+}
+
+func TestCreateDetachedChild(t *testing.T) {
+	mo := setupLibhoney()
+	ctx, tr := NewTrace(context.Background(), "")
+	tr.AddField("user", "ada")
+	root := tr.GetRootSpan()
+	dctx, detached := root.CreateDetachedChild(ctx)
+	assert.Equal(t, detached, GetSpanFromContext(dctx))
+	assert.NotEqual(t, tr, GetTraceFromContext(dctx))
+
+	// neither trace sees fields added to the other afterwards
+	tr.AddField("late", true)
+	detached.AddTraceField("job", "resize")
+	root.Send()
+	_, child := detached.CreateChild(dctx)
+	child.Send()
+	detached.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 3, len(evs)) {
+		return
+	}
+	r, c, d := evs[0].Data, evs[1].Data, evs[2].Data
+	assert.Equal(t, true, r["late"])
+	assert.NotContains(t, r, "job")
+	assert.Equal(t, "async", d["meta.span_type"])
+	assert.Equal(t, tr.GetTraceID(), d["trace.trace_id"])
+	assert.Equal(t, root.GetSpanID(), d["trace.parent_id"])
+	assert.Equal(t, "ada", d["user"])
+	assert.Equal(t, "resize", d["job"])
+	assert.NotContains(t, d, "late")
+	assert.Equal(t, detached.GetSpanID(), c["trace.parent_id"])
+	assert.Equal(t, "resize", c["job"])
+}
+
+func TestSetSampleRate(t *testing.T) {
+	mo := setupLibhoney()
+	called := false
+	GlobalConfig.SamplerHook = func(map[string]interface{}) (bool, int) {
+		called = true
+		return true, 1
+	}
+	defer func() { GlobalConfig.SamplerHook = nil }()
+
+	// a rate this high keeps almost nothing
+	ctx, tr := NewTrace(context.Background(), "")
+	tr.SetSampleRate(math.MaxUint32)
+	_, child := tr.GetRootSpan().CreateChild(ctx)
+	child.Send()
+	assert.Empty(t, mo.Events())
+	assert.False(t, called, "the trace's sample rate should be used instead of the hook")
+
+	tr.SetSampleRate(0)
+	tr.GetRootSpan().Send()
+	assert.Equal(t, 1, len(mo.Events()))
+	assert.True(t, called)
+}
@@ -0,0 +1,436 @@
+package beeline
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/honeycombio/libhoney-go/transmission"
+)
+
+const (
+	defaultRetryBackoff = 100 * time.Millisecond
+	// maxRetryBackoff caps the doubling backoff so a large MaxRetries can't
+	// overflow it.
+	maxRetryBackoff = time.Minute
+	// queueOverflowMessage is the error libhoney reports when an event is
+	// dropped because the pending work queue is full, and begins the errors
+	// an overflowSender reports. Retrying those only adds to the pressure, so
+	// they are counted as failures straight away.
+	queueOverflowMessage = "queue overflow"
+
+	defaultTransmissionStatsInterval = 10 * time.Second
+)
+
+// TransmissionStats counts the outcome of every event handed to the
+// transmission the beeline created in Init. Counts are zero if Config.Client
+// was set.
+type TransmissionStats struct {
+	// Sent is the number of events Honeycomb accepted.
+	Sent uint64
+	// Failed is the number of events that could not be sent and will not be
+	// retried, eg because of a rejected write key or an overflowing queue.
+	Failed uint64
+	// Retried is the number of times an event was sent again after a
+	// retryable failure.
+	Retried uint64
+	// Spooled is the number of times an event was written to the disk spool
+	// while the API was unreachable.
+	Spooled uint64
+	// Replayed is the number of events read back from the spool and sent
+	// again.
+	Replayed uint64
+	// Shed is the number of events dropped without being sent because the
+	// circuit breaker was open and there was no spool to hold them. They are
+	// included in Failed.
+	Shed uint64
+	// BreakerTrips is the number of times the circuit breaker has opened,
+	// and BreakerOpen whether it is open now.
+	BreakerTrips uint64
+	BreakerOpen  bool
+	// DroppedNewest, DroppedOldest and TimedOut count the events dropped
+	// because the queue of events waiting to be sent was full, by the
+	// OverflowPolicy that dropped them. They are included in Failed.
+	DroppedNewest uint64
+	DroppedOldest uint64
+	TimedOut      uint64
+}
+
+// reportTransmissionStats calls handler with the sender's stats every
+// interval until done is closed.
+func reportTransmissionStats(s *retrySender, handler func(TransmissionStats), interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			handler(s.stats())
+		case <-done:
+			return
+		}
+	}
+}
+
+// droppedEventsField is added to root spans by droppedEventsHook.
+const droppedEventsField = "meta.dropped_events_total"
+
+// droppedEventsHook is a FieldHook that adds the number of events that have
+// failed to be sent by b so far to root spans.
+func (b *Beeline) droppedEventsHook(fields map[string]interface{}) {
+	if fields["meta.span_type"] != "root" {
+		return
+	}
+	fields[droppedEventsField] = b.GetTransmissionStats().Failed
+}
+
+// retryMetadata replaces the metadata of every event given to a retrySender
+// so the event can be found again when its response comes back.
+type retryMetadata struct {
+	metadata interface{}
+	event    *transmission.Event
+	attempts uint
+	added    time.Time
+}
+
+// retrySender wraps a libhoney transmission, counts the responses for every
+// event, resends events that failed in a way that is likely to be temporary
+// (throttling, server errors, timeouts) and reports events that are given up
+// on to an error handler.
+type retrySender struct {
+	transmission.Sender
+
+	maxRetries   uint
+	retryBackoff time.Duration
+	onError      func(transmission.Response)
+	// writeKey is the key replayed events are sent with.
+	writeKey string
+
+	sent     uint64
+	failed   uint64
+	retried  uint64
+	spooled  uint64
+	replayed uint64
+
+	droppedNewest uint64
+	droppedOldest uint64
+	timedOut      uint64
+
+	// inFlight is the number of events handed to the wrapped transmission
+	// still waiting for a response, and latencyNanos and latencyCount sum up
+	// the time from Add to the final response of every event.
+	inFlight     int64
+	latencyNanos uint64
+	latencyCount uint64
+
+	// spool, if set, holds events while breaker is open and those that ran
+	// out of retries, until the API can be reached again. Without one, events
+	// are shed while the breaker is open if shedLoad is set.
+	spool     *diskSpool
+	shedLoad  bool
+	breaker   circuitBreaker
+	replaying int32
+	shed      uint64
+
+	responses chan transmission.Response
+	readers   sync.WaitGroup
+	stopped   chan struct{}
+
+	// lock protects running and pending. Add can block until responses are
+	// read, and reading them takes the lock, so it isn't held while events
+	// are added to the wrapped transmission. Instead adding counts the
+	// retries and replayed events being added, and Stop waits for them
+	// before stopping the wrapped transmission underneath them.
+	lock    sync.Mutex
+	running bool
+	pending map[*retryMetadata]*time.Timer
+	adding  sync.WaitGroup
+}
+
+func newRetrySender(tx transmission.Sender, config Config) *retrySender {
+	backoff := config.RetryBackoff
+	if backoff == 0 {
+		backoff = defaultRetryBackoff
+	}
+	threshold := config.BreakerThreshold
+	if threshold == 0 {
+		threshold = defaultBreakerThreshold
+	}
+	cooldown := config.BreakerCooldown
+	if cooldown == 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &retrySender{
+		Sender:       tx,
+		maxRetries:   config.MaxRetries,
+		retryBackoff: backoff,
+		onError:      config.TransmissionErrorHandler,
+		writeKey:     config.WriteKey,
+		shedLoad:     config.EnableBreaker,
+		responses:    make(chan transmission.Response, 100),
+		pending:      make(map[*retryMetadata]*time.Timer),
+		breaker:      circuitBreaker{threshold: threshold, cooldown: cooldown},
+	}
+}
+
+// Start starts the wrapped transmission and begins reading its responses.
+// libhoney stops and starts the transmission on every Flush, and the wrapped
+// responses channel is replaced each time, so a new reader is started too.
+func (s *retrySender) Start() error {
+	err := s.Sender.Start()
+	s.lock.Lock()
+	s.running = true
+	s.stopped = make(chan struct{})
+	s.lock.Unlock()
+	s.readers.Add(1)
+	go s.readResponses(s.Sender.TxResponses(), s.stopped)
+	return err
+}
+
+// Stop sends any events waiting out their backoff immediately, then stops the
+// wrapped transmission and waits for all of its responses to be handled.
+func (s *retrySender) Stop() error {
+	s.lock.Lock()
+	// a timer that has already fired is waiting on the lock in retry and will
+	// do nothing once its event is gone from pending, so send those too.
+	retries := make([]*retryMetadata, 0, len(s.pending))
+	for meta, timer := range s.pending {
+		timer.Stop()
+		delete(s.pending, meta)
+		retries = append(retries, meta)
+	}
+	s.running = false
+	stopped := s.stopped
+	s.stopped = nil
+	s.lock.Unlock()
+
+	// Add can block until the wrapped transmission has room, which needs the
+	// responses read, and reading them takes the lock to schedule retries, so
+	// the retries are added without it.
+	for _, meta := range retries {
+		atomic.AddUint64(&s.retried, 1)
+		atomic.AddInt64(&s.inFlight, 1)
+		s.Sender.Add(meta.event)
+	}
+	s.adding.Wait()
+	err := s.Sender.Stop()
+	if stopped != nil {
+		close(stopped)
+	}
+	s.readers.Wait()
+	return err
+}
+
+// Add records the event so its response can be matched up with it later and
+// hands it to the wrapped transmission, or while the breaker is open to the
+// spool, or drops it if there is none.
+func (s *retrySender) Add(ev *transmission.Event) {
+	meta := &retryMetadata{
+		metadata: ev.Metadata,
+		event:    ev,
+		added:    time.Now(),
+	}
+	ev.Metadata = meta
+	if s.usesBreaker() && !s.breaker.allow() {
+		if s.spool == nil {
+			s.shedEvent(meta)
+		} else if !s.spoolEvent(meta) {
+			ev.Metadata = meta.metadata
+			s.finish(transmission.Response{Err: errSpoolFull, Metadata: meta.metadata})
+		}
+		return
+	}
+	atomic.AddInt64(&s.inFlight, 1)
+	s.Sender.Add(ev)
+}
+
+// usesBreaker returns true if events are kept from the API while the breaker
+// is open.
+func (s *retrySender) usesBreaker() bool {
+	return s.spool != nil || s.shedLoad
+}
+
+// shedEvent drops an event because the breaker is open.
+func (s *retrySender) shedEvent(meta *retryMetadata) {
+	atomic.AddUint64(&s.shed, 1)
+	meta.event.Metadata = meta.metadata
+	s.finish(transmission.Response{Err: errBreakerOpen, Metadata: meta.metadata})
+}
+
+// TxResponses returns the final response for every event, after any retries,
+// with the event's original metadata.
+func (s *retrySender) TxResponses() chan transmission.Response {
+	return s.responses
+}
+
+// SendResponse adds a response to the queue returned by TxResponses. It
+// returns true if the queue was full and the response was dropped.
+func (s *retrySender) SendResponse(r transmission.Response) bool {
+	select {
+	case s.responses <- r:
+		return false
+	default:
+		return true
+	}
+}
+
+// stats returns a snapshot of the counters.
+func (s *retrySender) stats() TransmissionStats {
+	open, trips := s.breaker.state()
+	return TransmissionStats{
+		Sent:         atomic.LoadUint64(&s.sent),
+		Failed:       atomic.LoadUint64(&s.failed),
+		Retried:      atomic.LoadUint64(&s.retried),
+		Spooled:      atomic.LoadUint64(&s.spooled),
+		Replayed:     atomic.LoadUint64(&s.replayed),
+		Shed:         atomic.LoadUint64(&s.shed),
+		BreakerTrips: trips,
+		BreakerOpen:  open,
+
+		DroppedNewest: atomic.LoadUint64(&s.droppedNewest),
+		DroppedOldest: atomic.LoadUint64(&s.droppedOldest),
+		TimedOut:      atomic.LoadUint64(&s.timedOut),
+	}
+}
+
+// readResponses handles responses until the wrapped transmission has been
+// stopped and everything it had queued has been read. Not every transmission
+// closes its responses channel when stopped, so that can't be relied on.
+func (s *retrySender) readResponses(responses chan transmission.Response, stopped chan struct{}) {
+	defer s.readers.Done()
+	for {
+		select {
+		case r, ok := <-responses:
+			if !ok {
+				return
+			}
+			s.handleResponse(r)
+		case <-stopped:
+			for {
+				select {
+				case r, ok := <-responses:
+					if !ok {
+						return
+					}
+					s.handleResponse(r)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *retrySender) handleResponse(r transmission.Response) {
+	meta, ok := r.Metadata.(*retryMetadata)
+	if !ok {
+		// not an event we added; pass it along untouched
+		s.finish(r)
+		return
+	}
+	atomic.AddInt64(&s.inFlight, -1)
+	if s.usesBreaker() {
+		s.recordOutcome(r)
+	}
+	if isRetryable(r) && meta.attempts < s.maxRetries && s.scheduleRetry(meta) {
+		return
+	}
+	if s.spool != nil && isRetryable(r) && s.spoolEvent(meta) {
+		// it's sent again once the API is back
+		return
+	}
+	atomic.AddUint64(&s.latencyNanos, uint64(time.Since(meta.added)))
+	atomic.AddUint64(&s.latencyCount, 1)
+	meta.event.Metadata = meta.metadata
+	r.Metadata = meta.metadata
+	s.finish(r)
+}
+
+// scheduleRetry arranges for the event to be sent again after its backoff,
+// which doubles with every attempt. It returns false if the transmission is
+// stopping and the event can't be retried.
+func (s *retrySender) scheduleRetry(meta *retryMetadata) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.running {
+		return false
+	}
+	backoff := retryBackoff(s.retryBackoff, meta.attempts)
+	meta.attempts++
+	s.pending[meta] = time.AfterFunc(backoff, func() { s.retry(meta) })
+	return true
+}
+
+// retryBackoff returns base doubled once for every previous attempt, capped at
+// maxRetryBackoff.
+func retryBackoff(base time.Duration, attempts uint) time.Duration {
+	backoff := base
+	for i := uint(0); i < attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+func (s *retrySender) retry(meta *retryMetadata) {
+	s.lock.Lock()
+	if _, ok := s.pending[meta]; !ok {
+		// Stop already sent it
+		s.lock.Unlock()
+		return
+	}
+	delete(s.pending, meta)
+	breakerOpen := s.usesBreaker() && s.breaker.isOpen()
+	s.adding.Add(1)
+	s.lock.Unlock()
+	defer s.adding.Done()
+
+	if breakerOpen {
+		if s.spool == nil {
+			s.shedEvent(meta)
+			return
+		}
+		if s.spoolEvent(meta) {
+			return
+		}
+	}
+	atomic.AddUint64(&s.retried, 1)
+	atomic.AddInt64(&s.inFlight, 1)
+	s.Sender.Add(meta.event)
+}
+
+func (s *retrySender) finish(r transmission.Response) {
+	// senders like the WriterSender respond with neither a status code nor an
+	// error; those events weren't rejected.
+	if r.Err != nil || (r.StatusCode != 0 && (r.StatusCode < 200 || r.StatusCode >= 300)) {
+		atomic.AddUint64(&s.failed, 1)
+		if r.Err != nil {
+			switch r.Err.Error() {
+			case queueOverflowMessage:
+				atomic.AddUint64(&s.droppedNewest, 1)
+			case errDroppedOldest.Error():
+				atomic.AddUint64(&s.droppedOldest, 1)
+			case errBlockTimeout.Error():
+				atomic.AddUint64(&s.timedOut, 1)
+			}
+		}
+		if s.onError != nil {
+			s.onError(r)
+		}
+	} else {
+		atomic.AddUint64(&s.sent, 1)
+	}
+	s.SendResponse(r)
+}
+
+// isRetryable returns true if the response describes a failure that may well
+// succeed if tried again: throttling, a server error or a transport error
+// such as a timeout.
+func isRetryable(r transmission.Response) bool {
+	if r.Err != nil {
+		return !strings.HasPrefix(r.Err.Error(), queueOverflowMessage)
+	}
+	return r.StatusCode == 429 || r.StatusCode >= 500
+}
@@ -0,0 +1,399 @@
+package beeline
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedSender responds to each event it is given with the next status code
+// from codes, or 202 once they run out.
+type scriptedSender struct {
+	sync.Mutex
+	codes     []int
+	added     int
+	responses chan transmission.Response
+}
+
+func (s *scriptedSender) Start() error {
+	s.responses = make(chan transmission.Response, 10)
+	return nil
+}
+
+func (s *scriptedSender) Stop() error {
+	close(s.responses)
+	return nil
+}
+
+func (s *scriptedSender) Add(ev *transmission.Event) {
+	s.Lock()
+	defer s.Unlock()
+	s.added++
+	code := 202
+	if len(s.codes) > 0 {
+		code, s.codes = s.codes[0], s.codes[1:]
+	}
+	r := transmission.Response{StatusCode: code, Metadata: ev.Metadata}
+	if code == 0 {
+		r.Err = errors.New("timeout")
+	}
+	s.responses <- r
+}
+
+func (s *scriptedSender) TxResponses() chan transmission.Response {
+	return s.responses
+}
+
+func (s *scriptedSender) SendResponse(r transmission.Response) bool {
+	s.responses <- r
+	return false
+}
+
+func (s *scriptedSender) addCount() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.added
+}
+
+func startRetrySender(t *testing.T, tx transmission.Sender, config Config) *retrySender {
+	s := newRetrySender(tx, config)
+	assert.NoError(t, s.Start())
+	return s
+}
+
+func nextResponse(t *testing.T, s *retrySender) transmission.Response {
+	select {
+	case r := <-s.TxResponses():
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a response")
+	}
+	return transmission.Response{}
+}
+
+func TestRetrySenderRetriesTemporaryFailures(t *testing.T) {
+	tx := &scriptedSender{codes: []int{503, 0, 429}}
+	s := startRetrySender(t, tx, Config{MaxRetries: 3, RetryBackoff: time.Millisecond})
+	defer s.Stop()
+
+	s.Add(&transmission.Event{Metadata: "meta"})
+	r := nextResponse(t, s)
+	assert.Equal(t, 202, r.StatusCode)
+	assert.Equal(t, "meta", r.Metadata, "original metadata should be restored")
+	assert.Equal(t, 4, tx.addCount(), "event should have been sent 4 times")
+	assert.Equal(t, TransmissionStats{Sent: 1, Retried: 3}, s.stats())
+}
+
+func TestRetrySenderReportsPermanentFailures(t *testing.T) {
+	var failures []transmission.Response
+	var lock sync.Mutex
+	config := Config{
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+		TransmissionErrorHandler: func(r transmission.Response) {
+			lock.Lock()
+			defer lock.Unlock()
+			failures = append(failures, r)
+		},
+	}
+	tx := &scriptedSender{codes: []int{401}}
+	s := startRetrySender(t, tx, config)
+	defer s.Stop()
+
+	s.Add(&transmission.Event{Metadata: "meta"})
+	r := nextResponse(t, s)
+	assert.Equal(t, 401, r.StatusCode)
+	assert.Equal(t, 1, tx.addCount(), "a rejected write key should not be retried")
+	assert.Equal(t, TransmissionStats{Failed: 1}, s.stats())
+
+	lock.Lock()
+	defer lock.Unlock()
+	if assert.Equal(t, 1, len(failures), "error handler should be called once") {
+		assert.Equal(t, 401, failures[0].StatusCode)
+		assert.Equal(t, "meta", failures[0].Metadata)
+	}
+}
+
+func TestRetrySenderGivesUp(t *testing.T) {
+	tx := &scriptedSender{codes: []int{500, 500, 202}}
+	s := startRetrySender(t, tx, Config{MaxRetries: 1, RetryBackoff: time.Millisecond})
+	defer s.Stop()
+
+	s.Add(&transmission.Event{})
+	r := nextResponse(t, s)
+	assert.Equal(t, 500, r.StatusCode)
+	assert.Equal(t, TransmissionStats{Failed: 1, Retried: 1}, s.stats())
+}
+
+func TestRetrySenderDoesNotRetryOverflow(t *testing.T) {
+	tx := &scriptedSender{}
+	s := startRetrySender(t, tx, Config{MaxRetries: 3})
+	defer s.Stop()
+
+	// libhoney reports an overflowing queue with a response of its own
+	tx.SendResponse(transmission.Response{
+		Err:      errors.New("queue overflow"),
+		Metadata: &retryMetadata{metadata: "meta", event: &transmission.Event{}},
+	})
+	r := nextResponse(t, s)
+	assert.Equal(t, "meta", r.Metadata)
+	assert.Equal(t, TransmissionStats{Failed: 1, DroppedNewest: 1}, s.stats())
+}
+
+func TestRetrySenderStopSendsPendingRetries(t *testing.T) {
+	tx := &scriptedSender{codes: []int{503}}
+	s := startRetrySender(t, tx, Config{MaxRetries: 3, RetryBackoff: time.Hour})
+
+	s.Add(&transmission.Event{Metadata: "meta"})
+	assert.Eventually(t, func() bool {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return len(s.pending) == 1
+	}, 5*time.Second, time.Millisecond, "retry should be scheduled")
+
+	assert.NoError(t, s.Stop())
+	r := nextResponse(t, s)
+	assert.Equal(t, 202, r.StatusCode)
+	assert.Equal(t, TransmissionStats{Sent: 1, Retried: 1}, s.stats())
+
+	// the transmission is restarted after every flush
+	assert.NoError(t, s.Start())
+	s.Add(&transmission.Event{Metadata: "again"})
+	r = nextResponse(t, s)
+	assert.Equal(t, "again", r.Metadata)
+	assert.NoError(t, s.Stop())
+}
+
+// unbufferedSender is a scriptedSender whose Add blocks until its response
+// has been read.
+type unbufferedSender struct {
+	scriptedSender
+}
+
+func (s *unbufferedSender) Start() error {
+	s.responses = make(chan transmission.Response)
+	return nil
+}
+
+func TestRetrySenderStopDoesNotHoldLockWhileAdding(t *testing.T) {
+	tx := &unbufferedSender{scriptedSender{codes: []int{503, 503, 503, 503}}}
+	s := startRetrySender(t, tx, Config{MaxRetries: 3, RetryBackoff: time.Hour})
+
+	s.Add(&transmission.Event{Metadata: "first"})
+	s.Add(&transmission.Event{Metadata: "second"})
+	assert.Eventually(t, func() bool {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return len(s.pending) == 2
+	}, 5*time.Second, time.Millisecond, "retries should be scheduled")
+
+	// the second retry can't be added until the first one's 503 has been
+	// handled, which takes the lock
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+	assert.Equal(t, 503, nextResponse(t, s).StatusCode)
+	assert.Equal(t, 503, nextResponse(t, s).StatusCode)
+	assert.Equal(t, 4, tx.addCount())
+}
+
+// fullSender is a scriptedSender that has no room once its first event has
+// been added: Add blocks until room is closed.
+type fullSender struct {
+	scriptedSender
+	blocked chan struct{}
+	room    chan struct{}
+}
+
+func (s *fullSender) Add(ev *transmission.Event) {
+	if s.addCount() > 0 {
+		close(s.blocked)
+		<-s.room
+	}
+	s.scriptedSender.Add(ev)
+}
+
+func TestRetrySenderRetryDoesNotHoldLockWhileAdding(t *testing.T) {
+	tx := &fullSender{
+		scriptedSender: scriptedSender{codes: []int{503}},
+		blocked:        make(chan struct{}),
+		room:           make(chan struct{}),
+	}
+	s := startRetrySender(t, tx, Config{MaxRetries: 1, RetryBackoff: time.Millisecond})
+
+	s.Add(&transmission.Event{Metadata: "meta"})
+	select {
+	case <-tx.blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the retry was not added")
+	}
+
+	// reading responses takes the lock, so it must be free while the retry
+	// waits for room
+	unlocked := make(chan struct{})
+	go func() {
+		s.lock.Lock()
+		s.lock.Unlock()
+		close(unlocked)
+	}()
+	select {
+	case <-unlocked:
+	case <-time.After(5 * time.Second):
+		t.Error("the lock was held while the retry waited for room")
+	}
+	close(tx.room)
+	assert.Equal(t, 202, nextResponse(t, s).StatusCode)
+	assert.NoError(t, s.Stop())
+	assert.Equal(t, uint64(1), s.stats().Retried)
+}
+
+func TestTransmissionStatsWithClient(t *testing.T) {
+	setupLibhoney(t)
+	assert.Equal(t, TransmissionStats{}, GetTransmissionStats(),
+		"stats should be zero when a client was given to Init")
+}
+
+func TestDroppedEventsHook(t *testing.T) {
+	b := &Beeline{sender: &retrySender{failed: 3}}
+
+	root := map[string]interface{}{"meta.span_type": "root"}
+	b.droppedEventsHook(root)
+	assert.Equal(t, uint64(3), root[droppedEventsField])
+	child := map[string]interface{}{"meta.span_type": "leaf"}
+	b.droppedEventsHook(child)
+	assert.NotContains(t, child, droppedEventsField)
+}
+
+func TestRetrySenderStopsWriterSender(t *testing.T) {
+	// the WriterSender never closes its responses channel
+	tx := &transmission.WriterSender{W: ioutil.Discard}
+	s := startRetrySender(t, tx, Config{})
+	s.Add(&transmission.Event{Metadata: "meta"})
+
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+	r := nextResponse(t, s)
+	assert.Equal(t, "meta", r.Metadata)
+	assert.Equal(t, TransmissionStats{Sent: 1}, s.stats())
+}
+
+func TestRetryBackoff(t *testing.T) {
+	assert.Equal(t, 100*time.Millisecond, retryBackoff(100*time.Millisecond, 0))
+	assert.Equal(t, 400*time.Millisecond, retryBackoff(100*time.Millisecond, 2))
+	assert.Equal(t, maxRetryBackoff, retryBackoff(100*time.Millisecond, 37),
+		"large attempt counts should not overflow")
+	assert.Equal(t, maxRetryBackoff, retryBackoff(time.Duration(1<<62), 1000))
+	assert.Equal(t, maxRetryBackoff, retryBackoff(2*time.Hour, 0),
+		"the configured backoff should be capped too")
+}
+
+func TestPayloadEncoding(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		config   Config
+		ctype    string
+		encoding string
+	}{
+		{"default", Config{}, "application/json", "zstd"},
+		{"msgpack", Config{EnableMsgpackEncoding: true}, "application/msgpack", "zstd"},
+		{"uncompressed", Config{DisableCompression: true}, "application/json", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var lock sync.Mutex
+			var headers []http.Header
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lock.Lock()
+				headers = append(headers, r.Header)
+				lock.Unlock()
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`[{"status":202}]`))
+			}))
+			defer srv.Close()
+
+			config := tt.config
+			config.WriteKey = "abc"
+			config.APIHost = srv.URL
+			config.DisableEnvConfig = true
+			Init(config)
+			_, span := StartSpan(context.Background(), "work")
+			span.Send()
+			Close()
+
+			lock.Lock()
+			defer lock.Unlock()
+			if assert.NotEmpty(t, headers) {
+				assert.Equal(t, tt.ctype, headers[0].Get("Content-Type"))
+				assert.Equal(t, tt.encoding, headers[0].Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestBreakerShedsLoad(t *testing.T) {
+	tx := &scriptedSender{codes: []int{503, 503}}
+	s := startRetrySender(t, tx, Config{EnableBreaker: true, BreakerThreshold: 2, BreakerCooldown: time.Hour})
+	defer s.Stop()
+
+	s.Add(&transmission.Event{})
+	s.Add(&transmission.Event{})
+	nextResponse(t, s)
+	nextResponse(t, s)
+	assert.True(t, s.breaker.isOpen())
+
+	s.Add(&transmission.Event{Metadata: "meta"})
+	r := nextResponse(t, s)
+	assert.Equal(t, errBreakerOpen, r.Err)
+	assert.Equal(t, "meta", r.Metadata)
+	assert.Equal(t, 2, tx.addCount(), "the open breaker should keep events from the API")
+	assert.Equal(t, TransmissionStats{Failed: 3, Shed: 1, BreakerTrips: 1, BreakerOpen: true}, s.stats())
+
+	// the probe after the cooldown closes it again
+	s.breaker.lock.Lock()
+	s.breaker.openedAt = time.Now().Add(-time.Hour)
+	s.breaker.lock.Unlock()
+	s.Add(&transmission.Event{})
+	assert.Equal(t, 202, nextResponse(t, s).StatusCode)
+	assert.False(t, s.breaker.isOpen())
+}
+
+func TestTransmissionStatsHandler(t *testing.T) {
+	stats := make(chan TransmissionStats, 10)
+	Init(Config{
+		WriteKey:                  "abc",
+		STDOUT:                    true,
+		DisableEnvConfig:          true,
+		TransmissionStatsHandler:  func(s TransmissionStats) { stats <- s },
+		TransmissionStatsInterval: time.Millisecond,
+	})
+	defer Close()
+
+	select {
+	case s := <-stats:
+		assert.False(t, s.BreakerOpen)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stats handler was never called")
+	}
+}
@@ -0,0 +1,80 @@
+package beeline
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// newTransport returns the transport events, markers and the remote config
+// are sent with, built from the config's Transport, proxy, TLS and timeout
+// settings. It returns nil if none are set, so the default transport is
+// used.
+func newTransport(config Config) (http.RoundTripper, error) {
+	if config.Transport != nil {
+		return config.Transport, nil
+	}
+	if config.ProxyURL == "" && config.TLSConfig == nil && config.CACertFile == "" &&
+		config.DialTimeout == 0 && config.TLSHandshakeTimeout == 0 && config.ResponseHeaderTimeout == 0 {
+		return nil, nil
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if config.ProxyURL != "" {
+		proxy, err := url.Parse(config.ProxyURL)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("beeline: the proxy URL %q is not a valid URL", config.ProxyURL)
+		}
+		tr.Proxy = http.ProxyURL(proxy)
+	}
+	if config.TLSConfig != nil {
+		tr.TLSClientConfig = config.TLSConfig.Clone()
+	}
+	if config.CACertFile != "" {
+		pem, err := ioutil.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("beeline: failed to read CA certificates: %s", err)
+		}
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+		pool := tr.TLSClientConfig.RootCAs
+		if pool == nil {
+			// add to the system's CAs rather than replace them, so
+			// Honeycomb's own certificate is still trusted
+			if pool, err = x509.SystemCertPool(); err != nil {
+				pool = x509.NewCertPool()
+			}
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("beeline: no certificates found in %s", config.CACertFile)
+		}
+		tr.TLSClientConfig.RootCAs = pool
+	}
+	if config.DialTimeout > 0 {
+		tr.DialContext = (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if config.TLSHandshakeTimeout > 0 {
+		tr.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	}
+	if config.ResponseHeaderTimeout > 0 {
+		tr.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	}
+	return tr, nil
+}
+
+// httpClientFor returns a client that uses transport, or client if transport
+// is nil.
+func httpClientFor(transport http.RoundTripper, client *http.Client) *http.Client {
+	if transport == nil {
+		return client
+	}
+	return &http.Client{Transport: transport, Timeout: client.Timeout}
+}
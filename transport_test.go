@@ -0,0 +1,83 @@
+package beeline
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTransport(t *testing.T) {
+	tr, err := newTransport(Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, tr, "the default transport should be used when nothing is set")
+
+	_, err = newTransport(Config{ProxyURL: "not a proxy"})
+	assert.EqualError(t, err, `beeline: the proxy URL "not a proxy" is not a valid URL`)
+	assert.Error(t, Config{WriteKey: "abc", ProxyURL: "not a proxy"}.Validate())
+
+	_, err = newTransport(Config{CACertFile: "/does/not/exist.pem"})
+	assert.Error(t, err)
+}
+
+func TestCACertFile(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	// the handshake that fails below is logged otherwise
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "beeline-ca")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.NoError(t, ioutil.WriteFile(caFile, certPEM, 0600))
+
+	tr, err := newTransport(Config{CACertFile: caFile})
+	if assert.NoError(t, err) {
+		resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if assert.NoError(t, err, "the server's certificate should be trusted") {
+			resp.Body.Close()
+		}
+	}
+	_, err = http.Get(srv.URL)
+	assert.Error(t, err, "but not by default")
+}
+
+func TestProxyURL(t *testing.T) {
+	var lock sync.Mutex
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hosts = append(hosts, r.URL.Host)
+		lock.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"status":202}]`))
+	}))
+	defer proxy.Close()
+
+	Init(Config{
+		WriteKey:         "abc",
+		APIHost:          "http://honeycomb.invalid",
+		ProxyURL:         proxy.URL,
+		DisableEnvConfig: true,
+	})
+	_, span := StartSpan(context.Background(), "work")
+	span.Send()
+	Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if assert.NotEmpty(t, hosts, "events should be sent through the proxy") {
+		assert.Equal(t, "honeycomb.invalid", hosts[0])
+	}
+}
@@ -0,0 +1,110 @@
+package beeline
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/libhoney-go/transmission"
+)
+
+// ErrMissingWriteKey is returned by InitWithError when no write key is set
+// and events would be sent to Honeycomb, which would reject them all.
+var ErrMissingWriteKey = errors.New("beeline: a write key is needed to send events to Honeycomb")
+
+// InitWithError checks config, with unset fields taken from the environment
+// as Init does, for mistakes that would stop events reaching Honeycomb, then
+// initializes the beeline as Init does. If the config is invalid, or an
+// environment variable can't be parsed, the beeline is left as it was and the
+// problem is returned. Errors found while initializing, such as a spool
+// directory that can't be opened, are returned too, though the beeline is
+// initialized as well as it can be.
+//
+// What Honeycomb thinks of the write key and dataset is only known once events
+// are sent; use TransmissionErrorHandler to hear about rejected events.
+func InitWithError(config Config) error {
+	config, err := applyEnvConfig(config)
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	return initialize(config)
+}
+
+// Validate returns an error describing the first problem found with the
+// config, or nil if there is none.
+func (c Config) Validate() error {
+	sending := c.Client == nil && !c.STDOUT && !c.Mute
+	if sending && (c.WriteKey == "" || c.WriteKey == defaultWriteKey) {
+		return ErrMissingWriteKey
+	}
+	if strings.TrimSpace(c.WriteKey) != c.WriteKey {
+		return errors.New("beeline: the write key has leading or trailing whitespace")
+	}
+	if strings.TrimSpace(c.Dataset) != c.Dataset {
+		return fmt.Errorf("beeline: the dataset %q has leading or trailing whitespace", c.Dataset)
+	}
+	if c.APIHost != "" {
+		u, err := url.Parse(c.APIHost)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("beeline: the API host %q should be an http or https URL", c.APIHost)
+		}
+	}
+	if _, err := parseTrustedProxies(c.TrustedProxies); err != nil {
+		return err
+	}
+	if c.OverflowPolicy > OverflowBlock {
+		return fmt.Errorf("beeline: unknown overflow policy %d", c.OverflowPolicy)
+	}
+	if c.MaxEventsPerSecondPerRoute < 0 {
+		return fmt.Errorf("beeline: MaxEventsPerSecondPerRoute %v is negative", c.MaxEventsPerSecondPerRoute)
+	}
+	if _, err := newTransport(c); err != nil {
+		return err
+	}
+	if c.HTTPBodyCaptureBytes < 0 {
+		return fmt.Errorf("beeline: HTTPBodyCaptureBytes %d is negative", c.HTTPBodyCaptureBytes)
+	}
+	if c.RefineryCompatible && c.MaxEventsPerSecondPerRoute > 0 {
+		return errors.New("beeline: MaxEventsPerSecondPerRoute drops single spans, breaking traces for Refinery")
+	}
+	if c.STDOUTFormat > STDOUTTree {
+		return fmt.Errorf("beeline: unknown STDOUT format %d", c.STDOUTFormat)
+	}
+	switch c.IDFormat {
+	case "", trace.IDFormatHex, trace.IDFormatHex64, trace.IDFormatUUID:
+	default:
+		return fmt.Errorf("beeline: unknown ID format %q", c.IDFormat)
+	}
+	return nil
+}
+
+// LogTransmissionErrors returns a TransmissionErrorHandler that logs each
+// event that couldn't be sent to l, or to the standard logger if l is nil,
+// with a hint for the most common mistakes.
+func LogTransmissionErrors(l *log.Logger) func(transmission.Response) {
+	printf := log.Printf
+	if l != nil {
+		printf = l.Printf
+	}
+	return func(r transmission.Response) {
+		var hint string
+		switch r.StatusCode {
+		case 401:
+			hint = " (the write key was rejected)"
+		case 400:
+			hint = " (check the dataset name and event fields)"
+		}
+		if r.Err != nil {
+			printf("beeline: failed to send event: %s", r.Err)
+			return
+		}
+		printf("beeline: failed to send event: status %d%s: %s",
+			r.StatusCode, hint, strings.TrimSpace(string(r.Body)))
+	}
+}
@@ -0,0 +1,76 @@
+package beeline
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{"valid", Config{WriteKey: "abc", Dataset: "app"}, ""},
+		{"no write key", Config{}, ErrMissingWriteKey.Error()},
+		{"no write key but muted", Config{Mute: true}, ""},
+		{"no write key but stdout", Config{STDOUT: true}, ""},
+		{"whitespace in write key", Config{WriteKey: "abc\n"}, "beeline: the write key has leading or trailing whitespace"},
+		{"whitespace in dataset", Config{WriteKey: "abc", Dataset: " app"}, `beeline: the dataset " app" has leading or trailing whitespace`},
+		{"bad API host", Config{WriteKey: "abc", APIHost: "api.honeycomb.io"}, `beeline: the API host "api.honeycomb.io" should be an http or https URL`},
+		{"good API host", Config{WriteKey: "abc", APIHost: "https://api.honeycomb.io/"}, ""},
+		{"bad trusted proxy", Config{WriteKey: "abc", TrustedProxies: []string{"nope"}}, `beeline: trusted proxy "nope" is neither an IP address nor a CIDR range`},
+		{"bad overflow policy", Config{WriteKey: "abc", OverflowPolicy: 7}, "beeline: unknown overflow policy 7"},
+		{"negative rate limit", Config{WriteKey: "abc", MaxEventsPerSecondPerRoute: -1}, "beeline: MaxEventsPerSecondPerRoute -1 is negative"},
+		{"rate limit with refinery", Config{WriteKey: "abc", RefineryCompatible: true, MaxEventsPerSecondPerRoute: 5}, "beeline: MaxEventsPerSecondPerRoute drops single spans, breaking traces for Refinery"},
+		{"negative body capture", Config{WriteKey: "abc", HTTPBodyCaptureBytes: -1}, "beeline: HTTPBodyCaptureBytes -1 is negative"},
+		{"uuid IDs", Config{WriteKey: "abc", IDFormat: trace.IDFormatUUID}, ""},
+		{"bad ID format", Config{WriteKey: "abc", IDFormat: "UUID"}, `beeline: unknown ID format "UUID"`},
+	}
+	for _, tt := range tests {
+		err := tt.config.Validate()
+		if tt.err == "" {
+			assert.NoError(t, err, tt.name)
+		} else if assert.Error(t, err, tt.name) {
+			assert.Equal(t, tt.err, err.Error(), tt.name)
+		}
+	}
+}
+
+func TestInitWithError(t *testing.T) {
+	defer setupLibhoney(t)
+
+	assert.Equal(t, ErrMissingWriteKey, InitWithError(Config{}))
+	assert.NoError(t, InitWithError(Config{Mute: true}))
+
+	// the spool can't be a file
+	dir, err := ioutil.TempDir("", "beeline-validate")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, ioutil.WriteFile(file, nil, 0600))
+	err = InitWithError(Config{Mute: true, SpoolDir: file})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "beeline: failed to open spool")
+	}
+}
+
+func TestLogTransmissionErrors(t *testing.T) {
+	var buf bytes.Buffer
+	handler := LogTransmissionErrors(log.New(&buf, "", 0))
+	handler(transmission.Response{StatusCode: 401, Body: []byte("unknown API key\n")})
+	handler(transmission.Response{Err: errors.New("connection refused")})
+	assert.Equal(t, "beeline: failed to send event: status 401 (the write key was rejected): unknown API key\n"+
+		"beeline: failed to send event: connection refused\n", buf.String())
+}
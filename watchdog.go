@@ -0,0 +1,22 @@
+package beeline
+
+import (
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// watchInProgress reports spans open for longer than age, checking twice
+// every age so that none is more than half as old again when reported.
+func watchInProgress(age time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(age / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			trace.SendInProgress(age)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package beeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInProgressAfter(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.NoError(t, err)
+	Init(Config{Client: client, InProgressAfter: 10 * time.Millisecond})
+	defer setupLibhoney(t)
+
+	_, span := StartSpan(context.Background(), "stuck")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(mo.Events()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "stuck", evs[0].Data["name"])
+		assert.Equal(t, true, evs[0].Data["meta.in_progress"])
+		assert.Nil(t, evs[1].Data["meta.in_progress"])
+	}
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/honeycombio/beeline-go/propagation"
+)
+
+// addAmazonTraceFields records the parts of an X-Amzn-Trace-Id header that
+// tie the request to AWS load balancer logs and X-Ray: request.aws.trace_root
+// is the Root as the load balancer logged it, request.aws.self is the load
+// balancer hop that forwarded the request, and request.aws.parent is the X-Ray
+// segment that made it, if any. The header isn't used to continue the trace,
+// as the load balancer makes a new Root for most requests it sees.
+func addAmazonTraceFields(props map[string]interface{}, header string) {
+	for _, segment := range strings.Split(header, ";") {
+		keyval := strings.SplitN(strings.TrimSpace(segment), "=", 2)
+		if len(keyval) < 2 || keyval[1] == "" {
+			continue
+		}
+		switch strings.ToLower(keyval[0]) {
+		case "root":
+			props["request.aws.trace_root"] = keyval[1]
+		case "self":
+			props["request.aws.self"] = keyval[1]
+		case "parent":
+			props["request.aws.parent"] = keyval[1]
+		}
+	}
+}
+
+// echoAmazonTraceHeader copies the request's X-Amzn-Trace-Id header to the
+// response headers h, so that clients can quote it to find the request in
+// load balancer logs and in Honeycomb by request.aws.trace_root.
+func echoAmazonTraceHeader(h http.Header, r *http.Request) {
+	if header := r.Header.Get(propagation.AmazonTracePropagationHTTPHeader); header != "" {
+		h.Set(propagation.AmazonTracePropagationHTTPHeader, header)
+	}
+}
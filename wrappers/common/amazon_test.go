@@ -0,0 +1,20 @@
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmazonTraceFields(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Amzn-Trace-Id", "Self=1-67891234-12456789abcdef012345678;Root=1-67891233-abcdef012345678912345678;Parent=53995c3f42cd8ad8;Sampled=1")
+	props := GetRequestProps(r)
+	assert.Equal(t, "1-67891233-abcdef012345678912345678", props["request.aws.trace_root"])
+	assert.Equal(t, "1-67891234-12456789abcdef012345678", props["request.aws.self"])
+	assert.Equal(t, "53995c3f42cd8ad8", props["request.aws.parent"])
+
+	props = GetRequestProps(httptest.NewRequest("GET", "/", nil))
+	assert.NotContains(t, props, "request.aws.trace_root")
+}
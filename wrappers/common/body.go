@@ -0,0 +1,106 @@
+package common
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// addBodyFields records the request's Content-Type and Content-Encoding
+// headers, and the start of its body if its media type is one of the
+// HTTPBodyContentTypes of the config in its context. The part of the body read is put
+// back in front of the rest, so the handler reads the whole body as usual.
+// Only the bodies of requests received by a server are read, and not those of
+// handlers wrapped with WithoutRequestBodyFields.
+func addBodyFields(props map[string]interface{}, req *http.Request) {
+	contentType := req.Header.Get("Content-Type")
+	if contentType != "" {
+		props["request.header.content_type"] = contentType
+	}
+	encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+	if encoding != "" {
+		props["request.header.content_encoding"] = encoding
+	}
+	config := trace.GetConfigFromContext(req.Context())
+	limit := config.HTTPBodyCaptureBytes
+	if len(config.HTTPBodyContentTypes) == 0 || limit <= 0 ||
+		req.RequestURI == "" || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	if omit, _ := req.Context().Value(omitRequestBodyKey{}).(bool); omit {
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !bodyTypeCaptured(config.HTTPBodyContentTypes, mediaType) {
+		return
+	}
+
+	// read one more byte than is recorded to find out if there's more
+	var raw []byte
+	switch encoding {
+	case "", "identity":
+		raw, err = ioutil.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+		req.Body = readCloser{io.MultiReader(bytes.NewReader(raw), req.Body), req.Body}
+	case "gzip", "deflate":
+		// the compressed body is read as it is decoded, and put back as it
+		// was sent
+		var read bytes.Buffer
+		tee := io.TeeReader(req.Body, &read)
+		var decoder io.Reader
+		if encoding == "gzip" {
+			decoder, err = gzip.NewReader(tee)
+		} else {
+			decoder = flate.NewReader(tee)
+		}
+		if err == nil {
+			raw, err = ioutil.ReadAll(io.LimitReader(decoder, int64(limit)+1))
+		}
+		req.Body = readCloser{io.MultiReader(&read, req.Body), req.Body}
+	default:
+		return
+	}
+	if err != nil && len(raw) == 0 {
+		props["request.body_error"] = err.Error()
+		return
+	}
+	if len(raw) > limit {
+		raw = raw[:limit]
+		props["request.body_truncated"] = true
+	}
+	if redact := config.HTTPBodyRedactor; redact != nil {
+		raw = redact(mediaType, raw)
+		if raw == nil {
+			return
+		}
+	}
+	props["request.body"] = string(raw)
+}
+
+// omitRequestBodyKey marks the context of a request whose body isn't to be
+// recorded.
+type omitRequestBodyKey struct{}
+
+// bodyTypeCaptured returns true if bodies of mediaType are to be recorded,
+// given the content types configured.
+func bodyTypeCaptured(contentTypes []string, mediaType string) bool {
+	for _, t := range contentTypes {
+		t = strings.ToLower(t)
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// readCloser reads from the body put back together and closes the original.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
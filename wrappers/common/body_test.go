@@ -0,0 +1,92 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddBodyFields(t *testing.T) {
+	defer func() {
+		trace.GlobalConfig.HTTPBodyContentTypes = nil
+		trace.GlobalConfig.HTTPBodyCaptureBytes = 0
+		trace.GlobalConfig.HTTPBodyRedactor = nil
+	}()
+	trace.GlobalConfig.HTTPBodyContentTypes = []string{"application/json", "text/*"}
+	trace.GlobalConfig.HTTPBodyCaptureBytes = 8
+
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write([]byte(`{"a":1}`))
+	zw.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        string
+		captured    interface{}
+		truncated   bool
+	}{
+		{"json", "application/json; charset=utf-8", "", `{"a":1}`, `{"a":1}`, false},
+		{"wildcard type", "text/plain", "", "hello", "hello", false},
+		{"truncated", "application/json", "", `{"a":"long"}`, `{"a":"lo`, true},
+		{"gzip", "application/json", "gzip", gzipped.String(), `{"a":1}`, false},
+		{"other type", "application/octet-stream", "", "binary", nil, false},
+		{"unknown encoding", "application/json", "br", "compressed", nil, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+		if tt.encoding != "" {
+			r.Header.Set("Content-Encoding", tt.encoding)
+		}
+		props := GetRequestProps(r)
+		assert.Equal(t, tt.contentType, props["request.header.content_type"], tt.name)
+		assert.Equal(t, tt.captured, props["request.body"], tt.name)
+		if tt.truncated {
+			assert.Equal(t, true, props["request.body_truncated"], tt.name)
+		} else {
+			assert.NotContains(t, props, "request.body_truncated", tt.name)
+		}
+		if tt.encoding != "" {
+			assert.Equal(t, tt.encoding, props["request.header.content_encoding"], tt.name)
+		}
+		// the handler still gets the whole body, as it was sent
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.body, string(body), tt.name)
+	}
+}
+
+func TestAddBodyFieldsRedactor(t *testing.T) {
+	defer func() {
+		trace.GlobalConfig.HTTPBodyContentTypes = nil
+		trace.GlobalConfig.HTTPBodyCaptureBytes = 0
+		trace.GlobalConfig.HTTPBodyRedactor = nil
+	}()
+	trace.GlobalConfig.HTTPBodyContentTypes = []string{"application/json"}
+	trace.GlobalConfig.HTTPBodyCaptureBytes = 1024
+	trace.GlobalConfig.HTTPBodyRedactor = func(contentType string, body []byte) []byte {
+		assert.Equal(t, "application/json", contentType)
+		return bytes.Replace(body, []byte("hunter2"), []byte("*******"), -1)
+	}
+
+	r := httptest.NewRequest("POST", "/login", strings.NewReader(`{"password":"hunter2"}`))
+	r.Header.Set("Content-Type", "application/json")
+	props := GetRequestProps(r)
+	assert.Equal(t, `{"password":"*******"}`, props["request.body"])
+
+	// outgoing requests are left alone
+	r = httptest.NewRequest("POST", "http://example.com/login", strings.NewReader(`{}`))
+	r.RequestURI = ""
+	r.Header.Set("Content-Type", "application/json")
+	props = GetRequestProps(r)
+	assert.NotContains(t, props, "request.body")
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// defaultTrustedProxies are the proxies trusted when the config's
+// TrustedProxies is empty: the loopback and private
+// ranges load balancers are usually found in.
+var defaultTrustedProxies = mustParseCIDRs(
+	"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16",
+	"169.254.0.0/16", "::1/128", "fc00::/7", "fe80::/10",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// clientIP returns the address of the client that made r, looking through the
+// forwarding headers set by trusted proxies, or "" if it can't be told.
+func clientIP(r *http.Request) string {
+	remote := parseAddr(r.RemoteAddr)
+	if remote == nil {
+		return ""
+	}
+	config := trace.GetConfigFromContext(r.Context())
+	if config.IgnoreClientIPHeaders {
+		return remote.String()
+	}
+	trusted := config.TrustedProxies
+	if len(trusted) == 0 {
+		trusted = defaultTrustedProxies
+	}
+	if !isTrusted(remote, trusted) {
+		return remote.String()
+	}
+
+	// the addresses each proxy saw the request come from, oldest first
+	chain := forwardedFor(r.Header)
+	if len(chain) == 0 {
+		chain = splitList(r.Header["X-Forwarded-For"])
+	}
+	if len(chain) == 0 {
+		if ip := parseAddr(r.Header.Get("X-Real-Ip")); ip != nil {
+			return ip.String()
+		}
+		return remote.String()
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseAddr(chain[i])
+		if ip == nil {
+			// an obfuscated or garbled entry; whoever is behind it can't
+			// be named
+			return ""
+		}
+		if i == 0 || !isTrusted(ip, trusted) {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the for= addresses of an RFC 7239 Forwarded header.
+func forwardedFor(h http.Header) []string {
+	var addrs []string
+	for _, elem := range splitList(h["Forwarded"]) {
+		for _, pair := range strings.Split(elem, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				addrs = append(addrs, strings.Trim(kv[1], `"`))
+			}
+		}
+	}
+	return addrs
+}
+
+// splitList splits the comma separated lists in headers sent one or more
+// times.
+func splitList(values []string) []string {
+	var items []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// parseAddr parses an IP address that may have a port, and IPv6 addresses
+// that may be in brackets, as they are in Forwarded headers.
+func parseAddr(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	_, lb, _ := net.ParseCIDR("203.0.113.0/24")
+	defer func() {
+		trace.GlobalConfig.TrustedProxies = nil
+		trace.GlobalConfig.IgnoreClientIPHeaders = false
+	}()
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		trusted bool
+		ignore  bool
+		want    string
+	}{
+		{"direct", "198.51.100.7:5000", nil, false, false, "198.51.100.7"},
+		{"untrusted proxy's headers are ignored", "198.51.100.7:5000",
+			map[string]string{"X-Forwarded-For": "192.0.2.1"}, false, false, "198.51.100.7"},
+		{"private proxies are trusted by default", "10.1.2.3:5000",
+			map[string]string{"X-Forwarded-For": "192.0.2.1, 10.0.0.9"}, false, false, "192.0.2.1"},
+		{"spoofed entries before the client are skipped", "203.0.113.5:5000",
+			map[string]string{"X-Forwarded-For": "1.1.1.1, 192.0.2.1, 203.0.113.6"}, true, false, "192.0.2.1"},
+		{"forwarded wins over x-forwarded-for", "203.0.113.5:5000",
+			map[string]string{
+				"Forwarded":       `for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"`,
+				"X-Forwarded-For": "192.0.2.1",
+			}, true, false, "2001:db8:cafe::17"},
+		{"obfuscated client", "203.0.113.5:5000",
+			map[string]string{"Forwarded": "for=_hidden"}, true, false, ""},
+		{"x-real-ip", "203.0.113.5:5000",
+			map[string]string{"X-Real-IP": "192.0.2.2"}, true, false, "192.0.2.2"},
+		{"headers ignored", "10.1.2.3:5000",
+			map[string]string{"X-Forwarded-For": "192.0.2.1"}, false, true, "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		trace.GlobalConfig.TrustedProxies = nil
+		if tt.trusted {
+			trace.GlobalConfig.TrustedProxies = []*net.IPNet{lb}
+		}
+		trace.GlobalConfig.IgnoreClientIPHeaders = tt.ignore
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		assert.Equal(t, tt.want, clientIP(r), tt.name)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "198.51.100.7:5000"
+	assert.Equal(t, "198.51.100.7", GetRequestProps(r)["request.client_ip"])
+}
@@ -1,11 +1,19 @@
 package common
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"runtime"
 	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/felixge/httpsnoop"
 	"github.com/honeycombio/beeline-go/propagation"
@@ -14,43 +22,262 @@ import (
 	libhoney "github.com/honeycombio/libhoney-go"
 )
 
+// maxPropagationErrorLength bounds the meta.propagation_error field, which
+// can contain parts of headers chosen by whoever sent the request.
+const maxPropagationErrorLength = 256
+
+// AddPropagationError records err, the error from parsing the trace context
+// a request or message carried, on span as meta.propagation_error, so traces
+// that should have been continued but weren't can be found. The error can
+// quote the trace context, so it is cut to maxPropagationErrorLength bytes,
+// between characters. Nothing is added if err is nil.
+func AddPropagationError(span *trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.AddField("meta.propagation_error", truncatePropagationError(err.Error()))
+}
+
+// truncatePropagationError cuts msg to at most maxPropagationErrorLength
+// bytes without splitting a multi-byte character.
+func truncatePropagationError(msg string) string {
+	if len(msg) <= maxPropagationErrorLength {
+		return msg
+	}
+	cut := maxPropagationErrorLength
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut]
+}
+
 type ResponseWriter struct {
 	// Wrapped is not embedded to prevent ResponseWriter from directly
 	// fulfilling the http.ResponseWriter interface. Wrapping in this
 	// way would obscure optional http.ResponseWriter interfaces.
 	Wrapped http.ResponseWriter
-	Status  int
+	// Status is the final status code sent to the client. Informational (1xx)
+	// responses are never recorded here. It is zero until either WriteHeader
+	// or Write is called.
+	Status int
+	// InformationalResponses counts the 1xx responses sent ahead of the final
+	// status. They are passed through to the wrapped writer.
+	InformationalResponses int
+	// SuperfluousWriteHeaders counts the calls to WriteHeader made after the
+	// status was already sent, either explicitly or implicitly by a Write.
+	// They are not passed through to the wrapped writer.
+	SuperfluousWriteHeaders int
+	// BytesWritten counts the bytes of the response body written.
+	BytesWritten int64
+	// FirstByte is when the handler first wrote anything, a header or the
+	// body, or flushed the response. It is zero until then.
+	FirstByte time.Time
+	// Hijacked is set once the handler has taken over the connection, eg to
+	// upgrade it to a websocket. Nothing written to the hijacked connection
+	// is seen by the ResponseWriter.
+	Hijacked bool
+
+	// started is when the writer was made, at the start of the request.
+	started time.Time
+	// progress, if set, is also updated with BytesWritten, for a stream
+	// reporting on the response while it is written.
+	progress *streamProgress
 }
 
 func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
-	var rw ResponseWriter
+	rw := ResponseWriter{started: time.Now()}
 
 	rw.Wrapped = httpsnoop.Wrap(w, httpsnoop.Hooks{
 		WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
 			return func(code int) {
-				// The first call to WriteHeader sends the response header.
-				// Any subsequent calls are invalid. Only record the first
-				// code written.
-				if rw.Status == 0 {
+				// The first call to WriteHeader with a final status sends the
+				// response header. Any subsequent calls are invalid, so only
+				// record the first code written and drop the rest.
+				if rw.Status != 0 {
+					rw.SuperfluousWriteHeaders++
+					return
+				}
+				if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+					rw.InformationalResponses++
+				} else {
 					rw.Status = code
 				}
+				rw.markFirstByte()
 				next(code)
 			}
 		},
+		Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return func(b []byte) (int, error) {
+				// writing the body without calling WriteHeader first sends an
+				// implicit 200
+				if rw.Status == 0 {
+					rw.Status = http.StatusOK
+				}
+				rw.markFirstByte()
+				n, err := next(b)
+				rw.addBytes(int64(n))
+				return n, err
+			}
+		},
+		ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				if rw.Status == 0 {
+					rw.Status = http.StatusOK
+				}
+				rw.markFirstByte()
+				n, err := next(src)
+				rw.addBytes(n)
+				return n, err
+			}
+		},
+		Hijack: func(next httpsnoop.HijackFunc) httpsnoop.HijackFunc {
+			return func() (net.Conn, *bufio.ReadWriter, error) {
+				conn, brw, err := next()
+				if err == nil {
+					rw.Hijacked = true
+					// handlers hijack connections to switch protocols, and
+					// write the 101 themselves
+					if rw.Status == 0 {
+						rw.Status = http.StatusSwitchingProtocols
+					}
+				}
+				return conn, brw, err
+			}
+		},
+		Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+			return func() {
+				rw.markFirstByte()
+				next()
+			}
+		},
 	})
 
 	return &rw
 }
 
+func (rw *ResponseWriter) addBytes(n int64) {
+	rw.BytesWritten += n
+	if rw.progress != nil {
+		atomic.AddInt64(&rw.progress.bytes, n)
+	}
+}
+
+func (rw *ResponseWriter) markFirstByte() {
+	if rw.FirstByte.IsZero() {
+		rw.FirstByte = time.Now()
+	}
+}
+
+// AddResponseFields adds the size of the response body to span as
+// response.bytes_written, and how long the handler took to start the
+// response as response.time_to_first_byte_ms, which for streamed responses
+// says more than the span's duration does. Responses whose connection was
+// hijacked get response.hijacked.
+func (rw *ResponseWriter) AddResponseFields(span *trace.Span) {
+	span.AddField("response.bytes_written", rw.BytesWritten)
+	if !rw.FirstByte.IsZero() {
+		span.AddField("response.time_to_first_byte_ms", float64(rw.FirstByte.Sub(rw.started))/float64(time.Millisecond))
+	}
+	if rw.Hijacked {
+		span.AddField("response.hijacked", true)
+	}
+}
+
+// RecordPanic must be deferred by wrappers after `defer span.Send()`. If the
+// handler panics, it records the response the client will get on span - a
+// 500, unless the handler had already written its status - and then panics
+// again with the same value, so that Send records the panic on the span.
+func (rw *ResponseWriter) RecordPanic(span *trace.Span) {
+	if r := recover(); r != nil {
+		status := rw.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		span.AddField("response.status_code", status)
+		rw.AddResponseFields(span)
+		rw.AddAnomalyFields(span)
+		panic(r)
+	}
+}
+
+// AddAnomalyFields adds meta fields to span describing any unusual use of the
+// ResponseWriter by the handler, such as calling WriteHeader more than once.
+// Nothing is added for well behaved handlers.
+func (rw *ResponseWriter) AddAnomalyFields(span *trace.Span) {
+	if rw.InformationalResponses > 0 {
+		span.AddField("meta.informational_responses", rw.InformationalResponses)
+	}
+	if rw.SuperfluousWriteHeaders > 0 {
+		span.AddField("meta.superfluous_write_headers", rw.SuperfluousWriteHeaders)
+	}
+}
+
+// AddContextFields records on span, the span for a request, whether ctx, the
+// request's context, was done by the time the handler returned, so cancelled
+// requests can be told apart from slow ones. request.context_error holds why
+// it was done, and request.timeout is true if its deadline passed rather than
+// the client going away. A deadline the context had is recorded as
+// request.deadline.
+func AddContextFields(ctx context.Context, span *trace.Span) {
+	if deadline, ok := ctx.Deadline(); ok {
+		span.AddField("request.deadline", deadline)
+	}
+	if err := ctx.Err(); err != nil {
+		span.AddField("request.context_error", err.Error())
+		span.AddField("request.timeout", err == context.DeadlineExceeded)
+	}
+}
+
+// IgnoreRequest reports whether the HTTP wrappers should pass r straight to
+// the handler without creating a span for it, as configured with the
+// IgnoreRequestHook of the config in r's context.
+func IgnoreRequest(r *http.Request) bool {
+	hook := trace.GetConfigFromContext(r.Context()).IgnoreRequestHook
+	return hook != nil && hook(r)
+}
+
+// AddTraceFieldsFromRequest adds the fields the TraceFieldsHook of its config
+// returns for r to the trace in r's context, prefixed with `app.`. It does
+// nothing if there is no hook or no trace.
+func AddTraceFieldsFromRequest(r *http.Request) {
+	tr := trace.GetTraceFromContext(r.Context())
+	if tr == nil {
+		return
+	}
+	hook := tr.GetConfig().TraceFieldsHook
+	if hook == nil {
+		return
+	}
+	for k, v := range hook(r) {
+		if v != nil {
+			tr.AddField("app."+k, v)
+		}
+	}
+}
+
 func StartSpanOrTraceFromHTTP(r *http.Request) (context.Context, *trace.Span) {
 	ctx := r.Context()
 	span := trace.GetSpanFromContext(ctx)
 	if span == nil {
 		// there is no trace yet. We should make one! and use the root span.
-		beelineHeader := r.Header.Get(propagation.TracePropagationHTTPHeader)
+		prop, propErr := parseTraceHeaders(r)
 		var tr *trace.Trace
-		ctx, tr = trace.NewTrace(ctx, beelineHeader)
+		ctx, tr = trace.NewTraceFromPropagationContext(ctx, prop)
 		span = tr.GetRootSpan()
+		if propErr != "" {
+			// we couldn't make sense of the upstream trace headers; say so
+			// rather than silently starting a fresh trace
+			span.AddField("meta.propagation_error", propErr)
+		}
+		if hook := tr.GetConfig().TenantHook; hook != nil {
+			tenant, dataset := hook(r)
+			if tenant != "" {
+				tr.AddField("tenant", tenant)
+			}
+			if dataset != "" {
+				tr.SetDataset(dataset)
+			}
+		}
 	} else {
 		// we had a parent! let's make a new child for this handler
 		ctx, span = span.CreateChild(ctx)
@@ -62,9 +289,105 @@ func StartSpanOrTraceFromHTTP(r *http.Request) (context.Context, *trace.Span) {
 	return ctx, span
 }
 
+// parseTraceHeaders returns the propagation context from the first of the
+// Honeycomb trace header, the W3C traceparent and tracestate headers, the B3
+// single header and the B3 multiple headers that the request has, along with
+// a description of any trace headers that could not be parsed. Trace IDs from
+// W3C and B3 headers are put in the IDFormat of the config in r's context.
+// AWS headers aren't used to continue traces, but malformed ones are still
+// reported. Parse errors can quote the header they failed on, so the
+// description is capped at maxPropagationErrorLength.
+func parseTraceHeaders(r *http.Request) (*propagation.PropagationContext, string) {
+	config := trace.GetConfigFromContext(r.Context())
+	var prop *propagation.PropagationContext
+	var errs []string
+	if header := r.Header.Get(propagation.TracePropagationHTTPHeader); header != "" {
+		var err error
+		prop, err = propagation.UnmarshalHoneycombTraceContext(header)
+		if err != nil {
+			errs = append(errs, propagation.TracePropagationHTTPHeader+": "+err.Error())
+		}
+	}
+	if header := r.Header.Get(propagation.AmazonTracePropagationHTTPHeader); header != "" {
+		if _, err := propagation.UnmarshalAmazonTraceContext(header); err != nil {
+			errs = append(errs, propagation.AmazonTracePropagationHTTPHeader+": "+err.Error())
+		}
+	}
+	if header := r.Header.Get(propagation.W3CTraceParentHTTPHeader); header != "" {
+		headers := map[string]string{
+			propagation.W3CTraceParentHTTPHeader: header,
+			propagation.W3CTraceStateHTTPHeader:  r.Header.Get(propagation.W3CTraceStateHTTPHeader),
+		}
+		_, w3cProp, err := propagation.UnmarshalW3CTraceContext(r.Context(), headers)
+		if err != nil {
+			errs = append(errs, propagation.W3CTraceParentHTTPHeader+": "+err.Error())
+		} else if prop == nil {
+			w3cProp.TraceID = config.PropagatedTraceID(w3cProp.TraceID)
+			prop = w3cProp
+		}
+	}
+	if header := r.Header.Get(propagation.B3SingleHTTPHeader); header != "" {
+		b3Prop, err := propagation.UnmarshalB3SingleTraceContext(header)
+		if err != nil {
+			errs = append(errs, propagation.B3SingleHTTPHeader+": "+err.Error())
+		} else if prop == nil {
+			b3Prop.TraceID = config.PropagatedTraceID(b3Prop.TraceID)
+			prop = b3Prop
+		}
+	}
+	if header := r.Header.Get(propagation.B3TraceIDHTTPHeader); header != "" {
+		headers := map[string]string{propagation.B3TraceIDHTTPHeader: header}
+		for _, name := range []string{propagation.B3SpanIDHTTPHeader, propagation.B3SampledHTTPHeader, propagation.B3FlagsHTTPHeader} {
+			headers[name] = r.Header.Get(name)
+		}
+		b3Prop, err := propagation.UnmarshalB3TraceContext(headers)
+		if err != nil {
+			errs = append(errs, propagation.B3TraceIDHTTPHeader+": "+err.Error())
+		} else if prop == nil {
+			b3Prop.TraceID = config.PropagatedTraceID(b3Prop.TraceID)
+			prop = b3Prop
+		}
+	}
+	return prop, truncatePropagationError(strings.Join(errs, "; "))
+}
+
+// SetTraceHeaders sets the trace headers on an outgoing request so that the
+// service it goes to continues the trace as children of span: the Honeycomb
+// header, and the W3C traceparent and tracestate headers for services
+// instrumented with OpenTelemetry, plus the B3 headers chosen by the B3Format
+// of the span's trace. W3C and B3 headers are left out for traces
+// whose IDs can't be expressed in them, eg those continued from a Honeycomb
+// header with a short trace ID.
+func SetTraceHeaders(h http.Header, span *trace.Span) {
+	h.Set(propagation.TracePropagationHTTPHeader, span.SerializeHeaders())
+	prop := span.PropagationContext()
+	_, w3c := propagation.MarshalW3CTraceContext(context.Background(), prop)
+	for k, v := range w3c {
+		if v != "" {
+			h.Set(k, v)
+		}
+	}
+	config := &trace.GlobalConfig
+	if tr := span.GetTrace(); tr != nil {
+		config = tr.GetConfig()
+	}
+	switch config.B3Format {
+	case propagation.B3Single:
+		if b3 := propagation.MarshalB3SingleTraceContext(prop); b3 != "" {
+			h.Set(propagation.B3SingleHTTPHeader, b3)
+		}
+	case propagation.B3Multi:
+		for k, v := range propagation.MarshalB3TraceContext(prop) {
+			h.Set(k, v)
+		}
+	}
+}
+
 // GetRequestProps is a convenient method to grab all common http request
-// properties and get them back as a map.
+// properties and get them back as a map. What is recorded follows the config
+// in req's context.
 func GetRequestProps(req *http.Request) map[string]interface{} {
+	config := trace.GetConfigFromContext(req.Context())
 	userAgent := req.UserAgent()
 	xForwardedFor := req.Header.Get("x-forwarded-for")
 	xForwardedProto := req.Header.Get("x-forwarded-proto")
@@ -84,6 +407,9 @@ func GetRequestProps(req *http.Request) map[string]interface{} {
 	reqProps["request.http_version"] = req.Proto
 	reqProps["request.content_length"] = req.ContentLength
 	reqProps["request.remote_addr"] = req.RemoteAddr
+	if ip := clientIP(req); ip != "" {
+		reqProps["request.client_ip"] = ip
+	}
 	if userAgent != "" {
 		reqProps["request.header.user_agent"] = userAgent
 	}
@@ -93,59 +419,222 @@ func GetRequestProps(req *http.Request) map[string]interface{} {
 	if xForwardedProto != "" {
 		reqProps["request.header.x_forwarded_proto"] = xForwardedProto
 	}
+	if header := req.Header.Get(propagation.AmazonTracePropagationHTTPHeader); header != "" {
+		addAmazonTraceFields(reqProps, header)
+	}
+	if config.ParseUserAgents {
+		AddUserAgentFields(reqProps)
+	}
+	addCapturedHeaders(reqProps, req.Header, config.HTTPHeadersToCapture)
+	addBodyFields(reqProps, req)
+	if len(config.HTTPQueryParamsToCapture) > 0 && req.URL.RawQuery != "" {
+		addCapturedQueryParams(reqProps, req.URL.Query(), config)
+	}
 	return reqProps
 }
 
-// getCallersNames grabs the current call stack, skips up a few levels, then
-// grabs as many function names as depth. Suggested use is something like 1, 2
-// meaning "get my parent and its parent". skip=0 means the function calling
-// this one.
-func getCallersNames(skip, depth int) []string {
-	callers := make([]string, 0, depth)
-	callerPcs := make([]uintptr, depth)
-	// add 2 to skip to account for runtime.Callers and getCallersNames
+// redactedHeaders hold credentials, so their values are never recorded even
+// when they are listed in HTTPHeadersToCapture.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// addCapturedHeaders adds the headers listed in names to props as
+// request.header.<name>, lower cased with dashes replaced by underscores.
+// Headers sent more than once are joined with commas.
+func addCapturedHeaders(props map[string]interface{}, h http.Header, names []string) {
+	for _, name := range names {
+		key := http.CanonicalHeaderKey(name)
+		values := h[key]
+		if len(values) == 0 {
+			continue
+		}
+		field := "request.header." + strings.Replace(strings.ToLower(key), "-", "_", -1)
+		if redactedHeaders[key] {
+			props[field] = "[REDACTED]"
+			continue
+		}
+		props[field] = strings.Join(values, ", ")
+	}
+}
+
+// secretQueryParamWords are the words in query parameter names that mark them
+// as holding credentials, so their values are never recorded.
+var secretQueryParamWords = []string{"token", "key", "password", "secret", "auth", "signature", "session"}
+
+// addCapturedQueryParams adds the query parameters chosen by config's
+// HTTPQueryParamsToCapture and HTTPQueryParamsToOmit to props as
+// request.query_param.<name>, lower cased with dashes replaced by
+// underscores. Parameters given more than once are joined with commas.
+func addCapturedQueryParams(props map[string]interface{}, query url.Values, config *trace.Config) {
+	capture := make(map[string]bool)
+	for _, name := range config.HTTPQueryParamsToCapture {
+		capture[strings.ToLower(name)] = true
+	}
+	omit := make(map[string]bool)
+	for _, name := range config.HTTPQueryParamsToOmit {
+		omit[strings.ToLower(name)] = true
+	}
+	for name, values := range query {
+		lower := strings.ToLower(name)
+		if omit[lower] || !(capture["*"] || capture[lower]) {
+			continue
+		}
+		field := "request.query_param." + strings.Replace(lower, "-", "_", -1)
+		if isSecretQueryParam(lower) {
+			props[field] = "[REDACTED]"
+			continue
+		}
+		props[field] = strings.Join(values, ", ")
+	}
+}
+
+func isSecretQueryParam(lowerName string) bool {
+	for _, word := range secretQueryParamWords {
+		if strings.Contains(lowerName, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// dbWrapperPackages are the packages of the beeline's DB wrappers, whose
+// functions are passed over when looking for the function that made a DB call.
+var dbWrapperPackages = []string{
+	"github.com/honeycombio/beeline-go/wrappers/common",
+	"github.com/honeycombio/beeline-go/wrappers/hnysql",
+	"github.com/honeycombio/beeline-go/wrappers/hnysqlx",
+}
+
+// isDBLayerFrame reports whether fr is in one of the beeline's DB wrappers,
+// or one of the packages in config.DBCallerPackages, rather than in the code
+// using them.
+func isDBLayerFrame(fr runtime.Frame, config *trace.Config) bool {
+	pkg := FuncPackage(fr.Function)
+	if pkg == "" {
+		return false
+	}
+	for _, p := range dbWrapperPackages {
+		// tests of the wrappers are callers like any other
+		if pkg == p && !strings.HasSuffix(fr.File, "_test.go") {
+			return true
+		}
+	}
+	for _, p := range config.DBCallerPackages {
+		if pkg == p {
+			return true
+		}
+	}
+	return false
+}
+
+// dbCallerNames walks up the call stack from skip, where skip=0 means the
+// function calling this one, to find the DB call being made and the function
+// that made it. The call is the outermost function of the DB layer above
+// skip, so a wrapper method that calls another, such as MustExec calling
+// Exec, is named for the method the application called. config lists the
+// packages outside the beeline that are part of the DB layer.
+func dbCallerNames(skip int, config *trace.Config) (call, caller string) {
+	callerPcs := make([]uintptr, 32)
+	// add 2 to skip to account for runtime.Callers and dbCallerNames
 	numCallers := runtime.Callers(skip+2, callerPcs)
 	// If there are no callers, the entire stacktrace is nil
 	if numCallers == 0 {
-		return callers
+		return "", ""
 	}
-	callersFrames := runtime.CallersFrames(callerPcs)
-	for i := 0; i < depth; i++ {
-		fr, more := callersFrames.Next()
-		// store the function's name
-		nameParts := strings.Split(fr.Function, ".")
-		callers = append(callers, nameParts[len(nameParts)-1])
-		if !more {
-			break
+	frames := runtime.CallersFrames(callerPcs[:numCallers])
+	fr, more := frames.Next()
+	call = shortFuncName(fr.Function)
+	for more {
+		fr, more = frames.Next()
+		if !isDBLayerFrame(fr, config) {
+			return call, shortFuncName(fr.Function)
 		}
+		call = shortFuncName(fr.Function)
 	}
-	return callers
+	return call, ""
 }
 
-func sharedDBEvent(bld *libhoney.Builder, query string, args ...interface{}) *libhoney.Event {
+// shortFuncName returns the last part of the function name, such as
+// "QueryContext" for "github.com/honeycombio/beeline-go/wrappers/hnysql.(*DB).QueryContext".
+func shortFuncName(name string) string {
+	nameParts := strings.Split(name, ".")
+	return nameParts[len(nameParts)-1]
+}
+
+func sharedDBEvent(config *trace.Config, bld *libhoney.Builder, query string, args ...interface{}) *libhoney.Event {
 	ev := bld.NewEvent()
 
-	// skip 2 - this one and the buildDB*, so we get the sqlx function and its parent
-	callerNames := getCallersNames(2, 2)
-	switch len(callerNames) {
-	case 2:
-		ev.AddField("db.call", callerNames[0])
-		ev.AddField("name", callerNames[0])
-		ev.AddField("db.caller", callerNames[1])
-	case 1:
-		ev.AddField("db.call", callerNames[0])
-		ev.AddField("name", callerNames[0])
-	default:
+	// skip 2 - this one and the buildDB*, so we start at the sqlx function
+	call, caller := dbCallerNames(2, config)
+	if call != "" {
+		ev.AddField("db.call", call)
+		ev.AddField("name", call)
+	} else {
 		ev.AddField("name", "db")
 	}
+	if caller != "" {
+		ev.AddField("db.caller", caller)
+	}
+
+	addDBQueryFields(config, ev, query, args)
+	return ev
+}
+
+// dbCallNameKey holds the name given to a DB call with WithDBCallName.
+type dbCallNameKey struct{}
+
+// WithDBCallName returns a context that names the DB call it is passed to,
+// in `name` and `db.call`, instead of the DB wrapper naming it after the
+// method called, such as "QueryContext". Only the call ctx is passed to is
+// named; any calls made with the context it returns, such as those in a
+// transaction, are named as usual.
+func WithDBCallName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, dbCallNameKey{}, name)
+}
 
-	if query != "" {
-		ev.AddField("db.query", query)
+// nameDBCall names span after the name ctx was given by WithDBCallName, if
+// any, and returns a context without it.
+func nameDBCall(ctx context.Context, span *trace.Span) context.Context {
+	name, _ := ctx.Value(dbCallNameKey{}).(string)
+	if name == "" {
+		return ctx
 	}
-	if args != nil {
-		ev.AddField("db.query_args", args)
+	span.AddField("db.call", name)
+	span.AddField("name", name)
+	return context.WithValue(ctx, dbCallNameKey{}, "")
+}
+
+// dbPanic carries a value recovered from a panicking DB call to the finisher
+// returned by BuildDBEvent or BuildDBSpan.
+type dbPanic struct {
+	value interface{}
+}
+
+func (p *dbPanic) Error() string {
+	return fmt.Sprintf("panic: %v", p.value)
+}
+
+// FinishDBCall calls finish, the function returned by BuildDBEvent or
+// BuildDBSpan, with the error errp points to. It is meant to be deferred
+// directly rather than from within another deferred function, so that it
+// can see panics:
+//
+//	var err error
+//	ev, sender := common.BuildDBEvent(...)
+//	defer common.FinishDBCall(sender, &err)
+//
+// If the DB call panicked, the event is still timed and sent, with `error` set
+// to "panic" and the recovered value in `panic.value`. The panic then
+// continues with the same value.
+func FinishDBCall(finish func(error), errp *error) {
+	if r := recover(); r != nil {
+		finish(&dbPanic{r})
+		panic(r)
 	}
-	return ev
+	finish(*errp)
 }
 
 // BuildDBEvent tries to bring together most of the things that need to happen
@@ -156,54 +645,111 @@ func sharedDBEvent(bld *libhoney.Builder, query string, args ...interface{}) *li
 // if context is available, use BuildDBSpan() instead to tie it in to the active
 // trace.
 func BuildDBEvent(bld *libhoney.Builder, stats sql.DBStats, query string, args ...interface{}) (*libhoney.Event, func(error)) {
-	timer := timer.Start()
-	ev := sharedDBEvent(bld, query, args)
+	tm := timer.Start().(timer.DurationTimer)
+	ev := sharedDBEvent(&trace.GlobalConfig, bld, query, args...)
 	addDBStatsToEvent(ev, stats)
-	fn := func(err error) {
-		duration := timer.Finish()
+	return ev, dbEventSender(&trace.GlobalConfig, ev, tm)
+}
+
+// dbEventSender returns the function that finishes and sends ev, the event
+// for a DB call timed by t, with config.
+func dbEventSender(config *trace.Config, ev *libhoney.Event, t timer.DurationTimer) func(error) {
+	return func(err error) {
+		// read the clock once so duration_ms and duration_ns agree
+		duration := t.FinishDuration()
 		// rollup(ctx, ev, duration)
-		ev.AddField("duration_ms", duration)
+		ev.AddField("duration_ms", float64(duration)/float64(time.Millisecond))
+		if config.RecordDurationNanos {
+			ev.AddField("duration_ns", int64(duration))
+		}
 		if err != nil {
 			ev.AddField("db.error", err.Error())
+			if p, ok := err.(*dbPanic); ok {
+				ev.AddField("error", "panic")
+				ev.AddField("panic.value", fmt.Sprint(p.value))
+			}
 		}
 		ev.Metadata, _ = ev.Fields()["name"]
-		ev.Send()
+		config.SendEvent(ev)
 	}
-	return ev, fn
 }
 
-// BuildDBSpan does the same things as BuildDBEvent except that it has access to
-// a trace from the context and takes advantage of that to add the DB events
-// into the trace.
-func BuildDBSpan(ctx context.Context, bld *libhoney.Builder, stats sql.DBStats, query string, args ...interface{}) (context.Context, *trace.Span, func(error)) {
-	timer := timer.Start()
+// StartChildSpan creates a child of the span in ctx for timing a single
+// operation, such as a DB call. If ctx has no span, it starts a new trace and
+// returns its root span, marked with `meta.orphaned`.
+func StartChildSpan(ctx context.Context) (context.Context, *trace.Span) {
 	parentSpan := trace.GetSpanFromContext(ctx)
-	var span *trace.Span
 	if parentSpan == nil {
 		// if we have no trace, make a new one. This is unfortunate but the
 		// least confusing possibility. Would be nice to indicate this had
 		// happened in a better way than yet another meta. field.
-		var tr *trace.Trace
-		ctx, tr = trace.NewTrace(ctx, "")
-		span = tr.GetRootSpan()
+		ctx, tr := trace.NewTrace(ctx, "")
+		span := tr.GetRootSpan()
 		span.AddField("meta.orphaned", true)
-	} else {
-		ctx, span = parentSpan.CreateChild(ctx)
+		return ctx, span
 	}
+	return parentSpan.CreateChild(ctx)
+}
+
+// BuildDBSpan does the same things as BuildDBEvent except that it has access to
+// a trace from the context and takes advantage of that to add the DB events
+// into the trace.
+func BuildDBSpan(ctx context.Context, bld *libhoney.Builder, stats sql.DBStats, query string, args ...interface{}) (context.Context, *trace.Span, func(error)) {
+	timer := timer.Start()
+	ctx, span := StartChildSpan(ctx)
+	addDBStatsToSpan(span, stats)
+
+	ev := sharedDBEvent(span.GetTrace().GetConfig(), bld, query, args...)
+	for k, v := range ev.Fields() {
+		span.AddField(k, v)
+	}
+	ctx = nameDBCall(ctx, span)
+	return ctx, span, dbSpanSender(span, timer)
+}
+
+// BuildDBCall is for DB calls made without a context on something that may
+// remember one, such as a transaction begun with a context. If ctx has a span,
+// the call is timed with a child of it as BuildDBSpan does. Otherwise, and if
+// ctx is nil, it's timed with an event of its own as BuildDBEvent does, sent
+// with the config in ctx.
+func BuildDBCall(ctx context.Context, bld *libhoney.Builder, stats sql.DBStats, query string, args ...interface{}) (FieldAdder, func(error)) {
+	if ctx == nil || trace.GetSpanFromContext(ctx) == nil {
+		config := trace.GetConfigFromContext(ctx)
+		tm := timer.Start().(timer.DurationTimer)
+		ev := sharedDBEvent(config, bld, query, args...)
+		addDBStatsToEvent(ev, stats)
+		return ev, dbEventSender(config, ev, tm)
+	}
+	timer := timer.Start()
+	_, span := StartChildSpan(ctx)
 	addDBStatsToSpan(span, stats)
 
-	ev := sharedDBEvent(bld, query, args...)
+	ev := sharedDBEvent(span.GetTrace().GetConfig(), bld, query, args...)
 	for k, v := range ev.Fields() {
 		span.AddField(k, v)
 	}
-	fn := func(err error) {
-		duration := timer.Finish()
+	return span, dbSpanSender(span, timer)
+}
+
+// FieldAdder is the event or span a DB call is recorded with.
+type FieldAdder interface {
+	AddField(key string, val interface{})
+}
+
+// dbSpanSender returns the function that finishes and sends span, the span for
+// a DB call timed by t.
+func dbSpanSender(span *trace.Span, t timer.Timer) func(error) {
+	return func(err error) {
+		duration := t.Finish()
 		if err != nil {
 			span.AddField("db.error", err.Error())
+			if p, ok := err.(*dbPanic); ok {
+				span.AddField("error", "panic")
+				span.AddField("panic.value", fmt.Sprint(p.value))
+			}
 		}
 		span.AddRollupField("db.duration_ms", duration)
 		span.AddRollupField("db.call_count", 1)
 		span.Send()
 	}
-	return ctx, span, fn
 }
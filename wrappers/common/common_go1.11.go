@@ -4,6 +4,7 @@ package common
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/honeycombio/beeline-go/trace"
 	libhoney "github.com/honeycombio/libhoney-go"
@@ -24,3 +25,13 @@ func addDBStatsToSpan(span *trace.Span, stats sql.DBStats) {
 	span.AddField("db.wait_count", stats.WaitCount)
 	span.AddField("db.wait_duration", stats.WaitDuration)
 }
+
+// addDBStatsDeltas adds how much waiting for a connection went on between
+// prev and cur.
+func addDBStatsDeltas(ev *libhoney.Event, prev, cur sql.DBStats) {
+	ev.AddField("db.max_open_conns", cur.MaxOpenConnections)
+	ev.AddField("db.wait_count_delta", cur.WaitCount-prev.WaitCount)
+	ev.AddField("db.wait_duration_ms", float64(cur.WaitDuration-prev.WaitDuration)/float64(time.Millisecond))
+	ev.AddField("db.max_idle_closed_delta", cur.MaxIdleClosed-prev.MaxIdleClosed)
+	ev.AddField("db.max_lifetime_closed_delta", cur.MaxLifetimeClosed-prev.MaxLifetimeClosed)
+}
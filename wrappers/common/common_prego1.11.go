@@ -16,3 +16,5 @@ func addDBStatsToEvent(ev *libhoney.Event, stats sql.DBStats) {
 func addDBStatsToSpan(span *trace.Span, stats sql.DBStats) {
 	span.AddField("db.open_conns", stats.OpenConnections)
 }
+
+func addDBStatsDeltas(ev *libhoney.Event, prev, cur sql.DBStats) {}
@@ -3,12 +3,20 @@ package common
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/honeycombio/beeline-go/client"
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
 	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,15 +64,319 @@ func TestXForwardedProtoHeader(t *testing.T) {
 	assert.Equal(t, xForwardedProto, props["request.header.x_forwarded_proto"])
 }
 
+func TestCapturedHeaders(t *testing.T) {
+	trace.GlobalConfig.HTTPHeadersToCapture = []string{"x-request-source", "Accept", "authorization", "Cookie", "X-Missing"}
+	defer func() { trace.GlobalConfig.HTTPHeadersToCapture = nil }()
+
+	req := httptest.NewRequest("GET", "https://unused.com/", nil)
+	req.Header.Set("X-Request-Source", "mobile")
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	props := GetRequestProps(req)
+	assert.Equal(t, "mobile", props["request.header.x_request_source"])
+	assert.Equal(t, "text/html, application/json", props["request.header.accept"])
+	assert.Equal(t, "[REDACTED]", props["request.header.authorization"])
+	assert.Equal(t, "[REDACTED]", props["request.header.cookie"])
+	assert.NotContains(t, props, "request.header.x_missing")
+}
+
+func TestCapturedQueryParams(t *testing.T) {
+	trace.GlobalConfig.HTTPQueryParamsToCapture = []string{"page", "Sort-By", "api_key"}
+	defer func() { trace.GlobalConfig.HTTPQueryParamsToCapture = nil }()
+
+	req := httptest.NewRequest("GET", "https://unused.com/items?page=2&sort-by=name&sort-by=date&api_key=secret&q=shoes", nil)
+	props := GetRequestProps(req)
+	assert.Equal(t, "2", props["request.query_param.page"])
+	assert.Equal(t, "name, date", props["request.query_param.sort_by"])
+	assert.Equal(t, "[REDACTED]", props["request.query_param.api_key"])
+	assert.NotContains(t, props, "request.query_param.q")
+	assert.Equal(t, "page=2&sort-by=name&sort-by=date&api_key=secret&q=shoes", props["request.query"])
+}
+
+func TestCapturedQueryParamsAll(t *testing.T) {
+	trace.GlobalConfig.HTTPQueryParamsToCapture = []string{"*"}
+	trace.GlobalConfig.HTTPQueryParamsToOmit = []string{"Email"}
+	defer func() {
+		trace.GlobalConfig.HTTPQueryParamsToCapture = nil
+		trace.GlobalConfig.HTTPQueryParamsToOmit = nil
+	}()
+
+	req := httptest.NewRequest("GET", "https://unused.com/?q=shoes&email=ada@example.com&Password=hunter2&sessionId=abc", nil)
+	props := GetRequestProps(req)
+	assert.Equal(t, "shoes", props["request.query_param.q"])
+	assert.NotContains(t, props, "request.query_param.email")
+	assert.Equal(t, "[REDACTED]", props["request.query_param.password"])
+	assert.Equal(t, "[REDACTED]", props["request.query_param.sessionid"])
+}
+
+func TestStartSpanOrTraceFromHTTPBadHeader(t *testing.T) {
+	mo := setupLibhoney(t)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.TracePropagationHTTPHeader, "1;trace_id=abcdef")
+	_, span := StartSpanOrTraceFromHTTP(req)
+	span.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 1, len(evs))
+	assert.NotEqual(t, "abcdef", evs[0].Data["trace.trace_id"], "invalid header should not be used as the trace ID")
+	assert.Contains(t, evs[0].Data["meta.propagation_error"], "trace_id=abcdef", "parse error should be recorded on the span")
+}
+
+func TestStartSpanOrTraceFromHTTPOtherBadHeaders(t *testing.T) {
+	mo := setupLibhoney(t)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.AmazonTracePropagationHTTPHeader, "Self=abcdef")
+	req.Header.Set(propagation.W3CTraceParentHTTPHeader, "not-a-traceparent")
+	_, span := StartSpanOrTraceFromHTTP(req)
+	span.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 1, len(evs))
+	propErr, _ := evs[0].Data["meta.propagation_error"].(string)
+	assert.Contains(t, propErr, "X-Amzn-Trace-Id: ", "AWS parse errors should be recorded")
+	assert.Contains(t, propErr, "traceparent: ", "W3C parse errors should be recorded")
+}
+
+func TestStartSpanOrTraceFromHTTPLongBadHeader(t *testing.T) {
+	mo := setupLibhoney(t)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.TracePropagationHTTPHeader, strings.Repeat("a", 10000))
+	_, span := StartSpanOrTraceFromHTTP(req)
+	span.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 1, len(evs))
+	propErr, _ := evs[0].Data["meta.propagation_error"].(string)
+	assert.Equal(t, maxPropagationErrorLength, len(propErr), "parse errors should be truncated")
+}
+
+func TestAddPropagationError(t *testing.T) {
+	mo := setupLibhoney(t)
+	_, tr := trace.NewTrace(context.Background(), "")
+	span := tr.GetRootSpan()
+	AddPropagationError(span, nil)
+	// "é" is two bytes, so the limit falls in the middle of one
+	AddPropagationError(span, errors.New("x"+strings.Repeat("é", 1000)))
+	span.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 1, len(evs))
+	propErr, _ := evs[0].Data["meta.propagation_error"].(string)
+	assert.Equal(t, maxPropagationErrorLength-1, len(propErr), "errors should be cut between characters")
+	assert.True(t, utf8.ValidString(propErr))
+}
+
+func TestStartSpanOrTraceFromHTTPGoodHeaders(t *testing.T) {
+	mo := setupLibhoney(t)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.TracePropagationHTTPHeader, "1;trace_id=abcdef,parent_id=123456")
+	req.Header.Set(propagation.AmazonTracePropagationHTTPHeader, "Root=1-67891233-abcdef012345678912345678")
+	req.Header.Set(propagation.W3CTraceParentHTTPHeader, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	_, span := StartSpanOrTraceFromHTTP(req)
+	span.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 1, len(evs))
+	assert.Equal(t, "abcdef", evs[0].Data["trace.trace_id"], "the Honeycomb header should continue the trace")
+	assert.NotContains(t, evs[0].Data, "meta.propagation_error")
+}
+
+func TestStartSpanOrTraceFromHTTPW3CHeaders(t *testing.T) {
+	mo := setupLibhoney(t)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.W3CTraceParentHTTPHeader, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	req.Header.Set(propagation.W3CTraceStateHTTPHeader, "vendor=value")
+	_, span := StartSpanOrTraceFromHTTP(req)
+
+	out := http.Header{}
+	SetTraceHeaders(out, span)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", fields["trace.trace_id"], "traceparent should continue the trace")
+		assert.Equal(t, "b7ad6b7169203331", fields["trace.parent_id"])
+		assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-"+fields["trace.span_id"].(string)+"-01",
+			out.Get(propagation.W3CTraceParentHTTPHeader))
+	}
+	assert.Equal(t, "vendor=value", out.Get(propagation.W3CTraceStateHTTPHeader), "tracestate should be passed on")
+	assert.NotEmpty(t, out.Get(propagation.TracePropagationHTTPHeader))
+
+	// W3C headers need IDs in their format
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.TracePropagationHTTPHeader, "1;trace_id=abcdef,parent_id=123456")
+	_, span = StartSpanOrTraceFromHTTP(req)
+	out = http.Header{}
+	SetTraceHeaders(out, span)
+	assert.Empty(t, out.Get(propagation.W3CTraceParentHTTPHeader))
+	assert.NotEmpty(t, out.Get(propagation.TracePropagationHTTPHeader))
+}
+
+func TestStartSpanOrTraceFromHTTPIDFormat(t *testing.T) {
+	mo := setupLibhoney(t)
+	trace.GlobalConfig.IDFormat = trace.IDFormatUUID
+	defer func() { trace.GlobalConfig.IDFormat = "" }()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.W3CTraceParentHTTPHeader, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	_, span := StartSpanOrTraceFromHTTP(req)
+	out := http.Header{}
+	SetTraceHeaders(out, span)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "0af76519-16cd-43dd-8448-eb211c80319c", evs[0].Data["trace.trace_id"])
+	}
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-"+span.GetSpanID()+"-01", out.Get(propagation.W3CTraceParentHTTPHeader))
+
+	// Honeycomb headers are used as they are
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.TracePropagationHTTPHeader, "1;trace_id=0af7651916cd43dd8448eb211c80319c,parent_id=b7ad6b7169203331")
+	_, span = StartSpanOrTraceFromHTTP(req)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", span.GetTrace().GetTraceID())
+}
+
+func TestStartSpanOrTraceFromHTTPB3Headers(t *testing.T) {
+	mo := setupLibhoney(t)
+	trace.GlobalConfig.B3Format = propagation.B3Multi
+	defer func() { trace.GlobalConfig.B3Format = propagation.B3None }()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.B3TraceIDHTTPHeader, "64fe8b2a57d3eff7")
+	req.Header.Set(propagation.B3SpanIDHTTPHeader, "e457b5a2e4d86bd1")
+	req.Header.Set(propagation.B3SampledHTTPHeader, "1")
+	_, span := StartSpanOrTraceFromHTTP(req)
+	out := http.Header{}
+	SetTraceHeaders(out, span)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "64fe8b2a57d3eff7", evs[0].Data["trace.trace_id"], "B3 headers should continue the trace")
+		assert.Equal(t, "e457b5a2e4d86bd1", evs[0].Data["trace.parent_id"])
+		assert.Equal(t, evs[0].Data["trace.span_id"], out.Get(propagation.B3SpanIDHTTPHeader))
+	}
+	assert.Equal(t, "64fe8b2a57d3eff7", out.Get(propagation.B3TraceIDHTTPHeader))
+	assert.Equal(t, "1", out.Get(propagation.B3SampledHTTPHeader))
+	assert.Empty(t, out.Get(propagation.B3SingleHTTPHeader))
+
+	trace.GlobalConfig.B3Format = propagation.B3Single
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.B3SingleHTTPHeader, "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1")
+	_, span = StartSpanOrTraceFromHTTP(req)
+	out = http.Header{}
+	SetTraceHeaders(out, span)
+	assert.Equal(t, "80f198ee56343ba864fe8b2a57d3eff7-"+span.GetSpanID()+"-1", out.Get(propagation.B3SingleHTTPHeader))
+	assert.Empty(t, out.Get(propagation.B3TraceIDHTTPHeader))
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(propagation.B3SingleHTTPHeader, "nope")
+	_, span = StartSpanOrTraceFromHTTP(req)
+	span.Send()
+	evs = mo.Events()
+	propErr, _ := evs[len(evs)-1].Data["meta.propagation_error"].(string)
+	assert.Contains(t, propErr, "b3: ", "B3 parse errors should be recorded")
+}
+
+func TestStartSpanOrTraceFromHTTPTenantHook(t *testing.T) {
+	mo := setupLibhoney(t)
+	trace.GlobalConfig.TenantHook = func(r *http.Request) (string, string) {
+		tenant := r.Header.Get("X-Tenant")
+		if tenant == "acme" {
+			return tenant, "acme-traces"
+		}
+		return tenant, ""
+	}
+	defer func() { trace.GlobalConfig.TenantHook = nil }()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Tenant", "acme")
+	ctx, span := StartSpanOrTraceFromHTTP(req)
+	_, child := StartChildSpan(ctx)
+	assert.Contains(t, span.SerializeHeaders(), "dataset=acme-traces", "downstream services should use the tenant's dataset")
+	child.Send()
+	span.Send()
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Tenant", "initech")
+	_, span = StartSpanOrTraceFromHTTP(req)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		for _, ev := range evs[:2] {
+			assert.Equal(t, "acme-traces", ev.Dataset)
+			assert.Equal(t, "acme", ev.Data["tenant"])
+		}
+		assert.Equal(t, "placeholder", evs[2].Dataset, "tenants without a dataset use the default")
+		assert.Equal(t, "initech", evs[2].Data["tenant"])
+	}
+}
+
+func setupLibhoney(t testing.TB) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	c, err := libhoney.NewClient(
+		libhoney.ClientConfig{
+			APIKey:       "placeholder",
+			Dataset:      "placeholder",
+			APIHost:      "placeholder",
+			Transmission: mo,
+		},
+	)
+	assert.Equal(t, nil, err)
+	client.Set(c)
+	return mo
+}
+
 // TestSharedDBEvent verifies that the name field is set to something
 func TestSharedDBEvent(t *testing.T) {
 	bld := libhoney.NewBuilder()
 	query := "this is sql really promise"
 	// wrap it in another function to get the expected nesting right
 	var ev *libhoney.Event
-	func() { ev = sharedDBEvent(bld, query) }()
+	func() { ev = sharedDBEvent(&trace.GlobalConfig, bld, query) }()
 	assert.Equal(t, "TestSharedDBEvent", ev.Fields()["name"], "should get a reasonable name")
 }
+
+// callDB stands in for a DB wrapper method.
+func callDB() (call, caller string) {
+	return dbCallerNames(0, &trace.GlobalConfig)
+}
+
+func TestDBCallerNames(t *testing.T) {
+	call, caller := callDB()
+	assert.Equal(t, "callDB", call)
+	assert.Equal(t, "TestDBCallerNames", caller)
+
+	// functions in the configured packages are part of the call
+	trace.GlobalConfig.DBCallerPackages = []string{"github.com/honeycombio/beeline-go/wrappers/common"}
+	defer func() { trace.GlobalConfig.DBCallerPackages = nil }()
+	call, caller = callDB()
+	assert.Equal(t, "TestDBCallerNames", call)
+	assert.Equal(t, "tRunner", caller)
+}
+
+func TestWithDBCallName(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, tr := trace.NewTrace(context.Background(), "")
+	named, _, sender := BuildDBSpan(WithDBCallName(ctx, "loadUser"), libhoney.NewBuilder(), sql.DBStats{}, "select 1")
+	sender(nil)
+	_, _, sender = BuildDBSpan(named, libhoney.NewBuilder(), sql.DBStats{}, "select 1")
+	sender(nil)
+	tr.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		assert.Equal(t, "loadUser", evs[0].Data["db.call"])
+		assert.Equal(t, "loadUser", evs[0].Data["name"])
+		assert.Equal(t, "TestWithDBCallName", evs[1].Data["db.call"], "only the call given the context should be named")
+	}
+}
+
 func TestResponseWriter(t *testing.T) {
 	rr := httptest.NewRecorder()
 	wr := NewResponseWriter(rr)
@@ -72,6 +384,86 @@ func TestResponseWriter(t *testing.T) {
 	assert.Equal(t, 222, wr.Status)
 	wr.Wrapped.WriteHeader(333)
 	assert.Equal(t, 222, wr.Status)
+	assert.Equal(t, 1, wr.SuperfluousWriteHeaders)
+}
+
+func TestResponseWriterImplicitStatus(t *testing.T) {
+	rr := httptest.NewRecorder()
+	wr := NewResponseWriter(rr)
+	wr.Wrapped.Write([]byte("hi"))
+	assert.Equal(t, 200, wr.Status, "writing the body should imply a 200")
+	wr.Wrapped.WriteHeader(500)
+	assert.Equal(t, 200, wr.Status, "WriteHeader after Write should not change the status")
+	assert.Equal(t, 1, wr.SuperfluousWriteHeaders)
+	assert.Equal(t, 200, rr.Code)
+}
+
+func TestResponseWriterInformational(t *testing.T) {
+	rr := httptest.NewRecorder()
+	wr := NewResponseWriter(rr)
+	wr.Wrapped.WriteHeader(http.StatusEarlyHints)
+	assert.Equal(t, 0, wr.Status, "1xx responses should not be recorded as the status")
+	wr.Wrapped.WriteHeader(http.StatusCreated)
+	assert.Equal(t, http.StatusCreated, wr.Status)
+	assert.Equal(t, 1, wr.InformationalResponses)
+	assert.Equal(t, 0, wr.SuperfluousWriteHeaders)
+
+	// switching protocols is final, not informational
+	wr = NewResponseWriter(httptest.NewRecorder())
+	wr.Wrapped.WriteHeader(http.StatusSwitchingProtocols)
+	assert.Equal(t, http.StatusSwitchingProtocols, wr.Status)
+	assert.Equal(t, 0, wr.InformationalResponses)
+}
+
+func TestResponseWriterAnomalyFields(t *testing.T) {
+	mo := setupLibhoney(t)
+	_, tr := trace.NewTrace(context.Background(), "")
+	span := tr.GetRootSpan()
+
+	wr := NewResponseWriter(httptest.NewRecorder())
+	wr.Wrapped.WriteHeader(http.StatusContinue)
+	wr.Wrapped.WriteHeader(http.StatusOK)
+	wr.Wrapped.WriteHeader(http.StatusOK)
+	wr.AddAnomalyFields(span)
+	span.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 1, len(evs))
+	assert.Equal(t, 1, evs[0].Data["meta.informational_responses"])
+	assert.Equal(t, 1, evs[0].Data["meta.superfluous_write_headers"])
+}
+
+func TestResponseWriterResponseFields(t *testing.T) {
+	mo := setupLibhoney(t)
+	_, tr := trace.NewTrace(context.Background(), "")
+	span := tr.GetRootSpan()
+
+	wr := NewResponseWriter(httptest.NewRecorder())
+	time.Sleep(5 * time.Millisecond)
+	wr.Wrapped.(http.Flusher).Flush()
+	first := wr.FirstByte
+	assert.False(t, first.IsZero(), "flushing should start the response")
+	wr.Wrapped.Write([]byte("hello "))
+	wr.Wrapped.Write([]byte("world"))
+	assert.Equal(t, first, wr.FirstByte, "only the first write should be timed")
+	assert.Equal(t, int64(11), wr.BytesWritten)
+	wr.AddResponseFields(span)
+
+	_, tr = trace.NewTrace(context.Background(), "")
+	empty := tr.GetRootSpan()
+	NewResponseWriter(httptest.NewRecorder()).AddResponseFields(empty)
+	span.Send()
+	empty.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, int64(11), evs[0].Data["response.bytes_written"])
+		ttfb, ok := evs[0].Data["response.time_to_first_byte_ms"].(float64)
+		assert.True(t, ok)
+		assert.True(t, ttfb >= 5, "time to first byte should be measured from the start, got %v", ttfb)
+		assert.Equal(t, int64(0), evs[1].Data["response.bytes_written"])
+		assert.NotContains(t, evs[1].Data, "response.time_to_first_byte_ms")
+	}
 }
 
 func TestResponseWriterTypeAssertions(t *testing.T) {
@@ -114,9 +506,138 @@ func TestBuildDBEvent(t *testing.T) {
 	sender(nil)
 }
 
+func TestBuildDBEventDurationNanos(t *testing.T) {
+	mo := setupLibhoney(t)
+	trace.GlobalConfig.RecordDurationNanos = true
+	defer func() { trace.GlobalConfig.RecordDurationNanos = false }()
+
+	_, sender := BuildDBEvent(client.NewBuilder(), sql.DBStats{}, "select 1")
+	time.Sleep(time.Millisecond)
+	sender(nil)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		ns, ok := evs[0].Data["duration_ns"].(int64)
+		if assert.True(t, ok, "duration_ns should be an integer") {
+			assert.True(t, ns >= int64(time.Millisecond), "duration should cover the sleep")
+			assert.Equal(t, float64(ns)/float64(time.Millisecond), evs[0].Data["duration_ms"],
+				"duration_ms and duration_ns should describe the same duration")
+		}
+	}
+}
+
+func TestBuildDBEventSubMillisecondNanos(t *testing.T) {
+	mo := setupLibhoney(t)
+	trace.GlobalConfig.RecordDurationNanos = true
+	defer func() { trace.GlobalConfig.RecordDurationNanos = false }()
+
+	// a call that returns at once takes well under a millisecond
+	_, sender := BuildDBEvent(client.NewBuilder(), sql.DBStats{}, "select 1")
+	sender(nil)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		ns, _ := evs[0].Data["duration_ns"].(int64)
+		assert.True(t, ns > 0, "sub-millisecond calls should not round to zero")
+		assert.Equal(t, float64(ns)/float64(time.Millisecond), evs[0].Data["duration_ms"])
+	}
+}
+
+func TestBuildDBEventQueryArgs(t *testing.T) {
+	mo := setupLibhoney(t)
+
+	_, sender := BuildDBEvent(client.NewBuilder(), sql.DBStats{}, "select ?, ?", 1, "a")
+	sender(nil)
+	_, sender = BuildDBEvent(client.NewBuilder(), sql.DBStats{}, "select 1")
+	sender(nil)
+	_, sender = BuildDBCall(nil, client.NewBuilder(), sql.DBStats{}, "select ?, ?", 1, "a")
+	sender(nil)
+	_, sender = BuildDBCall(nil, client.NewBuilder(), sql.DBStats{}, "select 1")
+	sender(nil)
+
+	evs := mo.Events()
+	if assert.Equal(t, 4, len(evs)) {
+		assert.Equal(t, []interface{}{1, "a"}, evs[0].Data["db.query_args"])
+		assert.NotContains(t, evs[1].Data, "db.query_args", "a call without args should have none")
+		assert.Equal(t, []interface{}{1, "a"}, evs[2].Data["db.query_args"])
+		assert.NotContains(t, evs[3].Data, "db.query_args", "a call without args should have none")
+	}
+}
+
 func TestBuildDBSpan(t *testing.T) {
 	b := libhoney.NewBuilder()
 	ctx := context.Background()
 	ctx, _, sender := BuildDBSpan(ctx, b, sql.DBStats{}, "")
 	sender(nil)
 }
+
+func TestFinishDBCallDuringPanic(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, tr := trace.NewTrace(context.Background(), "")
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		var err error
+		_, _, sender := BuildDBSpan(ctx, libhoney.NewBuilder(), sql.DBStats{}, "select 1")
+		defer FinishDBCall(sender, &err)
+		panic("driver exploded")
+	}()
+	assert.Equal(t, "driver exploded", recovered, "the panic should continue after sending")
+	tr.Send()
+
+	evs := mo.Events()
+	assert.Equal(t, 2, len(evs))
+	fields := evs[0].Data
+	assert.Equal(t, "panic", fields["error"])
+	assert.Equal(t, "driver exploded", fields["panic.value"])
+	assert.Equal(t, "panic: driver exploded", fields["db.error"])
+	assert.Contains(t, fields, "duration_ms")
+}
+
+func TestFinishDBCallError(t *testing.T) {
+	var got error
+	err := sql.ErrNoRows
+	func() {
+		defer FinishDBCall(func(e error) { got = e }, &err)
+	}()
+	assert.Equal(t, sql.ErrNoRows, got, "the error should be passed through when there is no panic")
+}
+
+func TestStartChildSpan(t *testing.T) {
+	ctx, tr := trace.NewTrace(context.Background(), "")
+	_, span := StartChildSpan(ctx)
+	assert.Equal(t, tr.GetRootSpan().GetSpanID(), span.GetParentID(), "span should be a child of the span in the context")
+
+	_, orphan := StartChildSpan(context.Background())
+	assert.Empty(t, orphan.GetParentID(), "without a span in the context a new trace should be started")
+}
+
+func TestAddContextFields(t *testing.T) {
+	mo := setupLibhoney(t)
+	deadline := time.Now().Add(-time.Second)
+	timedOut, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, ctx := range []context.Context{context.Background(), timedOut, cancelled} {
+		_, tr := trace.NewTrace(ctx, "")
+		span := tr.GetRootSpan()
+		AddContextFields(ctx, span)
+		span.Send()
+	}
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		assert.NotContains(t, evs[0].Data, "request.context_error")
+		assert.NotContains(t, evs[0].Data, "request.deadline")
+
+		assert.Equal(t, true, evs[1].Data["request.timeout"])
+		assert.Equal(t, context.DeadlineExceeded.Error(), evs[1].Data["request.context_error"])
+		assert.True(t, deadline.Equal(evs[1].Data["request.deadline"].(time.Time)))
+
+		assert.Equal(t, false, evs[2].Data["request.timeout"], "a client going away isn't a timeout")
+		assert.Equal(t, context.Canceled.Error(), evs[2].Data["request.context_error"])
+	}
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/honeycombio/beeline-go/client"
+)
+
+// ReportDBStats sends a db_stats event with the state of a connection pool
+// every interval, until the returned function is called. Each event has the
+// same db.* pool fields as DB spans, plus how many calls waited for a
+// connection during the interval and how long they waited, so a slow query
+// can be told apart from an exhausted pool. stats is usually the pool's Stats
+// method. name, if set, is recorded as db.name to tell pools apart.
+func ReportDBStats(stats func() sql.DBStats, name string, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		prev := stats()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			cur := stats()
+			ev := client.NewBuilder().NewEvent()
+			ev.AddField("meta.type", "db_stats")
+			ev.AddField("name", "db_stats")
+			if name != "" {
+				ev.AddField("db.name", name)
+			}
+			addDBStatsToEvent(ev, cur)
+			addDBStatsDeltas(ev, prev, cur)
+			// like runtime metrics, these aren't sampled
+			ev.Send()
+			prev = cur
+		}
+	}()
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// FuncPackage returns the import path of the package of the function called
+// name, as runtime.FuncForPC names it. For example it returns "net/http" for
+// "net/http.(*ServeMux).ServeHTTP-fm", and "main" for "main.hello.func1". It
+// returns "" if name isn't the name of a function.
+func FuncPackage(name string) string {
+	// type parameters can contain paths of their own
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	dir := ""
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		dir, name = name[:i+1], name[i+1:]
+	}
+	i := strings.IndexByte(name, '.')
+	if i <= 0 {
+		return ""
+	}
+	return dir + name[:i]
+}
+
+// HandlerPackage returns the import path of the package that defines
+// handler, which is a function or a value of a named type, or a pointer to
+// one, such as an http.Handler. It returns "" if that can't be told, for
+// example for an http.HandlerFunc wrapping an anonymous function.
+func HandlerPackage(handler interface{}) string {
+	if handler == nil {
+		return ""
+	}
+	v := reflect.ValueOf(handler)
+	t := v.Type()
+	if t.Kind() == reflect.Func {
+		if v.IsNil() {
+			return ""
+		}
+		return FuncPackage(runtime.FuncForPC(v.Pointer()).Name())
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.PkgPath()
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testHandler struct{}
+
+func (testHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func testHandlerFunc(http.ResponseWriter, *http.Request) {}
+
+func TestFuncPackage(t *testing.T) {
+	for name, pkg := range map[string]string{
+		"main.hello":                              "main",
+		"main.hello.func1":                        "main",
+		"net/http.(*ServeMux).ServeHTTP-fm":       "net/http",
+		"github.com/a/b.v2.(*T).Method":           "github.com/a/b",
+		"github.com/a/b.Handle[...]":              "github.com/a/b",
+		"github.com/a/b.Handle[github.com/c/d.T]": "github.com/a/b",
+		"":      "",
+		"hello": "",
+	} {
+		assert.Equal(t, pkg, FuncPackage(name), name)
+	}
+}
+
+func TestHandlerPackage(t *testing.T) {
+	const pkg = "github.com/honeycombio/beeline-go/wrappers/common"
+	assert.Equal(t, pkg, HandlerPackage(testHandler{}))
+	assert.Equal(t, pkg, HandlerPackage(&testHandler{}))
+	assert.Equal(t, pkg, HandlerPackage(testHandlerFunc))
+	assert.Equal(t, pkg, HandlerPackage(http.HandlerFunc(testHandlerFunc)))
+	assert.Equal(t, "net/http", HandlerPackage(http.NotFoundHandler()))
+	assert.Equal(t, "net/http", HandlerPackage(http.NewServeMux()))
+	assert.Equal(t, "", HandlerPackage(nil))
+	assert.Equal(t, "", HandlerPackage(http.HandlerFunc(nil)))
+}
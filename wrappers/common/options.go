@@ -0,0 +1,141 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+	libhoney "github.com/honeycombio/libhoney-go"
+)
+
+// HandlerOption configures how a wrapper instruments one handler, so that
+// handlers wrapped by the same middleware can be configured independently.
+type HandlerOption func(*HandlerConfig)
+
+// HandlerConfig is the configuration built from a handler's options.
+type HandlerConfig struct {
+	// RouteName, if set, names the handler's spans and is recorded as
+	// handler.route.
+	RouteName string
+	// SampleRate, if set, samples the traces the handler starts or continues
+	// at this rate instead of the beeline's.
+	SampleRate uint
+	// Fields are added to every span for the handler.
+	Fields map[string]interface{}
+	// StreamInterval, if set, is how often a streaming handler's progress is
+	// reported. See StartStream.
+	StreamInterval time.Duration
+	// Client, if set, sends the traces the handler starts in place of the
+	// beeline's client. See PrepareRequest.
+	Client *libhoney.Client
+	// TraceConfig, if set, is the config the handler records requests and
+	// starts traces with in place of trace.GlobalConfig. See PrepareRequest.
+	TraceConfig *trace.Config
+	// OmitRequestBody keeps the handler's request bodies from being
+	// recorded, whatever HTTPBodyContentTypes says. See PrepareRequest.
+	OmitRequestBody bool
+}
+
+// NewHandlerConfig applies opts to a new HandlerConfig.
+func NewHandlerConfig(opts []HandlerOption) *HandlerConfig {
+	c := &HandlerConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithRouteName names the handler's spans name rather than after the
+// handler function, and records it as handler.route.
+func WithRouteName(name string) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.RouteName = name
+	}
+}
+
+// WithSampleRate samples the handler's traces at rate, keeping one in rate,
+// whatever the beeline's sample rate or sampler hook would have done.
+func WithSampleRate(rate uint) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.SampleRate = rate
+	}
+}
+
+// WithFields adds fields to every span for the handler, such as the team
+// that owns it.
+func WithFields(fields map[string]interface{}) HandlerOption {
+	return func(c *HandlerConfig) {
+		if c.Fields == nil {
+			c.Fields = make(map[string]interface{}, len(fields))
+		}
+		for k, v := range fields {
+			c.Fields[k] = v
+		}
+	}
+}
+
+// WithClient sends the traces the handler starts with c instead of the
+// client the beeline was initialized with, for a handler that reports to a
+// write key or dataset of its own.
+func WithClient(c *libhoney.Client) HandlerOption {
+	return func(cfg *HandlerConfig) {
+		cfg.Client = c
+	}
+}
+
+// WithTraceConfig records the handler's requests, and samples and sends the
+// traces it starts, with c instead of trace.GlobalConfig.
+func WithTraceConfig(c *trace.Config) HandlerOption {
+	return func(cfg *HandlerConfig) {
+		cfg.TraceConfig = c
+	}
+}
+
+// WithoutRequestBodyFields keeps the handler's request bodies from being
+// recorded in request.body, for handlers that take secrets such as logins or
+// token exchanges, while the beeline records the bodies of other handlers.
+func WithoutRequestBodyFields() HandlerOption {
+	return func(c *HandlerConfig) {
+		c.OmitRequestBody = true
+	}
+}
+
+// PrepareRequest returns r with the handler's client and trace config, if it
+// has them, in its context, so that r is recorded and a trace started for it
+// sent with them, and marked so its body isn't recorded if the handler omits
+// request bodies. Call it before anything else looks at the request, such as
+// IgnoreRequest.
+func (c *HandlerConfig) PrepareRequest(r *http.Request) *http.Request {
+	if c.Client == nil && c.TraceConfig == nil && !c.OmitRequestBody {
+		return r
+	}
+	ctx := r.Context()
+	if c.Client != nil {
+		ctx = trace.PutClientInContext(ctx, c.Client)
+	}
+	if c.TraceConfig != nil {
+		ctx = trace.PutConfigInContext(ctx, c.TraceConfig)
+	}
+	if c.OmitRequestBody {
+		ctx = context.WithValue(ctx, omitRequestBodyKey{}, true)
+	}
+	return r.WithContext(ctx)
+}
+
+// Apply configures span, the handler's span for a request, and the trace in
+// ctx it belongs to. Call it after the span is named so the route name wins.
+func (c *HandlerConfig) Apply(ctx context.Context, span *trace.Span) {
+	if c.RouteName != "" {
+		span.AddField("name", c.RouteName)
+		span.AddField("handler.route", c.RouteName)
+	}
+	for k, v := range c.Fields {
+		span.AddField(k, v)
+	}
+	if c.SampleRate > 0 {
+		if tr := trace.GetTraceFromContext(ctx); tr != nil {
+			tr.SetSampleRate(c.SampleRate)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package common
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// AddDBQueryFields adds query as db.query and args, unless they are nil, as
+// db.query_args, following the DBQueryMode and OmitDBQueryArgs of the span's
+// trace if f is a span, the config f's GetConfig method returns if it has
+// one, or trace.GlobalConfig. f is the event, span or builder for the DB
+// call.
+func AddDBQueryFields(f interface{ AddField(string, interface{}) }, query string, args []interface{}) {
+	config := &trace.GlobalConfig
+	switch f := f.(type) {
+	case *trace.Span:
+		if tr := f.GetTrace(); tr != nil {
+			config = tr.GetConfig()
+		}
+	case interface{ GetConfig() *trace.Config }:
+		config = f.GetConfig()
+	}
+	addDBQueryFields(config, f, query, args)
+}
+
+// addDBQueryFields adds the query fields as AddDBQueryFields does, following
+// config.
+func addDBQueryFields(config *trace.Config, f interface{ AddField(string, interface{}) }, query string, args []interface{}) {
+	if query != "" {
+		switch config.DBQueryMode {
+		case trace.DBQueryRaw:
+			f.AddField("db.query", query)
+		case trace.DBQueryNormalized:
+			f.AddField("db.query", NormalizeQuery(query))
+		}
+	}
+	if args != nil && !config.OmitDBQueryArgs {
+		f.AddField("db.query_args", args)
+	}
+}
+
+// inList matches an IN list that NormalizeQuery has replaced the values of.
+var inList = regexp.MustCompile(`(?i)(\bIN\s*\()\s*\?(?:\s*,\s*\?)*\s*\)`)
+
+// NormalizeQuery replaces the string and number literals in a SQL query with
+// ?, and lists of them after IN with a single ?, so that
+//
+//	SELECT * FROM users WHERE id IN (1, 2, 3) AND email = 'ada@example.com'
+//
+// becomes
+//
+//	SELECT * FROM users WHERE id IN (?) AND email = ?
+//
+// Quoted identifiers, placeholders such as $1, and comments are kept.
+func NormalizeQuery(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			i = skipQuoted(query, i)
+			b.WriteByte('?')
+		case c == '"' || c == '`':
+			end := skipQuoted(query, i)
+			b.WriteString(query[i:end])
+			i = end
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i + 4
+			}
+			b.WriteString(query[i:end])
+			i = end
+		case isDigit(c) && (i == 0 || !isIdentByte(query[i-1])):
+			i = skipNumber(query, i)
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return inList.ReplaceAllString(b.String(), "${1}?)")
+}
+
+// skipQuoted returns the index just past the quoted string starting at i. A
+// doubled quote or a backslash escapes the quote.
+func skipQuoted(s string, i int) int {
+	quote := s[i]
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// skipNumber returns the index just past the number starting at i, including
+// hex numbers, decimals and exponents.
+func skipNumber(s string, i int) int {
+	if strings.HasPrefix(s[i:], "0x") || strings.HasPrefix(s[i:], "0X") {
+		i += 2
+		for i < len(s) && isHexDigit(s[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case isDigit(c) || c == '.':
+			i++
+		case (c == 'e' || c == 'E') && i+1 < len(s):
+			i++
+			if s[i] == '+' || s[i] == '-' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// isIdentByte reports whether c can be part of an identifier or placeholder,
+// in which case a digit after it is too, as in t1 or $1.
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || c == ':' || isDigit(c) ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"SELECT * FROM users WHERE id = 42", "SELECT * FROM users WHERE id = ?"},
+		{"SELECT * FROM users WHERE email = 'ada@example.com' AND age > 3.5e2", "SELECT * FROM users WHERE email = ? AND age > ?"},
+		{"SELECT * FROM t1 WHERE id IN (1, 2,3)", "SELECT * FROM t1 WHERE id IN (?)"},
+		{"select * from t where name in ('a', 'b')", "select * from t where name in (?)"},
+		{"UPDATE t SET note = 'it''s \\'quoted\\'' WHERE id = $1", "UPDATE t SET note = ? WHERE id = $1"},
+		{`SELECT "col1", ` + "`col2`" + ` FROM "table 2" WHERE flags = 0xFF`, `SELECT "col1", ` + "`col2`" + ` FROM "table 2" WHERE flags = ?`},
+		{"INSERT INTO t (a, b) VALUES (?, ?)", "INSERT INTO t (a, b) VALUES (?, ?)"},
+		{"SELECT 1 -- id 42\n/* user 7 */ FROM t LIMIT 10", "SELECT ? -- id 42\n/* user 7 */ FROM t LIMIT ?"},
+		{"SELECT 'unterminated", "SELECT ?"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, NormalizeQuery(tt.query), tt.query)
+	}
+}
+
+// fields is a field adder for tests.
+type fields map[string]interface{}
+
+func (f fields) AddField(key string, val interface{}) { f[key] = val }
+
+func TestAddDBQueryFields(t *testing.T) {
+	defer func() {
+		trace.GlobalConfig.DBQueryMode = trace.DBQueryRaw
+		trace.GlobalConfig.OmitDBQueryArgs = false
+	}()
+	query, args := "SELECT * FROM users WHERE id = 42 AND name = ?", []interface{}{"ada"}
+
+	f := fields{}
+	AddDBQueryFields(f, query, args)
+	assert.Equal(t, fields{"db.query": query, "db.query_args": args}, f)
+
+	trace.GlobalConfig.DBQueryMode = trace.DBQueryNormalized
+	trace.GlobalConfig.OmitDBQueryArgs = true
+	f = fields{}
+	AddDBQueryFields(f, query, args)
+	assert.Equal(t, fields{"db.query": "SELECT * FROM users WHERE id = ? AND name = ?"}, f)
+
+	trace.GlobalConfig.DBQueryMode = trace.DBQueryOmitted
+	f = fields{}
+	AddDBQueryFields(f, query, args)
+	assert.Empty(t, f)
+}
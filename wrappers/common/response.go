@@ -0,0 +1,30 @@
+package common
+
+import (
+	"net/http"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// SetResponseHeaders adds the headers the HTTP wrappers are configured to
+// return to the client to h, the response headers of r, whose span is span:
+// the trace ID in the config's TraceIDResponseHeader, so that support teams
+// can look up a request a customer reports straight in Honeycomb, and the
+// X-Amzn-Trace-Id header when the config's EchoAmazonTraceHeader is set. The
+// config is that of span's trace, or of r's context if there is no span. The
+// wrappers call it before running the handler, so the handler can still
+// change or remove them.
+func SetResponseHeaders(h http.Header, r *http.Request, span *trace.Span) {
+	config := trace.GetConfigFromContext(r.Context())
+	if span != nil && span.GetTrace() != nil {
+		config = span.GetTrace().GetConfig()
+	}
+	if name := config.TraceIDResponseHeader; name != "" && span != nil {
+		if tr := span.GetTrace(); tr != nil {
+			h.Set(name, tr.GetTraceID())
+		}
+	}
+	if config.EchoAmazonTraceHeader {
+		echoAmazonTraceHeader(h, r)
+	}
+}
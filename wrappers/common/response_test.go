@@ -0,0 +1,36 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetResponseHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Amzn-Trace-Id", "Root=1-67891233-abcdef012345678912345678")
+	_, tr := trace.NewTrace(context.Background(), "")
+	span := tr.GetRootSpan()
+
+	h := http.Header{}
+	SetResponseHeaders(h, r, span)
+	assert.Empty(t, h, "no headers are set unless configured")
+
+	trace.GlobalConfig.TraceIDResponseHeader = "X-Trace"
+	trace.GlobalConfig.EchoAmazonTraceHeader = true
+	defer func() {
+		trace.GlobalConfig.TraceIDResponseHeader = ""
+		trace.GlobalConfig.EchoAmazonTraceHeader = false
+	}()
+	SetResponseHeaders(h, r, span)
+	assert.Equal(t, tr.GetTraceID(), h.Get("X-Trace"))
+	assert.Equal(t, "Root=1-67891233-abcdef012345678912345678", h.Get("X-Amzn-Trace-Id"))
+
+	h = http.Header{}
+	SetResponseHeaders(h, httptest.NewRequest("GET", "/", nil), nil)
+	assert.Empty(t, h, "nothing to echo without a span or AWS header")
+}
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// WithStreaming reports on requests to a handler that streams its response,
+// such as server-sent events or a long poll, while they are still going on,
+// instead of only once the handler returns. See StartStream.
+func WithStreaming(interval time.Duration) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.StreamInterval = interval
+	}
+}
+
+// streamProgress counts the bytes a streaming handler has written. It is
+// updated by the handler's goroutine and read by the stream's, so it is kept
+// apart from the rest of the ResponseWriter and accessed atomically.
+type streamProgress struct {
+	bytes int64
+}
+
+// stream sends the spans for one streamed request.
+type stream struct {
+	span     *trace.Span
+	rw       *ResponseWriter
+	interval time.Duration
+	started  time.Time
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// StartStream begins reporting on a streamed response, if the handler was
+// configured WithStreaming, and returns a func that must be called when the
+// handler returns, before span is sent. Nothing is done otherwise.
+//
+// A span named "stream.started" is sent straight away with the request's
+// fields, so the request shows up as soon as it begins. Every interval
+// after that a "stream.heartbeat" span follows, covering the interval, with
+// stream.bytes_written, the size of the response so far,
+// stream.interval_bytes, the part of it written during the interval,
+// stream.elapsed_ms, the time since the request began, and stream.sequence,
+// starting at 1. All of them are children of span, which is
+// the summary of the stream once it is sent, with the number of heartbeats
+// added as stream.heartbeats.
+func (c *HandlerConfig) StartStream(ctx context.Context, r *http.Request, span *trace.Span, rw *ResponseWriter) func() {
+	if c.StreamInterval <= 0 || span == nil {
+		return func() {}
+	}
+	rw.progress = &streamProgress{}
+	s := &stream{
+		span:     span,
+		rw:       rw,
+		interval: c.StreamInterval,
+		started:  time.Now(),
+		stop:     make(chan struct{}),
+	}
+
+	_, start := span.CreateChild(ctx)
+	for k, v := range GetRequestProps(r) {
+		start.AddField(k, v)
+	}
+	start.AddField("meta.type", "stream")
+	start.AddField("name", "stream.started")
+	start.Send()
+
+	s.stopped.Add(1)
+	go s.run()
+	return s.finish
+}
+
+func (s *stream) run() {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	var sequence int
+	var last int64
+	_, heartbeat := s.span.CreateChild(context.Background())
+	for {
+		select {
+		case <-ticker.C:
+			sequence++
+			total := atomic.LoadInt64(&s.rw.progress.bytes)
+			heartbeat.AddField("meta.type", "stream")
+			heartbeat.AddField("name", "stream.heartbeat")
+			heartbeat.AddField("stream.sequence", sequence)
+			heartbeat.AddField("stream.bytes_written", total)
+			heartbeat.AddField("stream.interval_bytes", total-last)
+			heartbeat.AddField("stream.elapsed_ms", float64(time.Since(s.started))/float64(time.Millisecond))
+			heartbeat.Send()
+			last = total
+			_, heartbeat = s.span.CreateChild(context.Background())
+		case <-s.stop:
+			// the partial interval is covered by the summary
+			heartbeat.Discard()
+			s.span.AddField("stream.heartbeats", sequence)
+			return
+		}
+	}
+}
+
+// finish stops the heartbeats.
+func (s *stream) finish() {
+	close(s.stop)
+	s.stopped.Wait()
+}
@@ -0,0 +1,92 @@
+package common
+
+import "strings"
+
+// userAgentMatch names what a user agent is when it contains any of tokens.
+type userAgentMatch struct {
+	name   string
+	tokens []string
+}
+
+// uaBrowsers are checked in order, since most browsers also claim to be the
+// ones they're built on: Edge and Opera say they're Chrome, and Chrome says
+// it's Safari.
+var uaBrowsers = []userAgentMatch{
+	{"Edge", []string{"edg/", "edge/", "edga/", "edgios/"}},
+	{"Opera", []string{"opr/", "opera"}},
+	{"Samsung Internet", []string{"samsungbrowser/"}},
+	{"Firefox", []string{"firefox/", "fxios/"}},
+	{"Chrome", []string{"chrome/", "crios/", "chromium/"}},
+	{"Safari", []string{"safari/"}},
+	{"Internet Explorer", []string{"msie ", "trident/"}},
+}
+
+// uaOSes are checked in order, since Android says it's Linux and iOS says
+// it's like Mac OS X.
+var uaOSes = []userAgentMatch{
+	{"Windows", []string{"windows"}},
+	{"Android", []string{"android"}},
+	{"iOS", []string{"iphone", "ipad", "ipod"}},
+	{"macOS", []string{"mac os x", "macintosh"}},
+	{"Chrome OS", []string{"cros "}},
+	{"Linux", []string{"linux"}},
+}
+
+// uaBotTokens mark crawlers, uptime checks and scripted clients rather than
+// people using a browser.
+var uaBotTokens = []string{
+	"bot", "crawl", "spider", "slurp", "facebookexternalhit", "headlesschrome",
+	"lighthouse", "pingdom", "curl/", "wget/", "python-requests", "python-urllib",
+	"go-http-client", "java/", "libwww-perl", "httpclient",
+}
+
+// AddUserAgentFields parses the request.header.user_agent field of props, if
+// there is one, to add request.ua.browser, request.ua.os, request.ua.device
+// (desktop, mobile, tablet or bot) and request.ua.is_bot. Browsers and
+// operating systems that aren't recognized are left out. GetRequestProps calls
+// it when the config's ParseUserAgents is set.
+func AddUserAgentFields(props map[string]interface{}) {
+	ua, _ := props["request.header.user_agent"].(string)
+	if ua == "" {
+		return
+	}
+	lower := strings.ToLower(ua)
+	isBot := containsAny(lower, uaBotTokens)
+	props["request.ua.is_bot"] = isBot
+	if browser := matchUserAgent(lower, uaBrowsers); browser != "" {
+		props["request.ua.browser"] = browser
+	}
+	os := matchUserAgent(lower, uaOSes)
+	if os != "" {
+		props["request.ua.os"] = os
+	}
+	switch {
+	case isBot:
+		props["request.ua.device"] = "bot"
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet") ||
+		(os == "Android" && !strings.Contains(lower, "mobile")):
+		props["request.ua.device"] = "tablet"
+	case strings.Contains(lower, "mobi") || strings.Contains(lower, "iphone") || strings.Contains(lower, "ipod"):
+		props["request.ua.device"] = "mobile"
+	default:
+		props["request.ua.device"] = "desktop"
+	}
+}
+
+func matchUserAgent(lowerUA string, matches []userAgentMatch) string {
+	for _, m := range matches {
+		if containsAny(lowerUA, m.tokens) {
+			return m.name
+		}
+	}
+	return ""
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddUserAgentFields(t *testing.T) {
+	tests := []struct {
+		ua      string
+		browser string
+		os      string
+		device  string
+		isBot   bool
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/80.0.3987.149 Safari/537.36",
+			"Chrome", "Windows", "desktop", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/80.0.3987.149 Safari/537.36 Edg/80.0.361.69",
+			"Edge", "Windows", "desktop", false},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_3) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.5 Safari/605.1.15",
+			"Safari", "macOS", "desktop", false},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 13_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.4 Mobile/15E148 Safari/604.1",
+			"Safari", "iOS", "mobile", false},
+		{"Mozilla/5.0 (iPad; CPU OS 13_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/80.0.3987.95 Mobile/15E148 Safari/604.1",
+			"Chrome", "iOS", "tablet", false},
+		{"Mozilla/5.0 (Linux; Android 10; SM-G975F) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/11.1 Chrome/75.0.3770.143 Mobile Safari/537.36",
+			"Samsung Internet", "Android", "mobile", false},
+		{"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:74.0) Gecko/20100101 Firefox/74.0",
+			"Firefox", "Linux", "desktop", false},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			"", "", "bot", true},
+		{"curl/7.68.0", "", "", "bot", true},
+	}
+	for _, tt := range tests {
+		props := map[string]interface{}{"request.header.user_agent": tt.ua}
+		AddUserAgentFields(props)
+		if tt.browser == "" {
+			assert.NotContains(t, props, "request.ua.browser", tt.ua)
+		} else {
+			assert.Equal(t, tt.browser, props["request.ua.browser"], tt.ua)
+		}
+		if tt.os == "" {
+			assert.NotContains(t, props, "request.ua.os", tt.ua)
+		} else {
+			assert.Equal(t, tt.os, props["request.ua.os"], tt.ua)
+		}
+		assert.Equal(t, tt.device, props["request.ua.device"], tt.ua)
+		assert.Equal(t, tt.isBot, props["request.ua.is_bot"], tt.ua)
+	}
+
+	props := map[string]interface{}{}
+	AddUserAgentFields(props)
+	assert.Empty(t, props, "no user agent, no fields")
+}
+
+func TestGetRequestPropsParsesUserAgent(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "curl/7.68.0")
+	assert.NotContains(t, GetRequestProps(r), "request.ua.is_bot", "off by default")
+
+	trace.GlobalConfig.ParseUserAgents = true
+	defer func() { trace.GlobalConfig.ParseUserAgents = false }()
+	assert.Equal(t, true, GetRequestProps(r)["request.ua.is_bot"])
+}
@@ -0,0 +1,98 @@
+package hnyauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+type contextKey int
+
+const authStateKey contextKey = iota
+
+// authState follows one request through the auth middleware.
+type authState struct {
+	parent  *trace.Span
+	span    *trace.Span
+	start   time.Time
+	allowed bool
+}
+
+// Middleware wraps an auth middleware so that the time it takes and its
+// decision are recorded. The request must already have a span in its context.
+func Middleware(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		inner := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state, ok := r.Context().Value(authStateKey).(*authState)
+			if !ok || state.allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+			state.allowed = true
+			state.finish("allow", 0)
+
+			// run the handler as a sibling of the auth span
+			handlerStart := time.Now()
+			next.ServeHTTP(w, r.WithContext(trace.PutSpanInContext(r.Context(), state.parent)))
+			state.parent.AddField("auth.handler_duration_ms", float64(time.Since(handlerStart))/float64(time.Millisecond))
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent := trace.GetSpanFromContext(r.Context())
+			if parent == nil {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			ctx, span := parent.CreateChild(r.Context())
+			span.AddField("meta.type", "auth")
+			span.AddField("name", "auth")
+			state := &authState{parent: parent, span: span, start: time.Now()}
+			ctx = context.WithValue(ctx, authStateKey, state)
+
+			rw := common.NewResponseWriter(w)
+			inner.ServeHTTP(rw.Wrapped, r.WithContext(ctx))
+			if !state.allowed {
+				state.finish("deny", rw.Status)
+			}
+		})
+	}
+}
+
+func (s *authState) finish(decision string, status int) {
+	dur := float64(time.Since(s.start)) / float64(time.Millisecond)
+	s.span.AddField("auth.decision", decision)
+	if status != 0 {
+		s.span.AddField("auth.status_code", status)
+	}
+	s.span.Send()
+	s.parent.AddField("auth.decision", decision)
+	s.parent.AddField("auth.duration_ms", dur)
+}
+
+// Identify records how the request in ctx was authenticated, eg "bearer" or
+// "session", and a hash of the principal it authenticated as. It should be
+// called from inside a middleware wrapped by Middleware.
+func Identify(ctx context.Context, method, principal string) {
+	state, ok := ctx.Value(authStateKey).(*authState)
+	if !ok {
+		return
+	}
+	for _, span := range []*trace.Span{state.span, state.parent} {
+		span.AddField("auth.method", method)
+		if principal != "" {
+			span.AddField("auth.principal_hash", HashPrincipal(principal))
+		}
+	}
+}
+
+// HashPrincipal returns the value recorded in auth.principal_hash: the first
+// 16 hex digits of the SHA-256 of principal.
+func HashPrincipal(principal string) string {
+	sum := sha256.Sum256([]byte(principal))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,109 @@
+package hnyauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/wrappers/hnynethttp"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+// requireToken lets requests with the token "secret" through.
+func requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		Identify(r.Context(), "bearer", "user@example.com")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func eventsByName(evs []*transmission.Event) map[string]map[string]interface{} {
+	byName := make(map[string]map[string]interface{})
+	for _, ev := range evs {
+		name, _ := ev.Data["name"].(string)
+		byName[name] = ev.Data
+	}
+	return byName
+}
+
+func TestAllowed(t *testing.T) {
+	mo := setupLibhoney(t)
+	handler := hnynethttp.WrapHandler(Middleware(requireToken)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := beeline.StartSpan(r.Context(), "work")
+		span.Send()
+	})))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	evs := eventsByName(mo.Events())
+	auth, work := evs["auth"], evs["work"]
+	var request map[string]interface{}
+	for name, ev := range evs {
+		if name != "auth" && name != "work" {
+			request = ev
+		}
+	}
+	if assert.NotNil(t, auth) && assert.NotNil(t, work) && assert.NotNil(t, request) {
+		assert.Equal(t, "allow", auth["auth.decision"])
+		assert.Equal(t, "bearer", auth["auth.method"])
+		assert.Equal(t, HashPrincipal("user@example.com"), auth["auth.principal_hash"])
+		assert.NotContains(t, auth["auth.principal_hash"], "example.com")
+		assert.Equal(t, request["trace.span_id"], work["trace.parent_id"],
+			"the handler should not be a child of the auth span")
+		assert.Equal(t, request["trace.span_id"], auth["trace.parent_id"])
+		assert.Equal(t, "allow", request["auth.decision"])
+		assert.Contains(t, request, "auth.duration_ms")
+		assert.Contains(t, request, "auth.handler_duration_ms")
+	}
+}
+
+func TestDenied(t *testing.T) {
+	mo := setupLibhoney(t)
+	called := false
+	handler := hnynethttp.WrapHandler(Middleware(requireToken)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.False(t, called)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	evs := eventsByName(mo.Events())
+	if auth := evs["auth"]; assert.NotNil(t, auth) {
+		assert.Equal(t, "deny", auth["auth.decision"])
+		assert.Equal(t, http.StatusForbidden, auth["auth.status_code"])
+		assert.NotContains(t, auth, "auth.method")
+	}
+}
+
+func TestWithoutTrace(t *testing.T) {
+	setupLibhoney(t)
+	called := false
+	handler := Middleware(requireToken)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, called)
+}
@@ -0,0 +1,20 @@
+// Package hnyauth times authentication and authorization middleware
+// separately from the handler it protects.
+//
+// Wrap any auth middleware with hnyauth.Middleware, inside the middleware that
+// starts the request's trace:
+//
+//	handler := hnynethttp.WrapHandler(hnyauth.Middleware(requireLogin)(mux))
+//
+// The auth middleware is timed in an "auth" span that ends when it calls the
+// next handler, or when it returns without doing so. Calling the next handler
+// is recorded as a decision of "allow" and returning without it as "deny". The
+// next handler runs as a child of the request span rather than the auth span,
+// and the request span gets auth.duration_ms and auth.handler_duration_ms, so
+// slow auth can be told apart from a slow handler.
+//
+// Call hnyauth.Identify from inside the auth middleware to record how the
+// request authenticated and who as. The principal is hashed before it is
+// recorded, so requests from the same principal can be grouped without
+// sending their identity to Honeycomb.
+package hnyauth
@@ -0,0 +1,100 @@
+package hnyaws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+const (
+	startHandlerName  = "hnyaws.StartSpan"
+	finishHandlerName = "hnyaws.FinishSpan"
+)
+
+// WrapSession adds the handlers to s, so the clients made from it emit a
+// span for each call, and returns s.
+func WrapSession(s *session.Session) *session.Session {
+	AddHandlers(&s.Handlers)
+	return s
+}
+
+// AddHandlers adds the handlers that emit a span for each call to h, such as
+// a client's Handlers. Adding them again replaces those already added.
+func AddHandlers(h *request.Handlers) {
+	c := &calls{spans: make(map[*request.Request]*call)}
+	h.Send.RemoveByName(startHandlerName)
+	h.Send.PushFrontNamed(request.NamedHandler{Name: startHandlerName, Fn: c.start})
+	h.Complete.RemoveByName(finishHandlerName)
+	h.Complete.PushBackNamed(request.NamedHandler{Name: finishHandlerName, Fn: c.finish})
+}
+
+// calls holds the span for each request between its first send and its
+// completion.
+type calls struct {
+	lock  sync.Mutex
+	spans map[*request.Request]*call
+}
+
+type call struct {
+	span  *trace.Span
+	start time.Time
+}
+
+// start starts the span for r the first time it's sent. Retries are sent
+// again, and are timed in the same span.
+func (c *calls) start(r *request.Request) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.spans[r]; ok {
+		return
+	}
+	_, span := common.StartChildSpan(r.Context())
+	span.AddField("meta.type", "aws")
+	span.AddField("name", r.ClientInfo.ServiceName+"."+operation(r))
+	span.AddField("aws.service", r.ClientInfo.ServiceName)
+	span.AddField("aws.operation", operation(r))
+	span.AddField("aws.region", aws.StringValue(r.Config.Region))
+	c.spans[r] = &call{span: span, start: time.Now()}
+}
+
+func (c *calls) finish(r *request.Request) {
+	c.lock.Lock()
+	cl, ok := c.spans[r]
+	delete(c.spans, r)
+	c.lock.Unlock()
+	if !ok {
+		// the call failed before it was sent
+		return
+	}
+	span := cl.span
+	span.AddField("aws.retry_count", r.RetryCount)
+	if r.RequestID != "" {
+		span.AddField("aws.request_id", r.RequestID)
+	}
+	if r.HTTPResponse != nil {
+		span.AddField("response.status_code", r.HTTPResponse.StatusCode)
+	}
+	if r.Error != nil {
+		span.AddField("aws.error", r.Error.Error())
+		if aerr, ok := r.Error.(awserr.Error); ok {
+			span.AddField("aws.error_code", aerr.Code())
+		}
+	}
+	span.AddRollupField("aws.duration_ms", float64(time.Since(cl.start))/float64(time.Millisecond))
+	span.AddRollupField("aws.call_count", 1)
+	span.Send()
+}
+
+func operation(r *request.Request) string {
+	if r.Operation == nil {
+		return ""
+	}
+	return r.Operation.Name
+}
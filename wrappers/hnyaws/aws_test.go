@@ -0,0 +1,101 @@
+package hnyaws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func newS3(t *testing.T, ts *httptest.Server) *s3.S3 {
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-west-2"),
+		Endpoint:         aws.String(ts.URL),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		S3ForcePathStyle: aws.Bool(true),
+		MaxRetries:       aws.Int(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// adding the handlers twice shouldn't send spans twice
+	AddHandlers(&sess.Handlers)
+	return s3.New(WrapSession(sess))
+}
+
+func TestCall(t *testing.T) {
+	mo := setupLibhoney(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amz-Request-Id", "req-1")
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+	svc := newS3(t, ts)
+
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	_, err := svc.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String("b"), Key: aws.String("k")})
+	assert.NoError(t, err)
+	root.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 2, len(evs)) {
+		return
+	}
+	call, req := evs[0].Data, evs[1].Data
+	assert.Equal(t, "s3.GetObject", call["name"])
+	assert.Equal(t, "aws", call["meta.type"])
+	assert.Equal(t, "s3", call["aws.service"])
+	assert.Equal(t, "GetObject", call["aws.operation"])
+	assert.Equal(t, "us-west-2", call["aws.region"])
+	assert.Equal(t, "req-1", call["aws.request_id"])
+	assert.Equal(t, 0, call["aws.retry_count"])
+	assert.Equal(t, 200, call["response.status_code"])
+	assert.NotContains(t, call, "aws.error")
+	assert.Equal(t, req["trace.span_id"], call["trace.parent_id"])
+	assert.Equal(t, 1.0, req["rollup.aws.call_count"])
+}
+
+func TestCallRetried(t *testing.T) {
+	mo := setupLibhoney(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	svc := newS3(t, ts)
+
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	_, err := svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String("b"), Key: aws.String("k")})
+	assert.Error(t, err)
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		call := evs[0].Data
+		assert.Equal(t, "s3.HeadObject", call["name"])
+		assert.Equal(t, 1, call["aws.retry_count"])
+		assert.Equal(t, 503, call["response.status_code"])
+		assert.Contains(t, call, "aws.error")
+		assert.Equal(t, "ServiceUnavailable", call["aws.error_code"])
+	}
+}
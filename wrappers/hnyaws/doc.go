@@ -0,0 +1,19 @@
+// Package hnyaws emits spans for the API calls made with aws-sdk-go.
+//
+// Add the handlers to a session, so every client made from it is traced, and
+// make calls with the WithContext variants of the client's methods, so the
+// spans are children of the span in the context:
+//
+//	sess := hnyaws.WrapSession(session.Must(session.NewSession()))
+//	svc := s3.New(sess)
+//	out, err := svc.GetObjectWithContext(r.Context(), input)
+//
+// Each call's span is named for the service and operation, and records them
+// in aws.service and aws.operation, along with aws.region, aws.request_id,
+// aws.retry_count and response.status_code. Calls that fail also record
+// aws.error and, for errors from the service, aws.error_code. The span covers
+// the call from when it is first sent, including any retries, until it
+// completes. The time spent in AWS calls and the number of calls made are
+// also rolled up onto the root span, as rollup.aws.duration_ms and
+// rollup.aws.call_count.
+package hnyaws
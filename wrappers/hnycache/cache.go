@@ -0,0 +1,178 @@
+package hnycache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Cache is the set of operations instrumented on a cache. Adapt a cache
+// library to it to time its operations.
+type Cache interface {
+	// Get returns the value stored for key, and whether there was one.
+	Get(ctx context.Context, key string) (interface{}, bool, error)
+	// Set stores value for key, expiring it after ttl if ttl is positive.
+	// It returns true if another entry was evicted to make room.
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+	// Delete removes any value stored for key.
+	Delete(ctx context.Context, key string) error
+}
+
+// Wrap returns a Cache that emits a span for each operation on c. name is
+// recorded in cache.name to tell caches apart.
+func Wrap(name string, c Cache) Cache {
+	return &wrappedCache{name: name, cache: c}
+}
+
+type wrappedCache struct {
+	name  string
+	cache Cache
+}
+
+func (w *wrappedCache) Get(ctx context.Context, key string) (interface{}, bool, error) {
+	op := StartOperation(ctx, w.name, "get", key)
+	val, ok, err := w.cache.Get(ctx, key)
+	if err == nil {
+		op.Hit(ok)
+		op.Size(valueSize(val))
+	}
+	op.Finish(err)
+	return val, ok, err
+}
+
+func (w *wrappedCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	op := StartOperation(ctx, w.name, "set", key)
+	if ttl > 0 {
+		op.span.AddField("cache.ttl_ms", float64(ttl)/float64(time.Millisecond))
+	}
+	op.Size(valueSize(value))
+	evicted, err := w.cache.Set(ctx, key, value, ttl)
+	op.span.AddField("cache.evicted", evicted)
+	if evicted {
+		op.span.AddRollupField("cache.eviction_count", 1)
+	}
+	op.Finish(err)
+	return evicted, err
+}
+
+func (w *wrappedCache) Delete(ctx context.Context, key string) error {
+	op := StartOperation(ctx, w.name, "delete", key)
+	err := w.cache.Delete(ctx, key)
+	op.Finish(err)
+	return err
+}
+
+// Operation times one operation on a cache, for caches that can't be adapted
+// to the Cache interface, such as those with batch operations. Start one
+// with StartOperation, record what it found and Finish it.
+type Operation struct {
+	span  *trace.Span
+	start time.Time
+}
+
+// StartOperation starts a span for operation, eg "get", on the cache called
+// name, as a child of the span in ctx. For keys namespaced with colons, the
+// first part of key is recorded as cache.key_prefix. The rest of the key is
+// not recorded.
+func StartOperation(ctx context.Context, name, operation, key string) *Operation {
+	_, span := common.StartChildSpan(ctx)
+	span.AddField("meta.type", "cache")
+	span.AddField("name", "cache."+operation)
+	span.AddField("cache.name", name)
+	span.AddField("cache.operation", operation)
+	if prefix := keyPrefix(key); prefix != "" {
+		span.AddField("cache.key_prefix", prefix)
+	}
+	return &Operation{span: span, start: time.Now()}
+}
+
+// AddField adds a field to the operation's span.
+func (o *Operation) AddField(key string, val interface{}) {
+	o.span.AddField(key, val)
+}
+
+// Hit records whether a lookup found what it was looking for, as cache.hit,
+// and counts it in the root span's rollups and cache.hit_rate.
+func (o *Operation) Hit(hit bool) {
+	o.span.AddField("cache.hit", hit)
+	if hit {
+		o.countHits(1, 0)
+	} else {
+		o.countHits(0, 1)
+	}
+}
+
+// Hits records the keys found and missed by a lookup of several at once, as
+// cache.hits and cache.misses, and counts them like Hit.
+func (o *Operation) Hits(hits, misses int) {
+	o.span.AddField("cache.hits", hits)
+	o.span.AddField("cache.misses", misses)
+	o.countHits(hits, misses)
+}
+
+func (o *Operation) countHits(hits, misses int) {
+	if hits > 0 {
+		o.span.AddRollupField("cache.hit_count", float64(hits))
+	}
+	if misses > 0 {
+		o.span.AddRollupField("cache.miss_count", float64(misses))
+	}
+	addHitRate(o.span)
+}
+
+// Size records the size in bytes of the item read or written, as
+// cache.item_size. Sizes that aren't positive are not recorded.
+func (o *Operation) Size(bytes int) {
+	if bytes > 0 {
+		o.span.AddField("cache.item_size", bytes)
+	}
+}
+
+// Finish sends the operation's span, with err, if there was one, as
+// cache.error.
+func (o *Operation) Finish(err error) {
+	if err != nil {
+		o.span.AddField("cache.error", err.Error())
+	}
+	o.span.AddRollupField("cache.duration_ms", float64(time.Since(o.start))/float64(time.Millisecond))
+	o.span.AddRollupField("cache.call_count", 1)
+	o.span.Send()
+}
+
+// addHitRate updates the root span's cache.hit_rate from the hits and misses
+// so far in the trace.
+func addHitRate(span *trace.Span) {
+	tr := span.GetTrace()
+	if tr == nil {
+		return
+	}
+	hits := tr.GetRollupField("cache.hit_count")
+	misses := tr.GetRollupField("cache.miss_count")
+	if hits+misses > 0 {
+		tr.GetRootSpan().AddField("cache.hit_rate", hits/(hits+misses))
+	}
+}
+
+// keyPrefix returns the part of key before its first colon, or "" if it has
+// none.
+func keyPrefix(key string) string {
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// valueSize returns the size of values stored as bytes or strings, or 0 for
+// others, whose size isn't known.
+func valueSize(val interface{}) int {
+	switch v := val.(type) {
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	}
+	return 0
+}
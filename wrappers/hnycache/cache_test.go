@@ -0,0 +1,135 @@
+package hnycache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+// oneEntry is a cache with room for a single entry.
+type oneEntry struct {
+	key   string
+	value interface{}
+}
+
+var errBroken = errors.New("broken")
+
+func (c *oneEntry) Get(ctx context.Context, key string) (interface{}, bool, error) {
+	if key == "broken" {
+		return nil, false, errBroken
+	}
+	if c.key == key {
+		return c.value, true, nil
+	}
+	return nil, false, nil
+}
+
+func (c *oneEntry) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	evicted := c.key != "" && c.key != key
+	c.key, c.value = key, value
+	return evicted, nil
+}
+
+func (c *oneEntry) Delete(ctx context.Context, key string) error {
+	if c.key == key {
+		c.key, c.value = "", nil
+	}
+	return nil
+}
+
+func TestCache(t *testing.T) {
+	mo := setupLibhoney(t)
+	cache := Wrap("things", &oneEntry{})
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+
+	cache.Set(ctx, "a", 1, time.Second)
+	v, ok, err := cache.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	cache.Set(ctx, "b", 2, 0)
+	_, ok, _ = cache.Get(ctx, "a")
+	assert.False(t, ok)
+	cache.Get(ctx, "b")
+	cache.Delete(ctx, "b")
+	_, _, err = cache.Get(ctx, "broken")
+	assert.Equal(t, errBroken, err)
+	span.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 8, len(evs)) {
+		return
+	}
+	set := evs[0].Data
+	assert.Equal(t, "cache", set["meta.type"])
+	assert.Equal(t, "cache.set", set["name"])
+	assert.Equal(t, "things", set["cache.name"])
+	assert.Equal(t, float64(1000), set["cache.ttl_ms"])
+	assert.Equal(t, false, set["cache.evicted"])
+	assert.Equal(t, true, evs[1].Data["cache.hit"])
+	assert.Equal(t, true, evs[2].Data["cache.evicted"])
+	assert.Equal(t, false, evs[3].Data["cache.hit"])
+	assert.Equal(t, "broken", evs[6].Data["cache.error"])
+	assert.NotContains(t, evs[6].Data, "cache.hit")
+
+	root := evs[7].Data
+	assert.Equal(t, float64(2), root["rollup.cache.hit_count"])
+	assert.Equal(t, float64(1), root["rollup.cache.miss_count"])
+	assert.Equal(t, float64(1), root["rollup.cache.eviction_count"])
+	assert.Equal(t, float64(7), root["rollup.cache.call_count"])
+	assert.InDelta(t, 2.0/3.0, root["cache.hit_rate"], 0.0001)
+}
+
+func TestOperation(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+
+	op := StartOperation(ctx, "profiles", "get_multi", "user:42:profile")
+	op.AddField("cache.keys", 4)
+	op.Hits(3, 1)
+	op.Finish(nil)
+	cache := Wrap("things", &oneEntry{})
+	cache.Set(ctx, "session:abc", []byte("hello"), 0)
+	cache.Get(ctx, "session:abc")
+	cache.Get(ctx, "plain")
+	span.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 5, len(evs)) {
+		return
+	}
+	multi := evs[0].Data
+	assert.Equal(t, "cache.get_multi", multi["name"])
+	assert.Equal(t, "user", multi["cache.key_prefix"])
+	assert.Equal(t, 4, multi["cache.keys"])
+	assert.Equal(t, 3, multi["cache.hits"])
+	assert.Equal(t, 1, multi["cache.misses"])
+	assert.Equal(t, "session", evs[1].Data["cache.key_prefix"])
+	assert.Equal(t, 5, evs[1].Data["cache.item_size"])
+	assert.Equal(t, 5, evs[2].Data["cache.item_size"])
+	assert.NotContains(t, evs[3].Data, "cache.key_prefix")
+	assert.NotContains(t, evs[3].Data, "cache.item_size")
+
+	root := evs[4].Data
+	assert.Equal(t, float64(4), root["rollup.cache.hit_count"])
+	assert.Equal(t, float64(2), root["rollup.cache.miss_count"])
+	assert.InDelta(t, 4.0/6.0, root["cache.hit_rate"], 0.0001)
+}
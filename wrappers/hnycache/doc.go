@@ -0,0 +1,28 @@
+// Package hnycache emits spans for cache lookups and writes, for any cache
+// adapted to the Cache interface.
+//
+// Wrap the cache once and use the wrapped cache everywhere:
+//
+//	sessions := hnycache.Wrap("sessions", mycache)
+//	...
+//	value, ok, err := sessions.Get(r.Context(), sessionID)
+//
+// Each span records the cache's name, the operation, and whether a Get hit or
+// a Set evicted another entry. Keys are not recorded, except for the first
+// part of keys namespaced with colons, as cache.key_prefix. The size of
+// values stored as bytes or strings is recorded as cache.item_size. The
+// number of hits, misses and evictions are also rolled up onto the root span,
+// as rollup.cache.hit_count and so on, along with the request's overall
+// cache.hit_rate.
+//
+// Caches that don't fit the Cache interface, such as those with batch
+// operations, can time each operation with StartOperation instead, for the
+// same fields:
+//
+//	op := hnycache.StartOperation(ctx, "profiles", "get_multi", keys[0])
+//	found, err := profiles.GetMulti(keys)
+//	op.Hits(len(found), len(keys)-len(found))
+//	op.Finish(err)
+//
+// See hnymemcache for a client instrumented this way.
+package hnycache
@@ -0,0 +1,173 @@
+package hnycaddy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+
+	"github.com/honeycombio/beeline-go/wrappers/hnytraefik"
+)
+
+func init() {
+	caddy.RegisterModule(Middleware{})
+	httpcaddyfile.RegisterHandlerDirective("honeycomb", parseCaddyfile)
+	// the span should cover everything else the route does
+	httpcaddyfile.RegisterDirectiveOrder("honeycomb", httpcaddyfile.Before, "tracing")
+}
+
+// Middleware is the http.handlers.honeycomb module. It starts a span for
+// every request, continuing the trace in the request's headers if it has one,
+// and passes the trace on to the handlers after it.
+type Middleware struct {
+	// WriteKey is the Honeycomb write key. It is required, and may be a
+	// placeholder such as {env.HONEYCOMB_API_KEY}.
+	WriteKey string `json:"write_key,omitempty"`
+	// Dataset is the dataset to send events to. default: caddy
+	Dataset string `json:"dataset,omitempty"`
+	// APIHost overrides the Honeycomb API host.
+	// default: https://api.honeycomb.io
+	APIHost string `json:"api_host,omitempty"`
+	// ServiceName is added to every event as service_name. default: caddy
+	ServiceName string `json:"service_name,omitempty"`
+	// SampleRate keeps 1 in SampleRate traces, deciding by trace id as the
+	// beeline's deterministic sampler does. default: 1
+	SampleRate uint `json:"sample_rate,omitempty"`
+
+	handler http.Handler
+}
+
+// CaddyModule returns the Caddy module information.
+func (Middleware) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.honeycomb",
+		New: func() caddy.Module { return new(Middleware) },
+	}
+}
+
+// Provision fills in the defaults and placeholders, and sets up the sender
+// the middleware's events are sent with.
+func (m *Middleware) Provision(ctx caddy.Context) error {
+	repl := caddy.NewReplacer()
+	config := hnytraefik.CreateConfig()
+	config.WriteKey = repl.ReplaceAll(m.WriteKey, "")
+	if config.WriteKey == "" {
+		return errors.New("hnycaddy: write_key is required")
+	}
+	config.Dataset = "caddy"
+	if m.Dataset != "" {
+		config.Dataset = repl.ReplaceAll(m.Dataset, "")
+	}
+	if m.APIHost != "" {
+		config.APIHost = repl.ReplaceAll(m.APIHost, "")
+	}
+	config.ServiceName = "caddy"
+	if m.ServiceName != "" {
+		config.ServiceName = repl.ReplaceAll(m.ServiceName, "")
+	}
+	if m.SampleRate > 0 {
+		config.SampleRate = m.SampleRate
+	}
+	handler, err := hnytraefik.New(ctx, http.HandlerFunc(serveNext), config, "caddy")
+	if err != nil {
+		return err
+	}
+	m.handler = handler
+	return nil
+}
+
+// nextKey is the context key the call to the next handler is passed to
+// serveNext with.
+type nextKey struct{}
+
+// nextCall is the next handler of a request, and the error it returned.
+type nextCall struct {
+	next caddyhttp.Handler
+	err  error
+}
+
+// ServeHTTP serves the request with next, recording it in a span.
+func (m Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	c := &nextCall{next: next}
+	m.handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), nextKey{}, c)))
+	return c.err
+}
+
+// serveNext is the handler the span is recorded around. It calls the next
+// handler of the request. Caddy handlers return errors for the server to
+// write the response for after the middleware returns, so their status is
+// recorded with the error.
+func serveNext(w http.ResponseWriter, r *http.Request) {
+	c := r.Context().Value(nextKey{}).(*nextCall)
+	c.err = c.next.ServeHTTP(w, r)
+	if c.err == nil {
+		return
+	}
+	status := http.StatusInternalServerError
+	var he caddyhttp.HandlerError
+	if errors.As(c.err, &he) && he.StatusCode != 0 {
+		status = he.StatusCode
+	}
+	if er, ok := w.(hnytraefik.ErrorRecorder); ok {
+		er.RecordError(status, c.err)
+	}
+}
+
+// UnmarshalCaddyfile sets up the middleware from Caddyfile tokens:
+//
+//	honeycomb {
+//		write_key    <key>
+//		dataset      <dataset>
+//		api_host     <url>
+//		service_name <name>
+//		sample_rate  <rate>
+//	}
+func (m *Middleware) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	d.Next() // the directive's name
+	if d.NextArg() {
+		return d.ArgErr()
+	}
+	for d.NextBlock(0) {
+		name := d.Val()
+		var value string
+		if !d.AllArgs(&value) {
+			return d.ArgErr()
+		}
+		switch name {
+		case "write_key":
+			m.WriteKey = value
+		case "dataset":
+			m.Dataset = value
+		case "api_host":
+			m.APIHost = value
+		case "service_name":
+			m.ServiceName = value
+		case "sample_rate":
+			rate, err := strconv.ParseUint(value, 10, 32)
+			if err != nil || rate == 0 {
+				return d.Errf("invalid sample_rate %q", value)
+			}
+			m.SampleRate = uint(rate)
+		default:
+			return d.Errf("unknown subdirective %q", name)
+		}
+	}
+	return nil
+}
+
+func parseCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	m := new(Middleware)
+	err := m.UnmarshalCaddyfile(h.Dispenser)
+	return m, err
+}
+
+var (
+	_ caddy.Provisioner           = (*Middleware)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Middleware)(nil)
+	_ caddyfile.Unmarshaler       = (*Middleware)(nil)
+)
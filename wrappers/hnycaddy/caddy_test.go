@@ -0,0 +1,183 @@
+package hnycaddy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// fakeAPI is a Honeycomb batch API that keeps the events sent to it.
+type fakeAPI struct {
+	*httptest.Server
+	mu     sync.Mutex
+	events []map[string]interface{}
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/batch/edge" || r.Header.Get("X-Honeycomb-Team") != "key" {
+			t.Errorf("unexpected request to %s with write key %q", r.URL.Path, r.Header.Get("X-Honeycomb-Team"))
+		}
+		var batch []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("decoding batch: %v", err)
+		}
+		api.mu.Lock()
+		api.events = append(api.events, batch...)
+		api.mu.Unlock()
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// waitForFields returns the fields of the events sent to the API once there
+// are n of them.
+func (api *fakeAPI) waitForFields(t *testing.T, n int) []map[string]interface{} {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		api.mu.Lock()
+		events := api.events
+		api.mu.Unlock()
+		if len(events) >= n {
+			fields := make([]map[string]interface{}, len(events))
+			for i, ev := range events {
+				fields[i] = ev["data"].(map[string]interface{})
+			}
+			return fields
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d events to be sent", n)
+	return nil
+}
+
+func provision(t *testing.T, m *Middleware) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	t.Cleanup(cancel)
+	if err := m.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProvisionRequiresWriteKey(t *testing.T) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	if err := new(Middleware).Provision(ctx); err == nil {
+		t.Error("expected an error without a write key")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	api := newFakeAPI(t)
+	t.Setenv("HNYCADDY_TEST_KEY", "key")
+	m := &Middleware{WriteKey: "{env.HNYCADDY_TEST_KEY}", Dataset: "edge", APIHost: api.URL}
+	provision(t, m)
+
+	var backendHeader string
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		backendHeader = r.Header.Get("X-Honeycomb-Trace")
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	})
+	r := httptest.NewRequest("GET", "http://example.com/hello", nil)
+	if err := m.ServeHTTP(httptest.NewRecorder(), r, next); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := api.waitForFields(t, 1)[0]
+	for k, v := range map[string]interface{}{
+		"name":                 "caddy",
+		"service_name":         "caddy",
+		"request.path":         "/hello",
+		"response.status_code": float64(201),
+	} {
+		if fields[k] != v {
+			t.Errorf("%s: got %v, expected %v", k, fields[k], v)
+		}
+	}
+	expected := "1;trace_id=" + fields["trace.trace_id"].(string) + ",parent_id=" + fields["trace.span_id"].(string)
+	if backendHeader != expected {
+		t.Errorf("backend got X-Honeycomb-Trace %q, expected %q", backendHeader, expected)
+	}
+}
+
+func TestMiddlewareRecordsHandlerError(t *testing.T) {
+	api := newFakeAPI(t)
+	m := &Middleware{WriteKey: "key", Dataset: "edge", APIHost: api.URL}
+	provision(t, m)
+
+	handlerErr := caddyhttp.Error(http.StatusBadGateway, context.DeadlineExceeded)
+	next := caddyhttp.HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		return handlerErr
+	})
+	err := m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/", nil), next)
+	if err != handlerErr {
+		t.Errorf("got error %v, expected the handler's to be returned", err)
+	}
+
+	fields := api.waitForFields(t, 1)[0]
+	if fields["response.status_code"] != float64(502) || fields["error"] != handlerErr.Error() {
+		t.Errorf("got status %v and error %v", fields["response.status_code"], fields["error"])
+	}
+}
+
+func TestUnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`honeycomb {
+		write_key key
+		dataset edge
+		api_host https://api.example.com
+		service_name proxy
+		sample_rate 10
+	}`)
+	var m Middleware
+	if err := m.UnmarshalCaddyfile(d); err != nil {
+		t.Fatal(err)
+	}
+	expected := Middleware{WriteKey: "key", Dataset: "edge", APIHost: "https://api.example.com", ServiceName: "proxy", SampleRate: 10}
+	if m != expected {
+		t.Errorf("got %+v, expected %+v", m, expected)
+	}
+
+	for _, bad := range []string{
+		"honeycomb key",
+		"honeycomb {\n sample_rate lots \n}",
+		"honeycomb {\n writekey key \n}",
+		"honeycomb {\n dataset \n}",
+	} {
+		if err := new(Middleware).UnmarshalCaddyfile(caddyfile.NewTestDispenser(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestCaddyfileDirective(t *testing.T) {
+	adapter := caddyconfig.GetAdapter("caddyfile")
+	config, _, err := adapter.Adapt([]byte(`:8080 {
+		reverse_proxy localhost:9090
+		honeycomb {
+			write_key key
+		}
+	}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the honeycomb handler comes first, wrapping the proxy
+	s := string(config)
+	honeycomb, proxy := strings.Index(s, `"handler":"honeycomb"`), strings.Index(s, `"handler":"reverse_proxy"`)
+	if honeycomb == -1 || proxy == -1 || honeycomb > proxy {
+		t.Errorf("unexpected config %s", s)
+	}
+}
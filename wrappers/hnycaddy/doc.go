@@ -0,0 +1,39 @@
+// Package hnycaddy is a Caddy module that starts a trace for every request
+// the server handles and passes it on to the handlers after it, such as
+// reverse_proxy, and so to the backend.
+//
+// Caddy needs a newer OpenTelemetry than the beeline's propagation package
+// is written against, so this module doesn't use the beeline. It shares the
+// middleware of the hnytraefik Traefik plugin, which uses only the standard
+// library: its events have the fields hnynethttp.WrapHandler records, and its
+// sampling decisions are the beeline's, so its spans fit in the traces of
+// beeline-instrumented backends.
+//
+// Build Caddy with the module with xcaddy:
+//
+//	xcaddy build --with github.com/honeycombio/beeline-go/wrappers/hnycaddy
+//
+// and add the honeycomb directive to a site. It is ordered before tracing,
+// so its span covers everything the route does:
+//
+//	example.com {
+//		honeycomb {
+//			write_key {env.HONEYCOMB_API_KEY}
+//			dataset edge
+//		}
+//		reverse_proxy localhost:8080
+//	}
+//
+// In JSON config, the handler is "honeycomb", with the fields of Middleware.
+//
+// Each request gets a span, and the request passed on carries the
+// X-Honeycomb-Trace and, for trace ids the W3C format can hold, traceparent
+// headers, so instrumented backends continue the trace the server started. A
+// trace header already on the incoming request is continued too. When a
+// handler returns an error, the span records it with the status the error is
+// sent with.
+//
+// Events are sent in batches from a background goroutine, so requests aren't
+// held up sending them. Events that can't be queued because the API is slow
+// to take them are dropped.
+package hnycaddy
@@ -0,0 +1,53 @@
+package hnychi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Middleware is a chi middleware to add Honeycomb instrumentation to the chi
+// router.
+func Middleware(handler http.Handler) http.Handler {
+	wrappedHandler := func(w http.ResponseWriter, r *http.Request) {
+		if common.IgnoreRequest(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		// get a new context with our trace from the request, and add common fields
+		ctx, span := common.StartSpanOrTraceFromHTTP(r)
+		defer span.Send()
+		common.SetResponseHeaders(w.Header(), r, span)
+		// push the context with our trace and span on to the request
+		r = r.WithContext(ctx)
+
+		// replace the writer with our wrapper to catch the status code
+		wrappedWriter := common.NewResponseWriter(w)
+		defer wrappedWriter.RecordPanic(span)
+		handler.ServeHTTP(wrappedWriter.Wrapped, r)
+
+		// middleware added with Use runs before chi routes the request, so
+		// the pattern and URL params are only known once the handler returns
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span.AddField("handler.route", pattern)
+				span.AddField("name", r.Method+" "+pattern)
+			}
+			for i, k := range rctx.URLParams.Keys {
+				if k == "*" {
+					k = "wildcard"
+				}
+				span.AddField("chi.params."+k, rctx.URLParams.Values[i])
+			}
+		}
+		if wrappedWriter.Status == 0 {
+			wrappedWriter.Status = 200
+		}
+		span.AddField("response.status_code", wrappedWriter.Status)
+		wrappedWriter.AddResponseFields(span)
+		wrappedWriter.AddAnomalyFields(span)
+		common.AddContextFields(ctx, span)
+	}
+	return http.HandlerFunc(wrappedHandler)
+}
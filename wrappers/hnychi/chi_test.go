@@ -0,0 +1,77 @@
+package hnychi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChiMiddleware(t *testing.T) {
+	// set up libhoney to catch events instead of send them
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	// build the chi router with Middleware
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/hello/{name}", func(_ http.ResponseWriter, _ *http.Request) {})
+	router.Route("/users/{id}", func(r chi.Router) {
+		r.Get("/files/*", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(204)
+		})
+	})
+
+	t.Run("route with a param", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/hello/pooh", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		evs := mo.Events()
+		if assert.Equal(t, 1, len(evs), "one event is created with one request through the Middleware") {
+			fields := evs[0].Data
+			assert.Equal(t, 200, fields["response.status_code"])
+			assert.Equal(t, "/hello/{name}", fields["handler.route"])
+			assert.Equal(t, "GET /hello/{name}", fields["name"])
+			assert.Equal(t, "pooh", fields["chi.params.name"])
+		}
+	})
+
+	t.Run("nested route with a wildcard", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/users/42/files/a/b.txt", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		evs := mo.Events()
+		if assert.Equal(t, 2, len(evs)) {
+			fields := evs[1].Data
+			assert.Equal(t, 204, fields["response.status_code"])
+			assert.Equal(t, "/users/{id}/files/*", fields["handler.route"])
+			assert.Equal(t, "42", fields["chi.params.id"])
+			assert.Equal(t, "a/b.txt", fields["chi.params.wildcard"])
+		}
+	})
+
+	t.Run("no matching route", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/nope", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		evs := mo.Events()
+		if assert.Equal(t, 3, len(evs)) {
+			assert.Equal(t, 404, evs[2].Data["response.status_code"])
+			assert.NotContains(t, evs[2].Data, "handler.route")
+		}
+	})
+}
@@ -0,0 +1,14 @@
+// Package hnychi has Middleware to use with the chi router.
+//
+// Summary
+//
+// hnychi has Middleware to add to a chi router with Use. It starts a span
+// for every request, or a child span if the request already has one from the
+// nethttp WrapHandler function, and once chi has routed the request adds the
+// route pattern that matched and the URL parameters chi extracted from the
+// path.
+//
+// For a complete example showing this wrapper in use, please see the examples in
+// https://github.com/honeycombio/beeline-go/tree/master/examples
+//
+package hnychi
@@ -0,0 +1,19 @@
+package hnychi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+func ExampleMiddleware() {
+	// assume you have handlers named root and hello
+	var root func(w http.ResponseWriter, r *http.Request)
+	var hello func(w http.ResponseWriter, r *http.Request)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	// Routes consist of a path and a handler function.
+	r.Get("/", root)
+	r.Get("/hello/{person}", hello)
+}
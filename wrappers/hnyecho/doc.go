@@ -6,7 +6,16 @@
 // It is recommended to put this middleware first in the chain via Echo.Use().
 // A Honeycomb event will be generated for every request that comes through your
 // Echo router, with basic http fields added. In addition, route related fields will
-// be added for that request route.
+// be added for that request route. Errors returned by handlers are handled
+// by the middleware, with Echo.HTTPErrorHandler, so that the event records
+// the status code actually sent.
+//
+// The request's context carries the span, so handlers can add fields to it:
+//
+//	func hello(c echo.Context) error {
+//		beeline.AddField(c.Request().Context(), "user_id", userID)
+//		return c.String(http.StatusOK, "hello")
+//	}
 //
 // For a complete example showing this wrapper in use, please see the examples in
 // https://github.com/honeycombio/beeline-go/tree/master/examples
@@ -25,9 +25,13 @@ func (e *EchoWrapper) Middleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			r := c.Request()
+			if common.IgnoreRequest(r) {
+				return next(c)
+			}
 			// get a new context with our trace from the request
 			ctx, span := common.StartSpanOrTraceFromHTTP(r)
 			defer span.Send()
+			common.SetResponseHeaders(c.Response().Header(), r, span)
 			// push the context with our trace and span on to the request
 			c.SetRequest(r.WithContext(ctx))
 
@@ -41,6 +45,7 @@ func (e *EchoWrapper) Middleware() echo.MiddlewareFunc {
 
 			// add route related fields
 			span.AddField("route", c.Path())
+			span.AddField("handler.route", c.Path())
 			span.AddField("route.handler", handlerName)
 			for _, name := range c.ParamNames() {
 				// add field for each path param
@@ -49,12 +54,20 @@ func (e *EchoWrapper) Middleware() echo.MiddlewareFunc {
 
 			// invoke next middleware in chain
 			err := next(c)
+			if err != nil {
+				// echo turns errors into responses only once the whole
+				// chain has returned, so have it do so now to see the
+				// status it chooses
+				span.AddField("handler.error", err.Error())
+				c.Error(err)
+			}
 
 			// add fields for http response code and size
 			span.AddField("response.status_code", c.Response().Status)
 			span.AddField("response.size", c.Response().Size)
+			common.AddContextFields(ctx, span)
 
-			return err
+			return nil
 		}
 	}
 }
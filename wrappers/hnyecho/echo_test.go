@@ -66,3 +66,35 @@ func TestEchoMiddleware(t *testing.T) {
 func helloHandler(c echo.Context) error {
 	return c.String(http.StatusOK, "ok")
 }
+
+func TestEchoMiddlewareHandlerError(t *testing.T) {
+	evCatcher := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "abcd",
+		Dataset:      "efgh",
+		APIHost:      "ijkl",
+		Transmission: evCatcher,
+	})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	router := echo.New()
+	router.Use(New().Middleware())
+	router.GET("/users/:id", func(c echo.Context) error {
+		beeline.AddField(c.Request().Context(), "user_id", c.Param("id"))
+		return echo.NewHTTPError(http.StatusNotFound, "no such user")
+	})
+	r, _ := http.NewRequest("GET", "/users/42", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	evs := evCatcher.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, http.StatusNotFound, fields["response.status_code"], "the status should be the one the error handler sent")
+		assert.Contains(t, fields["handler.error"], "no such user")
+		assert.Equal(t, "/users/:id", fields["handler.route"])
+		assert.Equal(t, "42", fields["app.user_id"], "handlers should be able to add fields to the span")
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-func ExampleMiddleware() {
+func ExampleEchoWrapper_Middleware() {
 	// assume you have handlers for hello and bye
 	var hello echo.HandlerFunc
 	var bye echo.HandlerFunc
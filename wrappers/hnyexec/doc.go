@@ -0,0 +1,11 @@
+// Package hnyexec wraps `os/exec` to emit a span for each command run.
+//
+// Use hnyexec.CommandContext in place of exec.CommandContext. The returned
+// *hnyexec.Cmd embeds the *exec.Cmd, so it can be configured in the same way,
+// and its Run, Start, Wait, Output and CombinedOutput methods time the command
+// in a span that is a child of the span in the context.
+//
+// Each span records the binary run, the number of arguments (but not the
+// arguments themselves, which often contain secrets), the exit code and the
+// number of bytes the command wrote to stdout and stderr.
+package hnyexec
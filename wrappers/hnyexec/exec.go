@@ -0,0 +1,151 @@
+package hnyexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Cmd is an *exec.Cmd whose methods emit a span.
+type Cmd struct {
+	*exec.Cmd
+
+	ctx    context.Context
+	span   *trace.Span
+	stdout *countingWriter
+	stderr *countingWriter
+}
+
+// CommandContext returns a Cmd to run the named program with the given
+// arguments, like exec.CommandContext. The command is killed if ctx is done
+// before it completes.
+func CommandContext(ctx context.Context, name string, arg ...string) *Cmd {
+	return &Cmd{
+		Cmd: exec.CommandContext(ctx, name, arg...),
+		ctx: ctx,
+	}
+}
+
+// Run starts the command and waits for it to complete.
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Start starts the command and the span timing it. Wait must be called to
+// send the span.
+func (c *Cmd) Start() error {
+	_, c.span = common.StartChildSpan(c.ctx)
+	c.span.AddField("meta.type", "exec")
+	c.span.AddField("name", "exec")
+	c.span.AddField("exec.binary", c.Path)
+	c.span.AddField("exec.arg_count", len(c.Args)-1)
+
+	if c.Stdout != nil {
+		c.stdout = &countingWriter{w: c.Stdout}
+		c.Stdout = c.stdout
+	}
+	if c.Stderr != nil {
+		if c.stdout != nil && c.Stderr == c.stdout.w {
+			// exec shares one pipe when both are the same writer, so keep
+			// them the same
+			c.stderr = c.stdout
+		} else {
+			c.stderr = &countingWriter{w: c.Stderr}
+		}
+		c.Stderr = c.stderr
+	}
+
+	err := c.Cmd.Start()
+	if err != nil {
+		c.finish(err)
+	}
+	return err
+}
+
+// Wait waits for the command to exit and sends its span.
+func (c *Cmd) Wait() error {
+	err := c.Cmd.Wait()
+	c.finish(err)
+	return err
+}
+
+// Output runs the command and returns its standard output. If Stderr was nil
+// and the command fails with an *exec.ExitError, its Stderr holds the
+// command's standard error.
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+	var stderr *bytes.Buffer
+	if c.Stderr == nil {
+		stderr = &bytes.Buffer{}
+		c.Stderr = stderr
+	}
+	err := c.Run()
+	if ee, ok := err.(*exec.ExitError); ok && stderr != nil {
+		ee.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command and returns its combined standard output
+// and standard error.
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if c.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+	var b bytes.Buffer
+	c.Stdout = &b
+	c.Stderr = &b
+	err := c.Run()
+	return b.Bytes(), err
+}
+
+func (c *Cmd) finish(err error) {
+	if c.span == nil {
+		return
+	}
+	if c.stdout != nil && c.stdout == c.stderr {
+		c.span.AddField("exec.output_bytes", c.stdout.n)
+	} else {
+		if c.stdout != nil {
+			c.span.AddField("exec.stdout_bytes", c.stdout.n)
+		}
+		if c.stderr != nil {
+			c.span.AddField("exec.stderr_bytes", c.stderr.n)
+		}
+	}
+	if c.ProcessState != nil {
+		c.span.AddField("exec.exit_code", c.ProcessState.ExitCode())
+	}
+	if err != nil {
+		c.span.AddField("exec.error", err.Error())
+	}
+	c.span.Send()
+	c.span = nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,97 @@
+package hnyexec
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func requireSh(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+}
+
+func TestOutput(t *testing.T) {
+	requireSh(t)
+	mo := setupLibhoney(t)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+
+	out, err := CommandContext(ctx, "sh", "-c", "printf hello; printf oops >&2").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(out))
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "exec", fields["meta.type"])
+		assert.Contains(t, fields["exec.binary"], "sh")
+		assert.Equal(t, 2, fields["exec.arg_count"])
+		assert.Equal(t, 0, fields["exec.exit_code"])
+		assert.Equal(t, int64(5), fields["exec.stdout_bytes"])
+		assert.Equal(t, int64(4), fields["exec.stderr_bytes"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], fields["trace.parent_id"])
+	}
+}
+
+func TestFailingCommand(t *testing.T) {
+	requireSh(t)
+	mo := setupLibhoney(t)
+
+	out, err := CommandContext(context.Background(), "sh", "-c", "printf bad >&2; exit 3").Output()
+	assert.Empty(t, out)
+	if ee, ok := err.(*exec.ExitError); assert.True(t, ok, "exit errors should be returned") {
+		assert.Equal(t, "bad", string(ee.Stderr), "stderr should be kept like exec does")
+	}
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, 3, evs[0].Data["exec.exit_code"])
+		assert.Equal(t, err.Error(), evs[0].Data["exec.error"])
+	}
+}
+
+func TestCombinedOutput(t *testing.T) {
+	requireSh(t)
+	mo := setupLibhoney(t)
+
+	out, err := CommandContext(context.Background(), "sh", "-c", "printf a; printf b >&2").CombinedOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(out))
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, int64(2), evs[0].Data["exec.output_bytes"])
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	mo := setupLibhoney(t)
+
+	err := CommandContext(context.Background(), "/does/not/exist").Run()
+	assert.Error(t, err)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs), "a command that fails to start should still send its span") {
+		assert.Equal(t, err.Error(), evs[0].Data["exec.error"])
+		assert.NotContains(t, evs[0].Data, "exec.exit_code")
+	}
+}
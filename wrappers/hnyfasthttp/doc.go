@@ -0,0 +1,30 @@
+/*
+Package hnyfasthttp provides Honeycomb wrappers for fasthttp RequestHandlers.
+
+Summary
+
+WrapHandler wraps a fasthttp.RequestHandler so that each request it serves
+gets a span with the same request.* fields as the net/http wrappers, along
+with the response's status, content type and size. Trace headers sent by the
+client are used to continue its trace, as they are by hnynethttp.
+
+fasthttp avoids allocating for each request, so rather than converting the
+whole request to a net/http one, the wrapper copies just the parts of it the
+beeline reads: the method, URL, host, remote address and the headers it
+records or continues traces from. Request bodies aren't recorded.
+beeline.Config.IgnoreHTTPRequest and TenantFunc are passed a request holding
+those parts.
+
+	server := &fasthttp.Server{Handler: hnyfasthttp.WrapHandler(handler)}
+
+The RequestCtx is reused once the handler returns, so the span is not kept in
+it as a context. Use ContextFromRequestCtx to get a context holding the span,
+to pass to the code the handler calls:
+
+	func handler(ctx *fasthttp.RequestCtx) {
+		users, err := loadUsers(hnyfasthttp.ContextFromRequestCtx(ctx))
+		...
+	}
+
+*/
+package hnyfasthttp
@@ -0,0 +1,207 @@
+package hnyfasthttp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+
+	"github.com/valyala/fasthttp"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Option configures a handler wrapped by WrapHandler.
+type Option = common.HandlerOption
+
+// WithRouteName names the handler's spans name instead of after the handler,
+// and records it as handler.route.
+func WithRouteName(name string) Option {
+	return common.WithRouteName(name)
+}
+
+// WithSampleRate samples the traces of requests to the handler at rate,
+// instead of at the rate the beeline was configured with.
+func WithSampleRate(rate uint) Option {
+	return common.WithSampleRate(rate)
+}
+
+// WithFields adds fields to the span for every request to the handler.
+func WithFields(fields map[string]interface{}) Option {
+	return common.WithFields(fields)
+}
+
+// WithBeeline records requests to the handler, and sends their traces, with
+// b's client and config instead of those of the beeline set up with Init.
+func WithBeeline(b *beeline.Beeline) Option {
+	return func(c *common.HandlerConfig) {
+		common.WithClient(b.Client())(c)
+		common.WithTraceConfig(b.TraceConfig())(c)
+	}
+}
+
+// contextKey is the user value the span's context is kept in on the
+// RequestCtx.
+type contextKey struct{}
+
+// ContextFromRequestCtx returns a context holding the span of the request
+// ctx is serving, or context.Background() if the request isn't traced. It
+// must not be kept once the handler returns.
+func ContextFromRequestCtx(ctx *fasthttp.RequestCtx) context.Context {
+	if c, ok := ctx.UserValue(contextKey{}).(context.Context); ok {
+		return c
+	}
+	return context.Background()
+}
+
+// readHeaders are the request headers the beeline reads, to continue traces
+// and to record the client and the proxies in between.
+var readHeaders = []string{
+	propagation.TracePropagationHTTPHeader,
+	propagation.AmazonTracePropagationHTTPHeader,
+	propagation.W3CTraceParentHTTPHeader,
+	propagation.W3CTraceStateHTTPHeader,
+	propagation.B3SingleHTTPHeader,
+	propagation.B3TraceIDHTTPHeader,
+	propagation.B3SpanIDHTTPHeader,
+	propagation.B3SampledHTTPHeader,
+	propagation.B3FlagsHTTPHeader,
+	"User-Agent",
+	"Content-Type",
+	"Content-Encoding",
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Proto",
+	"X-Real-Ip",
+}
+
+// request returns the parts of the request ctx is serving that the beeline
+// reads as a net/http request, without its body or the headers it doesn't
+// read. config says which other headers are recorded.
+func request(ctx *fasthttp.RequestCtx, config *trace.Config) *http.Request {
+	uri := ctx.URI()
+	r := &http.Request{
+		Method: string(ctx.Method()),
+		URL: &url.URL{
+			Path:     string(uri.Path()),
+			RawQuery: string(uri.QueryString()),
+		},
+		Proto:         string(ctx.Request.Header.Protocol()),
+		Host:          string(ctx.Host()),
+		ContentLength: int64(ctx.Request.Header.ContentLength()),
+		RemoteAddr:    ctx.RemoteAddr().String(),
+	}
+	r.ProtoMajor, r.ProtoMinor, _ = http.ParseHTTPVersion(r.Proto)
+	for _, name := range readHeaders {
+		copyHeader(r, ctx, name)
+	}
+	for _, name := range config.HTTPHeadersToCapture {
+		copyHeader(r, ctx, name)
+	}
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	return r
+}
+
+// copyHeader copies the values of the header called name in ctx's request
+// to r, making r's headers when the first one is found.
+func copyHeader(r *http.Request, ctx *fasthttp.RequestCtx, name string) {
+	values := ctx.Request.Header.PeekAll(name)
+	if len(values) == 0 {
+		return
+	}
+	if r.Header == nil {
+		r.Header = make(http.Header, len(readHeaders))
+	}
+	key := http.CanonicalHeaderKey(name)
+	if len(r.Header[key]) > 0 {
+		return
+	}
+	for _, v := range values {
+		r.Header[key] = append(r.Header[key], string(v))
+	}
+}
+
+// setResponseHeaders adds the headers the beeline is configured to return to
+// the client to the response, as common.SetResponseHeaders does for net/http.
+func setResponseHeaders(ctx *fasthttp.RequestCtx, span *trace.Span) {
+	tr := span.GetTrace()
+	if tr == nil {
+		return
+	}
+	config := tr.GetConfig()
+	if name := config.TraceIDResponseHeader; name != "" {
+		ctx.Response.Header.Set(name, tr.GetTraceID())
+	}
+	if config.EchoAmazonTraceHeader {
+		if header := ctx.Request.Header.Peek(propagation.AmazonTracePropagationHTTPHeader); len(header) > 0 {
+			ctx.Response.Header.SetBytesV(propagation.AmazonTracePropagationHTTPHeader, header)
+		}
+	}
+}
+
+// WrapHandler returns a handler that creates a Honeycomb span for each
+// request handler serves, with all the standard HTTP fields attached.
+// Options configure this handler independently of any others that are
+// wrapped.
+func WrapHandler(handler fasthttp.RequestHandler, opts ...Option) fasthttp.RequestHandler {
+	handlerName := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	handlerPkg := common.FuncPackage(handlerName)
+	config := common.NewHandlerConfig(opts)
+
+	return func(ctx *fasthttp.RequestCtx) {
+		traceConfig := config.TraceConfig
+		if traceConfig == nil {
+			traceConfig = &trace.GlobalConfig
+		}
+		r := config.PrepareRequest(request(ctx, traceConfig))
+		if common.IgnoreRequest(r) {
+			handler(ctx)
+			return
+		}
+		spanCtx, span := common.StartSpanOrTraceFromHTTP(r)
+		defer span.Send()
+		setResponseHeaders(ctx, span)
+		defer func() {
+			if p := recover(); p != nil {
+				status := ctx.Response.StatusCode()
+				if status == fasthttp.StatusOK {
+					status = fasthttp.StatusInternalServerError
+				}
+				span.AddField("response.status_code", status)
+				panic(p)
+			}
+		}()
+
+		if handlerName != "" {
+			span.AddField("handler.name", handlerName)
+			span.AddField("name", handlerName)
+		} else {
+			span.AddField("name", "handler")
+		}
+		if handlerPkg != "" {
+			span.AddField("handler.pkg", handlerPkg)
+		}
+		config.Apply(spanCtx, span)
+
+		ctx.SetUserValue(contextKey{}, spanCtx)
+		handler(ctx)
+		ctx.RemoveUserValue(contextKey{})
+
+		span.AddField("response.status_code", ctx.Response.StatusCode())
+		if ct := ctx.Response.Header.ContentType(); len(ct) > 0 {
+			span.AddField("response.content_type", string(ct))
+		}
+		if ce := ctx.Response.Header.Peek("Content-Encoding"); len(ce) > 0 {
+			span.AddField("response.content_encoding", string(ce))
+		}
+		if !ctx.Response.IsBodyStream() {
+			span.AddField("response.bytes_written", len(ctx.Response.Body()))
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package hnyfasthttp
+
+import (
+	"net"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/beelinetest"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func newRequestCtx(method, uri string) *fasthttp.RequestCtx {
+	var req fasthttp.Request
+	req.Header.SetMethod(method)
+	req.SetRequestURI(uri)
+	req.Header.Set("User-Agent", "test")
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&req, &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4321}, nil)
+	return ctx
+}
+
+func hello(ctx *fasthttp.RequestCtx) {
+	if trace.GetSpanFromContext(ContextFromRequestCtx(ctx)) == nil {
+		ctx.SetStatusCode(fasthttp.StatusTeapot)
+		return
+	}
+	ctx.SetContentType("text/plain")
+	ctx.WriteString("hello")
+}
+
+func TestWrapHandler(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+
+	ctx := newRequestCtx("GET", "http://example.com/hello/pooh?honey=yes")
+	WrapHandler(hello)(ctx)
+
+	evs := rec.Events()
+	assert.Equal(t, 1, len(evs), "one event is created with one request through the wrapper")
+	fields := evs[0]
+	assert.Equal(t, "http_request", fields["meta.type"])
+	assert.Equal(t, "GET", fields["request.method"])
+	assert.Equal(t, "/hello/pooh", fields["request.path"])
+	assert.Equal(t, "honey=yes", fields["request.query"])
+	assert.Equal(t, "/hello/pooh?honey=yes", fields["request.url"])
+	assert.Equal(t, "example.com", fields["request.host"])
+	assert.Equal(t, "HTTP/1.1", fields["request.http_version"])
+	assert.Equal(t, int64(0), fields["request.content_length"])
+	assert.Equal(t, "10.0.0.1:4321", fields["request.remote_addr"])
+	assert.Equal(t, "10.0.0.1", fields["request.client_ip"])
+	assert.Equal(t, "test", fields["request.header.user_agent"])
+	assert.Equal(t, 200, fields["response.status_code"])
+	assert.Equal(t, "text/plain", fields["response.content_type"])
+	assert.Equal(t, 5, fields["response.bytes_written"])
+	assert.Equal(t, "github.com/honeycombio/beeline-go/wrappers/hnyfasthttp.hello", fields["name"])
+	assert.Equal(t, "github.com/honeycombio/beeline-go/wrappers/hnyfasthttp", fields["handler.pkg"])
+	assert.Nil(t, ctx.UserValue(contextKey{}), "the span's context is not left on the RequestCtx")
+}
+
+func TestWrapHandlerContinuesTrace(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+
+	ctx := newRequestCtx("GET", "http://example.com/")
+	ctx.Request.Header.Set("X-Honeycomb-Trace", "1;trace_id=abcdef,parent_id=123456")
+	ctx.Request.Header.Set("X-Forwarded-For", "192.0.2.7")
+	WrapHandler(hello, WithRouteName("root"))(ctx)
+
+	evs := rec.Events()
+	assert.Equal(t, 1, len(evs))
+	fields := evs[0]
+	assert.Equal(t, "abcdef", fields["trace.trace_id"])
+	assert.Equal(t, "123456", fields["trace.parent_id"])
+	assert.Equal(t, "root", fields["name"])
+	assert.Equal(t, "192.0.2.7", fields["request.client_ip"])
+	assert.Equal(t, "192.0.2.7", fields["request.header.x_forwarded_for"])
+}
+
+func TestWrapHandlerRecordsPanic(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+
+	ctx := newRequestCtx("POST", "http://example.com/boom")
+	assert.Panics(t, func() {
+		WrapHandler(func(*fasthttp.RequestCtx) { panic("boom") })(ctx)
+	})
+
+	evs := rec.Events()
+	assert.Equal(t, 1, len(evs))
+	assert.Equal(t, 500, evs[0]["response.status_code"])
+}
+
+func BenchmarkWrapHandler(b *testing.B) {
+	beelinetest.Init(beeline.Config{})
+	handler := WrapHandler(hello)
+	ctx := newRequestCtx("GET", "http://example.com/hello/pooh?honey=yes")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		handler(ctx)
+	}
+}
@@ -0,0 +1,35 @@
+module github.com/honeycombio/beeline-go/wrappers/hnyfasthttp
+
+go 1.20
+
+require (
+	github.com/honeycombio/beeline-go v0.5.1
+	github.com/stretchr/testify v1.8.1
+	github.com/valyala/fasthttp v1.51.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.5 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/facebookgo/clock v0.0.0-20150410010913-600d898af40a // indirect
+	github.com/facebookgo/limitgroup v0.0.0-20150612190941-6abd8d71ec01 // indirect
+	github.com/facebookgo/muster v0.0.0-20150708232844-fd3d7953fd52 // indirect
+	github.com/felixge/httpsnoop v1.0.1 // indirect
+	github.com/golang/protobuf v1.4.2 // indirect
+	github.com/honeycombio/dynsampler-go v0.2.1 // indirect
+	github.com/honeycombio/libhoney-go v1.12.4 // indirect
+	github.com/klauspost/compress v1.17.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/vmihailenco/msgpack/v4 v4.3.11 // indirect
+	github.com/vmihailenco/tagparser v0.1.1 // indirect
+	go.opentelemetry.io/otel v0.6.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	google.golang.org/appengine v1.6.6 // indirect
+	google.golang.org/grpc v1.30.0 // indirect
+	google.golang.org/protobuf v1.25.0 // indirect
+	gopkg.in/alexcesaro/statsd.v2 v2.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/honeycombio/beeline-go => ../..
@@ -0,0 +1,21 @@
+// Package hnyflags records the feature flags evaluated during a request, so
+// that behavior can be broken down by flag state.
+//
+// Each flag evaluated is added to the trace as an app.flags.<flag> field
+// holding the value it evaluated to, so every span in the trace, including
+// those in downstream services, can be filtered by it.
+//
+// Call hnyflags.Record after evaluating a flag with any provider, or evaluate
+// it through one of the typed helpers, which record the result for you. For
+// example, with the LaunchDarkly SDK:
+//
+//	enabled, err := hnyflags.Bool(r.Context(), "new-checkout", func() (bool, error) {
+//		return ldClient.BoolVariation("new-checkout", user, false)
+//	})
+//
+// The value is recorded even when evaluation fails, since that is the value
+// the request goes on to use.
+//
+// For LaunchDarkly, the hook in the hnyld module records every flag the
+// client evaluates with a context, without wrapping each call.
+package hnyflags
@@ -0,0 +1,41 @@
+package hnyflags
+
+import (
+	"context"
+
+	beeline "github.com/honeycombio/beeline-go"
+)
+
+// Record adds the value a flag evaluated to to the trace in ctx as
+// app.flags.<flag>.
+func Record(ctx context.Context, flag string, value interface{}) {
+	beeline.AddFieldToTrace(ctx, "flags."+flag, value)
+}
+
+// Bool evaluates a boolean flag with eval and records the result.
+func Bool(ctx context.Context, flag string, eval func() (bool, error)) (bool, error) {
+	v, err := eval()
+	Record(ctx, flag, v)
+	return v, err
+}
+
+// String evaluates a string flag with eval and records the result.
+func String(ctx context.Context, flag string, eval func() (string, error)) (string, error) {
+	v, err := eval()
+	Record(ctx, flag, v)
+	return v, err
+}
+
+// Int evaluates an integer flag with eval and records the result.
+func Int(ctx context.Context, flag string, eval func() (int, error)) (int, error) {
+	v, err := eval()
+	Record(ctx, flag, v)
+	return v, err
+}
+
+// Float64 evaluates a numeric flag with eval and records the result.
+func Float64(ctx context.Context, flag string, eval func() (float64, error)) (float64, error) {
+	v, err := eval()
+	Record(ctx, flag, v)
+	return v, err
+}
@@ -0,0 +1,61 @@
+package hnyflags
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func TestFlags(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	ctx, child := beeline.StartSpan(ctx, "work")
+
+	on, err := Bool(ctx, "new-checkout", func() (bool, error) { return true, nil })
+	assert.True(t, on)
+	assert.NoError(t, err)
+	broken := errors.New("flag store unavailable")
+	limit, err := Int(ctx, "rate-limit", func() (int, error) { return 10, broken })
+	assert.Equal(t, 10, limit)
+	assert.Equal(t, broken, err)
+	String(ctx, "theme", func() (string, error) { return "dark", nil })
+	Float64(ctx, "ratio", func() (float64, error) { return 0.5, nil })
+	Record(ctx, "plan", "pro")
+	child.Send()
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		for _, ev := range evs {
+			assert.Equal(t, true, ev.Data["app.flags.new-checkout"])
+			assert.Equal(t, 10, ev.Data["app.flags.rate-limit"], "the default should be recorded when evaluation fails")
+			assert.Equal(t, "dark", ev.Data["app.flags.theme"])
+			assert.Equal(t, 0.5, ev.Data["app.flags.ratio"])
+			assert.Equal(t, "pro", ev.Data["app.flags.plan"])
+		}
+	}
+}
+
+func TestFlagsWithoutTrace(t *testing.T) {
+	setupLibhoney(t)
+	on, err := Bool(context.Background(), "new-checkout", func() (bool, error) { return true, nil })
+	assert.True(t, on)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,21 @@
+// Package hnyld records the feature flags a LaunchDarkly client evaluates on
+// the trace of the request evaluating them, as hnyflags does for other
+// providers.
+//
+// Add the hook to the client's config, and evaluate flags with the Ctx
+// variation methods, passing a context holding the request's span:
+//
+//	ldClient, err := ld.MakeCustomClient(sdkKey, ld.Config{
+//		Hooks: []ldhooks.Hook{hnyld.NewHook()},
+//	}, 5*time.Second)
+//	...
+//	enabled, err := ldClient.BoolVariationCtx(r.Context(), "new-checkout", user, false)
+//
+// Each flag is added to the trace as app.flags.<flag>, holding the value it
+// evaluated to, including the default when evaluation fails. Flags evaluated
+// with the variation methods that don't take a context have no trace to be
+// added to.
+//
+// hnyld is a module of its own, as the LaunchDarkly SDK needs Go 1.20 or
+// newer.
+package hnyld
@@ -0,0 +1,34 @@
+package hnyld
+
+import (
+	"context"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-server-sdk/v7/ldhooks"
+
+	"github.com/honeycombio/beeline-go/wrappers/hnyflags"
+)
+
+// Hook is a LaunchDarkly evaluation hook that records each flag evaluated on
+// the trace in the evaluation's context.
+type Hook struct {
+	ldhooks.Unimplemented
+}
+
+// NewHook returns a hook to add to ld.Config.Hooks.
+func NewHook() Hook {
+	return Hook{}
+}
+
+// Metadata names the hook for the LaunchDarkly SDK.
+func (Hook) Metadata() ldhooks.Metadata {
+	return ldhooks.NewMetadata("honeycomb-beeline")
+}
+
+// AfterEvaluation is called by the LaunchDarkly SDK once a flag has been
+// evaluated, and records the value it evaluated to.
+func (Hook) AfterEvaluation(ctx context.Context, series ldhooks.EvaluationSeriesContext,
+	data ldhooks.EvaluationSeriesData, detail ldreason.EvaluationDetail) (ldhooks.EvaluationSeriesData, error) {
+	hnyflags.Record(ctx, series.FlagKey(), detail.Value.AsArbitraryValue())
+	return data, nil
+}
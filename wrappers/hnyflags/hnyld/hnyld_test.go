@@ -0,0 +1,50 @@
+package hnyld
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	ld "github.com/launchdarkly/go-server-sdk/v7"
+	"github.com/launchdarkly/go-server-sdk/v7/ldcomponents"
+	"github.com/launchdarkly/go-server-sdk/v7/ldhooks"
+	"github.com/launchdarkly/go-server-sdk/v7/testhelpers/ldtestdata"
+	"github.com/stretchr/testify/assert"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/beelinetest"
+)
+
+func TestHook(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	td := ldtestdata.DataSource()
+	td.Update(td.Flag("new-checkout").VariationForAll(true))
+	td.Update(td.Flag("theme").ValueForAll(ldvalue.String("dark")))
+	client, err := ld.MakeCustomClient("sdk-key", ld.Config{
+		DataSource: td,
+		Events:     ldcomponents.NoEvents(),
+		Hooks:      []ldhooks.Hook{NewHook()},
+	}, time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close()
+
+	user := ldcontext.New("user-1")
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	on, err := client.BoolVariationCtx(ctx, "new-checkout", user, false)
+	assert.NoError(t, err)
+	assert.True(t, on)
+	client.StringVariationCtx(ctx, "theme", user, "light")
+	client.IntVariationCtx(ctx, "missing", user, 7)
+	span.Send()
+
+	spans := rec.SpansNamed("request")
+	if assert.Equal(t, 1, len(spans)) {
+		assert.Equal(t, true, spans[0]["app.flags.new-checkout"])
+		assert.Equal(t, "dark", spans[0]["app.flags.theme"])
+		assert.Equal(t, 7.0, spans[0]["app.flags.missing"], "the default should be recorded when evaluation fails")
+	}
+}
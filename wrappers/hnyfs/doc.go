@@ -0,0 +1,15 @@
+// Package hnyfs emits spans for file system access, which helps when files
+// live on slow network storage such as NFS or EFS. It requires Go 1.16.
+//
+// WrapFS wraps an fs.FS so that Open, Stat and ReadFile on it are timed in
+// spans that are children of the span in the given context. Files opened
+// through it send one more span when they are closed, covering every Read.
+//
+//	fsys := hnyfs.WrapFS(r.Context(), os.DirFS("/mnt/efs/assets"))
+//	data, err := fs.ReadFile(fsys, "logo.png")
+//
+// Open, ReadFile and Stat do the same for paths on the host file system.
+//
+// The time spent and bytes read are also summed into `rollup.fs.duration_ms`,
+// `rollup.fs.call_count` and `rollup.fs.read_bytes` on the root span.
+package hnyfs
@@ -0,0 +1,143 @@
+//go:build go1.16
+// +build go1.16
+
+package hnyfs
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// PathPrefix, if set, is removed from the start of every path before it is
+// recorded, so spans don't reveal where file systems are mounted.
+var PathPrefix string
+
+// WrapFS returns an fs.FS that emits a span for each operation on fsys. The
+// spans are children of the span in ctx, so wrap the file system again for
+// each request.
+func WrapFS(ctx context.Context, fsys fs.FS) fs.FS {
+	return &wrappedFS{ctx: ctx, fsys: fsys}
+}
+
+type wrappedFS struct {
+	ctx  context.Context
+	fsys fs.FS
+}
+
+func (w *wrappedFS) Open(name string) (fs.File, error) {
+	op := start(w.ctx, "open", name)
+	f, err := w.fsys.Open(name)
+	op.finish(err)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, ctx: w.ctx, name: name}, nil
+}
+
+func (w *wrappedFS) Stat(name string) (fs.FileInfo, error) {
+	op := start(w.ctx, "stat", name)
+	info, err := fs.Stat(w.fsys, name)
+	op.finish(err)
+	return info, err
+}
+
+func (w *wrappedFS) ReadFile(name string) ([]byte, error) {
+	op := start(w.ctx, "read_file", name)
+	data, err := fs.ReadFile(w.fsys, name)
+	op.span.AddRollupField("fs.read_bytes", float64(len(data)))
+	op.finish(err)
+	return data, err
+}
+
+// Open opens the named file for reading, like os.Open, in a span.
+func Open(ctx context.Context, name string) (*os.File, error) {
+	op := start(ctx, "open", name)
+	f, err := os.Open(name)
+	op.finish(err)
+	return f, err
+}
+
+// ReadFile reads the named file, like os.ReadFile, in a span.
+func ReadFile(ctx context.Context, name string) ([]byte, error) {
+	op := start(ctx, "read_file", name)
+	data, err := os.ReadFile(name)
+	op.span.AddRollupField("fs.read_bytes", float64(len(data)))
+	op.finish(err)
+	return data, err
+}
+
+// Stat describes the named file, like os.Stat, in a span.
+func Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	op := start(ctx, "stat", name)
+	info, err := os.Stat(name)
+	op.finish(err)
+	return info, err
+}
+
+// file is an fs.File that totals up its reads and sends them as a span when
+// it is closed.
+type file struct {
+	fs.File
+	ctx     context.Context
+	name    string
+	reads   int
+	bytes   int64
+	readDur time.Duration
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Read(p)
+	f.readDur += time.Since(start)
+	f.reads++
+	f.bytes += int64(n)
+	return n, err
+}
+
+func (f *file) Close() error {
+	err := f.File.Close()
+	if f.reads > 0 {
+		_, span := common.StartChildSpan(f.ctx)
+		addOpFields(span, "read", f.name)
+		span.AddField("fs.read_count", f.reads)
+		span.AddField("fs.read_duration_ms", float64(f.readDur)/float64(time.Millisecond))
+		span.AddRollupField("fs.read_bytes", float64(f.bytes))
+		span.AddRollupField("fs.duration_ms", float64(f.readDur)/float64(time.Millisecond))
+		span.AddRollupField("fs.call_count", 1)
+		span.Send()
+	}
+	return err
+}
+
+type op struct {
+	span  *trace.Span
+	start time.Time
+}
+
+func start(ctx context.Context, operation, name string) *op {
+	_, span := common.StartChildSpan(ctx)
+	addOpFields(span, operation, name)
+	return &op{span: span, start: time.Now()}
+}
+
+func (o *op) finish(err error) {
+	if err != nil {
+		o.span.AddField("fs.error", err.Error())
+	}
+	o.span.AddRollupField("fs.duration_ms", float64(time.Since(o.start))/float64(time.Millisecond))
+	o.span.AddRollupField("fs.call_count", 1)
+	o.span.Send()
+}
+
+func addOpFields(span *trace.Span, operation, name string) {
+	span.AddField("meta.type", "fs")
+	span.AddField("name", "fs."+operation)
+	span.AddField("fs.operation", operation)
+	span.AddField("fs.path", strings.TrimPrefix(name, PathPrefix))
+}
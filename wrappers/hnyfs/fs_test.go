@@ -0,0 +1,103 @@
+//go:build go1.16
+// +build go1.16
+
+package hnyfs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+var testFS = fstest.MapFS{
+	"assets/logo.png": &fstest.MapFile{Data: []byte("0123456789")},
+}
+
+func TestWrapFS(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	fsys := WrapFS(ctx, testFS)
+
+	data, err := fs.ReadFile(fsys, "assets/logo.png")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, len(data))
+
+	f, err := fsys.Open("assets/logo.png")
+	if assert.NoError(t, err) {
+		_, err = io.Copy(io.Discard, f)
+		assert.NoError(t, err)
+		f.Close()
+	}
+
+	_, err = fs.Stat(fsys, "missing")
+	assert.Error(t, err)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 5, len(evs)) {
+		assert.Equal(t, "fs.read_file", evs[0].Data["name"])
+		assert.Equal(t, float64(10), evs[0].Data["fs.read_bytes"])
+		assert.Equal(t, "fs.open", evs[1].Data["name"])
+		assert.Equal(t, "fs.read", evs[2].Data["name"])
+		assert.Equal(t, float64(10), evs[2].Data["fs.read_bytes"])
+		assert.Equal(t, "fs.stat", evs[3].Data["name"])
+		assert.Equal(t, "missing", evs[3].Data["fs.path"])
+		assert.Contains(t, evs[3].Data, "fs.error")
+
+		root := evs[4].Data
+		assert.Equal(t, float64(20), root["rollup.fs.read_bytes"])
+		assert.Equal(t, float64(4), root["rollup.fs.call_count"])
+		for _, ev := range evs[:4] {
+			assert.Equal(t, root["trace.span_id"], ev.Data["trace.parent_id"])
+		}
+	}
+}
+
+func TestHostHelpers(t *testing.T) {
+	mo := setupLibhoney(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("hello"), 0600))
+
+	PathPrefix = dir
+	defer func() { PathPrefix = "" }()
+
+	ctx := context.Background()
+	data, err := ReadFile(ctx, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	_, err = Stat(ctx, path)
+	assert.NoError(t, err)
+	f, err := Open(ctx, path)
+	if assert.NoError(t, err) {
+		f.Close()
+	}
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		for _, ev := range evs {
+			assert.Equal(t, string(filepath.Separator)+"data.txt", ev.Data["fs.path"], "the path prefix should be removed")
+		}
+	}
+}
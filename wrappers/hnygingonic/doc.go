@@ -3,6 +3,9 @@
 // Summary
 //
 // hnygingonic has Middleware for use in the gin.Use function call wrapping all
-// requests that come into the gin muxer
+// requests that come into the gin muxer. Along with the request and response
+// fields common to all the HTTP wrappers, the event records the route that
+// matched, from gin.Context.FullPath, and any errors handlers attached with
+// gin.Context.Error: the last as handler.error and all of them as gin.errors.
 //
 package hnygingonic
@@ -15,9 +15,14 @@ const ginContextKey = "beeline-middleware-context"
 // parameters, it can add those values to the event it generates.
 func Middleware(queryParams map[string]struct{}) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if common.IgnoreRequest(c.Request) {
+			c.Next()
+			return
+		}
 		// get a new context with our trace from the request, and add common fields
 		ctx, span := common.StartSpanOrTraceFromHTTP(c.Request)
 		defer span.Send()
+		common.SetResponseHeaders(c.Writer.Header(), c.Request, span)
 		// Add the span context to the gin context as we need to be able to pass
 		// this context around our gin application
 		c.Set(ginContextKey, ctx)
@@ -47,9 +52,25 @@ func Middleware(queryParams map[string]struct{}) gin.HandlerFunc {
 		name := c.HandlerName()
 		span.AddField("handler.name", name)
 		span.AddField("name", name)
+		// gin names handlers after the function, so it gives the package too
+		if pkg := common.FuncPackage(name); pkg != "" {
+			span.AddField("handler.pkg", pkg)
+		}
+		if route := c.FullPath(); route != "" {
+			span.AddField("handler.route", route)
+		}
 		// Run the next function in the Middleware chain
 		c.Next()
 		span.AddField("response.status_code", c.Writer.Status())
+		// Size is -1 until something is written
+		if size := c.Writer.Size(); size >= 0 {
+			span.AddField("response.size", size)
+		}
+		if len(c.Errors) > 0 {
+			span.AddField("handler.error", c.Errors.Last().Error())
+			span.AddField("gin.errors", c.Errors.Errors())
+		}
+		common.AddContextFields(ctx, span)
 	}
 }
 
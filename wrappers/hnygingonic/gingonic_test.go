@@ -1,6 +1,7 @@
 package hnygingonic
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -43,6 +44,8 @@ func TestHTTPRouterMiddleware(t *testing.T) {
 	name, ok := fields["handler.vars.name"]
 	assert.True(t, ok, "handler.vars.name field must exist on middleware generated event")
 	assert.Equal(t, "pooh", name, "successfully served request should have name var populated")
+	assert.Equal(t, "/hello/:name", fields["handler.route"])
+	assert.NotContains(t, fields, "response.size", "nothing was written")
 }
 
 func TestHTTPRouterMiddlewareReturnsStatusCode(t *testing.T) {
@@ -74,3 +77,35 @@ func TestHTTPRouterMiddlewareReturnsStatusCode(t *testing.T) {
 	assert.True(t, ok, "'status_code' field must exist on middleware generated event")
 	assert.Equal(t, http.StatusNotFound, status)
 }
+
+func TestHTTPRouterMiddlewareRecordsErrors(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	r, _ := http.NewRequest("POST", "/users", nil)
+	w := httptest.NewRecorder()
+
+	router := gin.New()
+	router.Use(Middleware(nil))
+	router.POST("/users", func(c *gin.Context) {
+		c.Error(errors.New("bad name"))
+		c.Error(errors.New("bad email"))
+		c.String(http.StatusBadRequest, "invalid")
+	})
+	router.ServeHTTP(w, r)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, http.StatusBadRequest, fields["response.status_code"])
+		assert.Equal(t, 7, fields["response.size"])
+		assert.Equal(t, "bad email", fields["handler.error"])
+		assert.Equal(t, []string{"bad name", "bad email"}, fields["gin.errors"])
+	}
+}
@@ -15,19 +15,26 @@ import (
 // inserting middleware
 func Middleware(handler http.Handler) http.Handler {
 	wrappedHandler := func(w http.ResponseWriter, r *http.Request) {
+		if common.IgnoreRequest(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
 		// get a new context with our trace from the request, and add common fields
 		ctx, span := common.StartSpanOrTraceFromHTTP(r)
 		defer span.Send()
+		common.SetResponseHeaders(w.Header(), r, span)
 		// push the context with our trace and span on to the request
 		r = r.WithContext(ctx)
 
 		// replace the writer with our wrapper to catch the status code
 		wrappedWriter := common.NewResponseWriter(w)
+		defer wrappedWriter.RecordPanic(span)
 
 		// get bits about the handler
 		handler := middleware.Handler(ctx)
 		if handler == nil {
 			span.AddField("handler.name", "http.NotFound")
+			span.AddField("handler.pkg", "net/http")
 			handler = http.NotFoundHandler()
 		} else {
 			hType := reflect.TypeOf(handler)
@@ -35,30 +42,56 @@ func Middleware(handler http.Handler) http.Handler {
 			name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
 			span.AddField("handler.name", name)
 			span.AddField("name", name)
+			if pkg := common.HandlerPackage(handler); pkg != "" {
+				span.AddField("handler.pkg", pkg)
+			}
 		}
 		// find any matched patterns
 		pm := middleware.Pattern(ctx)
 		if pm != nil {
-			// TODO put a regex on `p.String()` to pull out any `:foo` and then
-			// use those instead of trying to pull them out of the pattern some
-			// other way
 			if p, ok := pm.(*pat.Pattern); ok {
 				span.AddField("goji.pat", p.String())
+				span.AddField("handler.route", p.String())
 				span.AddField("goji.methods", p.HTTPMethods())
 				span.AddField("goji.path_prefix", p.PathPrefix())
-				patvar := strings.TrimPrefix(p.String(), p.PathPrefix()+":")
-				span.AddField("goji.pat."+patvar, pat.Param(r, patvar))
+				for _, name := range patternVars(p.String()) {
+					span.AddField("goji.pat."+name, pat.Param(r, name))
+				}
 			} else {
 				span.AddField("pat", "NOT pat.Pattern")
 
 			}
 		}
-		// TODO get all the parameters and their values
 		handler.ServeHTTP(wrappedWriter.Wrapped, r)
 		if wrappedWriter.Status == 0 {
 			wrappedWriter.Status = 200
 		}
 		span.AddField("response.status_code", wrappedWriter.Status)
+		wrappedWriter.AddResponseFields(span)
+		wrappedWriter.AddAnomalyFields(span)
+		common.AddContextFields(ctx, span)
 	}
 	return http.HandlerFunc(wrappedHandler)
 }
+
+// patternVars returns the names of the variables in a pat pattern, eg
+// ["file", "ext"] for "/:file.:ext". A name runs from its ":" to the next
+// delimiter pat accepts.
+func patternVars(pattern string) []string {
+	var names []string
+	for {
+		i := strings.IndexByte(pattern, ':')
+		if i < 0 {
+			return names
+		}
+		pattern = pattern[i+1:]
+		end := strings.IndexAny(pattern, "/.;,")
+		if end < 0 {
+			end = len(pattern)
+		}
+		if end > 0 {
+			names = append(names, pattern[:end])
+		}
+		pattern = pattern[end:]
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/beelinetest"
 	libhoney "github.com/honeycombio/libhoney-go"
 	"github.com/honeycombio/libhoney-go/transmission"
 	"github.com/stretchr/testify/assert"
@@ -46,3 +47,28 @@ func TestGojiMiddleware(t *testing.T) {
 	assert.Equal(t, "pooh", name, "successfully served request should have name var populated")
 
 }
+
+func TestGojiMiddlewareMultipleVars(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+
+	router := goji.NewMux()
+	router.HandleFunc(pat.Get("/users/:id/files/:file.:ext"), func(_ http.ResponseWriter, _ *http.Request) {})
+	router.Use(Middleware)
+	r, _ := http.NewRequest("GET", "/users/42/files/data.tar.gz", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	evs := rec.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0]
+		assert.Equal(t, "/users/:id/files/:file.:ext", fields["handler.route"])
+		assert.Equal(t, "42", fields["goji.pat.id"])
+		assert.Equal(t, "data", fields["goji.pat.file"])
+		assert.Equal(t, "tar.gz", fields["goji.pat.ext"])
+	}
+}
+
+func TestPatternVars(t *testing.T) {
+	assert.Equal(t, []string(nil), patternVars("/hello"))
+	assert.Equal(t, []string{"name"}, patternVars("/hello/:name"))
+	assert.Equal(t, []string{"a", "b", "c"}, patternVars("/:a;:b,:c/*"))
+}
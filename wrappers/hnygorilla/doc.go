@@ -6,7 +6,11 @@
 // conjunction with the nethttp WrapHandler function. Using these two together
 // will get you an event for every request that comes through your application
 // while also decorating the most interesting paths (the handlers that you wrap)
-// with additional fields from the Gorilla patterns.
+// with additional fields from the Gorilla patterns. The matched route's name,
+// path, host and query templates and methods are added as gorilla.route_name,
+// gorilla.path_template, gorilla.host_template, gorilla.queries_template and
+// gorilla.methods, and the values matched by its variables as
+// gorilla.vars.<name>.
 //
 // For a complete example showing this wrapper in use, please see the examples in
 // https://github.com/honeycombio/beeline-go/tree/master/examples
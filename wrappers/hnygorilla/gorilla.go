@@ -1,34 +1,86 @@
 package hnygorilla
 
 import (
+	"context"
 	"net/http"
 	"reflect"
 	"runtime"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/honeycombio/beeline-go/trace"
 	"github.com/honeycombio/beeline-go/wrappers/common"
 )
 
+// instrumentedKey marks the context of a request that already has a span
+// from this package, so a router and its subrouters can all Use Middleware
+// without a request through both getting two spans.
+type instrumentedKey struct{}
+
 // Middleware is a gorilla middleware to add Honeycomb instrumentation to the
-// gorilla muxer.
+// gorilla muxer. Middlewares given to a router's Use also run for the routes
+// of its subrouters, so it only needs adding to the top router; see
+// InstrumentRouter to get events for requests that match no route too.
 func Middleware(handler http.Handler) http.Handler {
+	return instrument(handler, "")
+}
+
+// InstrumentRouter adds Middleware to router, and wraps its NotFoundHandler
+// and MethodNotAllowedHandler, or the defaults if they are unset, so that
+// requests matching none of its routes or its subrouters' routes also get an
+// event, with gorilla.match_error set to "not found" or "method not
+// allowed". Call it once the router's handlers are set; subrouters with
+// handlers of their own need instrumenting too.
+func InstrumentRouter(router *mux.Router) {
+	router.Use(Middleware)
+	notFound := router.NotFoundHandler
+	if notFound == nil {
+		notFound = http.NotFoundHandler()
+	}
+	router.NotFoundHandler = instrument(notFound, "not found")
+	methodNotAllowed := router.MethodNotAllowedHandler
+	if methodNotAllowed == nil {
+		methodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		})
+	}
+	router.MethodNotAllowedHandler = instrument(methodNotAllowed, "method not allowed")
+}
+
+// instrument wraps handler with a span for each request. matchError, if set,
+// is why the router didn't find a route for the request.
+func instrument(handler http.Handler, matchError string) http.Handler {
 	wrappedHandler := func(w http.ResponseWriter, r *http.Request) {
+		if common.IgnoreRequest(r) || r.Context().Value(instrumentedKey{}) != nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
 		// get a new context with our trace from the request, and add common fields
 		ctx, span := common.StartSpanOrTraceFromHTTP(r)
 		defer span.Send()
+		common.SetResponseHeaders(w.Header(), r, span)
 		// push the context with our trace and span on to the request
+		ctx = context.WithValue(ctx, instrumentedKey{}, true)
 		r = r.WithContext(ctx)
 
 		// replace the writer with our wrapper to catch the status code
 		wrappedWriter := common.NewResponseWriter(w)
+		defer wrappedWriter.RecordPanic(span)
 		// pull out any variables in the URL, add the thing we're matching, etc.
 		vars := mux.Vars(r)
 		for k, v := range vars {
 			span.AddField("gorilla.vars."+k, v)
 		}
+		if matchError != "" {
+			span.AddField("gorilla.match_error", matchError)
+			span.AddField("name", strings.Replace(matchError, " ", "_", -1))
+		}
 		route := mux.CurrentRoute(r)
 		if route != nil {
 			chosenHandler := route.GetHandler()
+			if pkg := common.HandlerPackage(chosenHandler); pkg != "" {
+				span.AddField("handler.pkg", pkg)
+			}
 			reflectHandler := reflect.ValueOf(chosenHandler)
 			if reflectHandler.Kind() == reflect.Func {
 				funcName := runtime.FuncForPC(reflectHandler.Pointer()).Name()
@@ -47,18 +99,40 @@ func Middleware(handler http.Handler) http.Handler {
 			name := route.GetName()
 			if name != "" {
 				span.AddField("handler.name", name)
+				span.AddField("gorilla.route_name", name)
 				// stomp name because user-supplied names are better than function names
 				span.AddField("name", name)
 			}
 			if path, err := route.GetPathTemplate(); err == nil {
 				span.AddField("handler.route", path)
+				span.AddField("gorilla.path_template", path)
 			}
+			addMatcherFields(span, route)
 		}
 		handler.ServeHTTP(wrappedWriter.Wrapped, r)
 		if wrappedWriter.Status == 0 {
 			wrappedWriter.Status = 200
 		}
 		span.AddField("response.status_code", wrappedWriter.Status)
+		wrappedWriter.AddResponseFields(span)
+		wrappedWriter.AddAnomalyFields(span)
+		common.AddContextFields(ctx, span)
 	}
 	return http.HandlerFunc(wrappedHandler)
 }
+
+// addMatcherFields records the templates of the host and query matchers of
+// the route that matched, and the methods it accepts. Like the path
+// template, they group requests far better than the raw values do; those
+// are in gorilla.vars already.
+func addMatcherFields(span *trace.Span, route *mux.Route) {
+	if host, err := route.GetHostTemplate(); err == nil {
+		span.AddField("gorilla.host_template", host)
+	}
+	if queries, err := route.GetQueriesTemplates(); err == nil {
+		span.AddField("gorilla.queries_template", strings.Join(queries, "&"))
+	}
+	if methods, err := route.GetMethods(); err == nil {
+		span.AddField("gorilla.methods", strings.Join(methods, ","))
+	}
+}
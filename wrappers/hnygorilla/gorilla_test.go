@@ -65,4 +65,70 @@ func TestGorillaMiddleware(t *testing.T) {
 		assert.Equal(t, 2, len(evs))
 		assert.Equal(t, "testHandler", evs[1].Data["name"])
 	})
+
+	t.Run("route templates", func(t *testing.T) {
+		router.HandleFunc("/users/{id}", func(_ http.ResponseWriter, _ *http.Request) {}).
+			Name("user").
+			Host("{tenant}.example.com").
+			Queries("page", "{page}").
+			Methods("GET", "HEAD")
+		r, _ := http.NewRequest("GET", "http://acme.example.com/users/42?page=3", nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+
+		evs := mo.Events()
+		if assert.Equal(t, 3, len(evs)) {
+			fields := evs[2].Data
+			assert.Equal(t, "user", fields["gorilla.route_name"])
+			assert.Equal(t, "/users/{id}", fields["gorilla.path_template"])
+			assert.Equal(t, "{tenant}.example.com", fields["gorilla.host_template"])
+			assert.Equal(t, "page={page}", fields["gorilla.queries_template"])
+			assert.Equal(t, "GET,HEAD", fields["gorilla.methods"])
+			assert.Equal(t, "acme", fields["gorilla.vars.tenant"])
+			assert.Equal(t, "3", fields["gorilla.vars.page"])
+		}
+		assert.NotContains(t, evs[0].Data, "gorilla.host_template", "routes without a host matcher have no host template")
+	})
+}
+
+func TestInstrumentRouter(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	// instrumenting the subrouter as well shouldn't double up spans
+	api.Use(Middleware)
+	api.HandleFunc("/items/{id}", func(_ http.ResponseWriter, _ *http.Request) {}).Methods("GET")
+	InstrumentRouter(router)
+
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/api/items/1"},
+		{"POST", "/api/items/1"},
+		{"GET", "/nope"},
+	} {
+		r, _ := http.NewRequest(req.method, req.path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		found, notAllowed, notFound := evs[0].Data, evs[1].Data, evs[2].Data
+		assert.Equal(t, "/api/items/{id}", found["handler.route"])
+		assert.Equal(t, "1", found["gorilla.vars.id"])
+		assert.NotContains(t, found, "gorilla.match_error")
+
+		assert.Equal(t, 405, notAllowed["response.status_code"])
+		assert.Equal(t, "method not allowed", notAllowed["gorilla.match_error"])
+		assert.Equal(t, "method_not_allowed", notAllowed["name"])
+
+		assert.Equal(t, 404, notFound["response.status_code"])
+		assert.Equal(t, "not found", notFound["gorilla.match_error"])
+		assert.Equal(t, "not_found", notFound["name"])
+	}
 }
@@ -17,3 +17,14 @@ func ExampleMiddleware() {
 	r.HandleFunc("/", root)
 	r.HandleFunc("/hello/{person}", hello)
 }
+
+func ExampleInstrumentRouter() {
+	// assume you have a handler named listItems
+	var listItems func(w http.ResponseWriter, r *http.Request)
+
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/items", listItems).Methods("GET")
+	// every route of r and api gets an event, and so do 404s and 405s
+	InstrumentRouter(r)
+}
@@ -0,0 +1,24 @@
+// Package hnygorm emits spans for the statements GORM runs, using GORM's
+// callbacks, so there is no need to wrap the *sql.DB underneath it.
+//
+// Register the plugin once the database is open, and pass GORM a context with
+// a span in it, so the statements are children of that span:
+//
+//	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+//	err = db.Use(hnygorm.NewPlugin())
+//	...
+//	err = db.WithContext(r.Context()).First(&user, id).Error
+//
+// Each create, query, update, delete, row and raw statement gets a span
+// named for the operation, eg gorm.query, recording the operation in
+// gorm.operation, the table in gorm.table, db.rows_affected and, for those
+// that fail, db.error. The SQL GORM generated and its arguments are recorded
+// in db.query and db.query_args, as Config.DBQueryMode and OmitDBQueryArgs
+// say. The time spent in the database and the number of statements are
+// rolled up onto the root span as rollup.db.duration_ms and
+// rollup.db.call_count.
+//
+// A query that finds nothing is not recorded as an error, as GORM's
+// ErrRecordNotFound is reported by First, Last and Take when there is no
+// matching row.
+package hnygorm
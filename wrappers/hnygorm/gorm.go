@@ -0,0 +1,101 @@
+package hnygorm
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// callKey is the key the call for a statement is kept under, between the
+// callbacks before and after it is run.
+const callKey = "hnygorm:call"
+
+// NewPlugin returns a plugin to register with db.Use.
+func NewPlugin() gorm.Plugin {
+	return &plugin{}
+}
+
+type plugin struct{}
+
+func (p *plugin) Name() string {
+	return "hnygorm"
+}
+
+// Initialize registers a callback either side of each statement GORM runs.
+func (p *plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("hnygorm:before_create", before("create")),
+		cb.Create().After("gorm:create").Register("hnygorm:after_create", after),
+		cb.Query().Before("gorm:query").Register("hnygorm:before_query", before("query")),
+		cb.Query().After("gorm:query").Register("hnygorm:after_query", after),
+		cb.Update().Before("gorm:update").Register("hnygorm:before_update", before("update")),
+		cb.Update().After("gorm:update").Register("hnygorm:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("hnygorm:before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("hnygorm:after_delete", after),
+		cb.Row().Before("gorm:row").Register("hnygorm:before_row", before("row")),
+		cb.Row().After("gorm:row").Register("hnygorm:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("hnygorm:before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("hnygorm:after_raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call times one statement.
+type call struct {
+	span  *trace.Span
+	start time.Time
+}
+
+// before returns the callback that starts the span for an operation.
+func before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+		_, span := common.StartChildSpan(db.Statement.Context)
+		span.AddField("meta.type", "sql")
+		span.AddField("name", "gorm."+operation)
+		span.AddField("db.call", operation)
+		span.AddField("gorm.operation", operation)
+		db.InstanceSet(callKey, &call{span: span, start: time.Now()})
+	}
+}
+
+// after sends the span for the statement that was run, once its SQL is known.
+func after(db *gorm.DB) {
+	v, ok := db.InstanceGet(callKey)
+	if !ok {
+		return
+	}
+	c, ok := v.(*call)
+	if !ok {
+		return
+	}
+	span := c.span
+	if table := db.Statement.Table; table != "" {
+		span.AddField("gorm.table", table)
+	}
+	var args []interface{}
+	if len(db.Statement.Vars) > 0 {
+		args = db.Statement.Vars
+	}
+	common.AddDBQueryFields(span, db.Statement.SQL.String(), args)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.AddField("db.error", db.Error.Error())
+	} else {
+		span.AddField("db.rows_affected", db.RowsAffected)
+	}
+	duration := float64(time.Since(c.start)) / float64(time.Millisecond)
+	span.AddRollupField("db.duration_ms", duration)
+	span.AddRollupField("db.call_count", 1)
+	span.Send()
+}
@@ -0,0 +1,87 @@
+package hnygorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/beelinetest"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type User struct {
+	ID   uint
+	Name string
+}
+
+func openDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(NewPlugin()))
+	return db, mock
+}
+
+func TestPlugin(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	db, mock := openDB(t)
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "ada"))
+	var user User
+	assert.NoError(t, db.WithContext(ctx).First(&user, 7).Error)
+
+	mock.ExpectExec(`UPDATE "users" SET "name"=\$1 WHERE "id" = \$2`).
+		WithArgs("grace", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, db.WithContext(ctx).Model(&user).Update("name", "grace").Error)
+
+	mock.ExpectQuery(`SELECT \* FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	assert.Equal(t, gorm.ErrRecordNotFound, db.WithContext(ctx).First(&User{}).Error)
+	root.Send()
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	evs := rec.Events()
+	if !assert.Equal(t, 4, len(evs)) {
+		return
+	}
+	query, update, missing, req := evs[0], evs[1], evs[2], evs[3]
+	assert.Equal(t, "gorm.query", query["name"])
+	assert.Equal(t, "sql", query["meta.type"])
+	assert.Equal(t, "query", query["gorm.operation"])
+	assert.Equal(t, "users", query["gorm.table"])
+	assert.Equal(t, `SELECT * FROM "users" WHERE "users"."id" = $1 ORDER BY "users"."id" LIMIT $2`, query["db.query"])
+	assert.Equal(t, []interface{}{7, 1}, query["db.query_args"])
+	assert.Equal(t, int64(1), query["db.rows_affected"])
+	assert.Equal(t, req["trace.span_id"], query["trace.parent_id"])
+
+	assert.Equal(t, "gorm.update", update["name"])
+	assert.Equal(t, "users", update["gorm.table"])
+	assert.Equal(t, int64(1), update["db.rows_affected"])
+
+	assert.NotContains(t, missing, "db.error", "finding nothing isn't an error")
+	assert.Equal(t, int64(0), missing["db.rows_affected"])
+
+	assert.Equal(t, 3.0, req["rollup.db.call_count"])
+}
+
+func TestPluginError(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	db, mock := openDB(t)
+
+	mock.ExpectExec(`DELETE FROM "users"`).WillReturnError(assert.AnError)
+	assert.Error(t, db.Delete(&User{}, 7).Error)
+
+	evs := rec.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "gorm.delete", evs[0]["name"])
+		assert.Equal(t, assert.AnError.Error(), evs[0]["db.error"])
+		assert.Equal(t, true, evs[0]["meta.orphaned"], "a statement run without a span starts a trace")
+	}
+}
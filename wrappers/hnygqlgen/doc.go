@@ -0,0 +1,23 @@
+// Package hnygqlgen emits spans for the GraphQL operations served with
+// gqlgen, and for the resolvers they run.
+//
+// Add the Tracer to the gqlgen server, and serve it with one of the HTTP
+// wrappers so the operations are children of the request's span:
+//
+//	srv := handler.NewDefaultServer(generated.NewExecutableSchema(cfg))
+//	srv.Use(hnygqlgen.Tracer{})
+//	http.Handle("/query", hnynethttp.WrapHandler(srv))
+//
+// Each operation's span is named for its type and name, eg "query GetUser",
+// and records graphql.operation_type, graphql.operation_name and the
+// document in graphql.query. Its variables are not recorded. The errors in
+// the response are recorded in graphql.errors. A subscription's span lasts
+// until the subscription ends, and counts the responses sent in
+// graphql.response_count.
+//
+// Each field that is resolved by a resolver or a method gets a child span,
+// named for the type and field, eg "Query.user", with its path in the
+// response in graphql.field_path. Errors returned by the resolver, or added
+// for the field with graphql.AddError, are recorded in graphql.error.
+// Fields read straight from a struct don't get spans.
+package hnygqlgen
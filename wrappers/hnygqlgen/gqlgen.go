@@ -0,0 +1,128 @@
+package hnygqlgen
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Tracer is a gqlgen handler extension that emits a span for each operation
+// and resolver.
+type Tracer struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.FieldInterceptor
+} = Tracer{}
+
+// ExtensionName names the extension to gqlgen.
+func (Tracer) ExtensionName() string {
+	return "HoneycombTracer"
+}
+
+// Validate accepts every schema.
+func (Tracer) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation runs the operation in a span that is a child of the
+// span in ctx, and sends it once the operation's last response is made.
+func (Tracer) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	ctx, span := common.StartChildSpan(ctx)
+	span.AddField("meta.type", "graphql")
+	opType, opName := operation(oc)
+	name := opType
+	if opName != "" {
+		name += " " + opName
+	}
+	span.AddField("name", name)
+	span.AddField("graphql.operation_type", opType)
+	if opName != "" {
+		span.AddField("graphql.operation_name", opName)
+	}
+	span.AddField("graphql.query", oc.RawQuery)
+
+	responses := next(ctx)
+	var errs []string
+	count := 0
+	finished := false
+	return func(ctx context.Context) *graphql.Response {
+		if finished {
+			return nil
+		}
+		resp := responses(ctx)
+		if resp != nil {
+			count++
+			for _, err := range resp.Errors {
+				errs = append(errs, err.Message)
+			}
+		}
+		// only subscriptions make more than one response, and they end with
+		// a nil one
+		if resp == nil || opType != string(ast.Subscription) {
+			finished = true
+			finishOperation(span, errs, count, opType)
+		}
+		return resp
+	}
+}
+
+func finishOperation(span *trace.Span, errs []string, count int, opType string) {
+	if len(errs) > 0 {
+		span.AddField("graphql.errors", errs)
+	}
+	if opType == string(ast.Subscription) {
+		span.AddField("graphql.response_count", count)
+	}
+	span.Send()
+}
+
+// operation returns the type and name of the operation being run.
+func operation(oc *graphql.OperationContext) (string, string) {
+	if oc.Operation == nil {
+		return "operation", oc.OperationName
+	}
+	name := oc.Operation.Name
+	if name == "" {
+		name = oc.OperationName
+	}
+	return string(oc.Operation.Operation), name
+}
+
+// InterceptField runs the resolvers for fields in a span that is a child of
+// the span in ctx.
+func (Tracer) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !(fc.IsResolver || fc.IsMethod) {
+		return next(ctx)
+	}
+	ctx, span := common.StartChildSpan(ctx)
+	defer span.Send()
+	span.AddField("meta.type", "graphql_field")
+	span.AddField("name", fc.Object+"."+fc.Field.Name)
+	span.AddField("graphql.parent_type", fc.Object)
+	span.AddField("graphql.field_name", fc.Field.Name)
+	span.AddField("graphql.field_path", fc.Path().String())
+	if def := fc.Field.Definition; def != nil && def.Type != nil {
+		span.AddField("graphql.return_type", def.Type.String())
+	}
+
+	res, err := next(ctx)
+	if err != nil {
+		span.AddField("graphql.error", err.Error())
+	} else if errs := graphql.GetFieldErrors(ctx, fc); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Message
+		}
+		span.AddField("graphql.error", strings.Join(msgs, "; "))
+	}
+	return res, err
+}
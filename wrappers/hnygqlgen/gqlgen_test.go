@@ -0,0 +1,153 @@
+package hnygqlgen
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/executor"
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+var schema = gqlparser.MustLoadSchema(&ast.Source{Input: `
+	type Query {
+		user: String!
+		broken: String
+		name: String!
+	}
+	type Subscription {
+		ticks: Int!
+	}
+`})
+
+// resolve runs a field through the resolver middleware, the way generated
+// code does.
+func resolve(ctx context.Context, object, field string, isResolver bool, err error) {
+	ctx = graphql.WithFieldContext(ctx, &graphql.FieldContext{
+		Object: object,
+		Field: graphql.CollectedField{Field: &ast.Field{
+			Name:       field,
+			Alias:      field,
+			Definition: schema.Types[object].Fields.ForName(field),
+		}},
+		IsResolver: isResolver,
+	})
+	_, err = graphql.GetOperationContext(ctx).ResolverMiddleware(ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, err
+	})
+	if err != nil {
+		graphql.AddError(ctx, err)
+	}
+}
+
+// newExecutor returns an executor whose queries resolve the user field,
+// fail to resolve the broken field, and read the name field from a struct,
+// and whose subscriptions send two ticks.
+func newExecutor() *executor.Executor {
+	exec := executor.New(&graphql.ExecutableSchemaMock{
+		ExecFunc: func(ctx context.Context) graphql.ResponseHandler {
+			if graphql.GetOperationContext(ctx).Operation.Operation == ast.Subscription {
+				ticks := 0
+				return func(ctx context.Context) *graphql.Response {
+					if ticks == 2 {
+						return nil
+					}
+					ticks++
+					resolve(ctx, "Subscription", "ticks", true, nil)
+					return &graphql.Response{Data: []byte(`{"ticks":1}`)}
+				}
+			}
+			return func(ctx context.Context) *graphql.Response {
+				resolve(ctx, "Query", "user", true, nil)
+				resolve(ctx, "Query", "broken", true, errors.New("no such thing"))
+				resolve(ctx, "Query", "name", false, nil)
+				return &graphql.Response{Data: []byte(`{}`)}
+			}
+		},
+		SchemaFunc: func() *ast.Schema { return schema },
+		ComplexityFunc: func(string, string, int, map[string]interface{}) (int, bool) {
+			return 0, false
+		},
+	})
+	exec.Use(Tracer{})
+	return exec
+}
+
+// run runs query and returns the responses made.
+func run(t *testing.T, ctx context.Context, query string) []*graphql.Response {
+	exec := newExecutor()
+	ctx = graphql.StartOperationTrace(ctx)
+	oc, errs := exec.CreateOperationContext(ctx, &graphql.RawParams{Query: query})
+	if !assert.Empty(t, errs) {
+		return nil
+	}
+	responses, ctx := exec.DispatchOperation(ctx, oc)
+	var resps []*graphql.Response
+	for resp := responses(ctx); resp != nil; resp = responses(ctx) {
+		resps = append(resps, resp)
+	}
+	return resps
+}
+
+func TestQuery(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	resps := run(t, ctx, "query GetUser { user broken name }")
+	root.Send()
+	if assert.Equal(t, 1, len(resps)) {
+		assert.Equal(t, 1, len(resps[0].Errors))
+	}
+
+	evs := mo.Events()
+	if !assert.Equal(t, 4, len(evs)) {
+		return
+	}
+	user, broken, op, req := evs[0].Data, evs[1].Data, evs[2].Data, evs[3].Data
+	assert.Equal(t, "query GetUser", op["name"])
+	assert.Equal(t, "query", op["graphql.operation_type"])
+	assert.Equal(t, "GetUser", op["graphql.operation_name"])
+	assert.Equal(t, "query GetUser { user broken name }", op["graphql.query"])
+	assert.Equal(t, []string{"no such thing"}, op["graphql.errors"])
+	assert.Equal(t, req["trace.span_id"], op["trace.parent_id"])
+
+	assert.Equal(t, "Query.user", user["name"])
+	assert.Equal(t, "user", user["graphql.field_path"])
+	assert.Equal(t, "String!", user["graphql.return_type"])
+	assert.NotContains(t, user, "graphql.error")
+	assert.Equal(t, op["trace.span_id"], user["trace.parent_id"])
+	assert.Equal(t, "Query.broken", broken["name"])
+	assert.Equal(t, "no such thing", broken["graphql.error"])
+}
+
+func TestSubscription(t *testing.T) {
+	mo := setupLibhoney(t)
+	resps := run(t, context.Background(), "subscription { ticks }")
+	assert.Equal(t, 2, len(resps))
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		op := evs[2].Data
+		assert.Equal(t, "subscription", op["name"])
+		assert.Equal(t, 2, op["graphql.response_count"])
+		assert.Equal(t, true, op["meta.orphaned"])
+		assert.Equal(t, op["trace.span_id"], evs[0].Data["trace.parent_id"])
+	}
+}
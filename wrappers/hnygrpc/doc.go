@@ -0,0 +1,49 @@
+// Package hnygrpc provides gRPC interceptors that emit a span per call and
+// propagate trace context in gRPC metadata.
+//
+// On servers, add the server interceptor to start a span for every call,
+// continuing the caller's trace if it sent one:
+//
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(hnygrpc.UnaryServerInterceptor()),
+//		grpc.StreamInterceptor(hnygrpc.StreamServerInterceptor()),
+//	)
+//
+// Server spans record the method, the peer's address and the status code.
+// Unary calls record the size of the request and response messages, and
+// streaming calls the number of messages and bytes sent and received.
+//
+// Health checks and server reflection calls from service meshes and tooling
+// can outnumber real traffic. UnaryServerInterceptorWithConfig skips them, or
+// traces only a sample of them:
+//
+//	interceptor := hnygrpc.UnaryServerInterceptorWithConfig(hnygrpc.ServerConfig{
+//		FilteredSampleRate: 1000,
+//	})
+//
+// On clients, add the client interceptor to emit a span for every outbound
+// call and pass the trace along to the server:
+//
+//	conn, err := grpc.Dial(addr,
+//		grpc.WithUnaryInterceptor(hnygrpc.UnaryClientInterceptor()),
+//		grpc.WithStreamInterceptor(hnygrpc.StreamClientInterceptor()),
+//	)
+//
+// Client spans record the method, the connection's target and the status
+// code. A streaming call's span is sent when the stream ends, so receive
+// until it returns an error or cancel the call's context when done with it.
+//
+// grpc-gateway
+//
+// A grpc-gateway proxy sits between an HTTP request and a gRPC call. Wrap the
+// gateway's mux with hnynethttp.WrapHandler, pass GatewayMetadata to the mux
+// and use the client interceptor for the gateway's connection to the backend:
+//
+//	mux := runtime.NewServeMux(runtime.WithMetadata(hnygrpc.GatewayMetadata))
+//	opts := []grpc.DialOption{grpc.WithUnaryInterceptor(hnygrpc.UnaryClientInterceptor())}
+//	err := pb.RegisterMyServiceHandlerFromEndpoint(ctx, mux, backendAddr, opts)
+//	http.ListenAndServe(":8080", hnynethttp.WrapHandler(mux))
+//
+// The HTTP request span, the gateway's gRPC client span and the backend's
+// server span (from the server interceptor) then all appear in one trace.
+package hnygrpc
@@ -0,0 +1,66 @@
+package hnygrpc
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// DefaultFilteredMethods are the method prefixes ServerConfig filters when
+// FilteredMethods is nil: health checks and server reflection, which mesh
+// probes and tooling call far more often than anything worth tracing.
+var DefaultFilteredMethods = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+	"/grpc.reflection.v1.ServerReflection/",
+}
+
+// ServerConfig configures UnaryServerInterceptorWithConfig.
+type ServerConfig struct {
+	// FilteredMethods lists full method names, or prefixes of them ending in
+	// "/" to match a whole service, whose calls are not traced.
+	// default: DefaultFilteredMethods
+	FilteredMethods []string
+	// FilteredSampleRate, if more than 1, traces one in this many filtered
+	// calls instead of none. Their spans have grpc.filter_sample_rate set to
+	// the rate so counts can be scaled back up. default: 0, trace none
+	FilteredSampleRate int
+}
+
+// UnaryServerInterceptorWithConfig is UnaryServerInterceptor with calls to
+// some methods, by default health checks and reflection, skipped or
+// sampled. Calls that are skipped go straight to the handler without a span.
+func UnaryServerInterceptorWithConfig(config ServerConfig) grpc.UnaryServerInterceptor {
+	methods := config.FilteredMethods
+	if methods == nil {
+		methods = DefaultFilteredMethods
+	}
+	traced := UnaryServerInterceptor()
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !isFiltered(info.FullMethod, methods) {
+			return traced(ctx, req, info, handler)
+		}
+		rate := config.FilteredSampleRate
+		if rate <= 1 || rand.Intn(rate) != 0 {
+			return handler(ctx, req)
+		}
+		ctx, span := startServerSpan(ctx, info.FullMethod)
+		defer span.Send()
+		span.AddField("grpc.filter_sample_rate", rate)
+
+		resp, err := handler(ctx, req)
+		addStatusFields(span, err)
+		return resp, err
+	}
+}
+
+func isFiltered(method string, filtered []string) bool {
+	for _, f := range filtered {
+		if method == f || (strings.HasSuffix(f, "/") && strings.HasPrefix(method, f)) {
+			return true
+		}
+	}
+	return false
+}
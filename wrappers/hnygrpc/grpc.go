@@ -0,0 +1,307 @@
+package hnygrpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// metadataKey is the gRPC metadata key carrying the Honeycomb trace header.
+// gRPC metadata keys are always lowercase.
+var metadataKey = strings.ToLower(propagation.TracePropagationHTTPHeader)
+
+// UnaryServerInterceptor returns an interceptor that runs each unary call in a
+// span. If the caller sent trace context in its metadata, the span continues
+// the caller's trace.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := startServerSpan(ctx, info.FullMethod)
+		defer span.Send()
+		addMessageSize(span, "grpc.request_size", req)
+
+		resp, err := handler(ctx, req)
+		if err == nil {
+			addMessageSize(span, "grpc.response_size", resp)
+		}
+		addStatusFields(span, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that runs each streaming
+// call in a span, like UnaryServerInterceptor. The span counts the messages
+// and bytes received and sent over the stream, and is sent when the handler
+// returns.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startServerSpan(ss.Context(), info.FullMethod)
+		defer span.Send()
+		span.AddField("grpc.client_stream", info.IsClientStream)
+		span.AddField("grpc.server_stream", info.IsServerStream)
+
+		stream := &serverStream{ServerStream: ss, ctx: ctx}
+		err := handler(srv, stream)
+		stream.addFields(span)
+		addStatusFields(span, err)
+		return err
+	}
+}
+
+// serverStream gives the handler the context with the call's span in it, and
+// counts the messages that pass through the stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	messageCounts
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent(m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received(m)
+	}
+	return err
+}
+
+// messageCounts counts the messages and bytes passing through a stream. A
+// stream may be sent on and received from concurrently, so the counts are
+// updated atomically.
+type messageCounts struct {
+	messagesSent, messagesReceived int64
+	bytesSent, bytesReceived       int64
+}
+
+func (c *messageCounts) sent(m interface{}) {
+	atomic.AddInt64(&c.messagesSent, 1)
+	atomic.AddInt64(&c.bytesSent, int64(messageSize(m)))
+}
+
+func (c *messageCounts) received(m interface{}) {
+	atomic.AddInt64(&c.messagesReceived, 1)
+	atomic.AddInt64(&c.bytesReceived, int64(messageSize(m)))
+}
+
+func (c *messageCounts) addFields(span *trace.Span) {
+	span.AddField("grpc.messages_sent", atomic.LoadInt64(&c.messagesSent))
+	span.AddField("grpc.messages_received", atomic.LoadInt64(&c.messagesReceived))
+	span.AddField("grpc.bytes_sent", atomic.LoadInt64(&c.bytesSent))
+	span.AddField("grpc.bytes_received", atomic.LoadInt64(&c.bytesReceived))
+}
+
+// UnaryClientInterceptor returns an interceptor that runs each outbound unary
+// call in a span that is a child of the span in the call's context, and sends
+// the trace context to the server in the call's metadata.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := common.StartChildSpan(ctx)
+		defer span.Send()
+		span.AddField("meta.type", "grpc_client")
+		span.AddField("name", method)
+		span.AddField("grpc.method", method)
+		span.AddField("grpc.target", cc.Target())
+
+		err := invoker(withTraceMetadata(ctx, span), method, req, reply, cc, opts...)
+		addStatusFields(span, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that runs each outbound
+// streaming call in a span, like UnaryClientInterceptor. The span counts the
+// messages and bytes sent and received, and is sent when the stream ends:
+// when receiving returns an error, including io.EOF, when the only response
+// of a call that doesn't stream responses is received, or when the call's
+// context is done.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := common.StartChildSpan(ctx)
+		span.AddField("meta.type", "grpc_client")
+		span.AddField("name", method)
+		span.AddField("grpc.method", method)
+		span.AddField("grpc.target", cc.Target())
+		span.AddField("grpc.client_stream", desc.ClientStreams)
+		span.AddField("grpc.server_stream", desc.ServerStreams)
+
+		cs, err := streamer(withTraceMetadata(ctx, span), desc, cc, method, opts...)
+		if err != nil {
+			addStatusFields(span, err)
+			span.Send()
+			return nil, err
+		}
+		stream := &clientStream{
+			ClientStream:  cs,
+			span:          span,
+			serverStreams: desc.ServerStreams,
+			done:          make(chan struct{}),
+		}
+		go func() {
+			select {
+			case <-ctx.Done():
+				stream.finish(contextError(ctx.Err()))
+			case <-stream.done:
+			}
+		}()
+		return stream, nil
+	}
+}
+
+// clientStream sends its span once the stream ends.
+type clientStream struct {
+	grpc.ClientStream
+	span          *trace.Span
+	serverStreams bool
+	messageCounts
+
+	once sync.Once
+	done chan struct{}
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sent(m)
+	}
+	// an error sending is followed by the real status from RecvMsg, so
+	// the stream doesn't end here
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.finish(nil)
+	case err != nil:
+		s.finish(err)
+	default:
+		s.received(m)
+		if !s.serverStreams {
+			s.finish(nil)
+		}
+	}
+	return err
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		s.addFields(s.span)
+		addStatusFields(s.span, err)
+		s.span.Send()
+	})
+}
+
+// contextError is the status a call ends with when its context is done.
+func contextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Canceled, err.Error())
+}
+
+// GatewayMetadata passes the trace from an HTTP request to the gRPC call that
+// grpc-gateway makes for it. Use it with runtime.WithMetadata when creating
+// the gateway's ServeMux.
+func GatewayMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	span := trace.GetSpanFromContext(ctx)
+	if span == nil {
+		span = trace.GetSpanFromContext(r.Context())
+	}
+	if span == nil {
+		return nil
+	}
+	return metadata.Pairs(metadataKey, span.SerializeHeaders())
+}
+
+// withTraceMetadata returns a context whose outgoing metadata carries span's
+// trace context, replacing any trace context already there, eg from
+// GatewayMetadata, so the server's span is a child of span.
+func withTraceMetadata(ctx context.Context, span *trace.Span) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	md.Set(metadataKey, span.SerializeHeaders())
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// startServerSpan starts the span for a call to a server. If the context
+// already has a span, the call's span is its child. Otherwise a trace is
+// started, continuing the caller's trace if its metadata has a valid trace
+// header.
+func startServerSpan(ctx context.Context, method string) (context.Context, *trace.Span) {
+	var span *trace.Span
+	if parent := trace.GetSpanFromContext(ctx); parent != nil {
+		ctx, span = parent.CreateChild(ctx)
+	} else {
+		var prop *propagation.PropagationContext
+		var propErr error
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(metadataKey); len(values) > 0 {
+				prop, propErr = propagation.UnmarshalHoneycombTraceContext(values[0])
+			}
+		}
+		var tr *trace.Trace
+		ctx, tr = trace.NewTraceFromPropagationContext(ctx, prop)
+		span = tr.GetRootSpan()
+		common.AddPropagationError(span, propErr)
+	}
+	span.AddField("meta.type", "grpc_request")
+	span.AddField("name", method)
+	span.AddField("grpc.method", method)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		span.AddField("grpc.peer", p.Addr.String())
+	}
+	return ctx, span
+}
+
+// messageSize is the encoded size of a protobuf message, or 0 for anything
+// else.
+func messageSize(m interface{}) int {
+	if pm, ok := m.(proto.Message); ok {
+		return proto.Size(pm)
+	}
+	return 0
+}
+
+func addMessageSize(span *trace.Span, key string, m interface{}) {
+	if _, ok := m.(proto.Message); ok {
+		span.AddField(key, messageSize(m))
+	}
+}
+
+func addStatusFields(span *trace.Span, err error) {
+	st := status.Convert(err)
+	span.AddField("grpc.status_code", st.Code().String())
+	if err != nil {
+		span.AddField("grpc.error", st.Message())
+	}
+}
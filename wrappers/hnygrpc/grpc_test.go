@@ -0,0 +1,380 @@
+package hnygrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/trace"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+// startHealthServer runs a gRPC health server with the given options and
+// returns a connection to it with the given dial options.
+func startHealthServer(t *testing.T, serverOpts []grpc.ServerOption, dialOpts ...grpc.DialOption) (*grpc.ClientConn, func()) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(serverOpts...)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+
+	dialOpts = append(dialOpts,
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+	)
+	conn, err := grpc.Dial("bufnet", dialOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	mo := setupLibhoney(t)
+	conn, stop := startHealthServer(t,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptor())},
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()))
+	defer stop()
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Error(t, err)
+	span.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 5, len(evs)) {
+		return
+	}
+	server, client, root := evs[0].Data, evs[1].Data, evs[4].Data
+	assert.Equal(t, "grpc_request", server["meta.type"])
+	assert.Equal(t, "/grpc.health.v1.Health/Check", server["grpc.method"])
+	assert.Equal(t, "OK", server["grpc.status_code"])
+	assert.Equal(t, "bufconn", server["grpc.peer"])
+	assert.Equal(t, 0, server["grpc.request_size"])
+	assert.Equal(t, 2, server["grpc.response_size"], "a SERVING status is one varint field")
+	assert.Equal(t, "grpc_client", client["meta.type"])
+	assert.Equal(t, "OK", client["grpc.status_code"])
+
+	// the server span continues the client's trace
+	assert.Equal(t, root["trace.trace_id"], server["trace.trace_id"])
+	assert.Equal(t, client["trace.span_id"], server["trace.parent_id"])
+	assert.Equal(t, root["trace.span_id"], client["trace.parent_id"])
+
+	assert.Equal(t, "NotFound", evs[2].Data["grpc.status_code"])
+	assert.Contains(t, evs[2].Data, "grpc.error")
+	assert.Equal(t, 9, evs[2].Data["grpc.request_size"])
+	assert.NotContains(t, evs[2].Data, "grpc.response_size")
+	assert.Equal(t, "NotFound", evs[3].Data["grpc.status_code"])
+}
+
+// fakeServerStream replays recv and records what is sent.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	recv []*healthpb.HealthCheckRequest
+	sent []interface{}
+}
+
+func (f *fakeServerStream) Context() context.Context { return f.ctx }
+
+func (f *fakeServerStream) SendMsg(m interface{}) error {
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+func (f *fakeServerStream) RecvMsg(m interface{}) error {
+	if len(f.recv) == 0 {
+		return io.EOF
+	}
+	m.(*healthpb.HealthCheckRequest).Service = f.recv[0].Service
+	f.recv = f.recv[1:]
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	mo := setupLibhoney(t)
+	_, upstream := beeline.StartSpan(context.Background(), "client")
+	md := metadata.Pairs(metadataKey, upstream.SerializeHeaders())
+	ss := &fakeServerStream{
+		ctx:  metadata.NewIncomingContext(context.Background(), md),
+		recv: []*healthpb.HealthCheckRequest{{Service: "a"}, {Service: "bb"}},
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Chat", IsClientStream: true, IsServerStream: true}
+
+	var handlerSpan *trace.Span
+	err := StreamServerInterceptor()(nil, ss, info, func(srv interface{}, stream grpc.ServerStream) error {
+		handlerSpan = trace.GetSpanFromContext(stream.Context())
+		for {
+			req := &healthpb.HealthCheckRequest{}
+			if err := stream.RecvMsg(req); err != nil {
+				break
+			}
+			stream.SendMsg(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+		}
+		return status.Error(codes.Aborted, "done")
+	})
+	assert.Error(t, err)
+	assert.NotNil(t, handlerSpan, "the handler's stream should carry the span")
+	assert.Equal(t, 2, len(ss.sent))
+	upstream.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		server := evs[0].Data
+		assert.Equal(t, "grpc_request", server["meta.type"])
+		assert.Equal(t, "/pkg.Svc/Chat", server["grpc.method"])
+		assert.Equal(t, true, server["grpc.client_stream"])
+		assert.Equal(t, true, server["grpc.server_stream"])
+		assert.Equal(t, int64(2), server["grpc.messages_received"])
+		assert.Equal(t, int64(7), server["grpc.bytes_received"])
+		assert.Equal(t, int64(2), server["grpc.messages_sent"])
+		assert.Equal(t, int64(4), server["grpc.bytes_sent"])
+		assert.Equal(t, "Aborted", server["grpc.status_code"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], server["trace.parent_id"])
+	}
+}
+
+// waitForEvents waits for n events to be sent, since stream spans can be
+// sent after the call returns.
+func waitForEvents(t *testing.T, mo *transmission.MockSender, n int) []*transmission.Event {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(mo.Events()) < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return mo.Events()
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	mo := setupLibhoney(t)
+	conn, stop := startHealthServer(t,
+		[]grpc.ServerOption{grpc.StreamInterceptor(StreamServerInterceptor())},
+		grpc.WithStreamInterceptor(StreamClientInterceptor()))
+	defer stop()
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watch, err := healthpb.NewHealthClient(conn).Watch(watchCtx, &healthpb.HealthCheckRequest{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	resp, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	cancel()
+	// the parent would send the stream's span early if it were still open
+	waitForEvents(t, mo, 2)
+	span.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 3, len(evs)) {
+		return
+	}
+	byType := map[string]map[string]interface{}{}
+	for _, ev := range evs {
+		typ, _ := ev.Data["meta.type"].(string)
+		byType[typ] = ev.Data
+	}
+	client, server := byType["grpc_client"], byType["grpc_request"]
+	if assert.NotNil(t, client) && assert.NotNil(t, server) {
+		assert.Equal(t, "/grpc.health.v1.Health/Watch", client["grpc.method"])
+		assert.Equal(t, "Canceled", client["grpc.status_code"])
+		assert.Equal(t, int64(1), client["grpc.messages_sent"])
+		assert.Equal(t, int64(1), client["grpc.messages_received"])
+		assert.Equal(t, int64(2), client["grpc.bytes_received"])
+		assert.Equal(t, byType[""]["trace.span_id"], client["trace.parent_id"])
+		assert.Equal(t, client["trace.trace_id"], server["trace.trace_id"])
+		assert.Equal(t, client["trace.span_id"], server["trace.parent_id"])
+		assert.Equal(t, int64(1), server["grpc.messages_received"])
+		assert.Equal(t, int64(1), server["grpc.messages_sent"])
+	}
+}
+
+// fakeClientStream returns recv, then err.
+type fakeClientStream struct {
+	grpc.ClientStream
+	recv []*healthpb.HealthCheckResponse
+	err  error
+}
+
+func (f *fakeClientStream) SendMsg(m interface{}) error { return nil }
+
+func (f *fakeClientStream) RecvMsg(m interface{}) error {
+	if len(f.recv) == 0 {
+		return f.err
+	}
+	m.(*healthpb.HealthCheckResponse).Status = f.recv[0].Status
+	f.recv = f.recv[1:]
+	return nil
+}
+
+func TestStreamClientInterceptorEnds(t *testing.T) {
+	mo := setupLibhoney(t)
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	interceptor := StreamClientInterceptor()
+	serving := &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}
+	recvAll := func(desc *grpc.StreamDesc, fake *fakeClientStream) {
+		var md metadata.MD
+		streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			md, _ = metadata.FromOutgoingContext(ctx)
+			return fake, nil
+		}
+		cs, err := interceptor(context.Background(), desc, conn, "/pkg.Svc/Stream", streamer)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NotEmpty(t, md.Get(metadataKey), "the trace should be sent in metadata")
+		for cs.RecvMsg(&healthpb.HealthCheckResponse{}) == nil {
+		}
+	}
+
+	recvAll(&grpc.StreamDesc{ServerStreams: true}, &fakeClientStream{recv: []*healthpb.HealthCheckResponse{serving, serving}, err: io.EOF})
+	recvAll(&grpc.StreamDesc{ServerStreams: true}, &fakeClientStream{err: status.Error(codes.Unavailable, "gone")})
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "OK", evs[0].Data["grpc.status_code"], "io.EOF ends the stream cleanly")
+		assert.Equal(t, int64(2), evs[0].Data["grpc.messages_received"])
+		assert.Equal(t, "bufnet", evs[0].Data["grpc.target"])
+		assert.Equal(t, "Unavailable", evs[1].Data["grpc.status_code"])
+		assert.Equal(t, "gone", evs[1].Data["grpc.error"])
+	}
+
+	// a call that doesn't stream responses ends with its only response
+	cs, err := interceptor(context.Background(), &grpc.StreamDesc{ClientStreams: true}, conn, "/pkg.Svc/Upload",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{recv: []*healthpb.HealthCheckResponse{serving}}, nil
+		})
+	assert.NoError(t, err)
+	assert.NoError(t, cs.SendMsg(&healthpb.HealthCheckRequest{Service: "a"}))
+	assert.NoError(t, cs.RecvMsg(&healthpb.HealthCheckResponse{}))
+	evs = mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		assert.Equal(t, "OK", evs[2].Data["grpc.status_code"])
+		assert.Equal(t, int64(1), evs[2].Data["grpc.messages_sent"])
+		assert.Equal(t, int64(3), evs[2].Data["grpc.bytes_sent"])
+	}
+
+	_, err = interceptor(context.Background(), &grpc.StreamDesc{}, conn, "/pkg.Svc/Fail",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return nil, status.Error(codes.PermissionDenied, "no")
+		})
+	assert.Error(t, err)
+	evs = mo.Events()
+	if assert.Equal(t, 4, len(evs)) {
+		assert.Equal(t, "PermissionDenied", evs[3].Data["grpc.status_code"])
+	}
+}
+
+func TestServerWithBadMetadata(t *testing.T) {
+	mo := setupLibhoney(t)
+	conn, stop := startHealthServer(t,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptor())})
+	defer stop()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), metadataKey, "garbage")
+	_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Contains(t, evs[0].Data, "meta.propagation_error")
+	}
+}
+
+func TestGatewayMetadata(t *testing.T) {
+	mo := setupLibhoney(t)
+	conn, stop := startHealthServer(t,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptor())},
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()))
+	defer stop()
+
+	// what grpc-gateway does with an annotator: the HTTP request's context
+	// gets the annotator's metadata as outgoing metadata
+	ctx, span := beeline.StartSpan(context.Background(), "http request")
+	r := httptest.NewRequest("GET", "/v1/health", nil).WithContext(ctx)
+	md := GatewayMetadata(ctx, r)
+	assert.Equal(t, []string{span.SerializeHeaders()}, md.Get(metadataKey))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		server, client := evs[0].Data, evs[1].Data
+		assert.Equal(t, client["trace.span_id"], server["trace.parent_id"],
+			"the client span should replace the gateway's trace metadata")
+		assert.Equal(t, evs[2].Data["trace.span_id"], client["trace.parent_id"])
+	}
+
+	assert.Nil(t, GatewayMetadata(context.Background(), httptest.NewRequest("GET", "/", nil)),
+		"no metadata should be added without a trace")
+}
+
+func TestServerFiltersHealthChecks(t *testing.T) {
+	mo := setupLibhoney(t)
+	conn, stop := startHealthServer(t,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(UnaryServerInterceptorWithConfig(ServerConfig{}))})
+	defer stop()
+
+	_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(mo.Events()), "health checks should not be traced")
+}
+
+func TestServerSamplesFilteredCalls(t *testing.T) {
+	mo := setupLibhoney(t)
+	interceptor := UnaryServerInterceptorWithConfig(ServerConfig{
+		FilteredMethods:    []string{"/pkg.Svc/Ping"},
+		FilteredSampleRate: 4,
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	for i := 0; i < 400; i++ {
+		interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Ping"}, handler)
+	}
+	interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/PingPong"}, handler)
+
+	evs := mo.Events()
+	assert.True(t, len(evs) > 50 && len(evs) < 150, "about a quarter of calls should be traced, got %d", len(evs))
+	for _, ev := range evs[:len(evs)-1] {
+		assert.Equal(t, 4, ev.Data["grpc.filter_sample_rate"])
+	}
+	last := evs[len(evs)-1].Data
+	assert.Equal(t, "/pkg.Svc/PingPong", last["grpc.method"], "only exact names and whole services are filtered")
+	assert.NotContains(t, last, "grpc.filter_sample_rate")
+}
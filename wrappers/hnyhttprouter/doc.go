@@ -6,6 +6,11 @@
 // conjunction with the nethttp WrapHandler function. Using these two together
 // will get you an event for every request that comes through your application
 // while also decorating the most interesting paths (the handlers that you wrap)
-// with additional fields from the httprouter patterns.
+// with additional fields from the httprouter patterns. Each named parameter
+// is added as handler.vars.<name>.
+//
+// httprouter doesn't tell handlers which route matched the request, so
+// Middleware can't record it. Registering routes with Handle instead of the
+// router's own methods adds the route pattern as handler.route too.
 //
 package hnyhttprouter
@@ -23,3 +23,14 @@ func ExampleMiddleware() {
 	// wrap the main router to set everything up for instrumenting
 	log.Fatal(http.ListenAndServe(":8080", hnynethttp.WrapHandler(router)))
 }
+
+func ExampleHandle() {
+	// assume you have a handler named getUser
+	var getUser func(w http.ResponseWriter, r *http.Request, _ httprouter.Params)
+
+	router := httprouter.New()
+	// registering the route with Handle records /users/:id as handler.route
+	Handle(router, "GET", "/users/:id", getUser)
+
+	log.Fatal(http.ListenAndServe(":8080", hnynethttp.WrapHandler(router)))
+}
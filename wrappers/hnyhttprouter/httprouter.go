@@ -12,23 +12,47 @@ import (
 // Middleware wraps httprouter handlers. Since it wraps handlers with explicit
 // parameters, it can add those values to the event it generates.
 func Middleware(handle httprouter.Handle) httprouter.Handle {
+	return middleware("", handle)
+}
+
+// Handle registers handle with router for requests matching method and path,
+// wrapped with Middleware. httprouter doesn't tell handlers which route they
+// matched, so registering routes this way is how the event gets the route
+// pattern, eg /users/:id, as handler.route.
+func Handle(router *httprouter.Router, method, path string, handle httprouter.Handle) {
+	router.Handle(method, path, middleware(path, handle))
+}
+
+func middleware(route string, handle httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if common.IgnoreRequest(r) {
+			handle(w, r, ps)
+			return
+		}
 		// get a new context with our trace from the request, and add common fields
 		ctx, span := common.StartSpanOrTraceFromHTTP(r)
 		defer span.Send()
+		common.SetResponseHeaders(w.Header(), r, span)
 		// push the context with our trace and span on to the request
 		r = r.WithContext(ctx)
 
 		// replace the writer with our wrapper to catch the status code
 		wrappedWriter := common.NewResponseWriter(w)
+		defer wrappedWriter.RecordPanic(span)
 
 		// pull out any variables in the URL, add the thing we're matching, etc.
 		for _, param := range ps {
 			span.AddField("handler.vars."+param.Key, param.Value)
 		}
+		if route != "" {
+			span.AddField("handler.route", route)
+		}
 		name := runtime.FuncForPC(reflect.ValueOf(handle).Pointer()).Name()
 		span.AddField("handler.name", name)
 		span.AddField("name", name)
+		if pkg := common.FuncPackage(name); pkg != "" {
+			span.AddField("handler.pkg", pkg)
+		}
 
 		handle(wrappedWriter.Wrapped, r, ps)
 
@@ -36,5 +60,8 @@ func Middleware(handle httprouter.Handle) httprouter.Handle {
 			wrappedWriter.Status = 200
 		}
 		span.AddField("response.status_code", wrappedWriter.Status)
+		wrappedWriter.AddResponseFields(span)
+		wrappedWriter.AddAnomalyFields(span)
+		common.AddContextFields(ctx, span)
 	}
 }
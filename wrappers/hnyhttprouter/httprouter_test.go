@@ -73,3 +73,31 @@ func TestHTTPRouterMiddlewareReturnsStatusCode(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, status)
 
 }
+
+func TestHandleRecordsRoute(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	router := httprouter.New()
+	Handle(router, "GET", "/users/:id/files/*path", func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	r, _ := http.NewRequest("GET", "/users/42/files/a/b.txt", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "/users/:id/files/*path", fields["handler.route"])
+		assert.Equal(t, "42", fields["handler.vars.id"])
+		assert.Equal(t, "/a/b.txt", fields["handler.vars.path"])
+		assert.Equal(t, http.StatusNoContent, fields["response.status_code"])
+	}
+}
@@ -0,0 +1,26 @@
+// Package hnykafkago traces messages written and read with segmentio's
+// kafka-go.
+//
+// Wrap the writer, and write messages with the context of the work they are
+// written for, so their spans are children of that work's span:
+//
+//	w := hnykafkago.WrapWriter(&kafka.Writer{Addr: kafka.TCP(broker), Topic: "orders"})
+//	err := w.WriteMessages(r.Context(), kafka.Message{Value: order})
+//
+// Each message gets a kafka.produce span with kafka.topic, and its trace
+// context is added to the message's headers.
+//
+// When reading, start a span for each message and send it when the message
+// has been handled:
+//
+//	msg, err := reader.FetchMessage(ctx)
+//	...
+//	ctx, span := hnykafkago.StartMessageSpan(ctx, msg)
+//	err = handle(ctx, msg)
+//	span.Send()
+//
+// The span is the root of a new trace, or continues the writer's trace if the
+// message has a trace header. It records kafka.topic, kafka.partition,
+// kafka.offset and kafka.lag, the number of messages in the partition after
+// this one.
+package hnykafkago
@@ -0,0 +1,102 @@
+package hnykafkago
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// headerKey is the message header that carries the trace context.
+const headerKey = propagation.TracePropagationHTTPHeader
+
+// Writer is a kafka.Writer that emits a span for each message it writes.
+type Writer struct {
+	*kafka.Writer
+}
+
+// WrapWriter returns a writer that writes messages with w.
+func WrapWriter(w *kafka.Writer) *Writer {
+	return &Writer{Writer: w}
+}
+
+// WriteMessages writes msgs with a span for each of them that is a child of
+// the span in ctx, and adds that span's trace context to the message's
+// headers. The messages passed in are not modified.
+func (w *Writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	traced := make([]kafka.Message, len(msgs))
+	spans := make([]*trace.Span, len(msgs))
+	for i, msg := range msgs {
+		_, span := common.StartChildSpan(ctx)
+		span.AddField("meta.type", "kafka")
+		span.AddField("name", "kafka.produce")
+		topic := msg.Topic
+		if topic == "" {
+			topic = w.Topic
+		}
+		span.AddField("kafka.topic", topic)
+		msg.Headers = withTraceHeader(msg.Headers, span.SerializeHeaders())
+		traced[i], spans[i] = msg, span
+	}
+
+	err := w.Writer.WriteMessages(ctx, traced...)
+
+	// kafka-go reports which messages failed, when it can
+	werrs, _ := err.(kafka.WriteErrors)
+	for i, span := range spans {
+		msgErr := err
+		if len(werrs) == len(msgs) {
+			msgErr = werrs[i]
+		}
+		if msgErr != nil {
+			span.AddField("kafka.error", msgErr.Error())
+		}
+		span.Send()
+	}
+	return err
+}
+
+// withTraceHeader returns a copy of headers with the trace header set,
+// replacing any from an earlier attempt to write the message.
+func withTraceHeader(headers []kafka.Header, header string) []kafka.Header {
+	traced := make([]kafka.Header, 0, len(headers)+1)
+	for _, h := range headers {
+		if h.Key != headerKey {
+			traced = append(traced, h)
+		}
+	}
+	return append(traced, kafka.Header{Key: headerKey, Value: []byte(header)})
+}
+
+// StartMessageSpan starts a trace for handling msg, continuing the writer's
+// trace if msg has a trace header, and returns its root span. The caller
+// must send the span.
+func StartMessageSpan(ctx context.Context, msg kafka.Message) (context.Context, *trace.Span) {
+	var prop *propagation.PropagationContext
+	var propErr error
+	for _, h := range msg.Headers {
+		if h.Key == headerKey {
+			prop, propErr = propagation.UnmarshalHoneycombTraceContext(string(h.Value))
+			break
+		}
+	}
+	ctx, tr := trace.NewTraceFromPropagationContext(ctx, prop)
+	span := tr.GetRootSpan()
+	common.AddPropagationError(span, propErr)
+	span.AddField("meta.type", "kafka")
+	span.AddField("name", "kafka.consume")
+	span.AddField("kafka.topic", msg.Topic)
+	span.AddField("kafka.partition", msg.Partition)
+	span.AddField("kafka.offset", msg.Offset)
+	// the high water mark is the offset the next message will have, and is
+	// only known for messages from a Reader
+	if msg.HighWaterMark > 0 {
+		if lag := msg.HighWaterMark - msg.Offset - 1; lag >= 0 {
+			span.AddField("kafka.lag", lag)
+		}
+	}
+	return ctx, span
+}
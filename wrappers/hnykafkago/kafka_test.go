@@ -0,0 +1,81 @@
+package hnykafkago
+
+import (
+	"context"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func TestWriteMessagesError(t *testing.T) {
+	mo := setupLibhoney(t)
+	// a writer without an address fails every write
+	w := WrapWriter(&kafka.Writer{Topic: "orders"})
+
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	msgs := []kafka.Message{{Value: []byte("order 1")}, {Topic: "invoices", Value: []byte("invoice 1")}}
+	assert.Error(t, w.WriteMessages(ctx, msgs...))
+	root.Send()
+	assert.Empty(t, msgs[0].Headers, "the messages passed in should be left alone")
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		assert.Equal(t, "kafka.produce", evs[0].Data["name"])
+		assert.Equal(t, "orders", evs[0].Data["kafka.topic"])
+		assert.Equal(t, "invoices", evs[1].Data["kafka.topic"])
+		assert.Contains(t, evs[1].Data, "kafka.error")
+		assert.Equal(t, evs[2].Data["trace.span_id"], evs[0].Data["trace.parent_id"])
+	}
+}
+
+func TestStartMessageSpan(t *testing.T) {
+	mo := setupLibhoney(t)
+	_, producer := beeline.StartSpan(context.Background(), "producer")
+	headers := []kafka.Header{{Key: "tenant", Value: []byte("acme")}}
+	headers = withTraceHeader(headers, "stale")
+	headers = withTraceHeader(headers, producer.SerializeHeaders())
+	assert.Equal(t, 2, len(headers), "an earlier trace header should be replaced")
+
+	msg := kafka.Message{Topic: "orders", Partition: 1, Offset: 41, HighWaterMark: 50, Headers: headers}
+	_, span := StartMessageSpan(context.Background(), msg)
+	span.Send()
+	producer.Send()
+
+	_, span = StartMessageSpan(context.Background(), kafka.Message{
+		Topic:   "orders",
+		Headers: []kafka.Header{{Key: headerKey, Value: []byte("2;nope")}},
+	})
+	span.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 3, len(evs)) {
+		return
+	}
+	consumer, prod, bad := evs[0].Data, evs[1].Data, evs[2].Data
+	assert.Equal(t, "kafka.consume", consumer["name"])
+	assert.Equal(t, prod["trace.trace_id"], consumer["trace.trace_id"])
+	assert.Equal(t, prod["trace.span_id"], consumer["trace.parent_id"])
+	assert.Equal(t, "orders", consumer["kafka.topic"])
+	assert.Equal(t, 1, consumer["kafka.partition"])
+	assert.Equal(t, int64(41), consumer["kafka.offset"])
+	assert.Equal(t, int64(8), consumer["kafka.lag"])
+
+	assert.Contains(t, bad, "meta.propagation_error")
+	assert.NotContains(t, bad, "kafka.lag")
+}
@@ -0,0 +1,22 @@
+// Package hnylambda wraps AWS Lambda handlers written with aws-lambda-go to
+// emit a trace per invocation.
+//
+// Wrap the handler you would pass to lambda.Start and start it with
+// lambda.StartHandler instead:
+//
+//	func main() {
+//		beeline.Init(beeline.Config{WriteKey: "...", Dataset: "lambda"})
+//		lambda.StartHandler(hnylambda.Wrap(handleRequest))
+//	}
+//
+// Each invocation gets a root span recording the function's name, version and
+// memory limit, the request ID, whether it was a cold start and how long the
+// invocation had left when it started. The span continues the X-Ray trace
+// the invocation is part of, so spans from services that call the function
+// through instrumented AWS services line up with it. Errors returned by the
+// handler are recorded on the span, and panics as for any deferred Send.
+//
+// Lambda freezes the function as soon as the handler returns, so the wrapper
+// flushes events before returning, waiting no longer than the invocation's
+// deadline.
+package hnylambda
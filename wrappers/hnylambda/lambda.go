@@ -0,0 +1,96 @@
+package hnylambda
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// traceIDKey is the context key aws-lambda-go stores the invocation's X-Ray
+// trace header under.
+const traceIDKey = "x-amzn-trace-id"
+
+// invoked is set once the first invocation in this process has started, so
+// later ones aren't cold starts.
+var invoked int32
+
+// Wrap returns a lambda.Handler that runs handler, which can be any function
+// lambda.Start accepts, in a span per invocation.
+func Wrap(handler interface{}) lambda.Handler {
+	return WrapHandler(lambda.NewHandler(handler))
+}
+
+// WrapHandler returns a lambda.Handler that runs h in a span per invocation.
+func WrapHandler(h lambda.Handler) lambda.Handler {
+	return &handler{wrapped: h}
+}
+
+type handler struct {
+	wrapped lambda.Handler
+}
+
+func (h *handler) Invoke(ctx context.Context, payload []byte) (resp []byte, err error) {
+	started := time.Now()
+	coldStart := atomic.CompareAndSwapInt32(&invoked, 0, 1)
+
+	var prop *propagation.PropagationContext
+	var propErr error
+	if header, ok := ctx.Value(traceIDKey).(string); ok && header != "" {
+		prop, propErr = unmarshalXRayTraceContext(header)
+	}
+	ctx, tr := trace.NewTraceFromPropagationContext(ctx, prop)
+	span := tr.GetRootSpan()
+	// flush last, once the span has been sent, even if the handler panics
+	defer beeline.Flush(ctx)
+	defer span.Send()
+
+	common.AddPropagationError(span, propErr)
+	span.AddField("meta.type", "lambda")
+	name := lambdacontext.FunctionName
+	if name == "" {
+		name = "lambda"
+	}
+	span.AddField("name", name)
+	span.AddField("aws.lambda.function_name", lambdacontext.FunctionName)
+	span.AddField("aws.lambda.function_version", lambdacontext.FunctionVersion)
+	span.AddField("aws.lambda.memory_limit_mb", lambdacontext.MemoryLimitInMB)
+	span.AddField("aws.lambda.cold_start", coldStart)
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		span.AddField("aws.lambda.request_id", lc.AwsRequestID)
+		span.AddField("aws.lambda.invoked_function_arn", lc.InvokedFunctionArn)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		span.AddField("aws.lambda.remaining_time_ms", float64(deadline.Sub(started))/float64(time.Millisecond))
+	}
+
+	resp, err = h.wrapped.Invoke(ctx, payload)
+	if err != nil {
+		span.AddError(err)
+	}
+	return resp, err
+}
+
+// unmarshalXRayTraceContext parses the X-Ray trace header Lambda passes each
+// invocation, eg Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1.
+// Unlike the load balancer header it names the caller's segment Parent
+// rather than Self. X-Ray's own sampling decision isn't kept as a trace
+// field.
+func unmarshalXRayTraceContext(header string) (*propagation.PropagationContext, error) {
+	prop, err := propagation.UnmarshalAmazonTraceContext(header)
+	if err != nil {
+		return nil, err
+	}
+	if parent, ok := prop.TraceContext["Parent"].(string); ok && parent != "" {
+		prop.ParentID = parent
+		delete(prop.TraceContext, "Parent")
+	}
+	delete(prop.TraceContext, "Sampled")
+	return prop, nil
+}
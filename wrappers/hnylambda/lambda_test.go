@@ -0,0 +1,121 @@
+package hnylambda
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func invocationContext(requestID, traceHeader string) (context.Context, context.CancelFunc) {
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		AwsRequestID:       requestID,
+		InvokedFunctionArn: "arn:aws:lambda:us-east-1:123456789012:function:greeter",
+	})
+	if traceHeader != "" {
+		ctx = context.WithValue(ctx, traceIDKey, traceHeader)
+	}
+	return context.WithTimeout(ctx, time.Minute)
+}
+
+func TestWrap(t *testing.T) {
+	mo := setupLibhoney(t)
+	atomic.StoreInt32(&invoked, 0)
+	lambdacontext.FunctionName = "greeter"
+	defer func() { lambdacontext.FunctionName = "" }()
+
+	h := Wrap(func(ctx context.Context, req struct{ Name string }) (string, error) {
+		if req.Name == "" {
+			return "", errors.New("no name")
+		}
+		_, span := beeline.StartSpan(ctx, "greet")
+		span.Send()
+		return "hello " + req.Name, nil
+	})
+
+	ctx, cancel := invocationContext("req-1", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	defer cancel()
+	resp, err := h.Invoke(ctx, []byte(`{"Name":"ada"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `"hello ada"`, string(resp))
+
+	ctx, cancel = invocationContext("req-2", "")
+	defer cancel()
+	_, err = h.Invoke(ctx, []byte(`{}`))
+	assert.EqualError(t, err, "no name")
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		child, first, second := evs[0].Data, evs[1].Data, evs[2].Data
+		assert.Equal(t, first["trace.span_id"], child["trace.parent_id"])
+		assert.Equal(t, "greeter", first["name"])
+		assert.Equal(t, "lambda", first["meta.type"])
+		assert.Equal(t, "greeter", first["aws.lambda.function_name"])
+		assert.Equal(t, "req-1", first["aws.lambda.request_id"])
+		assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:greeter", first["aws.lambda.invoked_function_arn"])
+		assert.Equal(t, true, first["aws.lambda.cold_start"])
+		assert.True(t, first["aws.lambda.remaining_time_ms"].(float64) > 0)
+		assert.Equal(t, "bd862e3fe1be46a994272793", first["trace.trace_id"])
+		assert.Equal(t, "53995c3f42cd8ad8", first["trace.parent_id"])
+		assert.NotContains(t, first, "Sampled")
+
+		assert.Equal(t, false, second["aws.lambda.cold_start"])
+		assert.Equal(t, "req-2", second["aws.lambda.request_id"])
+		assert.Equal(t, "no name", second["error"])
+		assert.NotContains(t, second, "trace.parent_id")
+	}
+}
+
+func TestWrapBadTraceHeader(t *testing.T) {
+	mo := setupLibhoney(t)
+	h := Wrap(func() error { return nil })
+	ctx, cancel := invocationContext("req-3", "Sampled=1")
+	defer cancel()
+	_, err := h.Invoke(ctx, nil)
+	assert.NoError(t, err)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "lambda", evs[0].Data["name"])
+		assert.Contains(t, evs[0].Data, "meta.propagation_error")
+	}
+}
+
+func TestWrapHandlerPanic(t *testing.T) {
+	mo := setupLibhoney(t)
+	h := WrapHandler(panicHandler{})
+	ctx, cancel := invocationContext("req-4", "")
+	defer cancel()
+	assert.Panics(t, func() { h.Invoke(ctx, nil) })
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "panic", evs[0].Data["error"])
+		assert.Equal(t, "out of cheese", evs[0].Data["panic.value"])
+	}
+}
+
+type panicHandler struct{}
+
+func (panicHandler) Invoke(context.Context, []byte) ([]byte, error) {
+	panic("out of cheese")
+}
@@ -0,0 +1,17 @@
+// Package hnylog attaches log lines to the span of the request they were
+// logged during, so their context is visible in the trace without logging to
+// Honeycomb separately.
+//
+// Each log line is sent as a span event on the span in its context, named
+// "log", with its level in `log.level`, its message in `log.message` and any
+// structured fields as `log.<field>`. The span also counts the lines logged
+// during it in `rollup.log.count` and per level, eg `rollup.log.error_count`,
+// and the root span totals them for the whole trace.
+//
+// Use Writer to bridge the standard library's log package:
+//
+//	logger := log.New(hnylog.Writer(r.Context(), os.Stderr), "", log.LstdFlags)
+//
+// The hnylogrus and hnyzap packages bridge logrus and zap. Other loggers can
+// call Record directly.
+package hnylog
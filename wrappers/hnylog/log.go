@@ -0,0 +1,54 @@
+package hnylog
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+// Record attaches a log line to the span in ctx, as a span event and in the
+// span's log counts. level is the logger's name for the level, eg "info" or
+// "error"; "warning" is counted as "warn" so that loggers agree. Errors in
+// fields are recorded as their message. It does nothing if ctx has no span.
+func Record(ctx context.Context, level, msg string, fields map[string]interface{}) {
+	span := trace.GetSpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	level = strings.ToLower(level)
+	if level == "warning" {
+		level = "warn"
+	}
+	span.AddRollupField("log.count", 1)
+	span.AddRollupField("log."+level+"_count", 1)
+
+	ev := make(map[string]interface{}, len(fields)+2)
+	for k, v := range fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		ev["log."+k] = v
+	}
+	ev["log.level"] = level
+	ev["log.message"] = msg
+	span.AddSpanEvent("log", ev)
+}
+
+// Writer returns an io.Writer for a log.Logger that records each line logged
+// during the span in ctx at the "info" level, then writes it to w as usual.
+func Writer(ctx context.Context, w io.Writer) io.Writer {
+	return &writer{ctx: ctx, w: w}
+}
+
+type writer struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// Write is called by log.Logger once for each line.
+func (w *writer) Write(p []byte) (int, error) {
+	Record(w.ctx, "info", strings.TrimSuffix(string(p), "\n"), nil)
+	return w.w.Write(p)
+}
@@ -0,0 +1,66 @@
+package hnylog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func TestRecord(t *testing.T) {
+	mo := setupLibhoney(t)
+	Record(context.Background(), "info", "no span", nil)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	Record(ctx, "WARNING", "slow query", map[string]interface{}{"table": "users"})
+	Record(ctx, "error", "query failed", map[string]interface{}{"error": errors.New("timeout")})
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		warn, failed, root := evs[0].Data, evs[1].Data, evs[2].Data
+		assert.Equal(t, "log", warn["name"])
+		assert.Equal(t, "span_event", warn["meta.annotation_type"])
+		assert.Equal(t, root["trace.span_id"], warn["trace.parent_id"])
+		assert.Equal(t, "warn", warn["log.level"])
+		assert.Equal(t, "slow query", warn["log.message"])
+		assert.Equal(t, "users", warn["log.table"])
+		assert.Equal(t, "timeout", failed["log.error"])
+		assert.Equal(t, float64(2), root["rollup.log.count"])
+		assert.Equal(t, float64(1), root["rollup.log.warn_count"])
+		assert.Equal(t, float64(1), root["rollup.log.error_count"])
+	}
+}
+
+func TestWriter(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	var buf bytes.Buffer
+	logger := log.New(Writer(ctx, &buf), "app: ", 0)
+	logger.Printf("hello %s", "world")
+	span.Send()
+
+	assert.Equal(t, "app: hello world\n", buf.String(), "lines should still be written")
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "info", evs[0].Data["log.level"])
+		assert.Equal(t, "app: hello world", evs[0].Data["log.message"])
+	}
+}
@@ -0,0 +1,12 @@
+// Package hnylogrus attaches logrus log lines to the span of the request they
+// were logged during.
+//
+// Add the hook to a logger and log with a request's context:
+//
+//	logrus.AddHook(hnylogrus.NewHook())
+//	logrus.WithContext(r.Context()).WithField("user_id", id).Warn("slow lookup")
+//
+// Each entry logged with a context that has a span is recorded on the span
+// as described by the hnylog package, with the entry's fields as
+// `log.<field>`. Entries without a context are only logged.
+package hnylogrus
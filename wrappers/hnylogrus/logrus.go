@@ -0,0 +1,34 @@
+package hnylogrus
+
+import (
+	"github.com/honeycombio/beeline-go/wrappers/hnylog"
+	"github.com/sirupsen/logrus"
+)
+
+// Hook is a logrus.Hook that records entries on the span in their context.
+type Hook struct {
+	levels []logrus.Level
+}
+
+// NewHook returns a Hook for entries at the given levels, or at every level
+// if none are given.
+func NewHook(levels ...logrus.Level) *Hook {
+	if len(levels) == 0 {
+		levels = logrus.AllLevels
+	}
+	return &Hook{levels: levels}
+}
+
+// Levels returns the levels the hook records.
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire records the entry on the span in its context.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	hnylog.Record(entry.Context, entry.Level.String(), entry.Message, entry.Data)
+	return nil
+}
@@ -0,0 +1,55 @@
+package hnylogrus
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// make sure the interface is implemented
+var _ logrus.Hook = &Hook{}
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func TestHook(t *testing.T) {
+	mo := setupLibhoney(t)
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.AddHook(NewHook(logrus.WarnLevel, logrus.ErrorLevel))
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	logger.WithContext(ctx).Info("not hooked")
+	logger.WithContext(ctx).WithField("user_id", 7).Warn("slow lookup")
+	logger.WithContext(ctx).WithError(errors.New("timeout")).Error("lookup failed")
+	logger.Error("no context")
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		warn, failed, root := evs[0].Data, evs[1].Data, evs[2].Data
+		assert.Equal(t, "warn", warn["log.level"])
+		assert.Equal(t, "slow lookup", warn["log.message"])
+		assert.Equal(t, 7, warn["log.user_id"])
+		assert.Equal(t, "error", failed["log.level"])
+		assert.Equal(t, "timeout", failed["log.error"])
+		assert.Equal(t, float64(2), root["rollup.log.count"])
+		assert.Equal(t, float64(1), root["rollup.log.error_count"])
+	}
+}
@@ -0,0 +1,19 @@
+// Package hnymemcache emits spans for the operations made with a gomemcache
+// client.
+//
+// Wrap the client with the context of the work it is used for, so the spans
+// are children of that work's span. Wrapping is cheap, so wrap the shared
+// client for each request:
+//
+//	mc := memcache.New("10.0.0.1:11211")
+//	...
+//	item, err := hnymemcache.Wrap(r.Context(), mc).Get("user:" + id)
+//
+// Spans are made with hnycache.StartOperation, so they have the same fields
+// as those for other caches: cache.name is "memcache", cache.operation is the
+// method called, eg "get", and for keys namespaced with colons, the first
+// part of the key is recorded as cache.key_prefix. Gets record whether they
+// hit in cache.hit, GetMulti the number of keys found and missed in
+// cache.hits and cache.misses, and gets and writes the size of the item in
+// cache.item_size. A cache miss is not recorded as an error.
+package hnymemcache
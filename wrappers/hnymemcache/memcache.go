@@ -0,0 +1,150 @@
+package hnymemcache
+
+import (
+	"context"
+
+	"github.com/bradfitz/gomemcache/memcache"
+
+	"github.com/honeycombio/beeline-go/wrappers/hnycache"
+)
+
+const (
+	// cacheName is recorded as cache.name on every span.
+	cacheName = "memcache"
+	// maxRelativeExpiration is the longest expiration memcached takes as a
+	// number of seconds; longer ones are unix times.
+	maxRelativeExpiration = 30 * 24 * 60 * 60
+)
+
+// Client times the operations made with a memcache client. Its methods are
+// those of memcache.Client.
+type Client struct {
+	ctx context.Context
+	mc  *memcache.Client
+}
+
+// Wrap returns a client that emits a span for each operation made with mc,
+// as a child of the span in ctx.
+func Wrap(ctx context.Context, mc *memcache.Client) *Client {
+	return &Client{ctx: ctx, mc: mc}
+}
+
+func (c *Client) start(operation, key string) *hnycache.Operation {
+	return hnycache.StartOperation(c.ctx, cacheName, operation, key)
+}
+
+// Get gets the item for key, recording whether it was found.
+func (c *Client) Get(key string) (*memcache.Item, error) {
+	op := c.start("get", key)
+	item, err := c.mc.Get(key)
+	op.Finish(c.lookup(op, item, err))
+	return item, err
+}
+
+// GetMulti gets the items for keys, recording how many were found.
+func (c *Client) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	var first string
+	if len(keys) > 0 {
+		first = keys[0]
+	}
+	op := c.start("get_multi", first)
+	op.AddField("cache.keys", len(keys))
+	items, err := c.mc.GetMulti(keys)
+	if err == nil {
+		op.Hits(len(items), len(keys)-len(items))
+		size := 0
+		for _, item := range items {
+			size += len(item.Value)
+		}
+		op.Size(size)
+	}
+	op.Finish(err)
+	return items, err
+}
+
+// lookup records the result of a single get, returning the error to record,
+// which a miss isn't.
+func (c *Client) lookup(op *hnycache.Operation, item *memcache.Item, err error) error {
+	switch {
+	case err == memcache.ErrCacheMiss:
+		op.Hit(false)
+		return nil
+	case err == nil:
+		op.Hit(true)
+		op.Size(len(item.Value))
+	}
+	return err
+}
+
+func (c *Client) write(operation string, item *memcache.Item, write func(*memcache.Item) error) error {
+	op := c.start(operation, item.Key)
+	op.Size(len(item.Value))
+	if item.Expiration > 0 && item.Expiration <= maxRelativeExpiration {
+		op.AddField("cache.ttl_ms", float64(item.Expiration)*1000)
+	}
+	err := write(item)
+	op.Finish(err)
+	return err
+}
+
+// Set writes the item unconditionally.
+func (c *Client) Set(item *memcache.Item) error {
+	return c.write("set", item, c.mc.Set)
+}
+
+// Add writes the item if there isn't one for its key already.
+func (c *Client) Add(item *memcache.Item) error {
+	return c.write("add", item, c.mc.Add)
+}
+
+// Replace writes the item if there is one for its key already.
+func (c *Client) Replace(item *memcache.Item) error {
+	return c.write("replace", item, c.mc.Replace)
+}
+
+// Append appends the item's value to that stored for its key.
+func (c *Client) Append(item *memcache.Item) error {
+	return c.write("append", item, c.mc.Append)
+}
+
+// Prepend prepends the item's value to that stored for its key.
+func (c *Client) Prepend(item *memcache.Item) error {
+	return c.write("prepend", item, c.mc.Prepend)
+}
+
+// CompareAndSwap writes the item if it hasn't changed since it was read.
+func (c *Client) CompareAndSwap(item *memcache.Item) error {
+	return c.write("cas", item, c.mc.CompareAndSwap)
+}
+
+// Delete deletes the item for key.
+func (c *Client) Delete(key string) error {
+	op := c.start("delete", key)
+	err := c.mc.Delete(key)
+	op.Finish(err)
+	return err
+}
+
+// Touch updates the expiry of the item for key.
+func (c *Client) Touch(key string, seconds int32) error {
+	op := c.start("touch", key)
+	err := c.mc.Touch(key, seconds)
+	op.Finish(err)
+	return err
+}
+
+// Increment adds delta to the number stored for key.
+func (c *Client) Increment(key string, delta uint64) (uint64, error) {
+	op := c.start("incr", key)
+	n, err := c.mc.Increment(key, delta)
+	op.Finish(err)
+	return n, err
+}
+
+// Decrement subtracts delta from the number stored for key.
+func (c *Client) Decrement(key string, delta uint64) (uint64, error) {
+	op := c.start("decr", key)
+	n, err := c.mc.Decrement(key, delta)
+	op.Finish(err)
+	return n, err
+}
@@ -0,0 +1,137 @@
+package hnymemcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/beelinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMemcached serves the gets, set and delete commands of the memcached
+// text protocol from a map.
+type fakeMemcached struct {
+	lock  sync.Mutex
+	items map[string][]byte
+}
+
+func startFakeMemcached(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeMemcached{items: map[string][]byte{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func (f *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+		f.lock.Lock()
+		switch fields[0] {
+		case "gets":
+			for _, key := range fields[1:] {
+				if val, ok := f.items[key]; ok {
+					fmt.Fprintf(rw, "VALUE %s 0 %d 1\r\n%s\r\n", key, len(val), val)
+				}
+			}
+			fmt.Fprintf(rw, "END\r\n")
+		case "set":
+			size, _ := strconv.Atoi(fields[4])
+			val := make([]byte, size+2)
+			io.ReadFull(rw, val)
+			f.items[fields[1]] = val[:size]
+			fmt.Fprintf(rw, "STORED\r\n")
+		case "delete":
+			if _, ok := f.items[fields[1]]; ok {
+				delete(f.items, fields[1])
+				fmt.Fprintf(rw, "DELETED\r\n")
+			} else {
+				fmt.Fprintf(rw, "NOT_FOUND\r\n")
+			}
+		default:
+			fmt.Fprintf(rw, "ERROR\r\n")
+		}
+		f.lock.Unlock()
+		rw.Flush()
+	}
+}
+
+func TestClient(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	addr, stop := startFakeMemcached(t)
+	defer stop()
+	mc := memcache.New(addr)
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	c := Wrap(ctx, mc)
+
+	assert.NoError(t, c.Set(&memcache.Item{Key: "user:1", Value: []byte("ada"), Expiration: 60}))
+	item, err := c.Get("user:1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "ada", string(item.Value))
+	}
+	_, err = c.Get("user:2")
+	assert.Equal(t, memcache.ErrCacheMiss, err)
+	items, err := c.GetMulti([]string{"user:1", "user:2", "user:3"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, memcache.ErrCacheMiss, c.Delete("user:2"))
+	root.Send()
+
+	evs := rec.Events()
+	if !assert.Equal(t, 6, len(evs)) {
+		return
+	}
+	set, hit, miss, multi, del, req := evs[0], evs[1], evs[2], evs[3], evs[4], evs[5]
+	assert.Equal(t, "cache.set", set["name"])
+	assert.Equal(t, "memcache", set["cache.name"])
+	assert.Equal(t, "user", set["cache.key_prefix"])
+	assert.Equal(t, 3, set["cache.item_size"])
+	assert.Equal(t, float64(60000), set["cache.ttl_ms"])
+	assert.Equal(t, req["trace.span_id"], set["trace.parent_id"])
+
+	assert.Equal(t, "get", hit["cache.operation"])
+	assert.Equal(t, true, hit["cache.hit"])
+	assert.Equal(t, 3, hit["cache.item_size"])
+	assert.Equal(t, false, miss["cache.hit"])
+	assert.NotContains(t, miss, "cache.error", "a miss isn't an error")
+
+	assert.Equal(t, "get_multi", multi["cache.operation"])
+	assert.Equal(t, 3, multi["cache.keys"])
+	assert.Equal(t, 1, multi["cache.hits"])
+	assert.Equal(t, 2, multi["cache.misses"])
+
+	assert.Equal(t, memcache.ErrCacheMiss.Error(), del["cache.error"])
+
+	assert.Equal(t, float64(2), req["rollup.cache.hit_count"])
+	assert.Equal(t, float64(3), req["rollup.cache.miss_count"])
+	assert.Equal(t, float64(5), req["rollup.cache.call_count"])
+	assert.InDelta(t, 2.0/5.0, req["cache.hit_rate"], 0.0001)
+}
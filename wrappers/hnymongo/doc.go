@@ -0,0 +1,18 @@
+// Package hnymongo emits spans for the commands the official MongoDB driver
+// sends to the server.
+//
+// Set the monitor on the client's options, and pass the driver a context
+// with a span in it, so the commands are children of that span:
+//
+//	opts := options.Client().ApplyURI(uri).SetMonitor(hnymongo.NewMonitor())
+//	client, err := mongo.Connect(ctx, opts)
+//	...
+//	err = client.Database("app").Collection("users").FindOne(r.Context(), filter).Decode(&user)
+//
+// Each span is named for the command, and records it in db.command, along
+// with mongodb.database, mongodb.collection and, for commands that fail,
+// mongodb.error. The command's filters and documents are not recorded. The
+// time spent in mongo and the number of commands sent are also rolled up
+// onto the root span, as rollup.mongodb.duration_ms and
+// rollup.mongodb.call_count.
+package hnymongo
@@ -0,0 +1,93 @@
+package hnymongo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// NewMonitor returns a command monitor that emits a span for each command,
+// as a child of the span in the context the command was run with.
+func NewMonitor() *event.CommandMonitor {
+	m := &monitor{spans: make(map[commandKey]*trace.Span)}
+	return &event.CommandMonitor{
+		Started:   m.started,
+		Succeeded: m.succeeded,
+		Failed:    m.failed,
+	}
+}
+
+// monitor holds the span for each command between its start and its end,
+// which the driver reports separately.
+type monitor struct {
+	lock  sync.Mutex
+	spans map[commandKey]*trace.Span
+}
+
+type commandKey struct {
+	connectionID string
+	requestID    int64
+}
+
+func (m *monitor) started(ctx context.Context, ev *event.CommandStartedEvent) {
+	_, span := common.StartChildSpan(ctx)
+	span.AddField("meta.type", "mongodb")
+	span.AddField("name", "mongodb."+ev.CommandName)
+	span.AddField("db.command", ev.CommandName)
+	span.AddField("mongodb.database", ev.DatabaseName)
+	span.AddField("mongodb.connection_id", ev.ConnectionID)
+	if coll := collection(ev.CommandName, ev.Command); coll != "" {
+		span.AddField("mongodb.collection", coll)
+	}
+
+	m.lock.Lock()
+	m.spans[commandKey{ev.ConnectionID, ev.RequestID}] = span
+	m.lock.Unlock()
+}
+
+func (m *monitor) succeeded(ctx context.Context, ev *event.CommandSucceededEvent) {
+	m.finish(ev.CommandFinishedEvent, "")
+}
+
+func (m *monitor) failed(ctx context.Context, ev *event.CommandFailedEvent) {
+	m.finish(ev.CommandFinishedEvent, ev.Failure)
+}
+
+func (m *monitor) finish(ev event.CommandFinishedEvent, failure string) {
+	key := commandKey{ev.ConnectionID, ev.RequestID}
+	m.lock.Lock()
+	span, ok := m.spans[key]
+	delete(m.spans, key)
+	m.lock.Unlock()
+	if !ok {
+		return
+	}
+	if failure != "" {
+		span.AddField("mongodb.error", failure)
+	}
+	span.AddRollupField("mongodb.duration_ms", float64(ev.DurationNanos)/float64(time.Millisecond))
+	span.AddRollupField("mongodb.call_count", 1)
+	span.Send()
+}
+
+// collection returns the collection a command is run on. Most commands name
+// it as the value of their first element, which is the command's name.
+// getMore names it separately.
+func collection(commandName string, command bson.Raw) string {
+	if commandName == "getMore" {
+		coll, _ := command.Lookup("collection").StringValueOK()
+		return coll
+	}
+	elem, err := command.IndexErr(0)
+	if err != nil || elem.Key() != commandName {
+		return ""
+	}
+	coll, _ := elem.Value().StringValueOK()
+	return coll
+}
@@ -0,0 +1,103 @@
+package hnymongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func command(t *testing.T, doc bson.D) bson.Raw {
+	raw, err := bson.Marshal(doc)
+	assert.NoError(t, err)
+	return raw
+}
+
+func TestMonitor(t *testing.T) {
+	mo := setupLibhoney(t)
+	m := NewMonitor()
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+
+	m.Started(ctx, &event.CommandStartedEvent{
+		Command:      command(t, bson.D{{Key: "find", Value: "users"}, {Key: "filter", Value: bson.D{{Key: "name", Value: "ada"}}}}),
+		DatabaseName: "app",
+		CommandName:  "find",
+		RequestID:    1,
+		ConnectionID: "localhost:27017[-1]",
+	})
+	m.Started(ctx, &event.CommandStartedEvent{
+		Command:      command(t, bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "events"}}),
+		DatabaseName: "app",
+		CommandName:  "getMore",
+		RequestID:    2,
+		ConnectionID: "localhost:27017[-2]",
+	})
+	m.Failed(ctx, &event.CommandFailedEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{
+			DurationNanos: int64(3 * time.Millisecond),
+			CommandName:   "getMore",
+			RequestID:     2,
+			ConnectionID:  "localhost:27017[-2]",
+		},
+		Failure: "(CursorNotFound) cursor id 42 not found",
+	})
+	m.Succeeded(ctx, &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{
+			DurationNanos: int64(2 * time.Millisecond),
+			CommandName:   "find",
+			RequestID:     1,
+			ConnectionID:  "localhost:27017[-1]",
+		},
+	})
+	// a command that finishes without having been seen to start is ignored
+	m.Succeeded(ctx, &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "ping", RequestID: 3},
+	})
+	root.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 3, len(evs)) {
+		return
+	}
+	getMore, find, req := evs[0].Data, evs[1].Data, evs[2].Data
+	assert.Equal(t, "mongodb.getMore", getMore["name"])
+	assert.Equal(t, "events", getMore["mongodb.collection"])
+	assert.Equal(t, "(CursorNotFound) cursor id 42 not found", getMore["mongodb.error"])
+
+	assert.Equal(t, "mongodb.find", find["name"])
+	assert.Equal(t, "mongodb", find["meta.type"])
+	assert.Equal(t, "find", find["db.command"])
+	assert.Equal(t, "app", find["mongodb.database"])
+	assert.Equal(t, "users", find["mongodb.collection"])
+	assert.NotContains(t, find, "mongodb.error")
+	assert.Equal(t, req["trace.span_id"], find["trace.parent_id"])
+	assert.Equal(t, req["trace.span_id"], getMore["trace.parent_id"])
+
+	assert.Equal(t, 5.0, req["rollup.mongodb.duration_ms"])
+	assert.Equal(t, 2.0, req["rollup.mongodb.call_count"])
+}
+
+func TestCollection(t *testing.T) {
+	assert.Equal(t, "users", collection("insert", command(t, bson.D{{Key: "insert", Value: "users"}})))
+	assert.Equal(t, "", collection("aggregate", command(t, bson.D{{Key: "aggregate", Value: 1}})))
+	assert.Equal(t, "", collection("ping", command(t, bson.D{{Key: "ping", Value: 1}})))
+	assert.Equal(t, "", collection("find", nil))
+}
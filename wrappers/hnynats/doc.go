@@ -0,0 +1,25 @@
+// Package hnynats traces messages published and received with nats.go, so a
+// flow of messages between services shows up as a single trace.
+//
+// Publish messages, or send requests, with the context of the work they are
+// sent for, so their spans are children of that work's span:
+//
+//	err := hnynats.Publish(r.Context(), nc, &nats.Msg{Subject: "orders.created", Data: order})
+//	reply, err := hnynats.Request(r.Context(), nc, &nats.Msg{Subject: "prices.quote", Data: q})
+//
+// Each message gets a nats.publish or nats.request span with nats.subject and
+// nats.payload_size, and its trace context is added to the message's
+// headers, which requires a server that supports them.
+//
+// Wrap the handlers of subscriptions so each message received gets a span,
+// sent once the handler returns:
+//
+//	nc.QueueSubscribe("orders.created", "billing", hnynats.WrapHandler(func(ctx context.Context, msg *nats.Msg) {
+//		...
+//	}))
+//
+// The span is the root of a new trace, or continues the publisher's trace if
+// the message has a trace header. It records nats.subject, nats.queue_group
+// for queue subscriptions, nats.reply for requests and nats.payload_size.
+// Messages read with NextMsg can be traced with StartMessageSpan instead.
+package hnynats
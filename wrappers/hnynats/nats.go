@@ -0,0 +1,108 @@
+package hnynats
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// headerKey is the message header that carries the trace context.
+const headerKey = propagation.TracePropagationHTTPHeader
+
+// Publish publishes msg with nc, with a span that is a child of the span in
+// ctx, and adds that span's trace context to the message's headers. The
+// message passed in is not modified.
+func Publish(ctx context.Context, nc *nats.Conn, msg *nats.Msg) error {
+	span, traced := startPublish(ctx, "nats.publish", msg)
+	err := nc.PublishMsg(traced)
+	finish(span, err)
+	return err
+}
+
+// Request sends msg with nc and waits for a reply until ctx is done, like
+// Publish. The span covers the wait for the reply, and the trace context is
+// passed on to the service replying.
+func Request(ctx context.Context, nc *nats.Conn, msg *nats.Msg) (*nats.Msg, error) {
+	span, traced := startPublish(ctx, "nats.request", msg)
+	reply, err := nc.RequestMsgWithContext(ctx, traced)
+	if err == nil {
+		span.AddField("nats.reply_size", len(reply.Data))
+	}
+	finish(span, err)
+	return reply, err
+}
+
+func startPublish(ctx context.Context, name string, msg *nats.Msg) (*trace.Span, *nats.Msg) {
+	_, span := common.StartChildSpan(ctx)
+	span.AddField("meta.type", "nats")
+	span.AddField("name", name)
+	span.AddField("nats.subject", msg.Subject)
+	span.AddField("nats.payload_size", len(msg.Data))
+	if msg.Reply != "" {
+		span.AddField("nats.reply", msg.Reply)
+	}
+	traced := &nats.Msg{
+		Subject: msg.Subject,
+		Reply:   msg.Reply,
+		Data:    msg.Data,
+		Header:  withTraceHeader(msg.Header, span.SerializeHeaders()),
+	}
+	return span, traced
+}
+
+func finish(span *trace.Span, err error) {
+	if err != nil {
+		span.AddField("nats.error", err.Error())
+	}
+	span.Send()
+}
+
+// withTraceHeader returns a copy of header with the trace header set.
+func withTraceHeader(header nats.Header, value string) nats.Header {
+	traced := make(nats.Header, len(header)+1)
+	for k, v := range header {
+		traced[k] = v
+	}
+	traced.Set(headerKey, value)
+	return traced
+}
+
+// WrapHandler returns a message handler for a subscription that calls
+// handler with a context holding a span for the message, which is sent once
+// handler returns.
+func WrapHandler(handler func(context.Context, *nats.Msg)) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		ctx, span := StartMessageSpan(context.Background(), msg)
+		defer span.Send()
+		handler(ctx, msg)
+	}
+}
+
+// StartMessageSpan starts a trace for handling msg, continuing the
+// publisher's trace if msg has a trace header, and returns its root span.
+// The caller must send the span.
+func StartMessageSpan(ctx context.Context, msg *nats.Msg) (context.Context, *trace.Span) {
+	var prop *propagation.PropagationContext
+	var propErr error
+	if header := msg.Header.Get(headerKey); header != "" {
+		prop, propErr = propagation.UnmarshalHoneycombTraceContext(header)
+	}
+	ctx, tr := trace.NewTraceFromPropagationContext(ctx, prop)
+	span := tr.GetRootSpan()
+	common.AddPropagationError(span, propErr)
+	span.AddField("meta.type", "nats")
+	span.AddField("name", "nats.consume")
+	span.AddField("nats.subject", msg.Subject)
+	span.AddField("nats.payload_size", len(msg.Data))
+	if msg.Reply != "" {
+		span.AddField("nats.reply", msg.Reply)
+	}
+	if msg.Sub != nil && msg.Sub.Queue != "" {
+		span.AddField("nats.queue_group", msg.Sub.Queue)
+	}
+	return ctx, span
+}
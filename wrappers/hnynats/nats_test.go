@@ -0,0 +1,221 @@
+package hnynats
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/beelinetest"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer speaks enough of the NATS protocol for a single client to
+// subscribe to subjects and publish messages to them, with headers.
+type fakeServer struct {
+	lock sync.Mutex
+	subs map[string][]string // subject to subscription ids
+}
+
+func startFakeServer(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{subs: map[string][]string{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return "nats://" + l.Addr().String(), func() { l.Close() }
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var wlock sync.Mutex
+	write := func(format string, args ...interface{}) {
+		wlock.Lock()
+		defer wlock.Unlock()
+		fmt.Fprintf(conn, format, args...)
+	}
+	write("INFO {\"server_id\":\"fake\",\"version\":\"2.10.0\",\"proto\":1,\"headers\":true,\"max_payload\":1048576}\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "PING":
+			write("PONG\r\n")
+		case "SUB":
+			// SUB <subject> [queue group] <sid>
+			s.lock.Lock()
+			s.subs[fields[1]] = append(s.subs[fields[1]], fields[len(fields)-1])
+			s.lock.Unlock()
+		case "PUB", "HPUB":
+			// PUB <subject> [reply-to] <bytes>, or
+			// HPUB <subject> [reply-to] <header bytes> <total bytes>
+			sizes := 1
+			if fields[0] == "HPUB" {
+				sizes = 2
+			}
+			total, _ := strconv.Atoi(fields[len(fields)-1])
+			data := make([]byte, total+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			reply := ""
+			if len(fields) == 3+sizes {
+				reply = fields[2] + " "
+			}
+			for _, sid := range s.matching(fields[1]) {
+				write("%s %s %s %s%s\r\n%s", strings.Replace(fields[0], "PUB", "MSG", 1), fields[1], sid, reply,
+					strings.Join(fields[len(fields)-sizes:], " "), data)
+			}
+		}
+	}
+}
+
+// matching returns the subscriptions to subject, supporting a * as the last
+// token of the subscription's subject, as used for request inboxes.
+func (s *fakeServer) matching(subject string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var sids []string
+	for sub, ids := range s.subs {
+		if sub == subject || strings.HasSuffix(sub, ".*") && strings.HasPrefix(subject, sub[:len(sub)-1]) {
+			sids = append(sids, ids...)
+		}
+	}
+	return sids
+}
+
+func connect(t *testing.T) (*nats.Conn, func()) {
+	url, stop := startFakeServer(t)
+	nc, err := nats.Connect(url)
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	return nc, func() {
+		nc.Close()
+		stop()
+	}
+}
+
+func TestPublishAndSubscribe(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	nc, stop := connect(t)
+	defer stop()
+
+	received := make(chan *nats.Msg, 1)
+	_, err := nc.QueueSubscribe("orders.created", "billing", WrapHandler(func(ctx context.Context, msg *nats.Msg) {
+		beeline.AddField(ctx, "order", string(msg.Data))
+		received <- msg
+	}))
+	assert.NoError(t, err)
+	assert.NoError(t, nc.Flush())
+
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	msg := &nats.Msg{Subject: "orders.created", Data: []byte("42"), Header: nats.Header{"Kind": []string{"new"}}}
+	assert.NoError(t, Publish(ctx, nc, msg))
+	assert.NotContains(t, msg.Header, headerKey, "the message passed in should not be modified")
+
+	select {
+	case got := <-received:
+		assert.Equal(t, "new", got.Header.Get("Kind"))
+		assert.NotEmpty(t, got.Header.Get(headerKey))
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message was never received")
+	}
+	root.Send()
+
+	// the handler's span is sent once it returns
+	assert.Eventually(t, func() bool { return len(rec.Events()) == 3 }, 5*time.Second, time.Millisecond)
+	var publish, consume, req map[string]interface{}
+	for _, ev := range rec.Events() {
+		switch ev["name"] {
+		case "nats.publish":
+			publish = ev
+		case "nats.consume":
+			consume = ev
+		case "request":
+			req = ev
+		}
+	}
+	if !assert.NotNil(t, publish) || !assert.NotNil(t, consume) || !assert.NotNil(t, req) {
+		return
+	}
+	assert.Equal(t, "nats", publish["meta.type"])
+	assert.Equal(t, "orders.created", publish["nats.subject"])
+	assert.Equal(t, 2, publish["nats.payload_size"])
+	assert.Equal(t, req["trace.span_id"], publish["trace.parent_id"])
+
+	assert.Equal(t, "orders.created", consume["nats.subject"])
+	assert.Equal(t, "billing", consume["nats.queue_group"])
+	assert.Equal(t, 2, consume["nats.payload_size"])
+	assert.Equal(t, "42", consume["app.order"])
+	assert.Equal(t, req["trace.trace_id"], consume["trace.trace_id"], "the subscriber should continue the publisher's trace")
+	assert.Equal(t, publish["trace.span_id"], consume["trace.parent_id"])
+}
+
+func TestRequest(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	nc, stop := connect(t)
+	defer stop()
+
+	_, err := nc.Subscribe("prices.quote", WrapHandler(func(ctx context.Context, msg *nats.Msg) {
+		msg.Respond([]byte("9.99"))
+	}))
+	assert.NoError(t, err)
+	assert.NoError(t, nc.Flush())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := Request(ctx, nc, &nats.Msg{Subject: "prices.quote", Data: []byte("widget")})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "9.99", string(reply.Data))
+	}
+
+	assert.Eventually(t, func() bool { return len(rec.Events()) == 2 }, 5*time.Second, time.Millisecond)
+	for _, ev := range rec.Events() {
+		if ev["name"] == "nats.request" {
+			assert.Equal(t, 4, ev["nats.reply_size"])
+		} else {
+			assert.Equal(t, "nats.consume", ev["name"])
+			assert.NotEmpty(t, ev["nats.reply"])
+		}
+	}
+}
+
+func TestStartMessageSpanBadHeader(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	msg := &nats.Msg{Subject: "events", Header: nats.Header{}}
+	msg.Header.Set(headerKey, "1;not a trace")
+	_, span := StartMessageSpan(context.Background(), msg)
+	span.Send()
+
+	evs := rec.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Contains(t, evs[0], "meta.propagation_error")
+		assert.NotContains(t, evs[0], "nats.queue_group")
+	}
+}
@@ -0,0 +1,18 @@
+// Package hnynegroni has middleware to use with the negroni stack.
+//
+// Summary
+//
+// hnynegroni provides a negroni.Handler that starts a span for every request
+// that passes through the stack, or a child span if the request already has
+// one. It is best added first, with negroni.Use, so that the span covers the
+// rest of the stack.
+//
+// negroni wraps the http.ResponseWriter it gives each middleware in its own
+// negroni.ResponseWriter, and middleware further down the stack may depend on
+// that. The handler leaves that writer in place and reads the status and size
+// of the response from it.
+//
+// For a complete example showing this wrapper in use, please see the examples in
+// https://github.com/honeycombio/beeline-go/tree/master/examples
+//
+package hnynegroni
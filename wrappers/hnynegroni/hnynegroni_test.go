@@ -0,0 +1,21 @@
+package hnynegroni
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/urfave/negroni"
+)
+
+func ExampleNew() {
+	// assume you have a mux with your handlers
+	var mux *http.ServeMux
+
+	n := negroni.New()
+	// add the beeline first so its span covers the rest of the stack
+	n.Use(New())
+	n.Use(negroni.NewRecovery())
+	n.UseHandler(mux)
+
+	log.Fatal(http.ListenAndServe(":8080", n))
+}
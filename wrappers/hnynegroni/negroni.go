@@ -0,0 +1,52 @@
+package hnynegroni
+
+import (
+	"net/http"
+
+	"github.com/honeycombio/beeline-go/wrappers/common"
+	"github.com/urfave/negroni"
+)
+
+// New returns a negroni.Handler that adds Honeycomb instrumentation to the
+// requests passing through the stack.
+func New() negroni.Handler {
+	return negroni.HandlerFunc(serveHTTP)
+}
+
+func serveHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if common.IgnoreRequest(r) {
+		next(w, r)
+		return
+	}
+	// get a new context with our trace from the request, and add common fields
+	ctx, span := common.StartSpanOrTraceFromHTTP(r)
+	defer span.Send()
+	common.SetResponseHeaders(w.Header(), r, span)
+	// push the context with our trace and span on to the request
+	r = r.WithContext(ctx)
+
+	// negroni's own writer already tracks the status, and replacing it would
+	// hide it from middleware further down the stack
+	if nw, ok := w.(negroni.ResponseWriter); ok {
+		next(nw, r)
+		status := nw.Status()
+		if status == 0 {
+			status = 200
+		}
+		span.AddField("response.status_code", status)
+		span.AddField("response.size", nw.Size())
+		return
+	}
+
+	// replace the writer with our wrapper to catch the status code
+	wrappedWriter := common.NewResponseWriter(w)
+	defer wrappedWriter.RecordPanic(span)
+	next(wrappedWriter.Wrapped, r)
+	if wrappedWriter.Status == 0 {
+		wrappedWriter.Status = 200
+	}
+	span.AddField("response.status_code", wrappedWriter.Status)
+	wrappedWriter.AddResponseFields(span)
+	wrappedWriter.AddAnomalyFields(span)
+	common.AddContextFields(ctx, span)
+}
@@ -0,0 +1,77 @@
+package hnynegroni
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/negroni"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func TestNegroniMiddleware(t *testing.T) {
+	mo := setupLibhoney(t)
+
+	var sawNegroniWriter bool
+	n := negroni.New()
+	n.Use(New())
+	// downstream middleware that relies on negroni's writer
+	n.UseFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		_, sawNegroniWriter = w.(negroni.ResponseWriter)
+		next(w, r)
+	})
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		beeline.AddField(r.Context(), "user_id", 42)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})
+
+	r, _ := http.NewRequest("POST", "/users", nil)
+	w := httptest.NewRecorder()
+	n.ServeHTTP(w, r)
+
+	assert.True(t, sawNegroniWriter, "downstream middleware should still get negroni's writer")
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs), "one event is created with one request through the middleware") {
+		fields := evs[0].Data
+		assert.Equal(t, http.StatusCreated, fields["response.status_code"])
+		assert.Equal(t, 7, fields["response.size"])
+		assert.Equal(t, 42, fields["app.user_id"])
+		assert.Equal(t, "POST", fields["request.method"])
+	}
+}
+
+func TestNegroniMiddlewareDefaultStatus(t *testing.T) {
+	mo := setupLibhoney(t)
+
+	n := negroni.New(New())
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	r, _ := http.NewRequest("GET", "/", nil)
+	n.ServeHTTP(httptest.NewRecorder(), r)
+
+	// and outside of a negroni stack
+	New().ServeHTTP(httptest.NewRecorder(), r, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, 200, evs[0].Data["response.status_code"], "nothing written means an implicit 200")
+		assert.Equal(t, http.StatusTeapot, evs[1].Data["response.status_code"])
+	}
+}
@@ -0,0 +1,73 @@
+package hnynet
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// DialContextFunc is the signature of net.Dialer.DialContext, also used by
+// http.Transport.DialContext and many client libraries.
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// WrapDialContext returns a dial function that calls dial in a span that is a
+// child of the span in the context passed to it.
+func WrapDialContext(dial DialContextFunc) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		start := time.Now()
+		_, span := common.StartChildSpan(ctx)
+		defer span.Send()
+		span.AddField("meta.type", "net_dial")
+		span.AddField("name", "net.dial")
+		span.AddField("net.network", network)
+		span.AddField("net.address", address)
+
+		conn, err := dial(ctx, network, address)
+
+		dur := float64(time.Since(start)) / float64(time.Millisecond)
+		span.AddRollupField("net.connect_duration_ms", dur)
+		span.AddRollupField("net.connect_count", 1)
+		if err != nil {
+			span.AddField("net.error", err.Error())
+			return conn, err
+		}
+		if addr := conn.LocalAddr(); addr != nil {
+			span.AddField("net.local_address", addr.String())
+		}
+		if addr := conn.RemoteAddr(); addr != nil {
+			span.AddField("net.remote_address", addr.String())
+		}
+		return conn, err
+	}
+}
+
+// Dialer is a *net.Dialer that emits a span for each connection it makes.
+type Dialer struct {
+	*net.Dialer
+	dial DialContextFunc
+}
+
+// WrapDialer wraps d. If d is nil, a zero net.Dialer is used.
+func WrapDialer(d *net.Dialer) *Dialer {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	return &Dialer{
+		Dialer: d,
+		dial:   WrapDialContext(d.DialContext),
+	}
+}
+
+// DialContext connects to the address on the named network, like
+// net.Dialer.DialContext, in a span.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d.dial(ctx, network, address)
+}
+
+// Dial connects to the address on the named network. It has no context, so
+// the span it sends starts a new trace; prefer DialContext.
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.dial(context.Background(), network, address)
+}
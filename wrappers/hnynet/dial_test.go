@@ -0,0 +1,58 @@
+package hnynet
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialContext(t *testing.T) {
+	mo := setupLibhoney(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	conn, err := WrapDialer(nil).DialContext(ctx, "tcp", ln.Addr().String())
+	if assert.NoError(t, err) {
+		conn.Close()
+	}
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "net.dial", fields["name"])
+		assert.Equal(t, "tcp", fields["net.network"])
+		assert.Equal(t, ln.Addr().String(), fields["net.address"])
+		assert.Equal(t, ln.Addr().String(), fields["net.remote_address"])
+		assert.Contains(t, fields, "net.local_address")
+		assert.Equal(t, evs[1].Data["trace.span_id"], fields["trace.parent_id"])
+		assert.Equal(t, float64(1), evs[1].Data["rollup.net.connect_count"])
+	}
+}
+
+func TestDialFailure(t *testing.T) {
+	mo := setupLibhoney(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	// nothing is listening once this is closed
+	ln.Close()
+
+	_, err = WrapDialer(nil).Dial("tcp", addr)
+	assert.Error(t, err)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, err.Error(), evs[0].Data["net.error"])
+		assert.NotContains(t, evs[0].Data, "net.remote_address")
+	}
+}
@@ -0,0 +1,21 @@
+// Package hnynet emits spans for network operations that don't go through
+// net/http, such as connections made by custom protocol clients.
+//
+// Wrap a dial function, or use a *hnynet.Dialer in place of a *net.Dialer, to
+// emit a span for every connection attempt with the address dialed, how long
+// connecting took and whether it succeeded:
+//
+//	dialer := hnynet.WrapDialer(&net.Dialer{Timeout: 5 * time.Second})
+//	conn, err := dialer.DialContext(ctx, "tcp", "cache.internal:6379")
+//
+// Time spent connecting is summed into `rollup.net.connect_duration_ms` and
+// `rollup.net.connect_count` on the root span.
+//
+// Similarly, a *hnynet.Resolver emits a span for every DNS lookup, with the
+// name looked up, the number of answers and any error, so slow or failing DNS
+// shows up in traces. Lookups are summed into `rollup.dns.duration_ms` and
+// `rollup.dns.lookup_count`.
+//
+//	resolver := hnynet.WrapResolver(nil)
+//	addrs, err := resolver.LookupHost(ctx, "api.internal")
+package hnynet
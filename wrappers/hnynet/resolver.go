@@ -0,0 +1,112 @@
+package hnynet
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Resolver is a *net.Resolver whose lookups each emit a span with the name
+// looked up, how many answers came back and how long it took.
+type Resolver struct {
+	*net.Resolver
+}
+
+// WrapResolver wraps r. If r is nil, net.DefaultResolver is used.
+func WrapResolver(r *net.Resolver) *Resolver {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return &Resolver{Resolver: r}
+}
+
+// LookupHost looks up the given host, like net.Resolver.LookupHost, in a span.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	l := startLookup(ctx, "host", host)
+	addrs, err := r.Resolver.LookupHost(ctx, host)
+	l.finish(len(addrs), err)
+	return addrs, err
+}
+
+// LookupIPAddr looks up the IP addresses of host, like
+// net.Resolver.LookupIPAddr, in a span.
+func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	l := startLookup(ctx, "ip", host)
+	addrs, err := r.Resolver.LookupIPAddr(ctx, host)
+	l.finish(len(addrs), err)
+	return addrs, err
+}
+
+// LookupCNAME returns the canonical name for host, like
+// net.Resolver.LookupCNAME, in a span.
+func (r *Resolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	l := startLookup(ctx, "cname", host)
+	cname, err := r.Resolver.LookupCNAME(ctx, host)
+	answers := 0
+	if cname != "" {
+		answers = 1
+	}
+	l.finish(answers, err)
+	return cname, err
+}
+
+// LookupSRV looks up SRV records, like net.Resolver.LookupSRV, in a span. The
+// query recorded is the full name that was looked up.
+func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	query := name
+	if service != "" || proto != "" {
+		query = "_" + service + "._" + proto + "." + name
+	}
+	l := startLookup(ctx, "srv", query)
+	cname, addrs, err := r.Resolver.LookupSRV(ctx, service, proto, name)
+	l.finish(len(addrs), err)
+	return cname, addrs, err
+}
+
+// LookupMX looks up MX records for name, like net.Resolver.LookupMX, in a
+// span.
+func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	l := startLookup(ctx, "mx", name)
+	mxs, err := r.Resolver.LookupMX(ctx, name)
+	l.finish(len(mxs), err)
+	return mxs, err
+}
+
+// LookupTXT looks up TXT records for name, like net.Resolver.LookupTXT, in a
+// span.
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	l := startLookup(ctx, "txt", name)
+	txts, err := r.Resolver.LookupTXT(ctx, name)
+	l.finish(len(txts), err)
+	return txts, err
+}
+
+type lookup struct {
+	span  *trace.Span
+	start time.Time
+}
+
+func startLookup(ctx context.Context, kind, query string) *lookup {
+	_, span := common.StartChildSpan(ctx)
+	span.AddField("meta.type", "dns")
+	span.AddField("name", "dns.lookup")
+	span.AddField("dns.type", kind)
+	span.AddField("dns.query", query)
+	return &lookup{span: span, start: time.Now()}
+}
+
+func (l *lookup) finish(answers int, err error) {
+	l.span.AddField("dns.answer_count", answers)
+	if err != nil {
+		l.span.AddField("dns.error", err.Error())
+		if dnsErr, ok := err.(*net.DNSError); ok {
+			l.span.AddField("dns.timeout", dnsErr.IsTimeout)
+		}
+	}
+	l.span.AddRollupField("dns.duration_ms", float64(time.Since(l.start))/float64(time.Millisecond))
+	l.span.AddRollupField("dns.lookup_count", 1)
+	l.span.Send()
+}
@@ -0,0 +1,55 @@
+package hnynet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupHost(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	addrs, err := WrapResolver(nil).LookupHost(ctx, "localhost")
+	if err != nil {
+		t.Skipf("localhost does not resolve here: %s", err)
+	}
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "dns.lookup", fields["name"])
+		assert.Equal(t, "host", fields["dns.type"])
+		assert.Equal(t, "localhost", fields["dns.query"])
+		assert.Equal(t, len(addrs), fields["dns.answer_count"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], fields["trace.parent_id"])
+		assert.Equal(t, float64(1), evs[1].Data["rollup.dns.lookup_count"])
+	}
+}
+
+func TestLookupFailure(t *testing.T) {
+	mo := setupLibhoney(t)
+	// a resolver that can't reach any DNS server
+	r := WrapResolver(&net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errors.New("no DNS here")
+		},
+	})
+	_, _, err := r.LookupSRV(context.Background(), "xmpp-server", "tcp", "example.invalid")
+	assert.Error(t, err)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "srv", fields["dns.type"])
+		assert.Equal(t, "_xmpp-server._tcp.example.invalid", fields["dns.query"])
+		assert.Equal(t, 0, fields["dns.answer_count"])
+		assert.Equal(t, err.Error(), fields["dns.error"])
+		assert.Contains(t, fields, "dns.timeout")
+	}
+}
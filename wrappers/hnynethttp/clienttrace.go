@@ -0,0 +1,177 @@
+package hnynethttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// attemptKey holds the attempt set by WithRetryAttempt and
+// WithHedgedRequest in a request's context.
+type attemptKey struct{}
+
+type attempt struct {
+	number int
+	hedged bool
+}
+
+// WithRetryAttempt returns a context for the nth try at an outbound
+// call, counting from 1, for code that retries failed calls. The spans of
+// requests made with it record request.attempt, and request.retry for
+// attempts after the first, so retries can be told apart from new calls.
+func WithRetryAttempt(ctx context.Context, n int) context.Context {
+	a, _ := ctx.Value(attemptKey{}).(attempt)
+	a.number = n
+	return context.WithValue(ctx, attemptKey{}, a)
+}
+
+// WithHedgedRequest returns a context for a hedged request, one sent while
+// an earlier request for the same call is still outstanding in case that one
+// is slow. The spans of requests made with it record request.hedged. The
+// request that loses the race is usually cancelled, and records the error.
+func WithHedgedRequest(ctx context.Context) context.Context {
+	a, _ := ctx.Value(attemptKey{}).(attempt)
+	a.hedged = true
+	return context.WithValue(ctx, attemptKey{}, a)
+}
+
+func addAttemptFields(ctx context.Context, ev interface{ AddField(string, interface{}) }) {
+	a, ok := ctx.Value(attemptKey{}).(attempt)
+	if !ok {
+		return
+	}
+	if a.number > 0 {
+		ev.AddField("request.attempt", a.number)
+		ev.AddField("request.retry", a.number > 1)
+	}
+	if a.hedged {
+		ev.AddField("request.hedged", true)
+	}
+}
+
+// connTimings uses httptrace to time the parts of an outbound request, so
+// that a slow call can be put down to getting a connection or to the server.
+// The callbacks can be made from the transport's dialing goroutines, so the
+// times are guarded by mu.
+type connTimings struct {
+	mu sync.Mutex
+
+	getConns     int
+	getConn      time.Time
+	gotConn      time.Time
+	dnsStart     time.Time
+	dns          time.Duration
+	connectStart time.Time
+	connect      time.Duration
+	tlsStart     time.Time
+	tls          time.Duration
+	wroteRequest time.Time
+	firstByte    time.Time
+	info         httptrace.GotConnInfo
+}
+
+func (ct *connTimings) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			ct.getConns++
+			ct.getConn = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			ct.gotConn = time.Now()
+			ct.info = info
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			ct.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			ct.dns = time.Since(ct.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			// with more than one address, dials race; time from the first
+			if ct.connectStart.IsZero() {
+				ct.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			if err == nil && ct.connect == 0 {
+				ct.connect = time.Since(ct.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			ct.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			ct.tls = time.Since(ct.tlsStart)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			ct.wroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			ct.firstByte = time.Now()
+		},
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// addFields records the timings on the span or event for the request. Time
+// spent getting a connection, including any DNS lookup, dial and TLS
+// handshake, is in request.get_conn_ms; the time from the request being
+// written to the first byte of the response, which is the server's, is in
+// request.server_ms. Requests the transport sent again on a new connection
+// after a reused one failed have request.transport_retries.
+func (ct *connTimings) addFields(ev interface{ AddField(string, interface{}) }) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if ct.getConns == 0 {
+		// the wrapped round tripper isn't a transport that traces
+		return
+	}
+	if ct.getConns > 1 {
+		ev.AddField("request.transport_retries", ct.getConns-1)
+	}
+	if ct.gotConn.IsZero() {
+		return
+	}
+	ev.AddField("request.get_conn_ms", durationMs(ct.gotConn.Sub(ct.getConn)))
+	ev.AddField("request.conn_reused", ct.info.Reused)
+	if ct.info.WasIdle {
+		ev.AddField("request.conn_idle_ms", durationMs(ct.info.IdleTime))
+	}
+	if ct.dns > 0 {
+		ev.AddField("request.dns_ms", durationMs(ct.dns))
+	}
+	if ct.connect > 0 {
+		ev.AddField("request.connect_ms", durationMs(ct.connect))
+	}
+	if ct.tls > 0 {
+		ev.AddField("request.tls_handshake_ms", durationMs(ct.tls))
+	}
+	if !ct.wroteRequest.IsZero() && !ct.firstByte.IsZero() {
+		ev.AddField("request.server_ms", durationMs(ct.firstByte.Sub(ct.wroteRequest)))
+	}
+}
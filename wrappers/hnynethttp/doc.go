@@ -11,6 +11,48 @@ For best results, use WrapHandler to wrap the mux passed to http.ListenAndServe
 Wrapping individual Handlers or HandleFuncs will generate events only for the
 endpoints that are wrapped; 404s, for example, will not generate events.
 
+Built with Go 1.23 or later, requests routed by an http.ServeMux record the
+pattern that matched, eg "GET /users/{id}", as handler.route, so requests can
+be broken down by route without a third party router. The value of each
+wildcard in the pattern is added as handler.vars.<name>.
+
+To see which middleware a slow request spent its time in, wrap each
+middleware with WrapMiddleware, or build the chain with WrapChain, inside
+WrapHandler. Each middleware then gets a span of its own.
+
+To trace the calls your service makes to others, wrap the client's transport
+with WrapRoundTripper, or the client itself with WrapClient. Each request made
+with a context holding a span gets a child span recording the URL, method,
+status and duration of the call, and carries the trace headers so the service
+called continues the trace.
+
+	client := hnynethttp.WrapClient(&http.Client{Timeout: 5 * time.Second})
+	req, _ := http.NewRequest("GET", "http://billing/invoices", nil)
+	resp, err := client.Do(req.WithContext(r.Context()))
+
+The span also records how long the call spent getting a connection, with its
+DNS, connect and TLS handshake times, whether the connection was reused, and
+the server's time to respond, so a slow call can be put down to one or the
+other. Code that retries or hedges calls marks each request's context with
+WithRetryAttempt or WithHedgedRequest, so their spans say which try they were.
+
+To make every span of a request's trace queryable by customer, set
+beeline.Config.TraceFieldsFunc to pull the user, account or plan out of the
+request, and add TraceFieldsMiddleware to the chain after your
+authentication middleware, so the function sees what it put in the request's
+context:
+
+	beeline.Init(beeline.Config{
+		TraceFieldsFunc: func(r *http.Request) map[string]interface{} {
+			claims, _ := r.Context().Value(claimsKey{}).(*Claims)
+			if claims == nil {
+				return nil
+			}
+			return map[string]interface{}{"user.id": claims.Subject, "account.plan": claims.Plan}
+		},
+	})
+	http.ListenAndServe(":8080", hnynethttp.WrapHandler(auth(hnynethttp.TraceFieldsMiddleware(mux))))
+
 For a complete example showing this wrapper in use, please see the examples in
 https://github.com/honeycombio/beeline-go/tree/master/examples
 
@@ -0,0 +1,95 @@
+package hnynethttp
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// middlewareKey identifies the state of one wrapped middleware in a request's
+// context. Each call to WrapMiddleware gets its own key so that nested
+// middlewares don't see each other's state.
+type middlewareKey struct{}
+
+// middlewareState follows one request through a wrapped middleware.
+type middlewareState struct {
+	calledNext bool
+	inNext     time.Duration
+}
+
+// WrapMiddleware wraps a middleware in the common func(http.Handler)
+// http.Handler form, as used by gorilla/mux, alice and others, so that each
+// request through it gets a span of its own. The middleware's span covers
+// everything it does, including the handlers after it in the chain, which
+// become its children; middleware.self_duration_ms records the time spent in
+// the middleware itself. If name is empty, the middleware's function name is
+// used.
+//
+// Requests must already have a span, eg from WrapHandler, for one to be
+// created.
+func WrapMiddleware(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	if name == "" {
+		name = runtime.FuncForPC(reflect.ValueOf(mw).Pointer()).Name()
+	}
+	key := &middlewareKey{}
+	return func(next http.Handler) http.Handler {
+		inner := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state, ok := r.Context().Value(key).(*middlewareState)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			state.calledNext = true
+			start := time.Now()
+			next.ServeHTTP(w, r)
+			state.inNext += time.Since(start)
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent := trace.GetSpanFromContext(r.Context())
+			if parent == nil {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			ctx, span := parent.CreateChild(r.Context())
+			defer span.Send()
+			span.AddField("meta.type", "middleware")
+			span.AddField("name", name)
+			span.AddField("middleware.name", name)
+
+			state := &middlewareState{}
+			start := time.Now()
+			inner.ServeHTTP(w, r.WithContext(context.WithValue(ctx, key, state)))
+			self := float64(time.Since(start)-state.inNext) / float64(time.Millisecond)
+			span.AddField("middleware.called_next", state.calledNext)
+			span.AddField("middleware.self_duration_ms", self)
+			span.AddRollupField("middleware.duration_ms", self)
+		})
+	}
+}
+
+// WrapChain applies the middlewares to handler, the first given being the
+// outermost, like alice.New(mws...).Then(handler), with each one wrapped by
+// WrapMiddleware and named after its function.
+func WrapChain(handler http.Handler, mws ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = WrapMiddleware("", mws[i])(handler)
+	}
+	return handler
+}
+
+// TraceFieldsMiddleware adds the fields beeline.Config.TraceFieldsFunc returns
+// for each request to the request's trace, then calls next. Put it after any
+// middleware that authenticates the request, inside WrapHandler, so that the
+// function sees the user it found in the request's context.
+func TraceFieldsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		common.AddTraceFieldsFromRequest(r)
+		next.ServeHTTP(w, r)
+	})
+}
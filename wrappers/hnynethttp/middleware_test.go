@@ -0,0 +1,143 @@
+package hnynethttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func slowMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func passMiddleware(next http.Handler) http.Handler {
+	return next
+}
+
+func rejectMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+}
+
+func TestWrapChain(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	handler := WrapHandler(WrapChain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}), slowMiddleware, passMiddleware))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	evs := mo.Events()
+	if !assert.Equal(t, 3, len(evs)) {
+		return
+	}
+	// spans are sent innermost first
+	pass, slow, request := evs[0].Data, evs[1].Data, evs[2].Data
+	assert.Equal(t, "middleware", slow["meta.type"])
+	assert.Contains(t, slow["middleware.name"], "slowMiddleware")
+	assert.Equal(t, true, slow["middleware.called_next"])
+	assert.Equal(t, request["trace.span_id"], slow["trace.parent_id"])
+	self := slow["middleware.self_duration_ms"].(float64)
+	assert.True(t, self >= 20, "self duration %v should include the sleep", self)
+	assert.True(t, slow["duration_ms"].(float64) >= self+10, "the span should include the handler")
+
+	assert.Equal(t, slow["trace.span_id"], pass["trace.parent_id"])
+	assert.True(t, pass["middleware.self_duration_ms"].(float64) < 10)
+	assert.Contains(t, request, "rollup.middleware.duration_ms")
+}
+
+func TestWrapMiddlewareNotCallingNext(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	called := false
+	handler := WrapHandler(WrapMiddleware("csrf", rejectMiddleware)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.False(t, called)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "csrf", evs[0].Data["name"])
+		assert.Equal(t, false, evs[0].Data["middleware.called_next"])
+		assert.Equal(t, http.StatusForbidden, evs[1].Data["response.status_code"])
+	}
+}
+
+func TestWrapMiddlewareWithoutSpan(t *testing.T) {
+	called := false
+	handler := WrapChain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), slowMiddleware)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.True(t, called)
+}
+
+type userKey struct{}
+
+func TestTraceFieldsMiddleware(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{
+		Client: client,
+		TraceFieldsFunc: func(r *http.Request) map[string]interface{} {
+			user, _ := r.Context().Value(userKey{}).(string)
+			if user == "" {
+				return nil
+			}
+			return map[string]interface{}{"user.id": user, "account.plan": "pro"}
+		},
+	})
+	defer beeline.Init(beeline.Config{Client: client})
+
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, "ada")))
+		})
+	}
+	handler := WrapHandler(auth(TraceFieldsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := beeline.StartSpan(r.Context(), "work")
+		span.Send()
+	}))))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		for _, ev := range evs {
+			assert.Equal(t, "ada", ev.Data["app.user.id"], "every span of the trace should have the user")
+			assert.Equal(t, "pro", ev.Data["app.account.plan"])
+		}
+	}
+}
@@ -3,31 +3,87 @@ package hnynethttp
 import (
 	"context"
 	"net/http"
+	"net/http/httptrace"
 	"reflect"
 	"runtime"
+	"time"
 
-	"github.com/honeycombio/beeline-go/propagation"
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/client"
 	"github.com/honeycombio/beeline-go/timer"
 	"github.com/honeycombio/beeline-go/trace"
 	"github.com/honeycombio/beeline-go/wrappers/common"
-	libhoney "github.com/honeycombio/libhoney-go"
 )
 
+// Option configures a handler wrapped by WrapHandler or WrapHandlerFunc.
+type Option = common.HandlerOption
+
+// WithRouteName names the handler's spans name instead of after the handler,
+// and records it as handler.route.
+func WithRouteName(name string) Option {
+	return common.WithRouteName(name)
+}
+
+// WithSampleRate samples the traces of requests to the handler at rate,
+// instead of at the rate the beeline was configured with.
+func WithSampleRate(rate uint) Option {
+	return common.WithSampleRate(rate)
+}
+
+// WithFields adds fields to the span for every request to the handler.
+func WithFields(fields map[string]interface{}) Option {
+	return common.WithFields(fields)
+}
+
+// WithBeeline records requests to the handler, and sends their traces, with
+// b's client and config instead of those of the beeline set up with Init.
+func WithBeeline(b *beeline.Beeline) Option {
+	return func(c *common.HandlerConfig) {
+		common.WithClient(b.Client())(c)
+		common.WithTraceConfig(b.TraceConfig())(c)
+	}
+}
+
+// WithStreaming is for handlers that stream their response for a long time,
+// such as server-sent events or long polls. A span is sent as soon as each
+// request starts, and another every interval with the number of bytes
+// streamed so far, ahead of the request's own span once the handler returns.
+func WithStreaming(interval time.Duration) Option {
+	return common.WithStreaming(interval)
+}
+
+// WithoutRequestBodyFields keeps the bodies of requests to the handler from
+// being recorded when the beeline is configured with HTTPBodyContentTypes,
+// for handlers that take secrets such as logins or token exchanges.
+func WithoutRequestBodyFields() Option {
+	return common.WithoutRequestBodyFields()
+}
+
 // WrapHandler will create a Honeycomb event per invocation of this handler with
 // all the standard HTTP fields attached. If passed a ServeMux instead, pull
-// what you can from there
-func WrapHandler(handler http.Handler) http.Handler {
+// what you can from there. Options configure this handler independently of
+// any others that are wrapped.
+func WrapHandler(handler http.Handler, opts ...Option) http.Handler {
 	// if we can cache handlerName here, let's do so for efficiency's sake
 	handlerName := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	handlerPkg := common.HandlerPackage(handler)
+	config := common.NewHandlerConfig(opts)
 
 	wrappedHandler := func(w http.ResponseWriter, r *http.Request) {
+		r = config.PrepareRequest(r)
+		if common.IgnoreRequest(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
 		// get a new context with our trace from the request, and add common fields
 		ctx, span := common.StartSpanOrTraceFromHTTP(r)
 		defer span.Send()
+		common.SetResponseHeaders(w.Header(), r, span)
 		// push the context with our trace and span on to the request
 		r = r.WithContext(ctx)
 		// replace the writer with our wrapper to catch the status code
 		wrappedWriter := common.NewResponseWriter(w)
+		defer wrappedWriter.RecordPanic(span)
 
 		mux, ok := handler.(*http.ServeMux)
 		if ok {
@@ -41,6 +97,9 @@ func WrapHandler(handler http.Handler) http.Handler {
 				span.AddField("handler.name", name)
 				span.AddField("name", name)
 			}
+			if pkg := common.HandlerPackage(handler); pkg != "" {
+				span.AddField("handler.pkg", pkg)
+			}
 		} else {
 			if handlerName != "" {
 				span.AddField("handler.name", handlerName)
@@ -49,9 +108,15 @@ func WrapHandler(handler http.Handler) http.Handler {
 				// we always want a name, even if it's kinda useless.
 				span.AddField("name", "handler")
 			}
+			if handlerPkg != "" {
+				span.AddField("handler.pkg", handlerPkg)
+			}
 		}
+		config.Apply(ctx, span)
+		defer config.StartStream(ctx, r, span, wrappedWriter)()
 
 		handler.ServeHTTP(wrappedWriter.Wrapped, r)
+		addPatternFields(span, r, config)
 		if wrappedWriter.Status == 0 {
 			wrappedWriter.Status = 200
 		}
@@ -65,29 +130,48 @@ func WrapHandler(handler http.Handler) http.Handler {
 			span.AddField("response.content_encoding", ce)
 		}
 		span.AddField("response.status_code", wrappedWriter.Status)
+		wrappedWriter.AddResponseFields(span)
+		wrappedWriter.AddAnomalyFields(span)
+		common.AddContextFields(ctx, span)
 	}
 	return http.HandlerFunc(wrappedHandler)
 }
 
 // WrapHandlerFunc will create a Honeycomb event per invocation of this handler
 // function with all the standard HTTP fields attached.
-func WrapHandlerFunc(hf func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+func WrapHandlerFunc(hf func(http.ResponseWriter, *http.Request), opts ...Option) func(http.ResponseWriter, *http.Request) {
 	handlerFuncName := runtime.FuncForPC(reflect.ValueOf(hf).Pointer()).Name()
+	handlerPkg := common.FuncPackage(handlerFuncName)
+	config := common.NewHandlerConfig(opts)
 	return func(w http.ResponseWriter, r *http.Request) {
+		r = config.PrepareRequest(r)
+		if common.IgnoreRequest(r) {
+			hf(w, r)
+			return
+		}
 		// get a new context with our trace from the request, and add common fields
 		ctx, span := common.StartSpanOrTraceFromHTTP(r)
 		defer span.Send()
+		common.SetResponseHeaders(w.Header(), r, span)
 		// push the context with our trace and span on to the request
 		r = r.WithContext(ctx)
 		// replace the writer with our wrapper to catch the status code
 		wrappedWriter := common.NewResponseWriter(w)
+		defer wrappedWriter.RecordPanic(span)
 		// add the name of the handler func we're about to invoke
 		if handlerFuncName != "" {
 			span.AddField("handler_func_name", handlerFuncName)
+			span.AddField("handler.name", handlerFuncName)
 			span.AddField("name", handlerFuncName)
 		}
+		if handlerPkg != "" {
+			span.AddField("handler.pkg", handlerPkg)
+		}
+		config.Apply(ctx, span)
+		defer config.StartStream(ctx, r, span, wrappedWriter)()
 
 		hf(wrappedWriter.Wrapped, r)
+		addPatternFields(span, r, config)
 		if wrappedWriter.Status == 0 {
 			wrappedWriter.Status = 200
 		}
@@ -101,6 +185,9 @@ func WrapHandlerFunc(hf func(http.ResponseWriter, *http.Request)) func(http.Resp
 			span.AddField("response.content_encoding", ce)
 		}
 		span.AddField("response.status_code", wrappedWriter.Status)
+		wrappedWriter.AddResponseFields(span)
+		wrappedWriter.AddAnomalyFields(span)
+		common.AddContextFields(ctx, span)
 	}
 }
 
@@ -119,10 +206,15 @@ func (ht *hnyTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 }
 
 func (ht *hnyTripper) eventRoundTrip(r *http.Request) (*http.Response, error) {
-	// if there's no trace in the context, just send an event
+	// if there's no trace in the context, just send an event, with the
+	// beeline of the context if it has one
 	tm := timer.Start()
-	ev := libhoney.NewEvent()
-	defer ev.Send()
+	bld := client.NewBuilder()
+	if c := trace.GetClientFromContext(r.Context()); c != nil {
+		bld = c.NewBuilder()
+	}
+	ev := bld.NewEvent()
+	defer trace.GetConfigFromContext(r.Context()).SendEvent(ev)
 
 	// add in common request headers.
 	for k, v := range common.GetRequestProps(r) {
@@ -130,12 +222,18 @@ func (ht *hnyTripper) eventRoundTrip(r *http.Request) (*http.Response, error) {
 	}
 
 	ev.AddField("meta.type", "http_client")
+	addAttemptFields(r.Context(), ev)
 
+	var timings connTimings
+	r = r.WithContext(httptrace.WithClientTrace(r.Context(), timings.clientTrace()))
 	resp, err := ht.wrt.RoundTrip(r)
+	timings.addFields(ev)
 
 	if err != nil {
 		// TODO should this error field be namespaced somehow
 		ev.AddField("error", err.Error())
+	} else {
+		addResponseFields(ev, resp)
 	}
 	dur := tm.Finish()
 	ev.AddField("duration_ms", dur)
@@ -149,41 +247,73 @@ func (ht *hnyTripper) spanRoundTrip(ctx context.Context, span *trace.Span, r *ht
 	ctx, span = span.CreateChild(ctx)
 	defer span.Send()
 
-	r = r.WithContext(ctx)
+	var timings connTimings
+	r = r.WithContext(httptrace.WithClientTrace(ctx, timings.clientTrace()))
 	// add in common request headers.
 	for k, v := range common.GetRequestProps(r) {
 		span.AddField(k, v)
 	}
 	span.AddField("meta.type", "http_client")
 	span.AddField("name", "http_client")
-	r.Header.Add(propagation.TracePropagationHTTPHeader, span.SerializeHeaders())
+	addAttemptFields(ctx, span)
+	common.SetTraceHeaders(r.Header, span)
 
 	resp, err := ht.wrt.RoundTrip(r)
+	timings.addFields(span)
 
 	if err != nil {
-		// TODO should this error field be namespaced somehow
-		span.AddField("error", err.Error())
+		span.AddError(err)
 	} else {
-		if cl := resp.Header.Get("Content-Length"); cl != "" {
-			span.AddField("response.content_length", cl)
-		}
-		if ct := resp.Header.Get("Content-Type"); ct != "" {
-			span.AddField("response.content_type", ct)
-		}
-		if ce := resp.Header.Get("Content-Encoding"); ce != "" {
-			span.AddField("response.content_encoding", ce)
-		}
-		span.AddField("response.status_code", resp.StatusCode)
+		addResponseFields(span, resp)
 	}
 	return resp, err
 }
 
+// addResponseFields records an outbound call's response on the span or event
+// for it.
+func addResponseFields(ev interface{ AddField(string, interface{}) }, resp *http.Response) {
+	if cl := resp.Header.Get("Content-Length"); cl != "" {
+		ev.AddField("response.content_length", cl)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		ev.AddField("response.content_type", ct)
+	}
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" {
+		ev.AddField("response.content_encoding", ce)
+	}
+	ev.AddField("response.status_code", resp.StatusCode)
+}
+
 // WrapRoundTripper wraps an http transport for outgoing HTTP calls. Using a
 // wrapped transport will send an event to Honeycomb for each outbound HTTP call
 // you make. Include a context with outbound requests when possible to enable
-// correlation
+// correlation. Calls made with a span in their context carry the Honeycomb and
+// W3C trace headers, so the services they go to can continue the trace.
+//
+// When the wrapped round tripper is an http.Transport, each call also
+// records how long it spent getting a connection, broken down into DNS,
+// connect and TLS handshake time, whether the connection was reused, and the
+// time the server took to respond. Code that retries or hedges calls can mark
+// them with WithRetryAttempt and WithHedgedRequest.
 func WrapRoundTripper(r http.RoundTripper) http.RoundTripper {
 	return &hnyTripper{
 		wrt: r,
 	}
 }
+
+// WrapClient returns a copy of c with its transport wrapped by
+// WrapRoundTripper. A client without a transport of its own uses
+// http.DefaultTransport, so that is wrapped instead. If c is nil a wrapped
+// copy of http.DefaultClient is returned.
+func WrapClient(c *http.Client) *http.Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	wrapped := *c
+	rt := c.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	wrapped.Transport = WrapRoundTripper(rt)
+	return &wrapped
+}
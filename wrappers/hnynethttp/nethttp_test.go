@@ -1,11 +1,22 @@
 package hnynethttp
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/client"
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
 	libhoney "github.com/honeycombio/libhoney-go"
 	"github.com/honeycombio/libhoney-go/transmission"
 	"github.com/stretchr/testify/assert"
@@ -44,6 +55,8 @@ func TestWrapHandlerFunc(t *testing.T) {
 	status, ok := successfulFields["response.status_code"]
 	assert.True(t, ok, "status field must exist on middleware generated event")
 	assert.Equal(t, 200, status, "successfully served request should have status 200")
+	assert.Contains(t, successfulFields["handler.name"], "TestWrapHandlerFunc.func1")
+	assert.Equal(t, "github.com/honeycombio/beeline-go/wrappers/hnynethttp", successfulFields["handler.pkg"])
 
 	failedFields := evs[1].Data
 	status, ok = failedFields["response.status_code"]
@@ -84,9 +97,576 @@ func TestWrapHandler(t *testing.T) {
 	status, ok := fields["response.status_code"]
 	assert.True(t, ok, "status field must exist on middleware generated event")
 	assert.Equal(t, 200, status, "successfully served request should have status 200")
+	assert.Contains(t, fields["handler.name"], "TestWrapHandler.func1")
+	assert.Equal(t, "github.com/honeycombio/beeline-go/wrappers/hnynethttp", fields["handler.pkg"])
 
 	failedFields := evs[1].Data
 	status, ok = failedFields["response.status_code"]
 	assert.True(t, ok, "status field must exist on middleware generated event")
 	assert.Equal(t, http.StatusTeapot, status, "served /fail request should have status 418")
 }
+
+// TestWrappersWithoutInit makes sure wrapped handlers and round trippers keep
+// working when the beeline isn't initialized.
+func TestWrappersWithoutInit(t *testing.T) {
+	client.Set(nil)
+
+	var called int
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		called++
+		w.WriteHeader(http.StatusTeapot)
+	}
+	w := httptest.NewRecorder()
+	WrapHandler(http.HandlerFunc(handler)).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	w = httptest.NewRecorder()
+	WrapHandlerFunc(handler)(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, 2, called, "wrapped handlers should always be called")
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	c := &http.Client{Transport: WrapRoundTripper(http.DefaultTransport)}
+	resp, err := c.Get(server.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	}
+}
+
+func TestWrapRoundTripperPropagation(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer server.Close()
+
+	ctx, span := beeline.StartSpan(context.Background(), "caller")
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	c := &http.Client{Transport: WrapRoundTripper(http.DefaultTransport)}
+	resp, err := c.Do(req.WithContext(ctx))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		call := evs[0].Data
+		assert.Equal(t, "http_client", call["name"])
+		assert.NotEmpty(t, received.Get(propagation.TracePropagationHTTPHeader))
+		assert.Equal(t, "00-"+call["trace.trace_id"].(string)+"-"+call["trace.span_id"].(string)+"-01",
+			received.Get(propagation.W3CTraceParentHTTPHeader), "the W3C header should name the client span as the parent")
+	}
+}
+
+// TestTraceAcrossServices makes sure a call made through a wrapped round
+// tripper to a wrapped handler joins the caller's trace, trace fields and all.
+func TestTraceAcrossServices(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	server := httptest.NewServer(http.HandlerFunc(WrapHandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		beeline.AddField(r.Context(), "downstream", true)
+	})))
+	defer server.Close()
+
+	ctx, span := beeline.StartSpan(context.Background(), "upstream")
+	beeline.AddFieldToTrace(ctx, "user_id", "42")
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	c := &http.Client{Transport: WrapRoundTripper(http.DefaultTransport)}
+	resp, err := c.Do(req.WithContext(ctx))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		downstream, call, upstream := evs[0].Data, evs[1].Data, evs[2].Data
+		assert.Equal(t, true, downstream["app.downstream"])
+		assert.Equal(t, upstream["trace.trace_id"], downstream["trace.trace_id"])
+		assert.Equal(t, call["trace.span_id"], downstream["trace.parent_id"], "the call should be the downstream span's parent")
+		assert.Equal(t, "42", downstream["app.user_id"], "trace fields should be passed on")
+	}
+}
+
+func TestWrapClient(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	original := &http.Client{Timeout: time.Second}
+	c := WrapClient(original)
+	assert.Nil(t, original.Transport, "the client passed in shouldn't be changed")
+	assert.Equal(t, time.Second, c.Timeout)
+	assert.NotNil(t, WrapClient(nil).Transport)
+
+	ctx, span := beeline.StartSpan(context.Background(), "caller")
+	req, _ := http.NewRequest("POST", server.URL+"/invoices", nil)
+	resp, err := c.Do(req.WithContext(ctx))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		call := evs[0].Data
+		assert.Equal(t, "http_client", call["meta.type"])
+		assert.Equal(t, "POST", call["request.method"])
+		assert.Equal(t, server.URL+"/invoices", call["request.url"])
+		assert.Equal(t, http.StatusAccepted, call["response.status_code"])
+		assert.Contains(t, call, "duration_ms")
+		assert.Equal(t, evs[1].Data["trace.span_id"], call["trace.parent_id"])
+	}
+}
+
+func TestWrapClientConnectionFields(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c := WrapClient(&http.Client{Transport: &http.Transport{}})
+	ctx, span := beeline.StartSpan(context.Background(), "caller")
+	for attempt := 1; attempt <= 2; attempt++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		actx := WithRetryAttempt(ctx, attempt)
+		if attempt == 2 {
+			actx = WithHedgedRequest(actx)
+		}
+		resp, err := c.Do(req.WithContext(actx))
+		if assert.NoError(t, err) {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+	}
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		first, second := evs[0].Data, evs[1].Data
+		assert.Equal(t, false, first["request.conn_reused"])
+		assert.Contains(t, first, "request.connect_ms")
+		assert.Contains(t, first, "request.get_conn_ms")
+		assert.Contains(t, first, "request.server_ms")
+		assert.Equal(t, 1, first["request.attempt"])
+		assert.Equal(t, false, first["request.retry"])
+		assert.NotContains(t, first, "request.hedged")
+
+		assert.Equal(t, true, second["request.conn_reused"])
+		assert.Contains(t, second, "request.conn_idle_ms")
+		assert.NotContains(t, second, "request.connect_ms")
+		assert.Equal(t, 2, second["request.attempt"])
+		assert.Equal(t, true, second["request.retry"])
+		assert.Equal(t, true, second["request.hedged"])
+	}
+}
+
+// TestSamplerHookSeesResponse makes sure sampling happens once the response
+// is known, whether or not the event is part of a trace.
+func TestSamplerHookSeesResponse(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{
+		Client: client,
+		SamplerHook: func(fields map[string]interface{}) (bool, int) {
+			if fields["response.status_code"] == http.StatusInternalServerError {
+				return true, 1
+			}
+			return false, 1000
+		},
+	})
+	defer beeline.Init(beeline.Config{Client: client})
+
+	handler := WrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	for _, path := range []string{"/ok", "/fail"} {
+		handler(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	c := WrapClient(nil)
+	for _, path := range []string{"/ok", "/fail"} {
+		// without a span in the context, the call is sent as an event
+		resp, err := c.Get(server.URL + path)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+	}
+
+	evs := mo.Events()
+	var paths []interface{}
+	for _, ev := range evs {
+		assert.Equal(t, http.StatusInternalServerError, ev.Data["response.status_code"])
+		paths = append(paths, ev.Data["meta.type"])
+	}
+	assert.Equal(t, []interface{}{"http_request", "http_request", "http_client"}, paths)
+}
+
+func TestWrapHandlerHijackAndFlush(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", WrapHandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		for i := 0; i < 2; i++ {
+			w.Write([]byte("tick\n"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	mux.HandleFunc("/upgrade", WrapHandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+	}))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream")
+	if assert.NoError(t, err) {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "tick\ntick\n", string(body))
+	}
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if assert.NoError(t, err) {
+		fmt.Fprintf(conn, "GET /upgrade HTTP/1.1\r\nHost: test\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+		}
+		conn.Close()
+	}
+
+	// the server sends the spans after the responses are done
+	deadline := time.Now().Add(time.Second)
+	for len(mo.Events()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		stream, upgrade := evs[0].Data, evs[1].Data
+		assert.Equal(t, 200, stream["response.status_code"])
+		assert.Equal(t, int64(10), stream["response.bytes_written"])
+		assert.Contains(t, stream, "response.time_to_first_byte_ms")
+		assert.Equal(t, http.StatusSwitchingProtocols, upgrade["response.status_code"])
+		assert.Equal(t, true, upgrade["response.hijacked"])
+	}
+}
+
+func TestWrapHandlerPanic(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	handler := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		panic("handler exploded")
+	}))
+	r, _ := http.NewRequest("GET", "/hello", nil)
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+	assert.Equal(t, "handler exploded", recovered, "the panic should reach middleware further up")
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, http.StatusInternalServerError, fields["response.status_code"])
+		assert.Equal(t, "panic", fields["error"])
+		assert.Equal(t, "handler exploded", fields["panic.value"])
+		assert.Contains(t, fields["panic.stack"], "TestWrapHandlerPanic")
+	}
+}
+
+func TestWrapHandlerIgnoredRequest(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client, IgnoreHTTPPaths: []string{"/healthz"}})
+	defer beeline.Init(beeline.Config{Client: client})
+
+	var hadSpan bool
+	handler := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hadSpan = trace.GetSpanFromContext(r.Context()) != nil
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.False(t, hadSpan, "ignored requests shouldn't have a span in their context")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs), "only the other request should be traced") {
+		assert.Equal(t, "/users", evs[0].Data["request.path"])
+	}
+}
+
+func TestWrapHandlerOptions(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	noop := func(_ http.ResponseWriter, _ *http.Request) {}
+	checkout := WrapHandler(http.HandlerFunc(noop),
+		WithRouteName("checkout"), WithFields(map[string]interface{}{"team": "payments"}))
+	checkout.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/checkout", nil))
+	// a sample rate this high keeps almost nothing
+	healthz := WrapHandlerFunc(noop, WithSampleRate(math.MaxUint32))
+	healthz(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs), "the sampled out request shouldn't be sent") {
+		fields := evs[0].Data
+		assert.Equal(t, "checkout", fields["name"])
+		assert.Equal(t, "checkout", fields["handler.route"])
+		assert.Equal(t, "payments", fields["team"])
+		assert.Equal(t, uint(1), evs[0].SampleRate, "other handlers keep the configured rate")
+	}
+}
+
+func TestWrapHandlerWithoutRequestBodyFields(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{
+		Client:               client,
+		HTTPBodyContentTypes: []string{"application/json"},
+		HTTPBodyCaptureBytes: 1024,
+	})
+	defer beeline.Init(beeline.Config{Client: client})
+
+	var read string
+	handler := func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		read = string(body)
+	}
+	for _, h := range []http.Handler{
+		WrapHandler(http.HandlerFunc(handler), WithRouteName("search")),
+		WrapHandler(http.HandlerFunc(handler), WithRouteName("login"), WithoutRequestBodyFields()),
+	} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"password":"hunter2"}`))
+		r.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		assert.Equal(t, `{"password":"hunter2"}`, read, "the handler should get the whole body")
+	}
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, `{"password":"hunter2"}`, evs[0].Data["request.body"])
+		assert.NotContains(t, evs[1].Data, "request.body", "the login handler's body shouldn't be recorded")
+		assert.Equal(t, "application/json", evs[1].Data["request.header.content_type"])
+	}
+}
+
+func TestWrapHandlerCancelledRequest(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := WrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the client goes away while the handler is working
+		cancel()
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil).WithContext(ctx))
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, context.Canceled.Error(), evs[0].Data["request.context_error"])
+		assert.Equal(t, false, evs[0].Data["request.timeout"])
+	}
+}
+
+func TestTraceIDResponseHeader(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client, SendTraceIDResponseHeader: true})
+	defer beeline.Init(beeline.Config{Client: client})
+
+	var fromContext string
+	handler := WrapHandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		fromContext = beeline.TraceIDFromContext(r.Context())
+	})
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/", nil))
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		traceID := evs[0].Data["trace.trace_id"]
+		assert.Equal(t, traceID, w.Header().Get("X-Honeycomb-Trace-Id"))
+		assert.Equal(t, traceID, fromContext)
+	}
+	assert.Equal(t, "", beeline.TraceIDFromContext(context.Background()))
+}
+
+func TestWrapHandlerStreaming(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	handler := WrapHandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		// wait for the started span and a heartbeat
+		deadline := time.Now().Add(5 * time.Second)
+		for len(mo.Events()) < 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		fmt.Fprint(w, "data: 2\n\n")
+	}, WithStreaming(20*time.Millisecond))
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", nil))
+
+	evs := mo.Events()
+	if assert.True(t, len(evs) >= 3) {
+		started, heartbeat, summary := evs[0].Data, evs[1].Data, evs[len(evs)-1].Data
+		assert.Equal(t, "stream.started", started["name"])
+		assert.Equal(t, "/events", started["request.path"])
+		assert.Equal(t, summary["trace.span_id"], started["trace.parent_id"])
+
+		assert.Equal(t, "stream.heartbeat", heartbeat["name"])
+		assert.Equal(t, 1, heartbeat["stream.sequence"])
+		assert.Equal(t, int64(9), heartbeat["stream.bytes_written"])
+		assert.Equal(t, int64(9), heartbeat["stream.interval_bytes"])
+		assert.Equal(t, summary["trace.span_id"], heartbeat["trace.parent_id"])
+
+		assert.Equal(t, len(evs)-2, summary["stream.heartbeats"])
+		assert.Equal(t, int64(18), summary["response.bytes_written"])
+	}
+}
+
+func TestWrapHandlerWithBeeline(t *testing.T) {
+	global := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: global})
+	assert.NoError(t, err)
+	beeline.Init(beeline.Config{Client: client})
+	mo := &transmission.MockSender{}
+	client, err = libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "tenant",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.NoError(t, err)
+	b, err := beeline.New(beeline.Config{
+		Client:          client,
+		IgnoreHTTPPaths: []string{"/healthz"},
+		PresendHook:     func(fields map[string]interface{}) { fields["tenant"] = true },
+	})
+	assert.NoError(t, err)
+
+	handler := WrapHandler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, span := beeline.StartSpan(r.Context(), "work")
+		span.Send()
+	}), WithBeeline(b))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+	// the instance's config decides what is ignored, but spans started while
+	// serving an ignored request are still the instance's
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		assert.Equal(t, "tenant", evs[1].Dataset)
+		assert.Equal(t, 200, evs[1].Data["response.status_code"])
+		assert.Equal(t, "work", evs[0].Data["name"])
+		assert.Equal(t, "work", evs[2].Data["name"])
+		for _, ev := range evs {
+			assert.Equal(t, true, ev.Data["tenant"])
+		}
+	}
+	assert.Equal(t, 0, len(global.Events()))
+}
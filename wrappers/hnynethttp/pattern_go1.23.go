@@ -0,0 +1,52 @@
+//go:build go1.23
+// +build go1.23
+
+package hnynethttp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// addPatternFields records the http.ServeMux pattern that matched r, eg
+// "GET /users/{id}", as handler.pattern, and as handler.route unless the
+// handler was given a route name, and the value of each wildcard in it as
+// handler.vars.<name>. Nothing is added for requests that weren't routed by
+// a ServeMux.
+func addPatternFields(span *trace.Span, r *http.Request, config *common.HandlerConfig) {
+	if r.Pattern == "" {
+		return
+	}
+	span.AddField("handler.pattern", r.Pattern)
+	if config.RouteName == "" {
+		span.AddField("handler.route", r.Pattern)
+	}
+	for _, name := range patternWildcards(r.Pattern) {
+		span.AddField("handler.vars."+name, r.PathValue(name))
+	}
+}
+
+// patternWildcards returns the names of the wildcards in a ServeMux pattern,
+// such as id and path in "GET example.com/users/{id}/files/{path...}". The
+// {$} that anchors a pattern to the end of the path is not a wildcard.
+func patternWildcards(pattern string) []string {
+	var names []string
+	for {
+		start := strings.IndexByte(pattern, '{')
+		if start < 0 {
+			return names
+		}
+		end := strings.IndexByte(pattern[start:], '}')
+		if end < 0 {
+			return names
+		}
+		name := strings.TrimSuffix(pattern[start+1:start+end], "...")
+		if name != "$" && name != "" {
+			names = append(names, name)
+		}
+		pattern = pattern[start+end+1:]
+	}
+}
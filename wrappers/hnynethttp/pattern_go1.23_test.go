@@ -0,0 +1,65 @@
+//go:build go1.23
+// +build go1.23
+
+// This module's go.mod predates Go 1.22, so ServeMux would otherwise keep its
+// old behavior and ignore methods and wildcards in patterns.
+//go:debug httpmuxgo121=0
+
+package hnynethttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeMuxPatternFields(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	noop := func(_ http.ResponseWriter, _ *http.Request) {}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}/files/{path...}", noop)
+	mux.HandleFunc("GET /orders/{id}", WrapHandlerFunc(noop, WithRouteName("order")))
+	mux.HandleFunc("/{$}", noop)
+
+	// the whole mux wrapped
+	WrapHandler(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42/files/a/b.txt", nil))
+	// a handler wrapped inside the mux
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/7", nil))
+	WrapHandler(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		users := evs[0].Data
+		assert.Equal(t, "GET /users/{id}/files/{path...}", users["handler.route"])
+		assert.Equal(t, "42", users["handler.vars.id"])
+		assert.Equal(t, "a/b.txt", users["handler.vars.path"])
+
+		orders := evs[1].Data
+		assert.Equal(t, "GET /orders/{id}", orders["handler.pattern"])
+		assert.Equal(t, "order", orders["handler.route"], "a route name wins over the pattern")
+		assert.Equal(t, "7", orders["handler.vars.id"])
+
+		root := evs[2].Data
+		assert.Equal(t, "/{$}", root["handler.route"])
+		assert.NotContains(t, root, "handler.vars.$")
+	}
+}
+
+func TestPatternWildcards(t *testing.T) {
+	assert.Equal(t, []string{"id", "path"}, patternWildcards("GET example.com/users/{id}/files/{path...}"))
+	assert.Equal(t, []string(nil), patternWildcards("/static/{$}"))
+	assert.Equal(t, []string(nil), patternWildcards("/"))
+}
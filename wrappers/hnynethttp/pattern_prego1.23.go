@@ -0,0 +1,14 @@
+//go:build !go1.23
+// +build !go1.23
+
+package hnynethttp
+
+import (
+	"net/http"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// addPatternFields does nothing; http.Request has no Pattern before Go 1.23.
+func addPatternFields(span *trace.Span, r *http.Request, config *common.HandlerConfig) {}
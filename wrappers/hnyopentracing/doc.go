@@ -0,0 +1,24 @@
+// Package hnyopentracing provides an opentracing.Tracer backed by beeline
+// spans, so libraries that only accept an OpenTracing tracer add their spans
+// to the same traces as the rest of the app.
+//
+// Set the tracer globally, after calling beeline.Init:
+//
+//	opentracing.SetGlobalTracer(hnyopentracing.NewTracer())
+//
+// Spans started with a parent from the tracer become children of it. To have
+// a library's spans nest under the beeline span in a context, pass the
+// library a context made with ContextWithSpan:
+//
+//	ctx = hnyopentracing.ContextWithSpan(r.Context())
+//	rows, err := instrumentedClient.Query(ctx, ...)
+//
+// Tags become span fields of the same name and log fields are added as
+// log.<key>. Baggage items are added to the trace as fields, so they are
+// passed on to downstream services like any other trace field. Spans are
+// timed by the beeline from when they start until Finish is called; start
+// and finish times given as options are not used.
+//
+// Inject and Extract use the Honeycomb trace header for the HTTPHeaders and
+// TextMap formats, and the same header value as bytes for Binary.
+package hnyopentracing
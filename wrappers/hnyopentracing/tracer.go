@@ -0,0 +1,276 @@
+package hnyopentracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// Tracer is an opentracing.Tracer that records spans with the beeline.
+type Tracer struct{}
+
+// NewTracer returns a Tracer. It sends spans with the client the beeline was
+// initialized with.
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+// StartSpan starts a span as a child of the first span referenced in opts, or
+// as the root of a new trace if there is none. Spans that only follow from
+// their parent are async children of it.
+func (t *Tracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	var so opentracing.StartSpanOptions
+	for _, o := range opts {
+		o.Apply(&so)
+	}
+
+	ctx := context.Background()
+	var ts *trace.Span
+	var baggage map[string]string
+	for _, ref := range so.References {
+		sc, ok := ref.ReferencedContext.(spanContext)
+		if !ok {
+			continue
+		}
+		switch {
+		case sc.span != nil && ref.Type == opentracing.FollowsFromRef:
+			_, ts = sc.span.CreateAsyncChild(ctx)
+		case sc.span != nil:
+			_, ts = sc.span.CreateChild(ctx)
+		case sc.prop != nil:
+			_, tr := trace.NewTraceFromPropagationContext(ctx, sc.prop)
+			ts = tr.GetRootSpan()
+		}
+		if ts != nil {
+			baggage = sc.baggage
+			break
+		}
+	}
+	if ts == nil {
+		_, tr := trace.NewTraceFromPropagationContext(ctx, nil)
+		ts = tr.GetRootSpan()
+	}
+
+	ts.AddField("name", operationName)
+	for k, v := range so.Tags {
+		ts.AddField(k, v)
+	}
+	s := &span{tracer: t, span: ts, baggage: make(map[string]string, len(baggage))}
+	for k, v := range baggage {
+		s.baggage[k] = v
+	}
+	return s
+}
+
+// Inject writes the Honeycomb trace header for sc to carrier.
+func (t *Tracer) Inject(sc opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	c, ok := sc.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	switch format {
+	case opentracing.HTTPHeaders, opentracing.TextMap:
+		w, ok := carrier.(opentracing.TextMapWriter)
+		if !ok {
+			return opentracing.ErrInvalidCarrier
+		}
+		w.Set(propagation.TracePropagationHTTPHeader, c.header())
+		return nil
+	case opentracing.Binary:
+		w, ok := carrier.(io.Writer)
+		if !ok {
+			return opentracing.ErrInvalidCarrier
+		}
+		_, err := io.WriteString(w, c.header())
+		return err
+	}
+	return opentracing.ErrUnsupportedFormat
+}
+
+// Extract reads a Honeycomb trace header from carrier. Spans started with the
+// returned context as their parent continue the upstream trace.
+func (t *Tracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	var header string
+	switch format {
+	case opentracing.HTTPHeaders, opentracing.TextMap:
+		r, ok := carrier.(opentracing.TextMapReader)
+		if !ok {
+			return nil, opentracing.ErrInvalidCarrier
+		}
+		err := r.ForeachKey(func(k, v string) error {
+			if strings.EqualFold(k, propagation.TracePropagationHTTPHeader) {
+				header = v
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	case opentracing.Binary:
+		r, ok := carrier.(io.Reader)
+		if !ok {
+			return nil, opentracing.ErrInvalidCarrier
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		header = string(b)
+	default:
+		return nil, opentracing.ErrUnsupportedFormat
+	}
+	if header == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	prop, err := propagation.UnmarshalHoneycombTraceContext(header)
+	if err != nil || prop == nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	baggage := make(map[string]string)
+	for k, v := range prop.TraceContext {
+		if s, ok := v.(string); ok {
+			baggage[k] = s
+		}
+	}
+	return spanContext{prop: prop, baggage: baggage}, nil
+}
+
+// ContextWithSpan returns a copy of ctx with the beeline span in it wrapped
+// as an opentracing.Span, so spans a library starts with
+// opentracing.StartSpanFromContext become its children. The wrapped span
+// still belongs to the caller, which must send it as usual.
+func ContextWithSpan(ctx context.Context) context.Context {
+	ts := trace.GetSpanFromContext(ctx)
+	if ts == nil {
+		return ctx
+	}
+	return opentracing.ContextWithSpan(ctx, &span{tracer: NewTracer(), span: ts, baggage: map[string]string{}})
+}
+
+// spanContext refers either to a span in this process or to one upstream.
+type spanContext struct {
+	span    *trace.Span
+	prop    *propagation.PropagationContext
+	baggage map[string]string
+}
+
+// ForeachBaggageItem calls handler with each baggage item until it returns
+// false.
+func (c spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	for k, v := range c.baggage {
+		if !handler(k, v) {
+			return
+		}
+	}
+}
+
+func (c spanContext) header() string {
+	if c.span != nil {
+		return c.span.SerializeHeaders()
+	}
+	return propagation.MarshalHoneycombTraceContext(c.prop)
+}
+
+// span is an opentracing.Span that records to a beeline span.
+type span struct {
+	tracer *Tracer
+	span   *trace.Span
+
+	lock    sync.Mutex
+	baggage map[string]string
+}
+
+func (s *span) Finish() {
+	s.span.Send()
+}
+
+func (s *span) FinishWithOptions(opts opentracing.FinishOptions) {
+	for _, rec := range opts.LogRecords {
+		s.LogFields(rec.Fields...)
+	}
+	for _, ld := range opts.BulkLogData {
+		s.LogFields(ld.ToLogRecord().Fields...)
+	}
+	s.span.Send()
+}
+
+func (s *span) Context() opentracing.SpanContext {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	baggage := make(map[string]string, len(s.baggage))
+	for k, v := range s.baggage {
+		baggage[k] = v
+	}
+	return spanContext{span: s.span, baggage: baggage}
+}
+
+func (s *span) SetOperationName(operationName string) opentracing.Span {
+	s.span.AddField("name", operationName)
+	return s
+}
+
+func (s *span) SetTag(key string, value interface{}) opentracing.Span {
+	s.span.AddField(key, value)
+	return s
+}
+
+// LogFields adds each field to the span as log.<key>. A later log of the
+// same key replaces an earlier one. Errors are recorded as their message.
+func (s *span) LogFields(fields ...log.Field) {
+	for _, f := range fields {
+		v := f.Value()
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		s.span.AddField("log."+f.Key(), v)
+	}
+}
+
+func (s *span) LogKV(alternatingKeyValues ...interface{}) {
+	fields, err := log.InterleavedKVToFields(alternatingKeyValues...)
+	if err != nil {
+		s.span.AddField("log.error", fmt.Sprintf("invalid key/values: %s", err))
+		return
+	}
+	s.LogFields(fields...)
+}
+
+// SetBaggageItem adds the item to the trace as a field, which is passed on to
+// downstream services.
+func (s *span) SetBaggageItem(restrictedKey, value string) opentracing.Span {
+	s.lock.Lock()
+	s.baggage[restrictedKey] = value
+	s.lock.Unlock()
+	s.span.AddTraceField(restrictedKey, value)
+	return s
+}
+
+func (s *span) BaggageItem(restrictedKey string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.baggage[restrictedKey]
+}
+
+func (s *span) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *span) LogEvent(event string) {
+	s.LogFields(log.String("event", event))
+}
+
+func (s *span) LogEventWithPayload(event string, payload interface{}) {
+	s.LogFields(log.String("event", event), log.Object("payload", payload))
+}
+
+func (s *span) Log(ld opentracing.LogData) {
+	s.LogFields(ld.ToLogRecord().Fields...)
+}
@@ -0,0 +1,133 @@
+package hnyopentracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/propagation"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// make sure the interfaces are implemented
+var _ opentracing.Tracer = &Tracer{}
+var _ opentracing.Span = &span{}
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func TestStartSpan(t *testing.T) {
+	mo := setupLibhoney(t)
+	tracer := NewTracer()
+
+	root := tracer.StartSpan("query", opentracing.Tag{Key: "db.system", Value: "postgres"})
+	root.SetBaggageItem("tenant", "acme")
+	child := tracer.StartSpan("fetch", opentracing.ChildOf(root.Context()))
+	assert.Equal(t, "acme", child.BaggageItem("tenant"), "baggage should pass to children")
+	child.SetTag("rows", 3)
+	child.LogKV("event", "retry", "attempt", 2)
+	child.LogFields(log.Error(assert.AnError))
+	child.SetOperationName("fetch rows")
+	child.Finish()
+	root.Finish()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		c, r := evs[0].Data, evs[1].Data
+		assert.Equal(t, "fetch rows", c["name"])
+		assert.Equal(t, 3, c["rows"])
+		assert.Equal(t, "retry", c["log.event"])
+		assert.Equal(t, 2, c["log.attempt"])
+		assert.Equal(t, assert.AnError.Error(), c["log.error.object"])
+		assert.Equal(t, r["trace.trace_id"], c["trace.trace_id"])
+		assert.Equal(t, r["trace.span_id"], c["trace.parent_id"])
+		assert.Equal(t, "query", r["name"])
+		assert.Equal(t, "postgres", r["db.system"])
+		assert.Equal(t, "acme", r["tenant"], "baggage should be a trace field")
+	}
+}
+
+func TestFollowsFrom(t *testing.T) {
+	mo := setupLibhoney(t)
+	tracer := NewTracer()
+
+	root := tracer.StartSpan("enqueue")
+	async := tracer.StartSpan("process", opentracing.FollowsFrom(root.Context()))
+	root.Finish()
+	async.Finish()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "enqueue", evs[0].Data["name"], "an async child shouldn't be sent with its parent")
+		assert.Equal(t, "async", evs[1].Data["meta.span_type"])
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	mo := setupLibhoney(t)
+	tracer := NewTracer()
+	upstream := tracer.StartSpan("client")
+
+	headers := http.Header{}
+	assert.NoError(t, tracer.Inject(upstream.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers)))
+	assert.NotEmpty(t, headers.Get(propagation.TracePropagationHTTPHeader))
+
+	sc, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+	assert.NoError(t, err)
+	server := tracer.StartSpan("server", opentracing.ChildOf(sc))
+	server.Finish()
+	upstream.Finish()
+
+	var buf bytes.Buffer
+	assert.NoError(t, tracer.Inject(sc, opentracing.Binary, &buf))
+	sc, err = tracer.Extract(opentracing.Binary, &buf)
+	assert.NoError(t, err)
+	assert.NotNil(t, sc)
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, evs[1].Data["trace.trace_id"], evs[0].Data["trace.trace_id"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], evs[0].Data["trace.parent_id"])
+	}
+
+	_, err = tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier{})
+	assert.Equal(t, opentracing.ErrSpanContextNotFound, err)
+	_, err = tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier{propagation.TracePropagationHTTPHeader: "2;nope"})
+	assert.Equal(t, opentracing.ErrSpanContextCorrupted, err)
+	_, err = tracer.Extract("other", nil)
+	assert.Equal(t, opentracing.ErrUnsupportedFormat, err)
+	assert.Equal(t, opentracing.ErrInvalidCarrier, tracer.Inject(sc, opentracing.TextMap, "not a carrier"))
+}
+
+func TestContextWithSpan(t *testing.T) {
+	mo := setupLibhoney(t)
+	opentracing.SetGlobalTracer(NewTracer())
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	ctx, parent := beeline.StartSpan(context.Background(), "handler")
+	child, _ := opentracing.StartSpanFromContext(ContextWithSpan(ctx), "library")
+	child.Finish()
+	parent.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "library", evs[0].Data["name"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], evs[0].Data["trace.parent_id"])
+	}
+	assert.Equal(t, context.Background(), ContextWithSpan(context.Background()))
+}
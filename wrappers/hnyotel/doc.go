@@ -0,0 +1,19 @@
+// Package hnyotel bridges OpenTelemetry instrumentation to the beeline, so a
+// library instrumented with OpenTelemetry adds its spans to the beeline's
+// trace rather than starting a disconnected one.
+//
+// Register the Provider as the global OpenTelemetry trace provider:
+//
+//	global.SetTraceProvider(hnyotel.NewProvider())
+//
+// Spans started with its tracers are beeline spans: children of the beeline
+// span in the context, if there is one, or continuing the remote span
+// context in it, or otherwise the roots of new traces. Their attributes
+// become fields, events become span events and recorded errors are added
+// with Span.AddError. Beeline spans started in their context are in turn
+// their children. Links, custom start times and end times are not recorded.
+//
+// To go the other way, a library looking for the current OpenTelemetry span
+// in a context finds the beeline span in it once the context is passed
+// through ContextWithSpan.
+package hnyotel
@@ -0,0 +1,186 @@
+package hnyotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	"go.opentelemetry.io/otel/api/kv"
+	apitrace "go.opentelemetry.io/otel/api/trace"
+	"google.golang.org/grpc/codes"
+)
+
+// Provider is an OpenTelemetry trace.Provider whose tracers record spans with
+// the beeline.
+type Provider struct{}
+
+// NewProvider returns a Provider. Its spans are sent with the client the
+// beeline was initialized with.
+func NewProvider() *Provider {
+	return &Provider{}
+}
+
+// Tracer returns a tracer for the named instrumentation library. Its spans
+// record the name in `otel.library.name`.
+func (p *Provider) Tracer(name string) apitrace.Tracer {
+	return &Tracer{name: name}
+}
+
+// Tracer is an OpenTelemetry trace.Tracer that records spans with the
+// beeline.
+type Tracer struct {
+	name string
+}
+
+// Start starts a span as a child of the beeline span in ctx, or continuing
+// the remote span context in ctx, or as the root of a new trace. The
+// returned context has the span in it both as a beeline span and as an
+// OpenTelemetry span.
+func (t *Tracer) Start(ctx context.Context, spanName string, opts ...apitrace.StartOption) (context.Context, apitrace.Span) {
+	var cfg apitrace.StartConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	var ts *trace.Span
+	if !cfg.NewRoot {
+		if parent := trace.GetSpanFromContext(ctx); parent != nil {
+			ctx, ts = parent.CreateChild(ctx)
+		} else if sc := apitrace.RemoteSpanContextFromContext(ctx); sc.IsValid() {
+			var tr *trace.Trace
+			ctx, tr = trace.NewTraceFromPropagationContext(ctx, &propagation.PropagationContext{
+				TraceID:    sc.TraceID.String(),
+				ParentID:   sc.SpanID.String(),
+				TraceFlags: sc.TraceFlags,
+			})
+			ts = tr.GetRootSpan()
+		}
+	}
+	if ts == nil {
+		var tr *trace.Trace
+		ctx, tr = trace.NewTraceFromPropagationContext(ctx, nil)
+		ts = tr.GetRootSpan()
+	}
+
+	ts.AddField("name", spanName)
+	if t.name != "" {
+		ts.AddField("otel.library.name", t.name)
+	}
+	if cfg.SpanKind != apitrace.SpanKindUnspecified {
+		ts.AddField("span.kind", cfg.SpanKind.String())
+	}
+	s := &span{tracer: t, span: ts}
+	s.SetAttributes(cfg.Attributes...)
+	return apitrace.ContextWithSpan(ctx, s), s
+}
+
+// WithSpan runs fn in a span, recording the error it returns.
+func (t *Tracer) WithSpan(ctx context.Context, spanName string, fn func(ctx context.Context) error, opts ...apitrace.StartOption) error {
+	ctx, s := t.Start(ctx, spanName, opts...)
+	defer s.End()
+	err := fn(ctx)
+	if err != nil {
+		s.RecordError(ctx, err)
+	}
+	return err
+}
+
+// ContextWithSpan returns a copy of ctx with the beeline span in it wrapped
+// as an OpenTelemetry span, so libraries that look for the current span with
+// trace.SpanFromContext find it. The wrapped span still belongs to the
+// caller, which must send it as usual.
+func ContextWithSpan(ctx context.Context) context.Context {
+	ts := trace.GetSpanFromContext(ctx)
+	if ts == nil {
+		return ctx
+	}
+	return apitrace.ContextWithSpan(ctx, &span{tracer: &Tracer{}, span: ts})
+}
+
+// span is an OpenTelemetry span that records to a beeline span.
+type span struct {
+	tracer *Tracer
+	span   *trace.Span
+}
+
+func (s *span) Tracer() apitrace.Tracer {
+	return s.tracer
+}
+
+func (s *span) End(options ...apitrace.EndOption) {
+	s.span.Send()
+}
+
+func (s *span) AddEvent(ctx context.Context, name string, attrs ...kv.KeyValue) {
+	s.span.AddSpanEvent(name, attrFields(attrs))
+}
+
+func (s *span) AddEventWithTimestamp(ctx context.Context, timestamp time.Time, name string, attrs ...kv.KeyValue) {
+	s.span.AddSpanEvent(name, attrFields(attrs))
+}
+
+func (s *span) IsRecording() bool {
+	return true
+}
+
+func (s *span) RecordError(ctx context.Context, err error, opts ...apitrace.ErrorOption) {
+	if err == nil {
+		return
+	}
+	var cfg apitrace.ErrorConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	s.span.AddError(err)
+	if cfg.StatusCode != codes.OK {
+		s.SetStatus(cfg.StatusCode, err.Error())
+	}
+}
+
+// SpanContext returns the span's IDs. Spans continuing traces with IDs that
+// aren't OpenTelemetry's hex IDs have an empty span context.
+func (s *span) SpanContext() apitrace.SpanContext {
+	prop := s.span.PropagationContext()
+	traceID, err := apitrace.IDFromHex(prop.TraceID)
+	if err != nil {
+		return apitrace.EmptySpanContext()
+	}
+	spanID, err := apitrace.SpanIDFromHex(prop.ParentID)
+	if err != nil {
+		return apitrace.EmptySpanContext()
+	}
+	return apitrace.SpanContext{TraceID: traceID, SpanID: spanID, TraceFlags: prop.TraceFlags}
+}
+
+// SetStatus records statuses other than OK as `status.code` and
+// `status.message`.
+func (s *span) SetStatus(code codes.Code, msg string) {
+	if code == codes.OK {
+		return
+	}
+	s.span.AddField("status.code", int(code))
+	s.span.AddField("status.message", msg)
+}
+
+func (s *span) SetName(name string) {
+	s.span.AddField("name", name)
+}
+
+func (s *span) SetAttributes(attrs ...kv.KeyValue) {
+	for _, a := range attrs {
+		s.span.AddField(string(a.Key), a.Value.AsInterface())
+	}
+}
+
+func (s *span) SetAttribute(k string, v interface{}) {
+	s.span.AddField(k, v)
+}
+
+func attrFields(attrs []kv.KeyValue) map[string]interface{} {
+	fields := make(map[string]interface{}, len(attrs))
+	for _, a := range attrs {
+		fields[string(a.Key)] = a.Value.AsInterface()
+	}
+	return fields
+}
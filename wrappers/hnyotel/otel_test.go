@@ -0,0 +1,105 @@
+package hnyotel
+
+import (
+	"context"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/api/kv"
+	apitrace "go.opentelemetry.io/otel/api/trace"
+	"google.golang.org/grpc/codes"
+)
+
+// make sure the interfaces are implemented
+var _ apitrace.Provider = &Provider{}
+var _ apitrace.Tracer = &Tracer{}
+var _ apitrace.Span = &span{}
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func TestStartUnderBeelineSpan(t *testing.T) {
+	mo := setupLibhoney(t)
+	tracer := NewProvider().Tracer("github.com/example/lib")
+
+	ctx, root := beeline.StartSpan(context.Background(), "handler")
+	octx, os := tracer.Start(ctx, "lib.fetch", apitrace.WithSpanKind(apitrace.SpanKindClient), apitrace.WithAttributes(kv.Int("rows", 3)))
+	os.SetAttribute("cache", "miss")
+	os.AddEvent(octx, "retry", kv.Int("attempt", 2))
+	os.RecordError(octx, assert.AnError, apitrace.WithErrorStatus(codes.Unavailable))
+	_, inner := beeline.StartSpan(octx, "beeline.inner")
+	inner.Send()
+	os.End()
+	root.Send()
+
+	assert.Equal(t, os, apitrace.SpanFromContext(octx))
+	evs := mo.Events()
+	if assert.Equal(t, 4, len(evs)) {
+		event, in, lib, handler := evs[0].Data, evs[1].Data, evs[2].Data, evs[3].Data
+		assert.Equal(t, "retry", event["name"])
+		assert.Equal(t, int64(2), event["attempt"])
+		assert.Equal(t, lib["trace.span_id"], event["trace.parent_id"])
+		assert.Equal(t, lib["trace.span_id"], in["trace.parent_id"], "beeline spans should nest under otel spans")
+		assert.Equal(t, "lib.fetch", lib["name"])
+		assert.Equal(t, "github.com/example/lib", lib["otel.library.name"])
+		assert.Equal(t, "client", lib["span.kind"])
+		assert.Equal(t, int64(3), lib["rows"])
+		assert.Equal(t, "miss", lib["cache"])
+		assert.Equal(t, assert.AnError.Error(), lib["error"])
+		assert.Equal(t, int(codes.Unavailable), lib["status.code"])
+		assert.Equal(t, handler["trace.span_id"], lib["trace.parent_id"], "otel spans should nest under beeline spans")
+		assert.Equal(t, handler["trace.trace_id"], lib["trace.trace_id"])
+	}
+}
+
+func TestStartFromRemoteSpanContext(t *testing.T) {
+	mo := setupLibhoney(t)
+	tracer := NewProvider().Tracer("")
+	traceID, _ := apitrace.IDFromHex("0af7651916cd43dd8448eb211c80319c")
+	spanID, _ := apitrace.SpanIDFromHex("b7ad6b7169203331")
+	ctx := apitrace.ContextWithRemoteSpanContext(context.Background(), apitrace.SpanContext{TraceID: traceID, SpanID: spanID})
+
+	err := tracer.WithSpan(ctx, "consume", func(ctx context.Context) error {
+		sc := apitrace.SpanFromContext(ctx).SpanContext()
+		assert.Equal(t, traceID, sc.TraceID)
+		assert.True(t, sc.SpanID.IsValid())
+		return assert.AnError
+	})
+	assert.Equal(t, assert.AnError, err)
+
+	_, root := tracer.Start(ctx, "fresh", apitrace.WithNewRoot())
+	root.End()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", evs[0].Data["trace.trace_id"])
+		assert.Equal(t, "b7ad6b7169203331", evs[0].Data["trace.parent_id"])
+		assert.Equal(t, assert.AnError.Error(), evs[0].Data["error"])
+		assert.NotEqual(t, "0af7651916cd43dd8448eb211c80319c", evs[1].Data["trace.trace_id"])
+	}
+}
+
+func TestContextWithSpan(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, root := beeline.StartSpan(context.Background(), "handler")
+	apitrace.SpanFromContext(ContextWithSpan(ctx)).SetAttribute("lib.attr", "set")
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "set", evs[0].Data["lib.attr"])
+	}
+	assert.Equal(t, context.Background(), ContextWithSpan(context.Background()))
+}
@@ -0,0 +1,26 @@
+// Package hnypgx emits spans for the queries, batches and connections made
+// with jackc/pgx, for code that uses pgx directly rather than through
+// database/sql.
+//
+// Set the tracer on the connection's config, or on the pool's config to also
+// time acquiring a connection from the pool, and pass pgx a context with a
+// span in it, so the calls are children of that span:
+//
+//	config, err := pgxpool.ParseConfig(dsn)
+//	config.ConnConfig.Tracer = hnypgx.NewTracer()
+//	pool, err := pgxpool.NewWithConfig(ctx, config)
+//	...
+//	err = pool.QueryRow(r.Context(), "SELECT name FROM users WHERE id = $1", id).Scan(&name)
+//
+// Spans record the same fields as those of hnysql: the call in db.call and
+// name, the query and its arguments in db.query and db.query_args, as
+// Config.DBQueryMode and OmitDBQueryArgs say, db.rows_affected and, for calls
+// that fail, db.error. The time spent in the database and the number of
+// calls are rolled up onto the root span as rollup.db.duration_ms and
+// rollup.db.call_count.
+//
+// A batch gets a span of its own, with the number of queries in it as
+// db.batch_size, and a child span for each query. pgx sends the queries
+// together, so each child covers the time from the result before it to its
+// own.
+package hnypgx
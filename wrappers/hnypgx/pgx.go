@@ -0,0 +1,196 @@
+package hnypgx
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Tracer emits a span for each query, batch, prepare, copy and connection
+// pgx makes, as a child of the span in the context the call was made with.
+// Calls made without a span start a trace of their own.
+type Tracer struct{}
+
+// NewTracer returns a tracer to set as pgx.ConnConfig.Tracer.
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+// the tracers pgx and pgxpool look for
+var (
+	_ pgx.QueryTracer       = &Tracer{}
+	_ pgx.BatchTracer       = &Tracer{}
+	_ pgx.ConnectTracer     = &Tracer{}
+	_ pgx.PrepareTracer     = &Tracer{}
+	_ pgx.CopyFromTracer    = &Tracer{}
+	_ pgxpool.AcquireTracer = &Tracer{}
+)
+
+// callKey holds the call started by a Trace*Start method in the context pgx
+// passes to the matching Trace*End.
+type callKey struct{}
+
+// call times one call to pgx.
+type call struct {
+	span  *trace.Span
+	start time.Time
+	// batch holds the span for the next query of a batch, whose result
+	// hasn't been read yet.
+	batch *trace.Span
+}
+
+func startCall(ctx context.Context, name, query string, args []interface{}) context.Context {
+	ctx, span := common.StartChildSpan(ctx)
+	span.AddField("meta.type", "sql")
+	span.AddField("name", name)
+	span.AddField("db.call", name)
+	if len(args) == 0 {
+		args = nil
+	}
+	common.AddDBQueryFields(span, query, args)
+	return context.WithValue(ctx, callKey{}, &call{span: span, start: time.Now()})
+}
+
+// finishCall sends the span of the call started in ctx.
+func finishCall(ctx context.Context, err error) {
+	c, ok := ctx.Value(callKey{}).(*call)
+	if !ok {
+		return
+	}
+	if err != nil {
+		c.span.AddField("db.error", err.Error())
+	}
+	duration := float64(time.Since(c.start)) / float64(time.Millisecond)
+	c.span.AddRollupField("db.duration_ms", duration)
+	c.span.AddRollupField("db.call_count", 1)
+	c.span.Send()
+}
+
+func addCall(ctx context.Context, key string, val interface{}) {
+	if c, ok := ctx.Value(callKey{}).(*call); ok {
+		c.span.AddField(key, val)
+	}
+}
+
+// TraceQueryStart is called by pgx at the start of Query, QueryRow and Exec.
+func (t *Tracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return startCall(ctx, "Query", data.SQL, data.Args)
+}
+
+// TraceQueryEnd is called by pgx once the query has run. For Query, that is
+// once its rows have been read and closed.
+func (t *Tracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if data.Err == nil {
+		addCall(ctx, "db.rows_affected", data.CommandTag.RowsAffected())
+	}
+	finishCall(ctx, data.Err)
+}
+
+// TraceBatchStart is called by pgx at the start of SendBatch.
+func (t *Tracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	ctx = startCall(ctx, "Batch", "", nil)
+	c := ctx.Value(callKey{}).(*call)
+	if data.Batch != nil {
+		c.span.AddField("db.batch_size", data.Batch.Len())
+	}
+	_, c.batch = c.span.CreateChild(ctx)
+	return ctx
+}
+
+// TraceBatchQuery is called by pgx as the result of each query in a batch is
+// read.
+func (t *Tracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	c, ok := ctx.Value(callKey{}).(*call)
+	if !ok {
+		return
+	}
+	span := c.batch
+	span.AddField("meta.type", "sql")
+	span.AddField("name", "BatchQuery")
+	span.AddField("db.call", "BatchQuery")
+	args := data.Args
+	if len(args) == 0 {
+		args = nil
+	}
+	common.AddDBQueryFields(span, data.SQL, args)
+	if data.Err != nil {
+		span.AddField("db.error", data.Err.Error())
+	} else {
+		span.AddField("db.rows_affected", data.CommandTag.RowsAffected())
+	}
+	span.Send()
+	_, c.batch = c.span.CreateChild(ctx)
+}
+
+// TraceBatchEnd is called by pgx when the batch is closed.
+func (t *Tracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	if c, ok := ctx.Value(callKey{}).(*call); ok {
+		// there are no more queries
+		c.batch.Discard()
+	}
+	finishCall(ctx, data.Err)
+}
+
+// TraceConnectStart is called by pgx at the start of Connect and
+// ConnectConfig.
+func (t *Tracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	ctx = startCall(ctx, "Connect", "", nil)
+	if cfg := data.ConnConfig; cfg != nil {
+		addCall(ctx, "db.host", cfg.Host)
+		addCall(ctx, "db.name", cfg.Database)
+		addCall(ctx, "db.user", cfg.User)
+	}
+	return ctx
+}
+
+// TraceConnectEnd is called by pgx once the connection has been made.
+func (t *Tracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	finishCall(ctx, data.Err)
+}
+
+// TracePrepareStart is called by pgx at the start of Prepare.
+func (t *Tracer) TracePrepareStart(ctx context.Context, _ *pgx.Conn, data pgx.TracePrepareStartData) context.Context {
+	ctx = startCall(ctx, "Prepare", data.SQL, nil)
+	if data.Name != "" {
+		addCall(ctx, "db.stmt_id", data.Name)
+	}
+	return ctx
+}
+
+// TracePrepareEnd is called by pgx once the statement has been prepared.
+func (t *Tracer) TracePrepareEnd(ctx context.Context, _ *pgx.Conn, data pgx.TracePrepareEndData) {
+	addCall(ctx, "db.already_prepared", data.AlreadyPrepared)
+	finishCall(ctx, data.Err)
+}
+
+// TraceCopyFromStart is called by pgx at the start of CopyFrom.
+func (t *Tracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	ctx = startCall(ctx, "CopyFrom", "", nil)
+	addCall(ctx, "db.table", data.TableName.Sanitize())
+	return ctx
+}
+
+// TraceCopyFromEnd is called by pgx once the rows have been copied.
+func (t *Tracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	if data.Err == nil {
+		addCall(ctx, "db.rows_affected", data.CommandTag.RowsAffected())
+	}
+	finishCall(ctx, data.Err)
+}
+
+// TraceAcquireStart is called by pgxpool when a connection is requested from
+// the pool.
+func (t *Tracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	return startCall(ctx, "Acquire", "", nil)
+}
+
+// TraceAcquireEnd is called by pgxpool once a connection has been acquired,
+// including any time spent waiting for or connecting one.
+func (t *Tracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	finishCall(ctx, data.Err)
+}
@@ -0,0 +1,139 @@
+package hnypgx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/beelinetest"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracerQuery(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	tracer := NewTracer()
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+
+	qctx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{
+		SQL:  "UPDATE users SET name = $1 WHERE id = $2",
+		Args: []interface{}{"ada", 1},
+	})
+	tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 1")})
+
+	qctx = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: errors.New("connection reset")})
+	root.Send()
+
+	evs := rec.Events()
+	if !assert.Equal(t, 3, len(evs)) {
+		return
+	}
+	update, failed, req := evs[0], evs[1], evs[2]
+	assert.Equal(t, "Query", update["name"])
+	assert.Equal(t, "sql", update["meta.type"])
+	assert.Equal(t, "Query", update["db.call"])
+	assert.Equal(t, "UPDATE users SET name = $1 WHERE id = $2", update["db.query"])
+	assert.Equal(t, []interface{}{"ada", 1}, update["db.query_args"])
+	assert.Equal(t, int64(1), update["db.rows_affected"])
+	assert.Equal(t, req["trace.span_id"], update["trace.parent_id"])
+
+	assert.Equal(t, "connection reset", failed["db.error"])
+	assert.NotContains(t, failed, "db.query_args")
+	assert.NotContains(t, failed, "db.rows_affected")
+
+	assert.Equal(t, 2.0, req["rollup.db.call_count"])
+}
+
+func TestTracerBatch(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	tracer := NewTracer()
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+
+	batch := &pgx.Batch{}
+	batch.Queue("INSERT INTO events VALUES ($1)", 1)
+	batch.Queue("SELECT count(*) FROM events")
+	bctx := tracer.TraceBatchStart(ctx, nil, pgx.TraceBatchStartData{Batch: batch})
+	tracer.TraceBatchQuery(bctx, nil, pgx.TraceBatchQueryData{
+		SQL:        "INSERT INTO events VALUES ($1)",
+		Args:       []interface{}{1},
+		CommandTag: pgconn.NewCommandTag("INSERT 0 1"),
+	})
+	tracer.TraceBatchQuery(bctx, nil, pgx.TraceBatchQueryData{
+		SQL: "SELECT count(*) FROM events",
+		Err: errors.New("permission denied"),
+	})
+	tracer.TraceBatchEnd(bctx, nil, pgx.TraceBatchEndData{})
+	root.Send()
+
+	evs := rec.Events()
+	if !assert.Equal(t, 4, len(evs)) {
+		return
+	}
+	insert, count, b, req := evs[0], evs[1], evs[2], evs[3]
+	assert.Equal(t, "Batch", b["name"])
+	assert.Equal(t, 2, b["db.batch_size"])
+	assert.Equal(t, req["trace.span_id"], b["trace.parent_id"])
+
+	assert.Equal(t, "BatchQuery", insert["name"])
+	assert.Equal(t, "INSERT INTO events VALUES ($1)", insert["db.query"])
+	assert.Equal(t, int64(1), insert["db.rows_affected"])
+	assert.Equal(t, b["trace.span_id"], insert["trace.parent_id"])
+	assert.Equal(t, "permission denied", count["db.error"])
+	assert.Equal(t, b["trace.span_id"], count["trace.parent_id"])
+}
+
+func TestTracerConnections(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	tracer := NewTracer()
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+
+	actx := tracer.TraceAcquireStart(ctx, nil, pgxpool.TraceAcquireStartData{})
+	cctx := tracer.TraceConnectStart(actx, pgx.TraceConnectStartData{
+		ConnConfig: &pgx.ConnConfig{Config: pgconn.Config{Host: "db.internal", Database: "app", User: "web"}},
+	})
+	tracer.TraceConnectEnd(cctx, pgx.TraceConnectEndData{})
+	tracer.TraceAcquireEnd(actx, nil, pgxpool.TraceAcquireEndData{})
+	root.Send()
+
+	evs := rec.Events()
+	if !assert.Equal(t, 3, len(evs)) {
+		return
+	}
+	connect, acquire := evs[0], evs[1]
+	assert.Equal(t, "Connect", connect["name"])
+	assert.Equal(t, "db.internal", connect["db.host"])
+	assert.Equal(t, "app", connect["db.name"])
+	assert.Equal(t, "web", connect["db.user"])
+	assert.Equal(t, acquire["trace.span_id"], connect["trace.parent_id"], "connecting is part of acquiring")
+	assert.Equal(t, "Acquire", acquire["name"])
+}
+
+func TestTracerQueryModes(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	trace.GlobalConfig.DBQueryMode = trace.DBQueryNormalized
+	trace.GlobalConfig.OmitDBQueryArgs = true
+	defer func() {
+		trace.GlobalConfig.DBQueryMode = trace.DBQueryRaw
+		trace.GlobalConfig.OmitDBQueryArgs = false
+	}()
+	tracer := NewTracer()
+
+	// without a span, the query starts a trace of its own
+	qctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{
+		SQL:  "SELECT * FROM users WHERE email = 'ada@example.com' AND id = $1",
+		Args: []interface{}{1},
+	})
+	tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+
+	evs := rec.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "SELECT * FROM users WHERE email = ? AND id = $1", evs[0]["db.query"])
+		assert.NotContains(t, evs[0], "db.query_args")
+		assert.Equal(t, true, evs[0]["meta.orphaned"])
+	}
+}
@@ -0,0 +1,20 @@
+// Package hnyredis emits spans for the commands run on a redigo connection.
+//
+// Wrap each connection with the context of the work it is used for, so the
+// spans are children of that work's span:
+//
+//	conn := hnyredis.Wrap(r.Context(), pool.Get())
+//	defer conn.Close()
+//	name, err := redis.String(conn.Do("GET", "user:"+id+":name"))
+//
+// Each span records the command in db.command and, for keys namespaced with
+// colons, the first part of the key in redis.key_prefix. The rest of the key
+// and the command's other arguments are not recorded.
+//
+// Commands queued with Send are pipelined, and timed as a single span from
+// the Flush, or the Do that flushes them, until their last reply is received.
+// Its redis.pipeline_size is the number of commands in the pipeline, and
+// redis.commands lists them. The time spent in redis and the number of calls
+// made are also rolled up onto the root span, as rollup.redis.duration_ms and
+// rollup.redis.call_count.
+package hnyredis
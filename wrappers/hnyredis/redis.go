@@ -0,0 +1,229 @@
+package hnyredis
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Wrap returns a connection that emits a span for each command run on c, as
+// a child of the span in ctx.
+func Wrap(ctx context.Context, c redis.Conn) redis.Conn {
+	return &conn{Conn: c, ctx: ctx}
+}
+
+// conn times commands and pipelines. redigo allows one goroutine to send
+// and flush while another receives, so the pipeline state is locked.
+type conn struct {
+	redis.Conn
+	ctx context.Context
+
+	lock sync.Mutex
+	// pending are the commands sent but not yet flushed
+	pending []string
+	// pipeline is the span for the flushed commands whose replies haven't
+	// all been received, awaiting the number of replies still to come
+	pipeline *call
+	awaiting int
+}
+
+// call is the span for one command or pipeline.
+type call struct {
+	span  *trace.Span
+	start time.Time
+	// commands are those in a pipeline
+	commands []string
+}
+
+func (c *conn) start(name string) *call {
+	_, span := common.StartChildSpan(c.ctx)
+	span.AddField("meta.type", "redis")
+	span.AddField("name", "redis."+strings.ToLower(name))
+	return &call{span: span, start: time.Now()}
+}
+
+func (cl *call) finish(err error) {
+	if err != nil {
+		cl.span.AddField("redis.error", err.Error())
+	}
+	cl.span.AddRollupField("redis.duration_ms", float64(time.Since(cl.start))/float64(time.Millisecond))
+	cl.span.AddRollupField("redis.call_count", 1)
+	cl.span.Send()
+}
+
+func (c *conn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	cl := c.startDo(commandName, args)
+	reply, err := c.Conn.Do(commandName, args...)
+	c.finishDo(cl, err)
+	return reply, err
+}
+
+var _ redis.ConnWithTimeout = &conn{}
+
+// DoWithTimeout runs the command with redis.DoWithTimeout, which returns an
+// error if the wrapped connection doesn't support timeouts.
+func (c *conn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	cl := c.startDo(commandName, args)
+	reply, err := redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+	c.finishDo(cl, err)
+	return reply, err
+}
+
+// startDo starts the span for a call to Do, which also flushes any pending
+// commands and receives all outstanding replies. Do with an empty command
+// name only does that.
+func (c *conn) startDo(commandName string, args []interface{}) *call {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.pending) > 0 {
+		commands := c.pending
+		if commandName != "" {
+			commands = append(commands, commandName)
+		}
+		c.pending = nil
+		return c.startPipeline(commands)
+	}
+	if commandName == "" {
+		return nil
+	}
+	cl := c.start(commandName)
+	cl.span.AddField("db.command", commandName)
+	if prefix := keyPrefix(args); prefix != "" {
+		cl.span.AddField("redis.key_prefix", prefix)
+	}
+	return cl
+}
+
+func (c *conn) finishDo(cl *call, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// Do has received every outstanding reply
+	if c.pipeline != nil {
+		c.pipeline.finish(nil)
+		c.pipeline, c.awaiting = nil, 0
+	}
+	if cl != nil {
+		cl.finish(err)
+	}
+}
+
+func (c *conn) startPipeline(commands []string) *call {
+	cl := c.start("pipeline")
+	cl.addCommands(commands)
+	return cl
+}
+
+func (cl *call) addCommands(commands []string) {
+	cl.commands = append(cl.commands, commands...)
+	cl.span.AddField("redis.pipeline_size", len(cl.commands))
+	cl.span.AddField("redis.commands", cl.commands)
+}
+
+func (c *conn) Send(commandName string, args ...interface{}) error {
+	err := c.Conn.Send(commandName, args...)
+	if err == nil {
+		c.lock.Lock()
+		c.pending = append(c.pending, commandName)
+		c.lock.Unlock()
+	}
+	return err
+}
+
+func (c *conn) Flush() error {
+	c.lock.Lock()
+	var cl *call
+	if len(c.pending) > 0 {
+		cl = c.startPipeline(c.pending)
+		c.pending = nil
+	}
+	c.lock.Unlock()
+
+	err := c.Conn.Flush()
+
+	if cl != nil {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if err != nil {
+			cl.finish(err)
+			return err
+		}
+		if c.pipeline != nil {
+			// replies to an earlier flush are still to come, so time
+			// them all together
+			c.pipeline.addCommands(cl.commands)
+			c.awaiting += len(cl.commands)
+			cl.span.Discard()
+			return err
+		}
+		c.pipeline, c.awaiting = cl, len(cl.commands)
+	}
+	return err
+}
+
+func (c *conn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.received(err)
+	return reply, err
+}
+
+// ReceiveWithTimeout receives with redis.ReceiveWithTimeout, which returns an
+// error if the wrapped connection doesn't support timeouts.
+func (c *conn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := redis.ReceiveWithTimeout(c.Conn, timeout)
+	c.received(err)
+	return reply, err
+}
+
+// received counts a reply to the pipeline, finishing it after the last one.
+func (c *conn) received(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.pipeline != nil {
+		c.awaiting--
+		// errors in replies don't end the pipeline, but others do
+		_, isReplyErr := err.(redis.Error)
+		if err != nil && !isReplyErr || c.awaiting <= 0 {
+			c.pipeline.finish(err)
+			c.pipeline, c.awaiting = nil, 0
+		} else if isReplyErr {
+			c.pipeline.span.AddField("redis.error", err.Error())
+		}
+	}
+}
+
+func (c *conn) Close() error {
+	c.lock.Lock()
+	if c.pipeline != nil {
+		c.pipeline.finish(nil)
+		c.pipeline, c.awaiting = nil, 0
+	}
+	c.lock.Unlock()
+	return c.Conn.Close()
+}
+
+// keyPrefix returns the part of the first argument, usually a key, before
+// its first colon, or "" if it has none.
+func keyPrefix(args []interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+	var key string
+	switch k := args[0].(type) {
+	case string:
+		key = k
+	case []byte:
+		key = string(k)
+	default:
+		return ""
+	}
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package hnyredis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+// fakeConn replies to every command with its name, or with a reply error
+// for commands named ERR.
+type fakeConn struct {
+	queued []string
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Err() error   { return nil }
+func (c *fakeConn) Flush() error { return nil }
+
+func (c *fakeConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	var last interface{}
+	var err error
+	for len(c.queued) > 0 {
+		last, err = c.Receive()
+	}
+	if commandName == "" {
+		return last, err
+	}
+	return reply(commandName)
+}
+
+func (c *fakeConn) Send(commandName string, args ...interface{}) error {
+	if commandName == "BROKEN" {
+		return errors.New("connection lost")
+	}
+	c.queued = append(c.queued, commandName)
+	return nil
+}
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	name := c.queued[0]
+	c.queued = c.queued[1:]
+	return reply(name)
+}
+
+func reply(name string) (interface{}, error) {
+	if name == "ERR" {
+		return nil, redis.Error("ERR wrong type")
+	}
+	return name, nil
+}
+
+func TestDo(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	conn := Wrap(ctx, &fakeConn{})
+	reply, err := conn.Do("GET", "user:42:name")
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", reply)
+	_, err = conn.Do("ERR", []byte("nokey"))
+	assert.Error(t, err)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		get, errored, root := evs[0].Data, evs[1].Data, evs[2].Data
+		assert.Equal(t, "redis.get", get["name"])
+		assert.Equal(t, "GET", get["db.command"])
+		assert.Equal(t, "user", get["redis.key_prefix"])
+		assert.Equal(t, root["trace.span_id"], get["trace.parent_id"])
+		assert.Equal(t, "ERR wrong type", errored["redis.error"])
+		assert.NotContains(t, errored, "redis.key_prefix", "keys without a namespace shouldn't be recorded")
+		assert.Equal(t, float64(2), root["rollup.redis.call_count"])
+		assert.Contains(t, root, "rollup.redis.duration_ms")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	mo := setupLibhoney(t)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	conn := Wrap(ctx, &fakeConn{})
+
+	// a pipeline of Send, Flush and Receive
+	assert.NoError(t, conn.Send("SET", "a", 1))
+	assert.NoError(t, conn.Send("ERR", "b"))
+	assert.NoError(t, conn.Flush())
+	assert.Equal(t, 0, len(mo.Events()), "the pipeline should be open until its replies arrive")
+	_, err := conn.Receive()
+	assert.NoError(t, err)
+	_, err = conn.Receive()
+	assert.Error(t, err)
+
+	// and one ended by Do
+	assert.NoError(t, conn.Send("MULTI"))
+	assert.NoError(t, conn.Send("INCR", "c"))
+	_, err = conn.Do("EXEC")
+	assert.NoError(t, err)
+	assert.Error(t, conn.Send("BROKEN"))
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		first, second := evs[0].Data, evs[1].Data
+		assert.Equal(t, "redis.pipeline", first["name"])
+		assert.Equal(t, 2, first["redis.pipeline_size"])
+		assert.Equal(t, []string{"SET", "ERR"}, first["redis.commands"])
+		assert.Equal(t, "ERR wrong type", first["redis.error"])
+		assert.Equal(t, 3, second["redis.pipeline_size"])
+		assert.Equal(t, []string{"MULTI", "INCR", "EXEC"}, second["redis.commands"])
+		assert.Equal(t, float64(2), evs[2].Data["rollup.redis.call_count"])
+	}
+}
+
+func TestDoWithoutTimeoutSupport(t *testing.T) {
+	mo := setupLibhoney(t)
+	conn := Wrap(context.Background(), &fakeConn{})
+	_, err := redis.DoWithTimeout(conn, 0, "BLPOP", "queue:jobs", 0)
+	assert.Error(t, err, "the fake doesn't support timeouts")
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "queue", evs[0].Data["redis.key_prefix"])
+		assert.Contains(t, evs[0].Data, "redis.error")
+		assert.Equal(t, true, evs[0].Data["meta.orphaned"], "calls without a span start a trace")
+	}
+}
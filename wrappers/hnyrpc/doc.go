@@ -0,0 +1,25 @@
+// Package hnyrpc wraps the codecs used by `net/rpc` to emit a span per call.
+//
+// On the server, wrap the codec for each connection:
+//
+//	codec := hnyrpc.WrapServerCodec(jsonrpc.NewServerCodec(conn))
+//	go server.ServeCodec(codec)
+//
+// On the client, wrap the client's codec:
+//
+//	client := rpc.NewClientWithCodec(hnyrpc.WrapClientCodec(jsonrpc.NewClientCodec(conn)))
+//	err := hnyrpc.Call(ctx, client, "Arith.Multiply", args, &reply)
+//
+// net/rpc has no notion of a context, so calls made with client.Call start a
+// new trace. Use hnyrpc.Call to make the call's span a child of the span in a
+// context.
+//
+// Trace context is passed to the server by appending it to the request's
+// ServiceMethod after a "|", which can never appear in a method name. It is
+// only appended to calls made with hnyrpc.Call and a context holding a span,
+// so both ends must use these wrappers before those are made; an unwrapped
+// server will not find the method. Calls made with client.Call are sent
+// unchanged, so a wrapped client still works with unwrapped servers, but the
+// server starts a trace of its own for them. Server side spans can't be
+// passed to the method being called, so they have no children.
+package hnyrpc
@@ -0,0 +1,189 @@
+package hnyrpc
+
+import (
+	"context"
+	"net/rpc"
+	"strings"
+	"sync"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// traceSeparator separates the ServiceMethod of a request from the trace
+// header appended to it. It is not valid in Go identifiers, so it can't be
+// part of a real method name.
+const traceSeparator = "|"
+
+// Call calls the named method like client.Call, in a span that is a child of
+// the span in ctx, and passes the trace on to the server. client's codec
+// should be wrapped with WrapClientCodec, and the server's with
+// WrapServerCodec: if ctx holds a span, a server whose codec isn't wrapped
+// won't find the method.
+func Call(ctx context.Context, client *rpc.Client, serviceMethod string, args interface{}, reply interface{}) error {
+	if span := trace.GetSpanFromContext(ctx); span != nil {
+		serviceMethod = serviceMethod + traceSeparator + span.SerializeHeaders()
+	}
+	return client.Call(serviceMethod, args, reply)
+}
+
+// splitServiceMethod separates a ServiceMethod from any trace header appended
+// to it.
+func splitServiceMethod(serviceMethod string) (string, string) {
+	if i := strings.Index(serviceMethod, traceSeparator); i >= 0 {
+		return serviceMethod[:i], serviceMethod[i+len(traceSeparator):]
+	}
+	return serviceMethod, ""
+}
+
+// startSpan starts a trace for a call, continuing the trace in header if
+// there is one, and returns its root span.
+func startSpan(serviceMethod, header, spanType string) *trace.Span {
+	var prop *propagation.PropagationContext
+	var propErr error
+	if header != "" {
+		prop, propErr = propagation.UnmarshalHoneycombTraceContext(header)
+	}
+	_, tr := trace.NewTraceFromPropagationContext(context.Background(), prop)
+	span := tr.GetRootSpan()
+	common.AddPropagationError(span, propErr)
+	span.AddField("meta.type", spanType)
+	span.AddField("name", serviceMethod)
+	span.AddField("rpc.service_method", serviceMethod)
+	if i := strings.LastIndex(serviceMethod, "."); i >= 0 {
+		span.AddField("rpc.service", serviceMethod[:i])
+		span.AddField("rpc.method", serviceMethod[i+1:])
+	}
+	return span
+}
+
+// spans holds the span for each call in flight, by sequence number.
+type spans struct {
+	sync.Mutex
+	bySeq map[uint64]*trace.Span
+}
+
+func (s *spans) add(seq uint64, span *trace.Span) {
+	s.Lock()
+	defer s.Unlock()
+	if s.bySeq == nil {
+		s.bySeq = make(map[uint64]*trace.Span)
+	}
+	s.bySeq[seq] = span
+}
+
+func (s *spans) remove(seq uint64) *trace.Span {
+	s.Lock()
+	defer s.Unlock()
+	span := s.bySeq[seq]
+	delete(s.bySeq, seq)
+	return span
+}
+
+// sendAll sends the spans of calls that will never finish.
+func (s *spans) sendAll(reason string) {
+	s.Lock()
+	defer s.Unlock()
+	for seq, span := range s.bySeq {
+		span.AddField("rpc.error", reason)
+		span.Send()
+		delete(s.bySeq, seq)
+	}
+}
+
+type serverCodec struct {
+	rpc.ServerCodec
+	spans spans
+}
+
+// WrapServerCodec returns a codec that emits a span for each request read
+// from codec, sent when its response is written.
+func WrapServerCodec(codec rpc.ServerCodec) rpc.ServerCodec {
+	return &serverCodec{ServerCodec: codec}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.ServerCodec.ReadRequestHeader(r)
+	if err != nil {
+		return err
+	}
+	var header string
+	r.ServiceMethod, header = splitServiceMethod(r.ServiceMethod)
+	c.spans.add(r.Seq, startSpan(r.ServiceMethod, header, "rpc_request"))
+	return nil
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	err := c.ServerCodec.WriteResponse(r, body)
+	if span := c.spans.remove(r.Seq); span != nil {
+		if r.Error != "" {
+			span.AddField("rpc.error", r.Error)
+		}
+		if err != nil {
+			span.AddField("rpc.write_error", err.Error())
+		}
+		span.Send()
+	}
+	return err
+}
+
+func (c *serverCodec) Close() error {
+	c.spans.sendAll("connection closed")
+	return c.ServerCodec.Close()
+}
+
+type clientCodec struct {
+	rpc.ClientCodec
+	spans spans
+}
+
+// WrapClientCodec returns a codec that emits a span for each call made with
+// it. Calls made with Call and a context holding a span pass the call's trace
+// to the server; other calls are sent as they are.
+func WrapClientCodec(codec rpc.ClientCodec) rpc.ClientCodec {
+	return &clientCodec{ClientCodec: codec}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	serviceMethod, header := splitServiceMethod(r.ServiceMethod)
+	span := startSpan(serviceMethod, header, "rpc_client")
+	c.spans.add(r.Seq, span)
+
+	// only calls made by Call with a span carry the trace, so that calls
+	// made with client.Call still reach servers that aren't wrapped. The
+	// request is reused by net/rpc, so put the caller's method back once it
+	// has been written.
+	original := r.ServiceMethod
+	if header != "" {
+		r.ServiceMethod = serviceMethod + traceSeparator + span.SerializeHeaders()
+	}
+	err := c.ClientCodec.WriteRequest(r, body)
+	r.ServiceMethod = original
+	if err != nil {
+		if span := c.spans.remove(r.Seq); span != nil {
+			span.AddField("rpc.error", err.Error())
+			span.Send()
+		}
+	}
+	return err
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	err := c.ClientCodec.ReadResponseHeader(r)
+	if err != nil {
+		return err
+	}
+	if span := c.spans.remove(r.Seq); span != nil {
+		if r.Error != "" {
+			span.AddField("rpc.error", r.Error)
+		}
+		span.Send()
+	}
+	return nil
+}
+
+func (c *clientCodec) Close() error {
+	c.spans.sendAll("connection closed")
+	return c.ClientCodec.Close()
+}
@@ -0,0 +1,158 @@
+package hnyrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+type Args struct{ A, B int }
+
+type Arith struct{}
+
+func (Arith) Multiply(args *Args, reply *int) error {
+	*reply = args.A * args.B
+	return nil
+}
+
+func (Arith) Divide(args *Args, reply *int) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = args.A / args.B
+	return nil
+}
+
+func startServer(t *testing.T) (*rpc.Client, func()) {
+	server := rpc.NewServer()
+	if err := server.Register(Arith{}); err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		server.ServeCodec(WrapServerCodec(jsonrpc.NewServerCodec(serverConn)))
+		close(done)
+	}()
+	client := rpc.NewClientWithCodec(WrapClientCodec(jsonrpc.NewClientCodec(clientConn)))
+	return client, func() {
+		client.Close()
+		<-done
+	}
+}
+
+func TestCall(t *testing.T) {
+	mo := setupLibhoney(t)
+	client, stop := startServer(t)
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	var reply int
+	assert.NoError(t, Call(ctx, client, "Arith.Multiply", &Args{7, 8}, &reply))
+	assert.Equal(t, 56, reply)
+	err := Call(ctx, client, "Arith.Divide", &Args{1, 0}, &reply)
+	assert.EqualError(t, err, "divide by zero")
+	span.Send()
+	stop()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 5, len(evs)) {
+		return
+	}
+	// the server's spans are sent from its goroutine, so the events can be
+	// in any order
+	var root, server, client0 map[string]interface{}
+	for _, ev := range evs {
+		if ev.Data["name"] == "request" {
+			root = ev.Data
+			continue
+		}
+		if ev.Data["rpc.method"] != "Multiply" {
+			assert.Equal(t, "divide by zero", ev.Data["rpc.error"])
+			continue
+		}
+		switch ev.Data["meta.type"] {
+		case "rpc_request":
+			server = ev.Data
+		case "rpc_client":
+			client0 = ev.Data
+		}
+	}
+	if assert.NotNil(t, root) && assert.NotNil(t, server) && assert.NotNil(t, client0) {
+		assert.Equal(t, "Arith.Multiply", server["name"])
+		assert.Equal(t, "Arith", server["rpc.service"])
+		assert.Equal(t, root["trace.trace_id"], client0["trace.trace_id"])
+		assert.Equal(t, root["trace.span_id"], client0["trace.parent_id"])
+		assert.Equal(t, client0["trace.trace_id"], server["trace.trace_id"])
+		assert.Equal(t, client0["trace.span_id"], server["trace.parent_id"])
+	}
+}
+
+func TestCallWithoutContext(t *testing.T) {
+	mo := setupLibhoney(t)
+	client, stop := startServer(t)
+
+	var reply int
+	assert.NoError(t, client.Call("Arith.Multiply", &Args{2, 3}, &reply))
+	assert.Equal(t, 6, reply)
+	stop()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.NotEqual(t, evs[0].Data["trace.trace_id"], evs[1].Data["trace.trace_id"],
+			"calls without a span don't carry the trace")
+	}
+}
+
+func TestCallUnwrappedServer(t *testing.T) {
+	mo := setupLibhoney(t)
+	server := rpc.NewServer()
+	if err := server.Register(Arith{}); err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go server.ServeCodec(jsonrpc.NewServerCodec(serverConn))
+	client := rpc.NewClientWithCodec(WrapClientCodec(jsonrpc.NewClientCodec(clientConn)))
+	defer client.Close()
+
+	var reply int
+	assert.NoError(t, client.Call("Arith.Multiply", &Args{2, 3}, &reply), "the method name should be sent as it is")
+	assert.Equal(t, 6, reply)
+	assert.NoError(t, Call(context.Background(), client, "Arith.Multiply", &Args{3, 3}, &reply))
+	assert.Equal(t, 9, reply)
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "rpc_client", evs[0].Data["meta.type"])
+		assert.Equal(t, "Arith.Multiply", evs[0].Data["name"])
+	}
+}
+
+func TestSplitServiceMethod(t *testing.T) {
+	method, header := splitServiceMethod("Arith.Multiply|1;trace_id=abc,parent_id=def")
+	assert.Equal(t, "Arith.Multiply", method)
+	assert.Equal(t, "1;trace_id=abc,parent_id=def", header)
+
+	method, header = splitServiceMethod("Arith.Multiply")
+	assert.Equal(t, "Arith.Multiply", method)
+	assert.Empty(t, header)
+}
@@ -0,0 +1,30 @@
+// Package hnysarama traces messages produced and consumed with sarama, the
+// Shopify Kafka client.
+//
+// Wrap the producer, and send messages with the context of the work they are
+// sent for, so their spans are children of that work's span:
+//
+//	p, err := sarama.NewSyncProducer(brokers, config)
+//	producer := hnysarama.WrapSyncProducer(p)
+//	partition, offset, err := producer.SendMessageContext(r.Context(), msg)
+//
+// Each message gets a kafka.produce span with kafka.topic and, once it has
+// been written, kafka.partition and kafka.offset. Its trace context is added
+// to the message's headers, which needs the producer's config.Version to be
+// at least sarama.V0_11_0_0.
+//
+// On the consumer side, start a span for each message and send it when the
+// message has been handled:
+//
+//	for msg := range claim.Messages() {
+//		ctx, span := hnysarama.StartMessageSpan(sess.Context(), msg, claim)
+//		err := handle(ctx, msg)
+//		span.Send()
+//		sess.MarkMessage(msg, "")
+//	}
+//
+// The span is the root of a new trace, or continues the producer's trace if
+// the message has a trace header. It records kafka.topic, kafka.partition,
+// kafka.offset and kafka.lag, the number of messages in the partition after
+// this one.
+package hnysarama
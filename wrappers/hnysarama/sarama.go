@@ -0,0 +1,137 @@
+package hnysarama
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// headerKey is the record header that carries the trace context.
+var headerKey = []byte(propagation.TracePropagationHTTPHeader)
+
+// SyncProducer is a sarama.SyncProducer that emits a span for each message it
+// sends.
+type SyncProducer struct {
+	sarama.SyncProducer
+}
+
+// WrapSyncProducer returns a producer that sends messages with p.
+func WrapSyncProducer(p sarama.SyncProducer) *SyncProducer {
+	return &SyncProducer{SyncProducer: p}
+}
+
+// SendMessage sends msg like SendMessageContext, without a parent span.
+func (p *SyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	return p.SendMessageContext(context.Background(), msg)
+}
+
+// SendMessages sends msgs like SendMessagesContext, without a parent span.
+func (p *SyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	return p.SendMessagesContext(context.Background(), msgs)
+}
+
+// SendMessageContext sends msg in a span that is a child of the span in ctx,
+// with that span's trace context added to the message's headers.
+func (p *SyncProducer) SendMessageContext(ctx context.Context, msg *sarama.ProducerMessage) (int32, int64, error) {
+	span := startProducerSpan(ctx, msg)
+	partition, offset, err := p.SyncProducer.SendMessage(msg)
+	finishProducerSpan(span, partition, offset, err)
+	return partition, offset, err
+}
+
+// SendMessagesContext sends msgs with a span for each of them, like
+// SendMessageContext.
+func (p *SyncProducer) SendMessagesContext(ctx context.Context, msgs []*sarama.ProducerMessage) error {
+	spans := make([]*trace.Span, len(msgs))
+	for i, msg := range msgs {
+		spans[i] = startProducerSpan(ctx, msg)
+	}
+	err := p.SyncProducer.SendMessages(msgs)
+
+	// sarama reports which messages failed, when it can
+	failed := make(map[*sarama.ProducerMessage]error)
+	if perrs, ok := err.(sarama.ProducerErrors); ok {
+		for _, perr := range perrs {
+			failed[perr.Msg] = perr.Err
+		}
+	}
+	for i, msg := range msgs {
+		msgErr := err
+		if len(failed) > 0 {
+			msgErr = failed[msg]
+		}
+		finishProducerSpan(spans[i], msg.Partition, msg.Offset, msgErr)
+	}
+	return err
+}
+
+func startProducerSpan(ctx context.Context, msg *sarama.ProducerMessage) *trace.Span {
+	_, span := common.StartChildSpan(ctx)
+	span.AddField("meta.type", "kafka")
+	span.AddField("name", "kafka.produce")
+	span.AddField("kafka.topic", msg.Topic)
+	setTraceHeader(msg, span.SerializeHeaders())
+	return span
+}
+
+func finishProducerSpan(span *trace.Span, partition int32, offset int64, err error) {
+	if err != nil {
+		span.AddField("kafka.error", err.Error())
+	} else {
+		span.AddField("kafka.partition", partition)
+		span.AddField("kafka.offset", offset)
+	}
+	span.Send()
+}
+
+// setTraceHeader sets the trace header on msg, replacing any from an earlier
+// attempt to send it.
+func setTraceHeader(msg *sarama.ProducerMessage, header string) {
+	for i := range msg.Headers {
+		if string(msg.Headers[i].Key) == string(headerKey) {
+			msg.Headers[i].Value = []byte(header)
+			return
+		}
+	}
+	msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: headerKey, Value: []byte(header)})
+}
+
+// HighWaterMarker is implemented by the consumers messages come from, both
+// sarama.PartitionConsumer and sarama.ConsumerGroupClaim.
+type HighWaterMarker interface {
+	HighWaterMarkOffset() int64
+}
+
+// StartMessageSpan starts a trace for handling msg, continuing the
+// producer's trace if msg has a trace header, and returns its root span. The
+// caller must send the span. If hwm is not nil, the span records how far
+// behind the partition's latest message msg is as kafka.lag.
+func StartMessageSpan(ctx context.Context, msg *sarama.ConsumerMessage, hwm HighWaterMarker) (context.Context, *trace.Span) {
+	var prop *propagation.PropagationContext
+	var propErr error
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == string(headerKey) {
+			prop, propErr = propagation.UnmarshalHoneycombTraceContext(string(h.Value))
+			break
+		}
+	}
+	ctx, tr := trace.NewTraceFromPropagationContext(ctx, prop)
+	span := tr.GetRootSpan()
+	common.AddPropagationError(span, propErr)
+	span.AddField("meta.type", "kafka")
+	span.AddField("name", "kafka.consume")
+	span.AddField("kafka.topic", msg.Topic)
+	span.AddField("kafka.partition", msg.Partition)
+	span.AddField("kafka.offset", msg.Offset)
+	if hwm != nil {
+		// the high water mark is the offset the next message will have
+		if lag := hwm.HighWaterMarkOffset() - msg.Offset - 1; lag >= 0 {
+			span.AddField("kafka.lag", lag)
+		}
+	}
+	return ctx, span
+}
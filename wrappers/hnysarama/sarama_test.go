@@ -0,0 +1,140 @@
+package hnysarama
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+type highWaterMark int64
+
+func (h highWaterMark) HighWaterMarkOffset() int64 { return int64(h) }
+
+func TestProduceAndConsume(t *testing.T) {
+	mo := setupLibhoney(t)
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndFail(sarama.ErrNotLeaderForPartition)
+	producer := WrapSyncProducer(mock)
+	defer producer.Close()
+
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	msg := &sarama.ProducerMessage{Topic: "orders", Value: sarama.StringEncoder("order 1")}
+	_, offset, err := producer.SendMessageContext(ctx, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), offset)
+	// resending the message replaces its trace header
+	_, _, err = producer.SendMessageContext(ctx, msg)
+	assert.Equal(t, sarama.ErrNotLeaderForPartition, err)
+	assert.Equal(t, 1, len(msg.Headers))
+	root.Send()
+
+	consumed := &sarama.ConsumerMessage{Topic: "orders", Partition: 2, Offset: 7}
+	for _, h := range msg.Headers {
+		consumed.Headers = append(consumed.Headers, &sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	_, span := StartMessageSpan(context.Background(), consumed, highWaterMark(10))
+	span.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 4, len(evs)) {
+		return
+	}
+	sent, failed, req, consumer := evs[0].Data, evs[1].Data, evs[2].Data, evs[3].Data
+	assert.Equal(t, "kafka.produce", sent["name"])
+	assert.Equal(t, "orders", sent["kafka.topic"])
+	assert.Equal(t, int64(1), sent["kafka.offset"])
+	assert.Equal(t, req["trace.span_id"], sent["trace.parent_id"])
+	assert.Equal(t, sarama.ErrNotLeaderForPartition.Error(), failed["kafka.error"])
+	assert.NotContains(t, failed, "kafka.offset")
+
+	assert.Equal(t, "kafka.consume", consumer["name"])
+	assert.Equal(t, req["trace.trace_id"], consumer["trace.trace_id"])
+	assert.Equal(t, failed["trace.span_id"], consumer["trace.parent_id"], "the consumer should continue the last send's trace")
+	assert.Equal(t, int32(2), consumer["kafka.partition"])
+	assert.Equal(t, int64(7), consumer["kafka.offset"])
+	assert.Equal(t, int64(2), consumer["kafka.lag"])
+}
+
+func TestSendMessages(t *testing.T) {
+	mo := setupLibhoney(t)
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndSucceed()
+	producer := WrapSyncProducer(mock)
+	defer producer.Close()
+
+	ctx, root := beeline.StartSpan(context.Background(), "request")
+	err := producer.SendMessagesContext(ctx, []*sarama.ProducerMessage{
+		{Topic: "orders", Value: sarama.StringEncoder("order 1")},
+		{Topic: "invoices", Value: sarama.StringEncoder("invoice 1")},
+	})
+	assert.NoError(t, err)
+	root.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		assert.Equal(t, "orders", evs[0].Data["kafka.topic"])
+		assert.Equal(t, "invoices", evs[1].Data["kafka.topic"])
+		assert.Equal(t, evs[2].Data["trace.span_id"], evs[1].Data["trace.parent_id"])
+	}
+}
+
+func TestStartMessageSpanBadHeader(t *testing.T) {
+	mo := setupLibhoney(t)
+	msg := &sarama.ConsumerMessage{
+		Topic:   "orders",
+		Headers: []*sarama.RecordHeader{{Key: headerKey, Value: []byte("2;nope")}},
+	}
+	_, span := StartMessageSpan(context.Background(), msg, nil)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Contains(t, evs[0].Data, "meta.propagation_error")
+		assert.NotContains(t, evs[0].Data, "trace.parent_id")
+		assert.NotContains(t, evs[0].Data, "kafka.lag")
+	}
+}
+
+func TestSendMessagesErrors(t *testing.T) {
+	mo := setupLibhoney(t)
+	msgs := []*sarama.ProducerMessage{{Topic: "orders"}, {Topic: "invoices"}}
+	producer := WrapSyncProducer(failingProducer{failed: msgs[1]})
+	assert.Error(t, producer.SendMessages(msgs))
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.NotContains(t, evs[0].Data, "kafka.error")
+		assert.Equal(t, "broken", evs[1].Data["kafka.error"])
+	}
+}
+
+// failingProducer fails to send one message of each batch.
+type failingProducer struct {
+	sarama.SyncProducer
+	failed *sarama.ProducerMessage
+}
+
+func (p failingProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	return sarama.ProducerErrors{{Msg: p.failed, Err: errors.New("broken")}}
+}
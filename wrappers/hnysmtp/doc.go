@@ -0,0 +1,15 @@
+// Package hnysmtp emits a span for each email delivered.
+//
+// To send mail with `net/smtp`, use hnysmtp.SendMail in place of
+// smtp.SendMail:
+//
+//	err := hnysmtp.SendMail(r.Context(), "mail.example.com:25", auth, from, to, msg)
+//
+// Other delivery libraries, such as gomail or the SES API, can be timed in the
+// same way by adapting them to the Mailer interface, for example with a
+// MailerFunc, and sending with hnysmtp.Send.
+//
+// Each span records the number of recipients, the size of the message and,
+// when delivery fails with an SMTP error, the server's response code. The
+// addresses themselves are not recorded.
+package hnysmtp
@@ -0,0 +1,72 @@
+package hnysmtp
+
+import (
+	"context"
+	"net/smtp"
+	"net/textproto"
+	"time"
+
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Mailer delivers a message to a list of recipients.
+type Mailer interface {
+	Send(ctx context.Context, from string, to []string, msg []byte) error
+}
+
+// MailerFunc adapts a function to the Mailer interface.
+type MailerFunc func(ctx context.Context, from string, to []string, msg []byte) error
+
+// Send calls f.
+func (f MailerFunc) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	return f(ctx, from, to, msg)
+}
+
+// SMTPMailer is a Mailer that delivers messages with smtp.SendMail.
+type SMTPMailer struct {
+	// Addr is the address of the SMTP server, including the port.
+	Addr string
+	// Auth, if not nil, is used to authenticate with the server.
+	Auth smtp.Auth
+}
+
+// Send delivers msg with smtp.SendMail. net/smtp can't be cancelled, so ctx
+// is ignored.
+func (m SMTPMailer) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	return smtp.SendMail(m.Addr, m.Auth, from, to, msg)
+}
+
+// SendMail sends msg like smtp.SendMail, in a span that is a child of the
+// span in ctx.
+func SendMail(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	return send(ctx, SMTPMailer{Addr: addr, Auth: a}, addr, from, to, msg)
+}
+
+// Send delivers msg with m in a span that is a child of the span in ctx.
+func Send(ctx context.Context, m Mailer, from string, to []string, msg []byte) error {
+	return send(ctx, m, "", from, to, msg)
+}
+
+func send(ctx context.Context, m Mailer, addr string, from string, to []string, msg []byte) error {
+	ctx, span := common.StartChildSpan(ctx)
+	defer span.Send()
+	span.AddField("meta.type", "email")
+	span.AddField("name", "send_mail")
+	if addr != "" {
+		span.AddField("smtp.server", addr)
+	}
+	span.AddField("smtp.recipient_count", len(to))
+	span.AddField("smtp.message_bytes", len(msg))
+
+	start := time.Now()
+	err := m.Send(ctx, from, to, msg)
+	span.AddRollupField("smtp.duration_ms", float64(time.Since(start))/float64(time.Millisecond))
+	span.AddRollupField("smtp.send_count", 1)
+	if err != nil {
+		if tpErr, ok := err.(*textproto.Error); ok {
+			span.AddField("smtp.response_code", tpErr.Code)
+		}
+		span.AddField("smtp.error", err.Error())
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package hnysmtp
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+// startServer runs an SMTP server that accepts one message, rejecting any
+// recipient at reject.example.com.
+func startServer(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ready")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RSET", "NOOP":
+				tp.PrintfLine("250 ok")
+			case "RCPT":
+				if strings.Contains(line, "reject.example.com") {
+					tp.PrintfLine("550 no such user")
+				} else {
+					tp.PrintfLine("250 ok")
+				}
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				tp.ReadDotBytes()
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("502 unknown command")
+			}
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func TestSendMail(t *testing.T) {
+	mo := setupLibhoney(t)
+	addr, stop := startServer(t)
+	defer stop()
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+
+	msg := []byte("Subject: hi\r\n\r\nhello\r\n")
+	err := SendMail(ctx, addr, nil, "from@example.com",
+		[]string{"a@example.com", "b@example.com"}, msg)
+	assert.NoError(t, err)
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "email", fields["meta.type"])
+		assert.Equal(t, addr, fields["smtp.server"])
+		assert.Equal(t, 2, fields["smtp.recipient_count"])
+		assert.Equal(t, len(msg), fields["smtp.message_bytes"])
+		assert.NotContains(t, fields, "smtp.error")
+		assert.Equal(t, evs[1].Data["trace.span_id"], fields["trace.parent_id"])
+		assert.Equal(t, float64(1), evs[1].Data["rollup.smtp.send_count"])
+	}
+}
+
+func TestSendMailRejected(t *testing.T) {
+	mo := setupLibhoney(t)
+	addr, stop := startServer(t)
+	defer stop()
+
+	err := SendMail(context.Background(), addr, nil, "from@example.com",
+		[]string{"nobody@reject.example.com"}, []byte("hello\r\n"))
+	assert.Error(t, err)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, 550, evs[0].Data["smtp.response_code"])
+		assert.Equal(t, err.Error(), evs[0].Data["smtp.error"])
+	}
+}
+
+func TestSendWithMailer(t *testing.T) {
+	mo := setupLibhoney(t)
+	var got []string
+	m := MailerFunc(func(ctx context.Context, from string, to []string, msg []byte) error {
+		got = to
+		return nil
+	})
+
+	assert.NoError(t, Send(context.Background(), m, "from@example.com", []string{"a@example.com"}, []byte("x")))
+	assert.Equal(t, []string{"a@example.com"}, got)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, 1, evs[0].Data["smtp.recipient_count"])
+		assert.NotContains(t, evs[0].Data, "smtp.server")
+	}
+}
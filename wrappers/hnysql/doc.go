@@ -14,4 +14,27 @@
 // whenever possible; doing so not only lets you cancel your database calls, but
 // dramatically increases the value of the SQL isntrumentation by letting you
 // tie it back to individual HTTP requests.
+//
+// # Transactions
+//
+// A transaction begun with BeginTx and a context holding a span gets a span
+// of its own, the parent of every call made in the transaction. It's sent on
+// Commit or Rollback with the outcome in db.tx_outcome and the number and
+// total duration of the transaction's statements, so long running and
+// frequently rolled back transactions can be found as a unit.
+//
+// # Instrumenting the driver
+//
+// Code that gets its *sql.DB from elsewhere, such as an ORM or sqlx, can't
+// easily use *hnysql.DB. Instead, wrap the driver itself with WrapDriver or
+// WrapConnector. Every query, exec, prepare, begin, commit and rollback that
+// database/sql makes on the driver is then timed, however it was called.
+// Query spans cover running the query, not reading its rows.
+//
+// # Connection pool stats
+//
+// Spans for calls made with *hnysql.DB record the state of the connection pool
+// as db.open_conns, db.conns_in_use and so on. To watch a pool over time
+// instead, however the DB is instrumented, use ReportStats to send its stats
+// periodically.
 package hnysql
@@ -0,0 +1,446 @@
+package hnysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/honeycombio/beeline-go/client"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+	libhoney "github.com/honeycombio/libhoney-go"
+)
+
+// WrapDriver returns a driver that instruments every connection d opens.
+// Register it under a new name and open databases with that name to have
+// every query, exec, prepare, begin, commit and rollback made on it timed,
+// whether the calls are made with database/sql directly or through sqlx or
+// an ORM:
+//
+//	sql.Register("hny-postgres", hnysql.WrapDriver(&pq.Driver{}))
+//	db, err := sql.Open("hny-postgres", dsn)
+//
+// Calls made with a context that has a span are children of it. Others are
+// sent as events of their own.
+func WrapDriver(d driver.Driver) driver.Driver {
+	return &hnyDriver{wd: d}
+}
+
+// WrapConnector returns a connector that instruments every connection c
+// makes, like WrapDriver. Open a database with it using sql.OpenDB.
+func WrapConnector(c driver.Connector) driver.Connector {
+	return &hnyConnector{wc: c, driver: &hnyDriver{wd: c.Driver()}}
+}
+
+type hnyDriver struct {
+	wd driver.Driver
+}
+
+func (d *hnyDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.wd.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &hnyConn{wc: c}, nil
+}
+
+func (d *hnyDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.wd.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &hnyConnector{wc: c, driver: d}, nil
+	}
+	return &dsnConnector{name: name, driver: d}, nil
+}
+
+type hnyConnector struct {
+	wc     driver.Connector
+	driver *hnyDriver
+}
+
+func (c *hnyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.wc.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &hnyConn{wc: conn}, nil
+}
+
+func (c *hnyConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dsnConnector is the connector for drivers that only open connections by
+// name, as database/sql uses for them itself.
+type dsnConnector struct {
+	name   string
+	driver *hnyDriver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// hnyConn implements all the optional interfaces database/sql looks for on a
+// connection. Those the wrapped connection doesn't implement return
+// driver.ErrSkip, so database/sql falls back as it would have without the
+// wrapper, or do what database/sql would have done.
+type hnyConn struct {
+	wc driver.Conn
+}
+
+func (c *hnyConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *hnyConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var err error
+	call := startDriverCall(ctx, "Prepare", query, nil)
+	defer func() { call.finish(err) }()
+
+	var stmt driver.Stmt
+	if pc, ok := c.wc.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.wc.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hnyStmt{ws: stmt, wc: c.wc, query: query}, nil
+}
+
+func (c *hnyConn) Close() error {
+	return c.wc.Close()
+}
+
+func (c *hnyConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *hnyConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var err error
+	call := startDriverCall(ctx, "Begin", "", nil)
+	defer func() { call.finish(err) }()
+
+	var tx driver.Tx
+	if bc, ok := c.wc.(driver.ConnBeginTx); ok {
+		tx, err = bc.BeginTx(ctx, opts)
+	} else if opts.Isolation != driver.IsolationLevel(0) || opts.ReadOnly {
+		// the same checks database/sql makes for drivers without BeginTx
+		err = errors.New("sql: driver does not support non-default isolation level or read-only transactions")
+	} else {
+		tx, err = c.wc.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the transaction's commit or rollback is a sibling of its begin
+	return &hnyTx{wt: tx, ctx: ctx}, nil
+}
+
+// ExecContext execs query on the wrapped connection if it implements
+// driver.ExecerContext or the older driver.Execer, calling the older
+// interface as database/sql would have.
+func (c *hnyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, isExecerContext := c.wc.(driver.ExecerContext)
+	e, isExecer := c.wc.(driver.Execer)
+	if !isExecerContext && !isExecer {
+		return nil, driver.ErrSkip
+	}
+	var err error
+	call := startDriverCall(ctx, "Exec", query, args)
+	defer func() { call.finish(err) }()
+
+	var res driver.Result
+	if isExecerContext {
+		res, err = ec.ExecContext(ctx, query, args)
+	} else {
+		var values []driver.Value
+		if values, err = plainValues(args); err == nil {
+			if err = ctx.Err(); err == nil {
+				res, err = e.Exec(query, values)
+			}
+		}
+	}
+	if err == nil {
+		call.addResultFields(res)
+	}
+	return res, err
+}
+
+// QueryContext runs query on the wrapped connection if it implements
+// driver.QueryerContext or the older driver.Queryer, calling the older
+// interface as database/sql would have.
+func (c *hnyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, isQueryerContext := c.wc.(driver.QueryerContext)
+	q, isQueryer := c.wc.(driver.Queryer)
+	if !isQueryerContext && !isQueryer {
+		return nil, driver.ErrSkip
+	}
+	var err error
+	call := startDriverCall(ctx, "Query", query, args)
+	defer func() { call.finish(err) }()
+
+	var rows driver.Rows
+	if isQueryerContext {
+		rows, err = qc.QueryContext(ctx, query, args)
+	} else {
+		var values []driver.Value
+		if values, err = plainValues(args); err == nil {
+			if err = ctx.Err(); err == nil {
+				rows, err = q.Query(query, values)
+			}
+		}
+	}
+	return rows, err
+}
+
+func (c *hnyConn) Ping(ctx context.Context) error {
+	if p, ok := c.wc.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *hnyConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.wc.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *hnyConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := c.wc.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type hnyStmt struct {
+	ws driver.Stmt
+	// wc is the connection the statement was prepared on, whose
+	// NamedValueChecker database/sql would use for a statement without one.
+	wc    driver.Conn
+	query string
+}
+
+func (s *hnyStmt) Close() error {
+	return s.ws.Close()
+}
+
+func (s *hnyStmt) NumInput() int {
+	return s.ws.NumInput()
+}
+
+func (s *hnyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *hnyStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var err error
+	call := startDriverCall(ctx, "Exec", s.query, args)
+	defer func() { call.finish(err) }()
+
+	var res driver.Result
+	if ec, ok := s.ws.(driver.StmtExecContext); ok {
+		res, err = ec.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = plainValues(args); err == nil {
+			res, err = s.ws.Exec(values)
+		}
+	}
+	if err == nil {
+		call.addResultFields(res)
+	}
+	return res, err
+}
+
+func (s *hnyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *hnyStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var err error
+	call := startDriverCall(ctx, "Query", s.query, args)
+	defer func() { call.finish(err) }()
+
+	var rows driver.Rows
+	if qc, ok := s.ws.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = plainValues(args); err == nil {
+			rows, err = s.ws.Query(values)
+		}
+	}
+	return rows, err
+}
+
+func (s *hnyStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := s.ws.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	if nvc, ok := s.wc.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ColumnConverter returns the wrapped statement's converter for the
+// argument at idx if it has them, and otherwise the converter database/sql
+// would have used.
+func (s *hnyStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.ws.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+type hnyTx struct {
+	wt  driver.Tx
+	ctx context.Context
+}
+
+func (tx *hnyTx) Commit() error {
+	var err error
+	call := startDriverCall(tx.ctx, "Commit", "", nil)
+	defer func() { call.finish(err) }()
+	err = tx.wt.Commit()
+	return err
+}
+
+func (tx *hnyTx) Rollback() error {
+	var err error
+	call := startDriverCall(tx.ctx, "Rollback", "", nil)
+	defer func() { call.finish(err) }()
+	err = tx.wt.Rollback()
+	return err
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+func plainValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, nv := range args {
+		if nv.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = nv.Value
+	}
+	return values, nil
+}
+
+// driverCall times one call to a wrapped driver, as a child of the span in
+// its context, or as an event of its own if there is none.
+type driverCall struct {
+	span   *trace.Span
+	ev     *libhoney.Event
+	start  time.Time
+	config *trace.Config
+}
+
+func startDriverCall(ctx context.Context, name, query string, args []driver.NamedValue) *driverCall {
+	call := &driverCall{start: time.Now(), config: trace.GetConfigFromContext(ctx)}
+	if parent := trace.GetSpanFromContext(ctx); parent != nil {
+		_, call.span = parent.CreateChild(ctx)
+	} else if c := trace.GetClientFromContext(ctx); c != nil {
+		// made with the context of a beeline.Beeline
+		call.ev = c.NewBuilder().NewEvent()
+	} else {
+		call.ev = client.NewBuilder().NewEvent()
+	}
+	call.AddField("meta.type", "sql")
+	call.AddField("name", name)
+	call.AddField("db.call", name)
+	var values []interface{}
+	if len(args) > 0 {
+		values = make([]interface{}, len(args))
+		for i, nv := range args {
+			values[i] = nv.Value
+		}
+	}
+	common.AddDBQueryFields(call, query, values)
+	return call
+}
+
+func (c *driverCall) AddField(key string, val interface{}) {
+	if c.span != nil {
+		c.span.AddField(key, val)
+	} else {
+		c.ev.AddField(key, val)
+	}
+}
+
+// GetConfig returns the config the call is recorded with, that of the
+// trace or beeline of its context.
+func (c *driverCall) GetConfig() *trace.Config {
+	return c.config
+}
+
+func (c *driverCall) addResultFields(res driver.Result) {
+	if id, err := res.LastInsertId(); err == nil {
+		c.AddField("db.last_insert_id", id)
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		c.AddField("db.rows_affected", n)
+	}
+}
+
+// finish sends the span or event for the call, unless the driver returned
+// driver.ErrSkip, which means database/sql will make the call another way.
+func (c *driverCall) finish(err error) {
+	if err == driver.ErrSkip {
+		if c.span != nil {
+			c.span.Discard()
+		}
+		return
+	}
+	if err != nil {
+		c.AddField("db.error", err.Error())
+	}
+	if c.span != nil {
+		duration := float64(time.Since(c.start)) / float64(time.Millisecond)
+		c.span.AddRollupField("db.duration_ms", duration)
+		c.span.AddRollupField("db.call_count", 1)
+		c.span.Send()
+		return
+	}
+	duration := time.Since(c.start)
+	c.ev.AddField("duration_ms", float64(duration)/float64(time.Millisecond))
+	if c.config.RecordDurationNanos {
+		c.ev.AddField("duration_ns", int64(duration))
+	}
+	c.ev.Metadata, _ = c.ev.Fields()["name"]
+	c.config.SendEvent(c.ev)
+}
+
+// the interfaces database/sql looks for
+var (
+	_ driver.DriverContext      = &hnyDriver{}
+	_ driver.ConnPrepareContext = &hnyConn{}
+	_ driver.ConnBeginTx        = &hnyConn{}
+	_ driver.ExecerContext      = &hnyConn{}
+	_ driver.QueryerContext     = &hnyConn{}
+	_ driver.Pinger             = &hnyConn{}
+	_ driver.SessionResetter    = &hnyConn{}
+	_ driver.NamedValueChecker  = &hnyConn{}
+	_ driver.StmtExecContext    = &hnyStmt{}
+	_ driver.StmtQueryContext   = &hnyStmt{}
+	_ driver.NamedValueChecker  = &hnyStmt{}
+	_ driver.ColumnConverter    = &hnyStmt{}
+)
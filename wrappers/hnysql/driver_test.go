@@ -0,0 +1,264 @@
+package hnysql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/beelinetest"
+	"github.com/honeycombio/beeline-go/wrappers/hnysql"
+)
+
+func setupDriverTest(t *testing.T, dsn string) (*transmission.MockSender, sqlmock.Sqlmock, driver.Driver) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.Nil(t, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	odb, mock, err := sqlmock.NewWithDSN(dsn)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	return mo, mock, odb.Driver()
+}
+
+func TestWrapDriver(t *testing.T) {
+	mo, mock, drv := setupDriverTest(t, "hnysql_wrap_driver")
+	sql.Register("hnysql-sqlmock", hnysql.WrapDriver(drv))
+	db, err := sql.Open("hnysql-sqlmock", "hnysql_wrap_driver")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("update users.+").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery("select name.+").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("ada"))
+	mock.ExpectCommit()
+	mock.ExpectPrepare("select id.+").ExpectQuery().WithArgs("ada").WillReturnError(errors.New("broken"))
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	tx, err := db.BeginTx(ctx, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = tx.ExecContext(ctx, "update users set active=?", 1)
+	assert.NoError(t, err)
+	var name string
+	assert.NoError(t, tx.QueryRowContext(ctx, "select name from users").Scan(&name))
+	assert.NoError(t, tx.Commit())
+	span.Send()
+
+	// a call without a context is an event of its own
+	stmt, err := db.Prepare("select id from users where name=?")
+	if assert.NoError(t, err) {
+		_, err = stmt.Query("ada")
+		assert.Error(t, err)
+		stmt.Close()
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	evs := mo.Events()
+	if !assert.Equal(t, 7, len(evs)) {
+		return
+	}
+	root := evs[4].Data
+	assert.Equal(t, "request", root["name"])
+	for i, call := range []string{"Begin", "Exec", "Query", "Commit"} {
+		fields := evs[i].Data
+		assert.Equal(t, call, fields["db.call"])
+		assert.Equal(t, "sql", fields["meta.type"])
+		assert.Equal(t, root["trace.span_id"], fields["trace.parent_id"], "%s should be a child of the request", call)
+	}
+	assert.Equal(t, "update users set active=?", evs[1].Data["db.query"])
+	assert.Equal(t, []interface{}{int64(1)}, evs[1].Data["db.query_args"])
+	assert.Equal(t, int64(3), evs[1].Data["db.rows_affected"])
+	assert.Equal(t, float64(4), root["rollup.db.call_count"])
+
+	prepare, query := evs[5].Data, evs[6].Data
+	assert.Equal(t, "Prepare", prepare["db.call"])
+	assert.NotContains(t, prepare, "trace.trace_id")
+	assert.Equal(t, "Query", query["db.call"])
+	assert.Equal(t, "select id from users where name=?", query["db.query"])
+	assert.Equal(t, "broken", query["db.error"])
+}
+
+type mockConnector struct {
+	dsn string
+	drv driver.Driver
+}
+
+func (c mockConnector) Connect(context.Context) (driver.Conn, error) { return c.drv.Open(c.dsn) }
+func (c mockConnector) Driver() driver.Driver                        { return c.drv }
+
+func TestWrapConnector(t *testing.T) {
+	mo, mock, drv := setupDriverTest(t, "hnysql_wrap_connector")
+	db := sql.OpenDB(hnysql.WrapConnector(mockConnector{dsn: "hnysql_wrap_connector", drv: drv}))
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	tx, err := db.Begin()
+	if assert.NoError(t, err) {
+		assert.NoError(t, tx.Rollback())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		assert.Equal(t, "Begin", evs[0].Data["db.call"])
+		assert.Equal(t, "Rollback", evs[1].Data["db.call"])
+	}
+}
+
+// legacyDriver only implements the interfaces database/sql had before
+// contexts: its connections are driver.Execers and driver.Queryers, and its
+// statements convert their arguments with a driver.ColumnConverter.
+type legacyDriver struct {
+	execs   []string
+	queries []string
+	args    []driver.Value
+}
+
+func (d *legacyDriver) Open(string) (driver.Conn, error) { return &legacyConn{d}, nil }
+
+type legacyConn struct{ d *legacyDriver }
+
+func (c *legacyConn) Prepare(query string) (driver.Stmt, error) {
+	return &legacyStmt{d: c.d}, nil
+}
+func (c *legacyConn) Close() error              { return nil }
+func (c *legacyConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+func (c *legacyConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	c.d.execs = append(c.d.execs, query)
+	return driver.RowsAffected(2), nil
+}
+func (c *legacyConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	c.d.queries = append(c.d.queries, query)
+	return &legacyRows{}, nil
+}
+
+// CheckNamedValue accepts point arguments, which database/sql would
+// otherwise reject.
+func (c *legacyConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if p, ok := nv.Value.(point); ok {
+		nv.Value = fmt.Sprintf("(%d,%d)", p.x, p.y)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+type point struct{ x, y int }
+
+type legacyStmt struct{ d *legacyDriver }
+
+func (s *legacyStmt) Close() error  { return nil }
+func (s *legacyStmt) NumInput() int { return 1 }
+func (s *legacyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.args = append(s.d.args, args...)
+	return driver.RowsAffected(1), nil
+}
+func (s *legacyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+func (s *legacyStmt) ColumnConverter(int) driver.ValueConverter { return upperConverter{} }
+
+// upperConverter upper cases string arguments.
+type upperConverter struct{}
+
+func (upperConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if s, ok := v.(string); ok {
+		return strings.ToUpper(s), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+type legacyRows struct{}
+
+func (*legacyRows) Columns() []string              { return []string{"name"} }
+func (*legacyRows) Close() error                   { return nil }
+func (*legacyRows) Next(dest []driver.Value) error { return io.EOF }
+
+func TestWrapDriverLegacyInterfaces(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	d := &legacyDriver{}
+	sql.Register("hnysql-legacy", hnysql.WrapDriver(d))
+	db, err := sql.Open("hnysql-legacy", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec("update users set active=1")
+	assert.NoError(t, err)
+	rows, err := db.Query("select name from users")
+	if assert.NoError(t, err) {
+		rows.Close()
+	}
+	assert.Equal(t, []string{"update users set active=1"}, d.execs, "the connection's Exec should be used, not a prepared statement")
+	assert.Equal(t, []string{"select name from users"}, d.queries, "the connection's Query should be used, not a prepared statement")
+
+	stmt, err := db.Prepare("update users set name=?")
+	if assert.NoError(t, err) {
+		_, err = stmt.Exec("ada")
+		assert.NoError(t, err)
+		_, err = stmt.Exec(point{1, 2})
+		assert.NoError(t, err)
+		stmt.Close()
+	}
+	assert.Equal(t, []driver.Value{"ADA", "(1,2)"}, d.args, "the statement's column converter and the connection's checker should be used")
+
+	var calls []interface{}
+	for _, ev := range rec.Events() {
+		calls = append(calls, ev["db.call"])
+	}
+	assert.Equal(t, []interface{}{"Exec", "Query", "Prepare", "Exec", "Exec"}, calls)
+	assert.Equal(t, int64(2), rec.Events()[0]["db.rows_affected"])
+}
+
+func TestWrapDriverBeelineInstance(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	mo := &transmission.MockSender{}
+	c, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "billing",
+		Dataset:      "billing",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.Nil(t, err)
+	billing, err := beeline.New(beeline.Config{Client: c})
+	if !assert.NoError(t, err) {
+		return
+	}
+	sql.Register("hnysql-legacy-instance", hnysql.WrapDriver(&legacyDriver{}))
+	db, err := sql.Open("hnysql-legacy-instance", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	// a call outside a trace is sent by the beeline of its context
+	_, err = db.ExecContext(billing.Context(context.Background()), "update invoices set paid=1")
+	assert.NoError(t, err)
+
+	if evs := mo.Events(); assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "update invoices set paid=1", evs[0].Data["db.query"])
+	}
+	assert.Equal(t, 0, len(rec.Events()))
+}
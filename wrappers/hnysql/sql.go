@@ -4,10 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/google/uuid"
-
+	"github.com/honeycombio/beeline-go/trace"
 	"github.com/honeycombio/beeline-go/wrappers/common"
 	libhoney "github.com/honeycombio/libhoney-go"
 )
@@ -37,20 +38,25 @@ func WrapDB(s *sql.DB) *DB {
 	return db
 }
 
+// WithCallName returns a context that names the span for the DB call it is
+// passed to, in `name` and `db.call`, instead of after the method called:
+//
+//	db.QueryRowContext(hnysql.WithCallName(ctx, "loadUser"), query, id)
+func WithCallName(ctx context.Context, name string) context.Context {
+	return common.WithDBCallName(ctx, name)
+}
+
 func (db *DB) Begin() (*Tx, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	bld := db.Builder.Clone()
 	wrapTx := &Tx{
 		db:      db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	txid := newid.String()
+	txid := trace.NewSpanID()
 	bld.AddField("db.txId", txid)
 	ev.AddField("db.txId", txid)
 
@@ -62,22 +68,26 @@ func (db *DB) Begin() (*Tx, error) {
 	return wrapTx, err
 }
 
+// BeginTx begins a transaction. If ctx has a span, the transaction gets a
+// span of its own, which is the parent of every call made in the transaction
+// and is sent when it's committed or rolled back. See Tx.
 func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
-
+	txCtx, txSpan := startTxSpan(ctx)
 	// TODO if ctx.Cancel is called, the transaction is rolled back. We should
 	// submit an event indicating the rollback.
 	bld := db.Builder.Clone()
 	wrapTx := &Tx{
 		db:      db,
 		Builder: bld,
+		ctx:     txCtx,
+		span:    txSpan,
 	}
-	newid, _ := uuid.NewRandom()
-	txid := newid.String()
+	defer wrapTx.beginFailed(&err)
+	ctx, span, sender := common.BuildDBSpan(txCtx, db.Builder, db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
+
+	txid := trace.NewSpanID()
 	bld.AddField("db.txId", txid)
 	if span != nil {
 		span.AddField("db.txId", txid)
@@ -87,6 +97,10 @@ func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	if span != nil {
 		span.AddField("db.options", opts)
 	}
+	if txSpan != nil {
+		txSpan.AddField("db.txId", txid)
+		txSpan.AddField("db.options", opts)
+	}
 
 	// do DB call
 	tx, err := db.wdb.BeginTx(ctx, opts)
@@ -99,12 +113,9 @@ func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 func (db *DB) Conn(ctx context.Context) (*Conn, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 	bld := db.Builder.Clone()
-	id, _ := uuid.NewRandom()
-	connid := id.String()
+	connid := trace.NewSpanID()
 	wrapConn := &Conn{
 		db:      db,
 		Builder: bld,
@@ -125,9 +136,7 @@ func (db *DB) Conn(ctx context.Context) (*Conn, error) {
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	res, err := db.wdb.Exec(query, args...)
@@ -149,9 +158,7 @@ func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	res, err := db.wdb.ExecContext(ctx, query, args...)
@@ -177,9 +184,7 @@ func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}
 func (db *DB) Ping() error {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 	err = db.wdb.Ping()
 	return err
 }
@@ -187,9 +192,7 @@ func (db *DB) Ping() error {
 func (db *DB) PingContext(ctx context.Context) error {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 	err = db.wdb.Ping()
 	return err
 }
@@ -197,13 +200,10 @@ func (db *DB) PingContext(ctx context.Context) error {
 func (db *DB) Prepare(query string) (*Stmt, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	bld := db.Builder.Clone()
-	id, _ := uuid.NewRandom()
-	stmtid := id.String()
+	stmtid := trace.NewSpanID()
 	wrapStmt := &Stmt{
 		db:      db,
 		Builder: bld,
@@ -211,7 +211,7 @@ func (db *DB) Prepare(query string) (*Stmt, error) {
 	bld.AddField("db.stmtId", stmtid)
 	// add the query to the builder so all executions of this prepared statement
 	// have the query right there
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 	ev.AddField("db.stmtId", stmtid)
 
 	// do DB call
@@ -223,13 +223,10 @@ func (db *DB) Prepare(query string) (*Stmt, error) {
 func (db *DB) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	bld := db.Builder.Clone()
-	id, _ := uuid.NewRandom()
-	stmtid := id.String()
+	stmtid := trace.NewSpanID()
 	wrapStmt := &Stmt{
 		db:      db,
 		Builder: bld,
@@ -237,7 +234,7 @@ func (db *DB) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	bld.AddField("db.stmtId", stmtid)
 	// add the query to the builder so all executions of this prepared statement
 	// have the query right there
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 	if span != nil {
 		span.AddField("db.stmtId", stmtid)
 	}
@@ -251,9 +248,7 @@ func (db *DB) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := db.wdb.Query(query, args...)
@@ -264,9 +259,7 @@ func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := db.wdb.QueryContext(ctx, query, args...)
@@ -274,16 +267,18 @@ func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{
 }
 
 func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args)
-	defer sender(nil)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := db.wdb.QueryRow(query, args...)
 	return row
 }
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args)
-	defer sender(nil)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := db.wdb.QueryRowContext(ctx, query, args...)
@@ -293,9 +288,7 @@ func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interfa
 func (db *DB) Close() error {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 	err = db.wdb.Close()
 	return err
 }
@@ -313,21 +306,24 @@ type Conn struct {
 	Builder *libhoney.Builder
 }
 
+// BeginTx begins a transaction on the connection, with a span of its own if
+// ctx has a span, as DB.BeginTx does.
 func (c *Conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, c.Builder, c.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	txCtx, txSpan := startTxSpan(ctx)
 	// TODO if ctx.Cancel is called, the transaction is rolled back. We should
 	// submit an event indicating the rollback.
 	bld := c.Builder.Clone()
-	id, _ := uuid.NewRandom()
-	txid := id.String()
+	txid := trace.NewSpanID()
 	wrapTx := &Tx{
 		db:      c.db,
 		Builder: bld,
+		ctx:     txCtx,
+		span:    txSpan,
 	}
+	defer wrapTx.beginFailed(&err)
+	ctx, span, sender := common.BuildDBSpan(txCtx, c.Builder, c.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 	bld.AddField("db.txId", txid)
 	if span != nil {
 		span.AddField("db.txId", txid)
@@ -337,6 +333,10 @@ func (c *Conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 		span.AddField("db.options", opts)
 	}
 	bld.AddField("db.options", opts)
+	if txSpan != nil {
+		txSpan.AddField("db.txId", txid)
+		txSpan.AddField("db.options", opts)
+	}
 
 	// do DB call
 	tx, err := c.wconn.BeginTx(ctx, opts)
@@ -349,9 +349,7 @@ func (c *Conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 func (c *Conn) Close() error {
 	var err error
 	_, sender := common.BuildDBEvent(c.Builder, c.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	err = c.wconn.Close()
@@ -361,9 +359,7 @@ func (c *Conn) Close() error {
 func (c *Conn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, c.Builder, c.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	res, err := c.wconn.ExecContext(ctx, query, args...)
@@ -389,9 +385,7 @@ func (c *Conn) ExecContext(ctx context.Context, query string, args ...interface{
 func (c *Conn) PingContext(ctx context.Context) error {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, c.Builder, c.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 	err = c.wconn.PingContext(ctx)
 	return err
 }
@@ -399,19 +393,16 @@ func (c *Conn) PingContext(ctx context.Context) error {
 func (c *Conn) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, c.Builder, c.db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	bld := c.Builder.Clone()
-	id, _ := uuid.NewRandom()
-	stmtid := id.String()
+	stmtid := trace.NewSpanID()
 	wrapStmt := &Stmt{
 		db:      c.db,
 		Builder: bld,
 	}
 	bld.AddField("db.stmtId", stmtid)
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 	if span != nil {
 		span.AddField("db.stmtId", stmtid)
 	}
@@ -427,9 +418,7 @@ func (c *Conn) PrepareContext(ctx context.Context, query string) (*Stmt, error)
 func (c *Conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, c.Builder, c.db.Stats(), query, args)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := c.wconn.QueryContext(ctx, query, args...)
@@ -437,8 +426,9 @@ func (c *Conn) QueryContext(ctx context.Context, query string, args ...interface
 }
 
 func (c *Conn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, c.Builder, c.db.Stats(), query, args)
-	defer sender(nil)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := c.wconn.QueryRowContext(ctx, query, args...)
@@ -454,9 +444,7 @@ type Stmt struct {
 func (s *Stmt) Close() error {
 	var err error
 	_, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 	err = s.wstmt.Close()
 	return err
 }
@@ -464,9 +452,7 @@ func (s *Stmt) Close() error {
 func (s *Stmt) Exec(args ...interface{}) (sql.Result, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	res, err := s.wstmt.Exec(args...)
@@ -488,9 +474,7 @@ func (s *Stmt) Exec(args ...interface{}) (sql.Result, error) {
 func (s *Stmt) ExecContext(ctx context.Context, args ...interface{}) (sql.Result, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	res, err := s.wstmt.ExecContext(ctx, args...)
@@ -516,9 +500,7 @@ func (s *Stmt) ExecContext(ctx context.Context, args ...interface{}) (sql.Result
 func (s *Stmt) Query(args ...interface{}) (*sql.Rows, error) {
 	var err error
 	_, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "", args)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := s.wstmt.Query(args...)
@@ -528,9 +510,7 @@ func (s *Stmt) Query(args ...interface{}) (*sql.Rows, error) {
 func (s *Stmt) QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, s.Builder, s.db.Stats(), "", args)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := s.wstmt.QueryContext(ctx, args...)
@@ -538,8 +518,9 @@ func (s *Stmt) QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows
 }
 
 func (s *Stmt) QueryRow(args ...interface{}) *sql.Row {
+	var err error
 	_, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "", args)
-	defer sender(nil)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := s.wstmt.QueryRow(args...)
@@ -547,27 +528,103 @@ func (s *Stmt) QueryRow(args ...interface{}) *sql.Row {
 }
 
 func (s *Stmt) QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row {
+	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, s.Builder, s.db.Stats(), "", args)
-	defer sender(nil)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := s.wstmt.QueryRowContext(ctx, args...)
 	return row
 }
 
+// Tx is a transaction. One begun with a context holding a span has a span
+// of its own, named "transaction", and every call made in the transaction is
+// its child, whatever context the call is made with. The span is sent by the
+// first Commit or Rollback, recording which in `db.tx_outcome`, along with
+// the number of statements run in the transaction in
+// `db.tx_statement_count` and the time spent running them in
+// `db.tx_statement_duration_ms`, so that a transaction's time waiting on
+// the application shows up next to its time in the database.
 type Tx struct {
 	db *DB
 	// wtx is the wrapped transaction
 	wtx     *sql.Tx
 	Builder *libhoney.Builder
+
+	// ctx is the context the transaction was begun with, if any. If it had a
+	// span, span times the transaction.
+	ctx     context.Context
+	span    *trace.Span
+	endOnce sync.Once
+	// statements and statementNanos total the statements run in the
+	// transaction.
+	statements     int64
+	statementNanos int64
+}
+
+// startTxSpan starts the span for a transaction begun with ctx, if ctx has a
+// span to be its parent, and returns ctx with it in.
+func startTxSpan(ctx context.Context) (context.Context, *trace.Span) {
+	parent := trace.GetSpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	ctx, span := parent.CreateChild(ctx)
+	span.AddField("meta.type", "sql")
+	span.AddField("name", "transaction")
+	return ctx, span
+}
+
+// beginFailed sends the transaction's span if beginning the transaction
+// failed with the error errp points to.
+func (tx *Tx) beginFailed(errp *error) {
+	if *errp != nil {
+		tx.end("begin_failed", errp)
+	}
+}
+
+// end sends the transaction's span, recording how the transaction ended.
+// Only the first call counts, so a deferred Rollback after a Commit doesn't
+// change the outcome.
+func (tx *Tx) end(outcome string, errp *error) {
+	tx.endOnce.Do(func() {
+		if tx.span == nil {
+			return
+		}
+		tx.span.AddField("db.tx_outcome", outcome)
+		tx.span.AddField("db.tx_statement_count", atomic.LoadInt64(&tx.statements))
+		tx.span.AddField("db.tx_statement_duration_ms", float64(atomic.LoadInt64(&tx.statementNanos))/float64(time.Millisecond))
+		if *errp != nil {
+			tx.span.AddField("db.error", (*errp).Error())
+		}
+		tx.span.Send()
+	})
+}
+
+// statement counts a statement run in the transaction, and returns the
+// function to call, deferred, when it has run.
+func (tx *Tx) statement() func() {
+	start := time.Now()
+	return func() {
+		atomic.AddInt64(&tx.statements, 1)
+		atomic.AddInt64(&tx.statementNanos, int64(time.Since(start)))
+	}
+}
+
+// context returns ctx with the transaction's span in, if it has one, so that
+// calls made in the transaction are its children.
+func (tx *Tx) context(ctx context.Context) context.Context {
+	if tx.span == nil {
+		return ctx
+	}
+	return trace.PutSpanInContext(ctx, tx.span)
 }
 
 func (tx *Tx) Commit() error {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer tx.end("commit", &err)
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	err = tx.wtx.Commit()
@@ -576,10 +633,9 @@ func (tx *Tx) Commit() error {
 
 func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer tx.statement()()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	res, err := tx.wtx.Exec(query, args...)
@@ -600,10 +656,9 @@ func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
 
 func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer tx.statement()()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	res, err := tx.wtx.ExecContext(ctx, query, args...)
@@ -628,21 +683,19 @@ func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}
 
 func (tx *Tx) Prepare(query string) (*Stmt, error) {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer tx.statement()()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query)
+	defer common.FinishDBCall(sender, &err)
 
 	bld := tx.Builder.Clone()
-	id, _ := uuid.NewRandom()
-	stmtid := id.String()
+	stmtid := trace.NewSpanID()
 	wrapStmt := &Stmt{
 		db:      tx.db,
 		Builder: bld,
 	}
 	bld.AddField("db.stmtId", stmtid)
 	ev.AddField("db.stmtId", stmtid)
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 
 	// do DB call
 	stmt, err := tx.wtx.Prepare(query)
@@ -652,14 +705,12 @@ func (tx *Tx) Prepare(query string) (*Stmt, error) {
 
 func (tx *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer tx.statement()()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query)
+	defer common.FinishDBCall(sender, &err)
 
 	bld := tx.Builder.Clone()
-	id, _ := uuid.NewRandom()
-	stmtid := id.String()
+	stmtid := trace.NewSpanID()
 	wrapStmt := &Stmt{
 		db:      tx.db,
 		Builder: bld,
@@ -668,7 +719,7 @@ func (tx *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	if span != nil {
 		span.AddField("db.stmtId", stmtid)
 	}
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 
 	// do DB call
 	stmt, err := tx.wtx.PrepareContext(ctx, query)
@@ -678,10 +729,9 @@ func (tx *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 
 func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args)
-	defer func() {
-		sender(err)
-	}()
+	defer tx.statement()()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := tx.wtx.Query(query, args...)
@@ -690,10 +740,9 @@ func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
 
 func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	var err error
-	ctx, _, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args)
-	defer func() {
-		sender(err)
-	}()
+	defer tx.statement()()
+	ctx, _, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := tx.wtx.QueryContext(ctx, query, args...)
@@ -701,8 +750,10 @@ func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{
 }
 
 func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args)
-	defer sender(nil)
+	var err error
+	defer tx.statement()()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := tx.wtx.QueryRow(query, args...)
@@ -710,8 +761,10 @@ func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
 }
 
 func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	ctx, _, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args)
-	defer sender(nil)
+	var err error
+	defer tx.statement()()
+	ctx, _, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args)
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := tx.wtx.QueryRowContext(ctx, query, args...)
@@ -720,10 +773,9 @@ func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interfa
 
 func (tx *Tx) Rollback() error {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer tx.end("rollback", &err)
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	err = tx.wtx.Rollback()
@@ -731,8 +783,9 @@ func (tx *Tx) Rollback() error {
 }
 
 func (tx *Tx) Stmt(stmt *Stmt) *Stmt {
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), "")
-	defer sender(nil)
+	var err error
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	bld := stmt.Builder.Clone()
 	wrapStmt := &Stmt{
@@ -751,8 +804,9 @@ func (tx *Tx) Stmt(stmt *Stmt) *Stmt {
 }
 
 func (tx *Tx) StmtContext(ctx context.Context, stmt *Stmt) *Stmt {
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), "")
-	defer sender(nil)
+	var err error
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	bld := stmt.Builder.Clone()
 	wrapStmt := &Stmt{
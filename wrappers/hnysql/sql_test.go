@@ -3,15 +3,20 @@ package hnysql_test
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/go-sql-driver/mysql"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/client"
+	"github.com/honeycombio/beeline-go/trace"
 	"github.com/honeycombio/beeline-go/wrappers/hnysql"
 )
 
@@ -113,3 +118,257 @@ func TestSQLMiddleware(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+// panicValuer panics when database/sql converts it to a driver value, which
+// happens inside the wrapped call.
+type panicValuer struct{}
+
+func (panicValuer) Value() (driver.Value, error) {
+	panic("bad value")
+}
+
+func TestSQLPanicSendsEvent(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.Nil(t, err)
+
+	odb, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer odb.Close()
+	db := hnysql.WrapDB(odb)
+	db.Builder = client.NewBuilder()
+
+	func() {
+		defer func() {
+			assert.Equal(t, "bad value", recover(), "the panic should be passed on")
+		}()
+		db.QueryRow("SELECT id FROM flavors WHERE flavor=?", panicValuer{})
+	}()
+
+	events := mo.Events()
+	if assert.Equal(t, 1, len(events), "the panicking call should still send an event") {
+		assert.Equal(t, "panic", events[0].Data["error"])
+		assert.Equal(t, "bad value", events[0].Data["panic.value"])
+		assert.Equal(t, "SELECT id FROM flavors WHERE flavor=?", events[0].Data["db.query"])
+	}
+}
+
+// TestSQLWithoutInit makes sure wrapped calls still work when the beeline
+// isn't initialized.
+func TestSQLWithoutInit(t *testing.T) {
+	client.Set(nil)
+	odb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer odb.Close()
+	mock.ExpectExec("insert into flavors.+").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	db := hnysql.WrapDB(odb)
+	res, err := db.ExecContext(context.Background(), "insert into flavors (flavor) values ('rose')")
+	if assert.NoError(t, err) {
+		n, _ := res.RowsAffected()
+		assert.Equal(t, int64(1), n)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLPresendHook(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.Nil(t, err)
+	beeline.Init(beeline.Config{
+		Client: client,
+		PresendHook: func(fields map[string]interface{}) {
+			if _, ok := fields["db.query_args"]; ok {
+				fields["db.query_args"] = "[redacted]"
+			}
+		},
+	})
+	defer beeline.Init(beeline.Config{Client: client})
+
+	odb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer odb.Close()
+	mock.ExpectExec("update users.+").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("update users.+").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := hnysql.WrapDB(odb)
+	db.Builder = client.NewBuilder()
+	// once as an event of its own, and once as a span in a trace
+	_, err = db.Exec("update users set email=?", "ada@example.com")
+	assert.NoError(t, err)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	_, err = db.ExecContext(ctx, "update users set email=?", "ada@example.com")
+	assert.NoError(t, err)
+	span.Send()
+
+	events := mo.Events()
+	if assert.Equal(t, 3, len(events)) {
+		for _, ev := range events[:2] {
+			assert.Equal(t, "[redacted]", ev.Data["db.query_args"], "the hook should scrub every DB call")
+		}
+	}
+}
+
+func TestSQLNormalizedQueries(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.Nil(t, err)
+	beeline.Init(beeline.Config{
+		Client:          client,
+		DBQueryMode:     trace.DBQueryNormalized,
+		OmitDBQueryArgs: true,
+	})
+	defer beeline.Init(beeline.Config{Client: client})
+
+	odb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer odb.Close()
+	mock.ExpectExec("update users.+").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := hnysql.WrapDB(odb)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	_, err = db.ExecContext(ctx, "update users set email='ada@example.com' where id=?", 42)
+	assert.NoError(t, err)
+	span.Send()
+
+	events := mo.Events()
+	if assert.Equal(t, 2, len(events)) {
+		assert.Equal(t, "update users set email=? where id=?", events[0].Data["db.query"])
+		assert.NotContains(t, events[0].Data, "db.query_args")
+	}
+}
+
+func TestSQLCallNames(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.Nil(t, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	odb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer odb.Close()
+	mock.ExpectExec("update users.+").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("update users.+").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := hnysql.WrapDB(odb)
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	_, err = db.ExecContext(ctx, "update users set email=?", "ada@example.com")
+	assert.NoError(t, err)
+	_, err = db.ExecContext(hnysql.WithCallName(ctx, "updateEmail"), "update users set email=?", "ada@example.com")
+	assert.NoError(t, err)
+	span.Send()
+
+	events := mo.Events()
+	if assert.Equal(t, 3, len(events)) {
+		assert.Equal(t, "ExecContext", events[0].Data["db.call"])
+		assert.Equal(t, "ExecContext", events[0].Data["name"])
+		assert.Equal(t, "TestSQLCallNames", events[0].Data["db.caller"])
+		assert.Equal(t, "updateEmail", events[1].Data["db.call"])
+		assert.Equal(t, "updateEmail", events[1].Data["name"])
+		assert.Equal(t, "TestSQLCallNames", events[1].Data["db.caller"])
+	}
+}
+
+func TestSQLTransactionSpan(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.Nil(t, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	odb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer odb.Close()
+	db := hnysql.WrapDB(odb)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("update flavors.+").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("insert into flavors.+").WithArgs("rose").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("delete from flavors.+").WillReturnError(fmt.Errorf("locked"))
+	mock.ExpectRollback()
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	tx, err := db.BeginTx(ctx, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// neither call's context has the transaction in it
+	_, err = tx.Exec("update flavors set stocked=1")
+	assert.NoError(t, err)
+	_, err = tx.ExecContext(context.Background(), "insert into flavors (flavor) values (?)", "rose")
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+	// a deferred rollback after committing doesn't change the transaction
+	assert.Error(t, tx.Rollback())
+
+	tx, err = db.BeginTx(ctx, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = tx.ExecContext(ctx, "delete from flavors where stocked=0")
+	assert.Error(t, err)
+	assert.NoError(t, tx.Rollback())
+	span.Send()
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	evs := mo.Events()
+	if !assert.Equal(t, 11, len(evs)) {
+		return
+	}
+	committed, rolledBack, root := evs[4].Data, evs[9].Data, evs[10].Data
+	assert.Equal(t, "transaction", committed["name"])
+	assert.Equal(t, "commit", committed["db.tx_outcome"])
+	assert.Equal(t, int64(2), committed["db.tx_statement_count"])
+	assert.Contains(t, committed, "db.tx_statement_duration_ms")
+	assert.Equal(t, root["trace.span_id"], committed["trace.parent_id"])
+	for i, call := range []string{"BeginTx", "Exec", "ExecContext", "Commit"} {
+		fields := evs[i].Data
+		assert.Equal(t, call, fields["db.call"])
+		assert.Equal(t, committed["trace.span_id"], fields["trace.parent_id"], "%s should be a child of the transaction", call)
+	}
+	// the rollback after the commit is still recorded, on its own
+	assert.Equal(t, "Rollback", evs[5].Data["db.call"])
+
+	assert.Equal(t, "rollback", rolledBack["db.tx_outcome"])
+	assert.Equal(t, int64(1), rolledBack["db.tx_statement_count"])
+	assert.Equal(t, rolledBack["trace.span_id"], evs[7].Data["trace.parent_id"])
+	assert.Equal(t, "locked", evs[7].Data["db.error"])
+}
@@ -0,0 +1,22 @@
+package hnysql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// ReportStats sends a db_stats event with the state of db's connection pool
+// every interval, until the returned function is called. DB spans record
+// the pool's state as the call starts. These events also record how many
+// calls waited for a connection during the interval, and for how long. That
+// shows whether slow calls are slow queries or an exhausted pool, even for
+// a DB instrumented with WrapDriver or hnysqlx. name, if set, is recorded
+// as db.name so that pools can be told apart.
+//
+//	stop := hnysql.ReportStats(db, "primary", 10*time.Second)
+//	defer stop()
+func ReportStats(db *sql.DB, name string, interval time.Duration) (stop func()) {
+	return common.ReportDBStats(db.Stats, name, interval)
+}
@@ -0,0 +1,51 @@
+package hnysql_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/wrappers/hnysql"
+)
+
+func TestReportStats(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.Nil(t, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	db, _, err := sqlmock.New()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(4)
+
+	stop := hnysql.ReportStats(db, "primary", 5*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for len(mo.Events()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	stop()
+
+	evs := mo.Events()
+	if assert.NotEmpty(t, evs) {
+		fields := evs[0].Data
+		assert.Equal(t, "db_stats", fields["name"])
+		assert.Equal(t, "primary", fields["db.name"])
+		assert.Equal(t, 4, fields["db.max_open_conns"])
+		assert.Equal(t, int64(0), fields["db.wait_count_delta"])
+		assert.Contains(t, fields, "db.conns_in_use")
+	}
+}
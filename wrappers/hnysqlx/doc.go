@@ -20,6 +20,12 @@
 // dramatically increases the value of the SQL isntrumentation by letting you
 // tie it back to individual HTTP requests.
 //
+// A transaction begun with BeginTxx or MustBeginTx in a trace gets a span of
+// its own, named "transaction", which is sent when the transaction is
+// committed or rolled back. Every call made in the transaction is a child of
+// it, including calls made without a context, and all of them have the same
+// db.tx_id.
+//
 // If you need to differentiate multiple DB connections, there is a
 // *libhoney.Builder associated with the *hnysqlx.DB (as well as with
 // transactions and statements). Adding fields to this builder will add those
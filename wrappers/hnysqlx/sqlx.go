@@ -5,12 +5,13 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"reflect"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/jmoiron/sqlx/reflectx"
 
+	"github.com/honeycombio/beeline-go/trace"
 	"github.com/honeycombio/beeline-go/wrappers/common"
 	libhoney "github.com/honeycombio/libhoney-go"
 )
@@ -42,6 +43,14 @@ func WrapDB(s *sqlx.DB) *DB {
 	return db
 }
 
+// WithCallName returns a context that names the span for the DB call it is
+// passed to, in `name` and `db.call`, instead of after the method called:
+//
+//	db.GetContext(hnysqlx.WithCallName(ctx, "loadUser"), &user, query, id)
+func WithCallName(ctx context.Context, name string) context.Context {
+	return common.WithDBCallName(ctx, name)
+}
+
 func (db *DB) GetWrappedDB() *sqlx.DB {
 	return db.wdb
 }
@@ -49,9 +58,7 @@ func (db *DB) GetWrappedDB() *sqlx.DB {
 func (db *DB) Beginx() (*Tx, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -59,8 +66,7 @@ func (db *DB) Beginx() (*Tx, error) {
 	}
 
 	bld := db.Builder.Clone()
-	newid, _ := uuid.NewRandom()
-	txid := newid.String()
+	txid := trace.NewSpanID()
 	wrapTx := &Tx{
 		db:      db,
 		Builder: bld,
@@ -76,10 +82,10 @@ func (db *DB) Beginx() (*Tx, error) {
 
 func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	txCtx, txSpan := startTxSpan(ctx)
+	defer endTxSpan(txSpan, "", &err)
+	ctx, span, sender := common.BuildDBSpan(txCtx, db.Builder, db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -90,9 +96,10 @@ func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	wrapTx := &Tx{
 		db:      db,
 		Builder: bld,
+		ctx:     txCtx,
+		span:    txSpan,
 	}
-	newid, _ := uuid.NewRandom()
-	txid := newid.String()
+	txid := trace.NewSpanID()
 	bld.AddField("db.tx_id", txid)
 	if span != nil {
 		span.AddField("db.tx_id", txid)
@@ -102,6 +109,10 @@ func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	if span != nil {
 		span.AddField("db.options", opts)
 	}
+	if txSpan != nil {
+		txSpan.AddField("db.tx_id", txid)
+		txSpan.AddField("db.options", opts)
+	}
 
 	// do DB call
 	tx, err := db.wdb.BeginTxx(ctx, opts)
@@ -112,9 +123,7 @@ func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -141,9 +150,7 @@ func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -174,9 +181,7 @@ func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}
 func (db *DB) Get(dest interface{}, query string, args ...interface{}) error {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -194,9 +199,7 @@ func (db *DB) Get(dest interface{}, query string, args ...interface{}) error {
 func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -216,9 +219,7 @@ func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, ar
 func (db *DB) MapperFunc(mf func(string) string) {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -236,9 +237,7 @@ func (db *DB) MapperFunc(mf func(string) string) {
 func (db *DB) MustBegin() *Tx {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -250,8 +249,7 @@ func (db *DB) MustBegin() *Tx {
 		db:      db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	txid := newid.String()
+	txid := trace.NewSpanID()
 	bld.AddField("db.tx_id", txid)
 	ev.AddField("db.tx_id", txid)
 
@@ -269,10 +267,10 @@ func (db *DB) MustBegin() *Tx {
 
 func (db *DB) MustBeginTx(ctx context.Context, opts *sql.TxOptions) *Tx {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	txCtx, txSpan := startTxSpan(ctx)
+	defer endTxSpan(txSpan, "", &err)
+	ctx, span, sender := common.BuildDBSpan(txCtx, db.Builder, db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -283,9 +281,10 @@ func (db *DB) MustBeginTx(ctx context.Context, opts *sql.TxOptions) *Tx {
 	wrapTx := &Tx{
 		db:      db,
 		Builder: bld,
+		ctx:     txCtx,
+		span:    txSpan,
 	}
-	newid, _ := uuid.NewRandom()
-	txid := newid.String()
+	txid := trace.NewSpanID()
 	bld.AddField("db.tx_id", txid)
 	if span != nil {
 		span.AddField("db.tx_id", txid)
@@ -295,6 +294,10 @@ func (db *DB) MustBeginTx(ctx context.Context, opts *sql.TxOptions) *Tx {
 	if span != nil {
 		span.AddField("db.options", opts)
 	}
+	if txSpan != nil {
+		txSpan.AddField("db.tx_id", txid)
+		txSpan.AddField("db.options", opts)
+	}
 
 	// do DB call
 	tx, err := db.wdb.BeginTxx(ctx, opts)
@@ -314,9 +317,7 @@ func (db *DB) MustBeginTx(ctx context.Context, opts *sql.TxOptions) *Tx {
 func (db *DB) MustExec(query string, args ...interface{}) sql.Result {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -348,9 +349,7 @@ func (db *DB) MustExec(query string, args ...interface{}) sql.Result {
 func (db *DB) MustExecContext(ctx context.Context, query string, args ...interface{}) sql.Result {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -387,9 +386,7 @@ func (db *DB) MustExecContext(ctx context.Context, query string, args ...interfa
 func (db *DB) NamedExec(query string, arg interface{}) (sql.Result, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -416,9 +413,7 @@ func (db *DB) NamedExec(query string, arg interface{}) (sql.Result, error) {
 func (db *DB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -449,9 +444,7 @@ func (db *DB) NamedExecContext(ctx context.Context, query string, arg interface{
 func (db *DB) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -466,9 +459,7 @@ func (db *DB) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
 func (db *DB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -483,9 +474,7 @@ func (db *DB) NamedQueryContext(ctx context.Context, query string, arg interface
 func (db *DB) Ping() error {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -499,9 +488,7 @@ func (db *DB) Ping() error {
 func (db *DB) PingContext(ctx context.Context) error {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -515,9 +502,7 @@ func (db *DB) PingContext(ctx context.Context) error {
 func (db *DB) PrepareNamed(query string) (*NamedStmt, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -529,14 +514,13 @@ func (db *DB) PrepareNamed(query string) (*NamedStmt, error) {
 		db:      db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	ev.AddField("db.stmt_id", stmtid)
 
 	// add the query to the statement's builder so all executions of this query
 	// have it right there
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 
 	// do DB call
 	stmt, err := db.wdb.PrepareNamed(query)
@@ -547,9 +531,7 @@ func (db *DB) PrepareNamed(query string) (*NamedStmt, error) {
 func (db *DB) PrepareNamedContext(ctx context.Context, query string) (*NamedStmt, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -561,8 +543,7 @@ func (db *DB) PrepareNamedContext(ctx context.Context, query string) (*NamedStmt
 		db:      db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	if span != nil {
 		span.AddField("db.stmt_id", stmtid)
@@ -577,9 +558,7 @@ func (db *DB) PrepareNamedContext(ctx context.Context, query string) (*NamedStmt
 func (db *DB) Preparex(query string) (*Stmt, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -591,8 +570,7 @@ func (db *DB) Preparex(query string) (*Stmt, error) {
 		db:      db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	ev.AddField("db.stmt_id", stmtid)
 
@@ -605,9 +583,7 @@ func (db *DB) Preparex(query string) (*Stmt, error) {
 func (db *DB) PreparexContext(ctx context.Context, query string) (*Stmt, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -619,8 +595,7 @@ func (db *DB) PreparexContext(ctx context.Context, query string) (*Stmt, error)
 		db:      db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	if span != nil {
 		span.AddField("db.stmt_id", stmtid)
@@ -635,9 +610,7 @@ func (db *DB) PreparexContext(ctx context.Context, query string) (*Stmt, error)
 func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -652,9 +625,7 @@ func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -670,9 +641,7 @@ func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{
 func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -687,9 +656,7 @@ func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -704,9 +671,7 @@ func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interfa
 func (db *DB) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -721,9 +686,7 @@ func (db *DB) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
 func (db *DB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -738,9 +701,7 @@ func (db *DB) QueryxContext(ctx context.Context, query string, args ...interface
 func (db *DB) QueryRowx(query string, args ...interface{}) *sqlx.Row {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -755,9 +716,7 @@ func (db *DB) QueryRowx(query string, args ...interface{}) *sqlx.Row {
 func (db *DB) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -772,9 +731,7 @@ func (db *DB) QueryRowxContext(ctx context.Context, query string, args ...interf
 func (db *DB) Rebind(query string) string {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -788,9 +745,7 @@ func (db *DB) Rebind(query string) string {
 func (db *DB) Select(dest interface{}, query string, args ...interface{}) error {
 	var err error
 	ev, sender := common.BuildDBEvent(db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -807,9 +762,7 @@ func (db *DB) Select(dest interface{}, query string, args ...interface{}) error
 func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, db.Builder, db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -829,9 +782,7 @@ func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string,
 func (db *DB) Close() error {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -846,9 +797,7 @@ func (db *DB) Close() error {
 func (db *DB) Driver() driver.Driver {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -861,9 +810,7 @@ func (db *DB) Driver() driver.Driver {
 func (db *DB) SetConnMaxLifetime(d time.Duration) {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -876,9 +823,7 @@ func (db *DB) SetConnMaxLifetime(d time.Duration) {
 func (db *DB) SetMaxIdleConns(n int) {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -891,9 +836,7 @@ func (db *DB) SetMaxIdleConns(n int) {
 func (db *DB) SetMaxOpenConns(n int) {
 	var err error
 	_, sender := common.BuildDBEvent(db.Builder, db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if db.Mapper != nil {
@@ -925,9 +868,7 @@ func (n *NamedStmt) GetWrappedNamedStmt() *sqlx.NamedStmt {
 func (n *NamedStmt) Close() error {
 	var err error
 	_, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	err = n.wns.Close()
 	return err
@@ -936,9 +877,7 @@ func (n *NamedStmt) Close() error {
 func (n *NamedStmt) Exec(arg interface{}) (sql.Result, error) {
 	var err error
 	ev, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	res, err := n.wns.Exec(arg)
 
@@ -959,9 +898,7 @@ func (n *NamedStmt) Exec(arg interface{}) (sql.Result, error) {
 func (n *NamedStmt) ExecContext(ctx context.Context, arg interface{}) (sql.Result, error) {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	res, err := n.wns.ExecContext(ctx, arg)
 
@@ -986,9 +923,7 @@ func (n *NamedStmt) ExecContext(ctx context.Context, arg interface{}) (sql.Resul
 func (n *NamedStmt) Get(dest interface{}, arg interface{}) error {
 	var err error
 	ev, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// add the type of the objec being populated
 	ev.AddField("db.dest_type", typeof(dest))
@@ -1000,9 +935,7 @@ func (n *NamedStmt) Get(dest interface{}, arg interface{}) error {
 func (n *NamedStmt) GetContext(ctx context.Context, dest interface{}, arg interface{}) error {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// add the type of the objec being populated
 	if span != nil {
@@ -1016,9 +949,7 @@ func (n *NamedStmt) GetContext(ctx context.Context, dest interface{}, arg interf
 func (n *NamedStmt) MustExec(arg interface{}) sql.Result {
 	var err error
 	ev, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	res, err := n.wns.Exec(arg)
@@ -1044,9 +975,7 @@ func (n *NamedStmt) MustExec(arg interface{}) sql.Result {
 func (n *NamedStmt) MustExecContext(ctx context.Context, arg interface{}) sql.Result {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	res, err := n.wns.ExecContext(ctx, arg)
@@ -1078,9 +1007,7 @@ func (n *NamedStmt) MustExecContext(ctx context.Context, arg interface{}) sql.Re
 func (n *NamedStmt) Query(arg interface{}) (*sql.Rows, error) {
 	var err error
 	_, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := n.wns.Query(arg)
@@ -1090,9 +1017,7 @@ func (n *NamedStmt) Query(arg interface{}) (*sql.Rows, error) {
 func (n *NamedStmt) QueryContext(ctx context.Context, arg interface{}) (*sql.Rows, error) {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := n.wns.QueryContext(ctx, arg)
@@ -1102,9 +1027,7 @@ func (n *NamedStmt) QueryContext(ctx context.Context, arg interface{}) (*sql.Row
 func (n *NamedStmt) QueryRow(arg interface{}) *sqlx.Row {
 	var err error
 	_, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := n.wns.QueryRow(arg)
@@ -1114,9 +1037,7 @@ func (n *NamedStmt) QueryRow(arg interface{}) *sqlx.Row {
 func (n *NamedStmt) QueryRowContext(ctx context.Context, arg interface{}) *sqlx.Row {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := n.wns.QueryRowContext(ctx, arg)
@@ -1126,9 +1047,7 @@ func (n *NamedStmt) QueryRowContext(ctx context.Context, arg interface{}) *sqlx.
 func (n *NamedStmt) QueryRowx(arg interface{}) *sqlx.Row {
 	var err error
 	_, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := n.wns.QueryRowx(arg)
@@ -1138,9 +1057,7 @@ func (n *NamedStmt) QueryRowx(arg interface{}) *sqlx.Row {
 func (n *NamedStmt) QueryRowxContext(ctx context.Context, arg interface{}) *sqlx.Row {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	row := n.wns.QueryRowxContext(ctx, arg)
@@ -1150,9 +1067,7 @@ func (n *NamedStmt) QueryRowxContext(ctx context.Context, arg interface{}) *sqlx
 func (n *NamedStmt) Queryx(arg interface{}) (*sqlx.Rows, error) {
 	var err error
 	_, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := n.wns.Queryx(arg)
@@ -1162,9 +1077,7 @@ func (n *NamedStmt) Queryx(arg interface{}) (*sqlx.Rows, error) {
 func (n *NamedStmt) QueryxContext(ctx context.Context, arg interface{}) (*sqlx.Rows, error) {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// do DB call
 	rows, err := n.wns.QueryxContext(ctx, arg)
@@ -1174,9 +1087,7 @@ func (n *NamedStmt) QueryxContext(ctx context.Context, arg interface{}) (*sqlx.R
 func (n *NamedStmt) Select(dest interface{}, arg interface{}) error {
 	var err error
 	ev, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	ev.AddField("db.dest_type", typeof(dest))
 
@@ -1188,9 +1099,7 @@ func (n *NamedStmt) Select(dest interface{}, arg interface{}) error {
 func (n *NamedStmt) SelectContext(ctx context.Context, dest interface{}, arg interface{}) error {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, n.Builder, n.db.Stats(), "", arg)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	if span != nil {
 		span.AddField("db.dest_type", typeof(dest))
@@ -1204,9 +1113,7 @@ func (n *NamedStmt) SelectContext(ctx context.Context, dest interface{}, arg int
 func (n *NamedStmt) Unsafe() *NamedStmt {
 	var err error
 	_, sender := common.BuildDBEvent(n.Builder, n.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	newws := n.wns.Unsafe()
 	n.wns = newws
@@ -1223,9 +1130,7 @@ type Stmt struct {
 func (s *Stmt) Get(dest interface{}, args ...interface{}) error {
 	var err error
 	ev, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1242,9 +1147,7 @@ func (s *Stmt) Get(dest interface{}, args ...interface{}) error {
 func (s *Stmt) GetContext(ctx context.Context, dest interface{}, args ...interface{}) error {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1263,9 +1166,7 @@ func (s *Stmt) GetContext(ctx context.Context, dest interface{}, args ...interfa
 func (s *Stmt) MustExec(args ...interface{}) sql.Result {
 	var err error
 	ev, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1296,9 +1197,7 @@ func (s *Stmt) MustExec(args ...interface{}) sql.Result {
 func (s *Stmt) MustExecContext(ctx context.Context, args ...interface{}) sql.Result {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1335,9 +1234,7 @@ func (s *Stmt) MustExecContext(ctx context.Context, args ...interface{}) sql.Res
 func (s *Stmt) QueryRowx(args ...interface{}) *sqlx.Row {
 	var err error
 	_, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1352,9 +1249,7 @@ func (s *Stmt) QueryRowx(args ...interface{}) *sqlx.Row {
 func (s *Stmt) QueryRowxContext(ctx context.Context, args ...interface{}) *sqlx.Row {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1369,9 +1264,7 @@ func (s *Stmt) QueryRowxContext(ctx context.Context, args ...interface{}) *sqlx.
 func (s *Stmt) Queryx(args ...interface{}) (*sqlx.Rows, error) {
 	var err error
 	_, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1386,9 +1279,7 @@ func (s *Stmt) Queryx(args ...interface{}) (*sqlx.Rows, error) {
 func (s *Stmt) QueryxContext(ctx context.Context, args ...interface{}) (*sqlx.Rows, error) {
 	var err error
 	ctx, _, sender := common.BuildDBSpan(ctx, s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1403,9 +1294,7 @@ func (s *Stmt) QueryxContext(ctx context.Context, args ...interface{}) (*sqlx.Ro
 func (s *Stmt) Select(dest interface{}, args ...interface{}) error {
 	var err error
 	ev, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1422,9 +1311,7 @@ func (s *Stmt) Select(dest interface{}, args ...interface{}) error {
 func (s *Stmt) SelectContext(ctx context.Context, dest interface{}, args ...interface{}) error {
 	var err error
 	ctx, span, sender := common.BuildDBSpan(ctx, s.Builder, s.db.Stats(), "", args...)
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1443,9 +1330,7 @@ func (s *Stmt) SelectContext(ctx context.Context, dest interface{}, args ...inte
 func (s *Stmt) Unsafe() *Stmt {
 	var err error
 	_, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if s.Mapper != nil {
@@ -1460,7 +1345,7 @@ func (s *Stmt) Unsafe() *Stmt {
 func (s *Stmt) Close() error {
 	var err error
 	_, sender := common.BuildDBEvent(s.Builder, s.db.Stats(), "")
-	defer sender(err)
+	defer common.FinishDBCall(sender, &err)
 
 	err = s.wstmt.Close()
 	return err
@@ -1471,6 +1356,65 @@ type Tx struct {
 	wtx     *sqlx.Tx
 	Builder *libhoney.Builder
 	Mapper  *reflectx.Mapper
+
+	// ctx is the context the transaction was begun with, if any. If it had a
+	// span, span times the transaction, and calls made in it are its
+	// children, whatever context they're made with.
+	ctx     context.Context
+	span    *trace.Span
+	endOnce sync.Once
+}
+
+// startTxSpan starts the span for a transaction begun with ctx, if ctx has a
+// span to be its parent, and returns ctx with it in.
+func startTxSpan(ctx context.Context) (context.Context, *trace.Span) {
+	parent := trace.GetSpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	ctx, span := parent.CreateChild(ctx)
+	span.AddField("meta.type", "sqlx")
+	span.AddField("name", "transaction")
+	return ctx, span
+}
+
+// endTxSpan sends span, the span for a transaction, recording how the
+// transaction ended. It's deferred when beginning the transaction too, to
+// send the span if the transaction couldn't be begun.
+func endTxSpan(span *trace.Span, how string, errp *error) {
+	if span == nil {
+		return
+	}
+	if how == "" {
+		// beginning the transaction
+		if *errp == nil {
+			return
+		}
+		how = "begin"
+	}
+	span.AddField("db.tx_end", how)
+	if *errp != nil {
+		span.AddField("db.error", (*errp).Error())
+	}
+	span.Send()
+}
+
+// end sends the transaction's span when it's committed or rolled back. Only
+// the first of those counts, so a deferred Rollback after a Commit doesn't
+// change how the transaction ended.
+func (tx *Tx) end(how string, errp *error) {
+	tx.endOnce.Do(func() {
+		endTxSpan(tx.span, how, errp)
+	})
+}
+
+// context returns ctx with the transaction's span in it, if it has one, so
+// calls made with it are children of the transaction.
+func (tx *Tx) context(ctx context.Context) context.Context {
+	if tx.span == nil {
+		return ctx
+	}
+	return trace.PutSpanInContext(ctx, tx.span)
 }
 
 func (tx *Tx) GetWrappedTx() *sqlx.Tx {
@@ -1479,10 +1423,8 @@ func (tx *Tx) GetWrappedTx() *sqlx.Tx {
 
 func (tx *Tx) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, arg)
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, arg)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1495,10 +1437,9 @@ func (tx *Tx) BindNamed(query string, arg interface{}) (string, []interface{}, e
 
 func (tx *Tx) Commit() error {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer tx.end("commit", &err)
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1514,10 +1455,9 @@ func (tx *Tx) Commit() error {
 // to ensure that commits show up as part of a parent trace
 func (tx *Tx) CommitContext(ctx context.Context) error {
 	var err error
-	_, _, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer tx.end("commit", &err)
+	_, _, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1531,10 +1471,8 @@ func (tx *Tx) CommitContext(ctx context.Context) error {
 
 func (tx *Tx) DriverName() string {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1547,10 +1485,8 @@ func (tx *Tx) DriverName() string {
 
 func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1576,10 +1512,8 @@ func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
 
 func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1609,10 +1543,8 @@ func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}
 
 func (tx *Tx) Get(dest interface{}, query string, args ...interface{}) error {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1627,10 +1559,8 @@ func (tx *Tx) Get(dest interface{}, query string, args ...interface{}) error {
 }
 func (tx *Tx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1648,10 +1578,8 @@ func (tx *Tx) GetContext(ctx context.Context, dest interface{}, query string, ar
 
 func (tx *Tx) MustExec(query string, args ...interface{}) sql.Result {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1681,10 +1609,8 @@ func (tx *Tx) MustExec(query string, args ...interface{}) sql.Result {
 
 func (tx *Tx) MustExecContext(ctx context.Context, query string, args ...interface{}) sql.Result {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1720,10 +1646,8 @@ func (tx *Tx) MustExecContext(ctx context.Context, query string, args ...interfa
 
 func (tx *Tx) NamedExec(query string, arg interface{}) (sql.Result, error) {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, arg)
-	defer func() {
-		sender(err)
-	}()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, arg)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1749,10 +1673,8 @@ func (tx *Tx) NamedExec(query string, arg interface{}) (sql.Result, error) {
 
 func (tx *Tx) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, arg)
-	defer func() {
-		sender(err)
-	}()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, arg)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1782,10 +1704,8 @@ func (tx *Tx) NamedExecContext(ctx context.Context, query string, arg interface{
 
 func (tx *Tx) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, arg)
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, arg)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1799,10 +1719,8 @@ func (tx *Tx) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
 
 func (tx *Tx) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
 	var err error
-	ctx, _, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, arg)
-	defer func() {
-		sender(err)
-	}()
+	ctx, _, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, arg)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1816,10 +1734,8 @@ func (tx *Tx) NamedQueryContext(ctx context.Context, query string, arg interface
 
 func (tx *Tx) NamedStmt(stmt *NamedStmt) *NamedStmt {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	bld := tx.Builder.Clone()
 	wrapStmt := &NamedStmt{
@@ -1839,10 +1755,8 @@ func (tx *Tx) NamedStmt(stmt *NamedStmt) *NamedStmt {
 
 func (tx *Tx) NamedStmtContext(ctx context.Context, stmt *NamedStmt) *NamedStmt {
 	var err error
-	ctx, _, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	ctx, _, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	bld := tx.Builder.Clone()
 	wrapStmt := &NamedStmt{
@@ -1862,10 +1776,8 @@ func (tx *Tx) NamedStmtContext(ctx context.Context, stmt *NamedStmt) *NamedStmt
 
 func (tx *Tx) PrepareNamed(query string) (*NamedStmt, error) {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1877,11 +1789,10 @@ func (tx *Tx) PrepareNamed(query string) (*NamedStmt, error) {
 		db:      tx.db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	ev.AddField("db.stmt_id", stmtid)
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 
 	// do DB call
 	stmt, err := tx.wtx.PrepareNamed(query)
@@ -1891,10 +1802,8 @@ func (tx *Tx) PrepareNamed(query string) (*NamedStmt, error) {
 
 func (tx *Tx) PrepareNamedContext(ctx context.Context, query string) (*NamedStmt, error) {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1906,13 +1815,12 @@ func (tx *Tx) PrepareNamedContext(ctx context.Context, query string) (*NamedStmt
 		db:      tx.db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	if span != nil {
 		span.AddField("db.stmt_id", stmtid)
 	}
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 
 	// do DB call
 	stmt, err := tx.wtx.PrepareNamedContext(ctx, query)
@@ -1922,10 +1830,8 @@ func (tx *Tx) PrepareNamedContext(ctx context.Context, query string) (*NamedStmt
 
 func (tx *Tx) Preparex(query string) (*Stmt, error) {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1937,11 +1843,10 @@ func (tx *Tx) Preparex(query string) (*Stmt, error) {
 		db:      tx.db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	ev.AddField("db.stmt_id", stmtid)
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 
 	// do DB call
 	stmt, err := tx.wtx.Preparex(query)
@@ -1951,10 +1856,8 @@ func (tx *Tx) Preparex(query string) (*Stmt, error) {
 
 func (tx *Tx) PreparexContext(ctx context.Context, query string) (*Stmt, error) {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1966,13 +1869,12 @@ func (tx *Tx) PreparexContext(ctx context.Context, query string) (*Stmt, error)
 		db:      tx.db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	if span != nil {
 		span.AddField("db.stmt_id", stmtid)
 	}
-	bld.AddField("db.query", query)
+	common.AddDBQueryFields(bld, query, nil)
 
 	// do DB call
 	stmt, err := tx.wtx.PreparexContext(ctx, query)
@@ -1982,10 +1884,8 @@ func (tx *Tx) PreparexContext(ctx context.Context, query string) (*Stmt, error)
 
 func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -1999,10 +1899,8 @@ func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
 
 func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	var err error
-	ctx, _, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ctx, _, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2016,10 +1914,8 @@ func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{
 
 func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2033,10 +1929,8 @@ func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
 
 func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
 	var err error
-	ctx, _, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ctx, _, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2050,10 +1944,8 @@ func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interfa
 
 func (tx *Tx) QueryRowx(query string, args ...interface{}) *sqlx.Row {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2067,10 +1959,8 @@ func (tx *Tx) QueryRowx(query string, args ...interface{}) *sqlx.Row {
 
 func (tx *Tx) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2084,10 +1974,8 @@ func (tx *Tx) QueryRowxContext(ctx context.Context, query string, args ...interf
 
 func (tx *Tx) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2101,10 +1989,8 @@ func (tx *Tx) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
 
 func (tx *Tx) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
 	var err error
-	ctx, _, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ctx, _, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2118,10 +2004,8 @@ func (tx *Tx) QueryxContext(ctx context.Context, query string, args ...interface
 
 func (tx *Tx) Rebind(query string) string {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query)
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2134,10 +2018,9 @@ func (tx *Tx) Rebind(query string) string {
 
 func (tx *Tx) Rollback() error {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer tx.end("rollback", &err)
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2151,10 +2034,9 @@ func (tx *Tx) Rollback() error {
 
 func (tx *Tx) RollbackContext(ctx context.Context) error {
 	var err error
-	_, _, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	defer tx.end("rollback", &err)
+	_, _, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2168,10 +2050,8 @@ func (tx *Tx) RollbackContext(ctx context.Context) error {
 
 func (tx *Tx) Select(dest interface{}, query string, args ...interface{}) error {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2186,10 +2066,8 @@ func (tx *Tx) Select(dest interface{}, query string, args ...interface{}) error
 
 func (tx *Tx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), query, args...)
-	defer func() {
-		sender(err)
-	}()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), query, args...)
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2206,10 +2084,8 @@ func (tx *Tx) SelectContext(ctx context.Context, dest interface{}, query string,
 
 func (tx *Tx) Stmtx(stmt *Stmt) *Stmt {
 	var err error
-	ev, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	ev, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2221,8 +2097,7 @@ func (tx *Tx) Stmtx(stmt *Stmt) *Stmt {
 		db:      tx.db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	ev.AddField("db.stmt_id", stmtid)
 
@@ -2236,10 +2111,8 @@ func (tx *Tx) Stmtx(stmt *Stmt) *Stmt {
 
 func (tx *Tx) StmtxContext(ctx context.Context, stmt *Stmt) *Stmt {
 	var err error
-	ctx, span, sender := common.BuildDBSpan(ctx, tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	ctx, span, sender := common.BuildDBSpan(tx.context(ctx), tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -2251,8 +2124,7 @@ func (tx *Tx) StmtxContext(ctx context.Context, stmt *Stmt) *Stmt {
 		db:      tx.db,
 		Builder: bld,
 	}
-	newid, _ := uuid.NewRandom()
-	stmtid := newid.String()
+	stmtid := trace.NewSpanID()
 	bld.AddField("db.stmt_id", stmtid)
 	if span != nil {
 		span.AddField("db.stmt_id", stmtid)
@@ -2268,10 +2140,8 @@ func (tx *Tx) StmtxContext(ctx context.Context, stmt *Stmt) *Stmt {
 
 func (tx *Tx) Unsafe() *Tx {
 	var err error
-	_, sender := common.BuildDBEvent(tx.Builder, tx.db.Stats(), "")
-	defer func() {
-		sender(err)
-	}()
+	_, sender := common.BuildDBCall(tx.ctx, tx.Builder, tx.db.Stats(), "")
+	defer common.FinishDBCall(sender, &err)
 
 	// ensure any changes to the Mapper get passed along
 	if tx.Mapper != nil {
@@ -8,6 +8,8 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/go-sql-driver/mysql"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 
@@ -111,3 +113,61 @@ func TestSQLXMiddleware(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestTransactionSpans(t *testing.T) {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo,
+	})
+	assert.Nil(t, err)
+	beeline.Init(beeline.Config{Client: client})
+
+	odb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer odb.Close()
+	db := hnysqlx.WrapDB(sqlx.NewDb(odb, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("update flavors.+").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("insert into flavors.+").WithArgs("rose").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	tx, err := db.BeginTxx(ctx, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// neither call's context has the transaction in it
+	_, err = tx.Exec("update flavors set stocked=1")
+	assert.NoError(t, err)
+	_, err = tx.NamedExecContext(context.Background(), "insert into flavors (flavor) values (:flavor)",
+		map[string]interface{}{"flavor": "rose"})
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+	// a deferred rollback after committing doesn't change the transaction
+	assert.Error(t, tx.Rollback())
+	span.Send()
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	evs := mo.Events()
+	if !assert.Equal(t, 7, len(evs)) {
+		return
+	}
+	txSpan, root := evs[4].Data, evs[6].Data
+	assert.Equal(t, "transaction", txSpan["name"])
+	assert.Equal(t, "commit", txSpan["db.tx_end"])
+	assert.Equal(t, root["trace.span_id"], txSpan["trace.parent_id"])
+	for i, call := range []string{"BeginTxx", "Exec", "NamedExecContext", "Commit"} {
+		fields := evs[i].Data
+		assert.Equal(t, call, fields["db.call"])
+		assert.Equal(t, txSpan["trace.span_id"], fields["trace.parent_id"], "%s should be a child of the transaction", call)
+		assert.Equal(t, txSpan["db.tx_id"], fields["db.tx_id"])
+	}
+	// the rollback after the commit is still recorded, on its own
+	assert.Equal(t, "Rollback", evs[5].Data["db.call"])
+}
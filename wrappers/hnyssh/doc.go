@@ -0,0 +1,19 @@
+// Package hnyssh wraps `golang.org/x/crypto/ssh` sessions to emit a span for
+// each remote command.
+//
+// Open sessions with hnyssh.NewSession instead of client.NewSession. The
+// returned *hnyssh.Session embeds the *ssh.Session, so it can be configured in
+// the same way, and its Run, Start, Wait, Output and CombinedOutput methods
+// time the command in a span that is a child of the span in the context:
+//
+//	session, err := hnyssh.NewSession(ctx, client)
+//	if err != nil {
+//		return err
+//	}
+//	defer session.Close()
+//	out, err := session.Output("uptime")
+//
+// Each span records the remote host, the program run (the first word
+// of the command, since the rest often contains secrets), the exit status and
+// the number of bytes sent to stdin and read from stdout and stderr.
+package hnyssh
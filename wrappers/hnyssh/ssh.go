@@ -0,0 +1,182 @@
+package hnyssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+	"golang.org/x/crypto/ssh"
+)
+
+// Session is an *ssh.Session whose methods emit a span.
+type Session struct {
+	*ssh.Session
+
+	ctx    context.Context
+	host   string
+	span   *trace.Span
+	stdin  *countingReader
+	stdout *countingWriter
+	stderr *countingWriter
+}
+
+// NewSession opens a new session on client. Commands run with it are timed
+// in spans that are children of the span in ctx.
+func NewSession(ctx context.Context, client *ssh.Client) (*Session, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	s := WrapSession(ctx, session)
+	s.host = client.RemoteAddr().String()
+	return s, nil
+}
+
+// WrapSession wraps a session that has already been opened. Spans for
+// sessions wrapped this way don't record the remote host.
+func WrapSession(ctx context.Context, session *ssh.Session) *Session {
+	return &Session{
+		Session: session,
+		ctx:     ctx,
+	}
+}
+
+// Run runs cmd on the remote host and waits for it to complete.
+func (s *Session) Run(cmd string) error {
+	if err := s.Start(cmd); err != nil {
+		return err
+	}
+	return s.Wait()
+}
+
+// Start runs cmd on the remote host and starts the span timing it. Wait must
+// be called to send the span.
+func (s *Session) Start(cmd string) error {
+	_, s.span = common.StartChildSpan(s.ctx)
+	s.span.AddField("meta.type", "ssh")
+	s.span.AddField("name", "ssh")
+	if s.host != "" {
+		s.span.AddField("ssh.host", s.host)
+	}
+	if fields := strings.Fields(cmd); len(fields) > 0 {
+		s.span.AddField("ssh.command", fields[0])
+	}
+
+	if s.Stdin != nil {
+		s.stdin = &countingReader{r: s.Stdin}
+		s.Stdin = s.stdin
+	}
+	if s.Stdout != nil {
+		s.stdout = &countingWriter{w: s.Stdout}
+		s.Stdout = s.stdout
+	}
+	if s.Stderr != nil {
+		if s.stdout != nil && s.Stderr == s.stdout.w {
+			s.stderr = s.stdout
+		} else {
+			s.stderr = &countingWriter{w: s.Stderr}
+		}
+		s.Stderr = s.stderr
+	}
+
+	err := s.Session.Start(cmd)
+	if err != nil {
+		s.finish(err)
+	}
+	return err
+}
+
+// Wait waits for the remote command to exit and sends its span.
+func (s *Session) Wait() error {
+	err := s.Session.Wait()
+	s.finish(err)
+	return err
+}
+
+// Output runs cmd on the remote host and returns its standard output.
+func (s *Session) Output(cmd string) ([]byte, error) {
+	if s.Stdout != nil {
+		return nil, errors.New("ssh: Stdout already set")
+	}
+	var b bytes.Buffer
+	s.Stdout = &b
+	err := s.Run(cmd)
+	return b.Bytes(), err
+}
+
+// CombinedOutput runs cmd on the remote host and returns its combined
+// standard output and standard error.
+func (s *Session) CombinedOutput(cmd string) ([]byte, error) {
+	if s.Stdout != nil {
+		return nil, errors.New("ssh: Stdout already set")
+	}
+	if s.Stderr != nil {
+		return nil, errors.New("ssh: Stderr already set")
+	}
+	var b bytes.Buffer
+	s.Stdout = &b
+	s.Stderr = &b
+	err := s.Run(cmd)
+	return b.Bytes(), err
+}
+
+func (s *Session) finish(err error) {
+	if s.span == nil {
+		return
+	}
+	if s.stdin != nil {
+		s.span.AddField("ssh.stdin_bytes", s.stdin.n)
+	}
+	if s.stdout != nil && s.stdout == s.stderr {
+		s.span.AddField("ssh.output_bytes", s.stdout.n)
+	} else {
+		if s.stdout != nil {
+			s.span.AddField("ssh.stdout_bytes", s.stdout.n)
+		}
+		if s.stderr != nil {
+			s.span.AddField("ssh.stderr_bytes", s.stderr.n)
+		}
+	}
+	switch e := err.(type) {
+	case nil:
+		s.span.AddField("ssh.exit_status", 0)
+	case *ssh.ExitError:
+		s.span.AddField("ssh.exit_status", e.ExitStatus())
+		if e.Signal() != "" {
+			s.span.AddField("ssh.signal", e.Signal())
+		}
+	}
+	if err != nil {
+		s.span.AddField("ssh.error", err.Error())
+	}
+	s.span.Send()
+	s.span = nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,180 @@
+package hnyssh
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+// startServer runs an SSH server that understands three commands: "echo"
+// writes its arguments to stdout, "cat" copies stdin to stdout and "fail"
+// writes to stderr and exits with status 2.
+func startServer(t *testing.T) (*ssh.Client, func()) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_, chans, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		for nc := range chans {
+			ch, reqs, err := nc.Accept()
+			if err != nil {
+				return
+			}
+			go serveSession(ch, reqs)
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "deploy",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, func() {
+		client.Close()
+		l.Close()
+	}
+}
+
+func serveSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		if req.Type != "exec" || len(req.Payload) < 4 {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		args := strings.Fields(string(req.Payload[4:]))
+		status := uint32(0)
+		switch args[0] {
+		case "echo":
+			io.WriteString(ch, strings.Join(args[1:], " "))
+		case "cat":
+			io.Copy(ch, ch)
+		case "fail":
+			io.WriteString(ch.Stderr(), "failed")
+			status = 2
+		}
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, status)
+		ch.SendRequest("exit-status", false, payload)
+		ch.Close()
+		return
+	}
+}
+
+func TestOutput(t *testing.T) {
+	mo := setupLibhoney(t)
+	client, stop := startServer(t)
+	defer stop()
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+
+	session, err := NewSession(ctx, client)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer session.Close()
+	out, err := session.Output("echo secret-token")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-token", string(out))
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "ssh", fields["meta.type"])
+		assert.Equal(t, client.RemoteAddr().String(), fields["ssh.host"])
+		assert.Equal(t, "echo", fields["ssh.command"], "arguments should not be recorded")
+		assert.Equal(t, 0, fields["ssh.exit_status"])
+		assert.Equal(t, int64(12), fields["ssh.stdout_bytes"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], fields["trace.parent_id"])
+	}
+}
+
+func TestStdin(t *testing.T) {
+	mo := setupLibhoney(t)
+	client, stop := startServer(t)
+	defer stop()
+
+	session, err := NewSession(context.Background(), client)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer session.Close()
+	session.Stdin = strings.NewReader("hello")
+	session.Stdout = ioutil.Discard
+	assert.NoError(t, session.Run("cat"))
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, int64(5), evs[0].Data["ssh.stdin_bytes"])
+		assert.Equal(t, int64(5), evs[0].Data["ssh.stdout_bytes"])
+	}
+}
+
+func TestFailingCommand(t *testing.T) {
+	mo := setupLibhoney(t)
+	client, stop := startServer(t)
+	defer stop()
+
+	session, err := NewSession(context.Background(), client)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer session.Close()
+	out, err := session.CombinedOutput("fail")
+	assert.Equal(t, "failed", string(out))
+	_, ok := err.(*ssh.ExitError)
+	assert.True(t, ok, "exit errors should be returned")
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, 2, evs[0].Data["ssh.exit_status"])
+		assert.Equal(t, int64(6), evs[0].Data["ssh.output_bytes"])
+		assert.Equal(t, err.Error(), evs[0].Data["ssh.error"])
+	}
+}
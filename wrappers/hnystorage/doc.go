@@ -0,0 +1,16 @@
+// Package hnystorage emits spans for operations on blob storage services
+// such as S3 and GCS.
+//
+// Each service is adapted to the Store interface by a subpackage, which
+// returns a Store that is already wrapped:
+//
+//	store := s3store.New(s3.New(sess))
+//	body, size, err := store.Get(r.Context(), "uploads", key, nil)
+//
+// Other services can be instrumented by implementing Store and passing it to
+// hnystorage.Wrap.
+//
+// Each span records the bucket, the storage operation, the object size and any
+// range requested. Object keys often contain user IDs, so only a scrubbed
+// prefix of the key is recorded; see ScrubKey.
+package hnystorage
@@ -0,0 +1,8 @@
+// Package gcsstore adapts the Google Cloud Storage client to hnystorage, so
+// that GCS operations emit spans.
+//
+//	client, err := storage.NewClient(ctx)
+//	...
+//	store := gcsstore.New(client)
+//	err := store.Put(ctx, "uploads", key, file)
+package gcsstore
@@ -0,0 +1,59 @@
+package gcsstore
+
+import (
+	"context"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"github.com/honeycombio/beeline-go/wrappers/hnystorage"
+	"google.golang.org/api/iterator"
+)
+
+// New returns a Store for the buckets available to client that emits spans.
+func New(client *storage.Client) hnystorage.Store {
+	return hnystorage.Wrap("gcs", &store{client: client})
+}
+
+type store struct {
+	client *storage.Client
+}
+
+func (s *store) Get(ctx context.Context, bucket, key string, r *hnystorage.Range) (io.ReadCloser, int64, error) {
+	offset, length := int64(0), int64(-1)
+	if r != nil {
+		offset, length = r.Offset, r.Length
+	}
+	reader, err := s.client.Bucket(bucket).Object(key).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, -1, err
+	}
+	return reader, reader.Remain(), nil
+}
+
+func (s *store) Put(ctx context.Context, bucket, key string, body io.Reader) error {
+	w := s.client.Bucket(bucket).Object(key).NewWriter(ctx)
+	if _, err := io.Copy(w, body); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *store) List(ctx context.Context, bucket, prefix string) ([]hnystorage.Object, error) {
+	var objects []hnystorage.Object
+	it := s.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return objects, nil
+		}
+		if err != nil {
+			return objects, err
+		}
+		objects = append(objects, hnystorage.Object{Key: attrs.Name, Size: attrs.Size})
+	}
+}
+
+func (s *store) Delete(ctx context.Context, bucket, key string) error {
+	return s.client.Bucket(bucket).Object(key).Delete(ctx)
+}
@@ -0,0 +1,93 @@
+package gcsstore
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/wrappers/hnystorage"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+// newClient returns a storage client talking to a fake GCS that serves one
+// object, "docs/1", and lists it.
+func newClient(t *testing.T) (*storage.Client, func()) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bucket/docs/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		fmt.Fprint(w, "hello")
+	})
+	mux.HandleFunc("/storage/v1/b/bucket/o", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"items": [{"name": "docs/1", "size": "5"}]}`)
+	})
+	mux.HandleFunc("/storage/v1/b/bucket/o/docs/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewTLSServer(mux)
+	client, err := storage.NewClient(context.Background(),
+		option.WithEndpoint(srv.URL+"/storage/v1/"),
+		option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, srv.Close
+}
+
+func TestStore(t *testing.T) {
+	mo := setupLibhoney(t)
+	client, stop := newClient(t)
+	defer stop()
+	store := New(client)
+	ctx := context.Background()
+
+	body, size, err := store.Get(ctx, "bucket", "docs/1", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(5), size)
+		data, _ := ioutil.ReadAll(body)
+		assert.Equal(t, "hello", string(data))
+		body.Close()
+	}
+
+	objects, err := store.List(ctx, "bucket", "docs/")
+	assert.NoError(t, err)
+	assert.Equal(t, []hnystorage.Object{{Key: "docs/1", Size: 5}}, objects)
+
+	assert.NoError(t, store.Delete(ctx, "bucket", "docs/1"))
+
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		assert.Equal(t, "gcs", evs[0].Data["storage.provider"])
+		assert.Equal(t, int64(5), evs[0].Data["storage.object_bytes"])
+		assert.Equal(t, float64(5), evs[0].Data["storage.read_bytes"])
+		assert.Equal(t, 1, evs[1].Data["storage.result_count"])
+		assert.Equal(t, "storage.delete", evs[2].Data["name"])
+		for _, ev := range evs {
+			assert.NotContains(t, ev.Data, "storage.error")
+		}
+	}
+}
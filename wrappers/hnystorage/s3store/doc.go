@@ -0,0 +1,6 @@
+// Package s3store adapts the AWS S3 SDK to hnystorage, so that S3 operations
+// emit spans.
+//
+//	store := s3store.New(s3.New(sess))
+//	err := store.Put(ctx, "uploads", key, file)
+package s3store
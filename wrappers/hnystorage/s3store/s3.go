@@ -0,0 +1,101 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/honeycombio/beeline-go/wrappers/hnystorage"
+)
+
+// API is the subset of the S3 client used by the store. *s3.S3 implements it.
+type API interface {
+	GetObjectWithContext(aws.Context, *s3.GetObjectInput, ...request.Option) (*s3.GetObjectOutput, error)
+	PutObjectWithContext(aws.Context, *s3.PutObjectInput, ...request.Option) (*s3.PutObjectOutput, error)
+	ListObjectsV2PagesWithContext(aws.Context, *s3.ListObjectsV2Input, func(*s3.ListObjectsV2Output, bool) bool, ...request.Option) error
+	DeleteObjectWithContext(aws.Context, *s3.DeleteObjectInput, ...request.Option) (*s3.DeleteObjectOutput, error)
+}
+
+// New returns a Store for the buckets in api that emits spans.
+func New(api API) hnystorage.Store {
+	return hnystorage.Wrap("s3", &store{api: api})
+}
+
+type store struct {
+	api API
+}
+
+func (s *store) Get(ctx context.Context, bucket, key string, r *hnystorage.Range) (io.ReadCloser, int64, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if r != nil {
+		input.Range = aws.String(httpRange(r))
+	}
+	out, err := s.api.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, -1, err
+	}
+	size := int64(-1)
+	if out.ContentLength != nil {
+		size = *out.ContentLength
+	}
+	return out.Body, size, nil
+}
+
+// httpRange formats r as an HTTP Range header.
+func httpRange(r *hnystorage.Range) string {
+	if r.Length < 0 {
+		return fmt.Sprintf("bytes=%d-", r.Offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1)
+}
+
+func (s *store) Put(ctx context.Context, bucket, key string, body io.Reader) error {
+	rs, ok := body.(io.ReadSeeker)
+	if !ok {
+		// the SDK needs to seek over the body to sign it
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		rs = bytes.NewReader(data)
+	}
+	_, err := s.api.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   rs,
+	})
+	return err
+}
+
+func (s *store) List(ctx context.Context, bucket, prefix string) ([]hnystorage.Object, error) {
+	var objects []hnystorage.Object
+	err := s.api.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			objects = append(objects, hnystorage.Object{
+				Key:  aws.StringValue(obj.Key),
+				Size: aws.Int64Value(obj.Size),
+			})
+		}
+		return true
+	})
+	return objects, err
+}
+
+func (s *store) Delete(ctx context.Context, bucket, key string) error {
+	_, err := s.api.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
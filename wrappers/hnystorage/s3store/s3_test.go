@@ -0,0 +1,100 @@
+package s3store
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/wrappers/hnystorage"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+// fakeS3 records the requests made to it.
+type fakeS3 struct {
+	get    *s3.GetObjectInput
+	put    []byte
+	list   *s3.ListObjectsV2Input
+	delete *s3.DeleteObjectInput
+}
+
+func (f *fakeS3) GetObjectWithContext(ctx aws.Context, in *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	f.get = in
+	return &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(strings.NewReader("hello")),
+		ContentLength: aws.Int64(5),
+	}, nil
+}
+
+func (f *fakeS3) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	f.put, _ = ioutil.ReadAll(in.Body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectsV2PagesWithContext(ctx aws.Context, in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	f.list = in
+	fn(&s3.ListObjectsV2Output{Contents: []*s3.Object{{Key: aws.String("a/1"), Size: aws.Int64(3)}}}, false)
+	fn(&s3.ListObjectsV2Output{Contents: []*s3.Object{{Key: aws.String("a/2"), Size: aws.Int64(4)}}}, true)
+	return nil
+}
+
+func (f *fakeS3) DeleteObjectWithContext(ctx aws.Context, in *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	f.delete = in
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+var _ API = (*s3.S3)(nil)
+
+func TestStore(t *testing.T) {
+	mo := setupLibhoney(t)
+	fake := &fakeS3{}
+	store := New(fake)
+	ctx := context.Background()
+
+	body, size, err := store.Get(ctx, "bucket", "a/1", &hnystorage.Range{Offset: 10, Length: 5})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(5), size)
+		body.Close()
+	}
+	assert.Equal(t, "bytes=10-14", aws.StringValue(fake.get.Range))
+
+	assert.NoError(t, store.Put(ctx, "bucket", "a/3", ioutil.NopCloser(strings.NewReader("data"))))
+	assert.Equal(t, "data", string(fake.put))
+
+	objects, err := store.List(ctx, "bucket", "a/")
+	assert.NoError(t, err)
+	assert.Equal(t, []hnystorage.Object{{Key: "a/1", Size: 3}, {Key: "a/2", Size: 4}}, objects)
+
+	assert.NoError(t, store.Delete(ctx, "bucket", "a/1"))
+	assert.Equal(t, "a/1", aws.StringValue(fake.delete.Key))
+
+	evs := mo.Events()
+	if assert.Equal(t, 4, len(evs)) {
+		assert.Equal(t, "s3", evs[0].Data["storage.provider"])
+		assert.Equal(t, "bucket", evs[0].Data["storage.bucket"])
+		assert.Equal(t, int64(4), evs[1].Data["storage.object_bytes"])
+	}
+}
+
+func TestHTTPRange(t *testing.T) {
+	assert.Equal(t, "bytes=5-", httpRange(&hnystorage.Range{Offset: 5, Length: -1}))
+	assert.Equal(t, "bytes=0-0", httpRange(&hnystorage.Range{Offset: 0, Length: 1}))
+}
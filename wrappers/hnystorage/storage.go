@@ -0,0 +1,203 @@
+package hnystorage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Store is the set of operations instrumented on a blob storage service.
+type Store interface {
+	// Get opens the object for reading. If r is not nil, only that range of
+	// the object is read. The size returned is the number of bytes that will
+	// be read, or -1 if it isn't known.
+	Get(ctx context.Context, bucket, key string, r *Range) (io.ReadCloser, int64, error)
+	// Put writes body to the object, replacing it if it exists.
+	Put(ctx context.Context, bucket, key string, body io.Reader) error
+	// List returns the objects whose keys start with prefix.
+	List(ctx context.Context, bucket, prefix string) ([]Object, error)
+	// Delete removes the object.
+	Delete(ctx context.Context, bucket, key string) error
+}
+
+// Range is a range of bytes in an object.
+type Range struct {
+	Offset int64
+	// Length is the number of bytes to read. If it is negative, the object
+	// is read to its end.
+	Length int64
+}
+
+// Object describes an object returned by List.
+type Object struct {
+	Key  string
+	Size int64
+}
+
+// ScrubKey turns an object key into the value recorded in storage.key_prefix.
+// By default it is KeyPrefix; replace it to record more or less of each key.
+var ScrubKey = KeyPrefix
+
+// KeyPrefix returns the "directory" part of key, up to and including its last
+// "/", with any segment containing a digit replaced by "*", so that
+// "users/1234/avatar.png" becomes "users/*/".
+func KeyPrefix(key string) string {
+	i := strings.LastIndex(key, "/")
+	if i < 0 {
+		return ""
+	}
+	segments := strings.Split(key[:i], "/")
+	for n, segment := range segments {
+		if strings.ContainsAny(segment, "0123456789") {
+			segments[n] = "*"
+		}
+	}
+	return strings.Join(segments, "/") + "/"
+}
+
+// Wrap returns a Store that emits a span for each operation on s. provider
+// names the service, eg "s3", and is recorded in storage.provider.
+func Wrap(provider string, s Store) Store {
+	return &wrappedStore{provider: provider, store: s}
+}
+
+type wrappedStore struct {
+	provider string
+	store    Store
+}
+
+// Get starts a span that is sent when the object's body is closed, so that
+// it includes the time taken to read it.
+func (w *wrappedStore) Get(ctx context.Context, bucket, key string, r *Range) (io.ReadCloser, int64, error) {
+	op := w.start(ctx, "get", bucket, key)
+	if r != nil {
+		op.span.AddField("storage.range_offset", r.Offset)
+		if r.Length >= 0 {
+			op.span.AddField("storage.range_length", r.Length)
+		}
+	}
+	rc, size, err := w.store.Get(ctx, bucket, key, r)
+	if err != nil {
+		op.finish(err)
+		return nil, size, err
+	}
+	if size >= 0 {
+		op.span.AddField("storage.object_bytes", size)
+	}
+	return &body{ReadCloser: rc, op: op}, size, nil
+}
+
+func (w *wrappedStore) Put(ctx context.Context, bucket, key string, body io.Reader) error {
+	op := w.start(ctx, "put", bucket, key)
+	counter := &countingReader{r: body}
+	var wrapped io.Reader = counter
+	if _, ok := body.(io.ReadSeeker); ok {
+		// SDKs like the AWS one seek over the body to size and sign it, so
+		// keep it seekable
+		wrapped = &countingReadSeeker{counter}
+	}
+	err := w.store.Put(ctx, bucket, key, wrapped)
+	op.span.AddField("storage.object_bytes", counter.max)
+	op.span.AddRollupField("storage.write_bytes", float64(counter.max))
+	op.finish(err)
+	return err
+}
+
+func (w *wrappedStore) List(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	op := w.start(ctx, "list", bucket, prefix)
+	objects, err := w.store.List(ctx, bucket, prefix)
+	op.span.AddField("storage.result_count", len(objects))
+	op.finish(err)
+	return objects, err
+}
+
+func (w *wrappedStore) Delete(ctx context.Context, bucket, key string) error {
+	op := w.start(ctx, "delete", bucket, key)
+	err := w.store.Delete(ctx, bucket, key)
+	op.finish(err)
+	return err
+}
+
+type op struct {
+	span  *trace.Span
+	start time.Time
+}
+
+func (w *wrappedStore) start(ctx context.Context, operation, bucket, key string) *op {
+	_, span := common.StartChildSpan(ctx)
+	span.AddField("meta.type", "storage")
+	span.AddField("name", "storage."+operation)
+	span.AddField("storage.operation", operation)
+	span.AddField("storage.provider", w.provider)
+	span.AddField("storage.bucket", bucket)
+	span.AddField("storage.key_prefix", ScrubKey(key))
+	return &op{span: span, start: time.Now()}
+}
+
+func (o *op) finish(err error) {
+	if err != nil {
+		o.span.AddField("storage.error", err.Error())
+	}
+	o.span.AddRollupField("storage.duration_ms", float64(time.Since(o.start))/float64(time.Millisecond))
+	o.span.AddRollupField("storage.call_count", 1)
+	o.span.Send()
+}
+
+// body counts the bytes read from an object and finishes the Get span when it
+// is closed.
+type body struct {
+	io.ReadCloser
+	op   *op
+	n    int64
+	done bool
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *body) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.done {
+		b.done = true
+		b.op.span.AddRollupField("storage.read_bytes", float64(b.n))
+		b.op.finish(err)
+	}
+	return err
+}
+
+// countingReader records the furthest position read to, which is the size of
+// the body even if it is read more than once.
+type countingReader struct {
+	r   io.Reader
+	pos int64
+	max int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.pos += int64(n)
+	if c.pos > c.max {
+		c.max = c.pos
+	}
+	return n, err
+}
+
+// countingReadSeeker is a countingReader for a body that can seek.
+type countingReadSeeker struct {
+	*countingReader
+}
+
+func (c *countingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := c.r.(io.Seeker).Seek(offset, whence)
+	if err == nil {
+		c.pos = pos
+	}
+	return pos, err
+}
@@ -0,0 +1,132 @@
+package hnystorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+// memStore is a Store for a single bucket held in memory.
+type memStore map[string][]byte
+
+var errNotFound = errors.New("not found")
+
+func (m memStore) Get(ctx context.Context, bucket, key string, r *Range) (io.ReadCloser, int64, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, -1, errNotFound
+	}
+	if r != nil {
+		data = data[r.Offset:]
+		if r.Length >= 0 {
+			data = data[:r.Length]
+		}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+func (m memStore) Put(ctx context.Context, bucket, key string, body io.Reader) error {
+	if s, ok := body.(io.Seeker); ok {
+		// read it twice like an SDK signing the request would
+		ioutil.ReadAll(body)
+		s.Seek(0, io.SeekStart)
+	}
+	data, err := ioutil.ReadAll(body)
+	m[key] = data
+	return err
+}
+
+func (m memStore) List(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	var objects []Object
+	for key, data := range m {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, Object{Key: key, Size: int64(len(data))})
+		}
+	}
+	return objects, nil
+}
+
+func (m memStore) Delete(ctx context.Context, bucket, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestKeyPrefix(t *testing.T) {
+	assert.Equal(t, "users/*/avatars/", KeyPrefix("users/1234/avatars/a1.png"))
+	assert.Equal(t, "", KeyPrefix("a.png"))
+	assert.Equal(t, "reports/", KeyPrefix("reports/"))
+}
+
+func TestOperations(t *testing.T) {
+	mo := setupLibhoney(t)
+	store := Wrap("memory", memStore{})
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+
+	assert.NoError(t, store.Put(ctx, "b", "users/42/doc.txt", strings.NewReader("hello world")))
+	// a body that can't seek
+	assert.NoError(t, store.Put(ctx, "b", "users/43/doc.txt", ioutil.NopCloser(strings.NewReader("hi"))))
+
+	body, size, err := store.Get(ctx, "b", "users/42/doc.txt", &Range{Offset: 6, Length: -1})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(5), size)
+		data, _ := ioutil.ReadAll(body)
+		assert.Equal(t, "world", string(data))
+		body.Close()
+		body.Close()
+	}
+	objects, err := store.List(ctx, "b", "users/")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(objects))
+	assert.NoError(t, store.Delete(ctx, "b", "users/42/doc.txt"))
+	_, _, err = store.Get(ctx, "b", "users/42/doc.txt", nil)
+	assert.Equal(t, errNotFound, err)
+	span.Send()
+
+	evs := mo.Events()
+	if !assert.Equal(t, 7, len(evs)) {
+		return
+	}
+	put := evs[0].Data
+	assert.Equal(t, "storage", put["meta.type"])
+	assert.Equal(t, "storage.put", put["name"])
+	assert.Equal(t, "memory", put["storage.provider"])
+	assert.Equal(t, "b", put["storage.bucket"])
+	assert.Equal(t, "users/*/", put["storage.key_prefix"])
+	assert.Equal(t, int64(11), put["storage.object_bytes"], "rereading a body should not count twice")
+	assert.Equal(t, int64(2), evs[1].Data["storage.object_bytes"])
+
+	get := evs[2].Data
+	assert.Equal(t, int64(6), get["storage.range_offset"])
+	assert.NotContains(t, get, "storage.range_length")
+	assert.Equal(t, int64(5), get["storage.object_bytes"])
+	assert.Equal(t, float64(5), get["storage.read_bytes"])
+
+	assert.Equal(t, 2, evs[3].Data["storage.result_count"])
+	assert.Equal(t, "storage.delete", evs[4].Data["name"])
+	assert.Equal(t, "not found", evs[5].Data["storage.error"])
+
+	root := evs[6].Data
+	assert.Equal(t, float64(6), root["rollup.storage.call_count"])
+	assert.Equal(t, float64(13), root["rollup.storage.write_bytes"])
+}
@@ -0,0 +1,13 @@
+// Package hnytemplate times the rendering of html/template and text/template
+// templates.
+//
+// Replace calls to Execute and ExecuteTemplate with the functions in this
+// package, passing the request's context:
+//
+//	err := hnytemplate.ExecuteTemplate(r.Context(), tmpl, w, "page.html", data)
+//
+// Each render becomes a span with the template's name, the number of bytes
+// written and any error. The time spent rendering is also summed into the
+// `rollup.template.duration_ms` and `rollup.template.call_count` fields of the
+// root span, so you can see how much of a request went to rendering.
+package hnytemplate
@@ -0,0 +1,66 @@
+package hnytemplate
+
+import (
+	"context"
+	"io"
+
+	"github.com/honeycombio/beeline-go/timer"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Template is implemented by both *html/template.Template and
+// *text/template.Template.
+type Template interface {
+	Name() string
+	Execute(w io.Writer, data interface{}) error
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// Execute applies t to data, writing the output to w, in a span that is a
+// child of the span in ctx.
+func Execute(ctx context.Context, t Template, w io.Writer, data interface{}) error {
+	return render(ctx, t.Name(), w, func(w io.Writer) error {
+		return t.Execute(w, data)
+	})
+}
+
+// ExecuteTemplate applies the template associated with t that has the given
+// name to data, writing the output to w, in a span that is a child of the span
+// in ctx.
+func ExecuteTemplate(ctx context.Context, t Template, w io.Writer, name string, data interface{}) error {
+	return render(ctx, name, w, func(w io.Writer) error {
+		return t.ExecuteTemplate(w, name, data)
+	})
+}
+
+func render(ctx context.Context, name string, w io.Writer, execute func(io.Writer) error) error {
+	tm := timer.Start()
+	_, span := common.StartChildSpan(ctx)
+	defer span.Send()
+	span.AddField("meta.type", "template")
+	span.AddField("name", "template")
+	span.AddField("template.name", name)
+
+	cw := &countingWriter{w: w}
+	err := execute(cw)
+
+	span.AddField("template.output_bytes", cw.n)
+	if err != nil {
+		span.AddField("template.error", err.Error())
+	}
+	span.AddRollupField("template.duration_ms", tm.Finish())
+	span.AddRollupField("template.call_count", 1)
+	return err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
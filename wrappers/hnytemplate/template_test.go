@@ -0,0 +1,65 @@
+package hnytemplate
+
+import (
+	"bytes"
+	"context"
+	htmltemplate "html/template"
+	"testing"
+	texttemplate "text/template"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func TestExecuteTemplate(t *testing.T) {
+	mo := setupLibhoney(t)
+	tmpl := htmltemplate.Must(htmltemplate.New("page").Parse(`{{define "greeting"}}hello {{.}}{{end}}`))
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	var buf bytes.Buffer
+	err := ExecuteTemplate(ctx, tmpl, &buf, "greeting", "world")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", buf.String())
+	span.Send()
+
+	evs := mo.Events()
+	if assert.Equal(t, 2, len(evs)) {
+		fields := evs[0].Data
+		assert.Equal(t, "template", fields["meta.type"])
+		assert.Equal(t, "greeting", fields["template.name"])
+		assert.Equal(t, int64(len("hello world")), fields["template.output_bytes"])
+		assert.Equal(t, evs[1].Data["trace.span_id"], fields["trace.parent_id"], "render should be a child of the request")
+		assert.Equal(t, float64(1), evs[1].Data["rollup.template.call_count"])
+		assert.Contains(t, evs[1].Data, "rollup.template.duration_ms")
+	}
+}
+
+func TestExecuteError(t *testing.T) {
+	mo := setupLibhoney(t)
+	tmpl := texttemplate.Must(texttemplate.New("broken").Parse(`{{.Missing.Field}}`))
+
+	var buf bytes.Buffer
+	err := Execute(context.Background(), tmpl, &buf, struct{}{})
+	assert.Error(t, err)
+
+	evs := mo.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Equal(t, "broken", evs[0].Data["template.name"])
+		assert.Equal(t, err.Error(), evs[0].Data["template.error"])
+		assert.Equal(t, true, evs[0].Data["meta.orphaned"])
+	}
+}
@@ -0,0 +1,41 @@
+// Package hnytraefik is a Traefik middleware plugin that starts a trace for
+// every request the proxy handles and passes it on to the backend.
+//
+// Traefik runs plugins from source in its Yaegi interpreter, which can't
+// load packages that use unsafe or syscall. The beeline and libhoney both
+// do, so this package imports neither: it is a module of its own that uses
+// only the standard library, and sends its events to the Honeycomb API
+// itself. Its events have the fields hnynethttp.WrapHandler records, and its
+// sampling decisions are the beeline's, so its spans fit in the traces of
+// beeline-instrumented backends.
+//
+// Traefik loads plugins named in its static configuration. Plugins from its
+// catalog are fetched from a repository of their own; this one is run as a
+// local plugin, with the module's source in
+// ./plugins-local/src/github.com/honeycombio/beeline-go/wrappers/hnytraefik:
+//
+//	experimental:
+//	  localPlugins:
+//	    honeycomb:
+//	      moduleName: github.com/honeycombio/beeline-go/wrappers/hnytraefik
+//
+// and add it to a router's middlewares:
+//
+//	http:
+//	  middlewares:
+//	    honeycomb:
+//	      plugin:
+//	        honeycomb:
+//	          writeKey: YOUR_WRITE_KEY
+//	          dataset: edge
+//	          serviceName: traefik
+//
+// Each request gets a span, and the request forwarded to the backend carries
+// the X-Honeycomb-Trace and, for trace ids the W3C format can hold,
+// traceparent headers, so instrumented backends continue the trace the proxy
+// started. A trace header already on the incoming request is continued too.
+//
+// Events are sent in batches from a background goroutine, so requests aren't
+// held up sending them. Events that can't be queued because the API is slow
+// to take them are dropped.
+package hnytraefik
@@ -0,0 +1,5 @@
+module github.com/honeycombio/beeline-go/wrappers/hnytraefik
+
+go 1.21
+
+require github.com/traefik/yaegi v0.16.1
@@ -0,0 +1,117 @@
+package hnytraefik
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	honeycombHeader   = "X-Honeycomb-Trace"
+	traceparentHeader = "traceparent"
+)
+
+// traceContext is the trace a request continues, read from its headers.
+type traceContext struct {
+	traceID  string
+	parentID string
+	// dataset and context are the clauses of the same names in the
+	// X-Honeycomb-Trace header, passed on to the backend as they came.
+	dataset string
+	context string
+}
+
+// parseHoneycombHeader reads a version 1 X-Honeycomb-Trace header, of the
+// form 1;trace_id=...,parent_id=...,dataset=...,context=..., returning false
+// if it has no trace id or no parent id.
+func parseHoneycombHeader(header string) (traceContext, bool) {
+	var tc traceContext
+	if !strings.HasPrefix(header, "1;") {
+		return tc, false
+	}
+	for _, clause := range strings.Split(header[2:], ",") {
+		keyval := strings.SplitN(clause, "=", 2)
+		if len(keyval) < 2 {
+			continue
+		}
+		switch keyval[0] {
+		case "trace_id":
+			tc.traceID = keyval[1]
+		case "parent_id":
+			tc.parentID = keyval[1]
+		case "dataset":
+			tc.dataset = keyval[1]
+		case "context":
+			tc.context = keyval[1]
+		}
+	}
+	return tc, tc.traceID != "" && tc.parentID != ""
+}
+
+// parseTraceparent reads a W3C traceparent header, of the form
+// 00-<trace id>-<parent id>-<flags>.
+func parseTraceparent(header string) (traceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return traceContext{}, false
+	}
+	if !isHex(parts[1], 32) || !isHex(parts[2], 16) {
+		return traceContext{}, false
+	}
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return traceContext{}, false
+	}
+	return traceContext{traceID: parts[1], parentID: parts[2]}, true
+}
+
+// honeycombHeader returns the X-Honeycomb-Trace header for a request made
+// from the span spanID in this trace.
+func (tc traceContext) honeycombHeader(spanID string) string {
+	header := "1;trace_id=" + tc.traceID + ",parent_id=" + spanID
+	if tc.dataset != "" {
+		header += ",dataset=" + tc.dataset
+	}
+	if tc.context != "" {
+		header += ",context=" + tc.context
+	}
+	return header
+}
+
+// traceparent returns the W3C traceparent header for a request made from the
+// span spanID in this trace, or "" if the trace id isn't one the W3C format
+// can hold.
+func (tc traceContext) traceparent(spanID string) string {
+	traceID := tc.traceID
+	if isHex(traceID, 16) {
+		// 64 bit trace ids are padded, as the beeline does
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if !isHex(traceID, 32) || !isHex(spanID, 16) {
+		return ""
+	}
+	return "00-" + traceID + "-" + spanID + "-01"
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// newID returns a random id of n bytes, hex encoded, as the beeline makes
+// trace ids of 16 bytes and span ids of 8.
+func newID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on the platforms Traefik runs on
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,122 @@
+package hnytraefik
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pendingEvents is how many events can wait to be sent before more are
+	// dropped.
+	pendingEvents = 10000
+	// maxBatchSize is the most events sent in one request to the API.
+	maxBatchSize = 50
+	// batchTimeout is the longest an event waits for a batch to fill.
+	batchTimeout = 100 * time.Millisecond
+)
+
+// event is an event waiting to be sent, in the form the batch API takes.
+type event map[string]interface{}
+
+// sender sends events to a dataset in batches, from a goroutine of its own.
+type sender struct {
+	url      string
+	writeKey string
+	client   *http.Client
+	events   chan event
+}
+
+var (
+	sendersMu sync.Mutex
+	// senders holds a sender for each dataset the middleware sends to. Traefik
+	// calls New again for every router, and whenever its configuration is
+	// reloaded, so the middlewares share senders rather than each starting a
+	// goroutine of its own.
+	senders = map[string]*sender{}
+)
+
+// getSender returns the sender for config's API host, dataset and write key,
+// starting it if there isn't one yet.
+func getSender(config *Config) *sender {
+	apiURL := strings.TrimSuffix(config.APIHost, "/") + "/1/batch/" + url.PathEscape(config.Dataset)
+	key := apiURL + "\n" + config.WriteKey
+
+	sendersMu.Lock()
+	defer sendersMu.Unlock()
+	if s, ok := senders[key]; ok {
+		return s
+	}
+	s := &sender{
+		url:      apiURL,
+		writeKey: config.WriteKey,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		events:   make(chan event, pendingEvents),
+	}
+	go s.run()
+	senders[key] = s
+	return s
+}
+
+// add queues fields to be sent, dropping them if the queue is full.
+func (s *sender) add(fields map[string]interface{}, sampleRate uint, timestamp time.Time) {
+	ev := event{
+		"data": fields,
+		"time": timestamp.UTC().Format(time.RFC3339Nano),
+	}
+	if sampleRate > 1 {
+		ev["samplerate"] = sampleRate
+	}
+	select {
+	case s.events <- ev:
+	default:
+	}
+}
+
+func (s *sender) run() {
+	batch := make([]event, 0, maxBatchSize)
+	timer := time.NewTimer(batchTimeout)
+	for {
+		select {
+		case ev := <-s.events:
+			batch = append(batch, ev)
+			if len(batch) < maxBatchSize {
+				continue
+			}
+		case <-timer.C:
+			timer.Reset(batchTimeout)
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		s.send(batch)
+		batch = batch[:0]
+	}
+}
+
+// send posts batch to the API. Events the API doesn't take aren't retried.
+func (s *sender) send(batch []event) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Honeycomb-Team", s.writeKey)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	// read the body so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
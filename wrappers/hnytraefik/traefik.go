@@ -0,0 +1,261 @@
+package hnytraefik
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Config is the plugin's configuration, as set under the middleware's plugin
+// options.
+type Config struct {
+	// WriteKey is the Honeycomb write key. It is required.
+	WriteKey string `json:"writeKey,omitempty"`
+	// Dataset is the dataset to send events to. default: traefik
+	Dataset string `json:"dataset,omitempty"`
+	// APIHost overrides the Honeycomb API host.
+	// default: https://api.honeycomb.io
+	APIHost string `json:"apiHost,omitempty"`
+	// ServiceName is added to every event as service_name. default: traefik
+	ServiceName string `json:"serviceName,omitempty"`
+	// SampleRate keeps 1 in SampleRate traces, deciding by trace id as the
+	// beeline's deterministic sampler does. default: 1
+	SampleRate uint `json:"sampleRate,omitempty"`
+}
+
+// CreateConfig returns the default configuration. Traefik calls it before
+// decoding the middleware's options into it.
+func CreateConfig() *Config {
+	return &Config{
+		Dataset:     "traefik",
+		APIHost:     "https://api.honeycomb.io",
+		ServiceName: "traefik",
+		SampleRate:  1,
+	}
+}
+
+// New returns the middleware, wrapping next. Traefik calls it for each router
+// that uses the middleware.
+func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	if config.WriteKey == "" {
+		return nil, errors.New("hnytraefik: writeKey is required")
+	}
+	if config.Dataset == "" {
+		return nil, errors.New("hnytraefik: dataset is required")
+	}
+	if config.APIHost == "" {
+		config.APIHost = "https://api.honeycomb.io"
+	}
+	sampleRate := config.SampleRate
+	if sampleRate < 1 {
+		sampleRate = 1
+	}
+	return &middleware{
+		next:        next,
+		name:        name,
+		serviceName: config.ServiceName,
+		sampleRate:  sampleRate,
+		upperBound:  math.MaxUint32 / uint32(sampleRate),
+		sender:      getSender(config),
+	}, nil
+}
+
+type middleware struct {
+	next        http.Handler
+	name        string
+	serviceName string
+	sampleRate  uint
+	upperBound  uint32
+	sender      *sender
+}
+
+// sampled reports whether the trace traceID is kept, making the same
+// decision as the beeline's deterministic sampler at the same rate.
+func (m *middleware) sampled(traceID string) bool {
+	if m.sampleRate == 1 {
+		return true
+	}
+	sum := sha1.Sum([]byte(traceID))
+	v := uint32(sum[3]) | uint32(sum[2])<<8 | uint32(sum[1])<<16 | uint32(sum[0])<<24
+	return v <= m.upperBound
+}
+
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	tc, ok := parseHoneycombHeader(r.Header.Get(honeycombHeader))
+	if !ok {
+		tc, ok = parseTraceparent(r.Header.Get(traceparentHeader))
+	}
+	if !ok {
+		tc = traceContext{traceID: newID(16)}
+	}
+	spanID := newID(8)
+
+	fields := requestFields(r)
+	fields["name"] = m.name
+	fields["handler.name"] = m.name
+	if m.serviceName != "" {
+		fields["service_name"] = m.serviceName
+	}
+	fields["trace.trace_id"] = tc.traceID
+	fields["trace.span_id"] = spanID
+	if tc.parentID != "" {
+		fields["trace.parent_id"] = tc.parentID
+	}
+
+	// the backend continues the trace from this span
+	r.Header.Set(honeycombHeader, tc.honeycombHeader(spanID))
+	if tp := tc.traceparent(spanID); tp != "" {
+		r.Header.Set(traceparentHeader, tp)
+	} else {
+		r.Header.Del(traceparentHeader)
+	}
+
+	rw := &responseWriter{ResponseWriter: w}
+	defer func() {
+		if p := recover(); p != nil {
+			if rw.status == 0 {
+				rw.status = http.StatusInternalServerError
+			}
+			fields["panic.value"] = fmt.Sprint(p)
+			m.send(fields, rw, start)
+			panic(p)
+		}
+	}()
+	m.next.ServeHTTP(rw, r)
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if err := r.Context().Err(); err != nil {
+		fields["request.context_error"] = err.Error()
+	}
+	m.send(fields, rw, start)
+}
+
+// send adds the response's fields and the span's duration to fields, and
+// sends them if the trace is sampled.
+func (m *middleware) send(fields map[string]interface{}, rw *responseWriter, start time.Time) {
+	if !m.sampled(fields["trace.trace_id"].(string)) {
+		return
+	}
+	h := rw.Header()
+	if cl := h.Get("Content-Length"); cl != "" {
+		fields["response.content_length"] = cl
+	}
+	if ct := h.Get("Content-Type"); ct != "" {
+		fields["response.content_type"] = ct
+	}
+	if ce := h.Get("Content-Encoding"); ce != "" {
+		fields["response.content_encoding"] = ce
+	}
+	fields["response.status_code"] = rw.status
+	fields["response.bytes_written"] = rw.bytesWritten
+	if rw.hijacked {
+		fields["response.hijacked"] = true
+	}
+	if rw.err != nil {
+		fields["error"] = rw.err.Error()
+	}
+	fields["duration_ms"] = float64(time.Since(start)) / float64(time.Millisecond)
+	m.sender.add(fields, m.sampleRate, start)
+}
+
+// requestFields returns the fields hnynethttp records for every request.
+func requestFields(r *http.Request) map[string]interface{} {
+	fields := map[string]interface{}{
+		"meta.type":              "http_request",
+		"request.method":         r.Method,
+		"request.path":           r.URL.Path,
+		"request.url":            r.URL.String(),
+		"request.host":           r.Host,
+		"request.http_version":   r.Proto,
+		"request.content_length": r.ContentLength,
+		"request.remote_addr":    r.RemoteAddr,
+	}
+	if r.URL.RawQuery != "" {
+		fields["request.query"] = r.URL.RawQuery
+	}
+	if ua := r.UserAgent(); ua != "" {
+		fields["request.header.user_agent"] = ua
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		fields["request.header.x_forwarded_for"] = xff
+	}
+	if xfp := r.Header.Get("X-Forwarded-Proto"); xfp != "" {
+		fields["request.header.x_forwarded_proto"] = xfp
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		fields["request.client_ip"] = host
+	}
+	return fields
+}
+
+// ErrorRecorder is implemented by the http.ResponseWriter the middleware
+// passes to next. Handlers that leave an error response to be written after
+// the middleware returns, as Caddy's do, record the error and the status it
+// will be sent with on it.
+type ErrorRecorder interface {
+	RecordError(status int, err error)
+}
+
+// responseWriter records the status and size of the response the backend
+// sends through the proxy.
+type responseWriter struct {
+	http.ResponseWriter
+	status       int
+	bytesWritten int
+	hijacked     bool
+	err          error
+}
+
+// RecordError records err, and status if no response has been written.
+func (rw *responseWriter) RecordError(status int, err error) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.err = err
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	// 1xx responses are followed by the real one
+	if rw.status == 0 && (status < 100 || status >= 200) {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += n
+	return n, err
+}
+
+// Flush passes flushes on, so streamed responses such as server sent events
+// aren't held up by the middleware.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes hijacks on, so websockets can be proxied.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hnytraefik: the response writer doesn't support hijacking")
+	}
+	rw.hijacked = true
+	if rw.status == 0 {
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,238 @@
+package hnytraefik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI is a Honeycomb batch API that keeps the events sent to it.
+type fakeAPI struct {
+	*httptest.Server
+	mu     sync.Mutex
+	events []map[string]interface{}
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/batch/edge" || r.Header.Get("X-Honeycomb-Team") != "key" {
+			t.Errorf("unexpected request to %s with write key %q", r.URL.Path, r.Header.Get("X-Honeycomb-Team"))
+		}
+		var batch []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("decoding batch: %v", err)
+		}
+		api.mu.Lock()
+		api.events = append(api.events, batch...)
+		api.mu.Unlock()
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// waitForEvents returns the events sent to the API once there are n of them.
+func (api *fakeAPI) waitForEvents(t *testing.T, n int) []map[string]interface{} {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		api.mu.Lock()
+		events := api.events
+		api.mu.Unlock()
+		if len(events) >= n {
+			return events
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d events to be sent", n)
+	return nil
+}
+
+func newMiddleware(t *testing.T, api *fakeAPI, next http.Handler) http.Handler {
+	config := CreateConfig()
+	config.WriteKey = "key"
+	config.Dataset = "edge"
+	config.APIHost = api.URL
+	h, err := New(context.Background(), next, config, "honeycomb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestNewRequiresWriteKey(t *testing.T) {
+	_, err := New(context.Background(), http.NotFoundHandler(), CreateConfig(), "honeycomb")
+	if err == nil {
+		t.Error("expected an error without a write key")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	api := newFakeAPI(t)
+	var backendHeader, backendTraceparent string
+	h := newMiddleware(t, api, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendHeader = r.Header.Get("X-Honeycomb-Trace")
+		backendTraceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+
+	r := httptest.NewRequest("POST", "http://example.com/hello?honey=yes", nil)
+	r.Header.Set("User-Agent", "test")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	ev := api.waitForEvents(t, 1)[0]
+	if ev["time"] == nil {
+		t.Error("expected the event to have a time")
+	}
+	fields := ev["data"].(map[string]interface{})
+	for k, v := range map[string]interface{}{
+		"name":                      "honeycomb",
+		"service_name":              "traefik",
+		"meta.type":                 "http_request",
+		"request.method":            "POST",
+		"request.path":              "/hello",
+		"request.query":             "honey=yes",
+		"request.host":              "example.com",
+		"request.header.user_agent": "test",
+		"response.status_code":      float64(201),
+		"response.content_type":     "text/plain",
+		"response.bytes_written":    float64(5),
+	} {
+		if fields[k] != v {
+			t.Errorf("%s: got %v, expected %v", k, fields[k], v)
+		}
+	}
+	if _, ok := fields["trace.parent_id"]; ok {
+		t.Error("a new trace's root span has no parent")
+	}
+	traceID, spanID := fields["trace.trace_id"].(string), fields["trace.span_id"].(string)
+	if len(traceID) != 32 || len(spanID) != 16 {
+		t.Errorf("unexpected ids %q and %q", traceID, spanID)
+	}
+	if expected := "1;trace_id=" + traceID + ",parent_id=" + spanID; backendHeader != expected {
+		t.Errorf("backend got X-Honeycomb-Trace %q, expected %q", backendHeader, expected)
+	}
+	if expected := "00-" + traceID + "-" + spanID + "-01"; backendTraceparent != expected {
+		t.Errorf("backend got traceparent %q, expected %q", backendTraceparent, expected)
+	}
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	for _, tc := range []struct {
+		name, header, value, traceID, parentID, backendHeader string
+	}{
+		{
+			name:          "honeycomb",
+			header:        "X-Honeycomb-Trace",
+			value:         "1;trace_id=abcdef,parent_id=123456,dataset=edge,context=e30=",
+			traceID:       "abcdef",
+			parentID:      "123456",
+			backendHeader: "1;trace_id=abcdef,parent_id=%s,dataset=edge,context=e30=",
+		},
+		{
+			name:          "w3c",
+			header:        "traceparent",
+			value:         "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			traceID:       "0af7651916cd43dd8448eb211c80319c",
+			parentID:      "b7ad6b7169203331",
+			backendHeader: "1;trace_id=0af7651916cd43dd8448eb211c80319c,parent_id=%s",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := newFakeAPI(t)
+			var backendHeader string
+			h := newMiddleware(t, api, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				backendHeader = r.Header.Get("X-Honeycomb-Trace")
+			}))
+
+			r := httptest.NewRequest("GET", "http://example.com/", nil)
+			r.Header.Set(tc.header, tc.value)
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			fields := api.waitForEvents(t, 1)[0]["data"].(map[string]interface{})
+			if fields["trace.trace_id"] != tc.traceID || fields["trace.parent_id"] != tc.parentID {
+				t.Errorf("got trace %v and parent %v", fields["trace.trace_id"], fields["trace.parent_id"])
+			}
+			if fields["response.status_code"] != float64(200) {
+				t.Errorf("got status %v", fields["response.status_code"])
+			}
+			expected := strings.Replace(tc.backendHeader, "%s", fields["trace.span_id"].(string), 1)
+			if backendHeader != expected {
+				t.Errorf("backend got X-Honeycomb-Trace %q, expected %q", backendHeader, expected)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRecordsPanic(t *testing.T) {
+	api := newFakeAPI(t)
+	h := newMiddleware(t, api, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to be passed on")
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/", nil))
+	}()
+
+	fields := api.waitForEvents(t, 1)[0]["data"].(map[string]interface{})
+	if fields["response.status_code"] != float64(500) || fields["panic.value"] != "boom" {
+		t.Errorf("got status %v and panic %v", fields["response.status_code"], fields["panic.value"])
+	}
+}
+
+func TestMiddlewareRecordsError(t *testing.T) {
+	api := newFakeAPI(t)
+	h := newMiddleware(t, api, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(ErrorRecorder).RecordError(http.StatusBadGateway, errors.New("no upstream"))
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/", nil))
+
+	fields := api.waitForEvents(t, 1)[0]["data"].(map[string]interface{})
+	if fields["response.status_code"] != float64(502) || fields["error"] != "no upstream" {
+		t.Errorf("got status %v and error %v", fields["response.status_code"], fields["error"])
+	}
+}
+
+// TestSampledMatchesBeeline checks sampling decisions against those of
+// the beeline's deterministic sampler, from its interop test.
+func TestSampledMatchesBeeline(t *testing.T) {
+	m := &middleware{sampleRate: 2, upperBound: 1<<32/2 - 1}
+	for id, expected := range map[string]bool{
+		"4YeYygWjTZ41zOBKUoYUaSVxPGm78rdU": false,
+		"iow4KAFBl9u6lF4EYIcsFz60rXGvu7ph": true,
+		"EgQMHtruEfqaqQqRs5nwaDXsegFGmB5n": true,
+		"UnVVepVdyGIiwkHwofyva349tVu8QSDn": true,
+		"rWuxi2uZmBEprBBpxLLFcKtXHA8bQkvJ": true,
+		"8PV5LN1IGm5T0ZVIaakb218NvTEABNZz": false,
+	} {
+		if m.sampled(id) != expected {
+			t.Errorf("got unexpected decision for %s, expected %t", id, expected)
+		}
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	for _, tc := range []struct{ traceID, expected string }{
+		{"0af7651916cd43dd8448eb211c80319c", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		{"8448eb211c80319c", "00-00000000000000008448eb211c80319c-b7ad6b7169203331-01"},
+		{"abcdef", ""},
+	} {
+		if got := (traceContext{traceID: tc.traceID}).traceparent("b7ad6b7169203331"); got != tc.expected {
+			t.Errorf("traceparent for %s: got %q, expected %q", tc.traceID, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package hnytraefik
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// TestYaegi loads the plugin the way Traefik does, from source in the Yaegi
+// interpreter with only the standard library available, and sends a request
+// through it.
+func TestYaegi(t *testing.T) {
+	gopath := t.TempDir()
+	dir := filepath.Join(gopath, "src", "github.com", "honeycombio", "beeline-go", "wrappers", "hnytraefik")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range sources {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	i := interp.New(interp.Options{GoPath: gopath})
+	if err := i.Use(stdlib.Symbols); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.Eval(`import "github.com/honeycombio/beeline-go/wrappers/hnytraefik"`); err != nil {
+		t.Fatalf("the plugin doesn't load in yaegi: %v", err)
+	}
+	createConfig, err := i.Eval("hnytraefik.CreateConfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newMiddleware, err := i.Eval("hnytraefik.New")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := newFakeAPI(t)
+	config := createConfig.Call(nil)[0]
+	config.Elem().FieldByName("WriteKey").SetString("key")
+	config.Elem().FieldByName("Dataset").SetString("edge")
+	config.Elem().FieldByName("APIHost").SetString(api.URL)
+	var backendHeader string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendHeader = r.Header.Get("X-Honeycomb-Trace")
+	})
+	results := newMiddleware.Call([]reflect.Value{
+		reflect.ValueOf(context.Background()),
+		reflect.ValueOf(next),
+		config,
+		reflect.ValueOf("honeycomb"),
+	})
+	if err, _ := results[1].Interface().(error); err != nil {
+		t.Fatal(err)
+	}
+	h := results[0].Interface().(http.Handler)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/", nil))
+
+	fields := api.waitForEvents(t, 1)[0]["data"].(map[string]interface{})
+	if fields["name"] != "honeycomb" || fields["response.status_code"] != float64(200) {
+		t.Errorf("got name %v and status %v", fields["name"], fields["response.status_code"])
+	}
+	if !strings.HasPrefix(backendHeader, "1;trace_id="+fields["trace.trace_id"].(string)) {
+		t.Errorf("backend got X-Honeycomb-Trace %q", backendHeader)
+	}
+}
@@ -0,0 +1,30 @@
+// Package hnywebsocket records gorilla/websocket connections, which outlive
+// the request that upgraded them and so are otherwise just one long request
+// span.
+//
+// Upgrade the connection in a handler wrapped by one of the HTTP wrappers,
+// and close it when done:
+//
+//	conn, err := hnywebsocket.Upgrade(&upgrader, w, r, nil, hnywebsocket.WithHeartbeat(time.Minute))
+//	if err != nil {
+//		return
+//	}
+//	defer conn.Close()
+//	for {
+//		messageType, p, err := conn.ReadMessage()
+//		...
+//	}
+//
+// The connection gets a span named websocket, a child of the request's span,
+// that is sent when it is closed. It records the number of messages and bytes
+// each way in websocket.messages_in, websocket.messages_out,
+// websocket.bytes_in and websocket.bytes_out, read and write errors in
+// websocket.errors, and the close code the peer sent in websocket.close_code.
+//
+// WithMessageSpans adds a child span for each message with
+// websocket.direction (in or out), websocket.opcode (text or binary) and
+// websocket.message_bytes. WithHeartbeat sends a summary of the traffic every
+// interval instead, as beeline.StartHeartbeat does. Use Conn.Context for work
+// done on behalf of the connection, so its spans are children of the
+// connection's.
+package hnywebsocket
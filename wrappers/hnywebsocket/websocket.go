@@ -0,0 +1,240 @@
+package hnywebsocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/common"
+)
+
+// Option configures how a connection is recorded.
+type Option func(*config)
+
+type config struct {
+	messageSpans bool
+	heartbeat    time.Duration
+}
+
+// WithMessageSpans sends a child span of the connection's span for every data
+// message read or written. Busy connections make a lot of them, so consider
+// WithHeartbeat instead.
+func WithMessageSpans() Option {
+	return func(c *config) {
+		c.messageSpans = true
+	}
+}
+
+// WithHeartbeat summarizes the connection's traffic in a heartbeat span every
+// interval, as beeline.StartHeartbeat does, so that it shows up while the
+// connection is open rather than only once it closes.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(c *config) {
+		c.heartbeat = interval
+	}
+}
+
+// Conn is a websocket connection that records its lifetime in a span, sent
+// when it is closed. Its ReadMessage, WriteMessage, ReadJSON and WriteJSON
+// methods count the messages and bytes each way; messages read or written
+// through NextReader and NextWriter aren't counted.
+type Conn struct {
+	*websocket.Conn
+
+	ctx       context.Context
+	span      *trace.Span
+	heartbeat *beeline.Heartbeat
+	config    config
+
+	lock                    sync.Mutex
+	messagesIn, messagesOut int64
+	bytesIn, bytesOut       int64
+	errors                  int64
+	closeErr                *websocket.CloseError
+
+	closeOnce sync.Once
+}
+
+// Upgrade upgrades the request's connection with upgrader, as
+// upgrader.Upgrade does, and records the connection in a span that is a child
+// of the request's span. If the upgrade fails the error is recorded on the
+// request's span as websocket.upgrade_error.
+func Upgrade(upgrader *websocket.Upgrader, w http.ResponseWriter, r *http.Request, responseHeader http.Header, opts ...Option) (*Conn, error) {
+	c, err := upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		if span := trace.GetSpanFromContext(r.Context()); span != nil {
+			span.AddField("websocket.upgrade_error", err.Error())
+		}
+		return nil, err
+	}
+	return Wrap(r.Context(), c, opts...), nil
+}
+
+// Wrap records c, a connection that has already been upgraded or dialled, in
+// a span that is a child of the span in ctx.
+func Wrap(ctx context.Context, c *websocket.Conn, opts ...Option) *Conn {
+	conn := &Conn{Conn: c}
+	for _, opt := range opts {
+		opt(&conn.config)
+	}
+	conn.ctx, conn.span = common.StartChildSpan(ctx)
+	conn.span.AddField("meta.type", "websocket")
+	conn.span.AddField("name", "websocket")
+	if p := c.Subprotocol(); p != "" {
+		conn.span.AddField("websocket.subprotocol", p)
+	}
+	if addr := c.RemoteAddr(); addr != nil {
+		conn.span.AddField("websocket.remote_addr", addr.String())
+	}
+	if conn.config.heartbeat > 0 {
+		conn.heartbeat = beeline.StartHeartbeat(conn.ctx, conn.config.heartbeat)
+	}
+	return conn
+}
+
+// Context returns a context holding the connection's span, for work done on
+// behalf of the connection.
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+// ReadMessage reads the next data message, as websocket.Conn.ReadMessage
+// does. With WithMessageSpans, the message's span includes the time spent
+// waiting for it to arrive.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType, p, err := c.Conn.ReadMessage()
+	c.record(c.startMessage(), "in", messageType, len(p), err)
+	return messageType, p, err
+}
+
+// WriteMessage writes a data message, as websocket.Conn.WriteMessage does.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	span := c.startMessage()
+	err := c.Conn.WriteMessage(messageType, data)
+	c.record(span, "out", messageType, len(data), err)
+	return err
+}
+
+// ReadJSON reads the next message and decodes it from JSON into v.
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, p, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(p, v)
+}
+
+// WriteJSON writes v as a JSON encoded text message.
+func (c *Conn) WriteJSON(v interface{}) error {
+	p, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(websocket.TextMessage, p)
+}
+
+// Close closes the connection and sends its span, with the number of
+// messages and bytes read and written, the number of errors and the close
+// code the peer sent, if it sent one. Only the first call sends the span.
+func (c *Conn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.heartbeat.Stop()
+		c.lock.Lock()
+		c.span.AddField("websocket.messages_in", c.messagesIn)
+		c.span.AddField("websocket.messages_out", c.messagesOut)
+		c.span.AddField("websocket.bytes_in", c.bytesIn)
+		c.span.AddField("websocket.bytes_out", c.bytesOut)
+		c.span.AddField("websocket.errors", c.errors)
+		if c.closeErr != nil {
+			c.span.AddField("websocket.close_code", c.closeErr.Code)
+			if c.closeErr.Text != "" {
+				c.span.AddField("websocket.close_text", c.closeErr.Text)
+			}
+		}
+		c.lock.Unlock()
+		c.span.Send()
+	})
+	return err
+}
+
+// startMessage starts the span for a message, or returns nil if message spans
+// are off.
+func (c *Conn) startMessage() *trace.Span {
+	if !c.config.messageSpans {
+		return nil
+	}
+	_, span := c.span.CreateChild(c.ctx)
+	return span
+}
+
+// record counts a message read or written, and sends its span if it has one.
+// Close frames from the peer are recorded as the reason the connection closed
+// rather than as errors, and have no span.
+func (c *Conn) record(span *trace.Span, direction string, messageType, size int, err error) {
+	closeErr, closed := err.(*websocket.CloseError)
+	c.lock.Lock()
+	switch {
+	case closed:
+		c.closeErr = closeErr
+	case err != nil:
+		c.errors++
+	case direction == "in":
+		c.messagesIn++
+		c.bytesIn += int64(size)
+	default:
+		c.messagesOut++
+		c.bytesOut += int64(size)
+	}
+	c.lock.Unlock()
+
+	switch {
+	case closed:
+	case err != nil:
+		c.heartbeat.Error()
+	case direction == "in":
+		c.heartbeat.MessageReceived(size)
+	default:
+		c.heartbeat.MessageSent(size)
+	}
+
+	if span == nil {
+		return
+	}
+	if closed {
+		span.Discard()
+		return
+	}
+	span.AddField("meta.type", "websocket_message")
+	span.AddField("name", "websocket."+direction)
+	span.AddField("websocket.direction", direction)
+	span.AddField("websocket.opcode", opcodeName(messageType))
+	span.AddField("websocket.message_bytes", size)
+	if err != nil {
+		span.AddField("error", err.Error())
+	}
+	span.Send()
+}
+
+// opcodeName names a websocket message type.
+func opcodeName(messageType int) string {
+	switch messageType {
+	case websocket.TextMessage:
+		return "text"
+	case websocket.BinaryMessage:
+		return "binary"
+	case websocket.CloseMessage:
+		return "close"
+	case websocket.PingMessage:
+		return "ping"
+	case websocket.PongMessage:
+		return "pong"
+	}
+	return "unknown"
+}
@@ -0,0 +1,109 @@
+package hnywebsocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	beeline "github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/beelinetest"
+	"github.com/honeycombio/beeline-go/wrappers/hnynethttp"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoServer echoes every message it reads until the client closes the
+// connection. The returned channel receives once the handler has returned.
+func echoServer(opts ...Option) (*httptest.Server, chan struct{}) {
+	upgrader := websocket.Upgrader{}
+	done := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(hnynethttp.WrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() { done <- struct{}{} }()
+		conn, err := Upgrade(&upgrader, w, r, nil, opts...)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msg map[string]interface{}
+		for conn.ReadJSON(&msg) == nil {
+			conn.WriteJSON(msg)
+		}
+	})))
+	return srv, done
+}
+
+func talk(t *testing.T, srv *httptest.Server) {
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, client.WriteJSON(map[string]interface{}{"n": i}))
+		var reply map[string]interface{}
+		assert.NoError(t, client.ReadJSON(&reply))
+		assert.Equal(t, float64(i), reply["n"])
+	}
+	client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"))
+	// wait for the server to close its end
+	client.ReadMessage()
+	client.Close()
+}
+
+func TestConnectionSpan(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	srv, done := echoServer()
+	defer srv.Close()
+	talk(t, srv)
+	<-done
+
+	conns := rec.SpansNamed("websocket")
+	if assert.Equal(t, 1, len(conns)) {
+		conn := conns[0]
+		assert.Equal(t, "websocket", conn["meta.type"])
+		assert.Equal(t, int64(2), conn["websocket.messages_in"])
+		assert.Equal(t, int64(2), conn["websocket.messages_out"])
+		assert.Equal(t, int64(16), conn["websocket.bytes_in"])
+		// the client's encoder adds a trailing newline to each message
+		assert.Equal(t, int64(14), conn["websocket.bytes_out"])
+		assert.Equal(t, int64(0), conn["websocket.errors"])
+		assert.Equal(t, websocket.CloseNormalClosure, conn["websocket.close_code"])
+		assert.Equal(t, "bye", conn["websocket.close_text"])
+	}
+	assert.Empty(t, rec.SpansNamed("websocket.in"), "no message spans by default")
+}
+
+func TestMessageSpans(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	srv, done := echoServer(WithMessageSpans())
+	defer srv.Close()
+	talk(t, srv)
+	<-done
+
+	conns := rec.SpansNamed("websocket")
+	in := rec.SpansNamed("websocket.in")
+	out := rec.SpansNamed("websocket.out")
+	if assert.Equal(t, 1, len(conns)) && assert.Equal(t, 2, len(in)) && assert.Equal(t, 2, len(out)) {
+		assert.Equal(t, "in", in[0]["websocket.direction"])
+		assert.Equal(t, "text", in[0]["websocket.opcode"])
+		assert.Equal(t, 8, in[0]["websocket.message_bytes"])
+		assert.Equal(t, "out", out[0]["websocket.direction"])
+		assert.Equal(t, conns[0]["trace.span_id"], out[0]["trace.parent_id"])
+	}
+}
+
+func TestUpgradeError(t *testing.T) {
+	rec := beelinetest.Init(beeline.Config{})
+	srv, done := echoServer()
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	<-done
+
+	evs := rec.Events()
+	if assert.Equal(t, 1, len(evs)) {
+		assert.Contains(t, evs[0], "websocket.upgrade_error")
+	}
+}
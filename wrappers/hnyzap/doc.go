@@ -0,0 +1,13 @@
+// Package hnyzap attaches zap log lines to the span of the request they were
+// logged during.
+//
+// zap loggers don't carry a context, so derive one for the request with
+// WithSpan:
+//
+//	logger := hnyzap.WithSpan(r.Context(), baseLogger)
+//	logger.Warn("slow lookup", zap.Int("user_id", id))
+//
+// Each line the logger writes is recorded on the span in the context as
+// described by the hnylog package, with the line's fields as `log.<field>`.
+// Only lines at levels the original logger is enabled for are recorded.
+package hnyzap
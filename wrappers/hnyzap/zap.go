@@ -0,0 +1,58 @@
+package hnyzap
+
+import (
+	"context"
+
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/honeycombio/beeline-go/wrappers/hnylog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithSpan returns a copy of logger that also records what it logs on the
+// span in ctx. If ctx has no span, logger is returned unchanged.
+func WithSpan(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if trace.GetSpanFromContext(ctx) == nil {
+		return logger
+	}
+	return logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, &spanCore{LevelEnabler: c, ctx: ctx})
+	}))
+}
+
+// spanCore is a zapcore.Core that records entries on a span.
+type spanCore struct {
+	zapcore.LevelEnabler
+	ctx    context.Context
+	fields []zapcore.Field
+}
+
+func (c *spanCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &spanCore{LevelEnabler: c.LevelEnabler, ctx: c.ctx, fields: all}
+}
+
+func (c *spanCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *spanCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	hnylog.Record(c.ctx, ent.Level.String(), ent.Message, enc.Fields)
+	return nil
+}
+
+func (c *spanCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,54 @@
+package hnyzap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	beeline "github.com/honeycombio/beeline-go"
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func setupLibhoney(t *testing.T) *transmission.MockSender {
+	mo := &transmission.MockSender{}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       "placeholder",
+		Dataset:      "placeholder",
+		APIHost:      "placeholder",
+		Transmission: mo})
+	assert.Equal(t, nil, err)
+	beeline.Init(beeline.Config{Client: client})
+	return mo
+}
+
+func TestWithSpan(t *testing.T) {
+	mo := setupLibhoney(t)
+	core, logs := observer.New(zap.WarnLevel)
+	base := zap.New(core)
+	assert.Equal(t, base, WithSpan(context.Background(), base))
+
+	ctx, span := beeline.StartSpan(context.Background(), "request")
+	logger := WithSpan(ctx, base).With(zap.String("tenant", "acme"))
+	logger.Info("below the logger's level")
+	logger.Warn("slow lookup", zap.Int("user_id", 7))
+	logger.Error("lookup failed", zap.Error(errors.New("timeout")))
+	span.Send()
+
+	assert.Equal(t, 2, logs.Len(), "lines should still be logged")
+	evs := mo.Events()
+	if assert.Equal(t, 3, len(evs)) {
+		warn, failed, root := evs[0].Data, evs[1].Data, evs[2].Data
+		assert.Equal(t, "warn", warn["log.level"])
+		assert.Equal(t, "slow lookup", warn["log.message"])
+		assert.Equal(t, int64(7), warn["log.user_id"])
+		assert.Equal(t, "acme", warn["log.tenant"])
+		assert.Equal(t, "error", failed["log.level"])
+		assert.Equal(t, "timeout", failed["log.error"])
+		assert.Equal(t, float64(2), root["rollup.log.count"])
+		assert.Equal(t, float64(1), root["rollup.log.warn_count"])
+	}
+}